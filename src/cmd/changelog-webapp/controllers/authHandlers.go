@@ -23,6 +23,7 @@ import (
 	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cos.googlesource.com/cos/tools.git/src/pkg/httpclient"
 	"github.com/gorilla/sessions"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
@@ -45,7 +46,7 @@ var config = &oauth2.Config{
 	ClientSecret: "",
 	Endpoint:     google.Endpoint,
 	RedirectURL:  "",
-	Scopes:       []string{"https://www.googleapis.com/auth/gerritcodereview"},
+	Scopes:       []string{httpclient.GerritScope},
 }
 var store *sessions.CookieStore
 var projectID = os.Getenv("COS_CHANGELOG_PROJECT_ID")
@@ -54,7 +55,9 @@ var clientSecretName = os.Getenv("COS_CHANGELOG_CLIENT_SECRET_NAME")
 var sessionSecretName = os.Getenv("COS_CHANGELOG_SESSION_SECRET_NAME")
 var redirectURL = os.Getenv("COS_CHANGELOG_OAUTH_CALLBACK_NAME")
 
-func init() {
+// initAuth retrieves the OAuth client configuration and the session secret
+// from Secret Manager.
+func initAuth() {
 	var err error
 	client, err := secretmanager.NewClient(context.Background())
 	if err != nil {
@@ -163,7 +166,7 @@ func HTTPClient(w http.ResponseWriter, r *http.Request) (*http.Client, error) {
 		TokenType:    session.Values["tokenType"].(string),
 		Expiry:       parsedExpiry,
 	}
-	return config.Client(context.Background(), token), nil
+	return httpclient.NewWithTokenSource(config.TokenSource(context.Background(), token), nil), nil
 }
 
 // HandleLogin initiates the Oauth flow.
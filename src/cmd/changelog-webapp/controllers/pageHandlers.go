@@ -16,15 +16,23 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	htmltemplate "html/template"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/storage"
 	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
 	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
 	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
@@ -34,6 +42,19 @@ import (
 
 const (
 	subjectLen int = 100
+
+	// Maximum number of CLs accepted by a single batch findbuild request, and
+	// the number of CLs searched for concurrently.
+	maxBatchCLs        int = 50
+	batchFindBuildJobs int = 5
+
+	// Number of changelogs of a build chain retrieved for a single page. The
+	// remaining changelogs are linked so they can be loaded separately.
+	chainPageLinks int = 3
+
+	// Changelogs between two fixed builds never change, so they can be cached
+	// by the browser. Responses are private since they may contain internal data.
+	changelogCacheControl string = "private, max-age=86400"
 )
 
 var (
@@ -46,14 +67,23 @@ var (
 	externalFallbackGerritInstance string
 	externalGoBInstance            string
 	externalManifestRepo           string
+	fallbackRepoPrefix             string
+	fallbackRepoPrefixRules        []findbuild.RepoPrefixRule
 	envQuerySize                   string
 	envBoard                       string
 	artifactsBucket                string
+	storageClient                  *storage.Client
+
+	// externalManifestCache stores the external manifests retrieved for
+	// changelogs. Internal manifests are not cached, since they are
+	// retrieved with the credentials of each user.
+	externalManifestCache = changelog.NewManifestCache(0)
 
 	staticBasePath            string
 	indexTemplate             *template.Template
 	readme                    *template.Template
 	changelogTemplate         *template.Template
+	changelogChainTemplate    *htmltemplate.Template
 	promptLoginTemplate       *template.Template
 	findBuildTemplate         *template.Template
 	findReleasedBuildTemplate *template.Template
@@ -61,7 +91,15 @@ var (
 	basicTextTemplate         *template.Template
 )
 
-func init() {
+// newHTTPClient creates the HTTP client used to make requests on behalf of
+// the signed in user. It is replaced in tests.
+var newHTTPClient = HTTPClient
+
+// Init retrieves the configuration of the handlers from Secret Manager and
+// the environment, and parses the page templates. It must be called before
+// any request is served.
+func Init() {
+	initAuth()
 	var err error
 	client, err := secretmanager.NewClient(context.Background())
 	if err != nil {
@@ -92,16 +130,39 @@ func init() {
 		log.Fatalf("Failed to retrieve secret for COS_CHANGELOG_ARTIFACTS_BUCKET_NAME with key name %s\n%v", os.Getenv("COS_CHANGELOG_ARTIFACTS_BUCKET_NAME"), err)
 	}
 
+	// The storage client is shared by all requests to avoid creating a new
+	// connection pool for every changelog.
+	storageClient, err = storage.NewClient(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to setup storage client: %v", err)
+	}
+
 	externalGerritInstance = os.Getenv("COS_EXTERNAL_GERRIT_INSTANCE")
 	externalFallbackGerritInstance = os.Getenv("COS_EXTERNAL_FALLBACK_GERRIT_INSTANCE")
 	externalGoBInstance = os.Getenv("COS_EXTERNAL_GOB_INSTANCE")
 	externalManifestRepo = os.Getenv("COS_EXTERNAL_MANIFEST_REPO")
+	fallbackRepoPrefix = findbuild.FallbackRepoPrefix
+	if prefix, ok := os.LookupEnv("COS_FALLBACK_REPO_PREFIX"); ok {
+		fallbackRepoPrefix = prefix
+	}
+	fallbackRepoPrefixRules, err = findbuild.ParseRepoPrefixRules(os.Getenv("COS_FALLBACK_REPO_PREFIX_MAP"))
+	if err != nil {
+		log.Fatalf("Failed to parse COS_FALLBACK_REPO_PREFIX_MAP: %v", err)
+	}
 	envBoard = os.Getenv("BOARD_NAME")
 	envQuerySize = getIntVerifiedEnv("CHANGELOG_QUERY_SIZE")
 	staticBasePath = os.Getenv("STATIC_BASE_PATH")
+	parseTemplates()
+}
+
+// parseTemplates parses the page templates in staticBasePath.
+func parseTemplates() {
 	indexTemplate = template.Must(template.ParseFiles(staticBasePath + "templates/index.html"))
 	readme = template.Must(template.ParseFiles(staticBasePath + "templates/readme.html"))
 	changelogTemplate = template.Must(template.ParseFiles(staticBasePath + "templates/changelog.html"))
+	// The chain page echoes the builds requested by the user, so it is
+	// escaped by html/template.
+	changelogChainTemplate = htmltemplate.Must(htmltemplate.ParseFiles(staticBasePath + "templates/changelogChain.html"))
 	findBuildTemplate = template.Must(template.ParseFiles(staticBasePath + "templates/findBuild.html"))
 	findReleasedBuildTemplate = template.Must(template.ParseFiles(staticBasePath + "templates/findReleasedBuild.html"))
 	promptLoginTemplate = template.Must(template.ParseFiles(staticBasePath + "templates/promptLogin.html"))
@@ -109,11 +170,18 @@ func init() {
 }
 
 type changelogData struct {
-	Source    string
-	Target    string
-	Additions map[string]*changelog.RepoLog
-	Removals  map[string]*changelog.RepoLog
-	Internal  bool
+	Source          string
+	Target          string
+	SourceBoard     string
+	SourceMilestone string
+	TargetBoard     string
+	TargetMilestone string
+	Additions       map[string]*changelog.RepoLog
+	Removals        map[string]*changelog.RepoLog
+	Internal        bool
+	// Sysctl is the difference between the sysctl parameters of the builds,
+	// or nil if they are on different milestones and were not compared.
+	Sysctl *changelog.SysctlDiff
 }
 
 type changelogPage struct {
@@ -129,18 +197,73 @@ type changelogPage struct {
 	Sysctl          sysctlChanges
 }
 
+type changelogChainPage struct {
+	Builds    string
+	QuerySize string
+	Internal  bool
+	Links     []*chainLinkPage
+	// MoreLink is the URL of the chain page for the changelogs that were
+	// not loaded on this page, or empty if all changelogs were loaded.
+	MoreLink string
+	// Error explains why the chain could not be compared, ex. because too
+	// many builds were requested.
+	Error string
+}
+
+type chainLinkPage struct {
+	Source string
+	Target string
+	// Page is nil if the changelog was not loaded or could not be retrieved.
+	Page *changelogPage
+	// Error is the HTML error message if the changelog could not be
+	// retrieved.
+	Error htmltemplate.HTML
+	// Link is the URL of the changelog page between Source and Target.
+	Link string
+}
+
 type sysctlChanges struct {
-	Changes  [][]string
-	NotFound string
+	// Changes lists the added, removed, then changed sysctl parameters.
+	Changes []sysctlRow
+	// NotFound explains why sysctl changes are missing, ex. because the
+	// sysctl artifact of a build was not found.
+	NotFound []string
 	NotEmpty bool
 }
 
+type sysctlRow struct {
+	// Change is "Added", "Removed" or "Changed".
+	Change   string
+	Name     string
+	OldValue string
+	NewValue string
+}
+
 type findBuildPage struct {
 	CL         string
 	CLNum      string
 	BuildNum   string
 	GerritLink string
 	Internal   bool
+	// Repo and Branch are the Gerrit project and branch the CL was
+	// submitted to, and Submitted is the formatted submission time.
+	Repo      string
+	Branch    string
+	Submitted string
+	// Signature describes whether the CL was uploaded with a trusted signed
+	// push. It is empty if the signature status is unknown.
+	Signature string
+}
+
+type batchBuildResult struct {
+	CL        string `json:"cl"`
+	CLNum     string `json:"clNum,omitempty"`
+	BuildNum  string `json:"buildNum,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// RetryAfter is the number of seconds after which a rate limited
+	// search may be retried, if known.
+	RetryAfter int `json:"retryAfter,omitempty"`
 }
 
 type statusPage struct {
@@ -156,11 +279,18 @@ type basicTextPage struct {
 }
 
 type repoTable struct {
-	Name          string
-	Additions     []*repoTableEntry
-	Removals      []*repoTableEntry
-	AdditionsLink string
-	RemovalsLink  string
+	Name           string
+	Additions      []*repoTableEntry
+	Removals       []*repoTableEntry
+	AdditionsLinks []*logLinkAttr
+	RemovalsLinks  []*logLinkAttr
+}
+
+// logLinkAttr is a link to the Gitiles log of the commits of a repository
+// that are not displayed.
+type logLinkAttr struct {
+	Text string
+	URL  string
 }
 
 type repoTableEntry struct {
@@ -199,11 +329,24 @@ func gobCommitLink(instance, repo, SHA string) string {
 	return fmt.Sprintf("https://%s/%s/+/%s", instance, repo, SHA)
 }
 
-func gobDiffLink(instance, repo, sourceSHA, targetSHA string, diffLink bool) string {
-	if !diffLink {
-		return fmt.Sprintf("https://%s/%s/+log/%s?n=10000", instance, repo, targetSHA)
+// gobLogLinks returns the links to the Gitiles log of the commits of a repo
+// log. If sameRepo is false, the source and target SHAs are from different
+// repositories, so the links show the log of the target SHA.
+func gobLogLinks(repoLog *changelog.RepoLog, sameRepo bool) []*logLinkAttr {
+	sourceSHA := repoLog.SourceSHA
+	if !sameRepo {
+		sourceSHA = ""
+	}
+	links := changelog.LogLinks(repoLog.InstanceURL, repoLog.Repo, sourceSHA, repoLog.TargetSHA, repoLog.CommitCount, repoLog.LogPageTokens)
+	attrs := make([]*logLinkAttr, len(links))
+	for i, link := range links {
+		text := "Show more commits"
+		if len(links) > 1 {
+			text = fmt.Sprintf("Show commits %d-%d", link.First, link.Last)
+		}
+		attrs[i] = &logLinkAttr{Text: text, URL: link.URL}
 	}
-	return fmt.Sprintf("https://%s/%s/+log/%s..%s?n=10000", instance, repo, sourceSHA, targetSHA)
+	return attrs
 }
 
 func createRepoTableEntry(instance, repo string, commit *changelog.Commit, isAddition bool) *repoTableEntry {
@@ -227,9 +370,19 @@ func createRepoTableEntry(instance, repo string, commit *changelog.Commit, isAdd
 }
 
 func createChangelogPage(data changelogData) *changelogPage {
-	page := &changelogPage{Source: data.Source, Target: data.Target, QuerySize: envQuerySize, Internal: data.Internal}
+	page := &changelogPage{
+		Source:          data.Source,
+		Target:          data.Target,
+		SourceBoard:     data.SourceBoard,
+		SourceMilestone: data.SourceMilestone,
+		TargetBoard:     data.TargetBoard,
+		TargetMilestone: data.TargetMilestone,
+		QuerySize:       envQuerySize,
+		Internal:        data.Internal,
+		Sysctl:          createSysctlChanges(data),
+	}
 	for repoPath, addLog := range data.Additions {
-		diffLink := false
+		sameRepo := true
 		table := &repoTable{Name: repoPath}
 		for _, commit := range addLog.Commits {
 			tableEntry := createRepoTableEntry(addLog.InstanceURL, addLog.Repo, commit, true)
@@ -240,13 +393,13 @@ func createChangelogPage(data changelogData) *changelogPage {
 				tableEntry := createRepoTableEntry(rmLog.InstanceURL, rmLog.Repo, commit, false)
 				table.Removals = append(table.Removals, tableEntry)
 			}
-			if data.Removals[repoPath].HasMoreCommits {
-				diffLink = addLog.Repo == rmLog.Repo
-				table.RemovalsLink = gobDiffLink(rmLog.InstanceURL, rmLog.Repo, addLog.TargetSHA, rmLog.TargetSHA, diffLink)
+			sameRepo = addLog.Repo == rmLog.Repo
+			if rmLog.HasMoreCommits {
+				table.RemovalsLinks = gobLogLinks(rmLog, sameRepo)
 			}
 		}
 		if addLog.HasMoreCommits {
-			table.AdditionsLink = gobDiffLink(addLog.InstanceURL, addLog.Repo, addLog.SourceSHA, addLog.TargetSHA, diffLink)
+			table.AdditionsLinks = gobLogLinks(addLog, sameRepo)
 		}
 		page.RepoTables = append(page.RepoTables, table)
 	}
@@ -262,30 +415,130 @@ func createChangelogPage(data changelogData) *changelogPage {
 		}
 		page.RepoTables = append(page.RepoTables, table)
 		if repoLog.HasMoreCommits {
-			table.RemovalsLink = gobDiffLink(repoLog.InstanceURL, repoLog.Repo, repoLog.SourceSHA, repoLog.TargetSHA, false)
+			table.RemovalsLinks = gobLogLinks(repoLog, false)
 		}
 	}
 	return page
 }
 
-func findBuildWithFallback(httpClient *http.Client, gerrit, fallbackGerrit, gob, repo, cl string, internal bool) (*findbuild.BuildResponse, bool, utils.ChangelogError) {
+// changelogURL returns the URL of the changelog page between two builds.
+func changelogURL(source, target, querySize string, internal bool) string {
+	params := url.Values{}
+	params.Set("source", source)
+	params.Set("target", target)
+	params.Set("n", querySize)
+	params.Set("internal", strconv.FormatBool(internal))
+	return "/changelog/?" + params.Encode()
+}
+
+// changelogChainURL returns the URL of the chain page comparing builds.
+func changelogChainURL(builds []string, querySize string, internal bool) string {
+	params := url.Values{}
+	params.Set("builds", strings.Join(builds, ","))
+	params.Set("n", querySize)
+	params.Set("internal", strconv.FormatBool(internal))
+	return "/changelog/?" + params.Encode()
+}
+
+// createChangelogChainPage creates the page displaying the changelog between
+// each pair of consecutive builds in builds. Each loaded changelog is
+// assembled with createChangelogPage. If some changelogs were not loaded,
+// the page links to a chain page starting at the first unloaded pair.
+func createChangelogChainPage(builds []string, links []*changelog.ChainLink, querySize string, internal bool) *changelogChainPage {
+	page := &changelogChainPage{
+		Builds:    strings.Join(builds, ", "),
+		QuerySize: querySize,
+		Internal:  internal,
+	}
+	for i, link := range links {
+		linkPage := &chainLinkPage{
+			Source: link.Source,
+			Target: link.Target,
+			Link:   changelogURL(link.Source, link.Target, querySize, internal),
+		}
+		switch {
+		case !link.Loaded:
+			if page.MoreLink == "" {
+				page.MoreLink = changelogChainURL(builds[i:], querySize, internal)
+			}
+		case link.Err != nil:
+			linkPage.Error = htmltemplate.HTML(link.Err.HTMLError())
+		default:
+			linkPage.Page = createChangelogPage(changelogData{
+				Source:    link.Source,
+				Target:    link.Target,
+				Additions: link.Additions,
+				Removals:  link.Removals,
+				Internal:  internal,
+			})
+		}
+		page.Links = append(page.Links, linkPage)
+	}
+	return page
+}
+
+// createSysctlChanges classifies the sysctl changes between the builds of a
+// changelog for display.
+func createSysctlChanges(data changelogData) sysctlChanges {
+	var output sysctlChanges
+	if data.Sysctl == nil {
+		output.NotFound = append(output.NotFound, fmt.Sprintf("sysctl changes are not compared between milestones %s and %s.", data.SourceMilestone, data.TargetMilestone))
+		output.NotEmpty = true
+		return output
+	}
+	if !data.Sysctl.SourceFound {
+		output.NotFound = append(output.NotFound, fmt.Sprintf("sysctl file for %s-%s-%s not found.", data.SourceBoard, data.SourceMilestone, data.Source))
+	}
+	if !data.Sysctl.TargetFound {
+		output.NotFound = append(output.NotFound, fmt.Sprintf("sysctl file for %s-%s-%s not found.", data.TargetBoard, data.TargetMilestone, data.Target))
+	}
+	for _, category := range []struct {
+		Change  string
+		Changes []changelog.SysctlChange
+	}{
+		{"Added", data.Sysctl.Added},
+		{"Removed", data.Sysctl.Removed},
+		{"Changed", data.Sysctl.Changed},
+	} {
+		for _, change := range category.Changes {
+			row := sysctlRow{Change: category.Change, Name: change.Name, OldValue: change.SourceValue, NewValue: change.TargetValue}
+			if row.OldValue == "" {
+				row.OldValue = "---"
+			}
+			if row.NewValue == "" {
+				row.NewValue = "---"
+			}
+			output.Changes = append(output.Changes, row)
+		}
+	}
+	output.NotEmpty = len(output.NotFound) > 0 || len(output.Changes) > 0
+	return output
+}
+
+func findBuildWithFallback(httpClient *http.Client, gerrit, fallbackGerrit, gob, repo, cl string, internal, verifySignature bool, cache *findbuild.Cache) (*findbuild.BuildResponse, bool, utils.ChangelogError) {
 	didFallback := false
 	request := &findbuild.BuildRequest{
-		HTTPClient:   httpClient,
-		GerritHost:   gerrit,
-		GitilesHost:  gob,
-		ManifestRepo: repo,
-		CL:           cl,
+		HTTPClient:      httpClient,
+		GerritHost:      gerrit,
+		GitilesHost:     gob,
+		ManifestRepo:    repo,
+		CL:              cl,
+		VerifySignature: verifySignature,
+		Cache:           cache,
 	}
 	buildData, err := findbuild.FindBuild(request)
 	if err != nil && err.HTTPCode() == "404" {
 		log.Debugf("Cl %s not found in Gerrit instance, using fallback", cl)
 		fallbackRequest := &findbuild.BuildRequest{
-			HTTPClient:   httpClient,
-			GerritHost:   fallbackGerrit,
-			GitilesHost:  gob,
-			ManifestRepo: repo,
-			CL:           cl,
+			HTTPClient:      httpClient,
+			GerritHost:      fallbackGerrit,
+			GitilesHost:     gob,
+			ManifestRepo:    repo,
+			CL:              cl,
+			VerifySignature: verifySignature,
+			Cache:           cache,
+			RepoPrefix:      fallbackRepoPrefix,
+			RepoPrefixRules: fallbackRepoPrefixRules,
 		}
 		buildData, err = findbuild.FindBuild(fallbackRequest)
 		didFallback = true
@@ -308,8 +561,29 @@ func findReleaseBuild(httpClient *http.Client, gerrit, gob, repo, cl string, int
 	return buildData, err
 }
 
+// changelogETag returns an entity tag identifying a changelog page. The tag
+// is derived from every request parameter that affects the page contents.
+func changelogETag(source, target string, querySize int, internal bool, sourceBoard, sourceMilestone, targetBoard, targetMilestone string) string {
+	key := strings.Join([]string{source, target, strconv.Itoa(querySize), strconv.FormatBool(internal),
+		sourceBoard, sourceMilestone, targetBoard, targetMilestone}, "\x00")
+	return fmt.Sprintf("\"%x\"", sha256.Sum256([]byte(key)))
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // handleError creates the error page for a given error
 func handleError(w http.ResponseWriter, r *http.Request, displayErr utils.ChangelogError, currPage string) {
+	w.Header().Set("Cache-Control", "no-store")
+	setRetryAfter(w, displayErr)
 	err := basicTextTemplate.Execute(w, &basicTextPage{
 		Header:     displayErr.Header(),
 		Body:       displayErr.HTMLError(),
@@ -322,6 +596,25 @@ func handleError(w http.ResponseWriter, r *http.Request, displayErr utils.Change
 	}
 }
 
+// retryAfterSeconds returns the number of seconds after which the request
+// that failed with err may be retried, ex. when a request to Gerrit was rate
+// limited. It returns 0 if the delay is unknown.
+func retryAfterSeconds(err utils.ChangelogError) int {
+	temporary, ok := err.(interface{ RetryAfter() time.Duration })
+	if !ok || temporary.RetryAfter() <= 0 {
+		return 0
+	}
+	return int(math.Ceil(temporary.RetryAfter().Seconds()))
+}
+
+// setRetryAfter sets the Retry-After header of a response if the request
+// that failed with err may be retried after a known delay.
+func setRetryAfter(w http.ResponseWriter, err utils.ChangelogError) {
+	if seconds := retryAfterSeconds(err); seconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+}
+
 // HandleIndex serves the home page
 func HandleIndex(w http.ResponseWriter, r *http.Request) {
 	err := indexTemplate.Execute(w, &statusPage{SignedIn: SignedIn(r)})
@@ -354,6 +647,10 @@ func HandleChangelog(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if _, ok := r.Form["builds"]; ok {
+		handleChangelogChain(w, r)
+		return
+	}
 	source := r.FormValue("source")
 	target := r.FormValue("target")
 	sourceMilestone := r.FormValue("source-milestone")
@@ -383,53 +680,164 @@ func HandleChangelog(w http.ResponseWriter, r *http.Request) {
 	if r.FormValue("internal") == "true" {
 		internal, instance, manifestRepo = true, internalGoBInstance, internalManifestRepo
 	}
-	httpClient, err := HTTPClient(w, r)
+	httpClient, err := newHTTPClient(w, r)
 	if err != nil {
 		loginURL := GetLoginURL("/changelog/", false)
 		http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
 		return
 	}
-	added, removed, utilErr := changelog.Changelog(httpClient, source, target, instance, manifestRepo, croslandURL, querySize)
+	// "latest" refers to a different build over time, so it is resolved
+	// before it is used in the cache key or to locate artifacts.
+	source, target, utilErr := changelog.ResolveLatest(httpClient, source, target, instance, manifestRepo)
+	if utilErr != nil {
+		log.Errorf("error resolving latest build between builds %s and %s with manifest repository: %s\n%v\n",
+			r.FormValue("source"), r.FormValue("target"), manifestRepo, utilErr)
+		handleError(w, r, utilErr, "/changelog/")
+		return
+	}
+	etag := changelogETag(source, target, querySize, internal, sourceBoard, sourceMilestone, targetBoard, targetMilestone)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", changelogCacheControl)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	opts := &changelog.Options{}
+	if !internal {
+		opts.ManifestCache = externalManifestCache
+	}
+	added, removed, _, utilErr := changelog.ChangelogWithOptions(r.Context(), httpClient, source, target, instance, manifestRepo, croslandURL, querySize, opts)
 	if utilErr != nil {
 		log.Errorf("error retrieving changelog between builds %s and %s on GoB instance: %s with manifest repository: %s\n%v\n",
 			source, target, externalGoBInstance, externalManifestRepo, utilErr)
 		handleError(w, r, utilErr, "/changelog/")
 		return
 	}
+	sysctlDiff, err := changelog.GetSysctlDiff(storageClient, artifactsBucket, sourceBoard,
+		sourceMilestone, source, targetBoard, targetMilestone, target)
+	if err != nil && !errors.Is(err, changelog.ErrSysctlMilestoneMismatch) {
+		log.Errorf("error comparing sysctl files between builds %s and %s: %v", source, target, err)
+	}
 	page := createChangelogPage(changelogData{
-		Source:    source,
-		Target:    target,
-		Additions: added,
-		Removals:  removed,
-		Internal:  internal,
+		Source:          source,
+		Target:          target,
+		SourceBoard:     sourceBoard,
+		SourceMilestone: sourceMilestone,
+		TargetBoard:     targetBoard,
+		TargetMilestone: targetMilestone,
+		Additions:       added,
+		Removals:        removed,
+		Internal:        internal,
+		Sysctl:          sysctlDiff,
 	})
-	page.SourceMilestone = sourceMilestone
-	page.SourceBoard = sourceBoard
-	page.TargetMilestone = targetMilestone
-	page.TargetBoard = targetBoard
+	// Sysctl artifacts may be uploaded after a build is created, so only
+	// cache pages that are complete.
+	if sysctlDiff == nil || (sysctlDiff.SourceFound && sysctlDiff.TargetFound) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", changelogCacheControl)
+	}
+	err = changelogTemplate.Execute(w, page)
+	if err != nil {
+		log.Errorf("error executing changelog template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
 
-	var foundSource, foundTarget bool
-	page.Sysctl.Changes, foundSource, foundTarget = changelog.GetSysctlDiff(artifactsBucket, sourceBoard,
-		sourceMilestone, source, targetBoard, targetMilestone, target)
-	page.Sysctl.NotEmpty = false
-	if !foundSource {
-		page.Sysctl.NotFound += fmt.Sprintf("sysctl file for %s-%s-%s not found.<br>", sourceBoard, sourceMilestone, source)
-		page.Sysctl.NotEmpty = true
+// handleChangelogChain serves the changelog page comparing an ordered list of
+// builds, which displays the changelog between each pair of consecutive
+// builds. Only the first chainPageLinks changelogs are retrieved, and the
+// page links to the remaining ones.
+func handleChangelogChain(w http.ResponseWriter, r *http.Request) {
+	querySize := r.FormValue("n")
+	if _, err := strconv.Atoi(querySize); err != nil {
+		querySize = envQuerySize
 	}
-	if !foundTarget {
-		page.Sysctl.NotFound += fmt.Sprintf("sysctl file for %s-%s-%s not found.<br>", targetBoard, targetMilestone, target)
-		page.Sysctl.NotEmpty = true
+	internal, instance, manifestRepo := false, externalGoBInstance, externalManifestRepo
+	if r.FormValue("internal") == "true" {
+		internal, instance, manifestRepo = true, internalGoBInstance, internalManifestRepo
 	}
-	if len(page.Sysctl.Changes) > 0 {
-		page.Sysctl.NotEmpty = true
+	page := &changelogChainPage{Builds: r.FormValue("builds"), QuerySize: querySize, Internal: internal}
+	// If no builds are specified in the request, display an empty chain page
+	if strings.TrimSpace(page.Builds) == "" {
+		page.Internal = true
+		if err := changelogChainTemplate.Execute(w, page); err != nil {
+			log.Errorf("error executing changelog chain template: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
 	}
-	err = changelogTemplate.Execute(w, page)
+	builds, err := changelog.ParseBuildChain(page.Builds)
 	if err != nil {
-		log.Errorf("error executing changelog template: %v", err)
+		page.Error = err.Error()
+		if err := changelogChainTemplate.Execute(w, page); err != nil {
+			log.Errorf("error executing changelog chain template: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	httpClient, err := newHTTPClient(w, r)
+	if err != nil {
+		loginURL := GetLoginURL("/changelog/", false)
+		http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
+		return
+	}
+	opts := &changelog.Options{}
+	if !internal {
+		opts.ManifestCache = externalManifestCache
+	}
+	n, _ := strconv.Atoi(querySize)
+	links := changelog.ChangelogChain(r.Context(), httpClient, builds, instance, manifestRepo, croslandURL, n, opts, chainPageLinks)
+	for _, link := range links {
+		if link.Err != nil {
+			log.Errorf("error retrieving changelog between builds %s and %s on GoB instance: %s with manifest repository: %s\n%v\n",
+				link.Source, link.Target, instance, manifestRepo, link.Err)
+		}
+	}
+	page = createChangelogChainPage(builds, links, querySize, internal)
+	if err := changelogChainTemplate.Execute(w, page); err != nil {
+		log.Errorf("error executing changelog chain template: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// HandleManifest serves the raw manifest file for a build
+func HandleManifest(w http.ResponseWriter, r *http.Request) {
+	if RequireToken(w, r, "/manifest/") {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		log.Errorf("error parsing form: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	build := r.FormValue("build")
+	if build == "" {
+		http.Error(w, "no build provided", http.StatusBadRequest)
+		return
+	}
+	instance, manifestRepo := externalGoBInstance, externalManifestRepo
+	if r.FormValue("internal") == "true" {
+		instance, manifestRepo = internalGoBInstance, internalManifestRepo
+	}
+	httpClient, err := newHTTPClient(w, r)
+	if err != nil {
+		loginURL := GetLoginURL("/manifest/", false)
+		http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
+		return
+	}
+	manifest, utilErr := changelog.Manifest(httpClient, build, instance, manifestRepo)
+	if utilErr != nil {
+		log.Errorf("error retrieving manifest for build %s on GoB instance: %s with manifest repository: %s\n%v\n",
+			build, instance, manifestRepo, utilErr)
+		handleError(w, r, utilErr, "/manifest/")
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := w.Write([]byte(manifest)); err != nil {
+		log.Errorf("error writing manifest for build %s: %v", build, err)
+	}
+}
+
 // HandleFindBuild serves the Locate CL page
 func HandleFindBuild(w http.ResponseWriter, r *http.Request) {
 	if RequireToken(w, r, "/findbuild/") {
@@ -458,13 +866,13 @@ func HandleFindBuild(w http.ResponseWriter, r *http.Request) {
 	if r.FormValue("cos-internal") == "true" {
 		internal, gerrit, fallbackGerrit, gob, repo = true, internalGerritInstance, internalFallbackGerritInstance, internalGoBInstance, internalManifestRepo
 	}
-	httpClient, err := HTTPClient(w, r)
+	httpClient, err := newHTTPClient(w, r)
 	if err != nil {
 		loginURL := GetLoginURL("/findbuild/", false)
 		http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
 		return
 	}
-	buildData, didFallback, utilErr := findBuildWithFallback(httpClient, gerrit, fallbackGerrit, gob, repo, cl, internal)
+	buildData, didFallback, utilErr := findBuildWithFallback(httpClient, gerrit, fallbackGerrit, gob, repo, cl, internal, true, nil)
 	if utilErr != nil {
 		log.Errorf("error retrieving build for CL %s with internal set to %t\n%v", cl, internal, utilErr)
 		handleError(w, r, utilErr, "/findbuild/")
@@ -482,6 +890,18 @@ func HandleFindBuild(w http.ResponseWriter, r *http.Request) {
 		BuildNum:   buildData.BuildNum,
 		Internal:   internal,
 		GerritLink: gerritLink,
+		Repo:       buildData.Repo,
+		Branch:     buildData.Branch,
+	}
+	if !buildData.Submitted.IsZero() {
+		page.Submitted = buildData.Submitted.UTC().Format("Jan 2, 2006 15:04 MST")
+	}
+	if buildData.Verified != nil {
+		if *buildData.Verified {
+			page.Signature = "The CL was uploaded with a verified signed push."
+		} else {
+			page.Signature = "The CL was not uploaded with a verified signed push."
+		}
 	}
 	err = findBuildTemplate.Execute(w, page)
 	if err != nil {
@@ -490,6 +910,116 @@ func HandleFindBuild(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// batchCLs returns the CL identifiers in a batch findbuild request. CLs may be
+// provided as repeated "cl" parameters or as a comma separated list.
+func batchCLs(r *http.Request) []string {
+	var cls []string
+	for _, value := range r.Form["cl"] {
+		for _, cl := range strings.Split(value, ",") {
+			if cl = strings.TrimSpace(cl); cl != "" {
+				cls = append(cls, cl)
+			}
+		}
+	}
+	return cls
+}
+
+// findBuildBatch searches for the first build containing each CL, using at
+// most batchFindBuildJobs concurrent searches. Manifest commits and tags are
+// shared between searches. Results are returned in the same order as cls.
+func findBuildBatch(httpClient *http.Client, gerrit, fallbackGerrit, gob, repo string, cls []string, internal bool) []*batchBuildResult {
+	cache := findbuild.NewCache()
+	results := make([]*batchBuildResult, len(cls))
+	jobs := make(chan struct{}, batchFindBuildJobs)
+	var wg sync.WaitGroup
+	for i, cl := range cls {
+		wg.Add(1)
+		jobs <- struct{}{}
+		go func(i int, cl string) {
+			defer func() {
+				<-jobs
+				wg.Done()
+			}()
+			result := &batchBuildResult{CL: cl}
+			buildData, _, utilErr := findBuildWithFallback(httpClient, gerrit, fallbackGerrit, gob, repo, cl, internal, false, cache)
+			if utilErr != nil {
+				log.Errorf("error retrieving build for CL %s with internal set to %t\n%v", cl, internal, utilErr)
+				result.ErrorCode = utilErr.HTTPCode()
+				result.Error = utilErr.Error()
+				result.RetryAfter = retryAfterSeconds(utilErr)
+			} else {
+				result.CLNum = buildData.CLNum
+				result.BuildNum = buildData.BuildNum
+			}
+			results[i] = result
+		}(i, cl)
+	}
+	wg.Wait()
+	return results
+}
+
+// HandleFindBuildBatch returns the first build containing each of a list of
+// CLs in JSON
+func HandleFindBuildBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !SignedIn(r) {
+		http.Error(w, "sign in required", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		log.Errorf("error parsing form: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cls := batchCLs(r)
+	if len(cls) == 0 {
+		http.Error(w, "no cl provided", http.StatusBadRequest)
+		return
+	}
+	if len(cls) > maxBatchCLs {
+		http.Error(w, fmt.Sprintf("too many cls provided, maximum is %d", maxBatchCLs), http.StatusBadRequest)
+		return
+	}
+	internal, gerrit, fallbackGerrit, gob, repo := false, externalGerritInstance, externalFallbackGerritInstance, externalGoBInstance, externalManifestRepo
+	if r.FormValue("cos-internal") == "true" {
+		internal, gerrit, fallbackGerrit, gob, repo = true, internalGerritInstance, internalFallbackGerritInstance, internalGoBInstance, internalManifestRepo
+	}
+	httpClient, err := newHTTPClient(w, r)
+	if err != nil {
+		http.Error(w, "sign in required", http.StatusUnauthorized)
+		return
+	}
+	results := findBuildBatch(httpClient, gerrit, fallbackGerrit, gob, repo, cls, internal)
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		log.Errorf("error encoding batch findbuild results: %v", err)
+	}
+}
+
+// HandleResolveImageName serves the channel, milestone and build number of
+// the image name in the "name" parameter as JSON. Unparseable names are
+// rejected with a 400.
+func HandleResolveImageName(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := r.ParseForm(); err != nil {
+		log.Errorf("error parsing form: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		http.Error(w, "no image name provided", http.StatusBadRequest)
+		return
+	}
+	imageName, err := changelog.ParseImageName(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(imageName); err != nil {
+		log.Errorf("error encoding image name %q: %v", name, err)
+	}
+}
+
 // HandleFindReleasedBuild serves the Locate CL page
 func HandleFindReleasedBuild(w http.ResponseWriter, r *http.Request) {
 	if RequireToken(w, r, "/findreleasedbuild/") { // TODO add findreleasebuild.html
@@ -518,7 +1048,7 @@ func HandleFindReleasedBuild(w http.ResponseWriter, r *http.Request) {
 	if r.FormValue("cos-internal") == "true" {
 		internal, gerrit, gob, repo = true, internalGerritInstance, internalGoBInstance, internalManifestRepo
 	}
-	httpClient, err := HTTPClient(w, r)
+	httpClient, err := newHTTPClient(w, r)
 	if err != nil {
 		loginURL := GetLoginURL("/findreleasedbuild/", false)
 		http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
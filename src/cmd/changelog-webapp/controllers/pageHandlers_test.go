@@ -0,0 +1,260 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/httpclient"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"github.com/gorilla/sessions"
+)
+
+var record = flag.Bool("record", false, "record live Gerrit and Gitiles traffic into the replay fixtures in testdata/replay")
+
+// replayClient returns an http.Client that replays the traffic recorded in
+// testdata/replay/<name>.json. If the -record flag is set, the fixture is
+// regenerated from live services using application default credentials.
+func replayClient(t *testing.T, name string) *http.Client {
+	var base *http.Client
+	if *record {
+		var err error
+		if base, err = httpclient.NewDefault(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fakes.NewReplayClient(t, filepath.Join("testdata", "replay", name+".json"), *record, base)
+}
+
+// recordingTransport fails every request, and records the URLs requested.
+type recordingTransport struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, req.URL.String())
+	return nil, errors.New("unexpected request")
+}
+
+// setupHandlers prepares the handlers to serve requests without Secret
+// Manager: sessions are signed with a test secret, templates are parsed from
+// the static directory and requests on behalf of users are sent with client.
+func setupHandlers(t *testing.T, client *http.Client) {
+	t.Helper()
+	store = sessions.NewCookieStore([]byte("test-session-secret"))
+	staticBasePath = "../static/"
+	parseTemplates()
+	newHTTPClient = func(http.ResponseWriter, *http.Request) (*http.Client, error) {
+		return client, nil
+	}
+	t.Cleanup(func() { newHTTPClient = HTTPClient })
+}
+
+// signIn adds the cookie of a signed in session to r.
+func signIn(t *testing.T, r *http.Request) {
+	t.Helper()
+	session, err := store.New(httptest.NewRequest(http.MethodGet, "/", nil), sessionName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["accessToken"] = "access-token"
+	session.Values["refreshToken"] = "refresh-token"
+	session.Values["tokenType"] = "Bearer"
+	session.Values["expiry"] = time.Now().Add(time.Hour).Format(time.RFC3339)
+	w := httptest.NewRecorder()
+	if err := session.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+}
+
+func TestHandleChangelogNotModified(t *testing.T) {
+	externalGoBInstance, externalManifestRepo = "cos.googlesource.com", "cos/manifest-snapshots"
+	etag := changelogETag("15036.0.0", "15041.0.0", 10, false, "", "", "", "")
+	tests := map[string]struct {
+		IfNoneMatch  string
+		NotModified  bool
+		SendsRequest bool
+	}{
+		"matching etag":       {IfNoneMatch: etag, NotModified: true},
+		"weak etag in list":   {IfNoneMatch: `"other", W/` + etag, NotModified: true},
+		"different etag":      {IfNoneMatch: `"other"`, SendsRequest: true},
+		"no if-none-match":    {SendsRequest: true},
+		"different page size": {IfNoneMatch: changelogETag("15036.0.0", "15041.0.0", 20, false, "", "", "", ""), SendsRequest: true},
+	}
+	for name, test := range tests {
+		transport := &recordingTransport{}
+		setupHandlers(t, &http.Client{Transport: transport})
+		r := httptest.NewRequest(http.MethodGet, "/changelog/?source=15036.0.0&target=15041.0.0&n=10", nil)
+		signIn(t, r)
+		if test.IfNoneMatch != "" {
+			r.Header.Set("If-None-Match", test.IfNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		HandleChangelog(w, r)
+
+		if notModified := w.Code == http.StatusNotModified; notModified != test.NotModified {
+			t.Errorf("test %q failed: expected not modified %t, got status %d", name, test.NotModified, w.Code)
+		}
+		if test.NotModified {
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("test %q failed: expected ETag %s, got %s", name, etag, got)
+			}
+			if got := w.Header().Get("Cache-Control"); got != changelogCacheControl {
+				t.Errorf("test %q failed: expected Cache-Control %q, got %q", name, changelogCacheControl, got)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("test %q failed: expected empty body, got %q", name, w.Body.String())
+			}
+		}
+		if sent := len(transport.requests) > 0; sent != test.SendsRequest {
+			t.Errorf("test %q failed: expected requests sent %t, got %v", name, test.SendsRequest, transport.requests)
+		}
+	}
+}
+
+// newEmptyGerrit starts a Gerrit server that does not find any change.
+func newEmptyGerrit(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, ")]}'\n[]")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandleFindBuildBatch(t *testing.T) {
+	manyCLs := make([]string, maxBatchCLs+1)
+	for i := range manyCLs {
+		manyCLs[i] = strconv.Itoa(3000 + i)
+	}
+	tests := map[string]struct {
+		CLs       []string
+		SignedOut bool
+		// Replay sends requests to the live instances recorded in
+		// testdata/replay/findbuildbatch.json instead of an empty Gerrit.
+		Replay       bool
+		ExpectedCode int
+		Expected     []batchBuildResult
+	}{
+		"signed out":    {CLs: []string{"3781"}, SignedOut: true, ExpectedCode: http.StatusUnauthorized},
+		"no cl":         {ExpectedCode: http.StatusBadRequest},
+		"empty cl list": {CLs: []string{" , "}, ExpectedCode: http.StatusBadRequest},
+		"too many cls":  {CLs: manyCLs, ExpectedCode: http.StatusBadRequest},
+		"not found cl": {
+			CLs:          []string{"9"},
+			ExpectedCode: http.StatusOK,
+			Expected:     []batchBuildResult{{CL: "9", ErrorCode: "404"}},
+		},
+		"invalid bug": {
+			CLs:          []string{"bug:abc"},
+			ExpectedCode: http.StatusOK,
+			Expected:     []batchBuildResult{{CL: "bug:abc", ErrorCode: "404"}},
+		},
+		"found and not found cls": {
+			CLs:          []string{"3781,9"},
+			Replay:       true,
+			ExpectedCode: http.StatusOK,
+			Expected: []batchBuildResult{
+				{CL: "3781", CLNum: "3781", BuildNum: "12371.1072.0"},
+				{CL: "9", ErrorCode: "404"},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.Replay {
+				externalGerritInstance = "https://cos-review.googlesource.com"
+				externalFallbackGerritInstance = "https://chromium-review.googlesource.com"
+				setupHandlers(t, replayClient(t, "findbuildbatch"))
+			} else {
+				gerrit := newEmptyGerrit(t)
+				externalGerritInstance, externalFallbackGerritInstance = gerrit.URL, gerrit.URL
+				setupHandlers(t, gerrit.Client())
+			}
+			externalGoBInstance, externalManifestRepo = "cos.googlesource.com", "cos/manifest-snapshots"
+			query := url.Values{"cl": test.CLs}
+			r := httptest.NewRequest(http.MethodGet, "/findbuildbatch/?"+query.Encode(), nil)
+			if !test.SignedOut {
+				signIn(t, r)
+			}
+			w := httptest.NewRecorder()
+			HandleFindBuildBatch(w, r)
+
+			if w.Code != test.ExpectedCode {
+				t.Fatalf("expected status %d, got %d: %s", test.ExpectedCode, w.Code, w.Body.String())
+			}
+			if test.ExpectedCode != http.StatusOK {
+				return
+			}
+			var got []batchBuildResult
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(got) != len(test.Expected) {
+				t.Fatalf("expected %d results, got %+v", len(test.Expected), got)
+			}
+			for i, expected := range test.Expected {
+				result := got[i]
+				if result.CL != expected.CL || result.CLNum != expected.CLNum || result.BuildNum != expected.BuildNum || result.ErrorCode != expected.ErrorCode {
+					t.Errorf("result %d: expected %+v, got %+v", i, expected, result)
+				}
+				if expected.ErrorCode != "" && result.Error == "" {
+					t.Errorf("result %d: expected an error message for error code %s", i, expected.ErrorCode)
+				}
+			}
+		})
+	}
+}
+
+func TestChangelogChainPageEscapesBuilds(t *testing.T) {
+	setupHandlers(t, nil)
+	builds := []string{`"><script>alert(1)</script>`, "15000.0.0", "15001.0.0"}
+	links := []*changelog.ChainLink{
+		{Source: builds[0], Target: builds[1], Loaded: true, Err: utils.BuildNotFound(builds[0])},
+		{Source: builds[1], Target: builds[2]},
+	}
+	page := createChangelogChainPage(builds, links, "10", false)
+	var out strings.Builder
+	if err := changelogChainTemplate.Execute(&out, page); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "<script>") {
+		t.Errorf("expected build names to be escaped, got %s", out.String())
+	}
+	if !strings.Contains(out.String(), "&lt;script&gt;") {
+		t.Errorf("expected escaped build name in page, got %s", out.String())
+	}
+}
@@ -35,14 +35,18 @@ func init() {
 
 func main() {
 	log.SetLevel(log.DebugLevel)
+	controllers.Init()
 
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticBasePath))))
 	http.HandleFunc("/", controllers.HandleIndex)
 	http.HandleFunc("/readme/", controllers.HandleReadme)
 	http.HandleFunc("/changelog/", controllers.HandleChangelog)
+	http.HandleFunc("/manifest/", controllers.HandleManifest)
 	http.HandleFunc("/findbuild/", controllers.HandleFindBuild)
+	http.HandleFunc("/findbuildbatch/", controllers.HandleFindBuildBatch)
 	http.HandleFunc("/findreleasedbuildv2/", controllers.HandleFindReleasedBuild)
 	http.HandleFunc("/findreleasedbuild", controllers.HandleFindReleasedBuildGerrit)
+	http.HandleFunc("/api/resolve", controllers.HandleResolveImageName)
 	http.HandleFunc("/login/", controllers.HandleLogin)
 	http.HandleFunc("/oauth2callback/", controllers.HandleCallback)
 	http.HandleFunc("/signout/", controllers.HandleSignOut)
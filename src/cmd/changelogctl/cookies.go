@@ -0,0 +1,164 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/httpclient"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// httpOnlyPrefix marks HttpOnly cookies in a Netscape cookie file.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// gitCookie is a single entry of a .gitcookies file.
+type gitCookie struct {
+	domain            string
+	includeSubdomains bool
+	path              string
+	secure            bool
+	expires           time.Time
+	name              string
+	value             string
+}
+
+// matches reports whether the cookie should be sent with req.
+func (c *gitCookie) matches(req *http.Request) bool {
+	if c.secure && req.URL.Scheme != "https" {
+		return false
+	}
+	if !strings.HasPrefix(req.URL.Path, c.path) {
+		return false
+	}
+	host := req.URL.Hostname()
+	domain := strings.TrimPrefix(c.domain, ".")
+	if host == domain {
+		return true
+	}
+	return c.includeSubdomains && strings.HasSuffix(host, "."+domain)
+}
+
+func parseBool(field string) (bool, error) {
+	switch field {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected TRUE or FALSE, got %q", field)
+}
+
+// parseGitCookies parses cookies in the Netscape cookie file format used by
+// .gitcookies files. Each non-comment line contains 7 tab separated fields:
+// domain, include subdomains, path, secure, expiry, name and value.
+// Expired cookies are skipped.
+func parseGitCookies(r io.Reader, now time.Time) ([]*gitCookie, error) {
+	var cookies []*gitCookie
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab separated fields, got %d", lineNum, len(fields))
+		}
+		if fields[0] == "" || fields[5] == "" {
+			return nil, fmt.Errorf("line %d: cookie domain and name must not be empty", lineNum)
+		}
+		includeSubdomains, err := parseBool(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid include subdomains field: %v", lineNum, err)
+		}
+		secure, err := parseBool(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid secure field: %v", lineNum, err)
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q: %v", lineNum, fields[4], err)
+		}
+		cookie := &gitCookie{
+			domain:            fields[0],
+			includeSubdomains: includeSubdomains,
+			path:              fields[2],
+			secure:            secure,
+			name:              fields[5],
+			value:             fields[6],
+		}
+		// An expiry of 0 marks a session cookie, which never expires.
+		if expiry != 0 {
+			cookie.expires = time.Unix(expiry, 0)
+			if cookie.expires.Before(now) {
+				log.Debugf("Skipping expired cookie %s for %s", cookie.name, cookie.domain)
+				continue
+			}
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("no valid cookies found")
+	}
+	return cookies, nil
+}
+
+// cookieTransport adds the matching cookies of a .gitcookies file to every
+// request.
+type cookieTransport struct {
+	cookies []*gitCookie
+	base    http.RoundTripper
+}
+
+func (t *cookieTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for _, cookie := range t.cookies {
+		if cookie.matches(req) {
+			req.AddCookie(&http.Cookie{Name: cookie.name, Value: cookie.value})
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newCookieClient returns an HTTP client authenticating to Gerrit and Gitiles
+// with the cookies in the .gitcookies file at cookieFile.
+func newCookieClient(cookieFile string) (*http.Client, error) {
+	f, err := os.Open(cookieFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cookie file: %v", err)
+	}
+	defer f.Close()
+	cookies, err := parseGitCookies(f, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("invalid cookie file %s: %v", cookieFile, err)
+	}
+	return httpclient.New(&httpclient.Options{
+		Timeout: httpTimeout,
+		Base:    &cookieTransport{cookies: cookies, base: http.DefaultTransport},
+	}), nil
+}
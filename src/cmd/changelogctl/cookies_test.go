@@ -0,0 +1,112 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGitCookies(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tests := map[string]struct {
+		Input         string
+		ExpectedNames []string
+		ShouldError   bool
+	}{
+		"Valid": {
+			Input: "# Netscape HTTP Cookie File\n" +
+				".googlesource.com\tTRUE\t/\tTRUE\t2147483647\to\tgit-user.google.com=1//abc\n" +
+				"#HttpOnly_cos-review.googlesource.com\tFALSE\t/\tTRUE\t0\tsession\txyz\n",
+			ExpectedNames: []string{"o", "session"},
+		},
+		"SkipsExpired": {
+			Input: ".googlesource.com\tTRUE\t/\tTRUE\t1\told\tabc\n" +
+				".googlesource.com\tTRUE\t/\tTRUE\t2147483647\to\tabc\n",
+			ExpectedNames: []string{"o"},
+		},
+		"SpaceSeparated": {
+			Input:       ".googlesource.com TRUE / TRUE 2147483647 o abc\n",
+			ShouldError: true,
+		},
+		"InvalidBool": {
+			Input:       ".googlesource.com\tyes\t/\tTRUE\t2147483647\to\tabc\n",
+			ShouldError: true,
+		},
+		"InvalidExpiry": {
+			Input:       ".googlesource.com\tTRUE\t/\tTRUE\tnever\to\tabc\n",
+			ShouldError: true,
+		},
+		"EmptyName": {
+			Input:       ".googlesource.com\tTRUE\t/\tTRUE\t2147483647\t\tabc\n",
+			ShouldError: true,
+		},
+		"NoCookies": {
+			Input:       "# Netscape HTTP Cookie File\n\n",
+			ShouldError: true,
+		},
+	}
+	for name, test := range tests {
+		cookies, err := parseGitCookies(strings.NewReader(test.Input), now)
+		if (err != nil) != test.ShouldError {
+			t.Errorf("test %q failed: expected error: %t, got: %v", name, test.ShouldError, err)
+			continue
+		}
+		var names []string
+		for _, cookie := range cookies {
+			names = append(names, cookie.name)
+		}
+		if strings.Join(names, ",") != strings.Join(test.ExpectedNames, ",") {
+			t.Errorf("test %q failed: expected cookies %v, got %v", name, test.ExpectedNames, names)
+		}
+	}
+}
+
+func TestCookieClient(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Cookie")
+	}))
+	defer server.Close()
+	cookieFile := filepath.Join(t.TempDir(), ".gitcookies")
+	contents := "127.0.0.1\tFALSE\t/\tFALSE\t2147483647\to\tgit-user=secret\n" +
+		"127.0.0.1\tFALSE\t/\tTRUE\t2147483647\tsecure\tnot-sent\n" +
+		"127.0.0.1\tFALSE\t/other\tFALSE\t2147483647\tpath\tnot-sent\n" +
+		".googlesource.com\tTRUE\t/\tFALSE\t2147483647\tgob\tnot-sent\n"
+	if err := ioutil.WriteFile(cookieFile, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := getHTTPClient(cookieFile)
+	if err != nil {
+		t.Fatalf("getHTTPClient failed: %v", err)
+	}
+	resp, err := client.Get(server.URL + "/a/cos/tools/+log")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if expected := "o=git-user=secret"; header != expected {
+		t.Errorf("expected Cookie header %q, got %q", expected, header)
+	}
+
+	if _, err := getHTTPClient(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected error for missing cookie file, got nil")
+	}
+}
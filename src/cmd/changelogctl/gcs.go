@@ -0,0 +1,102 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"google.golang.org/api/googleapi"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const gcsScheme = "gs://"
+
+// newStorageClient creates the GCS client used to upload changelogs. It is a
+// variable so that tests can use a fake GCS server.
+var newStorageClient = func(ctx context.Context) (*storage.Client, error) {
+	return storage.NewClient(ctx)
+}
+
+// parseGCSOutput splits a GCS output location of the form gs://bucket/prefix
+// into its bucket and object name prefix. The prefix may be empty.
+func parseGCSOutput(gcsOutput string) (string, string, error) {
+	if !strings.HasPrefix(gcsOutput, gcsScheme) {
+		return "", "", fmt.Errorf("invalid GCS output %q, expected gs://bucket/prefix", gcsOutput)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(gcsOutput, gcsScheme), "/", 2)
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("invalid GCS output %q, missing bucket name", gcsOutput)
+	}
+	prefix := ""
+	if len(parts) == 2 {
+		prefix = strings.Trim(parts[1], "/")
+	}
+	return parts[0], prefix, nil
+}
+
+// uploadChangelogAsJSON uploads a changelog as JSON to an object named like
+// the file written by writeChangelogAsJSON, under the prefix of gcsOutput.
+func uploadChangelogAsJSON(ctx context.Context, client *storage.Client, gcsOutput, source, target string, changes map[string]*changelog.RepoLog) error {
+	bucket, prefix, err := parseGCSOutput(gcsOutput)
+	if err != nil {
+		return err
+	}
+	objectName := fmt.Sprintf("%s -> %s.json", source, target)
+	if prefix != "" {
+		objectName = prefix + "/" + objectName
+	}
+	objectURL := gcsScheme + bucket + "/" + objectName
+	log.Infof("Uploading changelog to %s\n", objectURL)
+	jsonData, err := json.MarshalIndent(changes, "", "    ")
+	if err != nil {
+		return fmt.Errorf("uploadChangelogAsJSON: error marshalling changelog from: %s to: %s\n%v", source, target, err)
+	}
+	// The object is written directly rather than with the gcs package, which
+	// parses object names as URLs and would escape the spaces in the name.
+	w := client.Bucket(bucket).Object(objectName).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(jsonData); err != nil {
+		w.Close()
+		return fmt.Errorf("uploadChangelogAsJSON: error uploading changelog to %s: %v", objectURL, gcsError(err, bucket))
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("uploadChangelogAsJSON: error uploading changelog to %s: %v", objectURL, gcsError(err, bucket))
+	}
+	return nil
+}
+
+// gcsError explains the GCS errors caused by a missing bucket or missing
+// permissions, which are the common causes of failed uploads.
+func gcsError(err error, bucket string) error {
+	var apiErr *googleapi.Error
+	switch {
+	case errors.Is(err, storage.ErrBucketNotExist):
+		return fmt.Errorf("bucket %s does not exist: %v", bucket, err)
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+		return fmt.Errorf("bucket %s does not exist: %v", bucket, err)
+	case errors.As(err, &apiErr) && (apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden):
+		return fmt.Errorf("permission denied writing to bucket %s - check that your application default credentials "+
+			"(`gcloud auth application-default login`) can create objects in the bucket: %v", bucket, err)
+	}
+	return err
+}
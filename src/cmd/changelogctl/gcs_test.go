@@ -0,0 +1,110 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"google.golang.org/api/googleapi"
+)
+
+func TestParseGCSOutput(t *testing.T) {
+	tests := map[string]struct {
+		Input          string
+		ExpectedBucket string
+		ExpectedPrefix string
+		ShouldError    bool
+	}{
+		"BucketOnly":    {Input: "gs://bucket", ExpectedBucket: "bucket"},
+		"TrailingSlash": {Input: "gs://bucket/", ExpectedBucket: "bucket"},
+		"Prefix":        {Input: "gs://bucket/changelogs/R93/", ExpectedBucket: "bucket", ExpectedPrefix: "changelogs/R93"},
+		"MissingScheme": {Input: "bucket/changelogs", ShouldError: true},
+		"MissingBucket": {Input: "gs:///changelogs", ShouldError: true},
+		"OtherScheme":   {Input: "s3://bucket/changelogs", ShouldError: true},
+	}
+	for name, test := range tests {
+		bucket, prefix, err := parseGCSOutput(test.Input)
+		if test.ShouldError {
+			if err == nil {
+				t.Errorf("test %q failed: expected error, got bucket %q and prefix %q", name, bucket, prefix)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+		} else if bucket != test.ExpectedBucket || prefix != test.ExpectedPrefix {
+			t.Errorf("test %q failed: expected bucket %q and prefix %q, got %q and %q", name, test.ExpectedBucket, test.ExpectedPrefix, bucket, prefix)
+		}
+	}
+}
+
+func TestUploadChangelogAsJSON(t *testing.T) {
+	gcs := fakes.GCSForTest(t)
+	defer gcs.Close()
+
+	changes := map[string]*changelog.RepoLog{
+		"src/overlays": {
+			Commits: []*changelog.Commit{{
+				SHA:     "0a1b2c3d",
+				Subject: "Update kernel",
+				Bugs:    []string{"b/123456"},
+			}},
+			Repo:      "cos/overlays",
+			SourceSHA: "4e5f6a7b",
+			TargetSHA: "0a1b2c3d",
+		},
+	}
+	ctx := context.Background()
+	if err := uploadChangelogAsJSON(ctx, gcs.Client, "gs://changelogs/R93/", "15050.0.0", "15056.0.0", changes); err != nil {
+		t.Fatalf("uploadChangelogAsJSON failed: %v", err)
+	}
+	contents, ok := gcs.Objects["/changelogs/R93/15050.0.0 -> 15056.0.0.json"]
+	if !ok {
+		t.Fatalf("expected changelog object to be uploaded, got objects: %v", gcs.Objects)
+	}
+	var data map[string]*changelog.RepoLog
+	if err := json.Unmarshal(contents, &data); err != nil {
+		t.Fatalf("uploaded changelog is not valid JSON: %v", err)
+	}
+	if got := data["src/overlays"]; got == nil || len(got.Commits) != 1 || got.Commits[0].SHA != "0a1b2c3d" {
+		t.Errorf("unexpected uploaded changelog:\n%s", contents)
+	}
+}
+
+func TestGCSError(t *testing.T) {
+	tests := map[string]struct {
+		Err      error
+		Expected string
+	}{
+		"BucketNotExist": {Err: storage.ErrBucketNotExist, Expected: "bucket changelogs does not exist"},
+		"NotFound":       {Err: &googleapi.Error{Code: 404}, Expected: "bucket changelogs does not exist"},
+		"Forbidden":      {Err: fmt.Errorf("upload: %w", &googleapi.Error{Code: 403}), Expected: "permission denied writing to bucket changelogs"},
+		"Unauthorized":   {Err: &googleapi.Error{Code: 401}, Expected: "permission denied writing to bucket changelogs"},
+		"Other":          {Err: errors.New("connection reset"), Expected: "connection reset"},
+	}
+	for name, test := range tests {
+		if err := gcsError(test.Err, "changelogs"); !strings.HasPrefix(err.Error(), test.Expected) {
+			t.Errorf("test %q failed: expected error starting with %q, got %q", name, test.Expected, err)
+		}
+	}
+}
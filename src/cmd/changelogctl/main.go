@@ -28,18 +28,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"time"
 
+	"cloud.google.com/go/storage"
 	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
 	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
+	"cos.googlesource.com/cos/tools.git/src/pkg/httpclient"
+	"gopkg.in/yaml.v3"
 
 	"github.com/urfave/cli/v2"
-	"go.chromium.org/luci/common/api/gerrit"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -51,13 +52,15 @@ const (
 	externalManifestRepo = "cos/manifest-snapshots"
 )
 
-func getHTTPClient() (*http.Client, error) {
+// httpTimeout is the time limit of each request to Gerrit and Gitiles.
+var httpTimeout = httpclient.DefaultTimeout
+
+func getHTTPClient(cookieFile string) (*http.Client, error) {
 	log.Debug("Creating HTTP client")
-	creds, err := google.FindDefaultCredentials(context.Background(), gerrit.OAuthScope)
-	if err != nil {
-		return nil, fmt.Errorf("no application default credentials found - run `gcloud auth application-default login` and try again")
+	if cookieFile != "" {
+		return newCookieClient(cookieFile)
 	}
-	return oauth2.NewClient(oauth2.NoContext, creds.TokenSource), nil
+	return httpclient.NewDefault(context.Background(), &httpclient.Options{Timeout: httpTimeout})
 }
 
 func writeChangelogAsJSON(source string, target string, changes map[string]*changelog.RepoLog) error {
@@ -73,22 +76,151 @@ func writeChangelogAsJSON(source string, target string, changes map[string]*chan
 	return nil
 }
 
-func generateChangelog(source, target, instance, manifestRepo string) error {
+func writeChangelogAsYAML(source string, target string, changes map[string]*changelog.RepoLog) error {
+	fileName := fmt.Sprintf("%s -> %s.yaml", source, target)
+	log.Infof("Writing changelog to %s\n", fileName)
+	yamlData, err := yaml.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("writeChangelogAsYAML: error marshalling changelog from: %s to: %s\n%v", source, target, err)
+	}
+	if err = ioutil.WriteFile(fileName, yamlData, 0644); err != nil {
+		return fmt.Errorf("writeChangelogAsYAML: error writing changelog to file: %s\n%v", fileName, err)
+	}
+	return nil
+}
+
+func writeChangelogAsOneline(source string, target string, changes map[string]*changelog.RepoLog) error {
+	fileName := fmt.Sprintf("%s -> %s.log", source, target)
+	log.Infof("Writing changelog to %s\n", fileName)
+	if err := ioutil.WriteFile(fileName, []byte(changelog.OnelineLog(changes)), 0644); err != nil {
+		return fmt.Errorf("writeChangelogAsOneline: error writing changelog to file: %s\n%v", fileName, err)
+	}
+	return nil
+}
+
+func writeChangelogAsSummary(source string, target string, changes map[string]*changelog.RepoLog) error {
+	fileName := fmt.Sprintf("%s -> %s.summary.json", source, target)
+	log.Infof("Writing changelog summary to %s\n", fileName)
+	jsonData, err := json.MarshalIndent(changelog.Summarize(changes), "", "    ")
+	if err != nil {
+		return fmt.Errorf("writeChangelogAsSummary: error marshalling changelog summary from: %s to: %s\n%v", source, target, err)
+	}
+	if err = ioutil.WriteFile(fileName, jsonData, 0644); err != nil {
+		return fmt.Errorf("writeChangelogAsSummary: error writing changelog summary to file: %s\n%v", fileName, err)
+	}
+	return nil
+}
+
+func writeChangelogAsMarkdown(source string, target string, changes map[string]*changelog.RepoLog) error {
+	fileName := fmt.Sprintf("%s -> %s.md", source, target)
+	log.Infof("Writing changelog to %s\n", fileName)
+	markdown, err := changelog.RenderMarkdown(changes)
+	if err != nil {
+		return fmt.Errorf("writeChangelogAsMarkdown: error rendering changelog from: %s to: %s\n%v", source, target, err)
+	}
+	if err = ioutil.WriteFile(fileName, []byte(markdown), 0644); err != nil {
+		return fmt.Errorf("writeChangelogAsMarkdown: error writing changelog to file: %s\n%v", fileName, err)
+	}
+	return nil
+}
+
+func writeChangelogByBugAsJSON(source string, target string, changes map[string]*changelog.RepoLog) error {
+	fileName := fmt.Sprintf("%s -> %s.bugs.json", source, target)
+	log.Infof("Writing changelog grouped by bug to %s\n", fileName)
+	jsonData, err := json.MarshalIndent(changelog.GroupByBug(changes), "", "    ")
+	if err != nil {
+		return fmt.Errorf("writeChangelogByBugAsJSON: error marshalling changelog from: %s to: %s\n%v", source, target, err)
+	}
+	if err = ioutil.WriteFile(fileName, jsonData, 0644); err != nil {
+		return fmt.Errorf("writeChangelogByBugAsJSON: error writing changelog to file: %s\n%v", fileName, err)
+	}
+	return nil
+}
+
+func writeChangelogByBugAsYAML(source string, target string, changes map[string]*changelog.RepoLog) error {
+	fileName := fmt.Sprintf("%s -> %s.bugs.yaml", source, target)
+	log.Infof("Writing changelog grouped by bug to %s\n", fileName)
+	yamlData, err := yaml.Marshal(changelog.GroupByBug(changes))
+	if err != nil {
+		return fmt.Errorf("writeChangelogByBugAsYAML: error marshalling changelog from: %s to: %s\n%v", source, target, err)
+	}
+	if err = ioutil.WriteFile(fileName, yamlData, 0644); err != nil {
+		return fmt.Errorf("writeChangelogByBugAsYAML: error writing changelog to file: %s\n%v", fileName, err)
+	}
+	return nil
+}
+
+func generateChangelog(source, target, instance, manifestRepo, format, cookieFile string, groupByBug bool, gcsOutput string, diffStats bool) error {
+	var writeChangelog func(string, string, map[string]*changelog.RepoLog) error
+	switch format {
+	case "json":
+		writeChangelog = writeChangelogAsJSON
+	case "yaml":
+		writeChangelog = writeChangelogAsYAML
+	case "oneline":
+		writeChangelog = writeChangelogAsOneline
+	case "summary":
+		writeChangelog = writeChangelogAsSummary
+	case "markdown":
+		writeChangelog = writeChangelogAsMarkdown
+	default:
+		return fmt.Errorf("generateChangelog: unsupported output format %q, must be one of: json, yaml, oneline, summary, markdown", format)
+	}
+	if groupByBug {
+		switch format {
+		case "json":
+			writeChangelog = writeChangelogByBugAsJSON
+		case "yaml":
+			writeChangelog = writeChangelogByBugAsYAML
+		default:
+			return fmt.Errorf("generateChangelog: --group-by-bug is only supported with the json and yaml formats, got %q", format)
+		}
+	}
+	ctx := context.Background()
+	var storageClient *storage.Client
+	if gcsOutput != "" {
+		if format != "json" || groupByBug {
+			return fmt.Errorf("generateChangelog: --gcs-output is only supported with the json format")
+		}
+		if _, _, err := parseGCSOutput(gcsOutput); err != nil {
+			return fmt.Errorf("generateChangelog: %v", err)
+		}
+		// Create the client before retrieving the changelog, so that missing
+		// credentials are reported without waiting for the changelog.
+		var err error
+		storageClient, err = newStorageClient(ctx)
+		if err != nil {
+			return fmt.Errorf("generateChangelog: failed to create GCS client - run `gcloud auth application-default login` and try again: \n%v", err)
+		}
+		defer storageClient.Close()
+	}
 	start := time.Now()
-	httpClient, err := getHTTPClient()
+	httpClient, err := getHTTPClient(cookieFile)
 	if err != nil {
 		return fmt.Errorf("generateChangelog: failed to create http client: \n%v", err)
 	}
-	sourceToTargetChanges, targetToSourceChanges, err := changelog.Changelog(httpClient, source, target, instance, manifestRepo, "", -1)
+	sourceToTargetChanges, targetToSourceChanges, _, err := changelog.ChangelogWithOptions(ctx, httpClient, source, target, instance, manifestRepo, "", -1, &changelog.Options{DiffStats: diffStats})
 	if err != nil {
 		return fmt.Errorf("generateChangelog: error retrieving changelog between builds %s and %s on GoB instance: %s with manifest repository: %s\n%v",
 			source, target, instance, manifestRepo, err)
 	}
-	if err := writeChangelogAsJSON(source, target, sourceToTargetChanges); err != nil {
+	if storageClient != nil {
+		// Uploads are used by pipelines, so failures are returned instead
+		// of logged.
+		if err := uploadChangelogAsJSON(ctx, storageClient, gcsOutput, source, target, sourceToTargetChanges); err != nil {
+			return err
+		}
+		if err := uploadChangelogAsJSON(ctx, storageClient, gcsOutput, target, source, targetToSourceChanges); err != nil {
+			return err
+		}
+		log.Infof("Retrieved changelog in %s\n", time.Since(start))
+		return nil
+	}
+	if err := writeChangelog(source, target, sourceToTargetChanges); err != nil {
 		log.Errorf("generateChangelog: error writing first changelog with source: %s and target: %s\n%v\n",
 			source, target, err)
 	}
-	if err := writeChangelogAsJSON(target, source, targetToSourceChanges); err != nil {
+	if err := writeChangelog(target, source, targetToSourceChanges); err != nil {
 		log.Errorf("generateChangelog: Error writing second changelog with source: %s and target: %s\n%v\n",
 			target, source, err)
 	}
@@ -96,53 +228,98 @@ func generateChangelog(source, target, instance, manifestRepo string) error {
 	return nil
 }
 
-func getBuildForCL(gerrit, fallback, gob, manifestRepo, targetCL string) error {
-	httpClient, err := getHTTPClient()
+func validateManifest(manifestFile string) error {
+	manifest, err := ioutil.ReadFile(manifestFile)
+	if err != nil {
+		return fmt.Errorf("validateManifest: error reading manifest file %s: %v", manifestFile, err)
+	}
+	if err := changelog.ValidateManifest(string(manifest)); err != nil {
+		return fmt.Errorf("validateManifest: %s: %v", manifestFile, err)
+	}
+	fmt.Printf("%s is a valid manifest\n", manifestFile)
+	return nil
+}
+
+// writeBuildResponse prints the first build containing a CL. If asJSON is
+// set, the build is printed as JSON along with the CL's repository, branch,
+// submission time and owner.
+func writeBuildResponse(w io.Writer, buildData *findbuild.BuildResponse, asJSON bool) error {
+	if !asJSON {
+		_, err := fmt.Fprintf(w, "Build: %s\n", buildData.BuildNum)
+		return err
+	}
+	jsonData, err := json.MarshalIndent(buildData, "", "    ")
+	if err != nil {
+		return fmt.Errorf("writeBuildResponse: error marshalling build for CL %s\n%v", buildData.CLNum, err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", jsonData)
+	return err
+}
+
+func getBuildForCL(gerrit, fallback, fallbackPrefix, fallbackPrefixMap, gob, manifestRepo, targetCL, revision, cookieFile string, asJSON bool) error {
+	prefixRules, err := findbuild.ParseRepoPrefixRules(fallbackPrefixMap)
+	if err != nil {
+		return fmt.Errorf("error parsing repo prefix map: %v", err)
+	}
+	httpClient, err := getHTTPClient(cookieFile)
 	if err != nil {
 		return fmt.Errorf("error creating http client: %v", err)
 	}
+	// The signed push status is only included in JSON output.
 	req := &findbuild.BuildRequest{
-		HTTPClient:   httpClient,
-		GerritHost:   gerrit,
-		GitilesHost:  gob,
-		ManifestRepo: manifestRepo,
-		CL:           targetCL,
+		HTTPClient:      httpClient,
+		GerritHost:      gerrit,
+		GitilesHost:     gob,
+		ManifestRepo:    manifestRepo,
+		CL:              targetCL,
+		Revision:        revision,
+		VerifySignature: asJSON,
 	}
 	buildData, clErr := findbuild.FindBuild(req)
 	if clErr != nil && clErr.HTTPCode() == "404" {
 		log.Debugf("Query failed on Gerrit url %s and Gitiles url %s, retrying with fallback urls", externalGerritURL, externalGoBURL)
 		fallbackReq := &findbuild.BuildRequest{
-			HTTPClient:   httpClient,
-			GerritHost:   fallback,
-			GitilesHost:  gob,
-			ManifestRepo: manifestRepo,
-			CL:           targetCL,
+			HTTPClient:      httpClient,
+			GerritHost:      fallback,
+			GitilesHost:     gob,
+			ManifestRepo:    manifestRepo,
+			CL:              targetCL,
+			Revision:        revision,
+			VerifySignature: asJSON,
+			RepoPrefix:      fallbackPrefix,
+			RepoPrefixRules: prefixRules,
 		}
 		buildData, clErr = findbuild.FindBuild(fallbackReq)
 	}
 	if clErr != nil {
 		return clErr
 	}
-	fmt.Printf("Build: %s\n", buildData.BuildNum)
-	return nil
+	return writeBuildResponse(os.Stdout, buildData, asJSON)
 }
 
 func main() {
-	var mode, gobURL, gerritURL, fallbackURL, manifestRepo string
+	var mode, gobURL, gerritURL, fallbackURL, fallbackPrefix, fallbackPrefixMap, manifestRepo, revision string
+	var format string
+	var cookieFile string
+	var jsonOutput bool
+	var groupByBug bool
+	var gcsOutput string
+	var diffStats bool
 	var debug bool
 	app := &cli.App{
 		Name:  "changelogctl",
 		Usage: "get commits between builds or first build containing CL",
-		Description: fmt.Sprintf("%s\n   %s",
+		Description: fmt.Sprintf("%s\n   %s\n   %s",
 			"changelog usage: ./changelogctl -m changelog [build-number || image-name] [build-number || image-name]",
-			"findbuild usage: ./changelogctl -m findbuild [CL-number || commit-SHA]",
+			"findbuild usage: ./changelogctl -m findbuild [CL-number || commit-SHA || bug:bug-number]",
+			"validate usage: ./changelogctl -m validate [manifest-file]",
 		),
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:        "mode",
 				Value:       "",
 				Aliases:     []string{"m"},
-				Usage:       "Specify query mode. Acceptable values: changelog | findbuild",
+				Usage:       "Specify query mode. Acceptable values: changelog | findbuild | validate",
 				Destination: &mode,
 				Required:    true,
 			},
@@ -158,6 +335,18 @@ func main() {
 				Usage:       "Fallback Gerrit `URL` to query from",
 				Destination: &fallbackURL,
 			},
+			&cli.StringFlag{
+				Name:        "repo-prefix",
+				Value:       findbuild.FallbackRepoPrefix,
+				Usage:       "`PREFIX` that fallback Gerrit repositories are mirrored under in the manifest",
+				Destination: &fallbackPrefix,
+			},
+			&cli.StringFlag{
+				Name:        "repo-prefix-map",
+				Value:       "",
+				Usage:       "Comma separated `PATTERN=PREFIX` pairs mapping fallback Gerrit repositories to mirror prefixes, ex. ^chromiumos/=mirrors/cros/. Repositories matching no pattern use --repo-prefix",
+				Destination: &fallbackPrefixMap,
+			},
 			&cli.StringFlag{
 				Name:        "gob",
 				Value:       externalGoBURL,
@@ -171,6 +360,54 @@ func main() {
 				Usage:       "`REPO` containing Manifest file",
 				Destination: &manifestRepo,
 			},
+			&cli.StringFlag{
+				Name:        "revision",
+				Value:       "",
+				Usage:       "Patchset number or revision `SHA` of the CL to search for in findbuild mode. Defaults to the current revision",
+				Destination: &revision,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Value:       "json",
+				Usage:       "Changelog output `FORMAT`. Acceptable values: json | yaml | oneline | summary | markdown",
+				Destination: &format,
+			},
+			&cli.BoolFlag{
+				Name:        "group-by-bug",
+				Value:       false,
+				Usage:       "Group the commits of the changelog by the bugs they reference instead of by repository. Only supported with the json and yaml formats",
+				Destination: &groupByBug,
+			},
+			&cli.BoolFlag{
+				Name:        "diff-stats",
+				Value:       false,
+				Usage:       "Include the number of files and lines changed by each commit in the changelog. Requires an additional Gitiles request per commit",
+				Destination: &diffStats,
+			},
+			&cli.StringFlag{
+				Name:        "gcs-output",
+				Value:       "",
+				Usage:       "Upload the changelogs as JSON to objects under `gs://BUCKET/PREFIX` instead of writing local files, using application default credentials",
+				Destination: &gcsOutput,
+			},
+			&cli.BoolFlag{
+				Name:        "json",
+				Value:       false,
+				Usage:       "Print the build found in findbuild mode as JSON, with the CL's repository, branch, submission time and owner",
+				Destination: &jsonOutput,
+			},
+			&cli.StringFlag{
+				Name:        "cookie-file",
+				Value:       "",
+				Usage:       "Authenticate with the cookies in a .gitcookies `FILE` instead of application default credentials",
+				Destination: &cookieFile,
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Value:       httpclient.DefaultTimeout,
+				Usage:       "Time limit of each request to Gerrit and Gitiles, ex. 30s. Failed requests are retried separately",
+				Destination: &httpTimeout,
+			},
 			&cli.BoolFlag{
 				Name:        "debug",
 				Value:       false,
@@ -186,19 +423,24 @@ func main() {
 			switch mode {
 			case "findbuild":
 				if c.NArg() != 1 {
-					return errors.New("must specify CL number (ex. 3280) or commit SHA (ex. 18d4ce48c1dc2f530120f85973fec348367f78a0)")
+					return errors.New("must specify CL number (ex. 3280), commit SHA (ex. 18d4ce48c1dc2f530120f85973fec348367f78a0) or bug (ex. bug:123456)")
 				}
 				targetCL := c.Args().Get(0)
-				return getBuildForCL(gerritURL, fallbackURL, gobURL, manifestRepo, targetCL)
+				return getBuildForCL(gerritURL, fallbackURL, fallbackPrefix, fallbackPrefixMap, gobURL, manifestRepo, targetCL, revision, cookieFile, jsonOutput)
 			case "changelog":
 				if c.NArg() != 2 {
 					return errors.New("must specify two build numbers (ex. 13310.1034.0) or image names (ex. cos-rc-85-13310-1034-0) to retrieve changelog")
 				}
 				source := c.Args().Get(0)
 				target := c.Args().Get(1)
-				return generateChangelog(source, target, gobURL, manifestRepo)
+				return generateChangelog(source, target, gobURL, manifestRepo, format, cookieFile, groupByBug, gcsOutput, diffStats)
+			case "validate":
+				if c.NArg() != 1 {
+					return errors.New("must specify a manifest file to validate")
+				}
+				return validateManifest(c.Args().Get(0))
 			default:
-				return fmt.Errorf("please specify either \"findbuild\" or \"changelog\" mode")
+				return fmt.Errorf("please specify \"findbuild\", \"changelog\" or \"validate\" mode")
 			}
 		},
 	}
@@ -23,6 +23,11 @@ import (
 	"os"
 	"os/exec"
 	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/changelog"
+	"cos.googlesource.com/cos/tools.git/src/pkg/findbuild"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -51,7 +56,7 @@ func setup() error {
 	// check if binary exists in the desired location.If not, compile from source.
 	_, err := os.Stat("changelogctl")
 	if errors.Is(err, os.ErrNotExist) {
-		cmd := exec.Command("go", "build", "-o", "changelogctl", "main.go")
+		cmd := exec.Command("go", "build", "-o", "changelogctl", ".")
 		return cmd.Run()
 	}
 	return err
@@ -323,3 +328,98 @@ func TestFindBuild(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteBuildResponse(t *testing.T) {
+	buildData := &findbuild.BuildResponse{
+		BuildNum:  "12371.1072.0",
+		CLNum:     "3781",
+		Repo:      "cos/overlays",
+		Branch:    "release-R81",
+		Release:   "release-R81",
+		Submitted: time.Date(2020, 6, 4, 18, 30, 0, 0, time.UTC),
+		Owner:     "Austin Yuan",
+	}
+	tests := map[string]struct {
+		AsJSON bool
+		Output string
+	}{
+		"text": {
+			Output: "Build: 12371.1072.0\n",
+		},
+		"json": {
+			AsJSON: true,
+			Output: `{
+    "BuildNum": "12371.1072.0",
+    "CLNum": "3781",
+    "Repo": "cos/overlays",
+    "Branch": "release-R81",
+    "Release": "release-R81",
+    "Submitted": "2020-06-04T18:30:00Z",
+    "Owner": "Austin Yuan"
+}
+`,
+		},
+	}
+	for name, test := range tests {
+		var out bytes.Buffer
+		if err := writeBuildResponse(&out, buildData, test.AsJSON); err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if out.String() != test.Output {
+			t.Errorf("test %q failed: expected output:\n%s\ngot:\n%s", name, test.Output, out.String())
+		}
+	}
+}
+
+func TestWriteChangelogAsYAML(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "changelogctl")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	changes := map[string]*changelog.RepoLog{
+		"cos/overlays": {
+			Commits: []*changelog.Commit{{
+				SHA:           "0a1b2c3d",
+				AuthorName:    "Austin Yuan",
+				CommitterName: "Austin Yuan",
+				Subject:       "Update kernel",
+				Bugs:          []string{"b/123456"},
+				ReleaseNote:   "Updated kernel",
+				CommitTime:    "Thu Jun 04 18:30:00 2020",
+			}},
+			InstanceURL: gitilesURL,
+			Repo:        "cos/overlays",
+			SourceSHA:   "4e5f6a7b",
+			TargetSHA:   "0a1b2c3d",
+		},
+	}
+	if err := writeChangelogAsYAML("15050.0.0", "15056.0.0", changes); err != nil {
+		t.Fatalf("writeChangelogAsYAML failed: %v", err)
+	}
+	contents, err := ioutil.ReadFile("15050.0.0 -> 15056.0.0.yaml")
+	if err != nil {
+		t.Fatalf("failed to read changelog: %v", err)
+	}
+	var data map[string]interface{}
+	if err := yaml.Unmarshal(contents, &data); err != nil {
+		t.Fatalf("changelog is not valid YAML: %v", err)
+	}
+	if len(data) != 1 || !validateRepoLog(data["cos/overlays"]) {
+		t.Fatalf("expected valid changelog, got:\n%s", contents)
+	}
+	repoLog := data["cos/overlays"].(map[string]interface{})
+	if repoLog["SourceSHA"] != "4e5f6a7b" || repoLog["TargetSHA"] != "0a1b2c3d" {
+		t.Errorf("expected SourceSHA 4e5f6a7b and TargetSHA 0a1b2c3d, got:\n%s", contents)
+	}
+}
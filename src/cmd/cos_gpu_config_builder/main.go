@@ -12,7 +12,11 @@ import (
 
 var (
 	bucket        = flag.String("gcs-bucket", "cos-gpu-configs", "GCS bucket to upload GPU configs to.")
-	kernelVersion = flag.String("kernel-version", "", "Kernel version for COS GPU precompilation build request, example: 5.10.105-23.m97")
+	mode          = flag.String("mode", "kernel", "kernel or image, for generating kernel CI or image CI configs.")
+	kernelVersion = flag.String("kernel-version", "", "Kernel version for COS GPU precompilation build request in kernel mode, example: 5.10.105-23.m97")
+	buildNumber   = flag.String("build-number", "", "COS image build number for COS GPU precompilation build request in image mode, example: 16108.403.42")
+	milestone     = flag.String("milestone", "", "Milestone of the COS image build in image mode, example: 97")
+	dryRun        = flag.Bool("dry-run", false, "Print the generated configs and the GCS paths they would be uploaded to, without uploading them.")
 
 	driverVersions = flag.String("driver-versions", "", "Driver version/ (Comma separated if multiple driver versions) for COS GPU precompilation build request, example 450.119.04 / 450.119.04,470.150.03")
 )
@@ -20,22 +24,39 @@ var (
 func main() {
 	flag.Parse()
 
-	if *kernelVersion == "" || *driverVersions == "" {
-		log.Fatal("empty kernel version: %s or driver version:%s specified", kernelVersion, driverVersions)
+	if err := gpuconfig.ValidateDriverVersions(*driverVersions); err != nil {
+		log.Fatal(err)
+	}
+	switch *mode {
+	case "kernel":
+		if err := gpuconfig.ValidateKernelVersion(*kernelVersion); err != nil {
+			log.Fatal(err)
+		}
+	case "image":
+		if *buildNumber == "" || *milestone == "" {
+			log.Fatalf("empty build number: %q or milestone: %q specified", *buildNumber, *milestone)
+		}
+	default:
+		log.Fatalf("unknown mode %q, must be kernel or image", *mode)
 	}
 
 	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
 	if err != nil {
-		log.Fatal("failed to setup client for GCS: %v", err)
+		log.Fatalf("failed to setup client for GCS: %v", err)
 	}
 
-	configs, err := gpuconfig.GenerateKernelCIConfigs(ctx, client, *kernelVersion, strings.Split(*driverVersions, ","))
+	var configs []gpuconfig.GPUPrecompilationConfig
+	if *mode == "image" {
+		configs, err = gpuconfig.GenerateImageCIConfigs(ctx, client, *buildNumber, *milestone, strings.Split(*driverVersions, ","))
+	} else {
+		configs, err = gpuconfig.GenerateKernelCIConfigs(ctx, client, *kernelVersion, strings.Split(*driverVersions, ","))
+	}
 	if err != nil {
-		log.Fatal("gpu config generation failed: %v", err)
+		log.Fatalf("gpu config generation failed: %v", err)
 	}
 
-	if err := gpuconfig.UploadConfigs(ctx, client, configs, *bucket); err != nil {
-		log.Fatal("uploading gpu config failed: %v", err)
+	if err := gpuconfig.UploadConfigs(ctx, client, configs, *bucket, *dryRun); err != nil {
+		log.Fatalf("uploading gpu config failed: %v", err)
 	}
 }
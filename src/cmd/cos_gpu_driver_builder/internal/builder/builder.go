@@ -3,9 +3,11 @@ package builder
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"cloud.google.com/go/storage"
@@ -16,7 +18,7 @@ import (
 
 const (
 	linkerLocation        = "/bin/ld"
-	installDirTemplate    = "/install/%s"
+	installRoot           = "/install"
 	defaultFilePermission = 0755
 	kernelSrcTemplate     = "usr/src/linux-headers-*"
 )
@@ -29,54 +31,89 @@ func kernelSrcDirectory(dirName string) string {
 	return files[0]
 }
 
-func nvidiaInstallerCommand(dirName, runfile string, config gpuconfig.GPUPrecompilationConfig) *exec.Cmd {
+// commandEnv returns the environment of the process with the variables in env
+// added or overridden.
+func commandEnv(env map[string]string) []string {
+	var result []string
+	for _, kv := range os.Environ() {
+		if _, ok := env[strings.SplitN(kv, "=", 2)[0]]; !ok {
+			result = append(result, kv)
+		}
+	}
+	var keys []string
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, key+"="+env[key])
+	}
+	return result
+}
+
+func nvidiaInstallerCommand(dirName, runfile string, config gpuconfig.GPUPrecompilationConfig, env map[string]string) *exec.Cmd {
 
-	cmd := exec.Command(filepath.Join(dirName, runfile), "--kernel-source-path="+kernelSrcDirectory(dirName), "--add-this-kernel", "--no-install-compat32-libs", "--silent", "--accept-license")
+	cmd := exec.Command(filepath.Join(dirName, runfile), "--kernel-source-path="+kernelSrcDirectory(dirName), "--add-this-kernel", "--no-install-compat32-libs", "--silent", "--accept-license", "--log-file-name="+filepath.Join(dirName, "nvidia-installer.log"))
 	cmd.Dir = dirName
+	cmd.Env = commandEnv(env)
 	return cmd
 }
 
+// BuildPrecompiledDriver builds the precompiled driver for config in a new
+// directory under installRoot. The toolchain environment is only set for the
+// NVIDIA installer command, not in the process, so that builds can run
+// concurrently. It returns the directory, which the caller must remove, and
+// the name of the precompiled driver in it.
 func BuildPrecompiledDriver(ctx context.Context, client *storage.Client, config gpuconfig.GPUPrecompilationConfig) (string, string, error) {
 	var err error
-	dirName := fmt.Sprintf(installDirTemplate, config.Version)
-	if err = os.MkdirAll(dirName, defaultFilePermission); err != nil {
+	if err = os.MkdirAll(installRoot, defaultFilePermission); err != nil {
+		return "", "", fmt.Errorf("failed to create installation dir: %v", err)
+	}
+	dirName, err := ioutil.TempDir(installRoot, config.Version+"-")
+	if err != nil {
 		return "", "", fmt.Errorf("failed to create installation dir: %v", err)
 	}
 	downloader := gpuconfig.NewGPUArtifactsDownloader(ctx, client, config)
 	// download NVIDIA runfile
 	var nvidiaInstaller string
 	if nvidiaInstaller, err = downloader.DownloadNVIDIARunfile(dirName); err != nil {
-		return "", "", fmt.Errorf("failed to download NVIDIA runfile: %v", err)
+		return dirName, "", fmt.Errorf("failed to download NVIDIA runfile: %v", err)
 	}
 	// install kernel headers and toolchain
-	// sets SYSROOT and PATH env vars
-	if err = cos.InstallCrossToolchain(downloader, dirName); err != nil {
-		return "", "", fmt.Errorf("failed to install toolchain: %v", err)
+	if err = cos.UnpackCrossToolchain(downloader, dirName); err != nil {
+		return dirName, "", fmt.Errorf("failed to install toolchain: %v", err)
 	}
-	// set CC CXX env vars from toolchain_env
-	if err = cos.SetCompilationEnv(downloader); err != nil {
-		return "", "", fmt.Errorf("failed to set compilation env vars: %v", err)
+	// get CC CXX env vars from toolchain_env
+	compilationEnv, err := cos.CompilationEnv(downloader, dirName)
+	if err != nil {
+		return dirName, "", fmt.Errorf("failed to get compilation env vars: %v", err)
 	}
 	// create symlink to ld - required by NVIDIA driver package
 	if err = cos.ForceSymlinkLinker(filepath.Join(dirName, linkerLocation)); err != nil {
-		return "", "", fmt.Errorf("failed to create symlink to COS linker: %v", err)
+		return dirName, "", fmt.Errorf("failed to create symlink to COS linker: %v", err)
 	}
-	cc := os.Getenv("CC")
+	cc := compilationEnv["CC"]
 	if cc == "" {
-		return "", "", fmt.Errorf("failed to find CC in env")
-	} else {
-		// create a wrapper removing -Werror=strict-prototypes from the CC command line.
-		if err = cos.AddCCWrapperToPath(dirName, dirName, cc); err != nil {
-			return "", "", fmt.Errorf("failed to create CC wrapper: %v", err)
-		}
+		return dirName, "", fmt.Errorf("failed to find CC in env")
+	}
+	// create a wrapper removing -Werror=strict-prototypes from the CC command line.
+	if err = cos.WriteCCWrapper(dirName, dirName, cc); err != nil {
+		return dirName, "", fmt.Errorf("failed to create CC wrapper: %v", err)
+	}
+	// the CC wrapper in dirName comes first in PATH, then the toolchain.
+	env := cos.CrossToolchainEnv(dirName, os.Getenv("PATH"))
+	env["PATH"] = dirName + ":" + env["PATH"]
+	for key, value := range compilationEnv {
+		env[key] = value
 	}
+	env["TMPDIR"] = dirName
 	// run NVIDIA driver package
 	if err = os.Chmod(filepath.Join(dirName, nvidiaInstaller), defaultFilePermission); err != nil {
-		return "", "", err
+		return dirName, "", err
 	}
-	cmd := nvidiaInstallerCommand(dirName, nvidiaInstaller, config)
+	cmd := nvidiaInstallerCommand(dirName, nvidiaInstaller, config, env)
 	if err = utils.RunCommandAndLogOutput(cmd, false); err != nil {
-		return "", "", fmt.Errorf("error running NVIDIA driver installation package: %v", err)
+		return dirName, "", fmt.Errorf("error running NVIDIA driver installation package: %v", err)
 	}
 
 	outputFileName := strings.Split(nvidiaInstaller, ".run")[0] + "-custom.run"
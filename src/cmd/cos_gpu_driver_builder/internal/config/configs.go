@@ -7,6 +7,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"cloud.google.com/go/storage"
 	"cos.googlesource.com/cos/tools.git/src/cmd/cos_gpu_driver_builder/internal/builder"
@@ -14,39 +16,108 @@ import (
 	"cos.googlesource.com/cos/tools.git/src/pkg/gpuconfig"
 )
 
+// buildPrecompiledDriver is a variable so tests can stub out the driver build.
+var buildPrecompiledDriver = builder.BuildPrecompiledDriver
+
 func outputDriverFile(config gpuconfig.GPUPrecompilationConfig) string {
 	driverRunfile := fmt.Sprintf("NVIDIA-Linux-x86_64-%s-custom.run", config.DriverVersion)
 	return fmt.Sprintf("%s/%s", config.ProtoConfig.GetDriverOutputGcsDir(), driverRunfile)
 }
 
-func ProcessConfigs(ctx context.Context, client *storage.Client, configs []gpuconfig.GPUPrecompilationConfig, dryRun bool) error {
-	for _, config := range configs {
-		log.Printf("building precompiled GPU driver for %s:%s, driver version %s\n", config.VersionType, config.Version, config.DriverVersion)
-		if processed, _ := gcs.GCSObjectExists(ctx, client, outputDriverFile(config)); processed {
-			log.Println("precompiled driver exists, skipping the build.")
-			continue
-		}
-		dir, precompiledDriver, err := builder.BuildPrecompiledDriver(ctx, client, config)
+// processConfig builds the precompiled driver for a single config and uploads
+// it to the config's driver output directory. Unless force is set, the build
+// is skipped if the driver was already uploaded. It returns the outcome of the
+// build and the GCS paths of the driver artifacts.
+func processConfig(ctx context.Context, client *storage.Client, config gpuconfig.GPUPrecompilationConfig, dryRun bool, force bool) (string, []string, error) {
+	log.Printf("building precompiled GPU driver for %s:%s, driver version %s\n", config.VersionType, config.Version, config.DriverVersion)
+	if processed, _ := gcs.GCSObjectExists(ctx, client, outputDriverFile(config)); processed && !force {
+		log.Println("precompiled driver exists, skipping the build.")
+		return outcomeSkipped, []string{outputDriverFile(config)}, nil
+	}
+	dir, precompiledDriver, err := buildPrecompiledDriver(ctx, client, config)
+	if dir != "" {
 		defer os.RemoveAll(dir)
-		if err != nil {
-			log.Printf("precompilation failed for: %s, driver version %s: %v\n", config.Version, config.DriverVersion, err)
-			continue
-		}
-		outputURL, err := url.Parse(config.ProtoConfig.GetDriverOutputGcsDir())
-		if err != nil {
-			log.Printf("failed to parse driver output gcs dir: %v\n", err)
-			continue
+	}
+	if err != nil {
+		return outcomeFailed, nil, fmt.Errorf("precompilation failed for: %s, driver version %s: %v", config.Version, config.DriverVersion, err)
+	}
+	outputURL, err := url.Parse(config.ProtoConfig.GetDriverOutputGcsDir())
+	if err != nil {
+		return outcomeFailed, nil, fmt.Errorf("failed to parse driver output gcs dir: %v", err)
+	}
+	outputURL.Path = filepath.Join(outputURL.Path, precompiledDriver)
+	outputDriverFile := outputURL.String()
+	if !dryRun {
+		if err := gcs.UploadGCSObject(ctx, client, filepath.Join(dir, precompiledDriver), outputDriverFile); err != nil {
+			return outcomeFailed, nil, fmt.Errorf("export failed for: %s, driver version %s: %v", config.Version, config.DriverVersion, err)
 		}
-		outputURL.Path = filepath.Join(outputURL.Path, precompiledDriver)
-		outputDriverFile := outputURL.String()
-		if !dryRun {
+		log.Printf("successfully uploaded precompiled GPU driver for %s:%s, driver version %s\n", config.VersionType, config.Version, config.DriverVersion)
+	}
+	return outcomeSucceeded, []string{outputDriverFile}, nil
+}
 
-			if err := gcs.UploadGCSObject(ctx, client, filepath.Join(dir, precompiledDriver), outputDriverFile); err != nil {
-				log.Printf("export failed for: %s, driver version %s: %v\n", config.Version, config.DriverVersion, err)
-				continue
+// ProcessConfigs builds and uploads the precompiled drivers for configs, with
+// at most concurrency builds running at once. A failing config does not stop
+// the others from being processed; all failures are returned in one error.
+// Unless dryRun is set, a status object recording the outcome is written next
+// to each config that was read from GCS. Unless force is set, configs whose
+// status object already records a successful build or whose driver was
+// already uploaded are skipped.
+func ProcessConfigs(ctx context.Context, client *storage.Client, configs []gpuconfig.GPUPrecompilationConfig, dryRun bool, concurrency int, force bool) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	errs := make([]error, len(configs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, config := range configs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, config gpuconfig.GPUPrecompilationConfig) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := processConfigWithStatus(ctx, client, config, dryRun, force); err != nil {
+				log.Println(err)
+				errs[i] = err
 			}
-			log.Printf("successfully uploaded precompiled GPU driver for %s:%s, driver version %s\n", config.VersionType, config.Version, config.DriverVersion)
+		}(i, config)
+	}
+	wg.Wait()
+
+	var failures []string
+	for _, err := range errs {
+		if err != nil {
+			failures = append(failures, err.Error())
 		}
 	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d configs failed:\n%s", len(failures), len(configs), strings.Join(failures, "\n"))
+	}
 	return nil
 }
+
+// processConfigWithStatus processes a single config and, unless dryRun is
+// set, records the outcome in its status object.
+func processConfigWithStatus(ctx context.Context, client *storage.Client, config gpuconfig.GPUPrecompilationConfig, dryRun bool, force bool) error {
+	if !force {
+		processed, err := alreadyProcessed(ctx, client, config)
+		if err != nil {
+			log.Printf("failed to read status for: %s, driver version %s, rebuilding: %v\n", config.Version, config.DriverVersion, err)
+		}
+		if processed {
+			log.Printf("config for %s:%s, driver version %s already processed, skipping.\n", config.VersionType, config.Version, config.DriverVersion)
+			return nil
+		}
+	}
+	outcome, artifacts, buildErr := processConfig(ctx, client, config, dryRun, force)
+	if dryRun {
+		return buildErr
+	}
+	if err := writeStatus(ctx, client, config, newBuildStatus(config, outcome, artifacts, buildErr)); err != nil {
+		if buildErr == nil {
+			return fmt.Errorf("failed to write status for: %s, driver version %s: %v", config.Version, config.DriverVersion, err)
+		}
+		log.Printf("failed to write status for: %s, driver version %s: %v\n", config.Version, config.DriverVersion, err)
+	}
+	return buildErr
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/gpuconfig"
+	"cos.googlesource.com/cos/tools.git/src/pkg/gpuconfig/pb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func testConfig(version string) gpuconfig.GPUPrecompilationConfig {
+	outputDir := "gs://test-drivers/" + version
+	return gpuconfig.GPUPrecompilationConfig{
+		ProtoConfig:   &pb.COSGPUBuildRequest{DriverOutputGcsDir: &outputDir},
+		DriverVersion: "510.47.03",
+		Version:       version,
+		VersionType:   "Image",
+	}
+}
+
+// stubBuildPrecompiledDriver replaces the driver build with a stub for the
+// duration of the test. The stub fails for the versions in failing and
+// otherwise writes a fake driver to a temporary directory. The returned
+// functions list the versions the stub was called for and return the maximum
+// number of builds that ran at once.
+func stubBuildPrecompiledDriver(t *testing.T, failing map[string]bool) (built func() []string, maxRunning func() int) {
+	orig := buildPrecompiledDriver
+	t.Cleanup(func() { buildPrecompiledDriver = orig })
+	var mu sync.Mutex
+	var versions []string
+	var running, maxRun int
+	buildPrecompiledDriver = func(_ context.Context, _ *storage.Client, config gpuconfig.GPUPrecompilationConfig) (string, string, error) {
+		mu.Lock()
+		versions = append(versions, config.Version)
+		running++
+		if running > maxRun {
+			maxRun = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+		if failing[config.Version] {
+			return "", "", errors.New("build failed")
+		}
+		dir, err := ioutil.TempDir("", "driver-build")
+		if err != nil {
+			return "", "", err
+		}
+		driver := "NVIDIA-Linux-x86_64-" + config.DriverVersion + "-custom.run"
+		if err := ioutil.WriteFile(filepath.Join(dir, driver), []byte("driver"), 0644); err != nil {
+			return dir, "", err
+		}
+		return dir, driver, nil
+	}
+	built = func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), versions...)
+	}
+	maxRunning = func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maxRun
+	}
+	return built, maxRunning
+}
+
+func TestProcessConfigs(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		configs     []string
+		failing     map[string]bool
+		existing    []string
+		dryRun      bool
+		concurrency int
+		wantObjects []string
+		wantErr     []string
+	}{
+		{
+			name:        "AllSucceed",
+			configs:     []string{"17800.0.0", "17801.0.0", "17802.0.0"},
+			concurrency: 2,
+			wantObjects: []string{
+				"/test-drivers/17800.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+				"/test-drivers/17801.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+				"/test-drivers/17802.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+			},
+		},
+		{
+			name:        "OneFails",
+			configs:     []string{"17800.0.0", "17801.0.0", "17802.0.0", "17803.0.0"},
+			failing:     map[string]bool{"17801.0.0": true},
+			concurrency: 2,
+			wantObjects: []string{
+				"/test-drivers/17800.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+				"/test-drivers/17802.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+				"/test-drivers/17803.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+			},
+			wantErr: []string{"1 of 4 configs failed", "precompilation failed for: 17801.0.0"},
+		},
+		{
+			name:        "SerialFailures",
+			configs:     []string{"17800.0.0", "17801.0.0", "17802.0.0"},
+			failing:     map[string]bool{"17800.0.0": true, "17802.0.0": true},
+			concurrency: 0,
+			wantObjects: []string{
+				"/test-drivers/17801.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+			},
+			wantErr: []string{"2 of 3 configs failed", "17800.0.0", "17802.0.0"},
+		},
+		{
+			name:        "SkipsExisting",
+			configs:     []string{"17800.0.0", "17801.0.0"},
+			failing:     map[string]bool{"17800.0.0": true},
+			existing:    []string{"/test-drivers/17800.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run"},
+			concurrency: 2,
+			wantObjects: []string{
+				"/test-drivers/17800.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+				"/test-drivers/17801.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run",
+			},
+		},
+		{
+			name:        "DryRun",
+			configs:     []string{"17800.0.0", "17801.0.0"},
+			dryRun:      true,
+			concurrency: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gcs := fakes.GCSForTest(t)
+			defer gcs.Close()
+			for _, object := range tc.existing {
+				gcs.Objects[object] = []byte("existing")
+			}
+
+			_, maxRunning := stubBuildPrecompiledDriver(t, tc.failing)
+
+			var configs []gpuconfig.GPUPrecompilationConfig
+			for _, version := range tc.configs {
+				configs = append(configs, testConfig(version))
+			}
+			err := ProcessConfigs(context.Background(), gcs.Client, configs, tc.dryRun, tc.concurrency, false)
+			if len(tc.wantErr) == 0 && err != nil {
+				t.Errorf("ProcessConfigs() failed: %v", err)
+			}
+			if len(tc.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("ProcessConfigs() succeeded; want error containing %q", tc.wantErr)
+				}
+				for _, want := range tc.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("ProcessConfigs() error %q; want it to contain %q", err, want)
+					}
+				}
+			}
+			var gotObjects []string
+			for object := range gcs.Objects {
+				gotObjects = append(gotObjects, object)
+			}
+			if diff := cmp.Diff(tc.wantObjects, gotObjects, cmpopts.EquateEmpty(), cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("ProcessConfigs() uploaded objects mismatch (-want +got):\n%s", diff)
+			}
+			wantMax := tc.concurrency
+			if wantMax < 1 {
+				wantMax = 1
+			}
+			if got := maxRunning(); got > wantMax {
+				t.Errorf("ProcessConfigs() ran %d builds at once; want at most %d", got, wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"cos.googlesource.com/cos/tools.git/src/pkg/gcs"
+	"cos.googlesource.com/cos/tools.git/src/pkg/gpuconfig"
+)
+
+const (
+	// statusObject is the name of the status object written next to a processed config.
+	statusObject = "status.json"
+
+	outcomeSucceeded = "succeeded"
+	outcomeSkipped   = "skipped"
+	outcomeFailed    = "failed"
+)
+
+// stubbing out current time as a function - allows the status timestamp to be injected in tests
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+// buildStatus is the machine-readable record of processing a single config.
+type buildStatus struct {
+	ConfigID      string   `json:"config_id"`
+	DriverVersion string   `json:"driver_version"`
+	Milestone     string   `json:"milestone"`
+	Version       string   `json:"version"`
+	VersionType   string   `json:"version_type"`
+	Outcome       string   `json:"outcome"`
+	Error         string   `json:"error,omitempty"`
+	Timestamp     string   `json:"timestamp"`
+	Artifacts     []string `json:"artifacts"`
+}
+
+func newBuildStatus(config gpuconfig.GPUPrecompilationConfig, outcome string, artifacts []string, err error) *buildStatus {
+	status := &buildStatus{
+		ConfigID:      path.Base(strings.TrimSuffix(config.ConfigDir, "/")),
+		DriverVersion: config.DriverVersion,
+		Milestone:     config.Milestone,
+		Version:       config.Version,
+		VersionType:   config.VersionType,
+		Outcome:       outcome,
+		Timestamp:     timeNow().UTC().Format(time.RFC3339),
+		Artifacts:     artifacts,
+	}
+	if status.Artifacts == nil {
+		status.Artifacts = []string{}
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// statusPath returns the GCS path of the status object for config.
+func statusPath(config gpuconfig.GPUPrecompilationConfig) string {
+	return strings.TrimSuffix(config.ConfigDir, "/") + "/" + statusObject
+}
+
+// alreadyProcessed reports whether the status object of config records a
+// successful build. Configs without a directory or status are never considered processed.
+func alreadyProcessed(ctx context.Context, client *storage.Client, config gpuconfig.GPUPrecompilationConfig) (bool, error) {
+	if config.ConfigDir == "" {
+		return false, nil
+	}
+	if exists, err := gcs.GCSObjectExists(ctx, client, statusPath(config)); err != nil || !exists {
+		return false, err
+	}
+	contents, err := gcs.DownloadGCSObjectString(ctx, client, statusPath(config))
+	if err != nil {
+		return false, err
+	}
+	var status buildStatus
+	if err := json.Unmarshal([]byte(contents), &status); err != nil {
+		return false, err
+	}
+	return status.Outcome == outcomeSucceeded || status.Outcome == outcomeSkipped, nil
+}
+
+// writeStatus uploads the status of processing config to the directory the
+// config was read from. Configs without a directory have no status written.
+func writeStatus(ctx context.Context, client *storage.Client, config gpuconfig.GPUPrecompilationConfig, status *buildStatus) error {
+	if config.ConfigDir == "" {
+		return nil
+	}
+	contents, err := json.MarshalIndent(status, "", "    ")
+	if err != nil {
+		return err
+	}
+	return gcs.UploadGCSObjectString(ctx, client, string(contents), statusPath(config))
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/gpuconfig"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestProcessConfigsWritesStatus(t *testing.T) {
+	gcs := fakes.GCSForTest(t)
+	defer gcs.Close()
+	gcs.Objects["/test-drivers/17802.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run"] = []byte("existing")
+
+	defer func(orig func() time.Time) { timeNow = orig }(timeNow)
+	timeNow = func() time.Time {
+		return time.Date(2022, time.October, 7, 1, 36, 7, 0, time.UTC)
+	}
+	stubBuildPrecompiledDriver(t, map[string]bool{"17801.0.0": true})
+
+	var configs []gpuconfig.GPUPrecompilationConfig
+	for _, c := range []struct{ version, dir string }{
+		{"17800.0.0", "gs://test-configs/config-a/"},
+		{"17801.0.0", "gs://test-configs/config-b/"},
+		{"17802.0.0", "gs://test-configs/config-c"},
+	} {
+		config := testConfig(c.version)
+		config.Milestone = "101"
+		config.ConfigDir = c.dir
+		configs = append(configs, config)
+	}
+	if err := ProcessConfigs(context.Background(), gcs.Client, configs, false, 1, false); err == nil {
+		t.Fatal("ProcessConfigs() succeeded; want error for failing config")
+	}
+
+	for _, tc := range []struct {
+		object string
+		want   buildStatus
+	}{
+		{
+			object: "/test-configs/config-a/status.json",
+			want: buildStatus{
+				ConfigID:      "config-a",
+				DriverVersion: "510.47.03",
+				Milestone:     "101",
+				Version:       "17800.0.0",
+				VersionType:   "Image",
+				Outcome:       outcomeSucceeded,
+				Timestamp:     "2022-10-07T01:36:07Z",
+				Artifacts:     []string{"gs://test-drivers/17800.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run"},
+			},
+		},
+		{
+			object: "/test-configs/config-b/status.json",
+			want: buildStatus{
+				ConfigID:      "config-b",
+				DriverVersion: "510.47.03",
+				Milestone:     "101",
+				Version:       "17801.0.0",
+				VersionType:   "Image",
+				Outcome:       outcomeFailed,
+				Error:         "precompilation failed for: 17801.0.0, driver version 510.47.03: build failed",
+				Timestamp:     "2022-10-07T01:36:07Z",
+				Artifacts:     []string{},
+			},
+		},
+		{
+			object: "/test-configs/config-c/status.json",
+			want: buildStatus{
+				ConfigID:      "config-c",
+				DriverVersion: "510.47.03",
+				Milestone:     "101",
+				Version:       "17802.0.0",
+				VersionType:   "Image",
+				Outcome:       outcomeSkipped,
+				Timestamp:     "2022-10-07T01:36:07Z",
+				Artifacts:     []string{"gs://test-drivers/17802.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run"},
+			},
+		},
+	} {
+		data, ok := gcs.Objects[tc.object]
+		if !ok {
+			t.Errorf("ProcessConfigs() did not write status object %s", tc.object)
+			continue
+		}
+		var got buildStatus
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("status object %s is not valid JSON: %v", tc.object, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("status object %s mismatch (-want +got):\n%s", tc.object, diff)
+		}
+	}
+}
+
+func TestProcessConfigsSkipsProcessed(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		force     bool
+		wantBuilt []string
+	}{
+		{
+			name:      "SkipsSuccessfulStatus",
+			wantBuilt: []string{"17801.0.0", "17802.0.0"},
+		},
+		{
+			name:      "Force",
+			force:     true,
+			wantBuilt: []string{"17800.0.0", "17801.0.0", "17802.0.0", "17803.0.0", "17804.0.0"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gcs := fakes.GCSForTest(t)
+			defer gcs.Close()
+			gcs.Objects["/test-configs/config-a/status.json"] = []byte(`{"outcome": "succeeded"}`)
+			gcs.Objects["/test-configs/config-b/status.json"] = []byte(`{"outcome": "failed"}`)
+			gcs.Objects["/test-configs/config-c/status.json"] = []byte("not json")
+			gcs.Objects["/test-configs/config-e/status.json"] = []byte(`{"outcome": "skipped"}`)
+			gcs.Objects["/test-drivers/17803.0.0/NVIDIA-Linux-x86_64-510.47.03-custom.run"] = []byte("existing")
+
+			built, _ := stubBuildPrecompiledDriver(t, nil)
+
+			var configs []gpuconfig.GPUPrecompilationConfig
+			for _, c := range []struct{ version, dir string }{
+				{"17800.0.0", "gs://test-configs/config-a/"},
+				{"17801.0.0", "gs://test-configs/config-b/"},
+				{"17802.0.0", "gs://test-configs/config-c/"},
+				{"17803.0.0", "gs://test-configs/config-d/"},
+				{"17804.0.0", "gs://test-configs/config-e/"},
+			} {
+				config := testConfig(c.version)
+				config.ConfigDir = c.dir
+				configs = append(configs, config)
+			}
+			if err := ProcessConfigs(context.Background(), gcs.Client, configs, false, 1, tc.force); err != nil {
+				t.Fatalf("ProcessConfigs() failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantBuilt, built(), cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("ProcessConfigs() built configs mismatch (-want +got):\n%s", diff)
+			}
+			if !tc.force {
+				if got := string(gcs.Objects["/test-configs/config-a/status.json"]); got != `{"outcome": "succeeded"}` {
+					t.Errorf("ProcessConfigs() rewrote status of skipped config: %s", got)
+				}
+			}
+		})
+	}
+}
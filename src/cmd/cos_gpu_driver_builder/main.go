@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"time"
 
 	log "github.com/golang/glog"
 
@@ -16,9 +18,16 @@ var (
 	configDir = flag.String("config-dir", "", "Directory containing config.textproto and metadata file that needs to be processed.")
 	bucket    = flag.String("watcher-gcs", "", "GCS bucket to watch for unprocessed configs.")
 	lookBack  = flag.Int("lookBackDays", 7, "read configs produced within the past <lookBack> days.")
+	// since/until select an explicit window for reproducible backfills.
+	since = flag.String("since", "", "read configs produced at or after this RFC3339 time. Cannot be used with lookBackDays.")
+	until = flag.String("until", "", "read configs produced before this RFC3339 time. Requires since.")
 	// default to only building image CI precompiled drivers
 	mode   = flag.String("mode", "image", "image, kernel, or both for processing image CI/kernel CI configs. Works only with watcher-gcs arg")
 	dryRun = flag.Bool("dry-run", false, "invoking the driver builder with -dry-run will not upload any build precompiled outputs")
+	force  = flag.Bool("force", false, "reprocess configs even if their status object records a successful build or their driver was already uploaded.")
+	// each build has its own directory and toolchain environment, so builds
+	// can run in parallel.
+	concurrency = flag.Int("concurrency", 1, "maximum number of configs to build in parallel.")
 )
 
 func main() {
@@ -28,6 +37,18 @@ func main() {
 	if *bucket == "" && *configDir == "" {
 		log.Fatal("empty watcher gcs dir and config file dir")
 	}
+	lookBackSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "lookBackDays" {
+			lookBackSet = true
+		}
+	})
+	if lookBackSet && *since != "" {
+		log.Fatal("-since and -lookBackDays are mutually exclusive")
+	}
+	if *until != "" && *since == "" {
+		log.Fatal("-until requires -since")
+	}
 
 	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
@@ -37,7 +58,11 @@ func main() {
 
 	var configs []gpuconfig.GPUPrecompilationConfig
 	if *bucket != "" { // cos_gpu_driver_builder --watcher-gcs="cos-gpu-configs"
-		configs, err = gpuconfig.ReadConfigs(ctx, client, *bucket, *lookBack, *mode)
+		if *since != "" {
+			configs, err = readConfigsInRange(ctx, client)
+		} else {
+			configs, err = gpuconfig.ReadConfigs(ctx, client, *bucket, *lookBack, *mode)
+		}
 		if err != nil {
 			log.Fatal("could not read configs:", err)
 		}
@@ -49,5 +74,23 @@ func main() {
 		configs = append(configs, config)
 	}
 
-	config.ProcessConfigs(ctx, client, configs, *dryRun)
+	if err := config.ProcessConfigs(ctx, client, configs, *dryRun, *concurrency, *force); err != nil {
+		log.Fatal("failed to process configs:", err)
+	}
+}
+
+// readConfigsInRange reads the configs in the window given by the since and until flags.
+func readConfigsInRange(ctx context.Context, client *storage.Client) ([]gpuconfig.GPUPrecompilationConfig, error) {
+	sinceTime, err := time.Parse(time.RFC3339, *since)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -since: %v", err)
+	}
+	var untilTime time.Time
+	if *until != "" {
+		untilTime, err = time.Parse(time.RFC3339, *until)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -until: %v", err)
+		}
+	}
+	return gpuconfig.ReadConfigsInRange(ctx, client, *bucket, sinceTime, untilTime, *mode)
 }
@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/cos"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+
+	log "github.com/golang/glog"
+	"github.com/google/subcommands"
+	"github.com/pkg/errors"
+)
+
+// DownloadCommand is the subcommand to download a single COS artifact, such
+// as a driver signature or a prebuilt module archive, for debugging.
+type DownloadCommand struct {
+	gcsDownloadBucket string
+	gcsDownloadPrefix string
+	output            string
+	debug             bool
+}
+
+// Name implements subcommands.Command.Name.
+func (*DownloadCommand) Name() string { return "download" }
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*DownloadCommand) Synopsis() string {
+	return "Download an artifact for this version the same way the installer does."
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*DownloadCommand) Usage() string { return "download [-output <filepath>] <artifact>\n" }
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (c *DownloadCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.gcsDownloadBucket, "gcs-download-bucket", "",
+		"The GCS bucket to download COS artifacts from. "+
+			"The default bucket is one of 'cos-tools', 'cos-tools-asia' and 'cos-tools-eu' based on where the VM is running. "+
+			"Those are the public COS artifacts buckets.")
+	f.StringVar(&c.gcsDownloadPrefix, "gcs-download-prefix", "",
+		"The GCS path prefix when downloading COS artifacts."+
+			"If not set then the COS build number and board (e.g. 13310.1041.38/lakitu) will be used.")
+	f.StringVar(&c.output, "output", "",
+		"The local path to write the artifact to. If it is a directory, the artifact is written into it "+
+			"under its own name. Use '-' to write to stdout. If not set, the artifact is written to the current directory.")
+	f.BoolVar(&c.debug, "debug", false,
+		"Enable debug mode.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (c *DownloadCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		log.Errorf("Expected exactly one artifact to download, got %d. Usage: %s", f.NArg(), c.Usage())
+		return subcommands.ExitUsageError
+	}
+	artifact := f.Arg(0)
+	outputPath, err := resolveOutputPath(c.output, artifact)
+	if err != nil {
+		c.logError(err)
+		return subcommands.ExitUsageError
+	}
+	envReader, err := cos.NewEnvReader(hostRootPath)
+	if err != nil {
+		c.logError(errors.Wrap(err, "failed to create envReader"))
+		return subcommands.ExitFailure
+	}
+	log.Infof("Running on COS build id %s", envReader.BuildNumber())
+	downloader := cos.NewGCSDownloader(envReader, c.gcsDownloadBucket, c.gcsDownloadPrefix)
+	log.Infof("Resolved GCS bucket: %s, prefix: %s", downloader.Bucket(), downloader.Prefix())
+	log.Infof("Downloading %s to %s", artifactURL(downloader, artifact), outputPath)
+	if outputPath == "-" {
+		// Keep stdout free of the progress bar when writing the artifact there.
+		utils.SetDownloadProgress(utils.NewProgressLogger())
+	}
+	if err := downloadArtifact(downloader, artifact, outputPath); err != nil {
+		c.logError(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// artifactURL returns the GCS URL the downloader fetches artifact from.
+func artifactURL(downloader *cos.GCSDownloader, artifact string) string {
+	return fmt.Sprintf("gs://%s/%s", downloader.Bucket(), path.Join(downloader.Prefix(), artifact))
+}
+
+// resolveOutputPath returns the local path to write artifact to, given the
+// value of -output. An empty output means the current directory, an output
+// that is an existing directory or ends with a separator gets the artifact's
+// base name appended, and "-" means stdout.
+func resolveOutputPath(output, artifact string) (string, error) {
+	name := path.Base(artifact)
+	if artifact == "" || strings.HasSuffix(artifact, "/") || name == "." || name == ".." {
+		return "", fmt.Errorf("artifact %q does not name a file", artifact)
+	}
+	switch {
+	case output == "-":
+		return output, nil
+	case output == "":
+		return name, nil
+	case strings.HasSuffix(output, string(filepath.Separator)):
+		return filepath.Join(output, name), nil
+	}
+	info, err := os.Stat(output)
+	if err == nil && info.IsDir() {
+		return filepath.Join(output, name), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", errors.Wrapf(err, "failed to check output path %s", output)
+	}
+	return output, nil
+}
+
+// downloadArtifact writes artifact to outputPath, or to stdout if outputPath
+// is "-". A partially written file is removed if the download fails.
+func downloadArtifact(downloader *cos.GCSDownloader, artifact, outputPath string) error {
+	if outputPath == "-" {
+		return downloader.DownloadArtifactTo(os.Stdout, artifact)
+	}
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %s", outputPath)
+	}
+	if err := downloader.DownloadArtifactTo(outputFile, artifact); err != nil {
+		outputFile.Close()
+		os.Remove(outputPath)
+		return err
+	}
+	return outputFile.Close()
+}
+
+func (c *DownloadCommand) logError(err error) {
+	if c.debug {
+		log.Errorf("%+v", err)
+	} else {
+		log.Errorf("%v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/cos"
+)
+
+func TestArtifactURL(t *testing.T) {
+	envReader := &cos.EnvReader{}
+	for _, tc := range []struct {
+		testName  string
+		bucket    string
+		prefix    string
+		artifact  string
+		expectURL string
+	}{
+		{"TopLevel", "cos-tools", "17800.0.0/lakitu", "toolchain_env", "gs://cos-tools/17800.0.0/lakitu/toolchain_env"},
+		{"Extension", "cos-tools-eu", "17800.0.0/lakitu", "extensions/gpu/535.129.03.signature.tar.gz", "gs://cos-tools-eu/17800.0.0/lakitu/extensions/gpu/535.129.03.signature.tar.gz"},
+		{"TrailingSlashPrefix", "my-bucket", "custom/", "nvidia-drivers-535.129.03.tgz", "gs://my-bucket/custom/nvidia-drivers-535.129.03.tgz"},
+	} {
+		downloader := cos.NewGCSDownloader(envReader, tc.bucket, tc.prefix)
+		if got := artifactURL(downloader, tc.artifact); got != tc.expectURL {
+			t.Errorf("%s: expect URL: %q, got: %q", tc.testName, tc.expectURL, got)
+		}
+	}
+}
+
+func TestResolveOutputPath(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	existingFile := filepath.Join(testDir, "existing")
+	if err := ioutil.WriteFile(existingFile, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		testName   string
+		output     string
+		artifact   string
+		expectPath string
+		expectErr  bool
+	}{
+		{"Default", "", "extensions/gpu/gpu_default_version", "gpu_default_version", false},
+		{"Stdout", "-", "toolchain_env", "-", false},
+		{"ExistingDir", testDir, "extensions/gpu/gpu_default_version", filepath.Join(testDir, "gpu_default_version"), false},
+		{"TrailingSeparator", filepath.Join(testDir, "new") + "/", "toolchain_env", filepath.Join(testDir, "new", "toolchain_env"), false},
+		{"ExistingFile", existingFile, "toolchain_env", existingFile, false},
+		{"NewFile", filepath.Join(testDir, "env"), "toolchain_env", filepath.Join(testDir, "env"), false},
+		{"EmptyArtifact", "", "", "", true},
+		{"DirArtifact", "", "extensions/gpu/", "", true},
+		{"DotDotArtifact", "", "extensions/..", "", true},
+	} {
+		got, err := resolveOutputPath(tc.output, tc.artifact)
+		if gotErr := err != nil; gotErr != tc.expectErr {
+			t.Errorf("%s: expect error: %v, got: %v", tc.testName, tc.expectErr, err)
+			continue
+		}
+		if got != tc.expectPath {
+			t.Errorf("%s: expect path: %q, got: %q", tc.testName, tc.expectPath, got)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	stderrors "errors"
 	"fmt"
 	"io/ioutil"
@@ -11,6 +12,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"flag"
@@ -26,10 +28,22 @@ import (
 )
 
 const (
-	grepFound       = 0
-	hostRootPath    = "/root"
-	kernelSrcDir    = "/build/usr/src/linux"
-	toolchainPkgDir = "/build/cos-tools"
+	kernelModuleAuto        = "auto"
+	kernelModuleOpen        = "open"
+	kernelModuleProprietary = "proprietary"
+)
+
+var errLicenseNotAccepted = stderrors.New("the NVIDIA driver license must be accepted with -accept-license to install drivers")
+
+const (
+	grepFound             = 0
+	hostRootPath          = "/root"
+	imaPolicyPath         = "/sys/kernel/security/ima/policy"
+	gpuTypeEnv            = "NVIDIA_GPU_TYPE"
+	installDirModeEnv     = "NVIDIA_INSTALL_DIR_MODE"
+	defaultInstallDirMode = 0755
+	kernelSrcDir          = "/build/usr/src/linux"
+	toolchainPkgDir       = "/build/cos-tools"
 )
 
 type GPUType int
@@ -114,18 +128,30 @@ var fallbackMap = map[GPUType]Fallback{
 type InstallCommand struct {
 	driverVersion          string
 	hostInstallDir         string
+	installDirMode         string
 	unsignedDriver         bool
+	unsignedFirmware       bool
 	gcsDownloadBucket      string
 	gcsDownloadPrefix      string
 	nvidiaInstallerURL     string
+	nvidiaInstallerFile    string
 	signatureURL           string
 	debug                  bool
 	test                   bool
 	prepareBuildTools      bool
 	kernelOpen             bool
+	kernelModuleType       string
+	gpuType                string
+	dumpConfig             string
+	dumpConfigOnly         bool
 	noVerify               bool
+	selfTest               bool
+	clean                  bool
 	kernelModuleParams     modules.ModuleParameters
+	moduleParamsFile       string
 	nvidiaInstallerURLOpen string
+	acceptLicense          bool
+	acceptLicenseSet       bool
 }
 
 // Name implements subcommands.Command.Name.
@@ -149,10 +175,18 @@ func (c *InstallCommand) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.hostInstallDir, "host-dir", "",
 		"Host directory that GPU drivers should be installed to. "+
 			"It tries to read from the env NVIDIA_INSTALL_DIR_HOST if the flag is not set explicitly.")
+	f.StringVar(&c.installDirMode, "install-dir-mode", "",
+		"Octal permission mode of the directories created for the GPU driver installation, e.g. 0750. "+
+			"The owner must have full access and the directories must not be world-writable. "+
+			"It tries to read from the env "+installDirModeEnv+" if the flag is not set explicitly. The default mode is 0755.")
 	f.BoolVar(&c.unsignedDriver, "allow-unsigned-driver", false,
 		"Whether to allow load unsigned GPU drivers. "+
 			"If this flag is set to true, module signing security features must be disabled on the host for driver installation to succeed. "+
 			"This flag is only for debugging and testing.")
+	f.BoolVar(&c.unsignedFirmware, "force-unsigned-firmware", false,
+		"Whether to install GSP firmware without its signature, independently of `-allow-unsigned-driver`. "+
+			"If this flag is set to true, the host IMA policy must not appraise firmware for driver installation to succeed. "+
+			"This flag is only for debugging and testing.")
 	f.StringVar(&c.gcsDownloadBucket, "gcs-download-bucket", "",
 		"The GCS bucket to download COS artifacts from. "+
 			"The default bucket is one of 'cos-tools', 'cos-tools-asia' and 'cos-tools-eu' based on where the VM is running. "+
@@ -163,6 +197,10 @@ func (c *InstallCommand) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.nvidiaInstallerURL, "nvidia-installer-url", "",
 		"A URL to an nvidia-installer to use for driver installation. This flag is mutually exclusive with `-version`. "+
 			"This flag must be used with `-allow-unsigned-driver`. This flag is only for debugging and testing.")
+	f.StringVar(&c.nvidiaInstallerFile, "nvidia-installer-file", "",
+		"A path to a local nvidia-installer to use for driver installation instead of downloading one. "+
+			"This flag is mutually exclusive with `-nvidia-installer-url` and `-version`. "+
+			"This flag must be used with `-allow-unsigned-driver`. This flag is only for debugging and testing.")
 	f.StringVar(&c.signatureURL, "signature-url", "",
 		"A URL to the driver signature. This flag can only be used together with `-test` and `-nvidia-installer-url` for for debugging and testing.")
 	f.StringVar(&c.nvidiaInstallerURLOpen, "nvidia-installer-url-open", "", "This can be used to specify the location of the GSP firmware and user-space NVIDIA GPU driver components from a corresponding driver release of the OSS kernel modules. This flag is only for debugging and testing.")
@@ -173,8 +211,28 @@ func (c *InstallCommand) SetFlags(f *flag.FlagSet) {
 			"In test mode, `-nvidia-installer-url` can be used without `-allow-unsigned-driver`.")
 	f.BoolVar(&c.prepareBuildTools, "prepare-build-tools", false, "Whether to populate the build tools cache, i.e. to download and install the toolchain and the kernel headers. Drivers are NOT installed when this flag is set and running with this flag does not require GPU attached to the instance.")
 	f.BoolVar(&c.noVerify, "no-verify", false, "Skip kernel module loading and installation verification. Useful for preloading drivers without attached GPU.")
+	f.StringVar(&c.kernelModuleType, "kernel-module-type", kernelModuleAuto,
+		"The type of GPU kernel modules to install: 'auto', 'open' or 'proprietary'. "+
+			"'auto' installs open kernel modules if the GPU supports them and proprietary ones otherwise. "+
+			"Forcing a type the GPU doesn't support is only for debugging and testing.")
+	f.StringVar(&c.gpuType, "gpu-type", "",
+		"Override GPU type detection with one of 'K80', 'P4', 'P100', 'V100', 'L4', 'H100', 'Others' or 'none'. "+
+			"It tries to read from the env "+gpuTypeEnv+" if the flag is not set explicitly. This flag is only for debugging and testing.")
+	f.StringVar(&c.dumpConfig, "dump-config", "",
+		"Write the resolved installation configuration as JSON to this file, or to stdout if set to '-'. "+
+			"The configuration is written after flag validation and driver version resolution.")
+	f.BoolVar(&c.dumpConfigOnly, "dump-config-only", false,
+		"Exit after writing the configuration requested with `-dump-config` instead of installing drivers.")
+	f.BoolVar(&c.clean, "clean", false, "Remove any previous installation from the host directory, including leftovers of a partial or failed installation, before installing.")
+	f.BoolVar(&c.selfTest, "self-test", false, "After installation, run a minimal compute workload that allocates GPU memory to verify the driver is usable. Requires an attached GPU.")
+	f.BoolVar(&c.acceptLicense, "accept-license", true,
+		"Accept the NVIDIA driver license. The acceptance is logged along with the driver version for audit trails. "+
+			"Drivers are not installed if this flag is set to false.")
 	c.kernelModuleParams = modules.NewModuleParameters()
 	f.Var(&c.kernelModuleParams, "module-arg", "Kernel module parameters can be specified using this flag. These parameters are used while loading the specific kernel mode drivers into the kernel. Usage: -module-arg <module-x>.<parameter-y>=<value> -module-arg <module-y>.<parameter-z>=<value> ..    For eg: –module-arg nvidia_uvm.uvm_debug_prints=1 –module-arg nvidia.NVreg_EnableGpuFirmware=0.")
+	f.StringVar(&c.moduleParamsFile, "module-params-file", "",
+		"A file with kernel module parameters to use while loading the GPU drivers, with one <module-x>.<parameter-y>=<value> per line. "+
+			"Empty lines and lines starting with '#' are ignored. The parameters are used in addition to those set with `-module-arg`.")
 }
 
 func (c *InstallCommand) validateFlags() error {
@@ -184,21 +242,106 @@ func (c *InstallCommand) validateFlags() error {
 	if c.nvidiaInstallerURL != "" && c.unsignedDriver == false && c.test == false {
 		return stderrors.New("-nvidia-installer-url is set, and -allow-unsigned-driver is not; -nvidia-installer-url must be used with -allow-unsigned-driver if not in test mode")
 	}
-	if c.signatureURL != "" && (c.nvidiaInstallerURL == "" || c.test == false) {
-		return stderrors.New("-signature-url must be used with -nvidia-installer-url and -test")
+	if c.nvidiaInstallerFile != "" {
+		if c.nvidiaInstallerURL != "" {
+			return stderrors.New("-nvidia-installer-file and -nvidia-installer-url are both set; these flags are mutually exclusive")
+		}
+		if c.driverVersion != "" {
+			return stderrors.New("-nvidia-installer-file and -version are both set; these flags are mutually exclusive")
+		}
+		if c.unsignedDriver == false && c.test == false {
+			return stderrors.New("-nvidia-installer-file is set, and -allow-unsigned-driver is not; -nvidia-installer-file must be used with -allow-unsigned-driver if not in test mode")
+		}
+		if _, err := installer.LocalInstaller(c.nvidiaInstallerFile); err != nil {
+			return fmt.Errorf("invalid -nvidia-installer-file: %v", err)
+		}
+	}
+	if c.signatureURL != "" && ((c.nvidiaInstallerURL == "" && c.nvidiaInstallerFile == "") || c.test == false) {
+		return stderrors.New("-signature-url must be used with -nvidia-installer-url or -nvidia-installer-file, and -test")
 	}
 	if c.nvidiaInstallerURLOpen != "" && (c.driverVersion == "" || c.test == false) {
 		return stderrors.New("-nvidia-installer-url-open must be used with -test and -version")
 	}
+	switch c.kernelModuleType {
+	case kernelModuleAuto, kernelModuleOpen, kernelModuleProprietary:
+	default:
+		return fmt.Errorf("-kernel-module-type must be one of %q, %q or %q, got %q", kernelModuleAuto, kernelModuleOpen, kernelModuleProprietary, c.kernelModuleType)
+	}
+	if c.dumpConfigOnly && c.dumpConfig == "" {
+		return stderrors.New("-dump-config-only must be used with -dump-config")
+	}
+	if c.dumpConfigOnly && c.clean {
+		return stderrors.New("-dump-config-only and -clean are both set; these flags are mutually exclusive")
+	}
+	if c.selfTest && (c.noVerify || c.prepareBuildTools) {
+		return stderrors.New("-self-test requires an attached GPU and cannot be used with -no-verify or -prepare-build-tools")
+	}
+	// Populating the build tools cache doesn't install drivers, so it doesn't
+	// require accepting the license.
+	if !c.acceptLicense && !c.prepareBuildTools {
+		return errLicenseNotAccepted
+	}
+	if _, err := c.getInstallDirMode(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// getInstallDirMode returns the permission mode of the directories created for
+// the driver installation, from the -install-dir-mode flag or the
+// NVIDIA_INSTALL_DIR_MODE environment variable if the flag is not set.
+func (c *InstallCommand) getInstallDirMode() (os.FileMode, error) {
+	value := c.installDirMode
+	if value == "" {
+		value = os.Getenv(installDirModeEnv)
+	}
+	if value == "" {
+		return defaultInstallDirMode, nil
+	}
+	mode, err := parseInstallDirMode(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid -install-dir-mode %q: %v", value, err)
+	}
+	return mode, nil
+}
+
+// parseInstallDirMode parses an octal directory permission mode. The owner
+// must have full access to the installation directories, and they must not be
+// world-writable or have special mode bits.
+func parseInstallDirMode(value string) (os.FileMode, error) {
+	bits, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, stderrors.New("not an octal number")
+	}
+	mode := os.FileMode(bits)
+	switch {
+	case mode&^os.ModePerm != 0:
+		return 0, stderrors.New("only permission bits (0777) are allowed")
+	case mode&0700 != 0700:
+		return 0, stderrors.New("the owner must have read, write and execute permission")
+	case mode&0002 != 0:
+		return 0, stderrors.New("world-writable directories are not allowed")
+	}
+	return mode, nil
+}
+
 // Execute implements subcommands.Command.Execute.
-func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+func (c *InstallCommand) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	f.Visit(func(f *flag.Flag) {
+		if f.Name == "accept-license" {
+			c.acceptLicenseSet = true
+		}
+	})
 	if err := c.validateFlags(); err != nil {
 		c.logError(err)
 		return subcommands.ExitFailure
 	}
+	if c.moduleParamsFile != "" {
+		if err := c.kernelModuleParams.SetFromFile(c.moduleParamsFile); err != nil {
+			c.logError(errors.Wrap(err, "invalid -module-params-file"))
+			return subcommands.ExitFailure
+		}
+	}
 	envReader, err := cos.NewEnvReader(hostRootPath)
 	if err != nil {
 		c.logError(errors.Wrapf(err, "failed to create envReader with host root path %s", hostRootPath))
@@ -215,8 +358,9 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 
 	// All prerelease builds are in dev-channel. For testing we don't need to check release track.
 	// we can preload dependencies for dev-channel images too.
-	if releaseTrack := envReader.ReleaseTrack(); !c.prepareBuildTools && !c.test && releaseTrack == "dev-channel" {
-		c.logError(fmt.Errorf("GPU installation is not supported on dev images for now; Please use LTS image."))
+	// Only released channels are supported, so unrecognized release tracks are rejected as well.
+	if channel := envReader.Channel(); !c.prepareBuildTools && !c.test && !isSupportedChannel(channel) {
+		c.logError(fmt.Errorf("GPU installation is not supported on %s images (release track %q) for now; Please use LTS image.", channel, envReader.ReleaseTrack()))
 		return subcommands.ExitFailure
 	}
 
@@ -232,7 +376,9 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 	}
 
 	downloader := cos.NewGCSDownloader(envReader, c.gcsDownloadBucket, c.gcsDownloadPrefix)
-	if c.nvidiaInstallerURL == "" {
+	if c.nvidiaInstallerFile != "" {
+		log.Infof("Installing GPU driver from local file %q", c.nvidiaInstallerFile)
+	} else if c.nvidiaInstallerURL == "" {
 		versionInput := c.driverVersion
 		c.driverVersion, err = getDriverVersion(downloader, c.driverVersion)
 		if err != nil {
@@ -256,6 +402,21 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 		if cos.CheckKernelModuleSigning(string(kernelCmdline)) {
 			log.Warning("Current kernel command line does not support unsigned kernel modules. Not enforcing kernel module signing may cause installation fail.")
 		}
+		if sigForce, err := envReader.KernelConfig("CONFIG_MODULE_SIG_FORCE"); err != nil {
+			log.V(2).Infof("Unable to read kernel config: %v", err)
+		} else if sigForce == "y" {
+			log.Warning("Current kernel is built with CONFIG_MODULE_SIG_FORCE=y. Unsigned kernel modules will fail to load.")
+		}
+	}
+
+	if c.unsignedFirmware {
+		imaPolicy, err := ioutil.ReadFile(imaPolicyPath)
+		if err != nil {
+			log.Warningf("Unable to read IMA policy, not validating -force-unsigned-firmware: %v", err)
+		} else if cos.CheckFirmwareSigning(string(imaPolicy)) {
+			c.logError(stderrors.New("-force-unsigned-firmware is set, but the kernel IMA policy appraises firmware; unsigned GSP firmware would fail to load"))
+			return subcommands.ExitFailure
+		}
 	}
 
 	// Read value from env NVIDIA_INSTALL_DIR_HOST if the flag is not set. This is to be compatible with old interface.
@@ -263,43 +424,85 @@ func (c *InstallCommand) Execute(ctx context.Context, _ *flag.FlagSet, _ ...inte
 		c.hostInstallDir = os.Getenv("NVIDIA_INSTALL_DIR_HOST")
 	}
 	hostInstallDir := filepath.Join(hostRootPath, c.hostInstallDir)
+	installDirMode, err := c.getInstallDirMode()
+	if err != nil {
+		c.logError(err)
+		return subcommands.ExitFailure
+	}
+	installer.SetInstallDirMode(installDirMode)
 
-	var cacher *installer.Cacher
-	// We only want to cache drivers installed from official sources.
-	if c.nvidiaInstallerURL == "" && c.nvidiaInstallerURLOpen == "" {
-		cacher = installer.NewCacher(hostInstallDir, envReader.BuildNumber(), c.driverVersion)
-		if isCached, isOpen, err := cacher.IsCached(); isCached && err == nil {
-			log.V(2).Info("Found cached version, NOT building the drivers.")
-			if err := installer.ConfigureCachedInstallation(hostInstallDir, !c.unsignedDriver, c.test, isOpen, c.noVerify, c.kernelModuleParams); err != nil {
-				c.logError(errors.Wrap(err, "failed to configure cached installation"))
-				return subcommands.ExitFailure
-			}
-			if err := installer.VerifyDriverInstallation(c.noVerify); err != nil {
-				c.logError(errors.Wrap(err, "failed to verify GPU driver installation"))
+	if !c.prepareBuildTools {
+		if c.clean {
+			if err := installer.CheckCleanDir(hostRootPath, hostInstallDir); err != nil {
+				c.logError(err)
 				return subcommands.ExitFailure
 			}
-			if err := modules.UpdateHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
-				c.logError(errors.Wrap(err, "failed to update host ld cache"))
+			if err := installer.CleanPriorInstallation(hostInstallDir); err != nil {
+				c.logError(errors.Wrap(err, "failed to clean up prior installation"))
 				return subcommands.ExitFailure
 			}
-			return subcommands.ExitSuccess
+		} else if issues, err := installer.CheckPriorInstallation(hostInstallDir); err != nil {
+			log.Warningf("Unable to check for a prior installation: %v", err)
+		} else if len(issues) > 0 {
+			log.Warningf("Found leftovers of a partial or failed previous installation; rerun with -clean to reset:\n  %s", strings.Join(issues, "\n  "))
 		}
 	}
 
-	log.V(2).Info("Did not find cached version, installing the drivers...")
+	var cacher *installer.Cacher
+	var isCached, isOpen bool
+	// We only want to cache drivers installed from official sources.
+	if c.nvidiaInstallerURL == "" && c.nvidiaInstallerURLOpen == "" && c.nvidiaInstallerFile == "" {
+		cacher = installer.NewCacher(hostInstallDir, envReader.BuildNumber(), c.driverVersion)
+		if isCached, isOpen, err = cacher.IsCached(); err != nil {
+			isCached = false
+		}
+	}
 
-	// install OSS kernel modules (if available) if device supports
-	if !c.unsignedDriver && gpuType.OpenSupported() {
-		c.kernelOpen = gpuType.OpenSupported()
+	c.kernelOpen = selectKernelOpen(c.kernelModuleType, gpuType, c.unsignedDriver)
+
+	var prebuiltModulesAvailable bool
+	if !isCached {
+		prebuiltModulesAvailable, err = installer.PrebuiltModulesAvailable(downloader, c.driverVersion, c.kernelOpen)
+		if err != nil {
+			c.logError(errors.Wrap(err, "failed to find prebuilt modules"))
+			return subcommands.ExitFailure
+		}
 	}
 
-	prebuiltModulesAvailable, err := installer.PrebuiltModulesAvailable(downloader, c.driverVersion, c.kernelOpen)
+	if c.dumpConfig != "" {
+		config := c.resolvedConfig(envReader, downloader, gpuType, isCached, prebuiltModulesAvailable)
+		if err := writeResolvedConfig(config, c.dumpConfig); err != nil {
+			c.logError(errors.Wrap(err, "failed to dump resolved configuration"))
+			return subcommands.ExitFailure
+		}
+		if c.dumpConfigOnly {
+			return subcommands.ExitSuccess
+		}
+	}
 
-	if err != nil {
-		c.logError(errors.Wrap(err, "failed to find prebuilt modules"))
-		return subcommands.ExitFailure
+	if !c.prepareBuildTools {
+		c.recordLicenseAcceptance()
+	}
+
+	if isCached {
+		log.V(2).Info("Found cached version, NOT building the drivers.")
+		if err := installer.ConfigureCachedInstallation(hostInstallDir, !c.unsignedDriver, c.test, isOpen, c.noVerify, c.kernelModuleParams); err != nil {
+			c.logError(errors.Wrap(err, "failed to configure cached installation"))
+			return subcommands.ExitFailure
+		}
+		if err := installer.VerifyDriverInstallation(c.noVerify, c.selfTest); err != nil {
+			c.logError(errors.Wrap(err, "failed to verify GPU driver installation"))
+			return subcommands.ExitFailure
+		}
+		if err := modules.UpdateHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
+			c.logError(errors.Wrap(err, "failed to update host ld cache"))
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
 	}
 
+	log.V(2).Info("Did not find cached version, installing the drivers...")
+
 	// skip prebuilt module installation if preparing build tools
 	if !c.prepareBuildTools && prebuiltModulesAvailable {
 		log.V(2).Info("Found prebuilt kernel modules, installing additional components...")
@@ -328,6 +531,37 @@ func getDriverVersion(downloader *cos.GCSDownloader, argVersion string) (string,
 	return argVersion, nil
 }
 
+// selectKernelOpen decides whether to install open kernel modules for gpuType.
+// An explicit moduleType overrides the automatic choice, with a warning if the
+// GPU doesn't support open kernel modules.
+func selectKernelOpen(moduleType string, gpuType GPUType, unsignedDriver bool) bool {
+	switch moduleType {
+	case kernelModuleOpen:
+		if !gpuType.OpenSupported() {
+			log.Warningf("Installing open kernel modules on %s GPU, which doesn't support them.", gpuType)
+		}
+		return true
+	case kernelModuleProprietary:
+		if gpuType.OpenSupported() {
+			log.Infof("Installing proprietary kernel modules on %s GPU, which supports open kernel modules.", gpuType)
+		}
+		return false
+	default:
+		// install OSS kernel modules (if available) if device supports
+		return !unsignedDriver && gpuType.OpenSupported()
+	}
+}
+
+// isSupportedChannel reports whether GPU drivers can be installed on images of channel.
+func isSupportedChannel(channel cos.Channel) bool {
+	switch channel {
+	case cos.ChannelBeta, cos.ChannelStable, cos.ChannelLTS:
+		return true
+	default:
+		return false
+	}
+}
+
 func remountExecutable(dir string) error {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create dir %q: %v", dir, err)
@@ -364,7 +598,11 @@ func installDriver(c *InstallCommand, cacher *installer.Cacher, envReader *cos.E
 	}
 
 	var installerFile string
-	if c.nvidiaInstallerURL == "" {
+	if c.nvidiaInstallerFile != "" {
+		if installerFile, err = installer.LocalInstaller(c.nvidiaInstallerFile); err != nil {
+			return errors.Wrap(err, "failed to use local GPU driver installer")
+		}
+	} else if c.nvidiaInstallerURL == "" {
 		installerFile, err = installer.DownloadDriverInstallerV2(downloader, c.driverVersion)
 		if err != nil {
 			return errors.Wrap(err, "failed to download GPU driver installer")
@@ -388,11 +626,11 @@ func installDriver(c *InstallCommand, cacher *installer.Cacher, envReader *cos.E
 		}
 	}
 
-	if err := installer.RunDriverInstaller(toolchainPkgDir, installerFile, c.driverVersion, !c.unsignedDriver, c.test, false, c.noVerify, c.kernelModuleParams); err != nil {
+	if err := installer.RunDriverInstaller(toolchainPkgDir, installerFile, c.driverVersion, !c.unsignedDriver, c.unsignedFirmware, c.test, false, c.noVerify, c.kernelModuleParams); err != nil {
 		if errors.Is(err, installer.ErrDriverLoad) {
 			// Drivers were linked, but couldn't load; try again with legacy linking
 			log.Infof("Failed to load kernel module, err: %v. Retrying driver installation with legacy linking", err)
-			if err := installer.RunDriverInstaller(toolchainPkgDir, installerFile, c.driverVersion, !c.unsignedDriver, c.test, true, c.noVerify, c.kernelModuleParams); err != nil {
+			if err := installer.RunDriverInstaller(toolchainPkgDir, installerFile, c.driverVersion, !c.unsignedDriver, c.unsignedFirmware, c.test, true, c.noVerify, c.kernelModuleParams); err != nil {
 				return fmt.Errorf("failed to run GPU driver installer: %v", err)
 			}
 		} else {
@@ -404,7 +642,7 @@ func installDriver(c *InstallCommand, cacher *installer.Cacher, envReader *cos.E
 			return errors.Wrap(err, "failed to cache installation")
 		}
 	}
-	if err := installer.VerifyDriverInstallation(c.noVerify); err != nil {
+	if err := installer.VerifyDriverInstallation(c.noVerify, c.selfTest); err != nil {
 		return errors.Wrap(err, "failed to verify installation")
 	}
 	if err := modules.UpdateHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
@@ -440,7 +678,7 @@ func installDriverPrebuiltModules(c *InstallCommand, cacher *installer.Cacher, e
 			return errors.Wrap(err, "failed to cache installation")
 		}
 	}
-	if err := installer.VerifyDriverInstallation(c.noVerify); err != nil {
+	if err := installer.VerifyDriverInstallation(c.noVerify, c.selfTest); err != nil {
 		return errors.Wrap(err, "failed to verify installation")
 	}
 	if err := modules.UpdateHostLdCache(hostRootPath, filepath.Join(c.hostInstallDir, "lib64")); err != nil {
@@ -450,6 +688,97 @@ func installDriverPrebuiltModules(c *InstallCommand, cacher *installer.Cacher, e
 	return nil
 }
 
+// resolvedConfig is the effective configuration of an installation, after flag
+// validation and driver version resolution.
+type resolvedConfig struct {
+	DriverVersion          string              `json:"driver_version"`
+	BuildNumber            string              `json:"build_number"`
+	KernelRelease          string              `json:"kernel_release"`
+	GPUType                string              `json:"gpu_type"`
+	HostInstallDir         string              `json:"host_install_dir"`
+	InstallDirMode         string              `json:"install_dir_mode"`
+	SignedModules          bool                `json:"signed_modules"`
+	SignedFirmware         bool                `json:"signed_firmware"`
+	KernelModuleType       string              `json:"kernel_module_type"`
+	KernelOpen             bool                `json:"kernel_open"`
+	Cached                 bool                `json:"cached"`
+	PrebuiltModules        bool                `json:"prebuilt_modules"`
+	GCSDownloadBucket      string              `json:"gcs_download_bucket"`
+	GCSDownloadPrefix      string              `json:"gcs_download_prefix"`
+	NvidiaInstallerURL     string              `json:"nvidia_installer_url,omitempty"`
+	NvidiaInstallerURLOpen string              `json:"nvidia_installer_url_open,omitempty"`
+	NvidiaInstallerFile    string              `json:"nvidia_installer_file,omitempty"`
+	SignatureURL           string              `json:"signature_url,omitempty"`
+	ModuleParameters       map[string][]string `json:"module_parameters,omitempty"`
+	Test                   bool                `json:"test"`
+	NoVerify               bool                `json:"no_verify"`
+	PrepareBuildTools      bool                `json:"prepare_build_tools"`
+	LicenseAccepted        bool                `json:"license_accepted"`
+}
+
+// resolvedConfig assembles the effective configuration from the resolved InstallCommand fields.
+func (c *InstallCommand) resolvedConfig(envReader *cos.EnvReader, downloader *cos.GCSDownloader, gpuType GPUType, cached, prebuiltModules bool) resolvedConfig {
+	// The mode is checked by validateFlags.
+	installDirMode, _ := c.getInstallDirMode()
+	return resolvedConfig{
+		DriverVersion:          c.driverVersion,
+		BuildNumber:            envReader.BuildNumber(),
+		KernelRelease:          envReader.KernelRelease(),
+		GPUType:                gpuType.String(),
+		HostInstallDir:         c.hostInstallDir,
+		InstallDirMode:         fmt.Sprintf("%04o", installDirMode),
+		SignedModules:          !c.unsignedDriver,
+		SignedFirmware:         !c.unsignedFirmware,
+		KernelModuleType:       c.kernelModuleType,
+		KernelOpen:             c.kernelOpen,
+		Cached:                 cached,
+		PrebuiltModules:        prebuiltModules,
+		GCSDownloadBucket:      downloader.Bucket(),
+		GCSDownloadPrefix:      downloader.Prefix(),
+		NvidiaInstallerURL:     c.nvidiaInstallerURL,
+		NvidiaInstallerURLOpen: c.nvidiaInstallerURLOpen,
+		NvidiaInstallerFile:    c.nvidiaInstallerFile,
+		SignatureURL:           c.signatureURL,
+		ModuleParameters:       c.kernelModuleParams,
+		Test:                   c.test,
+		NoVerify:               c.noVerify,
+		PrepareBuildTools:      c.prepareBuildTools,
+		LicenseAccepted:        c.acceptLicense,
+	}
+}
+
+// writeResolvedConfig writes config as JSON to path, or to stdout if path is "-".
+func writeResolvedConfig(config resolvedConfig, path string) error {
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if path == "-" {
+		_, err := os.Stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
+
+// recordLicenseAcceptance logs that the NVIDIA driver license was accepted,
+// along with the driver that is installed and whether -accept-license was set
+// explicitly or the license was accepted by default.
+func (c *InstallCommand) recordLicenseAcceptance() {
+	driver := c.driverVersion
+	switch {
+	case c.nvidiaInstallerFile != "":
+		driver = c.nvidiaInstallerFile
+	case c.nvidiaInstallerURL != "":
+		driver = c.nvidiaInstallerURL
+	}
+	if c.acceptLicenseSet {
+		auditf("NVIDIA driver license accepted with -accept-license for driver %s", driver)
+	} else {
+		auditf("NVIDIA driver license accepted by default, -accept-license not set, for driver %s", driver)
+	}
+}
+
 func (c *InstallCommand) logError(err error) {
 	if c.debug {
 		log.Errorf("%+v", err)
@@ -458,9 +787,58 @@ func (c *InstallCommand) logError(err error) {
 	}
 }
 
-func (c *InstallCommand) getGPUTypeInfo() (GPUType, error) {
+// auditf logs events kept for audit trails. It is a variable so tests can
+// capture them.
+var auditf = log.Infof
+
+// lspciNvidia lists the NVIDIA PCI devices. It is a variable so tests can stub out lspci.
+var lspciNvidia = func() ([]byte, error) {
 	cmd := "lspci | grep -i \"nvidia\""
-	outBytes, err := exec.Command("/bin/bash", "-c", cmd).Output()
+	return exec.Command("/bin/bash", "-c", cmd).Output()
+}
+
+// detectedGPU caches the GPU type detection result, which doesn't change for
+// the lifetime of the process.
+var detectedGPU struct {
+	once    sync.Once
+	gpuType GPUType
+	err     error
+}
+
+// getGPUTypeInfo returns the type of the attached GPU. The -gpu-type flag, or
+// the NVIDIA_GPU_TYPE environment variable if the flag is not set, overrides
+// detection so drivers can be tested without GPU hardware.
+func (c *InstallCommand) getGPUTypeInfo() (GPUType, error) {
+	override := c.gpuType
+	if override == "" {
+		override = os.Getenv(gpuTypeEnv)
+	}
+	if override != "" {
+		return parseGPUType(override)
+	}
+	detectedGPU.once.Do(func() {
+		detectedGPU.gpuType, detectedGPU.err = detectGPUType()
+	})
+	return detectedGPU.gpuType, detectedGPU.err
+}
+
+// parseGPUType parses a GPU type name as printed by GPUType.String, or "none"
+// for no GPU.
+func parseGPUType(name string) (GPUType, error) {
+	if strings.EqualFold(name, "none") {
+		return NO_GPU, nil
+	}
+	for _, g := range []GPUType{K80, P4, P100, V100, L4, H100, Others} {
+		if strings.EqualFold(name, g.String()) {
+			return g, nil
+		}
+	}
+	return NO_GPU, fmt.Errorf("unknown GPU type %q", name)
+}
+
+// detectGPUType runs lspci to find the type of the attached GPU.
+func detectGPUType() (GPUType, error) {
+	outBytes, err := lspciNvidia()
 	if err != nil {
 		return NO_GPU, err
 	}
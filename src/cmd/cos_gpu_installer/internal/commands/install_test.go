@@ -0,0 +1,329 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/cos"
+	"cos.googlesource.com/cos/tools.git/src/pkg/modules"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSelectKernelOpen(t *testing.T) {
+	for _, tc := range []struct {
+		testName       string
+		moduleType     string
+		gpuType        GPUType
+		unsignedDriver bool
+		expectOpen     bool
+	}{
+		{"AutoOpenSupported", kernelModuleAuto, L4, false, true},
+		{"AutoOpenUnsupported", kernelModuleAuto, P100, false, false},
+		{"AutoUnsignedDriver", kernelModuleAuto, L4, true, false},
+		{"OpenSupported", kernelModuleOpen, H100, false, true},
+		{"OpenUnsupported", kernelModuleOpen, V100, false, true},
+		{"OpenUnsignedDriver", kernelModuleOpen, L4, true, true},
+		{"ProprietaryOpenSupported", kernelModuleProprietary, L4, false, false},
+		{"ProprietaryOpenUnsupported", kernelModuleProprietary, K80, false, false},
+	} {
+		if got := selectKernelOpen(tc.moduleType, tc.gpuType, tc.unsignedDriver); got != tc.expectOpen {
+			t.Errorf("%s: expect open kernel modules: %v, got: %v", tc.testName, tc.expectOpen, got)
+		}
+	}
+}
+
+func TestValidateKernelModuleType(t *testing.T) {
+	for _, tc := range []struct {
+		moduleType string
+		expectErr  bool
+	}{
+		{kernelModuleAuto, false},
+		{kernelModuleOpen, false},
+		{kernelModuleProprietary, false},
+		{"closed", true},
+		{"", true},
+	} {
+		c := &InstallCommand{kernelModuleType: tc.moduleType, acceptLicense: true}
+		if err := c.validateFlags(); (err != nil) != tc.expectErr {
+			t.Errorf("validateFlags() with -kernel-module-type=%q: expect error: %v, got: %v", tc.moduleType, tc.expectErr, err)
+		}
+	}
+}
+
+func TestGetGPUTypeInfoCachesDetection(t *testing.T) {
+	origLspciNvidia := lspciNvidia
+	defer func() {
+		lspciNvidia = origLspciNvidia
+		detectedGPU.once = sync.Once{}
+	}()
+	detectedGPU.once = sync.Once{}
+
+	calls := 0
+	lspciNvidia = func() ([]byte, error) {
+		calls++
+		return []byte("00:04.0 3D controller: NVIDIA Corporation AD104GL [L4] (rev a1)\n"), nil
+	}
+	os.Unsetenv(gpuTypeEnv)
+	c := &InstallCommand{}
+	for i := 0; i < 3; i++ {
+		gpuType, err := c.getGPUTypeInfo()
+		if err != nil {
+			t.Fatalf("getGPUTypeInfo() failed: %v", err)
+		}
+		if gpuType != L4 {
+			t.Errorf("getGPUTypeInfo() = %v, want: %v", gpuType, L4)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("GPU type detection ran %d times, want: 1", calls)
+	}
+}
+
+func TestGetGPUTypeInfoOverride(t *testing.T) {
+	origLspciNvidia := lspciNvidia
+	defer func() { lspciNvidia = origLspciNvidia }()
+	lspciNvidia = func() ([]byte, error) {
+		t.Fatal("GPU type detection ran despite override")
+		return nil, nil
+	}
+	defer os.Unsetenv(gpuTypeEnv)
+
+	for _, tc := range []struct {
+		testName   string
+		flag       string
+		env        string
+		expectType GPUType
+		expectErr  bool
+	}{
+		{"Flag", "H100", "", H100, false},
+		{"FlagCaseInsensitive", "v100", "", V100, false},
+		{"Env", "", "P4", P4, false},
+		{"FlagOverridesEnv", "K80", "L4", K80, false},
+		{"NoGPU", "none", "", NO_GPU, false},
+		{"Unknown", "A100X", "", NO_GPU, true},
+	} {
+		os.Setenv(gpuTypeEnv, tc.env)
+		c := &InstallCommand{gpuType: tc.flag}
+		gpuType, err := c.getGPUTypeInfo()
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%s: expect error: %v, got: %v", tc.testName, tc.expectErr, err)
+		}
+		if gpuType != tc.expectType {
+			t.Errorf("%s: expect GPU type: %v, got: %v", tc.testName, tc.expectType, gpuType)
+		}
+	}
+}
+
+func TestWriteResolvedConfig(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	for path, content := range map[string]string{
+		"etc/os-release":  "BUILD_ID=17800.66.78\nVERSION=109\n",
+		"etc/lsb-release": "CHROMEOS_RELEASE_BOARD=lakitu\nCHROMEOS_RELEASE_TRACK=lts-channel\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(testDir, filepath.Dir(path)), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(testDir, path), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	envReader, err := cos.NewEnvReader(testDir)
+	if err != nil {
+		t.Fatalf("Failed to create EnvReader: %v", err)
+	}
+	downloader := cos.NewGCSDownloader(envReader, "cos-tools-test", "")
+
+	moduleParams := modules.NewModuleParameters()
+	if err := moduleParams.Set("nvidia_uvm.uvm_debug_prints=1"); err != nil {
+		t.Fatalf("Failed to set module parameters: %v", err)
+	}
+	c := &InstallCommand{
+		driverVersion:      "535.104.12",
+		hostInstallDir:     "/var/lib/nvidia",
+		installDirMode:     "0750",
+		unsignedFirmware:   true,
+		kernelModuleType:   kernelModuleAuto,
+		kernelOpen:         true,
+		kernelModuleParams: moduleParams,
+		acceptLicense:      true,
+	}
+	configPath := filepath.Join(testDir, "config.json")
+	if err := writeResolvedConfig(c.resolvedConfig(envReader, downloader, L4, false, true), configPath); err != nil {
+		t.Fatalf("writeResolvedConfig() failed: %v", err)
+	}
+
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read dumped config: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatalf("Dumped config is not valid JSON: %v", err)
+	}
+	want := map[string]interface{}{
+		"driver_version":      "535.104.12",
+		"build_number":        "17800.66.78",
+		"kernel_release":      envReader.KernelRelease(),
+		"gpu_type":            "L4",
+		"host_install_dir":    "/var/lib/nvidia",
+		"install_dir_mode":    "0750",
+		"signed_modules":      true,
+		"signed_firmware":     false,
+		"kernel_module_type":  "auto",
+		"kernel_open":         true,
+		"cached":              false,
+		"prebuilt_modules":    true,
+		"gcs_download_bucket": "cos-tools-test",
+		"gcs_download_prefix": "17800.66.78/lakitu",
+		"module_parameters": map[string]interface{}{
+			"nvidia_uvm": []interface{}{"uvm_debug_prints=1"},
+		},
+		"test":                false,
+		"no_verify":           false,
+		"prepare_build_tools": false,
+		"license_accepted":    true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected dumped config (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateNvidiaInstallerFile(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	installerFile := filepath.Join(testDir, "NVIDIA-Linux-x86_64-535.104.12.run")
+	if err := ioutil.WriteFile(installerFile, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to create installer file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		testName  string
+		c         InstallCommand
+		expectErr bool
+	}{
+		{"Valid", InstallCommand{nvidiaInstallerFile: installerFile, unsignedDriver: true}, false},
+		{"ValidTestMode", InstallCommand{nvidiaInstallerFile: installerFile, test: true}, false},
+		{"ValidWithSignatureURL", InstallCommand{nvidiaInstallerFile: installerFile, test: true, signatureURL: "https://example.com/signature.tar.gz"}, false},
+		{"WithURL", InstallCommand{nvidiaInstallerFile: installerFile, nvidiaInstallerURL: "https://example.com/installer.run", unsignedDriver: true}, true},
+		{"WithVersion", InstallCommand{nvidiaInstallerFile: installerFile, driverVersion: "535.104.12", unsignedDriver: true}, true},
+		{"SignedDriver", InstallCommand{nvidiaInstallerFile: installerFile}, true},
+		{"MissingFile", InstallCommand{nvidiaInstallerFile: filepath.Join(testDir, "missing.run"), unsignedDriver: true}, true},
+	} {
+		tc.c.kernelModuleType = kernelModuleAuto
+		tc.c.acceptLicense = true
+		if err := tc.c.validateFlags(); (err != nil) != tc.expectErr {
+			t.Errorf("%s: expect error: %v, got: %v", tc.testName, tc.expectErr, err)
+		}
+	}
+}
+
+func TestGetInstallDirMode(t *testing.T) {
+	defer os.Unsetenv(installDirModeEnv)
+
+	for _, tc := range []struct {
+		testName   string
+		flag       string
+		env        string
+		expectMode os.FileMode
+		expectErr  bool
+	}{
+		{"Default", "", "", 0755, false},
+		{"Flag", "0750", "", 0750, false},
+		{"FlagWithoutLeadingZero", "700", "", 0700, false},
+		{"Env", "", "0750", 0750, false},
+		{"FlagOverridesEnv", "0700", "0750", 0700, false},
+		{"NotOctal", "0789", "", 0, true},
+		{"NotANumber", "rwxr-x---", "", 0, true},
+		{"SpecialBits", "01755", "", 0, true},
+		{"OwnerCannotWrite", "0555", "", 0, true},
+		{"WorldWritable", "0777", "", 0, true},
+		{"InvalidEnv", "", "0666", 0, true},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			os.Setenv(installDirModeEnv, tc.env)
+			c := &InstallCommand{installDirMode: tc.flag, kernelModuleType: kernelModuleAuto, acceptLicense: true}
+			mode, err := c.getInstallDirMode()
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Fatalf("getInstallDirMode(): expect error: %v, got: %v", tc.expectErr, err)
+			}
+			if mode != tc.expectMode {
+				t.Errorf("getInstallDirMode(): expect mode: %04o, got: %04o", tc.expectMode, mode)
+			}
+			if err := c.validateFlags(); (err != nil) != tc.expectErr {
+				t.Errorf("validateFlags(): expect error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestRecordLicenseAcceptance(t *testing.T) {
+	origAuditf := auditf
+	defer func() { auditf = origAuditf }()
+	var logged []string
+	auditf = func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+	for _, tc := range []struct {
+		testName string
+		c        *InstallCommand
+		want     string
+	}{
+		{
+			testName: "DriverVersion",
+			c:        &InstallCommand{driverVersion: "535.104.12", acceptLicense: true, acceptLicenseSet: true},
+			want:     "NVIDIA driver license accepted with -accept-license for driver 535.104.12",
+		},
+		{
+			testName: "InstallerURL",
+			c:        &InstallCommand{nvidiaInstallerURL: "https://example.com/driver.run", acceptLicense: true, acceptLicenseSet: true},
+			want:     "NVIDIA driver license accepted with -accept-license for driver https://example.com/driver.run",
+		},
+		{
+			testName: "InstallerFile",
+			c:        &InstallCommand{nvidiaInstallerFile: "/tmp/driver.run", acceptLicense: true, acceptLicenseSet: true},
+			want:     "NVIDIA driver license accepted with -accept-license for driver /tmp/driver.run",
+		},
+		{
+			testName: "AcceptedByDefault",
+			c:        &InstallCommand{driverVersion: "535.104.12", acceptLicense: true},
+			want:     "NVIDIA driver license accepted by default, -accept-license not set, for driver 535.104.12",
+		},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			logged = nil
+			tc.c.recordLicenseAcceptance()
+			if diff := cmp.Diff([]string{tc.want}, logged); diff != "" {
+				t.Errorf("recordLicenseAcceptance() logged unexpected lines (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateAcceptLicense(t *testing.T) {
+	for _, tc := range []struct {
+		testName  string
+		c         InstallCommand
+		expectErr bool
+	}{
+		{"Accepted", InstallCommand{acceptLicense: true}, false},
+		{"NotAccepted", InstallCommand{acceptLicense: false}, true},
+		{"NotAcceptedPrepareBuildTools", InstallCommand{acceptLicense: false, prepareBuildTools: true}, false},
+	} {
+		tc.c.kernelModuleType = kernelModuleAuto
+		if err := tc.c.validateFlags(); (err != nil) != tc.expectErr {
+			t.Errorf("%s: expect error: %v, got: %v", tc.testName, tc.expectErr, err)
+		}
+	}
+}
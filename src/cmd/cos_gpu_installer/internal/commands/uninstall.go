@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"context"
+	stderrors "errors"
+	"flag"
+	"os"
+
+	"cos.googlesource.com/cos/tools.git/src/cmd/cos_gpu_installer/internal/installer"
+
+	log "github.com/golang/glog"
+	"github.com/google/subcommands"
+	"github.com/pkg/errors"
+)
+
+// UninstallCommand is the subcommand to uninstall GPU drivers.
+type UninstallCommand struct {
+	hostInstallDir string
+	debug          bool
+}
+
+// Name implements subcommands.Command.Name.
+func (*UninstallCommand) Name() string { return "uninstall" }
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*UninstallCommand) Synopsis() string {
+	return "Unload GPU drivers and remove the driver installation."
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*UninstallCommand) Usage() string { return "uninstall [-host-dir <hostdir>]\n" }
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (c *UninstallCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.hostInstallDir, "host-dir", "",
+		"Host directory that GPU drivers were installed to. "+
+			"It tries to read from the env NVIDIA_INSTALL_DIR_HOST if the flag is not set explicitly.")
+	f.BoolVar(&c.debug, "debug", false,
+		"Enable debug mode.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (c *UninstallCommand) Execute(_ context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if c.hostInstallDir == "" {
+		c.hostInstallDir = os.Getenv("NVIDIA_INSTALL_DIR_HOST")
+	}
+	if c.hostInstallDir == "" {
+		log.Errorf("The host directory to uninstall GPU drivers from must be set with -host-dir or NVIDIA_INSTALL_DIR_HOST. Usage: %s", c.Usage())
+		return subcommands.ExitUsageError
+	}
+	if err := installer.UninstallDriver(hostRootPath, c.hostInstallDir); err != nil {
+		if stderrors.Is(err, installer.ErrDriverInUse) {
+			log.Errorf("GPU driver is in use and was not uninstalled. Stop the workloads using the GPU and retry.")
+		}
+		c.logError(errors.Wrap(err, "failed to uninstall GPU driver"))
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *UninstallCommand) logError(err error) {
+	if c.debug {
+		log.Errorf("%+v", err)
+	} else {
+		log.Errorf("%v", err)
+	}
+}
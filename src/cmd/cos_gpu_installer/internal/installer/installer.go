@@ -2,16 +2,19 @@
 package installer
 
 import (
+	"bytes"
 	stderrors "errors"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"syscall"
 
 	"cos.googlesource.com/cos/tools.git/src/cmd/cos_gpu_installer/internal/signing"
@@ -27,6 +30,7 @@ import (
 const (
 	gpuInstallDirContainer        = "/usr/local/nvidia"
 	gpuFirmwareDirContainer       = "/usr/local/nvidia/firmware/nvidia"
+	computeProbePath              = "/cuda-probe"
 	templateGPUDriverFile         = "gpu_%s_version"
 	precompiledInstallerURLFormat = "https://storage.googleapis.com/nvidia-drivers-%s-public/nvidia-cos-project/%s/tesla/%s_00/%s/NVIDIA-Linux-x86_64-%s_%s-%s.cos"
 	precompiledDriverTemplate     = "NVIDIA-Linux-x86_64-%s-custom.run"
@@ -36,6 +40,7 @@ const (
 	DefaultVersion                = "default"
 	LatestVersion                 = "latest"
 	installerURLTemplate          = "https://storage.googleapis.com/nvidia-drivers-%[1]s-public/tesla/%[2]s/NVIDIA-Linux-x86_64-%[2]s.run"
+	imaXattr                      = "security.ima"
 )
 
 var (
@@ -45,16 +50,61 @@ var (
 	ErrDriverLoad = stderrors.New("failed to load GPU drivers")
 
 	errInstallerFailed = stderrors.New("failed to run GPU driver installer")
+	// errDriverLibMismatch indicates that the loaded kernel modules and the
+	// installed userspace libraries belong to different driver versions.
+	errDriverLibMismatch = stderrors.New("GPU driver kernel module and userspace library versions do not match")
 )
 
+var (
+	// nvidiaModuleVersionPath contains the version of the loaded nvidia
+	// kernel module. It is a variable so tests can provide a fake version.
+	nvidiaModuleVersionPath = "/sys/module/nvidia/version"
+	// userLibDir contains the installed userspace libraries. It is a variable
+	// so tests can provide fake libraries.
+	userLibDir = gpuInstallDirContainer + "/lib64"
+)
+
+// installDirMode is the permission mode of the directories created for the
+// driver installation. It can be changed with SetInstallDirMode.
+var installDirMode os.FileMode = defaultFilePermission
+
+// SetInstallDirMode sets the permission mode of the directories created for
+// the driver installation. It defaults to 0755.
+func SetInstallDirMode(mode os.FileMode) {
+	installDirMode = mode
+}
+
+// loadModule loads a kernel module with its parameters. It is a variable so
+// tests can check the parameters GPU drivers are loaded with.
+var loadModule = modules.LoadModule
+
+// setxattr sets an extended attribute. It is a variable so tests can simulate
+// a filesystem that silently drops xattrs.
+var setxattr = syscall.Setxattr
+
+// runComputeProbe runs the bundled compute probe against the installed driver.
+// It is a variable so tests can stub out the probe.
+var runComputeProbe = func() error {
+	cmd := exec.Command(computeProbePath)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("LD_LIBRARY_PATH=%s/lib64", gpuInstallDirContainer))
+	return utils.RunCommandAndLogOutput(cmd, false)
+}
+
 // VerifyDriverInstallation runs some commands to verify the driver installation.
-func VerifyDriverInstallation(noVerify bool) error {
+// If selfTest is set, it also checks that the GPU can run a minimal compute workload.
+func VerifyDriverInstallation(noVerify, selfTest bool) error {
 	if noVerify {
 		log.Infof("Flag --no-verify is set, skip driver installation verification.")
 		return nil
 	}
 	log.Info("Verifying GPU driver installation")
 
+	// nvidia-smi fails with an unhelpful message if a previous installation
+	// left libraries of a different driver version behind, so check first.
+	if err := checkDriverLibVersions(); err != nil {
+		return err
+	}
+
 	newPathEnv := fmt.Sprintf("%s/bin:%s", gpuInstallDirContainer, os.Getenv("PATH"))
 	os.Setenv("PATH", newPathEnv)
 	// Run nvidia-smi to check whether nvidia GPU driver is installed.
@@ -71,6 +121,68 @@ func VerifyDriverInstallation(noVerify bool) error {
 	if err := utils.RunCommandAndLogOutput(exec.Command("nvidia-ctk", "system", "create-dev-char-symlinks", "--create-all"), false); err != nil {
 		return errors.Wrap(err, "failed to create symlinks")
 	}
+
+	if selfTest {
+		return runComputeSelfTest()
+	}
+	return nil
+}
+
+// checkDriverLibVersions checks that the loaded kernel module has the same
+// version as the installed userspace libraries.
+func checkDriverLibVersions() error {
+	moduleVersion, err := kernelModuleVersion()
+	if err != nil {
+		return err
+	}
+	libVersion, err := userLibVersion()
+	if err != nil {
+		return err
+	}
+	if moduleVersion != libVersion {
+		return fmt.Errorf("%w: kernel module version is %s but userspace library version is %s, remove %s and reinstall the driver",
+			errDriverLibMismatch, moduleVersion, libVersion, userLibDir)
+	}
+	log.Infof("GPU driver kernel module and userspace library versions match: %s", moduleVersion)
+	return nil
+}
+
+// kernelModuleVersion returns the version of the loaded nvidia kernel module.
+func kernelModuleVersion() (string, error) {
+	version, err := os.ReadFile(nvidiaModuleVersionPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read nvidia kernel module version from %s", nvidiaModuleVersionPath)
+	}
+	return strings.TrimSpace(string(version)), nil
+}
+
+// userLibVersion returns the version of the installed userspace libraries,
+// from the file name that the libnvidia-ml.so.1 symlink resolves to, ex.
+// "libnvidia-ml.so.535.104.05". Libraries of older versions left behind by an
+// upgrade are ignored since libnvidia-ml.so.1 is what applications load.
+func userLibVersion() (string, error) {
+	const libPrefix = "libnvidia-ml.so."
+	soname := filepath.Join(userLibDir, libPrefix+"1")
+	lib, err := filepath.EvalSymlinks(soname)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %s", soname)
+	}
+	version := strings.TrimPrefix(filepath.Base(lib), libPrefix)
+	if !strings.HasPrefix(filepath.Base(lib), libPrefix) || version == "1" {
+		return "", errors.Errorf("failed to find the userspace library version: %s resolves to %s", soname, lib)
+	}
+	return version, nil
+}
+
+// runComputeSelfTest allocates and frees device memory through the CUDA driver
+// to catch installations where the driver loads but compute is broken.
+func runComputeSelfTest() error {
+	log.Info("Running GPU compute self-test")
+	if err := runComputeProbe(); err != nil {
+		log.Error("GPU compute self-test: FAIL")
+		return errors.Wrap(err, "GPU compute self-test failed")
+	}
+	log.Info("GPU compute self-test: PASS")
 	return nil
 }
 
@@ -102,6 +214,24 @@ func DownloadToInstallDir(url, infoStr string) (string, error) {
 
 }
 
+// LocalInstaller checks that installerPath is an executable regular file that
+// can be used as the GPU driver installer instead of downloading one. It
+// returns the absolute path of the installer, since installer paths are
+// otherwise resolved relative to the GPU installation directory.
+func LocalInstaller(installerPath string) (string, error) {
+	info, err := os.Stat(installerPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to stat GPU driver installer %s", installerPath)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("GPU driver installer %s is not a regular file", installerPath)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		return "", fmt.Errorf("GPU driver installer %s is not executable", installerPath)
+	}
+	return filepath.Abs(installerPath)
+}
+
 // DownloadDriverInstaller downloads GPU driver installer given driver version and COS version.
 func DownloadDriverInstaller(driverVersion, cosMilestone, cosBuildNumber string) (string, error) {
 	log.Infof("Downloading GPU driver installer version %s", driverVersion)
@@ -123,41 +253,112 @@ func DownloadDriverInstallerV2(downloader cos.ExtensionsDownloader, driverVersio
 	return installerFilename, nil
 }
 
+// These are variables so tests can configure the driver installation dirs
+// without touching the mounts or the ld cache of the test environment.
+var (
+	bindMount     = createHostDirBindMount
+	overlayMount  = createOverlayFS
+	unmount       = syscall.Unmount
+	updateLdCache = updateContainerLdCache
+	exit          = os.Exit
+)
+
+// errInterrupted indicates that the installer was interrupted while
+// configuring the driver installation dirs.
+var errInterrupted = stderrors.New("interrupted")
+
+// installationMounts records the mounts created for the driver installation
+// dirs, so that they can be torn down even if the installer is interrupted
+// halfway through configuring them.
+type installationMounts struct {
+	mu          sync.Mutex
+	mountPoints []string
+	tornDown    bool
+}
+
+// mount runs mountFunc and records mountPoint if it succeeds. It fails without
+// running mountFunc once the mounts have been torn down.
+func (m *installationMounts) mount(mountPoint string, mountFunc func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tornDown {
+		return errInterrupted
+	}
+	if err := mountFunc(); err != nil {
+		return err
+	}
+	m.mountPoints = append(m.mountPoints, mountPoint)
+	return nil
+}
+
+// tearDown unmounts the recorded mounts in reverse order.
+func (m *installationMounts) tearDown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tornDown = true
+	for i := len(m.mountPoints) - 1; i >= 0; i-- {
+		if err := unmount(m.mountPoints[i], 0); err != nil {
+			log.Warningf("Failed to unmount %s: %v", m.mountPoints[i], err)
+		}
+	}
+	m.mountPoints = nil
+}
+
 // ConfigureDriverInstallationDirs configures GPU driver installation directories by creating mounts.
+// The mounts are torn down when a value is sent on the returned channel. If
+// the installer receives SIGINT or SIGTERM before that, the mounts are torn
+// down and the installer exits.
 func ConfigureDriverInstallationDirs(gpuInstallDirHost string, kernelRelease string) (chan<- int, error) {
 	log.Info("Configuring driver installation directories")
 
-	if err := createHostDirBindMount(gpuInstallDirHost, gpuInstallDirContainer); err != nil {
-		return nil, errors.Wrap(err, "failed to create dirver installation dir")
+	mounts := &installationMounts{}
+	ch := make(chan int, 1)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		// cleans up mounts created below.
+		defer signal.Stop(sigs)
+		select {
+		case <-ch:
+			mounts.tearDown()
+		case sig := <-sigs:
+			log.Errorf("Received %v, cleaning up driver installation directories", sig)
+			mounts.tearDown()
+			log.Flush()
+			exit(1)
+		}
+	}()
+	fail := func(err error, msg string) (chan<- int, error) {
+		ch <- 0
+		return nil, errors.Wrap(err, msg)
+	}
+
+	if err := mounts.mount(gpuInstallDirContainer, func() error {
+		return bindMount(gpuInstallDirHost, gpuInstallDirContainer)
+	}); err != nil {
+		return fail(err, "failed to create dirver installation dir")
 	}
 
-	if err := createOverlayFS(
-		"/usr/bin", gpuInstallDirContainer+"/bin", gpuInstallDirContainer+"/bin-workdir"); err != nil {
-		return nil, errors.Wrap(err, "failed to create bin overlay")
+	if err := mounts.mount("/usr/bin", func() error {
+		return overlayMount("/usr/bin", gpuInstallDirContainer+"/bin", gpuInstallDirContainer+"/bin-workdir")
+	}); err != nil {
+		return fail(err, "failed to create bin overlay")
 	}
-	if err := createOverlayFS(
-		"/usr/lib/x86_64-linux-gnu", gpuInstallDirContainer+"/lib64", gpuInstallDirContainer+"/lib64-workdir"); err != nil {
-		return nil, errors.Wrap(err, "failed to create lib64 overlay")
+	if err := mounts.mount("/usr/lib/x86_64-linux-gnu", func() error {
+		return overlayMount("/usr/lib/x86_64-linux-gnu", gpuInstallDirContainer+"/lib64", gpuInstallDirContainer+"/lib64-workdir")
+	}); err != nil {
+		return fail(err, "failed to create lib64 overlay")
 	}
 	modulePath := filepath.Join("/lib/modules", kernelRelease, "video")
-	if err := createOverlayFS(
-		modulePath, gpuInstallDirContainer+"/drivers", gpuInstallDirContainer+"/drivers-workdir"); err != nil {
-		return nil, errors.Wrap(err, "failed to create drivers overlay")
+	if err := mounts.mount(modulePath, func() error {
+		return overlayMount(modulePath, gpuInstallDirContainer+"/drivers", gpuInstallDirContainer+"/drivers-workdir")
+	}); err != nil {
+		return fail(err, "failed to create drivers overlay")
 	}
 
-	if err := updateContainerLdCache(); err != nil {
-		return nil, errors.Wrap(err, "failed to update container ld cache")
+	if err := updateLdCache(); err != nil {
+		return fail(err, "failed to update container ld cache")
 	}
-
-	ch := make(chan int, 1)
-	go func() {
-		// cleans up mounts created above.
-		<-ch
-		syscall.Unmount("/usr/bin", 0)
-		syscall.Unmount("/usr/lib/x86_64-linux-gnu", 0)
-		syscall.Unmount(modulePath, 0)
-		syscall.Unmount(gpuInstallDirContainer, 0)
-	}()
 	return ch, nil
 }
 
@@ -321,7 +522,9 @@ func installUserLibs(nvidiaDir string) error {
 
 // RunDriverInstaller runs GPU driver installer. Only works if the provided
 // installer includes precompiled drivers.
-func RunDriverInstaller(toolchainDir, installerFilename, driverVersion string, needSigned, test, legacyLink, noVerify bool, moduleParameters modules.ModuleParameters) error {
+// Unless forceUnsignedFirmware is set, GSP firmware is signed when its
+// signature is available.
+func RunDriverInstaller(toolchainDir, installerFilename, driverVersion string, needSigned, forceUnsignedFirmware, test, legacyLink, noVerify bool, moduleParameters modules.ModuleParameters) error {
 	log.Info("Running GPU driver installer")
 
 	// Extract files to a fixed path first to make sure md5sum of generated gpu drivers are consistent.
@@ -423,7 +626,7 @@ func RunDriverInstaller(toolchainDir, installerFilename, driverVersion string, n
 			log.Info("found driver version from nvidia-installer pkg ", driverVersion)
 		}
 
-		if err := prepareGSPFirmware(extractDir, driverVersion, needSigned); err != nil {
+		if err := prepareGSPFirmware(extractDir, driverVersion, needSigned, forceUnsignedFirmware); err != nil {
 			return fmt.Errorf("failed to prepare GSP firmware, err: %v", err)
 		}
 	}
@@ -495,12 +698,23 @@ func getPrecompiledInstallerURL(driverVersion, cosMilestone, cosBuildNumber, dow
 		downloadLocation, cosMilestone, majorVersion, driverVersion, driverVersion, cosMilestone, cosBuildNumber)
 }
 
-func createHostDirBindMount(hostDir, bindMountPath string) error {
-	if err := os.MkdirAll(hostDir, defaultFilePermission); err != nil {
-		return errors.Wrapf(err, "failed to create dir %s", hostDir)
+// makeInstallDirs creates dirs and sets their permission mode to
+// installDirMode, regardless of the umask.
+func makeInstallDirs(dirs ...string) error {
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, installDirMode); err != nil {
+			return errors.Wrapf(err, "failed to create dir %s", dir)
+		}
+		if err := os.Chmod(dir, installDirMode); err != nil {
+			return errors.Wrapf(err, "failed to change permission of dir %s", dir)
+		}
 	}
-	if err := os.MkdirAll(bindMountPath, defaultFilePermission); err != nil {
-		return errors.Wrapf(err, "failed to create dir %s", bindMountPath)
+	return nil
+}
+
+func createHostDirBindMount(hostDir, bindMountPath string) error {
+	if err := makeInstallDirs(hostDir, bindMountPath); err != nil {
+		return err
 	}
 	if err := syscall.Mount(hostDir, bindMountPath, "", syscall.MS_BIND, ""); err != nil {
 		return errors.Wrapf(err, "failed to create bind mount %s", bindMountPath)
@@ -517,11 +731,8 @@ func createOverlayFS(lowerDir, upperDir, workDir string) error {
 	if err := os.MkdirAll(lowerDir, defaultFilePermission); err != nil {
 		return errors.Wrapf(err, "failed to create dir %s", lowerDir)
 	}
-	if err := os.MkdirAll(upperDir, defaultFilePermission); err != nil {
-		return errors.Wrapf(err, "failed to create dir %s", upperDir)
-	}
-	if err := os.MkdirAll(workDir, defaultFilePermission); err != nil {
-		return errors.Wrapf(err, "failed to create dir %s", workDir)
+	if err := makeInstallDirs(upperDir, workDir); err != nil {
+		return err
 	}
 
 	if err := syscall.Mount("none", lowerDir, "overlay", 0,
@@ -547,14 +758,44 @@ func loadGPUDrivers(moduleParams modules.ModuleParameters, needSigned, test, ker
 	moduleNames := []string{"nvidia", "nvidia_uvm", "nvidia_drm", "nvidia_modeset"}
 	for _, moduleName := range moduleNames {
 		modulePath := gpuModules[moduleName]
-		if err := modules.LoadModule(moduleName, modulePath, moduleParams); err != nil {
+		if err := loadModule(moduleName, modulePath, moduleParams); err != nil {
 			return errors.Wrapf(err, "failed to load module %s", modulePath)
 		}
 	}
 	return nil
 }
 
-func prepareGSPFirmware(extractDir, driverVersion string, needSigned bool) error {
+// firmwareAction is what prepareGSPFirmware does with a single GSP firmware file.
+type firmwareAction int
+
+const (
+	firmwareSkip firmwareAction = iota
+	firmwareCopy
+	firmwareCopySigned
+)
+
+// gspFirmwareAction decides how a GSP firmware file is prepared. Firmware is
+// signed whenever its signature is available unless forceUnsigned is set, and
+// skipped if a signature is required but missing. Firmware signing is decided
+// independently of kernel module signing.
+func gspFirmwareAction(haveFirmware, haveSignature, needSigned, forceUnsigned bool) (firmwareAction, error) {
+	switch {
+	case haveSignature && !haveFirmware:
+		return firmwareSkip, fmt.Errorf("firmware doesn't exist but its signature does.")
+	case !haveFirmware:
+		return firmwareSkip, nil
+	case forceUnsigned:
+		return firmwareCopy, nil
+	case !haveSignature && needSigned:
+		return firmwareSkip, nil
+	case !haveSignature:
+		return firmwareCopy, nil
+	default:
+		return firmwareCopySigned, nil
+	}
+}
+
+func prepareGSPFirmware(extractDir, driverVersion string, needSigned, forceUnsigned bool) error {
 	for _, gspFileName := range gspFileNames {
 		signaturePath := signing.GetModuleSignature(gspFileName)
 		installerGSPPath := filepath.Join(extractDir, "firmware", gspFileName)
@@ -567,19 +808,23 @@ func prepareGSPFirmware(extractDir, driverVersion string, needSigned bool) error
 		if err != nil {
 			return fmt.Errorf("failed to check if %s exists, err: %v", installerGSPPath, err)
 		}
-		switch {
-		case haveSignature && !haveFirmware:
-			return fmt.Errorf("firmware doesn't exist but its signature does.")
-		case !haveFirmware:
-			log.Infof("GSP firmware for %s doesn't exist. Skipping firmware preparation for %s.", gspFileName, gspFileName)
-		case !needSigned:
+		action, err := gspFirmwareAction(haveFirmware, haveSignature, needSigned, forceUnsigned)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case firmwareSkip:
+			if !haveFirmware {
+				log.Infof("GSP firmware for %s doesn't exist. Skipping firmware preparation for %s.", gspFileName, gspFileName)
+			} else {
+				log.Infof("GSP firmware signature for %s doesn't exist. Skipping firmware preparation for %s.", gspFileName, gspFileName)
+			}
+		case firmwareCopy:
 			// No signature needed, copy firmware only.
 			if err := copyFirmware(installerGSPPath, containerGSPPath, driverVersion); err != nil {
 				return fmt.Errorf("failed to copy firmware, err: %v.", err)
 			}
-		case !haveSignature:
-			log.Infof("GSP firmware signature for %s doesn't exist. Skipping firmware preparation for %s.", gspFileName, gspFileName)
-		default:
+		case firmwareCopySigned:
 			// Both firmware and signature exist.
 			if err := copyFirmware(installerGSPPath, containerGSPPath, driverVersion); err != nil {
 				return fmt.Errorf("failed to copy firmware, err: %v.", err)
@@ -587,13 +832,16 @@ func prepareGSPFirmware(extractDir, driverVersion string, needSigned bool) error
 			if err := setIMAXattr(signaturePath, containerGSPPath); err != nil {
 				return err
 			}
+			if err := verifyIMAXattr(signaturePath, containerGSPPath); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
 func copyFirmware(installerGSPPath, containerGSPPath, gspFileName string) error {
-	if err := os.MkdirAll(filepath.Dir(containerGSPPath), defaultFilePermission); err != nil {
+	if err := os.MkdirAll(filepath.Dir(containerGSPPath), installDirMode); err != nil {
 		return fmt.Errorf("Falied to create firmware directory, err: %v", err)
 	}
 	if err := utils.CopyFile(installerGSPPath, containerGSPPath); err != nil {
@@ -607,12 +855,38 @@ func setIMAXattr(signaturePath, containerGSPPath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read signature err: %v", err)
 	}
-	if err := syscall.Setxattr(containerGSPPath, "security.ima", signature, 0); err != nil {
+	if err := setxattr(containerGSPPath, imaXattr, signature, 0); err != nil {
 		return fmt.Errorf("failed to set xattr for security.ima, err: %v", err)
 	}
 	return nil
 }
 
+// verifyIMAXattr checks that the security.ima xattr of containerGSPPath
+// matches the signature at signaturePath. Some filesystems accept the xattr
+// without storing it, which would only be noticed when the firmware fails to
+// load.
+func verifyIMAXattr(signaturePath, containerGSPPath string) error {
+	signature, err := os.ReadFile(signaturePath)
+	if err != nil {
+		return fmt.Errorf("failed to read signature err: %v", err)
+	}
+	// Leave room for one extra byte to detect a value longer than signature.
+	value := make([]byte, len(signature)+1)
+	n, err := syscall.Getxattr(containerGSPPath, imaXattr, value)
+	switch {
+	case err == syscall.ENODATA || err == syscall.ENOTSUP:
+		return fmt.Errorf("xattr security.ima did not persist on %s, the filesystem may not support security xattrs, err: %v", containerGSPPath, err)
+	case err == syscall.ERANGE:
+		return fmt.Errorf("xattr security.ima on %s does not match the signature %s", containerGSPPath, signaturePath)
+	case err != nil:
+		return fmt.Errorf("failed to read xattr security.ima of %s, err: %v", containerGSPPath, err)
+	}
+	if !bytes.Equal(value[:n], signature) {
+		return fmt.Errorf("xattr security.ima on %s does not match the signature %s", containerGSPPath, signaturePath)
+	}
+	return nil
+}
+
 // tries to read .manifest file to find driverVersion present in the manifest
 func findDriverVersionManifestFile(manifestFilePath string) string {
 	manifestFileRawBytes, err := os.ReadFile(manifestFilePath)
@@ -639,7 +913,7 @@ func RunDriverInstallerPrebuiltModules(downloader *cos.GCSDownloader, installerF
 	if err := exec.Command("tar", "--overwrite", "--xattrs", "--xattrs-include=*", "-xf", tarballPath, "-C", gpuInstallDirContainer).Run(); err != nil {
 		return fmt.Errorf("failed to extract prebuilt modules: %v", err)
 	}
-	if err := os.Chmod(gpuInstallDirContainer, defaultFilePermission); err != nil {
+	if err := os.Chmod(gpuInstallDirContainer, installDirMode); err != nil {
 		return fmt.Errorf("failed to change permission of install dir: %v", err)
 	}
 
@@ -1,7 +1,17 @@
 package installer
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/modules"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestGetInstallerDownloadLocation(t *testing.T) {
@@ -56,3 +66,425 @@ func TestGetGenericDriverInstallerURL(t *testing.T) {
 		t.Errorf("Unexpected return, want: %s, got: %s", expectedRet, ret)
 	}
 }
+
+func TestGSPFirmwareAction(t *testing.T) {
+	for _, tc := range []struct {
+		testName      string
+		haveFirmware  bool
+		haveSignature bool
+		needSigned    bool
+		forceUnsigned bool
+		expectAction  firmwareAction
+		expectErr     bool
+	}{
+		{"SignedModulesSignedFirmware", true, true, true, false, firmwareCopySigned, false},
+		{"SignedModulesUnsignedFirmware", true, true, true, true, firmwareCopy, false},
+		{"UnsignedModulesSignedFirmware", true, true, false, false, firmwareCopySigned, false},
+		{"UnsignedModulesUnsignedFirmware", true, true, false, true, firmwareCopy, false},
+		{"SignedModulesMissingSignature", true, false, true, false, firmwareSkip, false},
+		{"SignedModulesMissingSignatureForceUnsigned", true, false, true, true, firmwareCopy, false},
+		{"UnsignedModulesMissingSignature", true, false, false, false, firmwareCopy, false},
+		{"MissingFirmware", false, false, true, true, firmwareSkip, false},
+		{"SignatureWithoutFirmware", false, true, true, true, firmwareSkip, true},
+	} {
+		action, err := gspFirmwareAction(tc.haveFirmware, tc.haveSignature, tc.needSigned, tc.forceUnsigned)
+		if gotErr := err != nil; gotErr != tc.expectErr {
+			t.Errorf("%s: expect error: %v, got: %v", tc.testName, tc.expectErr, err)
+		}
+		if action != tc.expectAction {
+			t.Errorf("%s: expect action: %v, got: %v", tc.testName, tc.expectAction, action)
+		}
+	}
+}
+
+func TestRunComputeSelfTest(t *testing.T) {
+	origRunComputeProbe := runComputeProbe
+	defer func() { runComputeProbe = origRunComputeProbe }()
+
+	for _, tc := range []struct {
+		testName  string
+		probeErr  error
+		expectErr bool
+	}{
+		{"ProbePasses", nil, false},
+		{"ProbeFails", errors.New("cuMemAlloc failed: 2"), true},
+	} {
+		probeRan := false
+		runComputeProbe = func() error {
+			probeRan = true
+			return tc.probeErr
+		}
+		err := runComputeSelfTest()
+		if !probeRan {
+			t.Errorf("%s: compute probe did not run", tc.testName)
+		}
+		if gotErr := err != nil; gotErr != tc.expectErr {
+			t.Errorf("%s: expect error: %v, got: %v", tc.testName, tc.expectErr, err)
+		}
+	}
+}
+
+func TestLocalInstaller(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	for name, mode := range map[string]os.FileMode{
+		"NVIDIA-Linux-x86_64-535.104.12.run": 0755,
+		"not-executable.run":                 0644,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(testDir, name), []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working dir: %v", err)
+	}
+	defer os.Chdir(origDir)
+	if err := os.Chdir(testDir); err != nil {
+		t.Fatalf("Failed to change working dir: %v", err)
+	}
+
+	for _, tc := range []struct {
+		testName     string
+		path         string
+		expectedPath string
+		expectErr    bool
+	}{
+		{"AbsolutePath", filepath.Join(testDir, "NVIDIA-Linux-x86_64-535.104.12.run"), filepath.Join(testDir, "NVIDIA-Linux-x86_64-535.104.12.run"), false},
+		{"RelativePath", "NVIDIA-Linux-x86_64-535.104.12.run", filepath.Join(testDir, "NVIDIA-Linux-x86_64-535.104.12.run"), false},
+		{"NotExecutable", "not-executable.run", "", true},
+		{"Missing", "missing.run", "", true},
+		{"Directory", testDir, "", true},
+	} {
+		path, err := LocalInstaller(tc.path)
+		if gotErr := err != nil; gotErr != tc.expectErr {
+			t.Errorf("%s: expect error: %v, got: %v", tc.testName, tc.expectErr, err)
+		}
+		if path != tc.expectedPath {
+			t.Errorf("%s: expect path: %s, got: %s", tc.testName, tc.expectedPath, path)
+		}
+	}
+}
+
+// stubInstallationMounts replaces the mount functions used to configure the
+// driver installation dirs. Mount points are recorded in mounted and
+// unmounted, and unmountedAll is closed once the installation dir itself is
+// unmounted. overlayFunc is run before recording each overlay.
+func stubInstallationMounts(t *testing.T, overlayFunc func(lowerDir string) error) (mounted, unmounted func() []string, unmountedAll <-chan struct{}) {
+	t.Helper()
+	origBindMount, origOverlayMount, origUnmount, origUpdateLdCache := bindMount, overlayMount, unmount, updateLdCache
+	t.Cleanup(func() {
+		bindMount, overlayMount, unmount, updateLdCache = origBindMount, origOverlayMount, origUnmount, origUpdateLdCache
+	})
+
+	var mu sync.Mutex
+	var mountPoints, unmountPoints []string
+	done := make(chan struct{})
+	bindMount = func(_, bindMountPath string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		mountPoints = append(mountPoints, bindMountPath)
+		return nil
+	}
+	overlayMount = func(lowerDir, _, _ string) error {
+		if err := overlayFunc(lowerDir); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		mountPoints = append(mountPoints, lowerDir)
+		return nil
+	}
+	unmount = func(target string, _ int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		unmountPoints = append(unmountPoints, target)
+		if target == gpuInstallDirContainer {
+			close(done)
+		}
+		return nil
+	}
+	updateLdCache = func() error { return nil }
+	snapshot := func(points *[]string) func() []string {
+		return func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), *points...)
+		}
+	}
+	return snapshot(&mountPoints), snapshot(&unmountPoints), done
+}
+
+func reversed(s []string) []string {
+	var r []string
+	for i := len(s) - 1; i >= 0; i-- {
+		r = append(r, s[i])
+	}
+	return r
+}
+
+func TestConfigureDriverInstallationDirs(t *testing.T) {
+	for _, tc := range []struct {
+		testName     string
+		overlayErr   string
+		expectMounts int
+		expectErr    bool
+	}{
+		{"Success", "", 4, false},
+		{"OverlayFails", "/usr/lib/x86_64-linux-gnu", 2, true},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			mounted, unmounted, unmountedAll := stubInstallationMounts(t, func(lowerDir string) error {
+				if lowerDir == tc.overlayErr {
+					return errors.New("mount failed")
+				}
+				return nil
+			})
+			callback, err := ConfigureDriverInstallationDirs("/var/lib/nvidia", "5.15.0")
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Fatalf("Unexpected error: want error: %v, got: %v", tc.expectErr, err)
+			}
+			if err == nil {
+				callback <- 0
+			}
+			select {
+			case <-unmountedAll:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timed out waiting for mounts to be torn down")
+			}
+			if got := mounted(); len(got) != tc.expectMounts {
+				t.Errorf("Unexpected mounts: want %d mounts, got: %q", tc.expectMounts, got)
+			}
+			if diff := cmp.Diff(reversed(mounted()), unmounted()); diff != "" {
+				t.Errorf("Unexpected unmounts (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigureDriverInstallationDirsInterrupted(t *testing.T) {
+	origExit := exit
+	defer func() { exit = origExit }()
+	exitCode := make(chan int, 1)
+	exit = func(code int) { exitCode <- code }
+
+	mounted, unmounted, _ := stubInstallationMounts(t, func(lowerDir string) error {
+		if lowerDir == "/usr/bin" {
+			// Simulate an interrupt while the installation dirs are being configured.
+			return syscall.Kill(os.Getpid(), syscall.SIGINT)
+		}
+		return nil
+	})
+	// Configuration either finishes before the interrupt is handled or fails
+	// because the mounts were already torn down; both are fine.
+	ConfigureDriverInstallationDirs("/var/lib/nvidia", "5.15.0")
+
+	select {
+	case code := <-exitCode:
+		if code != 1 {
+			t.Errorf("Unexpected exit code: want 1, got: %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the installer to exit on interrupt")
+	}
+	if len(mounted()) < 2 {
+		t.Errorf("Unexpected mounts: want at least the bind mount and the bin overlay, got: %q", mounted())
+	}
+	if diff := cmp.Diff(reversed(mounted()), unmounted()); diff != "" {
+		t.Errorf("Unexpected unmounts (-want +got):\n%s", diff)
+	}
+}
+
+func TestMakeInstallDirs(t *testing.T) {
+	defer SetInstallDirMode(installDirMode)
+
+	for _, mode := range []os.FileMode{0755, 0750, 0700} {
+		testDir, err := ioutil.TempDir("", "testing")
+		if err != nil {
+			t.Fatalf("Failed to create tempdir: %v", err)
+		}
+		defer os.RemoveAll(testDir)
+		existingDir := filepath.Join(testDir, "nvidia")
+		if err := os.Mkdir(existingDir, 0777); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+
+		SetInstallDirMode(mode)
+		dirs := []string{existingDir, filepath.Join(existingDir, "bin"), filepath.Join(existingDir, "bin-workdir")}
+		if err := makeInstallDirs(dirs...); err != nil {
+			t.Fatalf("makeInstallDirs() failed: %v", err)
+		}
+		for _, dir := range dirs {
+			info, err := os.Stat(dir)
+			if err != nil {
+				t.Fatalf("Failed to stat %s: %v", dir, err)
+			}
+			if got := info.Mode().Perm(); got != mode {
+				t.Errorf("Unexpected mode of %s: want %04o, got %04o", dir, mode, got)
+			}
+		}
+	}
+}
+
+func TestLoadGPUDriversModuleParameters(t *testing.T) {
+	origLoadModule := loadModule
+	defer func() { loadModule = origLoadModule }()
+
+	moduleParams := modules.NewModuleParameters()
+	for _, param := range []string{"nvidia.NVreg_EnableGpuFirmware=0", "nvidia_uvm.uvm_debug_prints=1", "nvidia.NVreg_OpenRmEnableUnsupportedGpus=1"} {
+		if err := moduleParams.Set(param); err != nil {
+			t.Fatalf("Failed to set module parameter %s: %v", param, err)
+		}
+	}
+	got := make(map[string][]string)
+	var loaded []string
+	loadModule = func(moduleName, _ string, params modules.ModuleParameters) error {
+		loaded = append(loaded, moduleName)
+		got[moduleName] = params[moduleName]
+		return nil
+	}
+	if err := loadGPUDrivers(moduleParams, false, false, false, false); err != nil {
+		t.Fatalf("loadGPUDrivers() failed: %v", err)
+	}
+	if diff := cmp.Diff([]string{"nvidia", "nvidia_uvm", "nvidia_drm", "nvidia_modeset"}, loaded); diff != "" {
+		t.Errorf("Unexpected loaded modules (-want +got):\n%s", diff)
+	}
+	want := map[string][]string{
+		"nvidia":         {"NVreg_EnableGpuFirmware=0", "NVreg_OpenRmEnableUnsupportedGpus=1"},
+		"nvidia_uvm":     {"uvm_debug_prints=1"},
+		"nvidia_drm":     nil,
+		"nvidia_modeset": nil,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected module parameters (-want +got):\n%s", diff)
+	}
+
+	loaded = nil
+	if err := loadGPUDrivers(moduleParams, false, false, false, true); err != nil {
+		t.Fatalf("loadGPUDrivers() with noVerify failed: %v", err)
+	}
+	if len(loaded) != 0 {
+		t.Errorf("loadGPUDrivers() with noVerify loaded modules: %q", loaded)
+	}
+}
+
+// mountTmpfs mounts a tmpfs for the duration of the test, since the xattr
+// support of the default temp dir depends on the host.
+func mountTmpfs(t *testing.T) string {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(testDir) })
+	if err := syscall.Mount("tmpfs", testDir, "tmpfs", 0, ""); err != nil {
+		t.Skipf("Failed to mount tmpfs, skipping: %v", err)
+	}
+	t.Cleanup(func() { syscall.Unmount(testDir, 0) })
+	return testDir
+}
+
+func TestVerifyIMAXattr(t *testing.T) {
+	origSetxattr := setxattr
+	defer func() { setxattr = origSetxattr }()
+
+	for _, tc := range []struct {
+		testName       string
+		dropXattrs     bool
+		otherSignature bool
+		expectErr      bool
+	}{
+		{"XattrPersisted", false, false, false},
+		{"XattrDropped", true, false, true},
+		{"SignatureMismatch", false, true, true},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			testDir := mountTmpfs(t)
+			signaturePath := filepath.Join(testDir, "gsp.bin.sig")
+			firmwarePath := filepath.Join(testDir, "gsp.bin")
+			if err := ioutil.WriteFile(signaturePath, []byte("signature"), 0644); err != nil {
+				t.Fatalf("Failed to write signature: %v", err)
+			}
+			if err := ioutil.WriteFile(firmwarePath, []byte("firmware"), 0644); err != nil {
+				t.Fatalf("Failed to write firmware: %v", err)
+			}
+			setxattr = origSetxattr
+			if tc.dropXattrs {
+				// Simulate a filesystem that accepts xattrs without storing them.
+				setxattr = func(string, string, []byte, int) error { return nil }
+			}
+
+			if err := setIMAXattr(signaturePath, firmwarePath); err != nil {
+				t.Fatalf("setIMAXattr() failed: %v", err)
+			}
+			if tc.otherSignature {
+				if err := ioutil.WriteFile(signaturePath, []byte("other signature"), 0644); err != nil {
+					t.Fatalf("Failed to write signature: %v", err)
+				}
+			}
+			err := verifyIMAXattr(signaturePath, firmwarePath)
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Errorf("verifyIMAXattr(): expect error: %v, got: %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckDriverLibVersions(t *testing.T) {
+	origModuleVersionPath, origUserLibDir := nvidiaModuleVersionPath, userLibDir
+	defer func() { nvidiaModuleVersionPath, userLibDir = origModuleVersionPath, origUserLibDir }()
+
+	for _, tc := range []struct {
+		testName       string
+		moduleVersion  string
+		libs           []string
+		sonameTarget   string
+		expectErr      bool
+		expectMismatch bool
+	}{
+		{"VersionsMatch", "535.104.05\n", []string{"libnvidia-ml.so.535.104.05"}, "libnvidia-ml.so.535.104.05", false, false},
+		{"VersionsMismatch", "535.104.05\n", []string{"libnvidia-ml.so.525.125.06"}, "libnvidia-ml.so.525.125.06", true, true},
+		{"OlderLibLeftBehind", "535.104.05\n", []string{"libnvidia-ml.so.535.104.05", "libnvidia-ml.so.525.125.06"}, "libnvidia-ml.so.535.104.05", false, false},
+		{"SonameNotSymlink", "535.104.05\n", []string{"libnvidia-ml.so.535.104.05", "libnvidia-ml.so.1"}, "", true, false},
+		{"NoLibs", "535.104.05\n", nil, "", true, false},
+		{"ModuleNotLoaded", "", []string{"libnvidia-ml.so.535.104.05"}, "libnvidia-ml.so.535.104.05", true, false},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			testDir, err := ioutil.TempDir("", "testing")
+			if err != nil {
+				t.Fatalf("Failed to create tempdir: %v", err)
+			}
+			defer os.RemoveAll(testDir)
+			nvidiaModuleVersionPath = filepath.Join(testDir, "version")
+			if tc.moduleVersion != "" {
+				if err := ioutil.WriteFile(nvidiaModuleVersionPath, []byte(tc.moduleVersion), 0644); err != nil {
+					t.Fatalf("Failed to write module version: %v", err)
+				}
+			}
+			userLibDir = filepath.Join(testDir, "lib64")
+			if err := os.Mkdir(userLibDir, 0755); err != nil {
+				t.Fatalf("Failed to create lib dir: %v", err)
+			}
+			for _, lib := range tc.libs {
+				if err := ioutil.WriteFile(filepath.Join(userLibDir, lib), nil, 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", lib, err)
+				}
+			}
+			if tc.sonameTarget != "" {
+				if err := os.Symlink(tc.sonameTarget, filepath.Join(userLibDir, "libnvidia-ml.so.1")); err != nil {
+					t.Fatalf("Failed to create symlink: %v", err)
+				}
+			}
+
+			err = checkDriverLibVersions()
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Errorf("checkDriverLibVersions(): expect error: %v, got: %v", tc.expectErr, err)
+			}
+			if gotMismatch := errors.Is(err, errDriverLibMismatch); gotMismatch != tc.expectMismatch {
+				t.Errorf("checkDriverLibVersions(): expect errDriverLibMismatch: %v, got: %v", tc.expectMismatch, err)
+			}
+		})
+	}
+}
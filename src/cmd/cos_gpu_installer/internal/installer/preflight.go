@@ -0,0 +1,176 @@
+package installer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// mountinfoPath lists the mounts visible to the installer. It is a variable so
+// tests can provide a sample mount table.
+var mountinfoPath = "/proc/self/mountinfo"
+
+// CheckPriorInstallation inspects the GPU driver installation directory on the
+// host and the current mounts for leftovers of an interrupted installation.
+// It returns a description of each inconsistency found.
+func CheckPriorInstallation(gpuInstallDirHost string) ([]string, error) {
+	var issues []string
+
+	mounts, err := staleMounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, mount := range mounts {
+		issues = append(issues, fmt.Sprintf("stale mount at %s", mount))
+	}
+
+	if exists, err := utils.CheckFileExists(gpuInstallDirHost); err != nil || !exists {
+		return issues, err
+	}
+	haveCache, err := utils.CheckFileExists(filepath.Join(gpuInstallDirHost, cacheFile))
+	if err != nil {
+		return nil, err
+	}
+	modules, err := filesWithSuffix(filepath.Join(gpuInstallDirHost, "drivers"), ".ko")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case haveCache && len(modules) == 0:
+		issues = append(issues, fmt.Sprintf("%s records a completed installation but %s has no kernel modules", cacheFile, filepath.Join(gpuInstallDirHost, "drivers")))
+	case !haveCache && len(modules) > 0:
+		issues = append(issues, fmt.Sprintf("kernel modules %s found in %s without a completed installation record", strings.Join(modules, ", "), filepath.Join(gpuInstallDirHost, "drivers")))
+	}
+	if !haveCache {
+		for _, dir := range []string{"bin", "lib64"} {
+			files, err := filesWithSuffix(filepath.Join(gpuInstallDirHost, dir), "")
+			if err != nil {
+				return nil, err
+			}
+			if len(files) > 0 {
+				issues = append(issues, fmt.Sprintf("%d files found in %s without a completed installation record", len(files), filepath.Join(gpuInstallDirHost, dir)))
+			}
+		}
+	}
+	return issues, nil
+}
+
+// CheckCleanDir rejects host installation directories whose contents must not
+// be removed: an unset directory, which resolves to the host root mounted at
+// hostRootDir, or a directory outside of the host root. It must be called
+// before CleanPriorInstallation removes anything.
+func CheckCleanDir(hostRootDir, gpuInstallDirHost string) error {
+	rel, err := filepath.Rel(hostRootDir, filepath.Clean(gpuInstallDirHost))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("refusing to remove the contents of %q: the host directory must be set to a directory under the host root", gpuInstallDirHost)
+	}
+	return nil
+}
+
+// CleanPriorInstallation unmounts stale installation mounts and removes
+// everything in the GPU driver installation directory on the host, so that the
+// next installation starts from scratch.
+func CleanPriorInstallation(gpuInstallDirHost string) error {
+	log.Infof("Cleaning up prior GPU driver installation in %s", gpuInstallDirHost)
+	mounts, err := staleMounts()
+	if err != nil {
+		return err
+	}
+	// Unmount in reverse order so that nested mounts go first.
+	for i := len(mounts) - 1; i >= 0; i-- {
+		if err := unmount(mounts[i], syscall.MNT_DETACH); err != nil {
+			return errors.Wrapf(err, "failed to unmount %s", mounts[i])
+		}
+	}
+	entries, err := ioutil.ReadDir(gpuInstallDirHost)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to list %s", gpuInstallDirHost)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(gpuInstallDirHost, entry.Name())); err != nil {
+			return errors.Wrapf(err, "failed to remove %s", filepath.Join(gpuInstallDirHost, entry.Name()))
+		}
+	}
+	return nil
+}
+
+// deletedSuffix is appended by the kernel to the root of a mount in mountinfo
+// when the mounted directory has been removed.
+const deletedSuffix = `\040(deleted)`
+
+// staleMounts returns the mount points of the installation bind mount and the
+// overlays backed by it, as listed in mountinfoPath, whose source no longer
+// exists. The live installation volume and the overlays of a completed
+// installation are not stale.
+func staleMounts() ([]string, error) {
+	mountinfo, err := ioutil.ReadFile(mountinfoPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", mountinfoPath)
+	}
+	var mounts []string
+	for _, line := range strings.Split(string(mountinfo), "\n") {
+		// See proc(5) for the format of mountinfo lines.
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		mountPoint := fields[4]
+		switch {
+		case mountPoint == gpuInstallDirContainer || strings.HasPrefix(mountPoint, gpuInstallDirContainer+"/"):
+			if strings.HasSuffix(fields[3], deletedSuffix) {
+				mounts = append(mounts, mountPoint)
+			}
+		case strings.Contains(line, "upperdir="+gpuInstallDirContainer+"/"):
+			stale, err := overlayStale(fields[len(fields)-1])
+			if err != nil {
+				return nil, err
+			}
+			if stale {
+				mounts = append(mounts, mountPoint)
+			}
+		}
+	}
+	return mounts, nil
+}
+
+// overlayStale reports whether the upper directory of an overlay with the
+// given super options no longer exists.
+func overlayStale(superOptions string) (bool, error) {
+	for _, option := range strings.Split(superOptions, ",") {
+		if strings.HasPrefix(option, "upperdir=") {
+			exists, err := utils.CheckFileExists(strings.TrimPrefix(option, "upperdir="))
+			return !exists, err
+		}
+	}
+	return false, nil
+}
+
+// filesWithSuffix returns the names of regular files in dir ending with suffix.
+// A missing dir has no files.
+func filesWithSuffix(dir, suffix string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s", dir)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), suffix) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,180 @@
+package installer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMountinfo = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+`
+
+const testLiveMountinfo = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+40 22 8:1 /var/lib/nvidia /usr/local/nvidia rw,nosuid,nodev,relatime - ext4 /dev/sda1 rw
+`
+
+const testStaleMountinfo = `22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+40 22 8:1 /var/lib/nvidia\040(deleted) /usr/local/nvidia rw,nosuid,nodev,relatime - ext4 /dev/sda1 rw
+41 22 0:50 / /usr/bin rw,relatime - overlay none rw,lowerdir=/usr/bin,upperdir=/usr/local/nvidia/bin,workdir=/usr/local/nvidia/bin-workdir
+`
+
+func writeTestFiles(t *testing.T, root string, files []string) {
+	t.Helper()
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(""), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+}
+
+func TestCheckPriorInstallation(t *testing.T) {
+	origMountinfoPath := mountinfoPath
+	defer func() { mountinfoPath = origMountinfoPath }()
+
+	for _, tc := range []struct {
+		testName     string
+		files        []string
+		mountinfo    string
+		expectIssues []string
+	}{
+		{
+			"NoPriorInstallation",
+			nil,
+			testMountinfo,
+			nil,
+		},
+		{
+			"CompletedInstallation",
+			[]string{".cache", "drivers/nvidia.ko", "drivers/nvidia-uvm.ko", "bin/nvidia-smi", "lib64/libcuda.so.1"},
+			testMountinfo,
+			nil,
+		},
+		{
+			"ModulesWithoutCache",
+			[]string{"drivers/nvidia.ko", "bin-workdir/work/leftover"},
+			testMountinfo,
+			[]string{"kernel modules nvidia.ko found in"},
+		},
+		{
+			"UserspaceWithoutCache",
+			[]string{"bin/nvidia-smi", "lib64/libcuda.so.1", "lib64/libnvidia-ml.so.1"},
+			testMountinfo,
+			[]string{"1 files found in", "2 files found in"},
+		},
+		{
+			"CacheWithoutModules",
+			[]string{".cache", "bin/nvidia-smi"},
+			testMountinfo,
+			[]string{".cache records a completed installation"},
+		},
+		{
+			"LiveMounts",
+			[]string{".cache", "drivers/nvidia.ko"},
+			testLiveMountinfo,
+			nil,
+		},
+		{
+			"StaleMounts",
+			[]string{".cache", "drivers/nvidia.ko"},
+			testStaleMountinfo,
+			[]string{"stale mount at /usr/local/nvidia", "stale mount at /usr/bin"},
+		},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			testDir, err := ioutil.TempDir("", "testing")
+			if err != nil {
+				t.Fatalf("Failed to create tempdir: %v", err)
+			}
+			defer os.RemoveAll(testDir)
+			installDir := filepath.Join(testDir, "nvidia")
+			writeTestFiles(t, installDir, tc.files)
+			mountinfoPath = filepath.Join(testDir, "mountinfo")
+			if err := ioutil.WriteFile(mountinfoPath, []byte(tc.mountinfo), 0644); err != nil {
+				t.Fatalf("Failed to create mountinfo: %v", err)
+			}
+
+			issues, err := CheckPriorInstallation(installDir)
+			if err != nil {
+				t.Fatalf("Failed to check prior installation: %v", err)
+			}
+			if len(issues) != len(tc.expectIssues) {
+				t.Fatalf("Unexpected issues: want: %q, got: %q", tc.expectIssues, issues)
+			}
+			for i, want := range tc.expectIssues {
+				if !strings.Contains(issues[i], want) {
+					t.Errorf("Unexpected issue: want it to contain %q, got: %q", want, issues[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckCleanDir(t *testing.T) {
+	hostRootDir := "/root"
+	for _, tc := range []struct {
+		testName       string
+		hostInstallDir string
+		expectErr      bool
+	}{
+		{"InstallDir", "/var/lib/nvidia", false},
+		{"Unset", "", true},
+		{"HostRoot", "/", true},
+		{"CurrentDir", ".", true},
+		{"HostRootWithDots", "/var/..", true},
+		{"OutsideHostRoot", "/..", true},
+	} {
+		hostInstallDir := filepath.Join(hostRootDir, tc.hostInstallDir)
+		if err := CheckCleanDir(hostRootDir, hostInstallDir); (err != nil) != tc.expectErr {
+			t.Errorf("%s: CheckCleanDir(%q, %q): expect error: %v, got: %v", tc.testName, hostRootDir, hostInstallDir, tc.expectErr, err)
+		}
+	}
+}
+
+func TestCleanPriorInstallation(t *testing.T) {
+	origMountinfoPath, origUnmount := mountinfoPath, unmount
+	defer func() { mountinfoPath, unmount = origMountinfoPath, origUnmount }()
+
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	mountinfoPath = filepath.Join(testDir, "mountinfo")
+	if err := ioutil.WriteFile(mountinfoPath, []byte(testLiveMountinfo), 0644); err != nil {
+		t.Fatalf("Failed to create mountinfo: %v", err)
+	}
+	var unmounted []string
+	unmount = func(target string, _ int) error {
+		unmounted = append(unmounted, target)
+		return nil
+	}
+	installDir := filepath.Join(testDir, "nvidia")
+	writeTestFiles(t, installDir, []string{"drivers/nvidia.ko", "bin/nvidia-smi", "bin-workdir/work/leftover"})
+
+	if err := CleanPriorInstallation(installDir); err != nil {
+		t.Fatalf("Failed to clean prior installation: %v", err)
+	}
+	if len(unmounted) != 0 {
+		t.Errorf("Unexpected unmounts of live mounts: %q", unmounted)
+	}
+	entries, err := ioutil.ReadDir(installDir)
+	if err != nil {
+		t.Fatalf("Failed to list install dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Unexpected entries left in install dir: %v", entries)
+	}
+	issues, err := CheckPriorInstallation(installDir)
+	if err != nil {
+		t.Fatalf("Failed to check prior installation: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("Unexpected issues after clean: %q", issues)
+	}
+}
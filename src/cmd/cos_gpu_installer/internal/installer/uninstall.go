@@ -0,0 +1,62 @@
+package installer
+
+import (
+	stderrors "errors"
+	"fmt"
+	"path/filepath"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/modules"
+)
+
+// ErrDriverInUse indicates that the GPU driver could not be uninstalled
+// because its kernel modules are in use.
+var ErrDriverInUse = stderrors.New("GPU driver is in use")
+
+// These are variables so tests can uninstall a fake driver installation.
+var (
+	unloadModule           = modules.UnloadModule
+	removeHostLdCacheEntry = modules.RemoveHostLdCacheEntry
+)
+
+// gpuModuleUnloadOrder lists the GPU kernel modules in reverse dependency
+// order: nvidia_drm depends on nvidia_modeset, and nvidia_modeset and
+// nvidia_uvm depend on nvidia.
+var gpuModuleUnloadOrder = []string{"nvidia_drm", "nvidia_modeset", "nvidia_uvm", "nvidia"}
+
+// UninstallDriver reverts a GPU driver installation in gpuInstallDirHost, a
+// path on the host whose root is mounted at hostRootDir. It unloads the GPU
+// kernel modules, unmounts the installation mounts, removes the contents of
+// the installation directory and removes its libraries from the host's ld
+// cache.
+//
+// If a module is in use, UninstallDriver fails with ErrDriverInUse and leaves
+// the installation in place, so that it can be run again once the GPU
+// workloads have stopped. Modules unloaded before that stay unloaded.
+// Nothing is unloaded or removed if gpuInstallDirHost is rejected by
+// CheckCleanDir.
+func UninstallDriver(hostRootDir, gpuInstallDirHost string) error {
+	installDir := filepath.Join(hostRootDir, gpuInstallDirHost)
+	if err := CheckCleanDir(hostRootDir, installDir); err != nil {
+		return err
+	}
+	log.Info("Uninstalling GPU driver")
+	for _, moduleName := range gpuModuleUnloadOrder {
+		if err := unloadModule(moduleName); err != nil {
+			if stderrors.Is(err, modules.ErrModuleInUse) {
+				return fmt.Errorf("%w: %v", ErrDriverInUse, err)
+			}
+			return errors.Wrapf(err, "failed to unload module %s", moduleName)
+		}
+	}
+	if err := CleanPriorInstallation(installDir); err != nil {
+		return errors.Wrap(err, "failed to remove driver installation")
+	}
+	if err := removeHostLdCacheEntry(hostRootDir, filepath.Join(gpuInstallDirHost, "lib64")); err != nil {
+		return errors.Wrap(err, "failed to revert host ld cache")
+	}
+	log.Info("Successfully uninstalled GPU driver")
+	return nil
+}
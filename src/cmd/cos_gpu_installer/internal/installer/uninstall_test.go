@@ -0,0 +1,110 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/modules"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUninstallDriver(t *testing.T) {
+	origMountinfoPath := mountinfoPath
+	origUnloadModule, origUnmount, origRemoveHostLdCacheEntry := unloadModule, unmount, removeHostLdCacheEntry
+	defer func() {
+		mountinfoPath = origMountinfoPath
+		unloadModule, unmount, removeHostLdCacheEntry = origUnloadModule, origUnmount, origRemoveHostLdCacheEntry
+	}()
+
+	for _, tc := range []struct {
+		testName       string
+		hostInstallDir string
+		moduleInUse    string
+		expectInUse    bool
+		expectRejected bool
+		expectSequence []string
+	}{
+		{
+			testName: "DriverUnused",
+			expectSequence: []string{
+				"rmmod nvidia_drm", "rmmod nvidia_modeset", "rmmod nvidia_uvm", "rmmod nvidia",
+				"umount /usr/bin", "umount /usr/local/nvidia",
+				"ldconfig remove /var/lib/nvidia/lib64",
+			},
+		},
+		{
+			testName:       "DriverInUse",
+			moduleInUse:    "nvidia_uvm",
+			expectInUse:    true,
+			expectSequence: []string{"rmmod nvidia_drm", "rmmod nvidia_modeset", "rmmod nvidia_uvm"},
+		},
+		{
+			testName:       "HostRoot",
+			hostInstallDir: "/",
+			expectRejected: true,
+		},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			hostRootDir, err := ioutil.TempDir("", "testing")
+			if err != nil {
+				t.Fatalf("Failed to create tempdir: %v", err)
+			}
+			defer os.RemoveAll(hostRootDir)
+			mountinfoPath = filepath.Join(hostRootDir, "mountinfo")
+			if err := ioutil.WriteFile(mountinfoPath, []byte(testStaleMountinfo), 0644); err != nil {
+				t.Fatalf("Failed to create mountinfo: %v", err)
+			}
+			installDir := filepath.Join(hostRootDir, "var/lib/nvidia")
+			writeTestFiles(t, installDir, []string{"drivers/nvidia.ko", "bin/nvidia-smi", "lib64/libnvidia-ml.so.1"})
+
+			var sequence []string
+			unloadModule = func(moduleName string) error {
+				sequence = append(sequence, "rmmod "+moduleName)
+				if moduleName == tc.moduleInUse {
+					return fmt.Errorf("failed to unload module %s: %w", moduleName, modules.ErrModuleInUse)
+				}
+				return nil
+			}
+			unmount = func(target string, flags int) error {
+				sequence = append(sequence, "umount "+target)
+				return nil
+			}
+			removeHostLdCacheEntry = func(hostRoot, moduleLibDir string) error {
+				if hostRoot != hostRootDir {
+					t.Errorf("Unexpected host root dir: want %s, got %s", hostRootDir, hostRoot)
+				}
+				sequence = append(sequence, "ldconfig remove "+moduleLibDir)
+				return nil
+			}
+
+			hostInstallDir := tc.hostInstallDir
+			if hostInstallDir == "" {
+				hostInstallDir = "/var/lib/nvidia"
+			}
+			err = UninstallDriver(hostRootDir, hostInstallDir)
+			if gotRejected := err != nil && !errors.Is(err, ErrDriverInUse); gotRejected != tc.expectRejected {
+				t.Fatalf("UninstallDriver(%q): expect rejected: %v, got: %v", hostInstallDir, tc.expectRejected, err)
+			}
+			if gotInUse := errors.Is(err, ErrDriverInUse); gotInUse != tc.expectInUse {
+				t.Errorf("UninstallDriver(): expect ErrDriverInUse: %v, got: %v", tc.expectInUse, err)
+			}
+			if !tc.expectInUse && !tc.expectRejected && err != nil {
+				t.Fatalf("UninstallDriver() failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.expectSequence, sequence); diff != "" {
+				t.Errorf("Unexpected uninstall sequence (-want +got):\n%s", diff)
+			}
+			entries, err := ioutil.ReadDir(installDir)
+			if err != nil {
+				t.Fatalf("Failed to list install dir: %v", err)
+			}
+			if gotRemoved := len(entries) == 0; gotRemoved == (tc.expectInUse || tc.expectRejected) {
+				t.Errorf("Unexpected install dir contents after uninstall: %v", entries)
+			}
+		})
+	}
+}
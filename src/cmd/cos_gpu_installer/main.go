@@ -21,12 +21,15 @@ func main() {
 	log.V(2).Info("Checking if this is the only cos_gpu_installer that is running.")
 	f := utils.Flock()
 	defer f.Close()
+	utils.SetDownloadProgress(utils.NewProgressReporter(os.Stdout))
 
 	subcommands.Register(subcommands.HelpCommand(), "")
 	subcommands.Register(subcommands.FlagsCommand(), "")
 	subcommands.Register(subcommands.CommandsCommand(), "")
 	subcommands.Register(&commands.InstallCommand{}, "")
 	subcommands.Register(&commands.ListCommand{}, "")
+	subcommands.Register(&commands.DownloadCommand{}, "")
+	subcommands.Register(&commands.UninstallCommand{}, "")
 
 	ctx := context.Background()
 	os.Exit(int(subcommands.Execute(ctx)))
@@ -0,0 +1,75 @@
+// cuda_probe is a minimal GPU compute sanity check. It loads the CUDA driver
+// library at runtime, creates a context on the first device and allocates and
+// frees a small amount of device memory. It exits non-zero on any failure.
+//
+// It is built without CUDA headers so it can be bundled in the installer
+// image independently of the installed driver version.
+#include <dlfcn.h>
+#include <stddef.h>
+#include <stdio.h>
+
+typedef int CUresult;
+typedef int CUdevice;
+typedef void *CUcontext;
+typedef unsigned long long CUdeviceptr;
+
+#define PROBE_ALLOC_SIZE (1 << 20)
+
+static void *load(void *lib, const char *name) {
+  void *sym = dlsym(lib, name);
+  if (sym == NULL) {
+    fprintf(stderr, "cuda_probe: missing symbol %s\n", name);
+  }
+  return sym;
+}
+
+int main(void) {
+  void *lib = dlopen("libcuda.so.1", RTLD_NOW);
+  if (lib == NULL) {
+    fprintf(stderr, "cuda_probe: failed to load libcuda.so.1: %s\n", dlerror());
+    return 1;
+  }
+
+  CUresult (*cuInit)(unsigned int) = load(lib, "cuInit");
+  CUresult (*cuDeviceGet)(CUdevice *, int) = load(lib, "cuDeviceGet");
+  CUresult (*cuCtxCreate)(CUcontext *, unsigned int, CUdevice) = load(lib, "cuCtxCreate_v2");
+  CUresult (*cuMemAlloc)(CUdeviceptr *, size_t) = load(lib, "cuMemAlloc_v2");
+  CUresult (*cuMemFree)(CUdeviceptr) = load(lib, "cuMemFree_v2");
+  CUresult (*cuCtxDestroy)(CUcontext) = load(lib, "cuCtxDestroy_v2");
+  if (!cuInit || !cuDeviceGet || !cuCtxCreate || !cuMemAlloc || !cuMemFree || !cuCtxDestroy) {
+    return 1;
+  }
+
+  CUresult res;
+  CUdevice dev;
+  CUcontext ctx;
+  CUdeviceptr ptr;
+  if ((res = cuInit(0)) != 0) {
+    fprintf(stderr, "cuda_probe: cuInit failed: %d\n", res);
+    return 1;
+  }
+  if ((res = cuDeviceGet(&dev, 0)) != 0) {
+    fprintf(stderr, "cuda_probe: cuDeviceGet failed: %d\n", res);
+    return 1;
+  }
+  if ((res = cuCtxCreate(&ctx, 0, dev)) != 0) {
+    fprintf(stderr, "cuda_probe: cuCtxCreate failed: %d\n", res);
+    return 1;
+  }
+  if ((res = cuMemAlloc(&ptr, PROBE_ALLOC_SIZE)) != 0) {
+    fprintf(stderr, "cuda_probe: cuMemAlloc failed: %d\n", res);
+    cuCtxDestroy(ctx);
+    return 1;
+  }
+  if ((res = cuMemFree(ptr)) != 0) {
+    fprintf(stderr, "cuda_probe: cuMemFree failed: %d\n", res);
+    cuCtxDestroy(ctx);
+    return 1;
+  }
+  if ((res = cuCtxDestroy(ctx)) != 0) {
+    fprintf(stderr, "cuda_probe: cuCtxDestroy failed: %d\n", res);
+    return 1;
+  }
+  printf("cuda_probe: allocated and freed %d bytes of device memory\n", PROBE_ALLOC_SIZE);
+  return 0;
+}
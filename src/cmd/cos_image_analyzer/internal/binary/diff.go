@@ -22,6 +22,14 @@ var (
 	etcOSRelease = "/etc/os-release"
 )
 
+// KernelParameterDiff holds the values of a kernel command line parameter in
+// two images. A nil value means the parameter is not set in the image, and an
+// empty value means the parameter is set without a value, ex. "rootwait".
+type KernelParameterDiff struct {
+	Image1 *string `json:"image1"`
+	Image2 *string `json:"image2"`
+}
+
 // Differences is a intermediate Struct used to store all binary differences
 // Field names are pre-defined in parse_input.go and will be cross-checked with -binary flag.
 type Differences struct {
@@ -32,8 +40,13 @@ type Differences struct {
 	Stateful           string
 	PartitionStructure string
 	KernelConfigs      string
-	KernelCommandLine  map[string]string
-	SysctlSettings     string
+	// KernelCommandLine holds the terminal output of the kernel command line
+	// difference, and KernelCommandLineParameters the same difference keyed by
+	// parameter for the JSON output.
+	KernelCommandLine           map[string]string              `json:"-"`
+	KernelCommandLineParameters map[string]KernelParameterDiff `json:"KernelCommandLine"`
+	SysctlSettings              string
+	SecurityScan                string
 }
 
 // versionDiff calculates the Version difference of two images
@@ -66,6 +79,10 @@ func (d *Differences) osConfigDiff(image1, image2 *input.ImageInfo, flagInfo *in
 	if err != nil {
 		return fmt.Errorf("failed to find OS Configs: %v", err)
 	}
+	var diffFlags []string
+	if flagInfo.NormalizeWhitespace {
+		diffFlags = normalizeWhitespaceFlags
+	}
 	output := make(map[string]string)
 	for etcEntryName, img := range mapOfEtcEntries {
 		etcEntryPath := filepath.Join(etc, etcEntryName) + "/"
@@ -74,7 +91,7 @@ func (d *Differences) osConfigDiff(image1, image2 *input.ImageInfo, flagInfo *in
 			if img != "" { // Unique /etc entry in Image 1 or Image2
 				output[etcEntryPath] += "Only in " + img + "/rootfs/etc: " + etcEntryName
 			} else { // Shared /etc entry in Image 1 and Image 2
-				osConfigDiff, err := pureDiff(filepath.Join(image1.RootfsPartition3, etcEntryPath), filepath.Join(image2.RootfsPartition3, etcEntryPath))
+				osConfigDiff, err := pureDiff(filepath.Join(image1.RootfsPartition3, etcEntryPath), filepath.Join(image2.RootfsPartition3, etcEntryPath), diffFlags...)
 				if err != nil {
 					return fmt.Errorf("fail to take \"diff -r --no-dereference\" on %v: %v", etcEntryPath, err)
 				}
@@ -149,10 +166,19 @@ func (d *Differences) kernelConfigsDiff(image1, image2 *input.ImageInfo) error {
 // kernelCommandLineDiff calculates the kernel commad line difference of two images
 func (d *Differences) kernelCommandLineDiff(image1, image2 *input.ImageInfo) error {
 	output := make(map[string]string)
+	parameters := make(map[string]KernelParameterDiff)
+	mapImage1 := getKclMap(strings.Fields(image1.KernelCommandLine))
 	if image2.TempDir != "" {
-		mapImage1 := getKclMap(strings.Fields(image1.KernelCommandLine))
 		mapImage2 := getKclMap(strings.Fields(image2.KernelCommandLine))
-
+		// Parameters set in either image whose values differ, for the JSON output
+		for _, kclMap := range []map[string]string{mapImage1, mapImage2} {
+			for key := range kclMap {
+				value1, value2 := kclValue(mapImage1, key), kclValue(mapImage2, key)
+				if value1 == nil || value2 == nil || *value1 != *value2 {
+					parameters[key] = KernelParameterDiff{Image1: value1, Image2: value2}
+				}
+			}
+		}
 		for key1, value1 := range mapImage1 {
 			if value2, ok := mapImage2[key1]; !ok { // Unique KCL parameter in image1
 				if value1 != "" {
@@ -175,11 +201,25 @@ func (d *Differences) kernelCommandLineDiff(image1, image2 *input.ImageInfo) err
 		}
 	} else {
 		output["Image1 KCL"] = image1.KernelCommandLine
+		for key := range mapImage1 {
+			parameters[key] = KernelParameterDiff{Image1: kclValue(mapImage1, key)}
+		}
 	}
 	d.KernelCommandLine = output
+	d.KernelCommandLineParameters = parameters
 	return nil
 }
 
+// kclValue returns the value of a kernel command line parameter in a map
+// returned by getKclMap, or nil if the parameter is not set.
+func kclValue(kclMap map[string]string, key string) *string {
+	value, ok := kclMap[key]
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
 // sysctlSettingsDiff calculates the sysctl Settings difference of two images
 func (d *Differences) sysctlSettingsDiff(image1, image2 *input.ImageInfo) error {
 	if image2.TempDir != "" {
@@ -298,6 +338,14 @@ func (d *Differences) FormatSysctlSettingsDiff() string {
 	return ""
 }
 
+// FormatSecurityScanDiff returns a formated string of the Security-scan difference
+func (d *Differences) FormatSecurityScanDiff() string {
+	if d.SecurityScan != "" {
+		return "----------Security Scan----------\n" + d.SecurityScan + "\n\n"
+	}
+	return ""
+}
+
 // Diff is a tool that finds all binary differences of two COS images
 // (COS version, rootfs, kernel command line, stateful partition, ...)
 // Input:
@@ -336,6 +384,11 @@ func Diff(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) (*Differenc
 			return BinaryDiff, fmt.Errorf("failed to get Sysctl-settings difference: %v", err)
 		}
 	}
+	if utilities.InArray("Security-scan", flagInfo.BinaryTypesSelected) {
+		if err := BinaryDiff.securityScanDiff(image1, image2); err != nil {
+			return BinaryDiff, fmt.Errorf("failed to get Security-scan difference: %v", err)
+		}
+	}
 
 	if image2.TempDir != "" {
 		if utilities.InArray("Rootfs", flagInfo.BinaryTypesSelected) {
@@ -1,6 +1,10 @@
 package binary
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
 	"testing"
 
 	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
@@ -38,6 +42,15 @@ diff -r --no-dereference ../testdata/image1/rootfs/etc/docker/credentials.txt ..
 Only in ../testdata/image1/rootfs/etc/docker/util: docker.txt
 Only in ../testdata/image1/rootfs/etc/docker/util: lib32
 Only in ../testdata/image2/rootfs/etc/docker/util: lib64`,
+		"/etc/motd/": `Configs for file /etc/motd/
+1,2c1,3
+< Welcome to Container-Optimized OS   
+< Documentation: https://cloud.google.com/container-optimized-os/docs
+\ No newline at end of file
+---
+> Welcome to Container-Optimized OS
+> Documentation: https://cloud.google.com/container-optimized-os/docs
+> `,
 		"/etc/os-release/": `Configs for file /etc/os-release/
 1c1
 < BUILD_ID=12871.119.0
@@ -86,6 +99,26 @@ Only in ../testdata/image1/rootfs/etc/docker/util: docker.txt
 Only in ../testdata/image1/rootfs/etc/docker/util: lib32
 Only in ../testdata/image2/rootfs/etc/docker/util: lib64`}
 
+	// OS Config test data with normalized whitespace. /etc/motd only differs by
+	// whitespace, so only the content changes of /etc/sysctl.d are shown.
+	testNormalizedOSConfig := map[string]string{
+		"/etc/motd/": "",
+		"/etc/sysctl.d/": `Configs for directory /etc/sysctl.d/
+diff -r --no-dereference --ignore-trailing-space --ignore-blank-lines ../testdata/image1/rootfs/etc/sysctl.d/00-sysctl.conf ../testdata/image2/rootfs/etc/sysctl.d/00-sysctl.conf
+8c8
+< net.ipv4.conf.all.rp_filter = 1
+---
+> net.ipv4.conf.all.rp_filter = 2
+11c11,14
+< net.ipv4.tcp_slow_start_after_idle = 0
+\ No newline at end of file
+---
+> net.ipv4.tcp_slow_start_after_idle = 1
+> 
+> # dumby variable
+> net.ipv4.conf = 2
+\ No newline at end of file`}
+
 	// Stateful test data
 	testVerboseStatefulDiff := `Only in ../testdata/image1/stateful/dev_image: image1_dev.txt
 Only in ../testdata/image2/stateful/dev_image: image2_dev.txt
@@ -247,6 +280,10 @@ net.ipv4.tcp_slow_start_after_idle = 0`
 			Image2:   &input.ImageInfo{TempDir: "../testdata/image2", RootfsPartition3: "../testdata/image2/rootfs/"},
 			FlagInfo: &input.FlagInfo{BinaryTypesSelected: []string{"OS-config"}, Verbose: false, CompressRootfsSlice: testCompressRootfsSlice},
 			want:     &Differences{OSConfigs: testBriefOSConfig}},
+		{Image1: &input.ImageInfo{TempDir: "../testdata/image1", RootfsPartition3: "../testdata/image1/rootfs/"},
+			Image2:   &input.ImageInfo{TempDir: "../testdata/image2", RootfsPartition3: "../testdata/image2/rootfs/"},
+			FlagInfo: &input.FlagInfo{BinaryTypesSelected: []string{"OS-config"}, Verbose: true, NormalizeWhitespace: true, CompressRootfsSlice: testCompressRootfsSlice},
+			want:     &Differences{OSConfigs: testNormalizedOSConfig}},
 
 		// Stateful difference test
 		{Image1: &input.ImageInfo{TempDir: "../testdata/image1", StatePartition1: "../testdata/image1/stateful/"},
@@ -333,3 +370,48 @@ net.ipv4.tcp_slow_start_after_idle = 0`
 		}
 	}
 }
+
+// test the JSON output of the kernel command line difference
+func TestKernelCommandLineJSON(t *testing.T) {
+	kclImage1 := `linux /syslinux/vmlinuz.A init=/usr/lib/systemd/systemd boot=local rootwait ro dm_verity.dev_wait=50`
+	kclImage2 := `linux /syslinux/vmlinuz.A init=/usr/lib32/systemd/ ro dm_verity.dev_wait=1      i915.modeset=1 cros_efi`
+	for _, tc := range []struct {
+		name   string
+		image1 *input.ImageInfo
+		image2 *input.ImageInfo
+		golden string
+	}{
+		{name: "one image",
+			image1: &input.ImageInfo{TempDir: "../testdata/image1", KernelCommandLine: kclImage1},
+			image2: &input.ImageInfo{},
+			golden: "../testdata/kcl_image1.json"},
+		{name: "two images",
+			image1: &input.ImageInfo{TempDir: "../testdata/image1", KernelCommandLine: kclImage1},
+			image2: &input.ImageInfo{TempDir: "../testdata/image2", KernelCommandLine: kclImage2},
+			golden: "../testdata/kcl_diff.json"},
+	} {
+		d := &Differences{}
+		if err := d.kernelCommandLineDiff(tc.image1, tc.image2); err != nil {
+			t.Fatalf("kernelCommandLineDiff for %v failed: %v", tc.name, err)
+		}
+		diffJSON, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("failed to json marshal the differences for %v: %v", tc.name, err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(diffJSON, &fields); err != nil {
+			t.Fatalf("failed to json unmarshal the differences for %v: %v", tc.name, err)
+		}
+		var got bytes.Buffer
+		if err := json.Indent(&got, fields["KernelCommandLine"], "", "  "); err != nil {
+			t.Fatalf("failed to indent the kernel command line JSON for %v: %v", tc.name, err)
+		}
+		want, err := ioutil.ReadFile(tc.golden)
+		if err != nil {
+			t.Fatalf("failed to read golden file %v: %v", tc.golden, err)
+		}
+		if got.String() != strings.TrimSuffix(string(want), "\n") {
+			t.Fatalf("kernel command line JSON for %v expected:\n%v\ngot:\n%v", tc.name, string(want), got.String())
+		}
+	}
+}
@@ -209,9 +209,16 @@ func directoryDiff(dir1, dir2, root string, verbose bool, compressedDirs []strin
 	return compressedDiffStr, nil
 }
 
-// pureDiff returns the output of a normal diff between two files or directories
-func pureDiff(input1, input2 string) (string, error) {
-	diff, err := exec.Command("sudo", "diff", "-r", "--no-dereference", input1, input2).Output()
+// normalizeWhitespaceFlags are the "diff" flags that ignore cosmetic changes:
+// trailing whitespace, a missing newline at the end of a file and blank lines.
+var normalizeWhitespaceFlags = []string{"--ignore-trailing-space", "--ignore-blank-lines"}
+
+// pureDiff returns the output of a normal diff between two files or directories.
+// diffFlags are passed to the "diff" command in addition to the default flags.
+func pureDiff(input1, input2 string, diffFlags ...string) (string, error) {
+	args := append([]string{"diff", "-r", "--no-dereference"}, diffFlags...)
+	args = append(args, input1, input2)
+	diff, err := exec.Command("sudo", args...).Output()
 	if exitError, ok := err.(*exec.ExitError); ok {
 		if exitError.ExitCode() == 2 {
 			return "", fmt.Errorf("failed to call 'diff' on %v and %v: %v", input1, input2, err)
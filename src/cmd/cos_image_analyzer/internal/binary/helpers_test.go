@@ -44,16 +44,29 @@ func TestPureDiff(t *testing.T) {
 < These are not the configs you are looking for
 ---
 > These are the configs you are looking for`
+	testOutput3 := `1,2c1,3
+< Welcome to Container-Optimized OS   
+< Documentation: https://cloud.google.com/container-optimized-os/docs
+\ No newline at end of file
+---
+> Welcome to Container-Optimized OS
+> Documentation: https://cloud.google.com/container-optimized-os/docs
+> `
 	for _, tc := range []struct {
-		input1 string
-		input2 string
-		want   string
+		input1    string
+		input2    string
+		diffFlags []string
+		want      string
 	}{
 		{input1: "../testdata/image1/rootfs/proc/security/access.conf", input2: "../testdata/image2/rootfs/proc/security/access.conf", want: testOutput1},
 		{input1: "../testdata/image1/rootfs/proc/security/configs", input2: "../testdata/image2/rootfs/proc/security/configs", want: testOutput2},
 		{input1: "../testdata/image1/rootfs/proc/security/lib-image1", input2: "../testdata/image2/rootfs/proc/security/lib-image2", want: ""},
+		// Whitespace only differences
+		{input1: "../testdata/image1/rootfs/etc/motd", input2: "../testdata/image2/rootfs/etc/motd", want: testOutput3},
+		{input1: "../testdata/image1/rootfs/etc/motd", input2: "../testdata/image2/rootfs/etc/motd", diffFlags: normalizeWhitespaceFlags, want: ""},
+		{input1: "../testdata/image1/rootfs/proc/security/configs", input2: "../testdata/image2/rootfs/proc/security/configs", diffFlags: normalizeWhitespaceFlags, want: testOutput2},
 	} {
-		got, _ := pureDiff(tc.input1, tc.input2)
+		got, _ := pureDiff(tc.input1, tc.input2, tc.diffFlags...)
 		if got != tc.want {
 			t.Fatalf("PureDiff expected:\n%v\ngot:\n%v", tc.want, got)
 		}
@@ -0,0 +1,92 @@
+package binary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/utilities"
+)
+
+// fileHash returns the hex encoded sha256 hash of the contents of a file
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// rootfsManifest returns a "path sha256" line for every regular file under
+// the rootfs directory, sorted by path. Paths are absolute paths in the
+// image, ex. "/etc/os-release". Directories, symlinks and special files are
+// not listed, and symlinks are not followed.
+// Input:
+//   (string) rootfs - Path to the mounted Rootfs partition
+// Output:
+//   ([]string) lines - Manifest lines, one per regular file
+func rootfsManifest(rootfs string) ([]string, error) {
+	lines := []string{}
+	err := filepath.Walk(rootfs, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(rootfs, path)
+		if err != nil {
+			return err
+		}
+		hash, err := fileHash(path)
+		if err != nil {
+			return fmt.Errorf("failed to hash file %v: %v", path, err)
+		}
+		lines = append(lines, "/"+filepath.ToSlash(rel)+" "+hash)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory %v: %v", rootfs, err)
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+// WriteRootfsManifest writes the content manifest of an image's Rootfs
+// partition to outputFile. Each line of the manifest holds the path of a
+// regular file in the image and the sha256 hash of its contents, separated
+// by a space and sorted by path. Since paths may contain spaces, the hash is
+// the last field of the line. Manifests of different images can be compared
+// with "diff" without the original images.
+// Input:
+//   (string) rootfs - Path to the mounted Rootfs partition
+//   (string) outputFile - Path of the manifest file to create
+// Output: nil on success, else error
+func WriteRootfsManifest(rootfs, outputFile string) error {
+	if rootfs == "" {
+		return errors.New("Error: Rootfs partition is not mounted")
+	}
+	lines, err := rootfsManifest(rootfs)
+	if err != nil {
+		return err
+	}
+	manifest := ""
+	if len(lines) > 0 {
+		manifest = strings.Join(lines, "\n") + "\n"
+	}
+	if err := utilities.WriteToNewFile(outputFile, manifest); err != nil {
+		return fmt.Errorf("failed to write Rootfs manifest to %v: %v", outputFile, err)
+	}
+	return nil
+}
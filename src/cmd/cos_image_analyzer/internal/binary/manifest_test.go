@@ -0,0 +1,107 @@
+package binary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sha256Hex returns the hex encoded sha256 hash of data
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// makeRootfs creates a small synthetic rootfs tree in a temporary directory
+func makeRootfs(t *testing.T) string {
+	rootfs := t.TempDir()
+	files := map[string]string{
+		"etc/os-release":           "NAME=\"Container-Optimized OS\"\n",
+		"etc/ssh/sshd_config":      "PermitRootLogin no\n",
+		"usr/bin/toolbox":          "#!/bin/bash\n",
+		"usr/share/empty file.txt": "",
+	}
+	for path, contents := range files {
+		fullPath := filepath.Join(rootfs, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory for %v: %v", path, err)
+		}
+		if err := ioutil.WriteFile(fullPath, []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write %v: %v", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(rootfs, "var", "empty"), 0755); err != nil {
+		t.Fatalf("failed to create empty directory: %v", err)
+	}
+	// Symlinks are not listed, even if they point to a file in the image
+	if err := os.Symlink("/etc/os-release", filepath.Join(rootfs, "usr", "os-release")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	return rootfs
+}
+
+// test rootfsManifest function
+func TestRootfsManifest(t *testing.T) {
+	rootfs := makeRootfs(t)
+	want := []string{
+		"/etc/os-release " + sha256Hex("NAME=\"Container-Optimized OS\"\n"),
+		"/etc/ssh/sshd_config " + sha256Hex("PermitRootLogin no\n"),
+		"/usr/bin/toolbox " + sha256Hex("#!/bin/bash\n"),
+		"/usr/share/empty file.txt " + sha256Hex(""),
+	}
+	for _, tc := range []struct {
+		rootfs  string
+		want    []string
+		wantErr bool
+	}{
+		{rootfs: rootfs, want: want},
+		{rootfs: filepath.Join(rootfs, "var", "empty"), want: []string{}},
+		{rootfs: filepath.Join(rootfs, "DOESNOTEXIST"), wantErr: true},
+	} {
+		got, err := rootfsManifest(tc.rootfs)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("rootfsManifest(%v) expected error but none returned", tc.rootfs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("rootfsManifest(%v) unexpected error: %v", tc.rootfs, err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("rootfsManifest(%v) expected:\n%v\ngot:\n%v", tc.rootfs, tc.want, got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("rootfsManifest(%v) expected:\n%v\ngot:\n%v", tc.rootfs, tc.want, got)
+			}
+		}
+	}
+}
+
+// test WriteRootfsManifest function
+func TestWriteRootfsManifest(t *testing.T) {
+	rootfs := makeRootfs(t)
+	outputFile := filepath.Join(t.TempDir(), "manifest.txt")
+	if err := WriteRootfsManifest(rootfs, outputFile); err != nil {
+		t.Fatalf("WriteRootfsManifest unexpected error: %v", err)
+	}
+	got, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("failed to read manifest %v: %v", outputFile, err)
+	}
+	want := "/etc/os-release " + sha256Hex("NAME=\"Container-Optimized OS\"\n") + "\n" +
+		"/etc/ssh/sshd_config " + sha256Hex("PermitRootLogin no\n") + "\n" +
+		"/usr/bin/toolbox " + sha256Hex("#!/bin/bash\n") + "\n" +
+		"/usr/share/empty file.txt " + sha256Hex("") + "\n"
+	if string(got) != want {
+		t.Fatalf("WriteRootfsManifest expected:\n%v\ngot:\n%v", want, string(got))
+	}
+
+	if err := WriteRootfsManifest("", outputFile); err == nil {
+		t.Fatalf("WriteRootfsManifest expected error for unmounted Rootfs but none returned")
+	}
+}
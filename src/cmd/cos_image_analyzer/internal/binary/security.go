@@ -0,0 +1,106 @@
+package binary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
+)
+
+// securityFlags returns the security relevant permission bits of a file,
+// ex. "setuid,world-writable", or "" if it has none. Symlinks are ignored
+// since their permissions are not used, and world-writable directories with
+// the sticky bit set, ex. /tmp, are expected and not reported.
+func securityFlags(mode os.FileMode) string {
+	if mode&os.ModeSymlink != 0 {
+		return ""
+	}
+	var flags []string
+	if mode&os.ModeSetuid != 0 {
+		flags = append(flags, "setuid")
+	}
+	if mode&os.ModeSetgid != 0 {
+		flags = append(flags, "setgid")
+	}
+	if mode.Perm()&0002 != 0 && !(mode.IsDir() && mode&os.ModeSticky != 0) {
+		flags = append(flags, "world-writable")
+	}
+	return strings.Join(flags, ",")
+}
+
+// securityScan lists the files under the rootfs directory with the setuid
+// or setgid bits set or with world-writable permissions, sorted by path.
+// Each line holds the absolute path of the file in the image followed by
+// its flags, ex. "/usr/bin/sudo (setuid)".
+// Input:
+//   (string) rootfs - Path to the mounted Rootfs partition
+// Output:
+//   ([]string) lines - One line per flagged file
+func securityScan(rootfs string) ([]string, error) {
+	lines := []string{}
+	err := filepath.Walk(rootfs, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		flags := securityFlags(info.Mode())
+		if flags == "" {
+			return nil
+		}
+		rel, err := filepath.Rel(rootfs, path)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, "/"+filepath.ToSlash(rel)+" ("+flags+")")
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory %v: %v", rootfs, err)
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+// securityScanDiff calculates the Security-scan difference of two images.
+// For one image, all flagged files are listed. For two images, flagged files
+// only in image 1 are prefixed with "<" and flagged files only in image 2 are
+// prefixed with ">". A file whose flags changed is listed twice: with its
+// flags in image 1 prefixed with "<" and with its flags in image 2 prefixed
+// with ">".
+func (d *Differences) securityScanDiff(image1, image2 *input.ImageInfo) error {
+	image1Files, err := securityScan(image1.RootfsPartition3)
+	if err != nil {
+		return fmt.Errorf("failed to scan Rootfs partition %v: %v", image1.RootfsPartition3, err)
+	}
+	if image2.TempDir == "" {
+		d.SecurityScan = strings.Join(image1Files, "\n")
+		return nil
+	}
+	image2Files, err := securityScan(image2.RootfsPartition3)
+	if err != nil {
+		return fmt.Errorf("failed to scan Rootfs partition %v: %v", image2.RootfsPartition3, err)
+	}
+	inImage1 := make(map[string]bool)
+	for _, file := range image1Files {
+		inImage1[file] = true
+	}
+	inImage2 := make(map[string]bool)
+	for _, file := range image2Files {
+		inImage2[file] = true
+	}
+	var lines []string
+	for _, file := range image1Files {
+		if !inImage2[file] {
+			lines = append(lines, "< "+file)
+		}
+	}
+	for _, file := range image2Files {
+		if !inImage1[file] {
+			lines = append(lines, "> "+file)
+		}
+	}
+	d.SecurityScan = strings.Join(lines, "\n")
+	return nil
+}
@@ -0,0 +1,161 @@
+package binary
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/cmd/cos_image_analyzer/internal/input"
+)
+
+// securityRootfs copies the rootfs directory of a security testdata image to
+// a temporary directory and applies the file modes listed in its modes.txt
+// file, since git does not preserve setuid, setgid and write permissions.
+func securityRootfs(t *testing.T, image string) string {
+	src := filepath.Join("..", "testdata", "security", image, "rootfs")
+	rootfs := t.TempDir()
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(rootfs, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dst, data, 0644)
+	})
+	if err != nil {
+		t.Fatalf("failed to copy %v: %v", src, err)
+	}
+
+	modesFile, err := os.Open(filepath.Join("..", "testdata", "security", image, "modes.txt"))
+	if err != nil {
+		t.Fatalf("failed to open modes file of %v: %v", image, err)
+	}
+	defer modesFile.Close()
+	scanner := bufio.NewScanner(modesFile)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		perm, err := strconv.ParseUint(fields[1], 8, 32)
+		if err != nil {
+			t.Fatalf("invalid mode %q of %v: %v", fields[1], fields[0], err)
+		}
+		mode := os.FileMode(perm & 0777)
+		if perm&04000 != 0 {
+			mode |= os.ModeSetuid
+		}
+		if perm&02000 != 0 {
+			mode |= os.ModeSetgid
+		}
+		if perm&01000 != 0 {
+			mode |= os.ModeSticky
+		}
+		if err := os.Chmod(filepath.Join(rootfs, fields[0]), mode); err != nil {
+			t.Fatalf("failed to chmod %v: %v", fields[0], err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read modes file of %v: %v", image, err)
+	}
+	return rootfs
+}
+
+// test securityFlags function
+func TestSecurityFlags(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mode os.FileMode
+		want string
+	}{
+		{name: "regular file", mode: 0755, want: ""},
+		{name: "setuid", mode: 0755 | os.ModeSetuid, want: "setuid"},
+		{name: "setgid", mode: 0755 | os.ModeSetgid, want: "setgid"},
+		{name: "setuid setgid", mode: 0755 | os.ModeSetuid | os.ModeSetgid, want: "setuid,setgid"},
+		{name: "world-writable file", mode: 0666, want: "world-writable"},
+		{name: "world-writable directory", mode: 0777 | os.ModeDir, want: "world-writable"},
+		{name: "sticky world-writable directory", mode: 0777 | os.ModeDir | os.ModeSticky, want: ""},
+		{name: "symlink", mode: 0777 | os.ModeSymlink, want: ""},
+	} {
+		if got := securityFlags(tc.mode); got != tc.want {
+			t.Fatalf("securityFlags(%v) for %v expected %q, got %q", tc.mode, tc.name, tc.want, got)
+		}
+	}
+}
+
+// test securityScan function
+func TestSecurityScan(t *testing.T) {
+	rootfs := securityRootfs(t, "image1")
+	want := []string{
+		"/etc/shared.conf (world-writable)",
+		"/usr/bin/sudo (setuid)",
+		"/usr/bin/wall (setgid)",
+	}
+	got, err := securityScan(rootfs)
+	if err != nil {
+		t.Fatalf("securityScan(%v) failed: %v", rootfs, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("securityScan(%v) expected %v, got %v", rootfs, want, got)
+	}
+}
+
+// test securityScanDiff function
+func TestSecurityScanDiff(t *testing.T) {
+	rootfs1 := securityRootfs(t, "image1")
+	rootfs2 := securityRootfs(t, "image2")
+	for _, tc := range []struct {
+		name   string
+		image1 *input.ImageInfo
+		image2 *input.ImageInfo
+		want   string
+	}{
+		{
+			name:   "one image",
+			image1: &input.ImageInfo{TempDir: "image1", RootfsPartition3: rootfs1},
+			image2: &input.ImageInfo{},
+			want: `/etc/shared.conf (world-writable)
+/usr/bin/sudo (setuid)
+/usr/bin/wall (setgid)`,
+		},
+		{
+			name:   "two images",
+			image1: &input.ImageInfo{TempDir: "image1", RootfsPartition3: rootfs1},
+			image2: &input.ImageInfo{TempDir: "image2", RootfsPartition3: rootfs2},
+			want: `< /usr/bin/sudo (setuid)
+< /usr/bin/wall (setgid)
+> /usr/bin/newgrp (setuid,setgid)
+> /usr/bin/sudo (setuid,setgid)
+> /var/log/debug.log (world-writable)`,
+		},
+		{
+			name:   "same image",
+			image1: &input.ImageInfo{TempDir: "image1", RootfsPartition3: rootfs1},
+			image2: &input.ImageInfo{TempDir: "image1", RootfsPartition3: rootfs1},
+			want:   "",
+		},
+	} {
+		d := &Differences{}
+		if err := d.securityScanDiff(tc.image1, tc.image2); err != nil {
+			t.Fatalf("securityScanDiff for %v failed: %v", tc.name, err)
+		}
+		if d.SecurityScan != tc.want {
+			t.Fatalf("securityScanDiff for %v expected:\n%v\ngot:\n%v", tc.name, tc.want, d.SecurityScan)
+		}
+	}
+}
@@ -31,6 +31,10 @@ type FlagInfo struct {
 	// 	For OS-configs difference, all /etc entries that are listed in CompressRootfsFile are ignored.
 	Verbose bool
 
+	// If true, trailing whitespace, missing newlines at the end of files and blank
+	// lines are ignored when comparing OS-config files, so that only content changes are shown.
+	NormalizeWhitespace bool
+
 	// File used to compress directories in the output from Rootfs difference and
 	// for ignore entries under /etc for OS-Config difference
 	// (either user provided or default CompressRootfs.txt)
@@ -46,4 +50,16 @@ type FlagInfo struct {
 
 	// Output
 	OutputSelected string
+
+	// Partitions
+	// Comma separated list of partitions to mount from the "-mount-partitions" flag
+	MountPartitionsPtr string
+	// Partitions of the images to mount, among "1" (stateful), "3" (Rootfs-A) and "12" (EFI-System).
+	// Defaults to the partitions required by the selected binary types.
+	MountPartitions []string
+
+	// Rootfs manifest
+	// If set, instead of analyzing the image, a content manifest of the single
+	// image's Rootfs (path and sha256 of every regular file) is written to this file.
+	RootfsManifestFile string
 }
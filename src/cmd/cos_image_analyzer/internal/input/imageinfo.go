@@ -76,13 +76,13 @@ func (image *ImageInfo) Rename(flagInfo *FlagInfo) error {
 // MountImage is an ImagInfo method that mounts partitions 1,3 and 12 of
 // the image into the temporary directory
 // Input:
-//   (string) arr - List of binary types selected from the user
+//   ([]string) partitions - List of partitions to mount, ex. ["3", "12"]. See FlagInfo.MountPartitions
 // Output: nil on success, else error
-func (image *ImageInfo) MountImage(arr []string) error {
+func (image *ImageInfo) MountImage(partitions []string) error {
 	if image.TempDir == "" {
 		return nil
 	}
-	if utilities.InArray("Stateful-partition", arr) {
+	if utilities.InArray("1", partitions) {
 		stateful := filepath.Join(image.TempDir, "stateful")
 		if err := os.Mkdir(stateful, makeDirFilemode); err != nil {
 			return fmt.Errorf("failed to create make directory %v: %v", stateful, err)
@@ -96,7 +96,7 @@ func (image *ImageInfo) MountImage(arr []string) error {
 		image.LoopDevice1 = loopDevice1
 	}
 
-	if utilities.InArray("Version", arr) || utilities.InArray("BuildID", arr) || utilities.InArray("Rootfs", arr) || utilities.InArray("Sysctl-settings", arr) || utilities.InArray("OS-config", arr) || utilities.InArray("Kernel-configs", arr) {
+	if utilities.InArray("3", partitions) {
 		rootfs := filepath.Join(image.TempDir, "rootfs")
 		if err := os.Mkdir(rootfs, makeDirFilemode); err != nil {
 			return fmt.Errorf("failed to create make directory %v: %v", rootfs, err)
@@ -110,7 +110,7 @@ func (image *ImageInfo) MountImage(arr []string) error {
 		image.LoopDevice3 = loopDevice3
 	}
 
-	if utilities.InArray("Kernel-command-line", arr) {
+	if utilities.InArray("12", partitions) {
 		efi := filepath.Join(image.TempDir, "efi")
 		if err := os.Mkdir(efi, makeDirFilemode); err != nil {
 			return fmt.Errorf("failed to create make directory %v: %v", efi, err)
@@ -13,7 +13,17 @@ import (
 )
 
 // BinaryDiffTypes is a list of all valid binary differnce types
-var BinaryDiffTypes = []string{"Version", "BuildID", "Rootfs", "Kernel-command-line", "Stateful-partition", "Partition-structure", "Sysctl-settings", "OS-config", "Kernel-configs"}
+var BinaryDiffTypes = []string{"Version", "BuildID", "Rootfs", "Kernel-command-line", "Stateful-partition", "Partition-structure", "Sysctl-settings", "OS-config", "Kernel-configs", "Security-scan"}
+
+// Binary difference types that are not selected by default, they must be
+// listed in the "-binary" flag
+var optInBinaryDiffTypes = []string{"Security-scan"}
+
+// Partitions of a COS image that can be mounted, in mounting order
+var mountablePartitions = []string{"1", "3", "12"}
+
+// Binary types that require the Rootfs partition #3 to be mounted
+var rootfsBinaryTypes = []string{"Version", "BuildID", "Rootfs", "Sysctl-settings", "OS-config", "Kernel-configs", "Security-scan"}
 
 // Default Rootfs entires that are overridden by the "compress-rootfs" flag
 var defaultCompressRootfs = []string{"/bin/", "/lib/modules/", "/lib64/", "/usr/libexec/", "/usr/bin/", "/usr/sbin/", "/usr/lib64/", "/usr/share/zoneinfo/", "/usr/share/git/", "/usr/lib/", "/sbin/", "/etc/ssh/", "/etc/os-release/", "/etc/package_list/"}
@@ -34,6 +44,8 @@ SYNOPSIS
 
 	%s -local -binary=Sysctl-settings,OS-config -package=false image-cos-77-12371-273-0/disk.raw
 
+	%s -rootfs-manifest=manifest.txt image-cos-77-12371-273-0/disk.raw
+
 	%s -gcs GCS-PATH-1 [GCS-PATH-2]
 		GCS-PATH - the GCS "gs://bucket/object" path for the COS Image ("object" is type .tar.gz)
 		Ex: %s -gcs gs://my-bucket/cos-images/cos-77-12371-273-0.tar.gz gs://my-bucket/cos-images/cos-81-12871-119-0.tar.gz
@@ -55,9 +67,12 @@ DESCRIPTION
 	Difference Flags:
 	-binary (string)
 		specify which type of binary difference to show. Types "Version", "BuildID", "Kernel-command-line",
-		"Partition-structure", "Sysctl-settings", and "Kernel-configs" are supported for one and two image. "Rootfs",
-		"Stateful-partition", and "OS-config" are only supported for two images. To list multiple types separate by
-		comma. To NOT list any binary difference, set flag to "false". (default all types)
+		"Partition-structure", "Sysctl-settings", "Kernel-configs" and "Security-scan" are supported for one and two
+		image. "Rootfs", "Stateful-partition", and "OS-config" are only supported for two images. To list multiple types
+		separate by comma. To NOT list any binary difference, set flag to "false". (default all types but "Security-scan")
+		"Security-scan" lists the files of the Rootfs partition with the setuid or setgid bits set or with world-writable
+		permissions. It is only shown if listed in the flag. For two images, the files flagged only in one of the images
+		are listed, so a file whose flags changed is listed with its flags in both images.
 	-package
 		specify whether to show package difference. Shows addition/removal of packages and package version updates.
 		To NOT list any package difference, set flag to false. (default false)
@@ -66,6 +81,9 @@ DESCRIPTION
 	-verbose
 		include flag to increase verbosity of Rootfs, Stateful-partition, and OS-config differences. See -compress-rootfs and
 		-compress-stateful flags descriptions for the directories that are compressed by default.
+	-normalize-whitespace
+		include flag to ignore trailing whitespace, missing newlines at the end of files and blank lines in the
+		OS-config difference, so that only changes to the content of configuration files are shown.
 	-compress-rootfs (string)
 		to customize which directories are compressed in a non-verbose Rootfs and OS-config difference output, provide a local
 		file path to a .txt file. Format of the file must be one root file path per line with an ending back slash and no commas.
@@ -76,10 +94,23 @@ DESCRIPTION
 		to customize which directories are compressed in a non-verbose Stateful-partition difference output, provide a local
 		file path to a .txt file. Format of file must be one root file path per line with no commas. By default the directory(s)
 		that are compressed during a diff are /var_overlay/db/.
+	-mount-partitions (string)
+		for advanced use, such as debugging a specific partition, provide a comma separated list of the partitions to
+		mount among 1 (stateful), 3 (Rootfs-A) and 12 (EFI-System). The list must include the partitions required by
+		the selected binary types: 1 for "Stateful-partition", 12 for "Kernel-command-line" and 3 for the other types
+		except "Partition-structure". (default only the required partitions)
 
 	Output Flags:
 	-output (string)
 		Specify format of output. Only "terminal" stdout or "json" object is supported. (default "terminal")
+		In the "json" object, the Kernel-command-line difference maps each changed parameter to its values in both
+		images, ex. {"init": {"image1": "/usr/lib/systemd/systemd", "image2": null}}. A null value means the
+		parameter is not set in the image.
+	-rootfs-manifest (string)
+		instead of analyzing the image, write a content manifest of its Rootfs partition to the given local file path.
+		Each line holds the path of a regular file in the image and the sha256 hash of its contents separated by a
+		space, sorted by path. Manifests can be diffed against historical manifests without the original images.
+		Only supported for one image.
 
 OUTPUT
 	Based on the "-output" flag. Either "terminal" stdout or machine readable "json" format.
@@ -88,7 +119,7 @@ NOTE
 	The root permission is needed for this program because it needs to mount images into your local filesystem to calculate difference.
 `
 	cmd := filepath.Base(os.Args[0])
-	usage := fmt.Sprintf(usageTemplate, cmd, cmd, cmd, cmd, cmd)
+	usage := fmt.Sprintf(usageTemplate, cmd, cmd, cmd, cmd, cmd, cmd)
 	fmt.Printf("%s", usage)
 }
 
@@ -106,18 +137,11 @@ func FlagErrorChecking(flagInfo *FlagInfo) error {
 		flagInfo.LocalPtr = true
 	}
 
-	if flagInfo.BinaryDiffPtr == "" {
-		flagInfo.BinaryTypesSelected = BinaryDiffTypes
-	} else {
-		binaryTypesSelected := strings.Split(flagInfo.BinaryDiffPtr, ",")
-		for _, elem := range binaryTypesSelected {
-			if utilities.InArray(elem, BinaryDiffTypes) {
-				flagInfo.BinaryTypesSelected = append(flagInfo.BinaryTypesSelected, elem)
-			} else if elem != "false" {
-				return errors.New("Error: Invalid option for \"-binary\" flag")
-			}
-		}
+	binaryTypesSelected, err := selectBinaryTypes(flagInfo.BinaryDiffPtr)
+	if err != nil {
+		return err
 	}
+	flagInfo.BinaryTypesSelected = append(flagInfo.BinaryTypesSelected, binaryTypesSelected...)
 	if flagInfo.CompressRootfsFile != "" {
 		if res := utilities.FileExists(flagInfo.CompressRootfsFile, "txt"); res == -1 {
 			return errors.New("Error: " + flagInfo.CompressRootfsFile + " file does not exist")
@@ -148,10 +172,103 @@ func FlagErrorChecking(flagInfo *FlagInfo) error {
 		}
 		flagInfo.Image2 = flag.Arg(1)
 	}
+	if flagInfo.RootfsManifestFile != "" && flagInfo.Image2 != "" {
+		return errors.New("Error: \"-rootfs-manifest\" flag is only supported for one image")
+	}
+
+	required := requiredPartitions(flagInfo.BinaryTypesSelected)
+	if flagInfo.RootfsManifestFile != "" { // Only the Rootfs partition is used for the manifest
+		required = []string{"3"}
+	}
+	mountPartitions, err := selectMountPartitions(required, flagInfo.MountPartitionsPtr)
+	if err != nil {
+		return err
+	}
+	flagInfo.MountPartitions = mountPartitions
 
 	return nil
 }
 
+// selectBinaryTypes returns the binary types selected by the "-binary" flag
+// Input:
+//   (string) binaryDiff - Value of the "-binary" flag
+// Output:
+//   ([]string) binaryTypes - The selected binary types. If the flag is not set,
+//   all types but the opt-in ones
+func selectBinaryTypes(binaryDiff string) ([]string, error) {
+	binaryTypes := []string{}
+	if binaryDiff == "" {
+		for _, elem := range BinaryDiffTypes {
+			if !utilities.InArray(elem, optInBinaryDiffTypes) {
+				binaryTypes = append(binaryTypes, elem)
+			}
+		}
+		return binaryTypes, nil
+	}
+	for _, elem := range strings.Split(binaryDiff, ",") {
+		if utilities.InArray(elem, BinaryDiffTypes) {
+			binaryTypes = append(binaryTypes, elem)
+		} else if elem != "false" {
+			return nil, errors.New("Error: Invalid option for \"-binary\" flag")
+		}
+	}
+	return binaryTypes, nil
+}
+
+// requiredPartitions returns the partitions that must be mounted to find the selected binary types
+// Input:
+//   ([]string) binaryTypes - List of binary types selected from the user
+// Output:
+//   ([]string) partitions - The required partitions, in mounting order
+func requiredPartitions(binaryTypes []string) []string {
+	partitions := []string{}
+	if utilities.InArray("Stateful-partition", binaryTypes) {
+		partitions = append(partitions, "1")
+	}
+	for _, binaryType := range rootfsBinaryTypes {
+		if utilities.InArray(binaryType, binaryTypes) {
+			partitions = append(partitions, "3")
+			break
+		}
+	}
+	if utilities.InArray("Kernel-command-line", binaryTypes) {
+		partitions = append(partitions, "12")
+	}
+	return partitions
+}
+
+// selectMountPartitions returns the partitions to mount given the "-mount-partitions" flag.
+// If the flag is not set, only the required partitions are mounted. Otherwise the listed
+// partitions are mounted, and they must include all of the required partitions.
+// Input:
+//   ([]string) required - Partitions required by the selected binary types
+//   (string) selection - Comma separated list of partitions from the "-mount-partitions" flag
+// Output:
+//   ([]string) partitions - The partitions to mount, in mounting order
+func selectMountPartitions(required []string, selection string) ([]string, error) {
+	if selection == "" {
+		return required, nil
+	}
+	selected := strings.Split(selection, ",")
+	for _, partition := range selected {
+		if !utilities.InArray(partition, mountablePartitions) {
+			return nil, errors.New("Error: Invalid partition \"" + partition + "\" for \"-mount-partitions\" flag, must be 1, 3 or 12")
+		}
+	}
+	for _, partition := range required {
+		if !utilities.InArray(partition, selected) {
+			return nil, errors.New("Error: Partition " + partition + " is required by the selected binary types but not listed in the \"-mount-partitions\" flag")
+		}
+	}
+	partitions := []string{}
+	for _, partition := range mountablePartitions {
+		if utilities.InArray(partition, selected) {
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions, nil
+}
+
 // ParseFlags reads and validates the flags from the command-line
 // Input: None (Command-line flags and args)
 // Output:
@@ -172,10 +289,13 @@ func ParseFlags() (*FlagInfo, error) {
 	flag.BoolVar(&flagInfo.ReleaseNotesSelected, "release-notes", true, "")
 
 	flag.BoolVar(&flagInfo.Verbose, "verbose", false, "")
+	flag.BoolVar(&flagInfo.NormalizeWhitespace, "normalize-whitespace", false, "")
 	flag.StringVar(&flagInfo.CompressRootfsFile, "compress-rootfs", "", "")
 	flag.StringVar(&flagInfo.CompressStatefulFile, "compress-stateful", "", "")
 
 	flag.StringVar(&flagInfo.OutputSelected, "output", "terminal", "")
+	flag.StringVar(&flagInfo.RootfsManifestFile, "rootfs-manifest", "", "")
+	flag.StringVar(&flagInfo.MountPartitionsPtr, "mount-partitions", "", "")
 	flag.Parse()
 
 	if err := FlagErrorChecking(flagInfo); err != nil {
@@ -57,3 +57,82 @@ func TestFlagErrorChecking(t *testing.T) {
 		}
 	}
 }
+
+// test requiredPartitions function
+// test selectBinaryTypes function
+func TestSelectBinaryTypes(t *testing.T) {
+	for _, tc := range []struct {
+		binaryDiff string
+		want       []string
+		wantErr    bool
+	}{
+		{binaryDiff: "", want: []string{"Version", "BuildID", "Rootfs", "Kernel-command-line", "Stateful-partition", "Partition-structure", "Sysctl-settings", "OS-config", "Kernel-configs"}},
+		{binaryDiff: "Version,Security-scan", want: []string{"Version", "Security-scan"}},
+		{binaryDiff: "false", want: []string{}},
+		{binaryDiff: "wrongType", wantErr: true},
+	} {
+		got, err := selectBinaryTypes(tc.binaryDiff)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Fatalf("selectBinaryTypes(%q) expected error %v, got %v", tc.binaryDiff, tc.wantErr, err)
+		}
+		if !tc.wantErr && !utilities.EqualArrays(got, tc.want) {
+			t.Fatalf("selectBinaryTypes(%q) expected %v, got %v", tc.binaryDiff, tc.want, got)
+		}
+	}
+}
+
+func TestRequiredPartitions(t *testing.T) {
+	for _, tc := range []struct {
+		binaryTypes []string
+		want        []string
+	}{
+		{binaryTypes: BinaryDiffTypes, want: []string{"1", "3", "12"}},
+		{binaryTypes: []string{"Partition-structure"}, want: []string{}},
+		{binaryTypes: []string{"Stateful-partition"}, want: []string{"1"}},
+		{binaryTypes: []string{"Version", "Security-scan"}, want: []string{"3"}},
+		{binaryTypes: []string{"Kernel-command-line", "OS-config"}, want: []string{"3", "12"}},
+		{binaryTypes: []string{}, want: []string{}},
+	} {
+		got := requiredPartitions(tc.binaryTypes)
+		if !utilities.EqualArrays(got, tc.want) {
+			t.Fatalf("requiredPartitions(%v) expected: %v, got: %v", tc.binaryTypes, tc.want, got)
+		}
+	}
+}
+
+// test selectMountPartitions function
+func TestSelectMountPartitions(t *testing.T) {
+	for _, tc := range []struct {
+		required  []string
+		selection string
+		want      []string
+		wantErr   bool
+	}{
+		// Default to the required partitions
+		{required: []string{"3", "12"}, selection: "", want: []string{"3", "12"}},
+		{required: []string{}, selection: "", want: []string{}},
+		// Force mount partitions that are not required
+		{required: []string{}, selection: "1", want: []string{"1"}},
+		{required: []string{"3"}, selection: "12,3,1", want: []string{"1", "3", "12"}},
+		{required: []string{"3"}, selection: "3,3", want: []string{"3"}},
+		// Invalid partitions
+		{required: []string{}, selection: "2", wantErr: true},
+		{required: []string{}, selection: "1,", wantErr: true},
+		// Required partition not selected
+		{required: []string{"1", "3"}, selection: "3", wantErr: true},
+	} {
+		got, err := selectMountPartitions(tc.required, tc.selection)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("selectMountPartitions(%v, %q) expected error but none returned", tc.required, tc.selection)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("selectMountPartitions(%v, %q) failed: %v", tc.required, tc.selection, err)
+		}
+		if !utilities.EqualArrays(got, tc.want) {
+			t.Fatalf("selectMountPartitions(%v, %q) expected: %v, got: %v", tc.required, tc.selection, tc.want, got)
+		}
+	}
+}
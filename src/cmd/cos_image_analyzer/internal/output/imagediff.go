@@ -38,6 +38,7 @@ func (imageDiff *ImageDiff) Formater(image1, image2 string, flagInfo *input.Flag
 			"Kernel-configs":      imageDiff.BinaryDiff.FormatKernelConfigsDiff,
 			"Kernel-command-line": imageDiff.BinaryDiff.FormatKernelCommandLineDiff,
 			"Sysctl-settings":     imageDiff.BinaryDiff.FormatSysctlSettingsDiff,
+			"Security-scan":       imageDiff.BinaryDiff.FormatSecurityScanDiff,
 		}
 		for _, diff := range input.BinaryDiffTypes {
 			if utilities.InArray(diff, flagInfo.BinaryTypesSelected) {
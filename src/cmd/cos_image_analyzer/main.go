@@ -71,10 +71,10 @@ func cosImageAnalyzer(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo)
 
 // CallCosImageAnalyzer is wrapper that gets the images, calls cosImageAnalyzer, and cleans up
 func CallCosImageAnalyzer(image1, image2 *input.ImageInfo, flagInfo *input.FlagInfo) error {
-	if err := image1.MountImage(flagInfo.BinaryTypesSelected); err != nil {
+	if err := image1.MountImage(flagInfo.MountPartitions); err != nil {
 		return fmt.Errorf("failed to mount first image %v: %v", flagInfo.Image1, err)
 	}
-	if err := image2.MountImage(flagInfo.BinaryTypesSelected); err != nil {
+	if err := image2.MountImage(flagInfo.MountPartitions); err != nil {
 		return fmt.Errorf("failed to mount second image %v: %v", flagInfo.Image2, err)
 	}
 	if err := cosImageAnalyzer(image1, image2, flagInfo); err != nil {
@@ -98,12 +98,27 @@ func analyze(flagInfo *input.FlagInfo) error {
 	if err != nil {
 		return fmt.Errorf("failed to get images: %v", err)
 	}
+	if flagInfo.RootfsManifestFile != "" {
+		return writeRootfsManifest(image1, flagInfo)
+	}
 	if err := CallCosImageAnalyzer(image1, image2, flagInfo); err != nil {
 		return err
 	}
 	return nil
 }
 
+// writeRootfsManifest mounts the Rootfs partition of an image and writes its
+// content manifest to the file given by the "-rootfs-manifest" flag
+func writeRootfsManifest(image *input.ImageInfo, flagInfo *input.FlagInfo) error {
+	if err := image.MountImage(flagInfo.MountPartitions); err != nil {
+		return fmt.Errorf("failed to mount image %v: %v", flagInfo.Image1, err)
+	}
+	if err := binary.WriteRootfsManifest(image.RootfsPartition3, flagInfo.RootfsManifestFile); err != nil {
+		return fmt.Errorf("failed to write Rootfs manifest of image %v: %v", flagInfo.Image1, err)
+	}
+	return nil
+}
+
 func main() {
 	if runtime.GOOS != "linux" {
 		fmt.Printf("Error: This is a Linux tool, can not run on %s", runtime.GOOS)
@@ -6,11 +6,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/logging"
@@ -23,16 +27,25 @@ import (
 const cloudLoggerName = "cos_node_profiler"
 
 var (
-	configFile       = flag.String("config-file", "", "specifies the path of the configuration file. If path is not set, then it is assumed that command line flags will be passed to configure the Node Profiler.")
-	projID           = flag.String("project", "", "specifies the GCP project where logs will be added.")
-	command          = flag.String("cmd", "", "specifies raw commands for which to log output.")
-	cmdCount         = flag.Int("cmd-count", 0, "specifies the number of times to run an arbitrary shell command.")
-	cmdInterval      = flag.Int("cmd-interval", 0, "specifies the interval (in seconds) separating the number of times the user runs an arbitrary shell command.")
-	cmdTimeOut       = flag.Int("cmd-timeout", 300, "specifies the amount of time (in seconds) it will take for the a raw command to timeout and be killed.")
-	profilerCount    = flag.Int("profiler-count", 1, "specifies the number of times to collect USE Report.")
-	profilerInterval = flag.Int("profiler-interval", 0, "specifies the interval (in seconds) separating the number of times the user collects USE Report.")
+	configFile          = flag.String("config-file", "", "specifies the path of the configuration file. If path is not set, then it is assumed that command line flags will be passed to configure the Node Profiler.")
+	projID              = flag.String("project", "", "specifies the GCP project where logs will be added.")
+	command             = flag.String("cmd", "", "specifies raw commands for which to log output.")
+	cmdCount            = flag.Int("cmd-count", 0, "specifies the number of times to run an arbitrary shell command.")
+	cmdInterval         = flag.Int("cmd-interval", 0, "specifies the interval (in seconds) separating the number of times the user runs an arbitrary shell command.")
+	cmdTimeOut          = flag.Int("cmd-timeout", 300, "specifies the amount of time (in seconds) it will take for the a raw command to timeout and be killed.")
+	profilerCount       = flag.Int("profiler-count", 1, "specifies the number of times to collect USE Report.")
+	profilerInterval    = flag.Int("profiler-interval", 0, "specifies the interval (in seconds) separating the number of times the user collects USE Report.")
+	outputFile          = flag.String("output-file", "", "specifies the path of a file to write the USE reports to as JSON. If set, the reports and shell command outputs are only written to Google Cloud Logging if a project is also specified.")
+	logSink             = flag.String("log-sink", cloudlogger.CloudLogSink, "specifies where to write logs: \"cloud\" for Google Cloud Logging or \"file\" for the local file set by log-file. The \"file\" sink does not need a project.")
+	logFile             = flag.String("log-file", "cos_node_profiler.log", "specifies the path of the file the \"file\" log sink appends JSON log entries to.")
+	severityFromMetrics = flag.Bool("severity-from-metrics", false, "specifies whether USE reports are logged at Error severity if a component has errors and at Warning severity if a component is saturated, instead of always at Debug severity.")
+	prometheusAddr      = flag.String("prometheus-addr", "", "specifies the address, ex. \":9100\", on which to serve USE metrics for Prometheus at /metrics. If set, USE metrics are collected every profiler-interval seconds (60 by default) and are not written to Google Cloud Logging.")
 )
 
+// defaultPrometheusInterval is the interval between USE report collections
+// when serving Prometheus metrics without a profiler interval.
+const defaultPrometheusInterval = time.Minute
+
 func main() {
 	var opts *cloudlogger.LoggerOpts
 	var err error
@@ -45,11 +58,54 @@ func main() {
 	} else {
 		opts = loadFlags()
 	}
+	logged := opts.ProjID != "" || opts.LogSink == cloudlogger.FileLogSink
+	if *outputFile != "" && (*prometheusAddr != "" || !logged) {
+		if err := writeUSEReports(*outputFile, opts); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if *prometheusAddr == "" {
+			return
+		}
+	}
+	if *prometheusAddr != "" {
+		if err := servePrometheus(*prometheusAddr, opts); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+	// The reports logged are also written to the output file, so the
+	// profiler only runs once for both.
+	var reports *useReports
+	if *outputFile != "" {
+		if opts.ProfilerCount < 1 {
+			opts.ProfilerCount = 1
+		}
+		reports = &useReports{}
+		opts.OnUSEReport = reports.add
+	}
+	err = logProfilerReport(opts)
+	if reports != nil {
+		if err := reports.write(*outputFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	log.Info("Successfully logged profiler report.")
+}
+
+// logProfilerReport logs the profiler report to the log sink set in opts.
+func logProfilerReport(opts *cloudlogger.LoggerOpts) error {
+	if opts.LogSink == cloudlogger.FileLogSink {
+		log.Infof("Begin logging profiler report to %s...", *logFile)
+		return cloudlogger.LogProfilerReport(cloudlogger.NewFileStructuredLogger(*logFile), opts)
+	}
 	// [START client setup]
 	ctx := context.Background()
 	client, err := logging.NewClient(ctx, opts.ProjID)
 	if err != nil {
-		log.Fatalf("failed to create logging client: %v", err)
+		return fmt.Errorf("failed to create logging client: %v", err)
 	}
 	defer client.Close()
 	client.OnError = func(err error) {
@@ -60,31 +116,151 @@ func main() {
 	// [END client setup]
 	log.Info("Begin logging profiler report...")
 	logger := client.Logger(cloudLoggerName)
-	if err = cloudlogger.LogProfilerReport(logger, opts); err != nil {
-		log.Fatalf("%v", err)
+	return cloudlogger.LogProfilerReport(logger, opts)
+}
+
+// useReports holds encoded USE reports to write them to a file as a JSON
+// array.
+type useReports struct {
+	reports []json.RawMessage
+}
+
+// add encodes report and appends it to the reports.
+func (u *useReports) add(report *profiler.USEReport) error {
+	// Components are reused by the next report, so the report is encoded
+	// right away.
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode USE report: %v", err)
 	}
-	log.Info("Successfully logged profiler report.")
+	u.reports = append(u.reports, data)
+	return nil
+}
+
+// write writes the reports to path as a JSON array.
+func (u *useReports) write(path string) error {
+	data, err := json.MarshalIndent(u.reports, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode USE reports: %v", err)
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write USE reports to %s: %v", path, err)
+	}
+	log.Infof("Wrote %d USE report(s) to %s", len(u.reports), path)
+	return nil
+}
+
+// writeUSEReports generates opts.ProfilerCount USE reports, profiler interval
+// apart, and writes them to path as a JSON array.
+func writeUSEReports(path string, opts *cloudlogger.LoggerOpts) error {
+	count := opts.ProfilerCount
+	if count < 1 {
+		count = 1
+	}
+	reports := &useReports{}
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			time.Sleep(opts.ProfilerInterval)
+		}
+		report, err := profiler.GenerateUSEReport(opts.Components, opts.ProfilerCmds)
+		if err != nil {
+			// The metrics of the components that succeeded are still
+			// written.
+			log.Errorf("failed to generate USE report: %v", err)
+		}
+		if err := reports.add(&report); err != nil {
+			return err
+		}
+	}
+	return reports.write(path)
+}
+
+// prometheusMetrics holds the latest USE metrics in the Prometheus text
+// exposition format.
+type prometheusMetrics struct {
+	mu      sync.RWMutex
+	metrics []byte
+}
+
+// refresh collects a USE report and replaces the served metrics with it.
+func (p *prometheusMetrics) refresh(opts *cloudlogger.LoggerOpts) {
+	report, err := profiler.GenerateUSEReport(opts.Components, opts.ProfilerCmds)
+	if err != nil {
+		// Metrics of the components that succeeded are still served,
+		// the failed ones are reported by cos_profiler_collection_errors.
+		log.Errorf("failed to generate USE report: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := profiler.WritePrometheus(&buf, report); err != nil {
+		log.Errorf("%v", err)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metrics = buf.Bytes()
+}
+
+// ServeHTTP serves the latest USE metrics.
+func (p *prometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(p.metrics)
+}
+
+// servePrometheus serves USE metrics for Prometheus at /metrics on addr,
+// collecting a new USE report every profiler interval.
+func servePrometheus(addr string, opts *cloudlogger.LoggerOpts) error {
+	interval := opts.ProfilerInterval
+	if interval <= 0 {
+		interval = defaultPrometheusInterval
+	}
+	metrics := &prometheusMetrics{}
+	metrics.refresh(opts)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			metrics.refresh(opts)
+		}
+	}()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	log.Infof("Serving Prometheus metrics on %s/metrics, refreshed every %v", addr, interval)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		return fmt.Errorf("failed to serve Prometheus metrics on %s: %v", addr, err)
+	}
+	return nil
 }
 
 // generateProfilerOpts is a helper function used to generate the components
 // array as well as the profiler options used to call the
-// profiler.GenerateUSEReport function from the profiler package.
-func generateProfilerOpts() ([]profiler.Component, []profiler.Command) {
+// profiler.GenerateUSEReport function from the profiler package. The
+// components are saturated above the given thresholds.
+func generateProfilerOpts(thresholds profiler.Thresholds) ([]profiler.Component, []profiler.Command) {
 	// [Begin generating ProfilerOpts from Profiler Package]
 	// Getting Components
-	cpu := profiler.NewCPU("CPU")
-	memcap := profiler.NewMemCap("MemCap")
-	sDevIO := profiler.NewStorageDevIO("StorageDevIO")
-	sCap := profiler.NewStorageCap("StorageCap")
-	components := []profiler.Component{cpu, memcap, sDevIO, sCap}
+	cpu := profiler.NewCPU("CPU", thresholds)
+	memcap := profiler.NewMemCap("MemCap", thresholds)
+	sDevIO := profiler.NewStorageDevIO("StorageDevIO", []string{}, thresholds)
+	sCap := profiler.NewStorageCap("StorageCap", thresholds)
+	netIO := profiler.NewNetworkIO("NetworkIO")
+	fds := profiler.NewFileDescriptors("FileDescriptors", thresholds)
+	components := []profiler.Component{cpu, memcap, sDevIO, sCap, netIO, fds}
 	// End Getting Components
 	// Getting Commands
 	vmstat := profiler.NewVMStat("vmstat", 1, 5, []string{"us", "sy", "st", "si", "so", "r"})
 	lscpu := profiler.NewLscpu("lscpu", []string{"CPU(s)"})
 	free := profiler.NewFree("free", []string{"Mem:used", "Mem:total", "Swap:used", "Swap:total"})
-	iostat := profiler.NewIOStat("iostat", "-xdz", 1, 5, []string{"aqu-sz", "%util"})
+	iostat := profiler.NewIOStat("iostat", "-xdz", 1, 5, []string{"Device", "aqu-sz", "%util"})
 	df := profiler.NewDF("df", "-k", []string{})
-	commands := []profiler.Command{vmstat, lscpu, free, iostat, df}
+	netdev := profiler.NewNetDev("netdev", 1, 5, []string{"rx_bytes", "tx_bytes",
+		"rx_errs", "tx_errs", "rx_drop", "tx_drop", "rx_fifo", "tx_fifo"})
+	dmesg := profiler.NewDMesg("dmesg", "")
+	loadavg := profiler.NewLoadAvg("loadavg")
+	psi := profiler.NewPSI("psi")
+	filenr := profiler.NewFileNr("filenr")
+	commands := []profiler.Command{vmstat, lscpu, free, iostat, df, netdev, dmesg, loadavg, psi, filenr}
 	// End Getting Commands
 	// [End generating ProfilerOpts from Profiler Package]
 	return components, commands
@@ -93,8 +269,9 @@ func generateProfilerOpts() ([]profiler.Component, []profiler.Command) {
 // loadflags helps to use command line flags as configuration to the Node
 // Profiler tool.
 func loadFlags() *cloudlogger.LoggerOpts {
-	// Getting Profiler Options.
-	components, commands := generateProfilerOpts()
+	// Getting Profiler Options. The saturation thresholds can only be set
+	// in a configuration file, so the default thresholds are used.
+	components, commands := generateProfilerOpts(profiler.Thresholds{})
 	shCmds := []cloudlogger.ShellCmdOpts{
 		cloudlogger.ShellCmdOpts{
 			Command:     *command,
@@ -105,12 +282,14 @@ func loadFlags() *cloudlogger.LoggerOpts {
 	}
 	// populating LoggerOpts struct with configurations from user.
 	opts := &cloudlogger.LoggerOpts{
-		ProjID:           *projID,
-		ShCmds:           shCmds,
-		ProfilerCount:    *profilerCount,
-		ProfilerInterval: time.Duration(*profilerInterval) * time.Second,
-		Components:       components,
-		ProfilerCmds:     commands,
+		ProjID:              *projID,
+		LogSink:             *logSink,
+		ShCmds:              shCmds,
+		ProfilerCount:       *profilerCount,
+		ProfilerInterval:    time.Duration(*profilerInterval) * time.Second,
+		Components:          components,
+		ProfilerCmds:        commands,
+		SeverityFromMetrics: *severityFromMetrics,
 	}
 	return opts
 }
@@ -133,7 +312,12 @@ func loadConfig(filename string) (*cloudlogger.LoggerOpts, error) {
 		logger.ShCmds[i].CmdTimeOut = logger.ShCmds[i].CmdTimeOut * time.Second
 	}
 	logger.ProfilerInterval = logger.ProfilerInterval * time.Second
-	components, commands := generateProfilerOpts()
+	// The log sink can be set in the configuration file or with the
+	// --log-sink flag.
+	if logger.LogSink == "" {
+		logger.LogSink = *logSink
+	}
+	components, commands := generateProfilerOpts(logger.Thresholds)
 	logger.Components = components
 	logger.ProfilerCmds = commands
 	return &logger, err
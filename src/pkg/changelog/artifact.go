@@ -0,0 +1,216 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// ArtifactChange is a key of an artifact file that differs between two
+// builds.
+type ArtifactChange struct {
+	Name string
+	// SourceValue is empty if the key was added in the target build.
+	SourceValue string
+	// TargetValue is empty if the key was removed in the target build.
+	TargetValue string
+	// SourceFile and TargetFile are the names of the artifact files the
+	// key was read from in each build, ex. "sysctl-a.txt" for a build whose
+	// sysctl artifact has an alternate name. They are empty if the key is
+	// not in the corresponding build.
+	SourceFile string
+	TargetFile string
+}
+
+// ArtifactDiff is the difference between the artifact files of two builds.
+// Each list of changes is sorted by key.
+type ArtifactDiff struct {
+	Added   []ArtifactChange
+	Removed []ArtifactChange
+	Changed []ArtifactChange
+	// SourceFound and TargetFound indicate whether the artifact file for
+	// each build was found. If either file was not found, there are no
+	// changes.
+	SourceFound bool
+	TargetFound bool
+}
+
+// Rows returns all changes as a list of [key, old-value, new-value] rows
+// sorted by key. Missing values are displayed as "---".
+func (d *ArtifactDiff) Rows() [][]string {
+	rows := [][]string{}
+	for _, changes := range [][]ArtifactChange{d.Added, d.Removed, d.Changed} {
+		for _, change := range changes {
+			oldValue, newValue := change.SourceValue, change.TargetValue
+			if oldValue == "" {
+				oldValue = "---"
+			}
+			if newValue == "" {
+				newValue = "---"
+			}
+			rows = append(rows, []string{change.Name, oldValue, newValue})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i][0] < rows[j][0]
+	})
+	return rows
+}
+
+// diffArtifact categorizes the differences between the key-value maps of two
+// artifact files, read from the files named sourceFile and targetFile.
+func diffArtifact(source, target map[string]string, sourceFile, targetFile string) *ArtifactDiff {
+	diff := &ArtifactDiff{
+		SourceFound: len(source) > 0,
+		TargetFound: len(target) > 0,
+	}
+	// if either one of the artifact files doesn't exist,
+	// return an empty diff.
+	if !diff.SourceFound || !diff.TargetFound {
+		return diff
+	}
+	for newName, newValue := range target {
+		if oldValue, found := source[newName]; !found {
+			diff.Added = append(diff.Added, ArtifactChange{Name: newName, TargetValue: newValue, TargetFile: targetFile})
+		} else if oldValue != newValue {
+			diff.Changed = append(diff.Changed, ArtifactChange{Name: newName, SourceValue: oldValue, TargetValue: newValue, SourceFile: sourceFile, TargetFile: targetFile})
+		}
+	}
+	for oldName, oldValue := range source {
+		if _, found := target[oldName]; !found {
+			diff.Removed = append(diff.Removed, ArtifactChange{Name: oldName, SourceValue: oldValue, SourceFile: sourceFile})
+		}
+	}
+	for _, changes := range [][]ArtifactChange{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Name < changes[j].Name
+		})
+	}
+	return diff
+}
+
+// CompareBuildArtifact finds the difference between an artifact file of two
+// builds on the same board and milestone. Artifacts are read from
+// <bucket>/<board>-release/R<milestone>-<build number>/<artifactName>, and
+// source and target may be build numbers or image names.
+//
+// Each line of an artifact file is parsed as a "key = value" or "key=value"
+// pair. Lines without a value, ex. in a package list, are compared by key
+// only. Keys for which filter returns false are not compared. If filter is
+// nil, all keys are compared.
+//
+// client is used to read artifacts from GCS, and may be shared between
+// concurrent calls. If client is nil, a new client is created and closed
+// before returning.
+func CompareBuildArtifact(client *storage.Client, bucket, board, milestone, source, target, artifactName string, filter func(key string) bool) (*ArtifactDiff, error) {
+	return compareBuildArtifact(client, bucket, board, milestone, source, board, milestone, target, []string{artifactName}, filter)
+}
+
+// compareBuildArtifact is like CompareBuildArtifact for builds on different
+// boards or milestones. The first of artifactNames that exists is read for
+// each build.
+func compareBuildArtifact(client *storage.Client, bucket, sourceBoard, sourceMilestone, source, targetBoard, targetMilestone, target string, artifactNames []string, filter func(key string) bool) (*ArtifactDiff, error) {
+	sourceBuildNum, targetBuildNum := resolveImageName(source), resolveImageName(target)
+	ctx := context.Background()
+	if client == nil {
+		var err error
+		client, err = newStorageClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create storage client: %v", err)
+		}
+		defer client.Close()
+	}
+	type artifact struct {
+		values map[string]string
+		name   string
+	}
+	sourceChan := make(chan artifact)
+	targetChan := make(chan artifact)
+	go func() {
+		values, name := fetchArtifactToMap(ctx, client, fmt.Sprintf("%s/%s-release/R%s-%s",
+			bucket, sourceBoard, sourceMilestone, sourceBuildNum), artifactNames, filter)
+		sourceChan <- artifact{values, name}
+	}()
+	go func() {
+		values, name := fetchArtifactToMap(ctx, client, fmt.Sprintf("%s/%s-release/R%s-%s",
+			bucket, targetBoard, targetMilestone, targetBuildNum), artifactNames, filter)
+		targetChan <- artifact{values, name}
+	}()
+	sourceArtifact, targetArtifact := <-sourceChan, <-targetChan
+	return diffArtifact(sourceArtifact.values, targetArtifact.values, sourceArtifact.name, targetArtifact.name), nil
+}
+
+// fetchArtifactToMap fetches an artifact file from GCS and maps each line to
+// a <key: value> pair, excluding the keys for which filter returns false.
+//
+// The first of names that exists at path is read, and its name is returned
+// along with the map. An empty map and name are returned if none of them
+// exist.
+func fetchArtifactToMap(ctx context.Context, client *storage.Client, path string, names []string, filter func(key string) bool) (map[string]string, string) {
+	outMap := make(map[string]string)
+	var rc *storage.Reader
+	var err error
+	var fileName string
+	for _, name := range names {
+		rc, err = client.Bucket(path).Object(name).NewReader(ctx)
+		if err != storage.ErrObjectNotExist {
+			fileName = name
+			break
+		}
+		log.Debugf("%s not found at %s", name, path)
+	}
+	if err != nil {
+		log.Errorf("failed to open artifact file at %s (error:%s)", path, err)
+		return outMap, ""
+	}
+
+	byteBuf, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		log.Errorf("failed to read artifact file at %s (error:%s)", path, err)
+		return outMap, ""
+	}
+	for _, line := range strings.Split(string(byteBuf), "\n") {
+		if line == "" {
+			continue
+		}
+		key, value := parseArtifactLine(line)
+		if filter != nil && !filter(key) {
+			continue
+		}
+		outMap[key] = value
+	}
+	return outMap, fileName
+}
+
+// parseArtifactLine splits a line of an artifact file into a key and a value.
+// The key is assumed to be before the first " = " separator, or the first
+// "=" if there is no " = " separator. Lines without a separator are a key
+// with no value.
+func parseArtifactLine(line string) (string, string) {
+	for _, separator := range []string{" = ", "="} {
+		if i := strings.Index(line, separator); i >= 0 {
+			return line[:i], line[i+len(separator):]
+		}
+	}
+	return line, ""
+}
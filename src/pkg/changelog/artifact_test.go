@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+)
+
+func TestParseArtifactLine(t *testing.T) {
+	tests := map[string]struct {
+		Line  string
+		Key   string
+		Value string
+	}{
+		"sysctl":              {"vm.swappiness = 60", "vm.swappiness", "60"},
+		"sysctl no value":     {"kernel.domainname = ", "kernel.domainname", ""},
+		"sysctl value sep":    {"kernel.core_pattern = |/bin/dump a=b", "kernel.core_pattern", "|/bin/dump a=b"},
+		"kernel config":       {"CONFIG_BPF=y", "CONFIG_BPF", "y"},
+		"kernel config unset": {"# CONFIG_KASAN is not set", "# CONFIG_KASAN is not set", ""},
+		"package":             {"app-admin/sudo-1.9.5", "app-admin/sudo-1.9.5", ""},
+	}
+	for name, test := range tests {
+		if key, value := parseArtifactLine(test.Line); key != test.Key || value != test.Value {
+			t.Errorf("test %q failed: expected (%q, %q), got (%q, %q)", name, test.Key, test.Value, key, value)
+		}
+	}
+}
+
+func TestCompareBuildArtifact(t *testing.T) {
+	ctx := context.Background()
+	gcs, err := fakes.NewGCSServer(ctx)
+	if err != nil {
+		t.Fatalf("failed to create fake GCS server: %v", err)
+	}
+	defer gcs.Server.Close()
+	defer gcs.Client.Close()
+	gcs.Objects["/bucket/lakitu-release/R93-16623.0.0/kernel_config.txt"] = []byte("CONFIG_BPF=y\nCONFIG_KASAN=y\nCONFIG_LOCALVERSION=\"-16623.0.0\"\n# CONFIG_DEBUG is not set\n")
+	gcs.Objects["/bucket/lakitu-release/R93-16623.1.0/kernel_config.txt"] = []byte("CONFIG_BPF=m\nCONFIG_LOCALVERSION=\"-16623.1.0\"\nCONFIG_IPV6=y\n# CONFIG_DEBUG is not set\n")
+	gcs.Objects["/bucket/lakitu-release/R93-16623.0.0/package_list.txt"] = []byte("app-admin/sudo-1.9.5\nsys-apps/systemd-248\n")
+	gcs.Objects["/bucket/lakitu-release/R93-16623.1.0/package_list.txt"] = []byte("app-admin/sudo-1.9.8\nsys-apps/systemd-248\n")
+
+	tests := map[string]struct {
+		ArtifactName string
+		Filter       func(key string) bool
+		Expected     *ArtifactDiff
+	}{
+		"kernel config": {
+			ArtifactName: "kernel_config.txt",
+			Filter:       func(key string) bool { return key != "CONFIG_LOCALVERSION" },
+			Expected: &ArtifactDiff{
+				Added:       []ArtifactChange{{Name: "CONFIG_IPV6", TargetValue: "y", TargetFile: "kernel_config.txt"}},
+				Removed:     []ArtifactChange{{Name: "CONFIG_KASAN", SourceValue: "y", SourceFile: "kernel_config.txt"}},
+				Changed:     []ArtifactChange{{Name: "CONFIG_BPF", SourceValue: "y", TargetValue: "m", SourceFile: "kernel_config.txt", TargetFile: "kernel_config.txt"}},
+				SourceFound: true,
+				TargetFound: true,
+			},
+		},
+		"filter by prefix": {
+			ArtifactName: "kernel_config.txt",
+			Filter:       func(key string) bool { return strings.HasPrefix(key, "CONFIG_LOCALVERSION") },
+			Expected: &ArtifactDiff{
+				Changed:     []ArtifactChange{{Name: "CONFIG_LOCALVERSION", SourceValue: "\"-16623.0.0\"", TargetValue: "\"-16623.1.0\"", SourceFile: "kernel_config.txt", TargetFile: "kernel_config.txt"}},
+				SourceFound: true,
+				TargetFound: true,
+			},
+		},
+		"package list": {
+			ArtifactName: "package_list.txt",
+			Expected: &ArtifactDiff{
+				Added:       []ArtifactChange{{Name: "app-admin/sudo-1.9.8", TargetFile: "package_list.txt"}},
+				Removed:     []ArtifactChange{{Name: "app-admin/sudo-1.9.5", SourceFile: "package_list.txt"}},
+				SourceFound: true,
+				TargetFound: true,
+			},
+		},
+		"missing artifact": {
+			ArtifactName: "missing.txt",
+			Expected:     &ArtifactDiff{},
+		},
+	}
+	for name, test := range tests {
+		diff, err := CompareBuildArtifact(gcs.Client, "bucket", "lakitu", "93", "16623.0.0", "cos-beta-93-16623-1-0", test.ArtifactName, test.Filter)
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(diff, test.Expected) {
+			t.Errorf("test %q failed: expected %+v, got %+v", name, test.Expected, diff)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import "sort"
+
+// UncategorizedBug is the Bug of the BugGroup holding the commits that don't
+// reference any bug.
+const UncategorizedBug = "uncategorized"
+
+// BugGroup lists the commits referencing a bug, across all repositories.
+type BugGroup struct {
+	// Bug is the bug referenced by the commits, ex. "b/123456", or
+	// UncategorizedBug.
+	Bug     string       `yaml:"Bug"`
+	Commits []*BugCommit `yaml:"Commits"`
+}
+
+// BugCommit is a commit in a BugGroup, along with the repository it was
+// submitted to.
+type BugCommit struct {
+	Path   string  `yaml:"Path"`
+	Repo   string  `yaml:"Repo"`
+	Commit *Commit `yaml:"Commit"`
+}
+
+// GroupByBug groups the commits of a changelog by the bugs they reference.
+// A commit referencing several bugs is listed in the group of each bug, and
+// commits referencing no bug are grouped under UncategorizedBug.
+//
+// Groups are sorted by bug, with the uncategorized group last. Within a
+// group, commits are sorted by repository path and keep the order of the
+// repository log.
+func GroupByBug(changes map[string]*RepoLog) []*BugGroup {
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	groups := make(map[string]*BugGroup)
+	addCommit := func(bug string, commit *BugCommit) {
+		group, ok := groups[bug]
+		if !ok {
+			group = &BugGroup{Bug: bug}
+			groups[bug] = group
+		}
+		group.Commits = append(group.Commits, commit)
+	}
+	for _, path := range paths {
+		repoLog := changes[path]
+		for _, commit := range repoLog.Commits {
+			bugCommit := &BugCommit{Path: path, Repo: repoLog.Repo, Commit: commit}
+			if len(commit.Bugs) == 0 {
+				addCommit(UncategorizedBug, bugCommit)
+				continue
+			}
+			// A commit may list the same bug more than once.
+			seen := make(map[string]bool)
+			for _, bug := range commit.Bugs {
+				if !seen[bug] {
+					seen[bug] = true
+					addCommit(bug, bugCommit)
+				}
+			}
+		}
+	}
+
+	output := make([]*BugGroup, 0, len(groups))
+	for bug, group := range groups {
+		if bug != UncategorizedBug {
+			output = append(output, group)
+		}
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Bug < output[j].Bug
+	})
+	if group, ok := groups[UncategorizedBug]; ok {
+		output = append(output, group)
+	}
+	return output
+}
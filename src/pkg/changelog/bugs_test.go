@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGroupByBug(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/kernel", "kernel-a"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/kernel", "kernel-b"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-b"},
+	)
+	fake.logs["kernel-b"] = []string{"kernel-2", "kernel-1"}
+	fake.logs["overlays-b"] = []string{"overlays-2", "overlays-1"}
+	fake.bugs["kernel-2"] = "b/200"
+	fake.bugs["kernel-1"] = "b/100, b:200, b/100"
+	fake.bugs["overlays-2"] = "None"
+	fake.bugs["overlays-1"] = "b/100"
+
+	additions, _, err := Changelog(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1)
+	if err != nil {
+		t.Fatalf("changelog failed, unexpected error: %v", err)
+	}
+	type groupedCommit struct{ Path, Repo, SHA string }
+	grouped := func(groups []*BugGroup) map[string][]groupedCommit {
+		out := make(map[string][]groupedCommit)
+		for _, group := range groups {
+			for _, commit := range group.Commits {
+				out[group.Bug] = append(out[group.Bug], groupedCommit{commit.Path, commit.Repo, commit.Commit.SHA})
+			}
+		}
+		return out
+	}
+
+	groups := GroupByBug(additions)
+	var bugs []string
+	for _, group := range groups {
+		bugs = append(bugs, group.Bug)
+	}
+	if expected := []string{"b/100", "b/200", UncategorizedBug}; !reflect.DeepEqual(bugs, expected) {
+		t.Errorf("GroupByBug failed, expected groups %v, got %v", expected, bugs)
+	}
+	expected := map[string][]groupedCommit{
+		"b/100": {
+			{"src/kernel", "cos/kernel", "kernel-1"},
+			{"src/overlays", "cos/overlays", "overlays-1"},
+		},
+		"b/200": {
+			{"src/kernel", "cos/kernel", "kernel-2"},
+			{"src/kernel", "cos/kernel", "kernel-1"},
+		},
+		UncategorizedBug: {
+			{"src/overlays", "cos/overlays", "overlays-2"},
+		},
+	}
+	if got := grouped(groups); !reflect.DeepEqual(got, expected) {
+		t.Errorf("GroupByBug failed, expected %v, got %v", expected, got)
+	}
+	if groups := GroupByBug(nil); groups == nil || len(groups) != 0 {
+		t.Errorf("GroupByBug failed, expected no groups for an empty changelog, got %v", groups)
+	}
+}
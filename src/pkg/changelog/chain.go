@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+)
+
+// MaxChainBuilds is the maximum number of builds accepted in a build chain.
+const MaxChainBuilds = 10
+
+// ChainLink is the changelog between two consecutive builds of a build chain.
+type ChainLink struct {
+	Source string
+	Target string
+	// Additions and Removals are the same as the outputs of Changelog
+	// between Source and Target. They are nil if the changelog was not
+	// retrieved.
+	Additions map[string]*RepoLog
+	Removals  map[string]*RepoLog
+	// Loaded is false if the changelog was not retrieved because the link
+	// is past the limit passed to ChangelogChain.
+	Loaded bool
+	// Err is the error encountered retrieving the changelog, if any.
+	Err utils.ChangelogError
+}
+
+// ParseBuildChain parses an ordered list of builds separated by commas or
+// whitespace, ex. "15000.0.0, 15001.0.0 15002.0.0". Builds may be build
+// numbers or image names. An error is returned if the list has fewer than 2
+// or more than MaxChainBuilds builds, or if a build is listed twice in a row.
+func ParseBuildChain(input string) ([]string, error) {
+	builds := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(builds) < 2 {
+		return nil, fmt.Errorf("a build chain needs at least 2 builds, got %d", len(builds))
+	}
+	if len(builds) > MaxChainBuilds {
+		return nil, fmt.Errorf("a build chain can have at most %d builds, got %d", MaxChainBuilds, len(builds))
+	}
+	for i := 1; i < len(builds); i++ {
+		if resolveImageName(builds[i-1]) == resolveImageName(builds[i]) {
+			return nil, fmt.Errorf("build %s is listed twice in a row", builds[i])
+		}
+	}
+	return builds, nil
+}
+
+// ChangelogChain retrieves the changelogs between each pair of consecutive
+// builds, ex. A..B and B..C for the builds [A, B, C]. host, manifestRepo,
+// croslandURL, querySize and opts are the same as for ChangelogWithOptions.
+//
+// Only the changelogs of the first limit pairs are retrieved, and they are
+// retrieved concurrently. The remaining links are returned with Loaded set
+// to false, so callers can retrieve them separately if needed. A negative
+// limit retrieves all pairs.
+//
+// An error retrieving one changelog does not stop the others, and is
+// reported in the Err field of its link.
+func ChangelogChain(ctx context.Context, httpClient *http.Client, builds []string, host, manifestRepo, croslandURL string, querySize int, opts *Options, limit int) []*ChainLink {
+	if len(builds) < 2 {
+		return nil
+	}
+	links := make([]*ChainLink, len(builds)-1)
+	var wg sync.WaitGroup
+	for i := range links {
+		link := &ChainLink{Source: builds[i], Target: builds[i+1]}
+		links[i] = link
+		if limit >= 0 && i >= limit {
+			continue
+		}
+		link.Loaded = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link.Additions, link.Removals, _, link.Err = ChangelogWithOptions(ctx, httpClient, link.Source, link.Target, host, manifestRepo, croslandURL, querySize, opts)
+		}()
+	}
+	wg.Wait()
+	return links
+}
@@ -0,0 +1,160 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseBuildChain(t *testing.T) {
+	tests := map[string]struct {
+		Input       string
+		Expected    []string
+		ShouldError bool
+	}{
+		"commas": {
+			Input:    "1.0.0,1.1.0,2.0.0",
+			Expected: []string{"1.0.0", "1.1.0", "2.0.0"},
+		},
+		"commas and whitespace": {
+			Input:    " 1.0.0, cos-rc-85-1-1-0\n2.0.0 ,",
+			Expected: []string{"1.0.0", "cos-rc-85-1-1-0", "2.0.0"},
+		},
+		"non-consecutive duplicates": {
+			Input:    "1.0.0 2.0.0 1.0.0",
+			Expected: []string{"1.0.0", "2.0.0", "1.0.0"},
+		},
+		"single build": {
+			Input:       "1.0.0,",
+			ShouldError: true,
+		},
+		"empty": {
+			Input:       "",
+			ShouldError: true,
+		},
+		"consecutive duplicates": {
+			Input:       "1.0.0 cos-85-1-0-0",
+			ShouldError: true,
+		},
+		"too many builds": {
+			Input:       "1.0.0 2.0.0 3.0.0 4.0.0 5.0.0 6.0.0 7.0.0 8.0.0 9.0.0 10.0.0 11.0.0",
+			ShouldError: true,
+		},
+	}
+	for name, test := range tests {
+		builds, err := ParseBuildChain(test.Input)
+		if test.ShouldError {
+			if err == nil {
+				t.Errorf("test %q failed: expected error, got builds %v", name, builds)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(builds, test.Expected) {
+			t.Errorf("test %q failed: expected %v, got %v", name, test.Expected, builds)
+		}
+	}
+}
+
+func TestChangelogChain(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+	)
+	fake.manifests["1.1.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-c"},
+	)
+	fake.logs["kernel-a"] = []string{"kernel-a"}
+	fake.logs["kernel-b"] = []string{"kernel-b"}
+	fake.logs["kernel-c"] = []string{"kernel-c"}
+
+	tests := map[string]struct {
+		Builds []string
+		Limit  int
+		// Expected lists the added SHAs of each link, or nil if the link
+		// should not be loaded.
+		Expected [][]string
+		// ErrCodes lists the expected error code of each link.
+		ErrCodes []string
+	}{
+		"all links": {
+			Builds:   []string{"1.0.0", "1.1.0", "2.0.0"},
+			Limit:    -1,
+			Expected: [][]string{{"kernel-b"}, {"kernel-c"}},
+			ErrCodes: []string{"", ""},
+		},
+		"limited links": {
+			Builds:   []string{"1.0.0", "1.1.0", "2.0.0"},
+			Limit:    1,
+			Expected: [][]string{{"kernel-b"}, nil},
+			ErrCodes: []string{"", ""},
+		},
+		"missing build": {
+			Builds:   []string{"1.0.0", "1.1.0", "3.0.0"},
+			Limit:    -1,
+			Expected: [][]string{{"kernel-b"}, {}},
+			ErrCodes: []string{"", "404"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			links := ChangelogChain(context.Background(), fake.client(), test.Builds, fake.host(), defaultManifestRepo, "", -1, nil, test.Limit)
+			if len(links) != len(test.Builds)-1 {
+				t.Fatalf("expected %d links, got %d", len(test.Builds)-1, len(links))
+			}
+			for i, link := range links {
+				if link.Source != test.Builds[i] || link.Target != test.Builds[i+1] {
+					t.Errorf("link %d: expected %s..%s, got %s..%s", i, test.Builds[i], test.Builds[i+1], link.Source, link.Target)
+				}
+				if link.Loaded != (test.Expected[i] != nil) {
+					t.Errorf("link %d: expected loaded %t, got %t", i, test.Expected[i] != nil, link.Loaded)
+				}
+				var errCode string
+				if link.Err != nil {
+					errCode = link.Err.HTTPCode()
+				}
+				if errCode != test.ErrCodes[i] {
+					t.Errorf("link %d: expected error code %q, got %q (%v)", i, test.ErrCodes[i], errCode, link.Err)
+				}
+				if !link.Loaded || link.Err != nil {
+					continue
+				}
+				got := []string{}
+				for _, repoLog := range link.Additions {
+					for _, commit := range repoLog.Commits {
+						got = append(got, commit.SHA)
+					}
+				}
+				if !reflect.DeepEqual(got, test.Expected[i]) {
+					t.Errorf("link %d: expected additions %v, got %v", i, test.Expected[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestChangelogChainSingleBuild(t *testing.T) {
+	if links := ChangelogChain(context.Background(), nil, []string{"1.0.0"}, "", "", "", -1, nil, -1); links != nil {
+		t.Errorf("expected no links, got %v", links)
+	}
+}
@@ -34,10 +34,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"cloud.google.com/go/storage"
@@ -46,13 +46,65 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	gitilesApi "go.chromium.org/luci/common/api/gitiles"
+	"go.chromium.org/luci/common/proto/git"
 	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
 )
 
+const (
+	// maxBuildSuggestions is the maximum number of similar builds suggested
+	// for a build that could not be found.
+	maxBuildSuggestions = 3
+	// maxSuggestionDistance is the maximum edit distance between a build
+	// number that could not be found and a suggested build number.
+	maxSuggestionDistance = 2
+	// latestBuild is the keyword that resolves to the newest build on the
+	// release branch of the other build in a changelog request.
+	latestBuild = "latest"
+)
+
 var (
-	imageBuildRe = regexp.MustCompile("^cos-(dev-|beta-|stable-|rc-)?\\d+-([\\d-]+)$")
+	// newStorageClient creates the GCS client used by GetSysctlDiff when
+	// no client is provided.
+	newStorageClient = func(ctx context.Context) (*storage.Client, error) {
+		return storage.NewClient(ctx)
+	}
+
+	// knownSysctlFileNames are the object names used for the sysctl
+	// artifact by different versions of build-executor, in the order they
+	// are tried.
+	knownSysctlFileNames = []string{"sysctl_a.txt", "sysctl-a.txt", "sysctl.txt"}
+
+	// insignificantSysctls are the sysctl parameters whose value changes
+	// are insignificant and should not be displayed.
+	insignificantSysctls = map[string]bool{
+		"kernel.hostname":                  true,
+		"kernel.version":                   true,
+		"fs.dentry-state":                  true,
+		"fs.file-nr":                       true,
+		"fs.inode-nr":                      true,
+		"fs.inode-state":                   true,
+		"fs.quota.syncs":                   true,
+		"kernel.ns_last_pid":               true,
+		"kernel.pty.nr":                    true,
+		"kernel.random.boot_id":            true,
+		"kernel.random.entropy_avail":      true,
+		"kernel.random.uuid":               true,
+		"net.netfilter.nf_conntrack_count": true,
+		"kernel.osrelease":                 true,
+		"net.ipv4.tcp_fastopen_key":        true,
+	}
+
+	// imageBuildRe matches an image name, capturing its channel, milestone
+	// and build number.
+	imageBuildRe = regexp.MustCompile(`^cos-(?:(dev|beta|stable|rc)-)?(\d+)-(\d+-\d+-\d+)$`)
+	buildNumRe   = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
 )
 
+// ErrSysctlMilestoneMismatch is returned by GetSysctlDiff for builds on
+// different milestones, whose sysctl files are laid out differently and
+// cannot be compared.
+var ErrSysctlMilestoneMismatch = errors.New("sysctl files of builds on different milestones cannot be compared")
+
 type repo struct {
 	Repo string
 	Path string
@@ -76,7 +128,10 @@ type commitsRequest struct {
 	Committish  string
 	Ancestor    string
 	QuerySize   int
-	OutputChan  chan commitsResult
+	// CountCommits counts the commits past QuerySize, see
+	// Options.CountCommits.
+	CountCommits bool
+	OutputChan   chan commitsResult
 }
 
 type commitsResult struct {
@@ -84,37 +139,176 @@ type commitsResult struct {
 	InstanceURL    string
 	Repo           string
 	Path           string
+	CommitCount    int
 	HasMoreCommits bool
+	LogPageTokens  map[int]string
 	Err            utils.ChangelogError
 }
 
 type additionsResult struct {
 	Additions map[string]*RepoLog
+	Failed    []RepoError
 	Err       utils.ChangelogError
 }
 
+// RepoLogResult is the log of a repository sent by ChangelogStream.
+type RepoLogResult struct {
+	// Path is the path of the repository in the manifest.
+	Path string
+	// Removed is set if Log lists the commits present in the source build
+	// but not in the target build, instead of the commits added to the
+	// target build.
+	Removed bool
+	Log     *RepoLog
+}
+
+// RepoError describes a repository whose changelog could not be retrieved.
+type RepoError struct {
+	Repo string
+	Path string
+	Err  utils.ChangelogError
+}
+
 // RepoLog contains a changelist for a particular repository
 type RepoLog struct {
-	Commits        []*Commit
-	InstanceURL    string
-	Repo           string
-	SourceSHA      string
-	TargetSHA      string
-	HasMoreCommits bool
+	// Commits are ordered from newest to oldest commit time. Commits with
+	// the same commit time are in the order returned by Gitiles.
+	Commits        []*Commit `yaml:"Commits"`
+	InstanceURL    string    `yaml:"InstanceURL"`
+	Repo           string    `yaml:"Repo"`
+	SourceSHA      string    `yaml:"SourceSHA"`
+	TargetSHA      string    `yaml:"TargetSHA"`
+	HasMoreCommits bool      `yaml:"HasMoreCommits"`
+	// CommitCount is the number of commits in the changelog of the
+	// repository. Commits removed from the changelog, ex. by
+	// Options.BugFilter, are not counted. If HasMoreCommits is set, the
+	// commits past the query size are only counted with
+	// Options.CountCommits, and CommitCount is 0 otherwise.
+	CommitCount int `yaml:"CommitCount"`
+	// LogPageTokens maps 0-based positions in the Gitiles log of the commits
+	// between SourceSHA and TargetSHA to the SHA of the commit at that
+	// position, for positions that are multiples of MaxLogLinkCommits. It
+	// is used to split links to the log, see LogLinks, and only lists the
+	// positions past the query size if the commits were counted.
+	LogPageTokens map[int]string `yaml:"LogPageTokens,omitempty"`
+}
+
+// Options contains optional settings that control how a changelog is
+// generated. A nil *Options is equivalent to the zero value.
+type Options struct {
+	// RepoAllowlist restricts the changelog to the listed repository paths,
+	// ex. "src/third_party/kernel/v5.4". If empty, every repository in the
+	// manifest is included.
+	RepoAllowlist []string
+	// RepoDenylist excludes the listed repository paths from the changelog.
+	// The denylist is applied after the allowlist.
+	RepoDenylist []string
+	// Lenient controls how errors retrieving a single repository's commits
+	// are handled. By default, the entire changelog fails. If Lenient is
+	// set, the failing repositories are omitted from the changelog and
+	// reported separately, so one broken repository does not prevent the
+	// rest of the changelog from being generated.
+	Lenient bool
+	// BugFilter restricts the changelog to commits that reference the bug,
+	// in the form used in Commit.Bugs, ex. "b/123456". Repositories left
+	// without commits are omitted. If empty, all commits are included.
+	//
+	// Commits are filtered before the query size is applied, so every
+	// commit of each repository is retrieved when BugFilter is set, and
+	// HasMoreCommits is only set if more than querySize commits match.
+	BugFilter string
+	// CollapsePairs removes pairs of commits whose net effect is zero from
+	// the changelog: a commit and its revert that are both added or both
+	// removed, and a cherry-pick that is added while the commit it was
+	// picked from, or another cherry-pick of the same commit, is removed.
+	// Repositories left without commits are omitted.
+	CollapsePairs bool
+	// ManifestCache stores the manifest files retrieved for the changelog,
+	// so that they can be reused by other changelogs sharing the cache. If
+	// nil, manifest files are always retrieved.
+	ManifestCache *ManifestCache
+	// CountCommits sets the CommitCount field of repositories with more
+	// commits than the query size. Gitiles cannot count the commits of a
+	// range without listing them, so the remaining commits are listed, but
+	// not parsed or returned, in pages of 10000 commits. This requires an
+	// additional Gitiles request per page, which is costly on large ranges
+	// such as kernel changelogs. Changelogs without a query size always
+	// have their CommitCount set.
+	CountCommits bool
+	// DiffStats sets the FilesChanged, Insertions and Deletions fields of
+	// every commit in the changelog. This requires an additional Gitiles
+	// request per commit.
+	DiffStats bool
 }
 
 // resolveImageName returns the build number associated with an image name.
 // If the string is not an image name, it returns the input string.
+// The "latest" keyword is returned unchanged, since resolving it requires
+// listing builds with resolveLatest.
 func resolveImageName(imageName string) string {
 	build := imageBuildRe.FindStringSubmatch(imageName)
-	if len(build) < 2 {
+	if build == nil {
 		return imageName
 	}
-	buildNum := strings.Replace(build[2], "-", ".", 3)
+	buildNum := strings.ReplaceAll(build[3], "-", ".")
 	log.Debugf("resolveImageName: image name %s was resolved to build number %s", imageName, buildNum)
 	return buildNum
 }
 
+// BuildNumberToImageName returns the name of the image of a build, ex.
+// "cos-dev-85-13310-0-0" for build 13310.0.0 on milestone 85 of the dev
+// channel. channel is one of "dev", "beta", "stable" or "rc", or empty for
+// the images named by milestone only, ex. "cos-85-13310-1041-9".
+//
+// The returned name is resolved back to the build number by the changelog
+// functions accepting image names.
+func BuildNumberToImageName(buildNum, channel string, milestone int) (string, error) {
+	if !buildNumRe.MatchString(buildNum) {
+		return "", fmt.Errorf("invalid build number %q", buildNum)
+	}
+	if milestone <= 0 {
+		return "", fmt.Errorf("invalid milestone %d", milestone)
+	}
+	prefix := "cos-"
+	switch channel {
+	case "":
+	case "dev", "beta", "stable", "rc":
+		prefix += channel + "-"
+	default:
+		return "", fmt.Errorf("invalid channel %q", channel)
+	}
+	return fmt.Sprintf("%s%d-%s", prefix, milestone, strings.ReplaceAll(buildNum, ".", "-")), nil
+}
+
+// ImageName holds the components of a COS image name.
+type ImageName struct {
+	// Channel is "dev", "beta", "stable" or "rc", or empty for images named
+	// by milestone only.
+	Channel   string `json:"channel"`
+	Milestone int    `json:"milestone"`
+	BuildNum  string `json:"buildNum"`
+}
+
+// ParseImageName splits an image name, ex. "cos-stable-85-13310-1041-9",
+// into its channel, milestone and build number. It is the inverse of
+// BuildNumberToImageName, and returns an error if imageName is not a
+// valid image name.
+func ParseImageName(imageName string) (*ImageName, error) {
+	match := imageBuildRe.FindStringSubmatch(imageName)
+	if match == nil {
+		return nil, fmt.Errorf("invalid image name %q", imageName)
+	}
+	milestone, err := strconv.Atoi(match[2])
+	if err != nil || milestone <= 0 {
+		return nil, fmt.Errorf("invalid milestone %q in image name %q", match[2], imageName)
+	}
+	return &ImageName{
+		Channel:   match[1],
+		Milestone: milestone,
+		BuildNum:  strings.ReplaceAll(match[3], "-", "."),
+	}, nil
+}
+
 // limitPageSize will restrict a request page size to min of pageSize (which grows exponentially)
 // or remaining request size
 func limitPageSize(pageSize, requestedSize int) int {
@@ -155,78 +349,424 @@ func createGitilesClients(clients map[string]gitilesProto.GitilesClient, httpCli
 // of source committish when generating changelog.
 func repoMap(manifest string) (map[string]*repo, error) {
 	log.Debug("Mapping repository to instance URL and committish")
-	if manifest == "" {
-		log.Error("repoMap: manifest file is empty")
-		return nil, errors.New("manifest file is empty")
+	root, err := manifestRoot(manifest)
+	if err != nil {
+		return nil, err
 	}
-	doc := etree.NewDocument()
-	if err := doc.ReadFromString(manifest); err != nil {
-		log.Debug("repoMap: error parsing manifest xml:\n%w", err)
-		return nil, errors.New("could not parse XML for manifest file associated with build")
+	if includes := root.SelectElements("include"); len(includes) > 0 {
+		log.Warningf("repoMap: ignoring %d <include> elements, projects in included manifests are not mapped", len(includes))
 	}
-	root := doc.SelectElement("manifest")
+	return manifestRepos(root), nil
+}
 
+// manifestRepos generates a mapping of repository ID to instance URL and
+// committish from the <manifest> element of a manifest file.
+//
+// <remove-project> and <extend-project> elements modify the projects defined
+// before them, so elements are processed in document order. Remotes and
+// projects missing a required attribute are logged and ignored.
+func manifestRepos(root *etree.Element) map[string]*repo {
 	// Parse each <remote fetch=X name=Y> tag in the manifest xml file.
 	// Extract the "fetch" and "name" attributes from each remote tag, and map the name to the fetch URL.
 	remoteMap := make(map[string]string)
 	for _, remote := range root.SelectElements("remote") {
-		url := strings.Replace(remote.SelectAttr("fetch").Value, "https://", "", 1)
-		remoteMap[remote.SelectAttr("name").Value] = url
+		name, fetch := remote.SelectAttrValue("name", ""), remote.SelectAttrValue("fetch", "")
+		if name == "" || fetch == "" {
+			log.Warnf("manifestRepos: ignoring <remote> without a name or fetch URL: name %q, fetch %q", name, fetch)
+			continue
+		}
+		remoteMap[name] = strings.Replace(fetch, "https://", "", 1)
 	}
 
 	// Parse each <project name=X remote=Y revision=Z> tag in the manifest xml file.
 	// Extract the "name", "remote", and "revision" attributes from each project tag.
-	// Some projects do not have a "remote" attribute.
-	// If this is the case, they should use the default remoteURL.
-	if root.SelectElement("default").SelectAttr("remote") != nil {
-		remoteMap[""] = remoteMap[root.SelectElement("default").SelectAttr("remote").Value]
+	// Some projects do not have a "remote" or "revision" attribute.
+	// If this is the case, they should use the default remoteURL and revision.
+	defaultRevision := ""
+	if defaults := root.SelectElement("default"); defaults != nil {
+		if remote := defaults.SelectAttr("remote"); remote != nil {
+			remoteMap[""] = remoteMap[remote.Value]
+		}
+		defaultRevision = defaults.SelectAttrValue("revision", "")
 	}
 	repos := make(map[string]*repo)
-	for _, project := range root.SelectElements("project") {
-		name, path := project.SelectAttr("name").Value, project.SelectAttrValue("path", "")
-		repos[path] = &repo{
-			Repo:        name,
-			Path:        path,
-			InstanceURL: remoteMap[project.SelectAttrValue("remote", "")],
-			Committish:  project.SelectAttr("revision").Value,
+	for _, element := range root.ChildElements() {
+		switch element.Tag {
+		case "project":
+			// A project without a path is checked out at its name.
+			name := element.SelectAttrValue("name", "")
+			path := element.SelectAttrValue("path", name)
+			revision := element.SelectAttrValue("revision", defaultRevision)
+			if name == "" || revision == "" {
+				log.Warnf("manifestRepos: ignoring <project> without a name or revision: name %q, path %q", name, path)
+				continue
+			}
+			repos[path] = &repo{
+				Repo:        name,
+				Path:        path,
+				InstanceURL: remoteMap[element.SelectAttrValue("remote", "")],
+				Committish:  revision,
+			}
+		case "remove-project":
+			for _, removed := range matchingRepos(repos, element) {
+				log.Debugf("manifestRepos: removing project %s at path %s", removed.Repo, removed.Path)
+				delete(repos, removed.Path)
+			}
+		case "extend-project":
+			for _, extended := range matchingRepos(repos, element) {
+				log.Debugf("manifestRepos: extending project %s at path %s", extended.Repo, extended.Path)
+				if revision := element.SelectAttrValue("revision", ""); revision != "" {
+					extended.Committish = revision
+				}
+				if remote := element.SelectAttr("remote"); remote != nil {
+					extended.InstanceURL = remoteMap[remote.Value]
+				}
+				if destPath := element.SelectAttr("dest-path"); destPath != nil {
+					delete(repos, extended.Path)
+					extended.Path = destPath.Value
+					repos[extended.Path] = extended
+				}
+			}
 		}
 	}
-	return repos, nil
+	return repos
 }
 
-// mappedManifest retrieves a Manifest file from GoB and unmarshals XML.
-// Returns a mapping of repository ID to repository data.
-func mappedManifest(client gitilesProto.GitilesClient, repo string, buildInput, buildNum string) (map[string]*repo, utils.ChangelogError) {
-	log.Debugf("Retrieving manifest file for build %s\n", buildNum)
+// matchingRepos returns the repositories a <remove-project> or
+// <extend-project> element applies to: every repository with the element's
+// name, restricted to the element's path if it has one.
+func matchingRepos(repos map[string]*repo, element *etree.Element) []*repo {
+	name := element.SelectAttrValue("name", "")
+	path := element.SelectAttr("path")
+	var matches []*repo
+	for _, repoData := range repos {
+		if repoData.Repo == name && (path == nil || repoData.Path == path.Value) {
+			matches = append(matches, repoData)
+		}
+	}
+	return matches
+}
+
+// manifestRoot parses a manifest file and returns its <manifest> element.
+func manifestRoot(manifest string) (*etree.Element, error) {
+	if manifest == "" {
+		log.Error("manifestRoot: manifest file is empty")
+		return nil, errors.New("manifest file is empty")
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(manifest); err != nil {
+		log.Debugf("manifestRoot: error parsing manifest xml:\n%v", err)
+		return nil, errors.New("could not parse XML for manifest file associated with build")
+	}
+	root := doc.SelectElement("manifest")
+	if root == nil {
+		log.Error("manifestRoot: manifest file has no <manifest> element")
+		return nil, errors.New("could not find manifest element in manifest file associated with build")
+	}
+	return root, nil
+}
+
+// filterRepos returns the subset of repos whose paths are in the allowlist and
+// not in the denylist. An empty allowlist permits every repository.
+func filterRepos(repos map[string]*repo, allowlist, denylist []string) map[string]*repo {
+	if len(allowlist) == 0 && len(denylist) == 0 {
+		return repos
+	}
+	allowed := make(map[string]bool)
+	for _, path := range allowlist {
+		allowed[path] = true
+	}
+	denied := make(map[string]bool)
+	for _, path := range denylist {
+		denied[path] = true
+	}
+	filtered := make(map[string]*repo)
+	for path, repoData := range repos {
+		if len(allowed) > 0 && !allowed[path] {
+			continue
+		}
+		if denied[path] {
+			continue
+		}
+		filtered[path] = repoData
+	}
+	log.Debugf("filterRepos: kept %d of %d repositories", len(filtered), len(repos))
+	return filtered
+}
+
+// downloadManifest retrieves a Manifest file from GoB.
+// Returns the contents of the manifest file.
+func downloadManifest(client gitilesProto.GitilesClient, repo string, buildInput, buildNum string) (string, utils.ChangelogError) {
 	response, err := utils.DownloadManifest(client, repo, buildNum)
 	if err != nil {
-		log.Errorf("mappedManifest: error downloading manifest file from repo %s for build %s:\n%v", repo, buildNum, err)
+		log.Errorf("downloadManifest: error downloading manifest file from repo %s for build %s:\n%v", repo, buildNum, err)
 		httpCode := utils.GitilesErrCode(err)
 		if httpCode == "403" {
-			return nil, utils.ForbiddenError
+			return "", utils.ForbiddenError
 		} else if httpCode == "404" {
-			return nil, utils.BuildNotFound(buildInput)
+			return "", utils.BuildNotFound(buildInput)
 		}
-		return nil, utils.InternalServerError
+		return "", utils.InternalServerError
 	}
-	mappedManifest, err := repoMap(response.Contents)
+	return response.Contents, nil
+}
+
+// mappedManifest retrieves a Manifest file from GoB and unmarshals XML.
+// Returns a mapping of repository ID to repository data.
+func mappedManifest(client gitilesProto.GitilesClient, repo string, buildInput, buildNum string) (map[string]*repo, utils.ChangelogError) {
+	log.Debugf("Retrieving manifest file for build %s\n", buildNum)
+	contents, utilErr := downloadManifest(client, repo, buildInput, buildNum)
+	if utilErr != nil {
+		return nil, utilErr
+	}
+	root, err := manifestRoot(contents)
 	if err != nil {
 		log.Errorf("mappedManifest: error retrieving mapped manifest file from repo %s for build %s:\n%v", repo, buildNum, err)
-		httpCode := utils.GitilesErrCode(err)
-		if httpCode == "404" {
-			return nil, utils.BuildNotFound(buildInput)
+		return nil, utils.InternalServerError
+	}
+	fetchInclude := func(name string) (string, error) {
+		response, err := utils.DownloadManifestFile(client, repo, buildNum, name)
+		if err != nil {
+			return "", err
+		}
+		return response.Contents, nil
+	}
+	if err := resolveIncludes(root, fetchInclude, nil); err != nil {
+		log.Errorf("mappedManifest: error resolving included manifest files from repo %s for build %s:\n%v", repo, buildNum, err)
+		if utils.GitilesErrCode(err) == "403" {
+			return nil, utils.ForbiddenError
 		}
 		return nil, utils.InternalServerError
 	}
-	return mappedManifest, nil
+	return manifestRepos(root), nil
+}
+
+// Manifest retrieves the raw manifest file for a build.
+//
+// httpClient is a authorized http.Client object with Gerrit scope.
+//
+// build should be a build number or an image name.
+//
+// host should be the GoB instance that Manifest files are hosted in
+// ex. "cos.googlesource.com"
+//
+// repo should be the repository that build manifest files
+// are located, ex. "cos/manifest-snapshots"
+func Manifest(httpClient *http.Client, build, host, repo string) (string, utils.ChangelogError) {
+	if httpClient == nil {
+		log.Error("httpClient is nil")
+		return "", utils.InternalServerError
+	}
+	buildNum := resolveImageName(build)
+	log.Infof("Retrieving manifest for build %s\n", buildNum)
+	client, err := gitilesClient(httpClient, host)
+	if err != nil {
+		return "", err
+	}
+	return downloadManifest(client, repo, build, buildNum)
+}
+
+// similarBuilds returns the build numbers in builds closest to buildNum, for
+// suggesting corrections to a mistyped build number. Builds are ranked by edit
+// distance, with ties broken by numeric distance between build numbers.
+func similarBuilds(builds []string, buildNum string) []string {
+	type candidate struct {
+		build    string
+		distance int
+		numeric  []int
+	}
+	target := buildComponents(buildNum)
+	var candidates []candidate
+	for _, build := range builds {
+		distance := editDistance(build, buildNum)
+		if distance == 0 || distance > maxSuggestionDistance {
+			continue
+		}
+		numeric := buildComponents(build)
+		for i := range numeric {
+			if i < len(target) {
+				numeric[i] -= target[i]
+			}
+			if numeric[i] < 0 {
+				numeric[i] = -numeric[i]
+			}
+		}
+		candidates = append(candidates, candidate{build, distance, numeric})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		a, b := candidates[i].numeric, candidates[j].numeric
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return candidates[i].build < candidates[j].build
+	})
+	var output []string
+	for i := 0; i < len(candidates) && i < maxBuildSuggestions; i++ {
+		output = append(output, candidates[i].build)
+	}
+	return output
+}
+
+// latestBuildOnBranch returns the newest build in builds on the release
+// branch of buildNum, or "" if there is none. A build number X.0.0 is on the
+// main branch, and any other build number X.Y.Z is on release branch X.
+// Tags that are not build numbers are ignored.
+func latestBuildOnBranch(builds []string, buildNum string) string {
+	branch := buildComponents(buildNum)
+	onMain := !buildNumRe.MatchString(buildNum) || (branch[1] == 0 && branch[2] == 0)
+	latest := ""
+	var latestComponents []int
+	for _, build := range builds {
+		if !buildNumRe.MatchString(build) {
+			continue
+		}
+		components := buildComponents(build)
+		if onMain && (components[1] != 0 || components[2] != 0) {
+			continue
+		}
+		if !onMain && (components[0] != branch[0] || (components[1] == 0 && components[2] == 0)) {
+			continue
+		}
+		if latest == "" || compareBuildComponents(components, latestComponents) > 0 {
+			latest, latestComponents = build, components
+		}
+	}
+	return latest
+}
+
+// compareBuildComponents compares two build numbers split by
+// buildComponents, returning -1, 0 or 1 if a is older than, the same as, or
+// newer than b.
+func compareBuildComponents(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] < b[i] {
+			return -1
+		} else if a[i] > b[i] {
+			return 1
+		}
+	}
+	if len(a) < len(b) {
+		return -1
+	} else if len(a) > len(b) {
+		return 1
+	}
+	return 0
+}
+
+// resolveLatest resolves the "latest" keyword in a source or target build
+// number to the newest build on the release branch of the other build, by
+// listing the builds in the manifest repository. If both builds are
+// "latest", they resolve to the newest build on the main branch.
+func resolveLatest(client gitilesProto.GitilesClient, repo, sourceBuildNum, targetBuildNum string) (string, string, utils.ChangelogError) {
+	if sourceBuildNum != latestBuild && targetBuildNum != latestBuild {
+		return sourceBuildNum, targetBuildNum, nil
+	}
+	builds, err := utils.ListBuilds(client, repo)
+	if err != nil {
+		log.Errorf("resolveLatest: failed to list builds in repo %s:\n%v", repo, err)
+		if utils.GitilesErrCode(err) == "403" {
+			return "", "", utils.ForbiddenError
+		}
+		return "", "", utils.InternalServerError
+	}
+	resolve := func(other string) (string, utils.ChangelogError) {
+		if other == latestBuild {
+			other = ""
+		}
+		latest := latestBuildOnBranch(builds, other)
+		if latest == "" {
+			log.Errorf("resolveLatest: no builds found on the release branch of %q in repo %s", other, repo)
+			return "", utils.BuildNotFound(latestBuild)
+		}
+		log.Debugf("resolveLatest: %s was resolved to build number %s", latestBuild, latest)
+		return latest, nil
+	}
+	if sourceBuildNum == latestBuild {
+		resolved, utilErr := resolve(targetBuildNum)
+		if utilErr != nil {
+			return "", "", utilErr
+		}
+		sourceBuildNum = resolved
+	}
+	if targetBuildNum == latestBuild {
+		resolved, utilErr := resolve(sourceBuildNum)
+		if utilErr != nil {
+			return "", "", utilErr
+		}
+		targetBuildNum = resolved
+	}
+	return sourceBuildNum, targetBuildNum, nil
+}
+
+// buildComponents splits a build number into its numeric components.
+// Non-numeric components are treated as 0.
+func buildComponents(buildNum string) []int {
+	parts := strings.Split(buildNum, ".")
+	output := make([]int, len(parts))
+	for i, part := range parts {
+		output[i], _ = strconv.Atoi(part)
+	}
+	return output
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
 }
 
 // commits get all commits that occur between committish and ancestor for a specific repo.
-func commits(req commitsRequest) {
+func commits(ctx context.Context, req commitsRequest) {
 	log.Debugf("Fetching changelog for repo: %s on committish %s\n", req.Repo, req.Committish)
-	commits, hasMoreCommits, err := utils.Commits(req.Client, req.Repo, req.Committish, req.Ancestor, req.QuerySize)
+	var commits []*git.Commit
+	var commitCount int
+	var hasMoreCommits bool
+	var pageTokens map[int]string
+	var err error
+	if req.CountCommits {
+		commits, commitCount, pageTokens, err = utils.CommitsWithCount(ctx, req.Client, req.Repo, req.Committish, req.Ancestor, req.QuerySize)
+		hasMoreCommits = commitCount > len(commits)
+	} else {
+		commits, hasMoreCommits, err = utils.Commits(ctx, req.Client, req.Repo, req.Committish, req.Ancestor, req.QuerySize)
+		if !hasMoreCommits {
+			commitCount = len(commits)
+		}
+	}
 	if err != nil {
-		if utils.GitilesErrCode(err) == "404" {
+		if ctx.Err() != nil {
+			req.OutputChan <- commitsResult{
+				InstanceURL: req.InstanceURL,
+				Path:        req.Path,
+				Repo:        req.Repo,
+				Err:         utils.RequestCanceled(ctx.Err()),
+			}
+		} else if utils.GitilesErrCode(err) == "404" {
 			req.OutputChan <- commitsResult{
 				InstanceURL: req.InstanceURL,
 				Path:        req.Path,
@@ -234,7 +774,12 @@ func commits(req commitsRequest) {
 			}
 		} else {
 			log.Errorf("commits: error retrieving commit changelog on repo %s from commit %s to commit %s:\n%v", req.Repo, req.Committish, req.Ancestor, err)
-			req.OutputChan <- commitsResult{Err: utils.InternalServerError}
+			req.OutputChan <- commitsResult{
+				InstanceURL: req.InstanceURL,
+				Path:        req.Path,
+				Repo:        req.Repo,
+				Err:         utils.InternalServerError,
+			}
 		}
 		return
 	}
@@ -244,7 +789,12 @@ func commits(req commitsRequest) {
 	parsedCommits, err := ParseGitCommitLog(commits)
 	if err != nil {
 		log.Errorf("commits: error parsing Gitiles commits response\n%v", err)
-		req.OutputChan <- commitsResult{Err: utils.InternalServerError}
+		req.OutputChan <- commitsResult{
+			InstanceURL: req.InstanceURL,
+			Path:        req.Path,
+			Repo:        req.Repo,
+			Err:         utils.InternalServerError,
+		}
 		return
 	}
 	req.OutputChan <- commitsResult{
@@ -252,15 +802,61 @@ func commits(req commitsRequest) {
 		InstanceURL:    req.InstanceURL,
 		Path:           req.Path,
 		Repo:           req.Repo,
+		CommitCount:    commitCount,
 		HasMoreCommits: hasMoreCommits,
+		LogPageTokens:  logPageTokens(commits, pageTokens),
+	}
+}
+
+// logPageTokens returns the LogPageTokens of a RepoLog, given its retrieved
+// commits and the page tokens of the commits that were only counted. Returns
+// nil if there are no tokens.
+func logPageTokens(commits []*git.Commit, pageTokens map[int]string) map[int]string {
+	var output map[int]string
+	add := func(pos int, sha string) {
+		if output == nil {
+			output = make(map[int]string)
+		}
+		output[pos] = sha
+	}
+	for pos := MaxLogLinkCommits; pos < len(commits); pos += MaxLogLinkCommits {
+		add(pos, commits[pos].Id)
+	}
+	for pos, token := range pageTokens {
+		if pos%MaxLogLinkCommits == 0 {
+			add(pos, token)
+		}
 	}
+	return output
 }
 
 // additions retrieves all commits that occured between 2 parsed manifest files for each repo.
 // Returns a map of repo name -> list of commits.
-func additions(clients map[string]gitilesProto.GitilesClient, sourceRepos map[string]*repo, targetRepos map[string]*repo, querySize int, outputChan chan additionsResult) {
+//
+// If lenient is set, repos whose commits cannot be retrieved are reported in
+// the Failed field of the result instead of failing the request. The request
+// fails regardless of lenient if ctx is done before all commits are retrieved.
+// If countCommits is set, commits past querySize are counted.
+func additions(ctx context.Context, clients map[string]gitilesProto.GitilesClient, sourceRepos map[string]*repo, targetRepos map[string]*repo, querySize int, lenient, countCommits bool, outputChan chan additionsResult) {
 	log.Debug("Retrieving commit additions")
 	repoCommits := make(map[string]*RepoLog)
+	failed, err := repoLogs(ctx, clients, sourceRepos, targetRepos, querySize, lenient, countCommits, func(path string, repoLog *RepoLog) {
+		repoCommits[path] = repoLog
+	})
+	if err != nil {
+		outputChan <- additionsResult{Err: err}
+		return
+	}
+	dedupCommits(repoCommits)
+	outputChan <- additionsResult{Additions: repoCommits, Failed: failed}
+}
+
+// repoLogs retrieves the commits that occured between 2 parsed manifest files
+// for each repo, like additions. Instead of collecting the logs, emit is called
+// with the log of each repo with commits as soon as it is retrieved. Logs are
+// not deduplicated across paths of the same repo.
+func repoLogs(ctx context.Context, clients map[string]gitilesProto.GitilesClient, sourceRepos map[string]*repo, targetRepos map[string]*repo, querySize int, lenient, countCommits bool, emit func(path string, repoLog *RepoLog)) ([]RepoError, utils.ChangelogError) {
+	var failed []RepoError
 	commitsChan := make(chan commitsResult, len(targetRepos))
 	for repoID, targetRepoInfo := range targetRepos {
 		cl := clients[targetRepoInfo.InstanceURL]
@@ -271,146 +867,289 @@ func additions(clients map[string]gitilesProto.GitilesClient, sourceRepos map[st
 			ancestorCommittish = sourceRepoInfo.Committish
 		}
 		commitsReq := commitsRequest{
-			Client:      cl,
-			Path:        targetRepoInfo.Path,
-			InstanceURL: targetRepoInfo.InstanceURL,
-			Repo:        targetRepoInfo.Repo,
-			Committish:  targetRepoInfo.Committish,
-			Ancestor:    ancestorCommittish,
-			QuerySize:   querySize,
-			OutputChan:  commitsChan,
+			Client:       cl,
+			Path:         targetRepoInfo.Path,
+			InstanceURL:  targetRepoInfo.InstanceURL,
+			Repo:         targetRepoInfo.Repo,
+			Committish:   targetRepoInfo.Committish,
+			Ancestor:     ancestorCommittish,
+			QuerySize:    querySize,
+			CountCommits: countCommits,
+			OutputChan:   commitsChan,
 		}
-		go commits(commitsReq)
+		go commits(ctx, commitsReq)
 	}
 	for i := 0; i < len(targetRepos); i++ {
-		res := <-commitsChan
-		if res.Err != nil {
-			outputChan <- additionsResult{Err: res.Err}
-			return
+		var res commitsResult
+		select {
+		case res = <-commitsChan:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			return nil, utils.RequestCanceled(ctx.Err())
+		}
+		if res.Err != nil && lenient {
+			failed = append(failed, RepoError{Repo: res.Repo, Path: res.Path, Err: res.Err})
+			continue
+		} else if res.Err != nil {
+			return nil, res.Err
 		}
 		var sourceSHA string
 		if sourceData, ok := sourceRepos[res.Path]; ok {
 			sourceSHA = sourceData.Committish
 		}
 		if len(res.Commits) > 0 {
-			repoCommits[res.Path] = &RepoLog{
+			emit(res.Path, &RepoLog{
 				Commits:        res.Commits,
+				CommitCount:    res.CommitCount,
 				HasMoreCommits: res.HasMoreCommits,
+				LogPageTokens:  res.LogPageTokens,
 				InstanceURL:    res.InstanceURL,
 				Repo:           res.Repo,
 				SourceSHA:      sourceSHA,
 				TargetSHA:      targetRepos[res.Path].Committish,
-			}
+			})
 		}
 	}
-	outputChan <- additionsResult{Additions: repoCommits}
+	return failed, nil
 }
 
-// getSysctlDiff finds sysctl difference between the two builds.
-// Returns a list of change lists:[[name, old-value, new-value], ...]
-func GetSysctlDiff(bucket, sourceBoard, sourceMilestone, source, targetBoard, targetMilestone, target string) (
-	[][]string, bool, bool) {
-	sourceBuildNum, targetBuildNum := resolveImageName(source), resolveImageName(target)
-	sourceChan := make(chan map[string]string)
-	targetChan := make(chan map[string]string)
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		log.Errorf("failed to create storage client (error: %s)", err)
-		return [][]string{}, false, false
+// dedupCommits removes commits that appear in more than one path of the same
+// repository, which happens when a manifest references a repository at
+// multiple paths. Commits are kept in the first path in sorted order, and
+// paths left without commits are removed. Commits are only compared within
+// the same repository, so distinct repositories with shared history are not
+// merged.
+func dedupCommits(repoCommits map[string]*RepoLog) {
+	paths := make([]string, 0, len(repoCommits))
+	for path := range repoCommits {
+		paths = append(paths, path)
 	}
-	go fetchSysctlToMap(fmt.Sprintf("%s/%s-release/R%s-%s",
-		bucket, sourceBoard, sourceMilestone, sourceBuildNum), sourceChan, client, ctx)
-	go fetchSysctlToMap(fmt.Sprintf("%s/%s-release/R%s-%s",
-		bucket, targetBoard, targetMilestone, targetBuildNum), targetChan, client, ctx)
-	sourceSysctl := <-sourceChan
-	targetSysctl := <-targetChan
-	foundSource := false
-	foundTarget := false
-	// if either one of the sysctl file doesn't exist,
-	// return an empty list.
-	if len(sourceSysctl) > 0 {
-		foundSource = true
+	sort.Strings(paths)
+	type commitKey struct {
+		instanceURL string
+		repo        string
+		sha         string
 	}
-	if len(targetSysctl) > 0 {
-		foundTarget = true
+	seen := make(map[commitKey]string)
+	for _, path := range paths {
+		repoLog := repoCommits[path]
+		var unique []*Commit
+		for _, commit := range repoLog.Commits {
+			key := commitKey{repoLog.InstanceURL, repoLog.Repo, commit.SHA}
+			if firstPath, ok := seen[key]; ok {
+				log.Debugf("dedupCommits: collapsed duplicate commit %s of repo %s at path %s into path %s", commit.SHA, repoLog.Repo, path, firstPath)
+				continue
+			}
+			seen[key] = path
+			unique = append(unique, commit)
+		}
+		if len(unique) == 0 {
+			delete(repoCommits, path)
+			continue
+		}
+		setCommits(repoLog, unique)
 	}
-	if !foundSource || !foundTarget {
-		return [][]string{}, foundSource, foundTarget
+}
+
+// filterCommitsByBug removes commits that do not reference bug, and paths
+// left without commits. The commits of each path must not have been
+// truncated to the query size, as CommitCount is set to the number of
+// matching commits.
+func filterCommitsByBug(repoCommits map[string]*RepoLog, bug string) {
+	for path, repoLog := range repoCommits {
+		var matching []*Commit
+		for _, commit := range repoLog.Commits {
+			for _, commitBug := range commit.Bugs {
+				if commitBug == bug {
+					matching = append(matching, commit)
+					break
+				}
+			}
+		}
+		if len(matching) == 0 {
+			delete(repoCommits, path)
+			continue
+		}
+		repoLog.Commits = matching
+		repoLog.CommitCount = len(matching)
+		repoLog.HasMoreCommits = false
 	}
+}
 
-	changes := [][]string{}
-	for newName, newValue := range targetSysctl {
-		if oldValue, found := sourceSysctl[newName]; !found {
-			changes = append(changes, []string{newName, "---", newValue})
-		} else if oldValue != newValue {
-			changes = append(changes, []string{newName, oldValue, newValue})
+// truncateCommits limits the commits of each path to querySize, setting
+// HasMoreCommits for paths with more commits. A querySize of -1 keeps all
+// commits.
+func truncateCommits(repoCommits map[string]*RepoLog, querySize int) {
+	if querySize == -1 {
+		return
+	}
+	for _, repoLog := range repoCommits {
+		if len(repoLog.Commits) > querySize {
+			repoLog.Commits = repoLog.Commits[:querySize]
+			repoLog.HasMoreCommits = true
 		}
 	}
-	for oldName, oldValue := range sourceSysctl {
-		if _, found := targetSysctl[oldName]; !found {
-			changes = append(changes, []string{oldName, oldValue, "---"})
+}
+
+// collapsePairs removes cherry-pick and revert pairs from the commits of each
+// path, as described by Options.CollapsePairs, and paths left without
+// commits.
+func collapsePairs(additions, removals map[string]*RepoLog) {
+	paths := make(map[string]bool)
+	for path := range additions {
+		paths[path] = true
+	}
+	for path := range removals {
+		paths[path] = true
+	}
+	for path := range paths {
+		var added, removed []*Commit
+		if repoLog, ok := additions[path]; ok {
+			added = repoLog.Commits
+		}
+		if repoLog, ok := removals[path]; ok {
+			removed = repoLog.Commits
+		}
+		dropped := make(map[*Commit]bool)
+		drop := func(a, b *Commit) {
+			if a != nil && b != nil && !dropped[a] && !dropped[b] {
+				dropped[a], dropped[b] = true, true
+			}
+		}
+		// A commit and its revert cancel out when both are on the same side.
+		for _, commits := range [][]*Commit{added, removed} {
+			bySHA := commitsBy(commits, func(c *Commit) string { return c.SHA })
+			for _, commit := range commits {
+				if commit.RevertOf != "" {
+					drop(commit, bySHA[commit.RevertOf])
+				}
+			}
+		}
+		// A cherry-pick cancels out the commit it was picked from, or another
+		// pick of the same commit, on the opposite side.
+		addedBySHA := commitsBy(added, func(c *Commit) string { return c.SHA })
+		removedBySHA := commitsBy(removed, func(c *Commit) string { return c.SHA })
+		removedByPick := commitsBy(removed, func(c *Commit) string { return c.CherryPickOf })
+		for _, commit := range added {
+			if commit.CherryPickOf == "" {
+				continue
+			}
+			drop(commit, removedBySHA[commit.CherryPickOf])
+			drop(commit, removedByPick[commit.CherryPickOf])
+		}
+		for _, commit := range removed {
+			if commit.CherryPickOf != "" {
+				drop(commit, addedBySHA[commit.CherryPickOf])
+			}
+		}
+		if len(dropped) == 0 {
+			continue
+		}
+		for _, changes := range []map[string]*RepoLog{additions, removals} {
+			repoLog, ok := changes[path]
+			if !ok {
+				continue
+			}
+			var kept []*Commit
+			for _, commit := range repoLog.Commits {
+				if dropped[commit] {
+					log.Debugf("collapsePairs: removed commit %s of repo %s at path %s", commit.SHA, repoLog.Repo, path)
+					continue
+				}
+				kept = append(kept, commit)
+			}
+			if len(kept) == 0 {
+				delete(changes, path)
+				continue
+			}
+			setCommits(repoLog, kept)
 		}
 	}
+}
 
-	sort.SliceStable(changes, func(i, j int) bool {
-		return changes[i][0] < changes[j][0]
-	})
+// setCommits replaces the commits of repoLog with a subset of them, and
+// removes the commits left out from its CommitCount if it is known.
+func setCommits(repoLog *RepoLog, commits []*Commit) {
+	if repoLog.CommitCount > 0 {
+		repoLog.CommitCount -= len(repoLog.Commits) - len(commits)
+	}
+	repoLog.Commits = commits
+}
 
-	return changes, foundSource, foundTarget
+// commitsBy indexes commits by a key, ignoring empty keys. The first commit
+// is kept for duplicate keys.
+func commitsBy(commits []*Commit, key func(*Commit) string) map[string]*Commit {
+	output := make(map[string]*Commit)
+	for _, commit := range commits {
+		k := key(commit)
+		if _, ok := output[k]; k != "" && !ok {
+			output[k] = commit
+		}
+	}
+	return output
 }
 
-// fetchSysctlToMap fetches sysctl file from artifacts in GCS created
-// by build-executor and map each line to a <parameter_name: value>
-// pair.
-func fetchSysctlToMap(path string, outputChan chan map[string]string, client *storage.Client, ctx context.Context) {
-	// Some sysctl value changes are insignificant and should not be displayed.
-	sysctlFilter := map[string]bool{
-		"kernel.hostname":                  true,
-		"kernel.version":                   true,
-		"fs.dentry-state":                  true,
-		"fs.file-nr":                       true,
-		"fs.inode-nr":                      true,
-		"fs.inode-state":                   true,
-		"fs.quota.syncs":                   true,
-		"kernel.ns_last_pid":               true,
-		"kernel.pty.nr":                    true,
-		"kernel.random.boot_id":            true,
-		"kernel.random.entropy_avail":      true,
-		"kernel.random.uuid":               true,
-		"net.netfilter.nf_conntrack_count": true,
-		"kernel.osrelease":                 true,
-		"net.ipv4.tcp_fastopen_key":        true,
+// SysctlChange is a sysctl parameter that differs between two builds.
+type SysctlChange = ArtifactChange
+
+// SysctlDiff is the difference between the sysctl parameters of two builds.
+// Each list of changes is sorted by parameter name.
+type SysctlDiff = ArtifactDiff
+
+// GetSysctlDiff finds sysctl difference between the two builds.
+//
+// client is used to read sysctl artifacts from GCS, and may be shared between
+// concurrent calls. If client is nil, a new client is created and closed
+// before returning.
+//
+// If the builds are on different milestones, GCS is not queried and an error
+// wrapping ErrSysctlMilestoneMismatch is returned.
+func GetSysctlDiff(client *storage.Client, bucket, sourceBoard, sourceMilestone, source, targetBoard, targetMilestone, target string) (*SysctlDiff, error) {
+	if sourceMilestone != targetMilestone {
+		return nil, fmt.Errorf("%w: source milestone %s, target milestone %s", ErrSysctlMilestoneMismatch, sourceMilestone, targetMilestone)
 	}
-	outMap := make(map[string]string)
-	defer func() { outputChan <- outMap }()
-	rc, err := client.Bucket(path).Object("sysctl_a.txt").NewReader(ctx)
+	diff, err := compareBuildArtifact(client, bucket, sourceBoard, sourceMilestone, source,
+		targetBoard, targetMilestone, target, sysctlFileNames(""), significantSysctl)
 	if err != nil {
-		log.Errorf("failed to open %s at %s (error:%s)", "sysctl_a.txt", path, err)
-		return
+		log.Errorf("failed to compare sysctl files (error: %s)", err)
+		return &SysctlDiff{}, nil
 	}
+	return diff, nil
+}
 
-	byteBuf, err := ioutil.ReadAll(rc)
-	rc.Close()
-	if err != nil {
-		log.Errorf("failed to read sysctl file (error:%s)", err)
-		return
+// sysctlFileNames returns the object names to try when fetching a sysctl
+// artifact, starting with fileName if it is set, followed by the known
+// artifact names.
+func sysctlFileNames(fileName string) []string {
+	names := []string{}
+	if fileName != "" {
+		names = append(names, fileName)
 	}
-	separator := " = "
-	for _, line := range strings.Split(string(byteBuf), "\n") {
-		parts := strings.Split(line, separator)
-		// Insignificant sysctl parameters are excluded.
-		if _, found := sysctlFilter[parts[0]]; found {
-			continue
-		}
-		// no value for this parameter
-		if len(parts) == 2 && parts[1] == "" {
-			outMap[parts[0]] = "---"
-		} else {
-			// assume the parameter name is before the first separator.
-			outMap[parts[0]] = strings.Join(parts[1:], separator)
+	for _, name := range knownSysctlFileNames {
+		if name != fileName {
+			names = append(names, name)
 		}
 	}
+	return names
+}
+
+// significantSysctl reports whether changes to a sysctl parameter should be
+// displayed.
+func significantSysctl(name string) bool {
+	return !insignificantSysctls[name]
+}
+
+// fetchSysctlToMap fetches sysctl file from artifacts in GCS created
+// by build-executor and map each line to a <parameter_name: value>
+// pair.
+//
+// fileName is the object name of the sysctl artifact. If it is empty or not
+// found, the known artifact names are tried in order. An empty map is returned
+// if none of them exist.
+func fetchSysctlToMap(path, fileName string, outputChan chan map[string]string, client *storage.Client, ctx context.Context) {
+	values, _ := fetchArtifactToMap(ctx, client, path, sysctlFileNames(fileName), significantSysctl)
+	outputChan <- values
 }
 
 // Changelog generates a changelog between 2 build numbers
@@ -421,6 +1160,14 @@ func fetchSysctlToMap(path string, outputChan chan map[string]string, client *st
 // a tag that links directly to snapshot.xml
 // Ex. For /refs/tags/15049.0.0, the argument should be 15049.0.0
 //
+// Either build may be "latest", which resolves to the newest build on the
+// release branch of the other build: builds X.0.0 are on the main branch,
+// and other builds X.Y.Z are on release branch X. A branch point build X.0.0
+// is treated as a main branch build, so "latest" resolves to the newest main
+// branch build rather than the newest build on release branch X. If both
+// builds are "latest", or the other build is not a build number, "latest"
+// resolves to the newest main branch build.
+//
 // host should be the GoB instance that Manifest files are hosted in
 // ex. "cos.googlesource.com"
 //
@@ -436,53 +1183,445 @@ func fetchSysctlToMap(path string, outputChan chan map[string]string, client *st
 //
 // The second changelog contains all commits that are present in the source build
 // but not present in the target build
-func Changelog(httpClient *http.Client, source, target, host, repo, croslandURL string, querySize int) (map[string]*RepoLog, map[string]*RepoLog, utils.ChangelogError) {
+//
+// Outstanding Gitiles requests are cancelled when ctx is done, in which case
+// an error wrapping the context error is returned.
+func Changelog(ctx context.Context, httpClient *http.Client, source, target, host, repo, croslandURL string, querySize int) (map[string]*RepoLog, map[string]*RepoLog, utils.ChangelogError) {
+	out := make(chan RepoLogResult)
+	errChan := make(chan utils.ChangelogError, 1)
+	go func() {
+		errChan <- ChangelogStream(ctx, httpClient, source, target, host, repo, croslandURL, querySize, out)
+	}()
+	additions := make(map[string]*RepoLog)
+	removals := make(map[string]*RepoLog)
+	for res := range out {
+		if res.Removed {
+			removals[res.Path] = res.Log
+		} else {
+			additions[res.Path] = res.Log
+		}
+	}
+	if err := <-errChan; err != nil {
+		return nil, nil, err
+	}
+	dedupCommits(additions)
+	dedupCommits(removals)
+	return additions, removals, nil
+}
+
+// ChangelogStream generates a changelog between 2 build numbers like
+// Changelog, sending the log of each repository with commits to out as soon
+// as it is retrieved instead of returning the complete changelog. out is
+// closed when the changelog is complete or fails.
+//
+// Unlike Changelog, the commits of a repository referenced at several paths
+// of the manifest are sent for each path.
+//
+// If the commits of a repository cannot be retrieved, the outstanding Gitiles
+// requests are cancelled and the error is returned. Logs sent before the
+// error do not form a complete changelog.
+func ChangelogStream(ctx context.Context, httpClient *http.Client, source, target, host, manifestRepo, croslandURL string, querySize int, out chan<- RepoLogResult) utils.ChangelogError {
+	defer close(out)
+	clients, sourceRepos, targetRepos, err := changelogRepos(ctx, httpClient, source, target, host, manifestRepo, croslandURL, nil)
+	if err != nil {
+		return err
+	}
+	if err := createGitilesClients(clients, httpClient, sourceRepos); err != nil {
+		return err
+	}
+	if err := createGitilesClients(clients, httpClient, targetRepos); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errChan := make(chan utils.ChangelogError, 2)
+	stream := func(sourceRepos, targetRepos map[string]*repo, removed bool) {
+		_, err := repoLogs(ctx, clients, sourceRepos, targetRepos, querySize, false, false, func(path string, repoLog *RepoLog) {
+			select {
+			case out <- RepoLogResult{Path: path, Removed: removed, Log: repoLog}:
+			case <-ctx.Done():
+			}
+		})
+		// Report the error before cancelling, so that it is returned
+		// instead of the cancellation of the other direction.
+		errChan <- err
+		if err != nil {
+			cancel()
+		}
+	}
+	go stream(sourceRepos, targetRepos, false)
+	go stream(targetRepos, sourceRepos, true)
+	var firstErr utils.ChangelogError
+	for i := 0; i < 2; i++ {
+		if err := <-errChan; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ChangelogWithOptions generates a changelog between 2 build numbers like
+// Changelog, with additional behavior controlled by opts.
+//
+// If opts specifies a repository allowlist or denylist, repositories are
+// filtered out of both manifests before any commits are requested, so
+// excluded repositories never generate Gitiles queries.
+//
+// The third output lists the repositories whose commits could not be
+// retrieved, sorted by path. It is only populated if opts.Lenient is set.
+func ChangelogWithOptions(ctx context.Context, httpClient *http.Client, source, target, host, repo, croslandURL string, querySize int, opts *Options) (map[string]*RepoLog, map[string]*RepoLog, []RepoError, utils.ChangelogError) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	clients, sourceRepos, targetRepos, err := changelogRepos(ctx, httpClient, source, target, host, repo, croslandURL, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// Commits matching the bug filter may be past the first querySize
+	// commits, so the filter is applied to every commit before truncating.
+	fetchSize := querySize
+	if opts.BugFilter != "" {
+		fetchSize = -1
+	}
+	additions, removals, failed, err := repoChangelog(ctx, clients, httpClient, sourceRepos, targetRepos, fetchSize, opts.Lenient, opts.CountCommits)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if opts.CollapsePairs {
+		collapsePairs(additions, removals)
+	}
+	if opts.BugFilter != "" {
+		filterCommitsByBug(additions, opts.BugFilter)
+		filterCommitsByBug(removals, opts.BugFilter)
+		truncateCommits(additions, querySize)
+		truncateCommits(removals, querySize)
+	}
+	// Diffs are retrieved last, so that commits filtered out of the
+	// changelog are not requested.
+	if opts.DiffStats {
+		if err := addDiffStats(ctx, httpClient, additions, removals); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return additions, removals, failed, nil
+}
+
+// ResolveLatest resolves the "latest" keyword in source or target as
+// described in Changelog, by listing the builds in the manifest repository
+// repo on the GoB instance host. Builds other than "latest" are returned
+// unchanged, and no request is made if neither build is "latest".
+//
+// Callers that derive state from the builds of a changelog, such as cache
+// keys or artifact paths, should resolve "latest" first, since the build it
+// refers to changes over time.
+func ResolveLatest(httpClient *http.Client, source, target, host, repo string) (string, string, utils.ChangelogError) {
+	if source != latestBuild && target != latestBuild {
+		return source, target, nil
+	}
 	if httpClient == nil {
 		log.Error("httpClient is nil")
-		return nil, nil, utils.InternalServerError
+		return "", "", utils.InternalServerError
+	}
+	manifestClient, err := gitilesClient(httpClient, host)
+	if err != nil {
+		return "", "", err
+	}
+	sourceBuildNum, targetBuildNum, err := resolveLatest(manifestClient, repo, resolveImageName(source), resolveImageName(target))
+	if err != nil {
+		return "", "", err
+	}
+	if source == latestBuild {
+		source = sourceBuildNum
+	}
+	if target == latestBuild {
+		target = targetBuildNum
+	}
+	return source, target, nil
+}
+
+// changelogRepos retrieves the manifests of 2 builds for a changelog and
+// returns the repositories of each build, filtered according to opts, along
+// with the Gitiles clients created to retrieve them. A nil opts is
+// equivalent to the zero value.
+func changelogRepos(ctx context.Context, httpClient *http.Client, source, target, host, repo, croslandURL string, opts *Options) (map[string]gitilesProto.GitilesClient, map[string]*repo, map[string]*repo, utils.ChangelogError) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if httpClient == nil {
+		log.Error("httpClient is nil")
+		return nil, nil, nil, utils.InternalServerError
 	}
 	sourceBuildNum, targetBuildNum := resolveImageName(source), resolveImageName(target)
-	log.Infof("Retrieving changelog between %s and %s\n", sourceBuildNum, targetBuildNum)
 	clients := make(map[string]gitilesProto.GitilesClient)
 
 	// Since the manifest file is always in the cos instance, add cos client
 	// so that client knows what URL to use
 	manifestClient, err := gitilesClient(httpClient, host)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	sourceRepos, sourceErr := mappedManifest(manifestClient, repo, source, sourceBuildNum)
-	targetRepos, targetErr := mappedManifest(manifestClient, repo, target, targetBuildNum)
+	sourceBuildNum, targetBuildNum, err = resolveLatest(manifestClient, repo, sourceBuildNum, targetBuildNum)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	log.Infof("Retrieving changelog between %s and %s\n", sourceBuildNum, targetBuildNum)
+	sourceRepos, sourceErr := opts.ManifestCache.mappedManifest(manifestClient, host, repo, source, sourceBuildNum)
+	targetRepos, targetErr := opts.ManifestCache.mappedManifest(manifestClient, host, repo, target, targetBuildNum)
 	if sourceErr != nil && sourceErr.HTTPCode() == "404" && targetErr != nil && targetErr.HTTPCode() == "404" {
-		return nil, nil, utils.BothBuildsNotFound(croslandURL, source, target, sourceBuildNum, targetBuildNum)
+		builds, err := utils.ListBuilds(manifestClient, repo)
+		if err != nil {
+			log.Errorf("failed to list builds in repo %s, returning error without suggestions:\n%v", repo, err)
+			return nil, nil, nil, utils.BothBuildsNotFound(croslandURL, source, target, sourceBuildNum, targetBuildNum)
+		}
+		return nil, nil, nil, utils.BothBuildsNotFoundWithSuggestions(croslandURL, source, target, sourceBuildNum, targetBuildNum,
+			similarBuilds(builds, sourceBuildNum), similarBuilds(builds, targetBuildNum))
 	} else if sourceErr != nil {
-		return nil, nil, sourceErr
+		return nil, nil, nil, sourceErr
 	} else if targetErr != nil {
-		return nil, nil, targetErr
+		return nil, nil, nil, targetErr
 	}
+	sourceRepos = filterRepos(sourceRepos, opts.RepoAllowlist, opts.RepoDenylist)
+	targetRepos = filterRepos(targetRepos, opts.RepoAllowlist, opts.RepoDenylist)
 
+	if ctx.Err() != nil {
+		return nil, nil, nil, utils.RequestCanceled(ctx.Err())
+	}
 	clients[host] = manifestClient
-	err = createGitilesClients(clients, httpClient, sourceRepos)
+	return clients, sourceRepos, targetRepos, nil
+}
+
+// ChangelogFromManifests generates a changelog between 2 manifest files
+// instead of 2 build numbers. This allows a changelog to be generated without
+// access to a manifest snapshot repository, ex. from manifest files on disk.
+//
+// httpClient is a authorized http.Client object with Gerrit scope.
+//
+// sourceXML and targetXML should be the contents of the source and target
+// manifest files.
+//
+// querySize should be the number of commits that should be included in each
+// repository changelog. Specify as -1 to get all commits
+//
+// The outputs are the same as those of Changelog.
+func ChangelogFromManifests(httpClient *http.Client, sourceXML, targetXML string, querySize int) (map[string]*RepoLog, map[string]*RepoLog, utils.ChangelogError) {
+	if httpClient == nil {
+		log.Error("httpClient is nil")
+		return nil, nil, utils.InternalServerError
+	}
+	sourceRepos, err := repoMap(sourceXML)
 	if err != nil {
-		return nil, nil, err
+		log.Errorf("ChangelogFromManifests: error parsing source manifest:\n%v", err)
+		return nil, nil, utils.InvalidManifest("source")
+	}
+	targetRepos, err := repoMap(targetXML)
+	if err != nil {
+		log.Errorf("ChangelogFromManifests: error parsing target manifest:\n%v", err)
+		return nil, nil, utils.InvalidManifest("target")
+	}
+	additions, removals, _, utilErr := repoChangelog(context.Background(), make(map[string]gitilesProto.GitilesClient), httpClient, sourceRepos, targetRepos, querySize, false, false)
+	return additions, removals, utilErr
+}
+
+// ChangelogMulti generates the additions of several target builds relative to
+// a common base build, ex. to compare a hotfix build and a sibling branch
+// build against their parent.
+//
+// base and targets should be build numbers or image names. host,
+// manifestRepo and querySize are the same as host, repo and querySize for
+// Changelog.
+//
+// The output maps the build number of each target to its additions, which
+// are the same as the first output of Changelog between base and the target.
+// Gitiles clients are shared across all comparisons, and the comparisons are
+// made concurrently.
+func ChangelogMulti(httpClient *http.Client, base string, targets []string, host, manifestRepo string, querySize int) (map[string]map[string]*RepoLog, utils.ChangelogError) {
+	if httpClient == nil {
+		log.Error("httpClient is nil")
+		return nil, utils.InternalServerError
+	}
+	clients := make(map[string]gitilesProto.GitilesClient)
+	manifestClient, err := gitilesClient(httpClient, host)
+	if err != nil {
+		return nil, err
+	}
+	clients[host] = manifestClient
+	baseRepos, err := mappedManifest(manifestClient, manifestRepo, base, resolveImageName(base))
+	if err != nil {
+		return nil, err
+	}
+	if err := createGitilesClients(clients, httpClient, baseRepos); err != nil {
+		return nil, err
+	}
+	// Clients are created for every target before any commits are requested,
+	// so the clients map is only read concurrently.
+	targetRepos := make(map[string]map[string]*repo)
+	for _, target := range targets {
+		targetBuildNum := resolveImageName(target)
+		if _, ok := targetRepos[targetBuildNum]; ok {
+			continue
+		}
+		repos, err := mappedManifest(manifestClient, manifestRepo, target, targetBuildNum)
+		if err != nil {
+			return nil, err
+		}
+		if err := createGitilesClients(clients, httpClient, repos); err != nil {
+			return nil, err
+		}
+		targetRepos[targetBuildNum] = repos
+	}
+	log.Infof("Retrieving changelogs of %d builds relative to %s\n", len(targetRepos), base)
+	ctx := context.Background()
+	outputChans := make(map[string]chan additionsResult)
+	for targetBuildNum, repos := range targetRepos {
+		outputChans[targetBuildNum] = make(chan additionsResult, 1)
+		go additions(ctx, clients, baseRepos, repos, querySize, false, false, outputChans[targetBuildNum])
+	}
+	output := make(map[string]map[string]*RepoLog)
+	for targetBuildNum, outputChan := range outputChans {
+		res := <-outputChan
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		output[targetBuildNum] = res.Additions
+	}
+	return output, nil
+}
+
+// BuildLocation identifies the manifest snapshot of a build, for builds that
+// may belong to different boards with separate manifest repositories.
+type BuildLocation struct {
+	// Build is a build number or image name.
+	// ex. "15000.0.0" or "cos-dev-93-16623-0-0"
+	Build string
+	// Host is the Gitiles instance containing the manifest repository.
+	// ex. "cos.googlesource.com"
+	Host string
+	// Repo is the manifest repository. ex. "cos/manifest-snapshots"
+	Repo string
+}
+
+// CrossBoardLog is the output of CrossBoardChangelog.
+type CrossBoardLog struct {
+	// Additions and Removals are the same as the outputs of Changelog,
+	// restricted to repositories present on both boards.
+	Additions map[string]*RepoLog
+	Removals  map[string]*RepoLog
+	// SourceOnlyRepos and TargetOnlyRepos are the paths of repositories
+	// that only exist in the source or target manifest, sorted by path.
+	// Commits are not retrieved for these repositories.
+	SourceOnlyRepos []string
+	TargetOnlyRepos []string
+}
+
+// CrossBoardChangelog generates a best-effort changelog between 2 builds
+// whose manifests may be located in different repositories, such as builds
+// for different boards or architectures.
+//
+// Repositories are matched by path. Repositories that exist in only one of
+// the manifests are reported in SourceOnlyRepos or TargetOnlyRepos instead
+// of having their entire history counted as additions or removals.
+func CrossBoardChangelog(httpClient *http.Client, source, target BuildLocation, querySize int) (*CrossBoardLog, utils.ChangelogError) {
+	if httpClient == nil {
+		log.Error("httpClient is nil")
+		return nil, utils.InternalServerError
+	}
+	log.Infof("Retrieving cross-board changelog between %s/%s and %s/%s\n", source.Repo, source.Build, target.Repo, target.Build)
+	clients := make(map[string]gitilesProto.GitilesClient)
+	sourceRepos, err := locatedManifest(clients, httpClient, source)
+	if err != nil {
+		return nil, err
+	}
+	targetRepos, err := locatedManifest(clients, httpClient, target)
+	if err != nil {
+		return nil, err
+	}
+	output := &CrossBoardLog{
+		SourceOnlyRepos: exclusiveRepos(sourceRepos, targetRepos),
+		TargetOnlyRepos: exclusiveRepos(targetRepos, sourceRepos),
+	}
+	for _, path := range output.SourceOnlyRepos {
+		delete(sourceRepos, path)
+	}
+	for _, path := range output.TargetOnlyRepos {
+		delete(targetRepos, path)
+	}
+	output.Additions, output.Removals, _, err = repoChangelog(context.Background(), clients, httpClient, sourceRepos, targetRepos, querySize, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// locatedManifest retrieves the parsed manifest file of a build at loc.
+// clients is populated with the Gitiles client used to download it.
+func locatedManifest(clients map[string]gitilesProto.GitilesClient, httpClient *http.Client, loc BuildLocation) (map[string]*repo, utils.ChangelogError) {
+	client, ok := clients[loc.Host]
+	if !ok {
+		var err utils.ChangelogError
+		client, err = gitilesClient(httpClient, loc.Host)
+		if err != nil {
+			return nil, err
+		}
+		clients[loc.Host] = client
+	}
+	return mappedManifest(client, loc.Repo, loc.Build, resolveImageName(loc.Build))
+}
+
+// exclusiveRepos returns the sorted paths of repositories in repos that are
+// not in other.
+func exclusiveRepos(repos, other map[string]*repo) []string {
+	var output []string
+	for path := range repos {
+		if _, ok := other[path]; !ok {
+			output = append(output, path)
+		}
+	}
+	sort.Strings(output)
+	return output
+}
+
+// repoChangelog retrieves the commits added and removed between the
+// repositories of 2 parsed manifest files. clients is populated with
+// any missing Gitiles clients required to query the repositories.
+//
+// If lenient is set, repositories whose commits cannot be retrieved are
+// returned in a list sorted by path instead of failing the request. If
+// countCommits is set, commits past querySize are counted.
+func repoChangelog(ctx context.Context, clients map[string]gitilesProto.GitilesClient, httpClient *http.Client, sourceRepos, targetRepos map[string]*repo, querySize int, lenient, countCommits bool) (map[string]*RepoLog, map[string]*RepoLog, []RepoError, utils.ChangelogError) {
+	err := createGitilesClients(clients, httpClient, sourceRepos)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	err = createGitilesClients(clients, httpClient, targetRepos)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	addChan := make(chan additionsResult, 1)
 	missChan := make(chan additionsResult, 1)
-	go additions(clients, sourceRepos, targetRepos, querySize, addChan)
-	go additions(clients, targetRepos, sourceRepos, querySize, missChan)
+	go additions(ctx, clients, sourceRepos, targetRepos, querySize, lenient, countCommits, addChan)
+	go additions(ctx, clients, targetRepos, sourceRepos, querySize, lenient, countCommits, missChan)
 	missRes := <-missChan
 	if missRes.Err != nil {
-		return nil, nil, missRes.Err
+		return nil, nil, nil, missRes.Err
 	}
 	addRes := <-addChan
 	if addRes.Err != nil {
-		return nil, nil, addRes.Err
+		return nil, nil, nil, addRes.Err
 	}
 
-	return addRes.Additions, missRes.Additions, nil
+	// A repository that fails in both directions is only reported once.
+	var failed []RepoError
+	failedPaths := make(map[string]bool)
+	for _, repoErr := range append(addRes.Failed, missRes.Failed...) {
+		if !failedPaths[repoErr.Path] {
+			failedPaths[repoErr.Path] = true
+			failed = append(failed, repoErr)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Path < failed[j].Path
+	})
+	return addRes.Additions, missRes.Additions, failed, nil
 }
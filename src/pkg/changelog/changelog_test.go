@@ -16,15 +16,27 @@ package changelog
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/storage"
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
 	"go.chromium.org/luci/common/api/gerrit"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
+var record = flag.Bool("record", false, "record live Gerrit and Gitiles traffic into the replay fixtures in testdata/replay")
+
 const cosInstance = "cos.googlesource.com"
 const defaultManifestRepo = "cos/manifest-snapshots"
 
@@ -51,6 +63,20 @@ func commitsMatch(commits []*Commit, expectedCommits []string) bool {
 	return true
 }
 
+// replayClient returns an http.Client that replays the traffic recorded in
+// testdata/replay/<name>.json. If the -record flag is set, the fixture is
+// regenerated from live services using application default credentials.
+func replayClient(t *testing.T, name string) *http.Client {
+	var base *http.Client
+	if *record {
+		var err error
+		if base, err = getHTTPClient(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fakes.NewReplayClient(t, filepath.Join("testdata", "replay", name+".json"), *record, base)
+}
+
 func repoListInLog(log map[string]*RepoLog, check []string) error {
 	for _, check := range check {
 		if log, ok := log[check]; !ok || len(log.Commits) == 0 {
@@ -61,10 +87,10 @@ func repoListInLog(log map[string]*RepoLog, check []string) error {
 }
 
 func TestChangelog(t *testing.T) {
-	httpClient, _ := getHTTPClient()
+	httpClient := replayClient(t, "changelog")
 
 	// Test invalid source
-	additions, removals, err := Changelog(httpClient, "15", "15043.0.0", cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err := Changelog(context.Background(), httpClient, "15", "15043.0.0", cosInstance, defaultManifestRepo, "", -1)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -76,7 +102,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test invalid target
-	additions, removals, err = Changelog(httpClient, "15043.0.0", "abx", cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "15043.0.0", "abx", cosInstance, defaultManifestRepo, "", -1)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -88,7 +114,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test invalid instance
-	additions, removals, err = Changelog(httpClient, "15036.0.0", "15041.0.0", "com", defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "15036.0.0", "15041.0.0", "com", defaultManifestRepo, "", -1)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -100,7 +126,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test invalid manifest repo
-	additions, removals, err = Changelog(httpClient, "15036.0.0", "15041.0.0", cosInstance, "cos/not-a-repo", "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "15036.0.0", "15041.0.0", cosInstance, "cos/not-a-repo", "", -1)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -112,7 +138,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test build number higher than latest release
-	additions, removals, err = Changelog(httpClient, "15036.0.0", "99999.0.0", cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "15036.0.0", "99999.0.0", cosInstance, defaultManifestRepo, "", -1)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -124,7 +150,7 @@ func TestChangelog(t *testing.T) {
 	}
 
 	// Test manifest with remote urls specified and no default URL
-	additions, removals, err = Changelog(httpClient, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, "1.0.0", "2.0.0", cosInstance, defaultManifestRepo, "", -1)
 	if additions == nil {
 		t.Errorf("changelog failed, expected additions, got nil")
 	} else if removals == nil {
@@ -248,7 +274,7 @@ func TestChangelog(t *testing.T) {
 		"9bc12bb411f357188d008864f80dfba43210b9d8",
 		"bf0dd3757826b9bc9d7082f5f749ff7615d4bcb3",
 	}
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", -1)
 	if err != nil {
 		t.Errorf("changelog failed, expected no error, got %v", err)
 	} else if len(removals) != 0 {
@@ -292,7 +318,7 @@ func TestChangelog(t *testing.T) {
 		"src/platform2",
 		"src/third_party/chromiumos-overlay",
 	}
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", -1)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", -1)
 	if err != nil {
 		t.Errorf("changelog failed, expected no error, got %v", err)
 	}
@@ -306,7 +332,7 @@ func TestChangelog(t *testing.T) {
 	source = "15030.0.0"
 	target = "15050.0.0"
 	querySize := 50
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
 	if err != nil {
 		t.Errorf("changelog failed, expected no error, got %v", err)
 	} else if additions == nil {
@@ -332,7 +358,7 @@ func TestChangelog(t *testing.T) {
 	// Test changelog handles manifest with non-matching repositories
 	source = "12871.1177.0"
 	target = "12871.1179.0"
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
 	if err != nil {
 		t.Errorf("changelog failed, expected no error, got %v", err)
 	} else if len(removals) != 0 {
@@ -357,7 +383,7 @@ func TestChangelog(t *testing.T) {
 	// Test with different release branches
 	source = "13310.1035.0"
 	target = "15000.0.0"
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
 	if err != nil {
 		t.Errorf("Changelog failed, expected no error, got %v", err)
 	} else if len(additions) != 0 {
@@ -369,7 +395,7 @@ func TestChangelog(t *testing.T) {
 	// Test empty repository
 	source = "0.0.0"
 	target = "2.0.0"
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
 	if additions != nil {
 		t.Errorf("changelog failed, expected nil additions, got %v", additions)
 	} else if removals != nil {
@@ -383,7 +409,7 @@ func TestChangelog(t *testing.T) {
 	// Test image name
 	source = "cos-rc-85-13310-1034-0"
 	target = "cos-rc-85-13310-1030-0"
-	additions, removals, err = Changelog(httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
+	additions, removals, err = Changelog(context.Background(), httpClient, source, target, cosInstance, defaultManifestRepo, "", querySize)
 	if err != nil {
 		t.Errorf("Changelog failed, expected no error, got %v", err)
 	} else if len(additions) != 0 {
@@ -392,3 +418,1440 @@ func TestChangelog(t *testing.T) {
 		t.Errorf("Changelog failed, expected non-empty removals, got %v", removals)
 	}
 }
+
+func TestChangelogWithOptionsRepoFilter(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-b"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-b"},
+	)
+	fake.logs["kernel-b"] = []string{"kernel-b"}
+	fake.logs["overlays-b"] = []string{"overlays-b"}
+	fake.logs["scripts-b"] = []string{"scripts-b"}
+
+	tests := map[string]struct {
+		Opts          *Options
+		ExpectedRepos []string
+	}{
+		"no filter": {
+			Opts:          nil,
+			ExpectedRepos: []string{"cos/kernel", "cos/overlays", "cos/scripts"},
+		},
+		"allowlist": {
+			Opts:          &Options{RepoAllowlist: []string{"src/third_party/kernel"}},
+			ExpectedRepos: []string{"cos/kernel"},
+		},
+		"denylist": {
+			Opts:          &Options{RepoDenylist: []string{"src/scripts"}},
+			ExpectedRepos: []string{"cos/kernel", "cos/overlays"},
+		},
+		"allowlist and denylist": {
+			Opts: &Options{
+				RepoAllowlist: []string{"src/third_party/kernel", "src/overlays"},
+				RepoDenylist:  []string{"src/overlays"},
+			},
+			ExpectedRepos: []string{"cos/kernel"},
+		},
+		"allowlist with unknown repo": {
+			Opts:          &Options{RepoAllowlist: []string{"src/not-a-repo"}},
+			ExpectedRepos: []string{},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			before := fake.queriedRepos()
+			additions, _, _, err := ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1, test.Opts)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			after := fake.queriedRepos()
+			var queried []string
+			for repo, count := range after {
+				if count > before[repo] {
+					queried = append(queried, repo)
+				}
+			}
+			sort.Strings(queried)
+			if strings.Join(queried, ",") != strings.Join(test.ExpectedRepos, ",") {
+				t.Errorf("expected queried repos %v, got %v", test.ExpectedRepos, queried)
+			}
+			if len(additions) != len(test.ExpectedRepos) {
+				t.Errorf("expected %d repos in additions, got %d: %v", len(test.ExpectedRepos), len(additions), additions)
+			}
+			for _, repoLog := range additions {
+				found := false
+				for _, repo := range test.ExpectedRepos {
+					if repoLog.Repo == repo {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("unexpected repo %s in additions", repoLog.Repo)
+				}
+			}
+		})
+	}
+}
+
+func TestChangelogFromManifests(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.logs["4444444444444444444444444444444444444444"] = []string{
+		"4444444444444444444444444444444444444444",
+		"4444444444444444444444444444444444444443",
+	}
+	fake.logs["5555555555555555555555555555555555555555"] = []string{"5555555555555555555555555555555555555555"}
+	fake.logs["3333333333333333333333333333333333333333"] = []string{"3333333333333333333333333333333333333333"}
+	readManifest := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatalf("failed to read manifest %s: %v", name, err)
+		}
+		return strings.ReplaceAll(string(contents), cosInstance, fake.host())
+	}
+	source := readManifest("source_snapshot.xml")
+	target := readManifest("target_snapshot.xml")
+
+	additions, removals, err := ChangelogFromManifests(fake.client(), source, target, -1)
+	if err != nil {
+		t.Fatalf("ChangelogFromManifests failed, expected no error, got %v", err)
+	}
+	if len(additions) != 2 {
+		t.Errorf("ChangelogFromManifests failed, expected 2 repos in additions, got %v", additions)
+	}
+	if overlays, ok := additions["src/overlays"]; !ok {
+		t.Errorf("ChangelogFromManifests failed, expected src/overlays in additions")
+	} else if !commitsMatch(overlays.Commits, fake.logs["4444444444444444444444444444444444444444"]) {
+		t.Errorf("ChangelogFromManifests failed, unexpected commits for src/overlays: %v", overlays.Commits)
+	} else if overlays.SourceSHA != "1111111111111111111111111111111111111111" {
+		t.Errorf("ChangelogFromManifests failed, unexpected SourceSHA for src/overlays: %s", overlays.SourceSHA)
+	} else if overlays.TargetSHA != "4444444444444444444444444444444444444444" {
+		t.Errorf("ChangelogFromManifests failed, unexpected TargetSHA for src/overlays: %s", overlays.TargetSHA)
+	}
+	if added, ok := additions["src/platform/added"]; !ok {
+		t.Errorf("ChangelogFromManifests failed, expected src/platform/added in additions")
+	} else if added.SourceSHA != "" {
+		t.Errorf("ChangelogFromManifests failed, expected empty SourceSHA for src/platform/added, got %s", added.SourceSHA)
+	}
+	if len(removals) != 1 {
+		t.Errorf("ChangelogFromManifests failed, expected 1 repo in removals, got %v", removals)
+	} else if _, ok := removals["src/platform/removed"]; !ok {
+		t.Errorf("ChangelogFromManifests failed, expected src/platform/removed in removals")
+	}
+
+	// Test invalid manifest
+	additions, removals, err = ChangelogFromManifests(fake.client(), "not xml", target, -1)
+	if additions != nil || removals != nil {
+		t.Errorf("ChangelogFromManifests failed, expected nil changelogs, got %v and %v", additions, removals)
+	} else if err == nil {
+		t.Errorf("ChangelogFromManifests failed, expected error, got nil")
+	} else if err.HTTPCode() != "400" {
+		t.Errorf("ChangelogFromManifests failed, expected error code 400, got %s", err.HTTPCode())
+	}
+}
+
+func TestManifest(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["15050.0.0"] = fake.manifest([3]string{"cos/overlays", "src/overlays", "overlays-a"})
+
+	tests := map[string]struct {
+		Build        string
+		ExpectedErr  string
+		ExpectedBody string
+	}{
+		"build number": {
+			Build:        "15050.0.0",
+			ExpectedBody: fake.manifests["15050.0.0"],
+		},
+		"image name": {
+			Build:        "cos-dev-90-15050-0-0",
+			ExpectedBody: fake.manifests["15050.0.0"],
+		},
+		"build not found": {
+			Build:       "99999.0.0",
+			ExpectedErr: "404",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			manifest, err := Manifest(fake.client(), test.Build, fake.host(), defaultManifestRepo)
+			if test.ExpectedErr != "" {
+				if err == nil {
+					t.Fatalf("expected error code %s, got nil", test.ExpectedErr)
+				} else if err.HTTPCode() != test.ExpectedErr {
+					t.Errorf("expected error code %s, got %s", test.ExpectedErr, err.HTTPCode())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if manifest != test.ExpectedBody {
+				t.Errorf("expected manifest %q, got %q", test.ExpectedBody, manifest)
+			}
+		})
+	}
+}
+
+func TestSimilarBuilds(t *testing.T) {
+	builds := []string{"13310.1034.0", "13310.1035.0", "13310.1036.0", "13310.1050.0", "13310.1135.0", "15000.0.0"}
+	tests := map[string]struct {
+		BuildNum string
+		Expected []string
+	}{
+		"transposed digits":   {"13310.1053.0", []string{"13310.1050.0", "13310.1036.0", "13310.1035.0"}},
+		"single typo":         {"13310.1235.0", []string{"13310.1135.0", "13310.1035.0", "13310.1036.0"}},
+		"existing build":      {"15000.0.0", nil},
+		"no similar builds":   {"99999.9.9", nil},
+		"missing build patch": {"15000.0", []string{"15000.0.0"}},
+	}
+	for name, test := range tests {
+		got := similarBuilds(builds, test.BuildNum)
+		if !reflect.DeepEqual(got, test.Expected) {
+			t.Errorf("test %q failed: expected %v, got %v", name, test.Expected, got)
+		}
+	}
+}
+
+func TestChangelogBothBuildsNotFoundSuggestions(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["13310.1035.0"] = fake.manifest()
+	fake.manifests["13310.1041.0"] = fake.manifest()
+	fake.manifests["15000.0.0"] = fake.manifest()
+
+	_, _, err := Changelog(context.Background(), fake.client(), "13310.1053.0", "15000.0.1", fake.host(), "cos/manifest-snapshots", "https://crosland.corp.google.com", -1)
+	if err == nil {
+		t.Fatalf("changelog failed, expected error, got nil")
+	}
+	if err.HTTPCode() != "404" {
+		t.Errorf("changelog failed, expected error code 404, got %s", err.HTTPCode())
+	}
+	for _, hint := range []string{
+		"Builds similar to 13310.1053.0: 13310.1041.0, 13310.1035.0.",
+		"Builds similar to 15000.0.1: 15000.0.0.",
+	} {
+		if !strings.Contains(err.Error(), hint) {
+			t.Errorf("changelog failed, expected error %q to contain %q", err.Error(), hint)
+		}
+	}
+}
+
+func TestCrossBoardChangelog(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["cos/manifest-snapshots/15000.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+		[3]string{"cos/amd64-firmware", "src/firmware/amd64", "amd64-a"},
+	)
+	fake.manifests["cos/manifest-snapshots-arm64/15000.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+		[3]string{"cos/arm64-firmware", "src/firmware/arm64", "arm64-a"},
+	)
+	fake.logs["kernel-b"] = []string{"kernel-b"}
+	fake.logs["kernel-a"] = []string{}
+	fake.logs["overlays-a"] = []string{}
+
+	source := BuildLocation{Build: "15000.0.0", Host: fake.host(), Repo: "cos/manifest-snapshots"}
+	target := BuildLocation{Build: "cos-dev-93-15000-0-0", Host: fake.host(), Repo: "cos/manifest-snapshots-arm64"}
+	res, err := CrossBoardChangelog(fake.client(), source, target, -1)
+	if err != nil {
+		t.Fatalf("changelog failed, unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(res.SourceOnlyRepos, []string{"src/firmware/amd64"}) {
+		t.Errorf("changelog failed, expected source only repos [src/firmware/amd64], got %v", res.SourceOnlyRepos)
+	}
+	if !reflect.DeepEqual(res.TargetOnlyRepos, []string{"src/firmware/arm64"}) {
+		t.Errorf("changelog failed, expected target only repos [src/firmware/arm64], got %v", res.TargetOnlyRepos)
+	}
+	if len(res.Additions) != 1 || res.Additions["src/third_party/kernel"] == nil {
+		t.Errorf("changelog failed, expected additions only in src/third_party/kernel, got %v", res.Additions)
+	} else if kernel := res.Additions["src/third_party/kernel"]; kernel.SourceSHA != "kernel-a" || kernel.TargetSHA != "kernel-b" {
+		t.Errorf("changelog failed, expected kernel diff kernel-a..kernel-b, got %s..%s", kernel.SourceSHA, kernel.TargetSHA)
+	}
+	if len(res.Removals) != 0 {
+		t.Errorf("changelog failed, expected no removals, got %v", res.Removals)
+	}
+	for _, repo := range []string{"cos/amd64-firmware", "cos/arm64-firmware"} {
+		if count := fake.queriedRepos()[repo]; count != 0 {
+			t.Errorf("changelog failed, expected board specific repo %s not to be queried, got %d queries", repo, count)
+		}
+	}
+
+	// A build missing from its board's manifest repository is not found
+	target.Build = "15001.0.0"
+	if _, err := CrossBoardChangelog(fake.client(), source, target, -1); err == nil || err.HTTPCode() != "404" {
+		t.Errorf("changelog failed, expected 404 error for missing target build, got %v", err)
+	}
+}
+
+func TestDiffSysctl(t *testing.T) {
+	tests := map[string]struct {
+		Source   map[string]string
+		Target   map[string]string
+		Expected *SysctlDiff
+		Rows     [][]string
+	}{
+		"added removed and changed": {
+			Source: map[string]string{
+				"kernel.pid_max":          "32768",
+				"net.core.somaxconn":      "128",
+				"vm.swappiness":           "60",
+				"net.ipv4.tcp_syncookies": "1",
+			},
+			Target: map[string]string{
+				"kernel.pid_max":          "4194304",
+				"net.core.somaxconn":      "128",
+				"vm.overcommit_memory":    "1",
+				"net.ipv4.tcp_syncookies": "1",
+			},
+			Expected: &SysctlDiff{
+				Added:       []SysctlChange{{Name: "vm.overcommit_memory", TargetValue: "1", TargetFile: "sysctl-a.txt"}},
+				Removed:     []SysctlChange{{Name: "vm.swappiness", SourceValue: "60", SourceFile: "sysctl_a.txt"}},
+				Changed:     []SysctlChange{{Name: "kernel.pid_max", SourceValue: "32768", TargetValue: "4194304", SourceFile: "sysctl_a.txt", TargetFile: "sysctl-a.txt"}},
+				SourceFound: true,
+				TargetFound: true,
+			},
+			Rows: [][]string{
+				{"kernel.pid_max", "32768", "4194304"},
+				{"vm.overcommit_memory", "---", "1"},
+				{"vm.swappiness", "60", "---"},
+			},
+		},
+		"identical": {
+			Source:   map[string]string{"vm.swappiness": "60"},
+			Target:   map[string]string{"vm.swappiness": "60"},
+			Expected: &SysctlDiff{SourceFound: true, TargetFound: true},
+			Rows:     [][]string{},
+		},
+		"source not found": {
+			Source:   map[string]string{},
+			Target:   map[string]string{"vm.swappiness": "60"},
+			Expected: &SysctlDiff{SourceFound: false, TargetFound: true},
+			Rows:     [][]string{},
+		},
+		"target not found": {
+			Source:   map[string]string{"vm.swappiness": "60"},
+			Target:   nil,
+			Expected: &SysctlDiff{SourceFound: true, TargetFound: false},
+			Rows:     [][]string{},
+		},
+	}
+	for name, test := range tests {
+		diff := diffArtifact(test.Source, test.Target, "sysctl_a.txt", "sysctl-a.txt")
+		if !reflect.DeepEqual(diff, test.Expected) {
+			t.Errorf("test %q failed: expected %+v, got %+v", name, test.Expected, diff)
+		}
+		if rows := diff.Rows(); !reflect.DeepEqual(rows, test.Rows) {
+			t.Errorf("test %q failed: expected rows %v, got %v", name, test.Rows, rows)
+		}
+	}
+}
+
+func TestGetSysctlDiffInjectedClient(t *testing.T) {
+	ctx := context.Background()
+	gcs, err := fakes.NewGCSServer(ctx)
+	if err != nil {
+		t.Fatalf("failed to create fake GCS server: %v", err)
+	}
+	defer gcs.Server.Close()
+	defer gcs.Client.Close()
+	gcs.Objects["/bucket/lakitu-release/R93-16623.0.0/sysctl_a.txt"] = []byte("kernel.pid_max = 32768\nvm.swappiness = 60")
+	gcs.Objects["/bucket/lakitu-release/R93-16623.1.0/sysctl-a.txt"] = []byte("kernel.pid_max = 4194304\nvm.swappiness = 60")
+
+	created := 0
+	origNewStorageClient := newStorageClient
+	defer func() { newStorageClient = origNewStorageClient }()
+	newStorageClient = func(ctx context.Context) (*storage.Client, error) {
+		created++
+		return origNewStorageClient(ctx)
+	}
+
+	for i := 0; i < 2; i++ {
+		diff, err := GetSysctlDiff(gcs.Client, "bucket", "lakitu", "93", "16623.0.0", "lakitu", "93", "16623.1.0")
+		if err != nil {
+			t.Fatalf("GetSysctlDiff failed: %v", err)
+		}
+		expected := []SysctlChange{{Name: "kernel.pid_max", SourceValue: "32768", TargetValue: "4194304", SourceFile: "sysctl_a.txt", TargetFile: "sysctl-a.txt"}}
+		if !diff.SourceFound || !diff.TargetFound {
+			t.Errorf("expected both sysctl files to be found, got %+v", diff)
+		} else if !reflect.DeepEqual(diff.Changed, expected) {
+			t.Errorf("expected changes %v, got %v", expected, diff.Changed)
+		}
+	}
+	if created != 0 {
+		t.Errorf("expected no storage clients to be created when a client is injected, got %d", created)
+	}
+}
+
+func TestGetSysctlDiffMilestoneMismatch(t *testing.T) {
+	created := 0
+	origNewStorageClient := newStorageClient
+	defer func() { newStorageClient = origNewStorageClient }()
+	newStorageClient = func(ctx context.Context) (*storage.Client, error) {
+		created++
+		return origNewStorageClient(ctx)
+	}
+
+	diff, err := GetSysctlDiff(nil, "bucket", "lakitu", "93", "16623.0.0", "lakitu", "97", "16919.0.0")
+	if !errors.Is(err, ErrSysctlMilestoneMismatch) {
+		t.Errorf("expected error wrapping ErrSysctlMilestoneMismatch, got %v (diff %+v)", err, diff)
+	}
+	if created != 0 {
+		t.Errorf("expected GCS not to be queried for builds on different milestones, got %d storage clients", created)
+	}
+}
+
+func TestSysctlFileNames(t *testing.T) {
+	tests := map[string]struct {
+		FileName string
+		Expected []string
+	}{
+		"default":    {"", []string{"sysctl_a.txt", "sysctl-a.txt", "sysctl.txt"}},
+		"known name": {"sysctl.txt", []string{"sysctl.txt", "sysctl_a.txt", "sysctl-a.txt"}},
+		"custom":     {"sysctl_b.txt", []string{"sysctl_b.txt", "sysctl_a.txt", "sysctl-a.txt", "sysctl.txt"}},
+	}
+	for name, test := range tests {
+		if got := sysctlFileNames(test.FileName); !reflect.DeepEqual(got, test.Expected) {
+			t.Errorf("test %q failed: expected %v, got %v", name, test.Expected, got)
+		}
+	}
+}
+
+func TestFetchSysctlToMapFallback(t *testing.T) {
+	ctx := context.Background()
+	gcs, err := fakes.NewGCSServer(ctx)
+	if err != nil {
+		t.Fatalf("failed to create fake GCS server: %v", err)
+	}
+	defer gcs.Server.Close()
+	defer gcs.Client.Close()
+	gcs.Objects["/bucket/current/sysctl_a.txt"] = []byte("vm.swappiness = 60")
+	gcs.Objects["/bucket/legacy/sysctl.txt"] = []byte("vm.swappiness = 10")
+	gcs.Objects["/bucket/custom/sysctl_b.txt"] = []byte("vm.swappiness = 30")
+	gcs.Objects["/bucket/custom/sysctl_a.txt"] = []byte("vm.swappiness = 60")
+
+	tests := map[string]struct {
+		Path     string
+		FileName string
+		Expected map[string]string
+	}{
+		"default name":          {"bucket/current", "", map[string]string{"vm.swappiness": "60"}},
+		"fallback name":         {"bucket/legacy", "", map[string]string{"vm.swappiness": "10"}},
+		"requested name":        {"bucket/custom", "sysctl_b.txt", map[string]string{"vm.swappiness": "30"}},
+		"requested name absent": {"bucket/current", "sysctl_b.txt", map[string]string{"vm.swappiness": "60"}},
+		"all names absent":      {"bucket/missing", "", map[string]string{}},
+	}
+	for name, test := range tests {
+		out := make(chan map[string]string, 1)
+		fetchSysctlToMap(test.Path, test.FileName, out, gcs.Client, ctx)
+		if got := <-out; !reflect.DeepEqual(got, test.Expected) {
+			t.Errorf("test %q failed: expected %v, got %v", name, test.Expected, got)
+		}
+	}
+}
+
+func TestChangelogWithOptionsLenient(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-b"},
+	)
+	fake.logs["kernel-b"] = []string{"kernel-b"}
+	fake.logs["overlays-b"] = []string{"overlays-b"}
+	fake.failures["cos/overlays"] = http.StatusInternalServerError
+
+	// Strict mode fails the entire changelog
+	additions, removals, failed, err := ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1, nil)
+	if err == nil || err.HTTPCode() != "500" {
+		t.Errorf("changelog failed, expected error code 500 in strict mode, got %v", err)
+	} else if additions != nil || removals != nil || failed != nil {
+		t.Errorf("changelog failed, expected no results in strict mode, got %v, %v, %v", additions, removals, failed)
+	}
+
+	// Lenient mode returns the changelog for the remaining repos
+	additions, _, failed, err = ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1, &Options{Lenient: true})
+	if err != nil {
+		t.Fatalf("changelog failed, unexpected error in lenient mode: %v", err)
+	}
+	if err := repoListInLog(additions, []string{"src/third_party/kernel"}); err != nil {
+		t.Errorf("changelog failed, %v", err)
+	}
+	if _, ok := additions["src/overlays"]; ok {
+		t.Errorf("changelog failed, expected failing repo src/overlays to be omitted from additions")
+	}
+	if len(failed) != 1 {
+		t.Fatalf("changelog failed, expected 1 failed repo, got %v", failed)
+	}
+	if failed[0].Path != "src/overlays" || failed[0].Repo != "cos/overlays" || failed[0].Err.HTTPCode() != "500" {
+		t.Errorf("changelog failed, expected src/overlays to fail with error code 500, got %+v", failed[0])
+	}
+}
+
+func TestChangelogDedupCommits(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/platform", "src/platform", "platform-a"},
+		[3]string{"cos/platform", "src/platform-mirror", "platform-a"},
+		[3]string{"cos/platform-fork", "src/platform-fork", "fork-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/platform", "src/platform", "platform-b"},
+		[3]string{"cos/platform", "src/platform-mirror", "platform-b"},
+		[3]string{"cos/platform-fork", "src/platform-fork", "platform-b"},
+	)
+	fake.logs["platform-b"] = []string{"platform-b", "platform-shared"}
+
+	additions, _, err := Changelog(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1)
+	if err != nil {
+		t.Fatalf("changelog failed, unexpected error: %v", err)
+	}
+	if platform, ok := additions["src/platform"]; !ok || len(platform.Commits) != 2 {
+		t.Errorf("changelog failed, expected 2 commits in src/platform, got %v", additions["src/platform"])
+	}
+	if mirror, ok := additions["src/platform-mirror"]; ok {
+		t.Errorf("changelog failed, expected duplicate commits in src/platform-mirror to be collapsed, got %v", mirror.Commits)
+	}
+	for path, repoLog := range additions {
+		if repoLog.CommitCount != len(repoLog.Commits) {
+			t.Errorf("changelog failed, expected CommitCount %d for %s, got %d", len(repoLog.Commits), path, repoLog.CommitCount)
+		}
+	}
+	// A distinct repository with shared history keeps its commits
+	if fork, ok := additions["src/platform-fork"]; !ok || len(fork.Commits) != 2 {
+		t.Errorf("changelog failed, expected 2 commits in src/platform-fork, got %v", additions["src/platform-fork"])
+	}
+}
+
+func TestChangelogManifestIncludes(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+		[3]string{"cos/kernel", "src/kernel", "kernel-a"},
+	)
+	fake.manifests["2.0.0"] = `<manifest>
+  <include name="default.xml"/>
+  <project name="cos/overlays" path="src/overlays" revision="overlays-b"/>
+</manifest>`
+	// default.xml is included by 2.0.0 and includes the kernel manifest in turn.
+	defaults := fake.manifest()
+	defaults = strings.Replace(defaults, "</manifest>", `  <include name="kernel/kernel.xml"/>
+</manifest>`, 1)
+	fake.includes["2.0.0/default.xml"] = defaults
+	fake.includes["2.0.0/kernel/kernel.xml"] = `<manifest>
+  <project name="cos/kernel" path="src/kernel" revision="kernel-b"/>
+</manifest>`
+	fake.logs["overlays-b"] = []string{"overlays-b"}
+	fake.logs["kernel-b"] = []string{"kernel-b"}
+
+	additions, _, err := Changelog(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1)
+	if err != nil {
+		t.Fatalf("changelog failed, unexpected error: %v", err)
+	}
+	for _, path := range []string{"src/overlays", "src/kernel"} {
+		if repoLog, ok := additions[path]; !ok || len(repoLog.Commits) != 1 {
+			t.Errorf("changelog failed, expected 1 commit in %s, got %v", path, additions[path])
+		}
+	}
+
+	fake.includes["2.0.0/kernel/kernel.xml"] = `<manifest><include name="default.xml"/></manifest>`
+	if _, _, err := Changelog(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1); err == nil {
+		t.Errorf("changelog failed, expected error for include cycle, got nil")
+	}
+}
+
+func TestRepoMapRemoveAndExtendProject(t *testing.T) {
+	manifest := `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <remote fetch="https://chromium.googlesource.com" name="cros"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="cos/overlays" path="src/overlays" revision="1111"/>
+  <project name="cos/kernel" path="src/kernel/v5.4" revision="2222"/>
+  <project name="cos/kernel" path="src/kernel/v5.10" revision="3333"/>
+  <project name="cos/platform" path="src/platform" revision="4444"/>
+  <project name="cos/removed" path="src/removed" revision="5555"/>
+  <remove-project name="cos/removed"/>
+  <remove-project name="cos/overlays"/>
+  <project name="cos/overlays-fork" path="src/overlays" revision="6666"/>
+  <extend-project name="cos/kernel" path="src/kernel/v5.10" revision="7777" remote="cros"/>
+  <extend-project name="cos/platform" dest-path="src/platform2"/>
+</manifest>`
+	expected := map[string]*repo{
+		"src/overlays":     {Repo: "cos/overlays-fork", Path: "src/overlays", InstanceURL: "cos.googlesource.com", Committish: "6666"},
+		"src/kernel/v5.4":  {Repo: "cos/kernel", Path: "src/kernel/v5.4", InstanceURL: "cos.googlesource.com", Committish: "2222"},
+		"src/kernel/v5.10": {Repo: "cos/kernel", Path: "src/kernel/v5.10", InstanceURL: "chromium.googlesource.com", Committish: "7777"},
+		"src/platform2":    {Repo: "cos/platform", Path: "src/platform2", InstanceURL: "cos.googlesource.com", Committish: "4444"},
+	}
+	repos, err := repoMap(manifest)
+	if err != nil {
+		t.Fatalf("repoMap failed, unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(repos, expected) {
+		t.Errorf("repoMap failed, expected:")
+		for path, repoData := range expected {
+			t.Errorf("  %s: %+v", path, *repoData)
+		}
+		t.Errorf("got:")
+		for path, repoData := range repos {
+			t.Errorf("  %s: %+v", path, *repoData)
+		}
+	}
+}
+
+func TestRepoMapMissingAttributes(t *testing.T) {
+	tests := map[string]struct {
+		Manifest string
+		Expected map[string]*repo
+	}{
+		"no default": {
+			Manifest: `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <project name="cos/overlays" path="src/overlays" remote="cos" revision="1111"/>
+</manifest>`,
+			Expected: map[string]*repo{
+				"src/overlays": {Repo: "cos/overlays", Path: "src/overlays", InstanceURL: "cos.googlesource.com", Committish: "1111"},
+			},
+		},
+		"default revision": {
+			Manifest: `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="cos/overlays" path="src/overlays"/>
+</manifest>`,
+			Expected: map[string]*repo{
+				"src/overlays": {Repo: "cos/overlays", Path: "src/overlays", InstanceURL: "cos.googlesource.com", Committish: "refs/heads/master"},
+			},
+		},
+		"default path": {
+			Manifest: `<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="cos/overlays"/>
+  <project name="cos/kernel" path="src/kernel"/>
+</manifest>`,
+			Expected: map[string]*repo{
+				"cos/overlays": {Repo: "cos/overlays", Path: "cos/overlays", InstanceURL: "cos.googlesource.com", Committish: "refs/heads/master"},
+				"src/kernel":   {Repo: "cos/kernel", Path: "src/kernel", InstanceURL: "cos.googlesource.com", Committish: "refs/heads/master"},
+			},
+		},
+		"incomplete elements": {
+			Manifest: `<manifest>
+  <remote name="broken"/>
+  <remote fetch="https://chromium.googlesource.com"/>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos"/>
+  <project path="src/unnamed" revision="1111"/>
+  <project name="cos/kernel" path="src/kernel"/>
+  <project name="cos/overlays" path="src/overlays" revision="2222"/>
+</manifest>`,
+			Expected: map[string]*repo{
+				"src/overlays": {Repo: "cos/overlays", Path: "src/overlays", InstanceURL: "cos.googlesource.com", Committish: "2222"},
+			},
+		},
+	}
+	for name, test := range tests {
+		repos, err := repoMap(test.Manifest)
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(repos, test.Expected) {
+			t.Errorf("test %q failed: expected %v, got %v", name, test.Expected, repos)
+		}
+	}
+}
+
+func TestLatestBuildOnBranch(t *testing.T) {
+	builds := []string{"13310.0.0", "13310.1034.0", "13310.999.0", "13310.1035.1", "15000.0.0", "15041.0.0", "9999.0.0", "15041.1.0", "release-R85", "16000.0.0-rc1"}
+	tests := map[string]struct {
+		BuildNum string
+		Expected string
+	}{
+		"ReleaseBranch": {"13310.1034.0", "13310.1035.1"},
+		"MainBranch":    {"15000.0.0", "15041.0.0"},
+		"BranchPoint":   {"13310.0.0", "15041.0.0"},
+		"Latest":        {"", "15041.0.0"},
+		"NotABuild":     {"cos-rc-85", "15041.0.0"},
+		"UnknownBranch": {"12000.1.0", ""},
+	}
+	for name, test := range tests {
+		if latest := latestBuildOnBranch(builds, test.BuildNum); latest != test.Expected {
+			t.Errorf("test %q failed: expected %q, got %q", name, test.Expected, latest)
+		}
+	}
+}
+
+func TestBuildNumberToImageName(t *testing.T) {
+	tests := map[string]struct {
+		BuildNum  string
+		Channel   string
+		Milestone int
+		Expected  string
+		Err       bool
+	}{
+		"Dev":              {"13310.0.0", "dev", 85, "cos-dev-85-13310-0-0", false},
+		"Beta":             {"13310.1025.0", "beta", 85, "cos-beta-85-13310-1025-0", false},
+		"Stable":           {"13310.1041.9", "stable", 85, "cos-stable-85-13310-1041-9", false},
+		"RC":               {"13310.1041.1", "rc", 85, "cos-rc-85-13310-1041-1", false},
+		"NoChannel":        {"13310.1041.9", "", 85, "cos-85-13310-1041-9", false},
+		"InvalidBuildNum":  {"13310.1041", "stable", 85, "", true},
+		"ImageName":        {"cos-85-13310-1041-9", "", 85, "", true},
+		"InvalidChannel":   {"13310.1041.9", "lts", 85, "", true},
+		"InvalidMilestone": {"13310.1041.9", "stable", 0, "", true},
+	}
+	for name, test := range tests {
+		imageName, err := BuildNumberToImageName(test.BuildNum, test.Channel, test.Milestone)
+		if test.Err {
+			if err == nil {
+				t.Errorf("test %q failed: expected error, got image name %q", name, imageName)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if imageName != test.Expected {
+			t.Errorf("test %q failed: expected %q, got %q", name, test.Expected, imageName)
+		}
+		if buildNum := resolveImageName(imageName); buildNum != test.BuildNum {
+			t.Errorf("test %q failed: expected %q to resolve to %q, got %q", name, imageName, test.BuildNum, buildNum)
+		}
+	}
+}
+
+func TestParseImageName(t *testing.T) {
+	tests := map[string]struct {
+		ImageName string
+		Expected  *ImageName
+		Err       bool
+	}{
+		"Dev":            {"cos-dev-85-13310-0-0", &ImageName{"dev", 85, "13310.0.0"}, false},
+		"Beta":           {"cos-beta-85-13310-1025-0", &ImageName{"beta", 85, "13310.1025.0"}, false},
+		"Stable":         {"cos-stable-85-13310-1041-9", &ImageName{"stable", 85, "13310.1041.9"}, false},
+		"RC":             {"cos-rc-85-13310-1041-1", &ImageName{"rc", 85, "13310.1041.1"}, false},
+		"NoChannel":      {"cos-85-13310-1041-9", &ImageName{"", 85, "13310.1041.9"}, false},
+		"BuildNum":       {"13310.1041.9", nil, true},
+		"InvalidChannel": {"cos-lts-85-13310-1041-9", nil, true},
+		"ShortBuildNum":  {"cos-stable-85-13310-1041", nil, true},
+		"LongBuildNum":   {"cos-stable-85-13310-1041-9-1", nil, true},
+		"ZeroMilestone":  {"cos-stable-0-13310-1041-9", nil, true},
+		"Empty":          {"", nil, true},
+	}
+	for name, test := range tests {
+		parsed, err := ParseImageName(test.ImageName)
+		if test.Err {
+			if err == nil {
+				t.Errorf("test %q failed: expected error, got %+v", name, parsed)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(parsed, test.Expected) {
+			t.Errorf("test %q failed: expected %+v, got %+v", name, test.Expected, parsed)
+		}
+		imageName, err := BuildNumberToImageName(parsed.BuildNum, parsed.Channel, parsed.Milestone)
+		if err != nil || imageName != test.ImageName {
+			t.Errorf("test %q failed: expected %+v to format as %q, got %q (%v)", name, parsed, test.ImageName, imageName, err)
+		}
+	}
+}
+
+func TestChangelogLatest(t *testing.T) {
+	fake := newFakeGitiles(t)
+	for build, revision := range map[string]string{
+		"13310.999.0":  "overlays-r85-999",
+		"13310.1034.0": "overlays-r85-1034",
+		"13310.1100.0": "overlays-r85-1100",
+		"15000.0.0":    "overlays-15000",
+		"15041.0.0":    "overlays-15041",
+	} {
+		fake.manifests[build] = fake.manifest([3]string{"cos/overlays", "src/overlays", revision})
+		fake.logs[revision] = []string{revision}
+	}
+	tests := map[string]struct {
+		Source         string
+		Target         string
+		ExpectedSource string
+		ExpectedTarget string
+		ExpectedErr    string
+	}{
+		"LatestTargetOnReleaseBranch": {
+			Source:         "13310.999.0",
+			Target:         "latest",
+			ExpectedSource: "overlays-r85-999",
+			ExpectedTarget: "overlays-r85-1100",
+		},
+		"LatestSourceOnMainBranch": {
+			Source:         "latest",
+			Target:         "cos-dev-93-15000-0-0",
+			ExpectedSource: "overlays-15041",
+			ExpectedTarget: "overlays-15000",
+		},
+		"LatestOnUnknownBranch": {
+			Source:      "12000.1.0",
+			Target:      "latest",
+			ExpectedErr: "404",
+		},
+	}
+	for name, test := range tests {
+		additions, _, err := Changelog(context.Background(), fake.client(), test.Source, test.Target, fake.host(), defaultManifestRepo, "", -1)
+		if test.ExpectedErr != "" {
+			if err == nil || err.HTTPCode() != test.ExpectedErr {
+				t.Errorf("test %q failed: expected error code %s, got: %v", name, test.ExpectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		overlays, ok := additions["src/overlays"]
+		if !ok {
+			t.Errorf("test %q failed: expected changes in src/overlays, got %v", name, additions)
+			continue
+		}
+		if overlays.SourceSHA != test.ExpectedSource || overlays.TargetSHA != test.ExpectedTarget {
+			t.Errorf("test %q failed: expected changelog from %s to %s, got %s to %s",
+				name, test.ExpectedSource, test.ExpectedTarget, overlays.SourceSHA, overlays.TargetSHA)
+		}
+	}
+}
+
+func TestResolveLatest(t *testing.T) {
+	fake := newFakeGitiles(t)
+	for _, build := range []string{"13310.999.0", "13310.1100.0", "15000.0.0", "15041.0.0"} {
+		fake.manifests[build] = fake.manifest()
+	}
+	tests := map[string]struct {
+		Source         string
+		Target         string
+		ExpectedSource string
+		ExpectedTarget string
+		ExpectedErr    string
+	}{
+		"NoLatest": {
+			Source:         "13310.999.0",
+			Target:         "cos-dev-93-15000-0-0",
+			ExpectedSource: "13310.999.0",
+			ExpectedTarget: "cos-dev-93-15000-0-0",
+		},
+		"LatestTarget": {
+			Source:         "13310.999.0",
+			Target:         "latest",
+			ExpectedSource: "13310.999.0",
+			ExpectedTarget: "13310.1100.0",
+		},
+		"LatestSourceWithImageName": {
+			Source:         "latest",
+			Target:         "cos-dev-93-15000-0-0",
+			ExpectedSource: "15041.0.0",
+			ExpectedTarget: "cos-dev-93-15000-0-0",
+		},
+		"LatestOnUnknownBranch": {
+			Source:      "12000.1.0",
+			Target:      "latest",
+			ExpectedErr: "404",
+		},
+	}
+	for name, test := range tests {
+		source, target, err := ResolveLatest(fake.client(), test.Source, test.Target, fake.host(), defaultManifestRepo)
+		if test.ExpectedErr != "" {
+			if err == nil || err.HTTPCode() != test.ExpectedErr {
+				t.Errorf("test %q failed: expected error code %s, got: %v", name, test.ExpectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if source != test.ExpectedSource || target != test.ExpectedTarget {
+			t.Errorf("test %q failed: expected %s and %s, got %s and %s", name, test.ExpectedSource, test.ExpectedTarget, source, target)
+		}
+	}
+}
+
+func TestChangelogCancelled(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+		[3]string{"cos/kernel", "src/kernel", "kernel-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/overlays", "src/overlays", "overlays-b"},
+		[3]string{"cos/kernel", "src/kernel", "kernel-b"},
+	)
+	fake.logs["overlays-b"] = []string{"overlays-b"}
+	fake.logs["kernel-b"] = []string{"kernel-b"}
+	fake.blockLogs = make(chan struct{})
+	defer close(fake.blockLogs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	additions, removals, err := Changelog(ctx, fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1)
+	if err == nil {
+		t.Fatalf("changelog failed, expected error for cancelled context, got additions %v and removals %v", additions, removals)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("changelog failed, expected error wrapping %v, got %v", context.DeadlineExceeded, err)
+	}
+	if err.HTTPCode() != "504" {
+		t.Errorf("changelog failed, expected error code 504, got %s", err.HTTPCode())
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("changelog failed, expected early return after cancellation, took %s", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := Changelog(ctx, fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1); !errors.Is(err, context.Canceled) {
+		t.Errorf("changelog failed, expected error wrapping %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestChangelogWithOptionsBugFilter(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-b"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-b"},
+	)
+	fake.logs["kernel-b"] = []string{"kernel-b", "kernel-fix"}
+	fake.logs["overlays-b"] = []string{"overlays-b"}
+	fake.logs["scripts-b"] = []string{"scripts-b"}
+	fake.logs["kernel-a"] = []string{"kernel-a", "kernel-revert"}
+	fake.logs["scripts-a"] = []string{"scripts-a"}
+	fake.bugs["kernel-fix"] = "b/2, b/3"
+	fake.bugs["overlays-b"] = "b/3"
+	fake.bugs["kernel-revert"] = "b/3"
+
+	tests := map[string]struct {
+		BugFilter         string
+		QuerySize         int
+		ExpectedAdditions map[string][]string
+		ExpectedRemovals  map[string][]string
+	}{
+		"no filter": {
+			BugFilter: "",
+			QuerySize: -1,
+			ExpectedAdditions: map[string][]string{
+				"src/third_party/kernel": {"kernel-b", "kernel-fix"},
+				"src/overlays":           {"overlays-b"},
+				"src/scripts":            {"scripts-b"},
+			},
+			ExpectedRemovals: map[string][]string{
+				"src/third_party/kernel": {"kernel-a", "kernel-revert"},
+				"src/scripts":            {"scripts-a"},
+			},
+		},
+		"bug in some commits": {
+			BugFilter: "b/3",
+			QuerySize: -1,
+			ExpectedAdditions: map[string][]string{
+				"src/third_party/kernel": {"kernel-fix"},
+				"src/overlays":           {"overlays-b"},
+			},
+			ExpectedRemovals: map[string][]string{
+				"src/third_party/kernel": {"kernel-revert"},
+			},
+		},
+		"bug in additions only": {
+			BugFilter: "b/2",
+			QuerySize: -1,
+			ExpectedAdditions: map[string][]string{
+				"src/third_party/kernel": {"kernel-fix"},
+			},
+			ExpectedRemovals: map[string][]string{},
+		},
+		"bug past query size": {
+			BugFilter: "b/3",
+			QuerySize: 1,
+			ExpectedAdditions: map[string][]string{
+				"src/third_party/kernel": {"kernel-fix"},
+				"src/overlays":           {"overlays-b"},
+			},
+			ExpectedRemovals: map[string][]string{
+				"src/third_party/kernel": {"kernel-revert"},
+			},
+		},
+		"unknown bug": {
+			BugFilter:         "b/4",
+			QuerySize:         -1,
+			ExpectedAdditions: map[string][]string{},
+			ExpectedRemovals:  map[string][]string{},
+		},
+	}
+	shas := func(changes map[string]*RepoLog) map[string][]string {
+		out := make(map[string][]string)
+		for path, repoLog := range changes {
+			for _, commit := range repoLog.Commits {
+				out[path] = append(out[path], commit.SHA)
+			}
+		}
+		return out
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			additions, removals, _, err := ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", test.QuerySize, &Options{BugFilter: test.BugFilter})
+			if err != nil {
+				t.Fatalf("changelog failed, unexpected error: %v", err)
+			}
+			if got := shas(additions); !reflect.DeepEqual(got, test.ExpectedAdditions) {
+				t.Errorf("changelog failed, expected additions %v, got %v", test.ExpectedAdditions, got)
+			}
+			if got := shas(removals); !reflect.DeepEqual(got, test.ExpectedRemovals) {
+				t.Errorf("changelog failed, expected removals %v, got %v", test.ExpectedRemovals, got)
+			}
+			for path, repoLog := range additions {
+				if repoLog.HasMoreCommits {
+					t.Errorf("changelog failed, additions of %s have more commits than the %d matching", path, len(repoLog.Commits))
+				}
+			}
+		})
+	}
+}
+
+func TestChangelogCommitCount(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-b"},
+	)
+	var kernelLog []string
+	for i := 0; i < 250; i++ {
+		kernelLog = append(kernelLog, fmt.Sprintf("kernel-%d", i))
+	}
+	fake.logs["kernel-b"] = kernelLog
+	fake.logs["scripts-b"] = []string{"scripts-b", "scripts-1", "scripts-2"}
+
+	tests := map[string]struct {
+		QuerySize       int
+		CountCommits    bool
+		ExpectedCommits map[string]int
+		ExpectedCounts  map[string]int
+		ExpectedMore    map[string]bool
+	}{
+		"all commits": {
+			QuerySize:       -1,
+			ExpectedCommits: map[string]int{"src/third_party/kernel": 250, "src/scripts": 3},
+			ExpectedCounts:  map[string]int{"src/third_party/kernel": 250, "src/scripts": 3},
+			ExpectedMore:    map[string]bool{"src/third_party/kernel": false, "src/scripts": false},
+		},
+		"truncated": {
+			QuerySize:       50,
+			ExpectedCommits: map[string]int{"src/third_party/kernel": 50, "src/scripts": 3},
+			ExpectedCounts:  map[string]int{"src/third_party/kernel": 0, "src/scripts": 3},
+			ExpectedMore:    map[string]bool{"src/third_party/kernel": true, "src/scripts": false},
+		},
+		"truncated and counted": {
+			QuerySize:       50,
+			CountCommits:    true,
+			ExpectedCommits: map[string]int{"src/third_party/kernel": 50, "src/scripts": 3},
+			ExpectedCounts:  map[string]int{"src/third_party/kernel": 250, "src/scripts": 3},
+			ExpectedMore:    map[string]bool{"src/third_party/kernel": true, "src/scripts": false},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			additions, _, _, err := ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", test.QuerySize, &Options{CountCommits: test.CountCommits})
+			if err != nil {
+				t.Fatalf("changelog failed, unexpected error: %v", err)
+			}
+			for path, expectedCommits := range test.ExpectedCommits {
+				repoLog, ok := additions[path]
+				if !ok {
+					t.Fatalf("changelog failed, expected additions for %s", path)
+				}
+				if len(repoLog.Commits) != expectedCommits {
+					t.Errorf("changelog failed, expected %d commits for %s, got %d", expectedCommits, path, len(repoLog.Commits))
+				}
+				if repoLog.CommitCount != test.ExpectedCounts[path] {
+					t.Errorf("changelog failed, expected CommitCount %d for %s, got %d", test.ExpectedCounts[path], path, repoLog.CommitCount)
+				}
+				if repoLog.HasMoreCommits != test.ExpectedMore[path] {
+					t.Errorf("changelog failed, expected HasMoreCommits %t for %s, got %t", test.ExpectedMore[path], path, repoLog.HasMoreCommits)
+				}
+			}
+		})
+	}
+}
+
+func TestChangelogLogPageTokens(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest([3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"})
+	fake.manifests["2.0.0"] = fake.manifest([3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"})
+	var kernelLog []string
+	for i := 0; i < 2*MaxLogLinkCommits+5; i++ {
+		kernelLog = append(kernelLog, fmt.Sprintf("kernel-%d", i))
+	}
+	fake.logs["kernel-b"] = kernelLog
+
+	tests := map[string]struct {
+		QuerySize      int
+		CountCommits   bool
+		ExpectedTokens map[int]string
+	}{
+		"retrieved": {
+			QuerySize:      -1,
+			ExpectedTokens: map[int]string{MaxLogLinkCommits: "kernel-10000", 2 * MaxLogLinkCommits: "kernel-20000"},
+		},
+		// The fake server uses the position of the next commit as page token.
+		"counted": {
+			QuerySize:      50,
+			CountCommits:   true,
+			ExpectedTokens: map[int]string{MaxLogLinkCommits: "10000", 2 * MaxLogLinkCommits: "20000"},
+		},
+		"not counted": {
+			QuerySize: 50,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			additions, _, _, err := ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", test.QuerySize, &Options{CountCommits: test.CountCommits})
+			if err != nil {
+				t.Fatalf("changelog failed, unexpected error: %v", err)
+			}
+			repoLog, ok := additions["src/third_party/kernel"]
+			if !ok {
+				t.Fatal("changelog failed, expected additions for src/third_party/kernel")
+			}
+			if !reflect.DeepEqual(repoLog.LogPageTokens, test.ExpectedTokens) {
+				t.Errorf("changelog failed, expected LogPageTokens %v, got %v", test.ExpectedTokens, repoLog.LogPageTokens)
+			}
+		})
+	}
+}
+
+func TestChangelogMulti(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-a"},
+	)
+	fake.manifests["1.1.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-hotfix"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-b"},
+	)
+	fake.logs["kernel-hotfix"] = []string{"kernel-hotfix"}
+	fake.logs["kernel-b"] = []string{"kernel-b", "kernel-1"}
+	fake.logs["scripts-a"] = []string{}
+	fake.logs["scripts-b"] = []string{"scripts-b"}
+
+	tests := map[string]struct {
+		Targets     []string
+		Expected    map[string]map[string][]string
+		ShouldError bool
+		ErrCode     string
+	}{
+		"two targets": {
+			Targets: []string{"1.1.0", "2.0.0"},
+			Expected: map[string]map[string][]string{
+				"1.1.0": {"src/third_party/kernel": {"kernel-hotfix"}},
+				"2.0.0": {"src/third_party/kernel": {"kernel-b", "kernel-1"}, "src/scripts": {"scripts-b"}},
+			},
+		},
+		"duplicate targets": {
+			Targets: []string{"1.1.0", "1.1.0"},
+			Expected: map[string]map[string][]string{
+				"1.1.0": {"src/third_party/kernel": {"kernel-hotfix"}},
+			},
+		},
+		"no targets": {
+			Targets:  nil,
+			Expected: map[string]map[string][]string{},
+		},
+		"missing target": {
+			Targets:     []string{"1.1.0", "3.0.0"},
+			ShouldError: true,
+			ErrCode:     "404",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			output, err := ChangelogMulti(fake.client(), "1.0.0", test.Targets, fake.host(), defaultManifestRepo, -1)
+			if test.ShouldError {
+				if err == nil {
+					t.Fatalf("changelog failed, expected error, got none")
+				}
+				if err.HTTPCode() != test.ErrCode {
+					t.Errorf("changelog failed, expected error code %s, got %s", test.ErrCode, err.HTTPCode())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("changelog failed, unexpected error: %v", err)
+			}
+			got := make(map[string]map[string][]string)
+			for target, changes := range output {
+				got[target] = make(map[string][]string)
+				for path, repoLog := range changes {
+					for _, commit := range repoLog.Commits {
+						got[target][path] = append(got[target][path], commit.SHA)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, test.Expected) {
+				t.Errorf("changelog failed, expected %v, got %v", test.Expected, got)
+			}
+		})
+	}
+}
+
+func TestChangelogWithOptionsCollapsePairs(t *testing.T) {
+	sha := func(c string) string { return strings.Repeat(c, 40) }
+	orig1, pick1, feat1, revert1 := sha("1"), sha("2"), sha("3"), sha("4")
+	orig2, pick2, pick3 := sha("5"), sha("6"), sha("7")
+	keepAdd, keepRm, scriptsFix, scriptsRevert := sha("8"), sha("9"), sha("a"), sha("b")
+
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-b"},
+	)
+	fake.logs["kernel-b"] = []string{pick1, revert1, feat1, pick3, keepAdd}
+	fake.logs["kernel-a"] = []string{orig1, pick2, keepRm}
+	fake.logs["scripts-b"] = []string{scriptsRevert, scriptsFix}
+	fake.logs["scripts-a"] = []string{}
+	fake.footers[pick1] = "(cherry picked from commit " + orig1 + ")\n"
+	fake.footers[revert1] = "This reverts commit " + feat1 + ".\n"
+	fake.footers[pick2] = "(cherry picked from commit " + orig2 + ")\n"
+	fake.footers[pick3] = "(cherry picked from commit " + orig2 + ")\n"
+	fake.footers[scriptsRevert] = "This reverts commit " + scriptsFix + ".\n"
+
+	tests := map[string]struct {
+		CollapsePairs     bool
+		ExpectedAdditions map[string][]string
+		ExpectedRemovals  map[string][]string
+	}{
+		"disabled": {
+			CollapsePairs: false,
+			ExpectedAdditions: map[string][]string{
+				"src/third_party/kernel": {pick1, revert1, feat1, pick3, keepAdd},
+				"src/scripts":            {scriptsRevert, scriptsFix},
+			},
+			ExpectedRemovals: map[string][]string{
+				"src/third_party/kernel": {orig1, pick2, keepRm},
+			},
+		},
+		"enabled": {
+			CollapsePairs: true,
+			ExpectedAdditions: map[string][]string{
+				"src/third_party/kernel": {keepAdd},
+			},
+			ExpectedRemovals: map[string][]string{
+				"src/third_party/kernel": {keepRm},
+			},
+		},
+	}
+	shas := func(changes map[string]*RepoLog) map[string][]string {
+		out := make(map[string][]string)
+		for path, repoLog := range changes {
+			for _, commit := range repoLog.Commits {
+				out[path] = append(out[path], commit.SHA)
+			}
+		}
+		return out
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			additions, removals, _, err := ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1, &Options{CollapsePairs: test.CollapsePairs})
+			if err != nil {
+				t.Fatalf("changelog failed, unexpected error: %v", err)
+			}
+			if got := shas(additions); !reflect.DeepEqual(got, test.ExpectedAdditions) {
+				t.Errorf("changelog failed, expected additions %v, got %v", test.ExpectedAdditions, got)
+			}
+			if got := shas(removals); !reflect.DeepEqual(got, test.ExpectedRemovals) {
+				t.Errorf("changelog failed, expected removals %v, got %v", test.ExpectedRemovals, got)
+			}
+			for _, changes := range []map[string]*RepoLog{additions, removals} {
+				for path, repoLog := range changes {
+					if repoLog.CommitCount != len(repoLog.Commits) {
+						t.Errorf("changelog failed, expected CommitCount %d for %s, got %d", len(repoLog.Commits), path, repoLog.CommitCount)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChangelogWithOptionsManifestCache(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest([3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"})
+	fake.manifests["2.0.0"] = fake.manifest([3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"})
+	fake.manifests["3.0.0"] = fake.manifest([3]string{"cos/kernel", "src/third_party/kernel", "kernel-c"})
+	fake.logs["kernel-a"] = []string{"a"}
+	fake.logs["kernel-b"] = []string{"b", "a"}
+	fake.logs["kernel-c"] = []string{"c", "b", "a"}
+
+	tests := map[string]struct {
+		CacheSize       int
+		Builds          [][2]string
+		ExpectedFetched map[string]int
+		ExpectedLen     int
+	}{
+		"reused": {
+			CacheSize:       2,
+			Builds:          [][2]string{{"1.0.0", "2.0.0"}, {"1.0.0", "2.0.0"}, {"2.0.0", "1.0.0"}},
+			ExpectedFetched: map[string]int{"1.0.0": 1, "2.0.0": 1},
+			ExpectedLen:     2,
+		},
+		"evicted": {
+			CacheSize:       2,
+			Builds:          [][2]string{{"1.0.0", "2.0.0"}, {"2.0.0", "3.0.0"}, {"1.0.0", "3.0.0"}},
+			ExpectedFetched: map[string]int{"1.0.0": 2, "2.0.0": 1, "3.0.0": 1},
+			ExpectedLen:     2,
+		},
+		"failure not cached": {
+			CacheSize:       2,
+			Builds:          [][2]string{{"1.0.0", "9.0.0"}, {"1.0.0", "9.0.0"}},
+			ExpectedFetched: map[string]int{"1.0.0": 1, "9.0.0": 2},
+			ExpectedLen:     1,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake.mu.Lock()
+			fake.fetched = make(map[string]int)
+			fake.mu.Unlock()
+			cache := NewManifestCache(test.CacheSize)
+			for _, builds := range test.Builds {
+				source, target := builds[0], builds[1]
+				additions, _, _, err := ChangelogWithOptions(context.Background(), fake.client(), source, target, fake.host(), defaultManifestRepo, "", -1, &Options{ManifestCache: cache})
+				if target == "9.0.0" {
+					if err == nil {
+						t.Fatalf("ChangelogWithOptions(%s, %s) succeeded, want error", source, target)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("ChangelogWithOptions(%s, %s) failed: %v", source, target, err)
+				}
+				if source == "1.0.0" && len(additions) != 1 {
+					t.Errorf("ChangelogWithOptions(%s, %s) returned %d repositories with additions, want 1", source, target, len(additions))
+				}
+			}
+			if got := fake.fetchedFiles(); !reflect.DeepEqual(got, test.ExpectedFetched) {
+				t.Errorf("manifest requests = %v, want %v", got, test.ExpectedFetched)
+			}
+			if got := cache.Len(); got != test.ExpectedLen {
+				t.Errorf("cache.Len() = %d, want %d", got, test.ExpectedLen)
+			}
+		})
+	}
+}
+
+func TestChangelogStream(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+		[3]string{"cos/overlays", "src/overlays", "overlays-b"},
+		[3]string{"cos/scripts", "src/scripts", "scripts-a"},
+	)
+	fake.logs["kernel-b"] = []string{"kernel-2", "kernel-1"}
+	fake.logs["kernel-a"] = []string{"kernel-0"}
+	fake.logs["overlays-b"] = []string{"overlays-1"}
+	fake.logs["overlays-a"] = []string{}
+	fake.logs["scripts-a"] = []string{}
+
+	tests := map[string]struct {
+		Failures        map[string]int
+		ExpectedErr     bool
+		ExpectedAdded   map[string][]string
+		ExpectedRemoved map[string][]string
+	}{
+		"success": {
+			ExpectedAdded: map[string][]string{
+				"src/third_party/kernel": {"kernel-2", "kernel-1"},
+				"src/overlays":           {"overlays-1"},
+			},
+			ExpectedRemoved: map[string][]string{
+				"src/third_party/kernel": {"kernel-0"},
+			},
+		},
+		"repo failure": {
+			Failures:    map[string]int{"cos/overlays": http.StatusInternalServerError},
+			ExpectedErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake.failures = make(map[string]int)
+			for repo, code := range test.Failures {
+				fake.failures[repo] = code
+			}
+			out := make(chan RepoLogResult)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- ChangelogStream(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1, out)
+			}()
+			added := make(map[string][]string)
+			removed := make(map[string][]string)
+			for res := range out {
+				shas := added
+				if res.Removed {
+					shas = removed
+				}
+				if _, ok := shas[res.Path]; ok {
+					t.Errorf("ChangelogStream sent path %s more than once (removed: %v)", res.Path, res.Removed)
+				}
+				shas[res.Path] = []string{}
+				for _, commit := range res.Log.Commits {
+					shas[res.Path] = append(shas[res.Path], commit.SHA)
+				}
+			}
+			err := <-errChan
+			if test.ExpectedErr {
+				if err == nil {
+					t.Errorf("ChangelogStream succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ChangelogStream failed: %v", err)
+			}
+			if !reflect.DeepEqual(added, test.ExpectedAdded) {
+				t.Errorf("ChangelogStream sent additions %v, want %v", added, test.ExpectedAdded)
+			}
+			if !reflect.DeepEqual(removed, test.ExpectedRemoved) {
+				t.Errorf("ChangelogStream sent removals %v, want %v", removed, test.ExpectedRemoved)
+			}
+		})
+	}
+}
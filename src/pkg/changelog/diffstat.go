@@ -0,0 +1,150 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxDiffRequests is the maximum number of concurrent requests for commit
+// diffs in a repository.
+const maxDiffRequests = 8
+
+// diffStat holds the number of files and lines changed by a commit.
+type diffStat struct {
+	FilesChanged int
+	Insertions   int
+	Deletions    int
+}
+
+// parseDiffStat counts the files and lines changed by a patch in the
+// unified diff format produced by `git diff`. Binary files are counted as
+// changed files without changed lines. Patches with a line longer than 1MB
+// cannot be parsed.
+func parseDiffStat(patch io.Reader) (diffStat, error) {
+	var stat diffStat
+	inHunk := false
+	scanner := bufio.NewScanner(patch)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			stat.FilesChanged++
+			inHunk = false
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+			// File header lines, ex. "--- a/file" or "+++ b/file", are
+			// not changed lines.
+		case strings.HasPrefix(line, "+"):
+			stat.Insertions++
+		case strings.HasPrefix(line, "-"):
+			stat.Deletions++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return diffStat{}, fmt.Errorf("parseDiffStat: error reading patch: %v", err)
+	}
+	return stat, nil
+}
+
+// commitDiffStat retrieves the diff of a commit against its first parent
+// from Gitiles and counts the files and lines it changes.
+func commitDiffStat(ctx context.Context, httpClient *http.Client, instanceURL, repo, sha string) (diffStat, error) {
+	// Gitiles serves the base64 encoded diff of a commit at <sha>^!.
+	diffURL := fmt.Sprintf("https://%s/a/%s/+/%s/?format=TEXT", instanceURL, repo, url.PathEscape(sha+"^!"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, diffURL, nil)
+	if err != nil {
+		return diffStat{}, fmt.Errorf("commitDiffStat: error creating request for commit %s in repo %s: %v", sha, repo, err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return diffStat{}, fmt.Errorf("commitDiffStat: error retrieving diff of commit %s in repo %s: %v", sha, repo, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return diffStat{}, fmt.Errorf("commitDiffStat: error retrieving diff of commit %s in repo %s: status %s", sha, repo, resp.Status)
+	}
+	return parseDiffStat(base64.NewDecoder(base64.StdEncoding, resp.Body))
+}
+
+// addDiffStats sets the FilesChanged, Insertions and Deletions fields of
+// every commit in repoCommits. The diff of each commit is retrieved with a
+// separate Gitiles request, so this is only done if requested by
+// Options.DiffStats.
+//
+// A commit present in several repository logs is only retrieved once. A
+// commit whose diff cannot be retrieved or parsed is logged and left
+// without diff stats, so that it does not fail the whole changelog.
+func addDiffStats(ctx context.Context, httpClient *http.Client, repoCommits ...map[string]*RepoLog) utils.ChangelogError {
+	type repoCommit struct {
+		instanceURL string
+		repo        string
+		commit      *Commit
+	}
+	var pending []repoCommit
+	seen := make(map[*Commit]bool)
+	for _, logs := range repoCommits {
+		for _, repoLog := range logs {
+			for _, commit := range repoLog.Commits {
+				if !seen[commit] {
+					seen[commit] = true
+					pending = append(pending, repoCommit{repoLog.InstanceURL, repoLog.Repo, commit})
+				}
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxDiffRequests)
+	for _, p := range pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p repoCommit) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			stat, err := commitDiffStat(ctx, httpClient, p.instanceURL, p.repo, p.commit.SHA)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Errorf("addDiffStats: leaving commit %s in repo %s without diff stats: %v", p.commit.SHA, p.repo, err)
+				}
+				return
+			}
+			p.commit.FilesChanged = &stat.FilesChanged
+			p.commit.Insertions = &stat.Insertions
+			p.commit.Deletions = &stat.Deletions
+		}(p)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return utils.RequestCanceled(ctx.Err())
+	}
+	return nil
+}
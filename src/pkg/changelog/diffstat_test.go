@@ -0,0 +1,138 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readPatch(t *testing.T) string {
+	patch, err := ioutil.ReadFile(filepath.Join("testdata", "diffstat.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch fixture: %v", err)
+	}
+	return string(patch)
+}
+
+func TestParseDiffStat(t *testing.T) {
+	tests := map[string]struct {
+		Patch    string
+		Expected diffStat
+	}{
+		"fixture": {
+			Patch:    readPatch(t),
+			Expected: diffStat{FilesChanged: 4, Insertions: 5, Deletions: 3},
+		},
+		"empty": {
+			Patch:    "",
+			Expected: diffStat{},
+		},
+		"binary file": {
+			Patch:    "diff --git a/logo.png b/logo.png\nindex 1a2b3c4..5d6e7f8 100644\nBinary files a/logo.png and b/logo.png differ\n",
+			Expected: diffStat{FilesChanged: 1},
+		},
+		"mode change": {
+			Patch:    "diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n",
+			Expected: diffStat{FilesChanged: 1},
+		},
+	}
+	for name, test := range tests {
+		stat, err := parseDiffStat(strings.NewReader(test.Patch))
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if stat != test.Expected {
+			t.Errorf("test %q failed: expected %+v, got %+v", name, test.Expected, stat)
+		}
+	}
+}
+
+func TestChangelogWithOptionsDiffStats(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/kernel", "src/third_party/kernel", "kernel-b"},
+	)
+	fake.logs["kernel-b"] = []string{"kernel-b", "kernel-1"}
+	fake.diffs["kernel-b"] = readPatch(t)
+	fake.diffs["kernel-1"] = "diff --git a/README b/README\nindex 1111111..2222222 100644\n--- a/README\n+++ b/README\n@@ -1 +1 @@\n-old\n+new\n"
+
+	additions, _, _, err := ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1, &Options{DiffStats: true})
+	if err != nil {
+		t.Fatalf("changelog failed, unexpected error: %v", err)
+	}
+	expected := map[string]diffStat{
+		"kernel-b": {FilesChanged: 4, Insertions: 5, Deletions: 3},
+		"kernel-1": {FilesChanged: 1, Insertions: 1, Deletions: 1},
+	}
+	repoLog, ok := additions["src/third_party/kernel"]
+	if !ok || len(repoLog.Commits) != len(expected) {
+		t.Fatalf("changelog failed, expected %d commits for src/third_party/kernel, got %v", len(expected), repoLog)
+	}
+	for _, commit := range repoLog.Commits {
+		if commit.FilesChanged == nil || commit.Insertions == nil || commit.Deletions == nil {
+			t.Errorf("changelog failed, expected diff stats for commit %s, got %+v", commit.SHA, commit)
+			continue
+		}
+		stat := diffStat{*commit.FilesChanged, *commit.Insertions, *commit.Deletions}
+		if stat != expected[commit.SHA] {
+			t.Errorf("changelog failed, expected diff stats %+v for commit %s, got %+v", expected[commit.SHA], commit.SHA, stat)
+		}
+	}
+
+	// Without DiffStats, no diffs are requested.
+	delete(fake.diffs, "kernel-1")
+	additions, _, _, err = ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1, nil)
+	if err != nil {
+		t.Fatalf("changelog failed, unexpected error without diff stats: %v", err)
+	}
+	for _, commit := range additions["src/third_party/kernel"].Commits {
+		if commit.FilesChanged != nil || commit.Insertions != nil || commit.Deletions != nil {
+			t.Errorf("changelog failed, expected no diff stats for commit %s, got %+v", commit.SHA, commit)
+		}
+	}
+
+	// A commit whose diff cannot be retrieved or parsed is left without diff
+	// stats, and the other commits keep theirs.
+	for name, diff := range map[string]*string{
+		"missing diff": nil,
+		"line too long": func() *string {
+			d := "diff --git a/blob b/blob\n--- a/blob\n+++ b/blob\n@@ -0,0 +1 @@\n+" + strings.Repeat("x", 2*1024*1024) + "\n"
+			return &d
+		}(),
+	} {
+		delete(fake.diffs, "kernel-1")
+		if diff != nil {
+			fake.diffs["kernel-1"] = *diff
+		}
+		additions, _, _, err := ChangelogWithOptions(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1, &Options{DiffStats: true})
+		if err != nil {
+			t.Fatalf("changelog with %s failed, unexpected error: %v", name, err)
+		}
+		for _, commit := range additions["src/third_party/kernel"].Commits {
+			hasStats := commit.FilesChanged != nil && commit.Insertions != nil && commit.Deletions != nil
+			if want := commit.SHA != "kernel-1"; hasStats != want {
+				t.Errorf("changelog with %s failed, expected diff stats for commit %s to be set: %t, got %+v", name, commit.SHA, want, commit)
+			}
+		}
+	}
+}
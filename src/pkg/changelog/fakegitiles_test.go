@@ -0,0 +1,272 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitiles is a fake Gitiles REST server that serves manifest snapshots
+// and commit logs from memory. It records every repository that receives a
+// log request so that tests can verify which repositories were queried.
+type fakeGitiles struct {
+	server *httptest.Server
+	// manifests maps a build number to the contents of its snapshot.xml.
+	// A manifest can be restricted to one manifest repository by keying it
+	// as "<repo>/<build number>".
+	manifests map[string]string
+	// includes maps "<build number>/<path>" to the contents of a manifest file
+	// included by the snapshot.xml of that build.
+	includes map[string]string
+	// logs maps a committish to the commit SHAs returned by a log request
+	// on that committish. Log requests on any other committish return 404.
+	logs map[string][]string
+	// bugs maps a commit SHA to the value of the BUG= line of its commit message.
+	// Commits without an entry reference b/1.
+	bugs map[string]string
+	// footers maps a commit SHA to lines appended to its commit message.
+	footers map[string]string
+	// diffs maps a commit SHA to the patch served as the diff of the commit.
+	// Diff requests on any other commit return 404.
+	diffs map[string]string
+	// failures maps a repository to the HTTP status code returned by log
+	// requests on that repository.
+	failures map[string]int
+	// blockLogs, if set, delays log requests until it is closed or the
+	// request is cancelled.
+	blockLogs chan struct{}
+
+	mu      sync.Mutex
+	queried map[string]int
+	// fetched counts the file requests received for each build number or
+	// included manifest path.
+	fetched map[string]int
+}
+
+// newFakeGitiles starts a fake Gitiles server. The server is closed when the
+// test completes.
+func newFakeGitiles(t *testing.T) *fakeGitiles {
+	f := &fakeGitiles{
+		manifests: make(map[string]string),
+		includes:  make(map[string]string),
+		logs:      make(map[string][]string),
+		bugs:      make(map[string]string),
+		footers:   make(map[string]string),
+		diffs:     make(map[string]string),
+		failures:  make(map[string]int),
+		queried:   make(map[string]int),
+		fetched:   make(map[string]int),
+	}
+	f.server = httptest.NewTLSServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// host returns the host name of the fake server, ex. "127.0.0.1:1234".
+func (f *fakeGitiles) host() string {
+	return strings.TrimPrefix(f.server.URL, "https://")
+}
+
+// client returns an http.Client that trusts the fake server certificate.
+func (f *fakeGitiles) client() *http.Client {
+	return f.server.Client()
+}
+
+// queriedRepos returns the number of log requests received by each repository.
+func (f *fakeGitiles) queriedRepos() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]int)
+	for repo, count := range f.queried {
+		out[repo] = count
+	}
+	return out
+}
+
+// fetchedFiles returns the number of file requests received for each build
+// number or included manifest path.
+func (f *fakeGitiles) fetchedFiles() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]int)
+	for file, count := range f.fetched {
+		out[file] = count
+	}
+	return out
+}
+
+// manifest builds a snapshot.xml whose projects are hosted on the fake server.
+// Each project is given as a {name, path, revision} triple.
+func (f *fakeGitiles) manifest(projects ...[3]string) string {
+	var sb strings.Builder
+	sb.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<manifest>\n")
+	fmt.Fprintf(&sb, "  <remote fetch=\"https://%s\" name=\"cos\"/>\n", f.host())
+	sb.WriteString("  <default remote=\"cos\" revision=\"refs/heads/master\"/>\n")
+	for _, p := range projects {
+		fmt.Fprintf(&sb, "  <project name=\"%s\" path=\"%s\" revision=\"%s\"/>\n", p[0], p[1], p[2])
+	}
+	sb.WriteString("</manifest>\n")
+	return sb.String()
+}
+
+func (f *fakeGitiles) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/a/")
+	if strings.HasSuffix(path, "/+refs/tags") {
+		f.serveTags(w, strings.TrimSuffix(path, "/+refs/tags"))
+		return
+	}
+	if i := strings.Index(path, "/+log/"); i >= 0 {
+		if f.blockLogs != nil {
+			select {
+			case <-f.blockLogs:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		f.serveLog(w, r.URL.Query(), path[:i], path[i+len("/+log/"):])
+		return
+	}
+	if i := strings.Index(path, "/+/"); i >= 0 && strings.HasSuffix(path, "^!/") {
+		f.serveDiff(w, strings.TrimSuffix(path[i+len("/+/"):], "^!/"))
+		return
+	}
+	if i := strings.Index(path, "/+/"); i >= 0 {
+		f.serveFile(w, path[:i], path[i+len("/+/"):])
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// serveLog serves the commits of ref, paginated by the "n" (page size) and
+// "s" (page token) parameters. Page tokens are offsets into the log.
+func (f *fakeGitiles) serveLog(w http.ResponseWriter, params url.Values, repo, ref string) {
+	f.mu.Lock()
+	f.queried[repo]++
+	f.mu.Unlock()
+	if code, ok := f.failures[repo]; ok {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	// ref is either "committish" or "ancestor..committish".
+	committish := ref[strings.LastIndex(ref, ".")+1:]
+	shas, ok := f.logs[committish]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	start, _ := strconv.Atoi(params.Get("s"))
+	end := len(shas)
+	if n, err := strconv.Atoi(params.Get("n")); err == nil && n > 0 && start+n < end {
+		end = start + n
+	}
+	next := ""
+	if end < len(shas) {
+		next = strconv.Itoa(end)
+	}
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Time  string `json:"time"`
+	}
+	type commit struct {
+		Commit    string `json:"commit"`
+		Author    user   `json:"author"`
+		Committer user   `json:"committer"`
+		Message   string `json:"message"`
+	}
+	resp := struct {
+		Log  []commit `json:"log"`
+		Next string   `json:"next,omitempty"`
+	}{Log: []commit{}, Next: next}
+	for _, sha := range shas[start:end] {
+		bug, ok := f.bugs[sha]
+		if !ok {
+			bug = "b/1"
+		}
+		u := user{Name: "author", Email: "author@example.com", Time: "Mon Jan 2 15:04:05 2006"}
+		resp.Log = append(resp.Log, commit{
+			Commit:    sha,
+			Author:    u,
+			Committer: u,
+			Message:   fmt.Sprintf("Commit %s\n\nBUG=%s\n%s", sha, bug, f.footers[sha]),
+		})
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, ")]}'\n%s", body)
+}
+
+// serveDiff serves the patch of a commit, base64 encoded like Gitiles.
+func (f *fakeGitiles) serveDiff(w http.ResponseWriter, sha string) {
+	patch, ok := f.diffs[sha]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, base64.StdEncoding.EncodeToString([]byte(patch)))
+}
+
+// serveTags lists a tag for each build in manifests available in repo.
+func (f *fakeGitiles) serveTags(w http.ResponseWriter, repo string) {
+	type ref struct {
+		Value string `json:"value"`
+	}
+	resp := make(map[string]ref)
+	for key := range f.manifests {
+		buildNum := strings.TrimPrefix(key, repo+"/")
+		if strings.Contains(buildNum, "/") {
+			continue
+		}
+		resp[buildNum] = ref{Value: "manifest-" + buildNum}
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, ")]}'\n%s", body)
+}
+
+func (f *fakeGitiles) serveFile(w http.ResponseWriter, repo, refAndPath string) {
+	buildAndPath := strings.TrimPrefix(refAndPath, "refs/tags/")
+	buildNum := strings.TrimSuffix(buildAndPath, "/snapshot.xml")
+	f.mu.Lock()
+	f.fetched[buildNum]++
+	f.mu.Unlock()
+	manifest, ok := f.manifests[repo+"/"+buildNum]
+	if !ok {
+		manifest, ok = f.manifests[buildNum]
+	}
+	if !ok {
+		manifest, ok = f.includes[buildAndPath]
+	}
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, base64.StdEncoding.EncodeToString([]byte(manifest)))
+}
@@ -17,7 +17,9 @@ package changelog
 import (
 	"errors"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"go.chromium.org/luci/common/proto/git"
 )
@@ -30,15 +32,37 @@ const (
 // Commit is a simplified struct of git.Commit
 // Useful for interfaces
 type Commit struct {
-	SHA           string
-	AuthorName    string
-	CommitterName string
-	Subject       string
-	Bugs          []string
-	ReleaseNote   string
-	CommitTime    string
+	SHA           string   `yaml:"SHA"`
+	AuthorName    string   `yaml:"AuthorName"`
+	CommitterName string   `yaml:"CommitterName"`
+	Subject       string   `yaml:"Subject"`
+	Bugs          []string `yaml:"Bugs"`
+	ReleaseNote   string   `yaml:"ReleaseNote"`
+	CommitTime    string   `yaml:"CommitTime"`
+	// CherryPickOf is the SHA of the commit this commit was cherry-picked
+	// from, if its message has a "cherry picked from commit" line.
+	CherryPickOf string `yaml:"CherryPickOf"`
+	// RevertOf is the SHA of the commit this commit reverts, if its message
+	// has a "This reverts commit" line.
+	RevertOf string `yaml:"RevertOf"`
+	// FilesChanged, Insertions and Deletions are the number of files and
+	// lines changed by the commit. They are only set if the changelog was
+	// generated with Options.DiffStats and the diff of the commit could be
+	// retrieved.
+	FilesChanged *int `yaml:"FilesChanged,omitempty" json:",omitempty"`
+	Insertions   *int `yaml:"Insertions,omitempty" json:",omitempty"`
+	Deletions    *int `yaml:"Deletions,omitempty" json:",omitempty"`
+
+	// committed is the exact commit time, used to order commits from
+	// different repositories. It is zero if the commit time is unknown.
+	committed time.Time
 }
 
+var (
+	cherryPickRe = regexp.MustCompile(`^\(cherry picked from commit ([0-9a-f]{40})\)$`)
+	revertRe     = regexp.MustCompile(`^This reverts commit ([0-9a-f]{40})\.?$`)
+)
+
 // All bug patterns need to be added here to recognize whether a bug entry
 // should be ignored or not
 var bugPatternToReplacement = map[*regexp.Regexp]string{
@@ -103,6 +127,19 @@ func releaseNote(commit *git.Commit) string {
 	return ""
 }
 
+// referencedCommit returns the SHA captured by re from the last matching line
+// of a commit message. The last line is used since a cherry-pick of a
+// cherry-pick lists the commits it was picked from in order.
+func referencedCommit(commit *git.Commit, re *regexp.Regexp) string {
+	sha := ""
+	for _, line := range strings.Split(commit.Message, "\n") {
+		if match := re.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			sha = match[1]
+		}
+	}
+	return sha
+}
+
 func commitTime(commit *git.Commit) string {
 	if commit.Committer != nil {
 		return commit.Committer.Time.AsTime().Format("Mon, 2 Jan 2006")
@@ -110,6 +147,13 @@ func commitTime(commit *git.Commit) string {
 	return "None"
 }
 
+func committed(commit *git.Commit) time.Time {
+	if commit.Committer != nil && commit.Committer.Time != nil {
+		return commit.Committer.Time.AsTime()
+	}
+	return time.Time{}
+}
+
 // ParseGitCommit converts a git.Commit object into a
 // Commit object with processed fields
 func parseGitCommit(commit *git.Commit) (*Commit, error) {
@@ -124,11 +168,18 @@ func parseGitCommit(commit *git.Commit) (*Commit, error) {
 		Bugs:          bugs(commit),
 		ReleaseNote:   releaseNote(commit),
 		CommitTime:    commitTime(commit),
+		CherryPickOf:  referencedCommit(commit, cherryPickRe),
+		RevertOf:      referencedCommit(commit, revertRe),
+		committed:     committed(commit),
 	}, nil
 }
 
 // ParseGitCommitLog converts a slice of git.Commit objects
-// into a slice of Commit objects with processed fields
+// into a slice of Commit objects with processed fields.
+//
+// The output is ordered from newest to oldest commit time, regardless of the
+// order of the input. Commits with the same commit time keep their input
+// order, and commits without a commit time are listed last.
 func ParseGitCommitLog(commits []*git.Commit) ([]*Commit, error) {
 	if commits == nil {
 		return nil, errors.New("parseCommitLog: Input should not be nil")
@@ -141,5 +192,18 @@ func ParseGitCommitLog(commits []*git.Commit) ([]*Commit, error) {
 		}
 		output[i] = parsedCommit
 	}
+	sortCommits(output)
 	return output, nil
 }
+
+// sortCommits orders commits from newest to oldest commit time, keeping the
+// order of commits with the same commit time. Commits without a commit time
+// are moved to the end.
+func sortCommits(commits []*Commit) {
+	sort.SliceStable(commits, func(i, j int) bool {
+		if commits[j].committed.IsZero() {
+			return !commits[i].committed.IsZero()
+		}
+		return commits[i].committed.After(commits[j].committed)
+	})
+}
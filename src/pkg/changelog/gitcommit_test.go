@@ -347,3 +347,123 @@ BUG=56456651, chromium:777882, -1, b:9999999`),
 		})
 	}
 }
+
+func TestParseGitCommitReferences(t *testing.T) {
+	const (
+		sha1 = "0123456789abcdef0123456789abcdef01234567"
+		sha2 = "89abcdef0123456789abcdef0123456789abcdef"
+	)
+	tests := map[string]struct {
+		Message      string
+		CherryPickOf string
+		RevertOf     string
+	}{
+		"no references": {
+			Message: "kernel: Fix a bug\n\nBUG=b/1\n",
+		},
+		"cherry-pick": {
+			Message:      "kernel: Fix a bug\n\nBUG=b/1\n\nChange-Id: I0b6895f7\n(cherry picked from commit " + sha1 + ")\n",
+			CherryPickOf: sha1,
+		},
+		"cherry-pick of cherry-pick": {
+			Message:      "kernel: Fix a bug\n\n(cherry picked from commit " + sha1 + ")\n(cherry picked from commit " + sha2 + ")\n",
+			CherryPickOf: sha2,
+		},
+		"revert": {
+			Message:  "Revert \"kernel: Fix a bug\"\n\nThis reverts commit " + sha1 + ".\n\nReason for revert: broke the build\n",
+			RevertOf: sha1,
+		},
+		"revert of cherry-pick": {
+			Message:      "Revert \"kernel: Fix a bug\"\n\nThis reverts commit " + sha2 + ".\n\n(cherry picked from commit " + sha1 + ")\n",
+			CherryPickOf: sha1,
+			RevertOf:     sha2,
+		},
+		"abbreviated sha": {
+			Message: "Revert \"kernel: Fix a bug\"\n\nThis reverts commit 0123456.\n",
+		},
+		"reference in body": {
+			Message: "kernel: Fix a bug\n\nThe fix in This reverts commit " + sha1 + " was incomplete.\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			commits, err := ParseGitCommitLog([]*git.Commit{createCommitWithMessage(test.Message)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if commits[0].CherryPickOf != test.CherryPickOf {
+				t.Errorf("expected CherryPickOf %q, got %q", test.CherryPickOf, commits[0].CherryPickOf)
+			}
+			if commits[0].RevertOf != test.RevertOf {
+				t.Errorf("expected RevertOf %q, got %q", test.RevertOf, commits[0].RevertOf)
+			}
+		})
+	}
+}
+
+func TestParseGitCommitLogOrder(t *testing.T) {
+	commitAt := func(sha string, committed time.Time) *git.Commit {
+		commit := createCommitWithMessage("kernel: Fix a bug\n")
+		commit.Id = sha
+		if committed.IsZero() {
+			commit.Committer.Time = nil
+		} else {
+			commit.Committer.Time = timestamppb.New(committed)
+		}
+		return commit
+	}
+	tests := map[string]struct {
+		Input []*git.Commit
+		SHAs  []string
+	}{
+		"ordered": {
+			Input: []*git.Commit{
+				commitAt("c", committerTime.Add(2*time.Hour)),
+				commitAt("b", committerTime.Add(time.Hour)),
+				commitAt("a", committerTime),
+			},
+			SHAs: []string{"c", "b", "a"},
+		},
+		"shuffled": {
+			Input: []*git.Commit{
+				commitAt("b", committerTime.Add(time.Hour)),
+				commitAt("a", committerTime),
+				commitAt("d", committerTime.Add(3*time.Hour)),
+				commitAt("c", committerTime.Add(2*time.Hour)),
+			},
+			SHAs: []string{"d", "c", "b", "a"},
+		},
+		"same commit time": {
+			Input: []*git.Commit{
+				commitAt("a", committerTime),
+				commitAt("c", committerTime.Add(time.Hour)),
+				commitAt("b", committerTime),
+			},
+			SHAs: []string{"c", "a", "b"},
+		},
+		"missing commit time": {
+			Input: []*git.Commit{
+				commitAt("x", time.Time{}),
+				commitAt("a", committerTime),
+				commitAt("y", time.Time{}),
+				commitAt("b", committerTime.Add(time.Hour)),
+			},
+			SHAs: []string{"b", "a", "x", "y"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			commits, err := ParseGitCommitLog(test.Input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var shas []string
+			for _, commit := range commits {
+				shas = append(shas, commit.SHA)
+			}
+			if !reflect.DeepEqual(shas, test.SHAs) {
+				t.Errorf("expected commits %v, got %v", test.SHAs, shas)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// MaxLogLinkCommits is the maximum number of commits shown by a single
+// Gitiles log link. Larger ranges are split into several links.
+const MaxLogLinkCommits = 10000
+
+var shaRe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// LogLink is a link to the Gitiles log of a range of commits.
+type LogLink struct {
+	URL string
+	// First and Last are the 1-based positions in the range of the first
+	// and last commits shown by the link, from newest to oldest. Last is
+	// the position of the last commit of the range if the link shows the
+	// rest of the range, or 0 if the number of commits is unknown.
+	First int
+	Last  int
+}
+
+// LogLinks returns links to the Gitiles log of the commits in a repository
+// that are reachable from targetSHA but not from sourceSHA, ex. the
+// commits of a RepoLog. commitCount is the total number of commits in the
+// range, or 0 if it is unknown, and pageTokens maps 0-based positions in
+// the range to the SHA of the commit at that position, ex.
+// RepoLog.LogPageTokens.
+//
+// If sourceSHA is empty or not a valid SHA, the links show the log of
+// targetSHA limited to commitCount commits. If the range has more than
+// MaxLogLinkCommits commits, it is split into one link per
+// MaxLogLinkCommits commits, as far as the commit each link starts from is
+// in pageTokens. The last link can be paged through on Gitiles to see any
+// remaining commits.
+//
+// No links are returned if targetSHA is not a valid SHA.
+func LogLinks(instance, repo, sourceSHA, targetSHA string, commitCount int, pageTokens map[int]string) []LogLink {
+	if !shaRe.MatchString(targetSHA) || instance == "" || repo == "" {
+		return nil
+	}
+	logPath := targetSHA
+	if shaRe.MatchString(sourceSHA) {
+		logPath = sourceSHA + ".." + targetSHA
+	}
+	base := fmt.Sprintf("https://%s/%s/+log/%s", instance, repo, logPath)
+	var links []LogLink
+	for first := 0; ; first += MaxLogLinkCommits {
+		params := url.Values{}
+		if first > 0 {
+			token, ok := pageTokens[first]
+			if (commitCount > 0 && first >= commitCount) || !ok {
+				break
+			}
+			params.Set("s", token)
+		}
+		// Unknown commit counts are linked with the maximum page size.
+		n := MaxLogLinkCommits
+		if commitCount > 0 && commitCount-first < n {
+			n = commitCount - first
+		}
+		params.Set("n", strconv.Itoa(n))
+		link := LogLink{URL: base + "?" + params.Encode(), First: first + 1}
+		if commitCount > 0 {
+			link.Last = first + n
+		}
+		links = append(links, link)
+	}
+	// The remaining commits can be reached by paging through the last link.
+	if commitCount > 0 {
+		links[len(links)-1].Last = commitCount
+	}
+	return links
+}
@@ -0,0 +1,124 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// linkPageTokens returns page tokens with distinct SHAs at each position.
+func linkPageTokens(positions ...int) map[int]string {
+	pageTokens := make(map[int]string)
+	for _, pos := range positions {
+		pageTokens[pos] = fmt.Sprintf("%040x", pos+1)
+	}
+	return pageTokens
+}
+
+func TestLogLinks(t *testing.T) {
+	const (
+		instance = "cos.googlesource.com"
+		repo     = "cos/overlays"
+		source   = "1111111111111111111111111111111111111111"
+		target   = "2222222222222222222222222222222222222222"
+		logURL   = "https://cos.googlesource.com/cos/overlays/+log/"
+	)
+	tests := map[string]struct {
+		SourceSHA   string
+		TargetSHA   string
+		PageTokens  map[int]string
+		CommitCount int
+		Expected    []LogLink
+	}{
+		"range": {
+			SourceSHA:   source,
+			TargetSHA:   target,
+			CommitCount: 250,
+			Expected: []LogLink{
+				{URL: logURL + source + ".." + target + "?n=250", First: 1, Last: 250},
+			},
+		},
+		"empty source SHA": {
+			SourceSHA:   "",
+			TargetSHA:   target,
+			CommitCount: 42,
+			Expected: []LogLink{
+				{URL: logURL + target + "?n=42", First: 1, Last: 42},
+			},
+		},
+		"invalid source SHA": {
+			SourceSHA:   "refs/heads/master",
+			TargetSHA:   target,
+			CommitCount: 42,
+			Expected: []LogLink{
+				{URL: logURL + target + "?n=42", First: 1, Last: 42},
+			},
+		},
+		"unknown commit count": {
+			SourceSHA: source,
+			TargetSHA: target,
+			Expected: []LogLink{
+				{URL: logURL + source + ".." + target + fmt.Sprintf("?n=%d", MaxLogLinkCommits), First: 1},
+			},
+		},
+		"unknown commit count with page tokens": {
+			SourceSHA:  source,
+			TargetSHA:  target,
+			PageTokens: linkPageTokens(MaxLogLinkCommits),
+			Expected: []LogLink{
+				{URL: logURL + source + ".." + target + fmt.Sprintf("?n=%d", MaxLogLinkCommits), First: 1},
+				{URL: logURL + source + ".." + target + fmt.Sprintf("?n=%d&s=%040x", MaxLogLinkCommits, MaxLogLinkCommits+1), First: MaxLogLinkCommits + 1},
+			},
+		},
+		"huge range": {
+			SourceSHA:   source,
+			TargetSHA:   target,
+			PageTokens:  linkPageTokens(100, MaxLogLinkCommits, 2*MaxLogLinkCommits),
+			CommitCount: 2*MaxLogLinkCommits + 500,
+			Expected: []LogLink{
+				{URL: logURL + source + ".." + target + "?n=10000", First: 1, Last: 10000},
+				{URL: logURL + source + ".." + target + "?n=10000&s=" + fmt.Sprintf("%040x", MaxLogLinkCommits+1), First: 10001, Last: 20000},
+				{URL: logURL + source + ".." + target + "?n=500&s=" + fmt.Sprintf("%040x", 2*MaxLogLinkCommits+1), First: 20001, Last: 20500},
+			},
+		},
+		"huge range without page tokens": {
+			SourceSHA:   source,
+			TargetSHA:   target,
+			PageTokens:  linkPageTokens(100),
+			CommitCount: 3 * MaxLogLinkCommits,
+			Expected: []LogLink{
+				{URL: logURL + source + ".." + target + "?n=10000", First: 1, Last: 3 * MaxLogLinkCommits},
+			},
+		},
+		"empty target SHA": {
+			SourceSHA:   source,
+			TargetSHA:   "",
+			CommitCount: 10,
+		},
+		"invalid target SHA": {
+			SourceSHA:   source,
+			TargetSHA:   "2222222",
+			CommitCount: 10,
+		},
+	}
+	for name, test := range tests {
+		links := LogLinks(instance, repo, test.SourceSHA, test.TargetSHA, test.CommitCount, test.PageTokens)
+		if !reflect.DeepEqual(links, test.Expected) {
+			t.Errorf("test %q failed: expected links %+v, got %+v", name, test.Expected, links)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// resolveIncludes replaces each <include name="..."> element of a manifest
+// with the elements of the included manifest file, recursively, so that the
+// manifest can be mapped as a single file.
+//
+// fetch retrieves an included manifest file by name. stack holds the names of
+// the manifest files currently being resolved, and is used to detect include
+// cycles.
+func resolveIncludes(root *etree.Element, fetch func(name string) (string, error), stack []string) error {
+	for _, include := range root.SelectElements("include") {
+		name := include.SelectAttrValue("name", "")
+		if name == "" {
+			return errors.New("<include> is missing a name")
+		}
+		includeStack := append(append([]string(nil), stack...), name)
+		for _, resolving := range stack {
+			if resolving == name {
+				return fmt.Errorf("include cycle: %s", strings.Join(includeStack, " -> "))
+			}
+		}
+		log.Debugf("resolveIncludes: resolving included manifest %s", name)
+		contents, err := fetch(name)
+		if err != nil {
+			return fmt.Errorf("error retrieving included manifest %s: %w", name, err)
+		}
+		included, err := manifestRoot(contents)
+		if err != nil {
+			return fmt.Errorf("included manifest %s: %v", name, err)
+		}
+		if err := resolveIncludes(included, fetch, includeStack); err != nil {
+			return err
+		}
+		index := include.Index()
+		for _, child := range included.ChildElements() {
+			root.InsertChildAt(index, child)
+			index++
+		}
+		root.RemoveChild(include)
+	}
+	return nil
+}
+
+// ValidateManifest checks that a manifest file parses and has the structure
+// required to generate a changelog or find a build:
+//   - every <remote> has a name and a fetch URL
+//   - a <default> element names a known remote
+//   - every <project> has a name and a revision, refers to a known remote,
+//     and has a unique path
+//   - every <remove-project> and <extend-project> refers to a known project
+//
+// It returns nil if the manifest is valid, or an error listing every problem
+// found otherwise.
+func ValidateManifest(xml string) error {
+	root, err := manifestRoot(xml)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	remotes := make(map[string]bool)
+	for _, remote := range root.SelectElements("remote") {
+		name := remote.SelectAttrValue("name", "")
+		if name == "" {
+			problems = append(problems, "<remote> is missing a name")
+			continue
+		}
+		if remote.SelectAttrValue("fetch", "") == "" {
+			problems = append(problems, fmt.Sprintf("remote %q is missing a fetch URL", name))
+		}
+		if remotes[name] {
+			problems = append(problems, fmt.Sprintf("remote %q is defined more than once", name))
+		}
+		remotes[name] = true
+	}
+
+	defaultRemote := ""
+	switch defaults := root.SelectElements("default"); {
+	case len(defaults) == 0:
+		problems = append(problems, "manifest has no <default> element")
+	case len(defaults) > 1:
+		problems = append(problems, "manifest has more than one <default> element")
+	default:
+		defaultRemote = defaults[0].SelectAttrValue("remote", "")
+		if defaultRemote == "" {
+			problems = append(problems, "<default> is missing a remote")
+		} else if !remotes[defaultRemote] {
+			problems = append(problems, fmt.Sprintf("<default> refers to unknown remote %q", defaultRemote))
+		}
+	}
+
+	if len(root.SelectElements("project")) == 0 {
+		problems = append(problems, "manifest has no <project> elements")
+	}
+	// paths maps the path of each project defined so far to its name.
+	// <remove-project> and <extend-project> modify the projects defined before
+	// them, so elements are checked in document order.
+	paths := make(map[string]string)
+	projectNum := 0
+	for _, element := range root.ChildElements() {
+		switch element.Tag {
+		case "project":
+			projectNum++
+			name := element.SelectAttrValue("name", "")
+			if name == "" {
+				problems = append(problems, fmt.Sprintf("<project> %d is missing a name", projectNum))
+				continue
+			}
+			if element.SelectAttrValue("revision", "") == "" {
+				problems = append(problems, fmt.Sprintf("project %q is missing a revision", name))
+			}
+			if remote := element.SelectAttrValue("remote", ""); remote != "" && !remotes[remote] {
+				problems = append(problems, fmt.Sprintf("project %q refers to unknown remote %q", name, remote))
+			}
+			// Like repo, a project without a path is checked out at its name.
+			path := element.SelectAttrValue("path", name)
+			if other, ok := paths[path]; ok {
+				problems = append(problems, fmt.Sprintf("projects %q and %q have the same path %q", other, name, path))
+				continue
+			}
+			paths[path] = name
+		case "remove-project", "extend-project":
+			name := element.SelectAttrValue("name", "")
+			if name == "" {
+				problems = append(problems, fmt.Sprintf("<%s> is missing a name", element.Tag))
+				continue
+			}
+			if remote := element.SelectAttrValue("remote", ""); remote != "" && !remotes[remote] {
+				problems = append(problems, fmt.Sprintf("<%s> for project %q refers to unknown remote %q", element.Tag, name, remote))
+			}
+			var matched []string
+			for path, projectName := range paths {
+				if projectName == name && (element.SelectAttr("path") == nil || element.SelectAttrValue("path", "") == path) {
+					matched = append(matched, path)
+				}
+			}
+			if len(matched) == 0 {
+				problems = append(problems, fmt.Sprintf("<%s> refers to unknown project %q", element.Tag, name))
+				continue
+			}
+			destPath := element.SelectAttr("dest-path")
+			for _, path := range matched {
+				if element.Tag == "remove-project" || destPath != nil {
+					delete(paths, path)
+				}
+				if element.Tag == "extend-project" && destPath != nil {
+					paths[destPath.Value] = name
+				}
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid manifest:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateManifest(t *testing.T) {
+	snapshot, err := ioutil.ReadFile("testdata/source_snapshot.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const remote = `<remote fetch="https://cos.googlesource.com" name="cos"/>`
+	tests := map[string]struct {
+		Manifest         string
+		ExpectedProblems []string
+	}{
+		"Snapshot": {
+			Manifest: string(snapshot),
+		},
+		"ProjectRemote": {
+			Manifest: `<manifest>` + remote + `
+				<remote fetch="https://chromium.googlesource.com" name="cros"/>
+				<default remote="cos"/>
+				<project name="a" path="a" revision="1111" remote="cros"/>
+				<project name="b" revision="2222"/>
+			</manifest>`,
+		},
+		"Empty": {
+			Manifest:         "",
+			ExpectedProblems: []string{"manifest file is empty"},
+		},
+		"MalformedXML": {
+			Manifest:         `<manifest><project name=a/></manifest>`,
+			ExpectedProblems: []string{"could not parse XML"},
+		},
+		"NoManifestElement": {
+			Manifest:         `<snapshot/>`,
+			ExpectedProblems: []string{"could not find manifest element"},
+		},
+		"MissingDefault": {
+			Manifest:         `<manifest>` + remote + `<project name="a" path="a" revision="1111"/></manifest>`,
+			ExpectedProblems: []string{"manifest has no <default> element"},
+		},
+		"DefaultWithoutRemote": {
+			Manifest:         `<manifest>` + remote + `<default revision="refs/heads/master"/><project name="a" path="a" revision="1111"/></manifest>`,
+			ExpectedProblems: []string{"<default> is missing a remote"},
+		},
+		"UnknownDefaultRemote": {
+			Manifest:         `<manifest>` + remote + `<default remote="cros"/><project name="a" path="a" revision="1111"/></manifest>`,
+			ExpectedProblems: []string{`<default> refers to unknown remote "cros"`},
+		},
+		"InvalidRemote": {
+			Manifest: `<manifest><remote fetch="https://cos.googlesource.com"/><remote name="cos"/>
+				<default remote="cos"/><project name="a" path="a" revision="1111"/></manifest>`,
+			ExpectedProblems: []string{"<remote> is missing a name", `remote "cos" is missing a fetch URL`},
+		},
+		"DefaultPathConflict": {
+			Manifest: `<manifest>` + remote + `<default remote="cos"/>
+				<project name="a" revision="1111"/>
+				<project name="b" path="a" revision="2222"/>
+				<project name="c" revision="3333"/>
+				<project name="d" path="d" revision="4444"/>
+			</manifest>`,
+			ExpectedProblems: []string{`projects "a" and "b" have the same path "a"`},
+		},
+		"InvalidProjects": {
+			Manifest: `<manifest>` + remote + `<default remote="cos"/>
+				<project path="a" revision="1111"/>
+				<project name="b" path="b"/>
+				<project name="c" path="c" revision="3333" remote="cros"/>
+				<project name="d" path="c" revision="4444"/>
+			</manifest>`,
+			ExpectedProblems: []string{
+				"<project> 1 is missing a name",
+				`project "b" is missing a revision`,
+				`project "c" refers to unknown remote "cros"`,
+				`projects "c" and "d" have the same path "c"`,
+			},
+		},
+		"RemoveAndExtendProjects": {
+			Manifest: `<manifest>` + remote + `<default remote="cos"/>
+				<project name="a" path="a" revision="1111"/>
+				<project name="b" path="b" revision="2222"/>
+				<remove-project name="a"/>
+				<project name="a-fork" path="a" revision="3333"/>
+				<extend-project name="b" dest-path="c" revision="4444"/>
+				<project name="d" path="b" revision="5555"/>
+			</manifest>`,
+		},
+		"InvalidRemoveAndExtendProjects": {
+			Manifest: `<manifest>` + remote + `<default remote="cos"/>
+				<extend-project name="a" revision="0000"/>
+				<project name="a" path="a" revision="1111"/>
+				<remove-project/>
+				<remove-project name="a" path="b"/>
+				<extend-project name="a" remote="cros"/>
+				<remove-project name="a"/>
+				<remove-project name="a"/>
+			</manifest>`,
+			ExpectedProblems: []string{
+				`<extend-project> refers to unknown project "a"`,
+				"<remove-project> is missing a name",
+				`<remove-project> refers to unknown project "a"`,
+				`<extend-project> for project "a" refers to unknown remote "cros"`,
+			},
+		},
+		"NoProjects": {
+			Manifest:         `<manifest>` + remote + `<default remote="cos"/></manifest>`,
+			ExpectedProblems: []string{"manifest has no <project> elements"},
+		},
+	}
+	for name, test := range tests {
+		err := ValidateManifest(test.Manifest)
+		if len(test.ExpectedProblems) == 0 {
+			if err != nil {
+				t.Errorf("test %q failed: expected no error, got: %v", name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("test %q failed: expected error, got nil", name)
+			continue
+		}
+		for _, problem := range test.ExpectedProblems {
+			if !strings.Contains(err.Error(), problem) {
+				t.Errorf("test %q failed: expected error to contain %q, got: %v", name, problem, err)
+			}
+		}
+	}
+}
+
+func TestResolveIncludes(t *testing.T) {
+	files := map[string]string{
+		"default.xml": `<manifest><remote name="cos" fetch="https://cos.googlesource.com"/><include name="sub.xml"/></manifest>`,
+		"sub.xml":     `<manifest><project name="b" path="b" revision="2222"/></manifest>`,
+		"cycle-a.xml": `<manifest><include name="cycle-b.xml"/></manifest>`,
+		"cycle-b.xml": `<manifest><include name="cycle-a.xml"/></manifest>`,
+		"self.xml":    `<manifest><include name="self.xml"/></manifest>`,
+	}
+	fetch := func(name string) (string, error) {
+		contents, ok := files[name]
+		if !ok {
+			return "", fmt.Errorf("%s not found", name)
+		}
+		return contents, nil
+	}
+	tests := map[string]struct {
+		Manifest         string
+		ExpectedElements []string
+		ExpectedError    string
+	}{
+		"NoIncludes": {
+			Manifest:         `<manifest><default remote="cos"/><project name="a"/></manifest>`,
+			ExpectedElements: []string{"default", "project"},
+		},
+		"Nested": {
+			Manifest:         `<manifest><include name="default.xml"/><default remote="cos"/><project name="a"/></manifest>`,
+			ExpectedElements: []string{"remote", "project", "default", "project"},
+		},
+		"Cycle": {
+			Manifest:      `<manifest><include name="cycle-a.xml"/></manifest>`,
+			ExpectedError: "include cycle: cycle-a.xml -> cycle-b.xml -> cycle-a.xml",
+		},
+		"SelfInclude": {
+			Manifest:      `<manifest><include name="self.xml"/></manifest>`,
+			ExpectedError: "include cycle: self.xml -> self.xml",
+		},
+		"Missing": {
+			Manifest:      `<manifest><include name="missing.xml"/></manifest>`,
+			ExpectedError: "missing.xml not found",
+		},
+		"MissingName": {
+			Manifest:      `<manifest><include/></manifest>`,
+			ExpectedError: "<include> is missing a name",
+		},
+	}
+	for name, test := range tests {
+		root, err := manifestRoot(test.Manifest)
+		if err != nil {
+			t.Fatalf("test %q failed: invalid test manifest: %v", name, err)
+		}
+		err = resolveIncludes(root, fetch, nil)
+		if test.ExpectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), test.ExpectedError) {
+				t.Errorf("test %q failed: expected error containing %q, got: %v", name, test.ExpectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		var elements []string
+		for _, child := range root.ChildElements() {
+			elements = append(elements, child.Tag)
+		}
+		if !reflect.DeepEqual(elements, test.ExpectedElements) {
+			t.Errorf("test %q failed: expected elements %v, got %v", name, test.ExpectedElements, elements)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"container/list"
+	"sync"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+// DefaultManifestCacheSize is the number of manifests kept by a ManifestCache
+// created with a non-positive size.
+const DefaultManifestCacheSize = 256
+
+// ManifestCache stores parsed manifest files so that they can be reused
+// across changelog requests, evicting the least recently used manifest when
+// it is full. Only manifests of build numbers are cached, since a manifest
+// snapshot never changes once it is tagged. Failed retrievals are not cached.
+//
+// Manifests are cached regardless of the credentials used to retrieve them,
+// so a cache should only be shared by callers with the same access to the
+// manifest repositories. A ManifestCache is safe for concurrent use.
+type ManifestCache struct {
+	mu      sync.Mutex
+	maxSize int
+	// order lists the cached manifests from most to least recently used.
+	order   *list.List
+	entries map[manifestKey]*list.Element
+}
+
+type manifestKey struct {
+	host     string
+	repo     string
+	buildNum string
+}
+
+type manifestEntry struct {
+	key   manifestKey
+	once  sync.Once
+	repos map[string]*repo
+	err   utils.ChangelogError
+}
+
+// NewManifestCache creates an empty ManifestCache holding up to maxSize
+// manifests, or DefaultManifestCacheSize manifests if maxSize is not positive.
+func NewManifestCache(maxSize int) *ManifestCache {
+	if maxSize <= 0 {
+		maxSize = DefaultManifestCacheSize
+	}
+	return &ManifestCache{
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[manifestKey]*list.Element),
+	}
+}
+
+// Len returns the number of cached manifests.
+func (c *ManifestCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// mappedManifest is like the mappedManifest function, returning the cached
+// manifest of the build if there is one. A nil cache retrieves every
+// manifest. Concurrent calls for the same manifest only retrieve it once.
+func (c *ManifestCache) mappedManifest(client gitilesProto.GitilesClient, host, manifestRepo, buildInput, buildNum string) (map[string]*repo, utils.ChangelogError) {
+	if c == nil || !buildNumRe.MatchString(buildNum) {
+		return mappedManifest(client, manifestRepo, buildInput, buildNum)
+	}
+	key := manifestKey{host: host, repo: manifestRepo, buildNum: buildNum}
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if ok {
+		c.order.MoveToFront(elem)
+	} else {
+		elem = c.order.PushFront(&manifestEntry{key: key})
+		c.entries[key] = elem
+		for c.order.Len() > c.maxSize {
+			c.remove(c.order.Back())
+		}
+	}
+	c.mu.Unlock()
+	entry := elem.Value.(*manifestEntry)
+	entry.once.Do(func() {
+		entry.repos, entry.err = mappedManifest(client, manifestRepo, buildInput, buildNum)
+	})
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == elem {
+			c.remove(elem)
+		}
+		c.mu.Unlock()
+		return nil, entry.err
+	}
+	return copyRepos(entry.repos), nil
+}
+
+// remove evicts a cached manifest. c.mu must be held.
+func (c *ManifestCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*manifestEntry).key)
+}
+
+// copyRepos returns a deep copy of a parsed manifest, so that callers can
+// modify it without affecting the cached manifest.
+func copyRepos(repos map[string]*repo) map[string]*repo {
+	output := make(map[string]*repo, len(repos))
+	for path, repoData := range repos {
+		repoCopy := *repoData
+		output[path] = &repoCopy
+	}
+	return output
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// markdownSHALength is the number of characters of a commit SHA displayed in
+// a markdown changelog, matching the changelog web app.
+const markdownSHALength = 8
+
+// markdownEscaper escapes characters in commit subjects that would otherwise
+// be interpreted as markdown formatting.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+)
+
+// RenderMarkdown renders a changelog as a markdown document suitable for
+// release notes. Each repository gets a level 2 header with its path,
+// followed by a list of its commits with the abbreviated commit SHA linking
+// to the commit on gitiles and the commit subject.
+//
+// Repositories are ordered by path and commits keep their order, as in the
+// JSON output. Repositories without commits are omitted.
+func RenderMarkdown(changes map[string]*RepoLog) (string, error) {
+	paths := make([]string, 0, len(changes))
+	for path, repoLog := range changes {
+		if repoLog == nil || len(repoLog.Commits) == 0 {
+			continue
+		}
+		if repoLog.InstanceURL == "" || repoLog.Repo == "" {
+			return "", fmt.Errorf("RenderMarkdown: missing instance URL or repository for %s, cannot link commits", path)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var sb strings.Builder
+	for i, path := range paths {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		repoLog := changes[path]
+		fmt.Fprintf(&sb, "## %s\n\n", path)
+		for _, commit := range repoLog.Commits {
+			sha := commit.SHA
+			if len(sha) > markdownSHALength {
+				sha = sha[:markdownSHALength]
+			}
+			link := fmt.Sprintf("https://%s/%s/+/%s", repoLog.InstanceURL, repoLog.Repo, commit.SHA)
+			fmt.Fprintf(&sb, "- [`%s`](%s) %s\n", sha, link, markdownEscaper.Replace(commit.Subject))
+		}
+		if repoLog.HasMoreCommits {
+			sb.WriteString("- *More commits not shown.*\n")
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2020, 9, d, h, 0, 0, 0, time.UTC) }
+	changes := map[string]*RepoLog{
+		"src/third_party/kernel/v5.4": onelineTestLog(t,
+			onelineTestCommit("9d3f1b7e4a1c2b3d4e5f60718293a4b5c6d7e8f9", "UPSTREAM: fix use-after-free in bpf\n\nBUG=b/1234", day(7, 15)),
+			onelineTestCommit("1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d", "CHROMIUM: enable lockdown LSM", day(3, 9)),
+		),
+		"src/overlays": onelineTestLog(t,
+			onelineTestCommit("0011223344556677889900aabbccddeeff001122", "lakitu: add sysctl defaults", day(5, 12)),
+			onelineTestCommit("aabbccddeeff00112233445566778899aabbccdd", "lakitu: bump docker to [19.03.13] *now*", day(7, 15)),
+		),
+		"src/platform/dev": {
+			Commits: []*Commit{{SHA: "abc", Subject: "short_sha"}},
+		},
+		"src/empty": {},
+	}
+	changes["src/third_party/kernel/v5.4"].Repo = "third_party/kernel"
+	changes["src/overlays"].Repo = "cos/overlays"
+	changes["src/overlays"].HasMoreCommits = true
+	changes["src/platform/dev"].Repo = "platform/dev-util"
+	for _, repoLog := range changes {
+		repoLog.InstanceURL = "cos.googlesource.com"
+	}
+	golden := "testdata/markdown.golden"
+	got, err := RenderMarkdown(changes)
+	if err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	if *update {
+		if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatalf("failed to update %s: %v", golden, err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", golden, err)
+	}
+	if got != string(want) {
+		t.Errorf("RenderMarkdown failed, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenderMarkdownMissingRepo(t *testing.T) {
+	changes := map[string]*RepoLog{
+		"src/overlays": {Commits: []*Commit{{SHA: "aabbccddeeff", Subject: "lakitu: add sysctl defaults"}}},
+	}
+	if _, err := RenderMarkdown(changes); err == nil {
+		t.Errorf("RenderMarkdown succeeded without a repository; want error")
+	}
+}
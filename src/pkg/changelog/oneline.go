@@ -0,0 +1,78 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// shortSHALength is the number of characters of a commit SHA displayed in
+// a oneline log, matching the default of `git log --oneline`.
+const shortSHALength = 7
+
+type onelineEntry struct {
+	path   string
+	commit *Commit
+	time   time.Time
+}
+
+// entryTime returns the time used to order a commit in a oneline log. If the
+// exact commit time is unknown, the day in CommitTime is used.
+func entryTime(commit *Commit) time.Time {
+	if !commit.committed.IsZero() {
+		return commit.committed
+	}
+	t, err := time.Parse("Mon, 2 Jan 2006", commit.CommitTime)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// OnelineLog flattens a changelog into a single list of commits across all
+// repositories, similar to a unified `git log --oneline`. Each line contains
+// the repository path, the abbreviated commit SHA and the commit subject.
+//
+// Commits are ordered from newest to oldest. Commits with the same commit
+// time are ordered by repository path, and keep their order within each
+// repository.
+func OnelineLog(changes map[string]*RepoLog) string {
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var entries []onelineEntry
+	for _, path := range paths {
+		for _, commit := range changes[path].Commits {
+			entries = append(entries, onelineEntry{path, commit, entryTime(commit)})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].time.After(entries[j].time)
+	})
+	var sb strings.Builder
+	for _, entry := range entries {
+		sha := entry.commit.SHA
+		if len(sha) > shortSHALength {
+			sha = sha[:shortSHALength]
+		}
+		fmt.Fprintf(&sb, "%s %s %s\n", entry.path, sha, entry.commit.Subject)
+	}
+	return sb.String()
+}
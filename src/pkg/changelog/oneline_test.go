@@ -0,0 +1,87 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func onelineTestLog(t *testing.T, commits ...*git.Commit) *RepoLog {
+	parsed, err := ParseGitCommitLog(commits)
+	if err != nil {
+		t.Fatalf("failed to parse test commits: %v", err)
+	}
+	return &RepoLog{Commits: parsed}
+}
+
+func onelineTestCommit(sha, message string, committed time.Time) *git.Commit {
+	signature := &git.Commit_User{
+		Name:  "Test User",
+		Email: "test@google.com",
+		Time:  timestamppb.New(committed),
+	}
+	return &git.Commit{
+		Id:        sha,
+		Author:    signature,
+		Committer: signature,
+		Message:   message,
+	}
+}
+
+func TestOnelineLog(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2020, 9, d, h, 0, 0, 0, time.UTC) }
+	changes := map[string]*RepoLog{
+		"src/third_party/kernel/v5.4": onelineTestLog(t,
+			onelineTestCommit("9d3f1b7e4a1c2b3d4e5f60718293a4b5c6d7e8f9", "UPSTREAM: fix use-after-free in bpf\n\nBUG=b/1234", day(7, 15)),
+			onelineTestCommit("1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d", "CHROMIUM: enable lockdown LSM", day(3, 9)),
+		),
+		"src/overlays": onelineTestLog(t,
+			onelineTestCommit("aabbccddeeff00112233445566778899aabbccdd", "lakitu: bump docker to 19.03.13", day(7, 15)),
+			onelineTestCommit("0011223344556677889900aabbccddeeff001122", "lakitu: add sysctl defaults", day(5, 12)),
+		),
+		"src/platform/dev": onelineTestLog(t,
+			onelineTestCommit("fedcba9876543210fedcba9876543210fedcba98", "cos-customizer: log step duration", day(9, 8)),
+		),
+		"src/empty": {},
+	}
+	// Commits without an exact commit time are ordered by their commit day.
+	changes["src/platform/dev"].Commits = append(changes["src/platform/dev"].Commits, &Commit{
+		SHA:        "abc",
+		Subject:    "short sha",
+		CommitTime: "Fri, 4 Sep 2020",
+	})
+	golden := "testdata/oneline.golden"
+	got := OnelineLog(changes)
+	if *update {
+		if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatalf("failed to update %s: %v", golden, err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", golden, err)
+	}
+	if got != string(want) {
+		t.Errorf("OnelineLog failed, got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import "sort"
+
+// RepoSummary describes the changes to a repository without listing its
+// commits.
+type RepoSummary struct {
+	Path        string
+	Repo        string
+	InstanceURL string
+	SourceSHA   string
+	TargetSHA   string
+	// CommitCount and HasMoreCommits are the same as in RepoLog.
+	CommitCount    int
+	HasMoreCommits bool
+}
+
+// Summarize returns a summary of each repository with commits in a
+// changelog, sorted by repository path. Repositories without commits are
+// omitted. A summary is cheaper to serialize and display than the full
+// changelog when only the changed repositories are of interest.
+func Summarize(changes map[string]*RepoLog) []*RepoSummary {
+	summaries := []*RepoSummary{}
+	for path, repoLog := range changes {
+		if len(repoLog.Commits) == 0 {
+			continue
+		}
+		summaries = append(summaries, &RepoSummary{
+			Path:           path,
+			Repo:           repoLog.Repo,
+			InstanceURL:    repoLog.InstanceURL,
+			SourceSHA:      repoLog.SourceSHA,
+			TargetSHA:      repoLog.TargetSHA,
+			CommitCount:    repoLog.CommitCount,
+			HasMoreCommits: repoLog.HasMoreCommits,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Path < summaries[j].Path
+	})
+	return summaries
+}
@@ -0,0 +1,61 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	fake := newFakeGitiles(t)
+	fake.manifests["1.0.0"] = fake.manifest(
+		[3]string{"cos/overlays", "src/overlays", "overlays-a"},
+		[3]string{"cos/kernel", "src/kernel", "kernel-a"},
+		[3]string{"cos/platform", "src/platform", "platform-a"},
+	)
+	fake.manifests["2.0.0"] = fake.manifest(
+		[3]string{"cos/overlays", "src/overlays", "overlays-b"},
+		[3]string{"cos/kernel", "src/kernel", "kernel-b"},
+		[3]string{"cos/platform", "src/platform", "platform-a"},
+	)
+	fake.logs["overlays-b"] = []string{"overlays-b", "overlays-1", "overlays-2"}
+	// The kernel was updated to a commit without any new history.
+	fake.logs["kernel-b"] = []string{}
+
+	additions, _, err := Changelog(context.Background(), fake.client(), "1.0.0", "2.0.0", fake.host(), defaultManifestRepo, "", -1)
+	if err != nil {
+		t.Fatalf("changelog failed, unexpected error: %v", err)
+	}
+	additions["src/empty"] = &RepoLog{Repo: "cos/empty"}
+	expected := []*RepoSummary{{
+		Path:        "src/overlays",
+		Repo:        "cos/overlays",
+		InstanceURL: fake.host(),
+		SourceSHA:   "overlays-a",
+		TargetSHA:   "overlays-b",
+		CommitCount: 3,
+	}}
+	if summaries := Summarize(additions); !reflect.DeepEqual(summaries, expected) {
+		t.Errorf("Summarize failed, expected %+v, got:", *expected[0])
+		for _, summary := range summaries {
+			t.Errorf("  %+v", *summary)
+		}
+	}
+	if summaries := Summarize(nil); summaries == nil || len(summaries) != 0 {
+		t.Errorf("Summarize failed, expected an empty summary for an empty changelog, got %v", summaries)
+	}
+}
@@ -1,7 +1,8 @@
 package cos
 
 import (
-	"io/ioutil"
+	"bytes"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -27,6 +28,9 @@ const (
 	crosKernelRepo   = "https://chromium.googlesource.com/chromiumos/third_party/kernel"
 )
 
+// downloadFromGCS is a variable so tests can stub out GCS reads.
+var downloadFromGCS = utils.DownloadFromGCSTo
+
 // Map VM zone prefix to specific cos-tools bucket for geo-redundancy.
 var cosToolsPrefixMap = map[string]string{
 	"us":           cosToolsGCS,
@@ -82,6 +86,12 @@ func NewGCSDownloader(e *EnvReader, bucket, prefix string) *GCSDownloader {
 	return &GCSDownloader{e, bucket, prefix}
 }
 
+// Bucket returns the GCS bucket artifacts are downloaded from.
+func (d *GCSDownloader) Bucket() string { return d.gcsDownloadBucket }
+
+// Prefix returns the GCS path prefix artifacts are downloaded from.
+func (d *GCSDownloader) Prefix() string { return d.gcsDownloadPrefix }
+
 // DownloadKernelSrc downloads COS kernel sources to destination directory.
 func (d *GCSDownloader) DownloadKernelSrc(destDir string) error {
 	return d.DownloadArtifact(destDir, kernelSrcArchive)
@@ -104,28 +114,32 @@ func (d *GCSDownloader) DownloadKernelHeaders(destDir string) error {
 
 // GetArtifact gets an artifact from GCS buckets and returns its content.
 func (d *GCSDownloader) GetArtifact(artifactPath string) ([]byte, error) {
-	tmpDir, err := ioutil.TempDir("", "tmp")
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create temp dir")
-	}
-	defer os.RemoveAll(tmpDir)
-
-	if err = d.DownloadArtifact(tmpDir, artifactPath); err != nil {
+	var buf bytes.Buffer
+	if err := d.DownloadArtifactTo(&buf, artifactPath); err != nil {
 		return nil, errors.Wrapf(err, "failed to download artifact %s", artifactPath)
 	}
+	return buf.Bytes(), nil
+}
 
-	content, err := ioutil.ReadFile(filepath.Join(tmpDir, filepath.Base(artifactPath)))
+// DownloadArtifact downloads an artifact from the GCS prefix configured in GCSDownloader.
+func (d *GCSDownloader) DownloadArtifact(destDir, artifactPath string) error {
+	outputPath := filepath.Join(destDir, filepath.Base(artifactPath))
+	outputFile, err := os.Create(outputPath)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read file %s", filepath.Join(tmpDir, artifactPath))
+		return errors.Wrapf(err, "failed to create file %s", outputPath)
 	}
-
-	return content, nil
+	if err := d.DownloadArtifactTo(outputFile, artifactPath); err != nil {
+		outputFile.Close()
+		return err
+	}
+	return outputFile.Close()
 }
 
-// DownloadArtifact downloads an artifact from the GCS prefix configured in GCSDownloader.
-func (d *GCSDownloader) DownloadArtifact(destDir, artifactPath string) error {
+// DownloadArtifactTo streams an artifact from the GCS prefix configured in
+// GCSDownloader to w without touching disk.
+func (d *GCSDownloader) DownloadArtifactTo(w io.Writer, artifactPath string) error {
 	gcsPath := path.Join(d.gcsDownloadPrefix, artifactPath)
-	if err := utils.DownloadFromGCS(destDir, d.gcsDownloadBucket, gcsPath); err != nil {
+	if err := downloadFromGCS(w, d.gcsDownloadBucket, gcsPath); err != nil {
 		return errors.Errorf("failed to download %s from gs://%s/%s : %s", artifactPath, d.gcsDownloadBucket, gcsPath, err)
 	}
 	return nil
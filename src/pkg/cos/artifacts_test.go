@@ -0,0 +1,81 @@
+package cos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeGCSReader serves objects from memory in place of GCS.
+type fakeGCSReader map[string]string
+
+func (f fakeGCSReader) download(w io.Writer, bucket, path string) error {
+	content, ok := f["gs://"+bucket+"/"+path]
+	if !ok {
+		return fmt.Errorf("object gs://%s/%s not found", bucket, path)
+	}
+	_, err := io.WriteString(w, content)
+	return err
+}
+
+func withFakeGCS(t *testing.T, objects fakeGCSReader) {
+	t.Helper()
+	orig := downloadFromGCS
+	downloadFromGCS = objects.download
+	t.Cleanup(func() { downloadFromGCS = orig })
+}
+
+func TestDownloadArtifactTo(t *testing.T) {
+	withFakeGCS(t, fakeGCSReader{
+		"gs://test-bucket/17800.0.0/lakitu/manifest.json": `{"build": "17800.0.0"}`,
+	})
+	d := &GCSDownloader{gcsDownloadBucket: "test-bucket", gcsDownloadPrefix: "17800.0.0/lakitu"}
+
+	var buf bytes.Buffer
+	if err := d.DownloadArtifactTo(&buf, "manifest.json"); err != nil {
+		t.Fatalf("DownloadArtifactTo() failed: %v", err)
+	}
+	if got, want := buf.String(), `{"build": "17800.0.0"}`; got != want {
+		t.Errorf("DownloadArtifactTo() wrote %q; want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := d.DownloadArtifactTo(&buf, "missing.json"); err == nil {
+		t.Errorf("DownloadArtifactTo() succeeded for a missing artifact; want error")
+	}
+}
+
+func TestDownloadArtifact(t *testing.T) {
+	withFakeGCS(t, fakeGCSReader{
+		"gs://test-bucket/17800.0.0/lakitu/gpu/toolchain_env": "CC=clang",
+	})
+	d := &GCSDownloader{gcsDownloadBucket: "test-bucket", gcsDownloadPrefix: "17800.0.0/lakitu"}
+	tmpDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := d.DownloadArtifact(tmpDir, "gpu/toolchain_env"); err != nil {
+		t.Fatalf("DownloadArtifact() failed: %v", err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(tmpDir, "toolchain_env"))
+	if err != nil {
+		t.Fatalf("Failed to read downloaded artifact: %v", err)
+	}
+	if string(got) != "CC=clang" {
+		t.Errorf("DownloadArtifact() wrote %q; want %q", got, "CC=clang")
+	}
+
+	content, err := d.GetArtifact("gpu/toolchain_env")
+	if err != nil {
+		t.Fatalf("GetArtifact() failed: %v", err)
+	}
+	if string(content) != "CC=clang" {
+		t.Errorf("GetArtifact() returned %q; want %q", content, "CC=clang")
+	}
+}
@@ -42,34 +42,84 @@ func CheckKernelModuleSigning(kernelCmdline string) bool {
 	return true
 }
 
+// CheckFirmwareSigning checks whether the IMA policy requires firmware to be signed.
+func CheckFirmwareSigning(imaPolicy string) bool {
+	for _, rule := range strings.Split(imaPolicy, "\n") {
+		fields := strings.Fields(rule)
+		if len(fields) == 0 || fields[0] != "appraise" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if field == "func=FIRMWARE_CHECK" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // SetCompilationEnv sets compilation environment variables (e.g. CC, CXX) for third-party kernel module compilation.
 // TODO(mikewu): pass environment variables to the *exec.Cmd that runs the installer.
 func SetCompilationEnv(downloader ArtifactsDownloader) error {
+	compilationEnvs, err := CompilationEnv(downloader, os.TempDir())
+	if err != nil {
+		return err
+	}
+	log.V(2).Info("Setting compilation environment variables")
+	for key, value := range compilationEnvs {
+		log.V(2).Infof("%s=%s", key, value)
+		os.Setenv(key, value)
+	}
+	return nil
+}
+
+// CompilationEnv returns the compilation environment variables (e.g. CC, CXX)
+// for third-party kernel module compilation, without setting them. The
+// toolchain_env file is downloaded to dir.
+func CompilationEnv(downloader ArtifactsDownloader, dir string) (map[string]string, error) {
 	log.V(2).Info("Downloading compilation environment variables")
 
 	compilationEnvs := make(map[string]string)
 
-	if err := downloader.DownloadToolchainEnv(os.TempDir()); err != nil {
+	if err := downloader.DownloadToolchainEnv(dir); err != nil {
 		// Required to support COS builds not having toolchain_env file
 		log.V(2).Info("Using default compilation environment variables")
 		compilationEnvs["CC"] = "x86_64-cros-linux-gnu-gcc"
 		compilationEnvs["CXX"] = "x86_64-cros-linux-gnu-g++"
 	} else {
-		if compilationEnvs, err = utils.LoadEnvFromFile(os.TempDir(), toolchainEnv); err != nil {
-			return errors.Wrap(err, "failed to parse toolchain_env file")
+		if compilationEnvs, err = utils.LoadEnvFromFile(dir, toolchainEnv); err != nil {
+			return nil, errors.Wrap(err, "failed to parse toolchain_env file")
 		}
 	}
+	return compilationEnvs, nil
+}
 
-	log.V(2).Info("Setting compilation environment variables")
-	for key, value := range compilationEnvs {
-		log.V(2).Infof("%s=%s", key, value)
+// InstallCrossToolchain installs COS toolchain and kernel headers to destination directory
+// and sets the environment variables returned by CrossToolchainEnv.
+func InstallCrossToolchain(downloader ArtifactsDownloader, destDir string) error {
+	if err := UnpackCrossToolchain(downloader, destDir); err != nil {
+		return err
+	}
+	log.V(2).Info("Configuring environment variables for cross-compilation")
+	for key, value := range CrossToolchainEnv(destDir, os.Getenv("PATH")) {
 		os.Setenv(key, value)
 	}
 	return nil
 }
 
-// InstallCrossToolchain installs COS toolchain and kernel headers to destination directory.
-func InstallCrossToolchain(downloader ArtifactsDownloader, destDir string) error {
+// CrossToolchainEnv returns the environment variables (PATH, SYSROOT) for
+// cross-compilation with the toolchain installed in destDir, where path is the
+// PATH to extend.
+func CrossToolchainEnv(destDir, path string) map[string]string {
+	return map[string]string{
+		"PATH":    fmt.Sprintf("%s/bin:%s", destDir, path),
+		"SYSROOT": filepath.Join(destDir, "usr/x86_64-cros-linux-gnu"),
+	}
+}
+
+// UnpackCrossToolchain installs COS toolchain and kernel headers to destination
+// directory, without changing the environment.
+func UnpackCrossToolchain(downloader ArtifactsDownloader, destDir string) error {
 	log.Info("Installing the toolchain")
 
 	if err := os.MkdirAll(destDir, 0755); err != nil {
@@ -112,10 +162,6 @@ func InstallCrossToolchain(downloader ArtifactsDownloader, destDir string) error
 		}
 		log.Info("Done unpacking kernel headers")
 	}
-
-	log.V(2).Info("Configuring environment variables for cross-compilation")
-	os.Setenv("PATH", fmt.Sprintf("%s/bin:%s", destDir, os.Getenv("PATH")))
-	os.Setenv("SYSROOT", filepath.Join(destDir, "usr/x86_64-cros-linux-gnu"))
 	return nil
 }
 
@@ -264,6 +310,16 @@ func symlinkLinker(symlinkPath string) error {
 // kernel always uses -Werror=strict-prototypes by default. This wrapper removes
 // -Werror=strict-prototypes from the CC command line.
 func AddCCWrapperToPath(toolchainDir, workDir, cc string) error {
+	if err := WriteCCWrapper(toolchainDir, workDir, cc); err != nil {
+		return err
+	}
+	os.Setenv("PATH", fmt.Sprintf("%s:%s", workDir, os.Getenv("PATH")))
+	return nil
+}
+
+// WriteCCWrapper creates the CC wrapper of AddCCWrapperToPath in workDir,
+// without adding workDir to the env PATH.
+func WriteCCWrapper(toolchainDir, workDir, cc string) error {
 	wrapper := `#!/bin/bash
 for arg; do
   shift
@@ -277,6 +333,5 @@ done
 	}
 	log.V(2).Info("Creating a CC wrapper removing -Werror=strict-prototypes from the CC command line.")
 	log.V(2).Info(wrapper)
-	os.Setenv("PATH", fmt.Sprintf("%s:%s", workDir, os.Getenv("PATH")))
 	return nil
 }
@@ -150,6 +150,36 @@ func TestSetCompilationEnv(t *testing.T) {
 	}
 }
 
+func TestCompilationEnv(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	origCC := os.Getenv("CC")
+
+	downloader := fakeDownloader{}
+	envs, err := CompilationEnv(&downloader, tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to run CompilationEnv: %v", err)
+	}
+
+	for _, tc := range []struct {
+		envKey           string
+		expectedEnvValue string
+	}{
+		{"CC", "x86_64-cros-linux-gnu-clang"},
+		{"CXX", "x86_64-cros-linux-gnu-clang++"},
+	} {
+		if envs[tc.envKey] != tc.expectedEnvValue {
+			t.Errorf("Unexpected env %s value: want: %s, got: %s", tc.envKey, tc.expectedEnvValue, envs[tc.envKey])
+		}
+	}
+	if os.Getenv("CC") != origCC {
+		t.Errorf("CompilationEnv changed env CC: want: %s, got: %s", origCC, os.Getenv("CC"))
+	}
+}
+
 func TestInstallCrossToolchain(t *testing.T) {
 	origEnvs := os.Environ()
 	defer func() {
@@ -300,3 +330,36 @@ func (*fakeDownloader) DownloadArtifact(string, string) error { return nil }
 func (*fakeDownloader) GetArtifact(string) ([]byte, error) { return nil, nil }
 
 func (*fakeDownloader) ArtifactExists(string) (bool, error) { return false, nil }
+
+func TestCheckFirmwareSigning(t *testing.T) {
+	for _, tc := range []struct {
+		testName  string
+		imaPolicy string
+		expectOut bool
+	}{
+		{
+			"AppraisesFirmware",
+			"measure func=BPRM_CHECK\nappraise func=FIRMWARE_CHECK appraise_type=imasig\n",
+			true,
+		},
+		{
+			"MeasuresFirmwareOnly",
+			"measure func=FIRMWARE_CHECK\nappraise func=MODULE_CHECK appraise_type=imasig\n",
+			false,
+		},
+		{
+			"DontAppraise",
+			"dont_appraise func=FIRMWARE_CHECK\n",
+			false,
+		},
+		{
+			"EmptyPolicy",
+			"",
+			false,
+		},
+	} {
+		if got := CheckFirmwareSigning(tc.imaPolicy); got != tc.expectOut {
+			t.Errorf("%s: Unexpected CheckFirmwareSigning result, want: %v, got: %v", tc.testName, tc.expectOut, got)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package cos
 
 import (
+	"bufio"
+	"compress/gzip"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
@@ -21,6 +25,39 @@ const (
 	releaseTrack   = "CHROMEOS_RELEASE_TRACK"
 )
 
+// kernelConfigPath is the compressed config of the running kernel. It is a
+// variable so tests can point it at a sample config.
+var kernelConfigPath = "/proc/config.gz"
+
+// Channel is the COS release channel an image was built for.
+type Channel int
+
+const (
+	// ChannelUnknown is any release track that is not a recognized channel.
+	ChannelUnknown Channel = iota
+	ChannelDev
+	ChannelBeta
+	ChannelStable
+	ChannelLTS
+)
+
+var channelTracks = map[string]Channel{
+	"dev-channel":    ChannelDev,
+	"beta-channel":   ChannelBeta,
+	"stable-channel": ChannelStable,
+	"lts-channel":    ChannelLTS,
+}
+
+// String returns the release track name of the channel.
+func (ch Channel) String() string {
+	for track, c := range channelTracks {
+		if c == ch {
+			return track
+		}
+	}
+	return "unknown"
+}
+
 // EnvReader is to read system configurations of COS.
 // TODO(mikewu): rename EnvReader to a better name.
 type EnvReader struct {
@@ -59,6 +96,15 @@ func (c *EnvReader) OsRelease() map[string]string { return c.osRelease }
 // ReleaseTrack returns the COS release track.
 func (c *EnvReader) ReleaseTrack() string { return c.lsbRelease[releaseTrack] }
 
+// Channel returns the COS release channel classified from the release track.
+// Release tracks that are not a known channel are reported as ChannelUnknown.
+func (c *EnvReader) Channel() Channel {
+	if ch, ok := channelTracks[c.ReleaseTrack()]; ok {
+		return ch
+	}
+	return ChannelUnknown
+}
+
 // Board returns the COS board name. Retrieved from /etc/lsb-release.
 func (c *EnvReader) Board() string { return c.lsbRelease["CHROMEOS_RELEASE_BOARD"] }
 
@@ -78,6 +124,36 @@ func (c *EnvReader) ToolchainPath() string { return c.toolchainPath }
 // KernelRelease return COS kernel release, i.e. `uname -r`
 func (c *EnvReader) KernelRelease() string { return charsToString(c.uname.Release[:]) }
 
+// KernelConfig returns the value of a config option of the running kernel,
+// e.g. "y" for KernelConfig("CONFIG_MODULE_SIG_FORCE"). The "CONFIG_" prefix
+// may be omitted. It returns an empty string if the option is not set.
+func (c *EnvReader) KernelConfig(option string) (string, error) {
+	if !strings.HasPrefix(option, "CONFIG_") {
+		option = "CONFIG_" + option
+	}
+	f, err := os.Open(kernelConfigPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open kernel config %s", kernelConfigPath)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to decompress kernel config %s", kernelConfigPath)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), option+"="); value != scanner.Text() {
+			return value, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errors.Wrapf(err, "failed to read kernel config %s", kernelConfigPath)
+	}
+	return "", nil
+}
+
 // charsToString converts a c-style byte array (null-terminated string) to string.
 func charsToString(chars []int8) string {
 	s := make([]byte, 0, len(chars))
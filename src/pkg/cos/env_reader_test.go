@@ -1,6 +1,7 @@
 package cos
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -113,3 +114,113 @@ func createConfigFile(configStr, configFileName, testDir string) error {
 	}
 	return nil
 }
+
+func TestEnvReaderChannel(t *testing.T) {
+	osReleaseString := `BUILD_ID=17800.66.78
+NAME="Container-Optimized OS"
+VERSION=109
+ID=cos`
+	for _, tc := range []struct {
+		track  string
+		expect Channel
+	}{
+		{"dev-channel", ChannelDev},
+		{"beta-channel", ChannelBeta},
+		{"stable-channel", ChannelStable},
+		{"lts-channel", ChannelLTS},
+		{"testimage-channel", ChannelUnknown},
+		{"", ChannelUnknown},
+	} {
+		t.Run(tc.track, func(t *testing.T) {
+			testDir, err := ioutil.TempDir("", "testing")
+			if err != nil {
+				t.Fatalf("Failed to create tempdir: %v", err)
+			}
+			defer os.RemoveAll(testDir)
+
+			lsbReleaseString := "CHROMEOS_RELEASE_BOARD=lakitu\n"
+			if tc.track != "" {
+				lsbReleaseString += "CHROMEOS_RELEASE_TRACK=" + tc.track + "\n"
+			}
+			if err := createConfigFile(osReleaseString, osReleasePath, testDir); err != nil {
+				t.Fatalf("Failed to create osRelease file: %v", err)
+			}
+			if err := createConfigFile(lsbReleaseString, lsbReleasePath, testDir); err != nil {
+				t.Fatalf("Failed to create lsbRelease file: %v", err)
+			}
+			envReader, err := NewEnvReader(testDir)
+			if err != nil {
+				t.Fatalf("Failed to create EnvReader: %v", err)
+			}
+			if got := envReader.Channel(); got != tc.expect {
+				t.Errorf("Unexpected Channel for track %q, want: %v, got: %v", tc.track, tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestEnvReaderKernelConfig(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "testing")
+	if err != nil {
+		t.Fatalf("Failed to create tempdir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	kernelConfigString := `#
+# Automatically generated file; DO NOT EDIT.
+# Linux/x86 5.15.107 Kernel Configuration
+#
+CONFIG_MODULES=y
+CONFIG_MODULE_SIG=y
+CONFIG_MODULE_SIG_FORCE=y
+CONFIG_MODULE_SIG_HASH="sha256"
+CONFIG_DRM=m
+# CONFIG_MODULE_COMPRESS is not set
+`
+	configFile, err := os.Create(filepath.Join(testDir, "config.gz"))
+	if err != nil {
+		t.Fatalf("Failed to create config.gz: %v", err)
+	}
+	gz := gzip.NewWriter(configFile)
+	if _, err := gz.Write([]byte(kernelConfigString)); err != nil {
+		t.Fatalf("Failed to write config.gz: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to write config.gz: %v", err)
+	}
+	if err := configFile.Close(); err != nil {
+		t.Fatalf("Failed to close config.gz: %v", err)
+	}
+
+	origKernelConfigPath := kernelConfigPath
+	defer func() { kernelConfigPath = origKernelConfigPath }()
+	kernelConfigPath = filepath.Join(testDir, "config.gz")
+
+	envReader := &EnvReader{}
+	for _, tc := range []struct {
+		option string
+		expect string
+	}{
+		{"CONFIG_MODULE_SIG_FORCE", "y"},
+		{"MODULE_SIG_FORCE", "y"},
+		{"CONFIG_MODULE_SIG", "y"},
+		{"CONFIG_MODULE_SIG_HASH", `"sha256"`},
+		{"CONFIG_DRM", "m"},
+		{"CONFIG_MODULE_COMPRESS", ""},
+		{"CONFIG_DOES_NOT_EXIST", ""},
+	} {
+		got, err := envReader.KernelConfig(tc.option)
+		if err != nil {
+			t.Errorf("KernelConfig(%q) failed: %v", tc.option, err)
+			continue
+		}
+		if got != tc.expect {
+			t.Errorf("Unexpected KernelConfig(%q), want: %q, got: %q", tc.option, tc.expect, got)
+		}
+	}
+
+	kernelConfigPath = filepath.Join(testDir, "missing.gz")
+	if _, err := envReader.KernelConfig("CONFIG_MODULES"); err == nil {
+		t.Errorf("KernelConfig() succeeded with missing kernel config; want error")
+	}
+}
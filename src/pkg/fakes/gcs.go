@@ -28,6 +28,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 
 	"cloud.google.com/go/storage"
@@ -56,7 +57,8 @@ func setTransportAddr(transport *http.Transport, addr string) {
 // are implemented here. Documentation for the GCS JSON API is here:
 // https://cloud.google.com/storage/docs/json_api/v1/
 //
-// This struct should not be considered concurrency safe.
+// Requests to the fake GCS server are handled one at a time, but the fields of this
+// struct should not be modified while requests are in flight.
 type GCS struct {
 	// Objects represents the collection of objects that exist in the fake GCS server.
 	// Keys are strings of the form "/<bucket>/<object path>". Values are data that belong
@@ -66,17 +68,23 @@ type GCS struct {
 	Client *storage.Client
 	// Server is the fake GCS server. It uses state from this struct for serving requests.
 	Server *httptest.Server
+
+	mu sync.Mutex
 }
 
 // NewGCSServer constructs a fake GCS implementation.
 func NewGCSServer(ctx context.Context) (*GCS, error) {
 	var err error
-	gcs := &GCS{make(map[string][]byte), nil, nil}
+	gcs := &GCS{Objects: make(map[string][]byte)}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", gcs.objectHandler)
 	mux.HandleFunc("/storage/v1/b/", gcs.bucketHandler)
 	mux.HandleFunc("/upload/storage/v1/b/", gcs.uploadHandler)
-	gcs.Server = httptest.NewTLSServer(mux)
+	gcs.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gcs.mu.Lock()
+		defer gcs.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
 	httpClient := gcs.Server.Client()
 	setTransportAddr(httpClient.Transport.(*http.Transport), gcs.Server.Listener.Addr().String())
 	gcs.Client, err = storage.NewClient(ctx, option.WithHTTPClient(httpClient), option.WithoutAuthentication())
@@ -100,7 +108,7 @@ func (g *GCS) objectHandler(w http.ResponseWriter, r *http.Request) {
 
 // list handles a `list` request.
 // See: https://cloud.google.com/storage/docs/json_api/v1/#Objects, `list` method.
-// Only handles the 'prefix', 'startOffset' and 'delimiter' optional parameters.
+// Only handles the 'prefix', 'startOffset', 'endOffset' and 'delimiter' optional parameters.
 func (g *GCS) list(w http.ResponseWriter, r *http.Request, bucket string) {
 	if err := r.ParseForm(); err != nil {
 		log.Printf("failed to parse form %q: %v", r.URL.Path, err)
@@ -109,6 +117,7 @@ func (g *GCS) list(w http.ResponseWriter, r *http.Request, bucket string) {
 	bucketPrefix := fmt.Sprintf("/%s/", bucket)
 	prefix := bucketPrefix + r.Form.Get("prefix")
 	startOffset := bucketPrefix + r.Form.Get("startOffset")
+	endOffset := r.Form.Get("endOffset")
 	delimiter := r.Form.Get("delimiter")
 	var all gcsObjects
 	allPrefixes := make(map[string]bool)
@@ -116,6 +125,9 @@ func (g *GCS) list(w http.ResponseWriter, r *http.Request, bucket string) {
 		if k < startOffset {
 			continue
 		}
+		if endOffset != "" && k >= bucketPrefix+endOffset {
+			continue
+		}
 		if strings.HasPrefix(k, prefix) {
 			part := strings.TrimPrefix(k, prefix)
 			idx := strings.Index(part, delimiter)
@@ -152,6 +164,29 @@ func (g *GCS) del(w http.ResponseWriter, r *http.Request, bucket, objectPath str
 	delete(g.Objects, key)
 }
 
+// attrs handles a `get` request for object metadata.
+// See: https://cloud.google.com/storage/docs/json_api/v1/#Objects, `get` method.
+// Only the name, bucket and size of the object are returned.
+func (g *GCS) attrs(w http.ResponseWriter, r *http.Request, bucket, objectPath string) {
+	data, ok := g.Objects[fmt.Sprintf("/%s/%s", bucket, objectPath)]
+	if !ok {
+		writeError(w, r, http.StatusNotFound)
+		return
+	}
+	bytes, err := json.Marshal(map[string]string{
+		"name":   objectPath,
+		"bucket": bucket,
+		"size":   fmt.Sprint(len(data)),
+	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(bytes); err != nil {
+		log.Printf("write %q failed: %v", r.URL.Path, err)
+	}
+}
+
 func (g *GCS) bucketHandler(w http.ResponseWriter, r *http.Request) {
 	// Path looks like:
 	// - /storage/v1/b/<bucket>/o
@@ -170,6 +205,8 @@ func (g *GCS) bucketHandler(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case objectPath != "" && r.Method == "DELETE":
 		g.del(w, r, bucket, objectPath)
+	case objectPath != "" && r.Method == "GET":
+		g.attrs(w, r, bucket, objectPath)
 	case objectPath == "":
 		g.list(w, r, bucket)
 	default:
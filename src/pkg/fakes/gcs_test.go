@@ -52,6 +52,22 @@ func TestReadObjectNotFound(t *testing.T) {
 	}
 }
 
+func TestObjectAttrs(t *testing.T) {
+	gcs := GCSForTest(t)
+	defer gcs.Close()
+	gcs.Objects["/test-bucket/test-dir/test-object"] = []byte("data")
+	attrs, err := gcs.Client.Bucket("test-bucket").Object("test-dir/test-object").Attrs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Bucket != "test-bucket" || attrs.Name != "test-dir/test-object" || attrs.Size != 4 {
+		t.Errorf("bucket 'test-bucket', object 'test-dir/test-object' has attrs %+v; want name, bucket and size 4", attrs)
+	}
+	if _, err := gcs.Client.Bucket("test-bucket").Object("missing").Attrs(context.Background()); err != storage.ErrObjectNotExist {
+		t.Errorf("bucket 'test-bucket', object 'missing' has %v; want %s", err, storage.ErrObjectNotExist)
+	}
+}
+
 type Obj struct {
 	Bucket string
 	Name   string
@@ -64,6 +80,7 @@ func TestIterate(t *testing.T) {
 		prefix          string
 		delimiter       string
 		startOffset     string
+		endOffset       string
 		objects         map[string][]byte
 		bucket          string
 		expectedObjects []Obj
@@ -73,6 +90,7 @@ func TestIterate(t *testing.T) {
 			"",
 			"",
 			"",
+			"",
 			map[string][]byte{
 				"/test-bucket/obj-1": []byte(""),
 				"/test-bucket/obj-2": []byte(""),
@@ -91,6 +109,7 @@ func TestIterate(t *testing.T) {
 			"",
 			"",
 			"",
+			"",
 			make(map[string][]byte),
 			"test-bucket",
 			nil,
@@ -100,6 +119,7 @@ func TestIterate(t *testing.T) {
 			"pre",
 			"",
 			"",
+			"",
 			map[string][]byte{
 				"/test-bucket/pre-1": []byte(""),
 				"/test-bucket/pre-2": []byte(""),
@@ -116,6 +136,7 @@ func TestIterate(t *testing.T) {
 			"",
 			"/",
 			"pre-2",
+			"",
 			map[string][]byte{
 				"/test-bucket/pre-1/1": []byte(""),
 				"/test-bucket/pre-1/2": []byte(""),
@@ -131,6 +152,25 @@ func TestIterate(t *testing.T) {
 				{"test-bucket", "pre-4", ""},
 			},
 		},
+		{
+			"HasEndOffset",
+			"",
+			"/",
+			"pre-2",
+			"pre-4",
+			map[string][]byte{
+				"/test-bucket/pre-1/1": []byte(""),
+				"/test-bucket/pre-2":   []byte(""),
+				"/test-bucket/pre-3/1": []byte(""),
+				"/test-bucket/pre-3/2": []byte(""),
+				"/test-bucket/pre-4":   []byte(""),
+			},
+			"test-bucket",
+			[]Obj{
+				{"", "", "pre-3/"},
+				{"test-bucket", "pre-2", ""},
+			},
+		},
 	}
 	gcs := GCSForTest(t)
 	defer gcs.Close()
@@ -141,6 +181,7 @@ func TestIterate(t *testing.T) {
 				Delimiter:   input.delimiter,
 				Prefix:      input.prefix,
 				StartOffset: input.startOffset,
+				EndOffset:   input.endOffset,
 				Versions:    false,
 			}
 			it := gcs.Client.Bucket(input.bucket).Objects(context.Background(), q)
@@ -0,0 +1,169 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Interaction is a single recorded HTTP exchange.
+type Interaction struct {
+	Method      string
+	URL         string
+	StatusCode  int
+	ContentType string `json:",omitempty"`
+	Body        string
+}
+
+// Replay is an http.RoundTripper that records HTTP exchanges with live
+// services to a fixture file, or replays the exchanges stored in a fixture
+// file. It is intended to be constructed with NewReplayClient.
+//
+// Requests are matched by method and URL. If the same request is made several
+// times, the recorded responses are replayed in the order they were recorded.
+// Request headers are never recorded, so fixtures do not contain credentials.
+type Replay struct {
+	// Interactions are the recorded exchanges.
+	Interactions []*Interaction
+
+	fixture string
+	base    http.RoundTripper
+
+	mu   sync.Mutex
+	used map[*Interaction]bool
+}
+
+// NewReplayClient returns an http.Client for tests that depend on live
+// services such as Gerrit and Gitiles.
+//
+// If record is false, responses are replayed from fixture. The test fails if
+// fixture has not been recorded yet or cannot be read. Requests without a
+// recorded response fail.
+//
+// If record is true, requests are sent using base, and every exchange is
+// written to fixture when the test completes.
+func NewReplayClient(t testing.TB, fixture string, record bool, base *http.Client) *http.Client {
+	t.Helper()
+	r := &Replay{fixture: fixture, used: make(map[*Interaction]bool)}
+	if record {
+		if base == nil {
+			t.Fatalf("recording %s requires a base http.Client", fixture)
+		}
+		r.base = base.Transport
+		if r.base == nil {
+			r.base = http.DefaultTransport
+		}
+		t.Cleanup(func() {
+			if err := r.save(); err != nil {
+				t.Errorf("failed to write fixture %s: %v", fixture, err)
+			}
+		})
+		return &http.Client{Transport: r}
+	}
+	data, err := ioutil.ReadFile(fixture)
+	if os.IsNotExist(err) {
+		t.Fatalf("fixture %s has not been recorded, rerun the test with -record to create it", fixture)
+	}
+	if err != nil {
+		t.Fatalf("failed to read fixture %s, rerun the test in record mode to create it: %v", fixture, err)
+	}
+	if err := json.Unmarshal(data, &r.Interactions); err != nil {
+		t.Fatalf("failed to parse fixture %s: %v", fixture, err)
+	}
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replay) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.base != nil {
+		return r.record(req)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	url := req.URL.String()
+	for _, interaction := range r.Interactions {
+		if r.used[interaction] || interaction.Method != req.Method || interaction.URL != url {
+			continue
+		}
+		r.used[interaction] = true
+		return interaction.response(req), nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, url, r.fixture)
+}
+
+func (r *Replay) record(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Interactions = append(r.Interactions, &Interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(body),
+	})
+	return resp, nil
+}
+
+func (r *Replay) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.Interactions); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.fixture), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.fixture, buf.Bytes(), 0644)
+}
+
+func (i *Interaction) response(req *http.Request) *http.Response {
+	header := make(http.Header)
+	if i.ContentType != "" {
+		header.Set("Content-Type", i.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.StatusCode, http.StatusText(i.StatusCode)),
+		StatusCode:    i.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(i.Body))),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestReplayRecordAndReplay(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		count++
+		fmt.Fprintf(w, "%s %d", r.URL.Path, count)
+	}))
+	defer server.Close()
+	fixture := filepath.Join(t.TempDir(), "testdata", "fixture.json")
+
+	t.Run("record", func(t *testing.T) {
+		client := NewReplayClient(t, fixture, true, server.Client())
+		for _, path := range []string{"/a", "/a", "/b", "/missing"} {
+			get(t, client, server.URL+path)
+		}
+	})
+	server.Close()
+
+	client := NewReplayClient(t, fixture, false, nil)
+	tests := []struct {
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"/a", http.StatusOK, "/a 1"},
+		{"/b", http.StatusOK, "/b 3"},
+		{"/a", http.StatusOK, "/a 2"},
+		{"/missing", http.StatusNotFound, "404 page not found\n"},
+	}
+	for _, test := range tests {
+		code, body := get(t, client, server.URL+test.path)
+		if code != test.expectedCode || body != test.expectedBody {
+			t.Errorf("replay %s: got (%d, %q), want (%d, %q)", test.path, code, body, test.expectedCode, test.expectedBody)
+		}
+	}
+	if _, err := client.Get(server.URL + "/a"); err == nil {
+		t.Errorf("replay /a: expected error after recorded responses were used, got nil")
+	}
+	if _, err := client.Get(server.URL + "/c"); err == nil {
+		t.Errorf("replay /c: expected error for unrecorded request, got nil")
+	}
+}
+
+// fatalTB records the message of the first call to Fatalf, and stops the
+// goroutine calling it like testing.T.Fatalf.
+type fatalTB struct {
+	testing.TB
+	msg string
+}
+
+func (f *fatalTB) Helper() {}
+
+func (f *fatalTB) Fatalf(format string, args ...interface{}) {
+	f.msg = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func TestReplayMissingFixture(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "missing.json")
+	tb := &fatalTB{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewReplayClient(tb, fixture, false, nil)
+	}()
+	<-done
+	if !strings.Contains(tb.msg, "has not been recorded") {
+		t.Errorf("NewReplayClient(%s) failed with %q, want a missing fixture failure", fixture, tb.msg)
+	}
+}
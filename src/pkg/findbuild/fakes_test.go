@@ -0,0 +1,169 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"go.chromium.org/luci/common/proto/git"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	gitilesProto "go.chromium.org/luci/common/proto/gitiles"
+)
+
+// fakeGitilesClient is a fake Gitiles client that serves files from memory.
+// Calling any method that is not implemented panics.
+type fakeGitilesClient struct {
+	gitilesProto.GitilesClient
+	// manifests maps a build number to the contents of its manifest file.
+	manifests map[string]string
+	// commits maps a commit SHA to the commit. A log request on a SHA only
+	// returns the commit itself.
+	commits map[string]*git.Commit
+}
+
+func (c *fakeGitilesClient) DownloadFile(ctx context.Context, req *gitilesProto.DownloadFileRequest, opts ...grpc.CallOption) (*gitilesProto.DownloadFileResponse, error) {
+	contents, ok := c.manifests[req.Committish[len("refs/tags/"):]]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "not found")
+	}
+	return &gitilesProto.DownloadFileResponse{Contents: contents}, nil
+}
+
+func (c *fakeGitilesClient) Log(ctx context.Context, req *gitilesProto.LogRequest, opts ...grpc.CallOption) (*gitilesProto.LogResponse, error) {
+	commit, ok := c.commits[req.Committish]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "not found")
+	}
+	return &gitilesProto.LogResponse{Log: []*git.Commit{commit}}, nil
+}
+
+// fakeGerritClient is a fake GerritClient that serves changes and tags from
+// memory.
+type fakeGerritClient struct {
+	// changes maps a query, ex. "change:3781", to the changes it returns.
+	changes map[string][]gerrit.ChangeInfo
+	// tags maps a repository to its tags.
+	tags map[string][]gerrit.TagInfo
+	// pushCertificates maps a change number to the push certificates of its
+	// revisions.
+	pushCertificates map[string]map[string]PushCertificateInfo
+	// errCode, if set, is returned by every request as a Gerrit HTTP error
+	// with the given status code.
+	errCode int
+	// queries counts the change queries received.
+	queries int
+	// certRequests counts the push certificate requests received.
+	certRequests int
+}
+
+// newFakeGerritClient creates a fakeGerritClient populated with the changes
+// in testdata/changes.json, the push certificates in
+// testdata/push_certificates.json and the tags in testdata/tags.json. Each change
+// can be queried by its number or the SHA of any of its revisions. All tags are
+// served for the repository tagRepo.
+func newFakeGerritClient(t *testing.T, tagRepo string) *fakeGerritClient {
+	var changes []gerrit.ChangeInfo
+	readFixture(t, "testdata/changes.json", &changes)
+	var tags []gerrit.TagInfo
+	readFixture(t, "testdata/tags.json", &tags)
+	var pushCertificates map[string]map[string]PushCertificateInfo
+	readFixture(t, "testdata/push_certificates.json", &pushCertificates)
+	c := &fakeGerritClient{
+		changes:          make(map[string][]gerrit.ChangeInfo),
+		tags:             map[string][]gerrit.TagInfo{tagRepo: tags},
+		pushCertificates: pushCertificates,
+	}
+	for _, change := range changes {
+		c.changes[queryString(strconv.Itoa(change.Number))] = []gerrit.ChangeInfo{change}
+		c.changes[queryString(change.CurrentRevision)] = []gerrit.ChangeInfo{change}
+		for sha := range change.Revisions {
+			c.changes[queryString(sha)] = []gerrit.ChangeInfo{change}
+		}
+	}
+	return c
+}
+
+func readFixture(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("failed to parse fixture %s: %v", path, err)
+	}
+}
+
+func (c *fakeGerritClient) err() error {
+	if c.errCode == 0 {
+		return nil
+	}
+	// Mirror the error format of go-gerrit so that utils.GerritErrCode can
+	// extract the status code.
+	return fmt.Errorf("API call failed with status code %d", c.errCode)
+}
+
+func (c *fakeGerritClient) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, error) {
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	c.queries++
+	var all []gerrit.ChangeInfo
+	for _, query := range opt.Query {
+		all = append(all, c.changes[query]...)
+	}
+	// Mirror Gerrit's pagination: skip the first opt.Start changes, and mark
+	// the last change of a page if more changes match the query.
+	out := []gerrit.ChangeInfo{}
+	if opt.Start < len(all) {
+		out = append(out, all[opt.Start:]...)
+	}
+	if opt.Limit > 0 && len(out) > opt.Limit {
+		out = out[:opt.Limit]
+		out[len(out)-1].MoreChanges = true
+	}
+	return &out, nil
+}
+
+func (c *fakeGerritClient) ListTags(project string, opt *gerrit.ProjectBaseOptions) (*[]gerrit.TagInfo, error) {
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	tags, ok := c.tags[project]
+	if !ok {
+		return nil, fmt.Errorf("API call failed with status code 404")
+	}
+	return &tags, nil
+}
+
+func (c *fakeGerritClient) PushCertificates(changeID string) (map[string]PushCertificateInfo, error) {
+	c.certRequests++
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	if _, ok := c.changes[queryString(changeID)]; !ok {
+		return nil, fmt.Errorf("API call failed with status code 404")
+	}
+	return c.pushCertificates[changeID], nil
+}
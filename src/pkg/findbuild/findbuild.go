@@ -36,10 +36,12 @@ package findbuild
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -69,9 +71,31 @@ const (
 	// Max size of changelog if no changelog source is specified
 	noSourceChangelogSize = 10000
 
+	// Number of recent manifest commits used to estimate the build cadence
+	// of a release branch
+	cadenceSampleSize = 10
+
+	// Number of CLs searched for concurrently on each release branch by
+	// FindBuilds
+	findBuildsJobs = 5
+
 	shortSHALength = 7
 	fullSHALength  = 40
 
+	// bugQueryPrefix is the prefix of a CL identifier searching for the CLs
+	// referencing a bug, ex. "bug:123456"
+	bugQueryPrefix = "bug:"
+	// Maximum number of CLs retrieved for a bug
+	maxBugCLs = 50
+	// Number of changes requested from Gerrit at a time by queries that
+	// may match multiple changes.
+	queryPageSize = 10
+
+	// FallbackRepoPrefix is the prefix that the repositories of the
+	// fallback Gerrit instance, chromium-review.googlesource.com, are
+	// mirrored under in the COS manifest files.
+	FallbackRepoPrefix = "mirrors/cros/"
+
 	// Definitions of column names in table.
 	commitSha       = "commit_sha"
 	cLNumber        = "cl_number"
@@ -105,6 +129,12 @@ var (
 	}
 	// crosRepoRe is used to strip chromium prefixes from the repo name.
 	crosRepoRe = regexp.MustCompile("^(?:chromeos|chrome|chromiumos|chromium)?/(.*)")
+	// bugIDRe matches the numeric bug IDs accepted in bug queries.
+	bugIDRe = regexp.MustCompile(`^\d+$`)
+
+	// errInvalidRelease is returned by newIterCache if the release branch
+	// does not exist in the manifest repository.
+	errInvalidRelease = errors.New("release branch not found in manifest repository")
 )
 
 // BuildRequest is the input struct for the FindBuild function
@@ -123,7 +153,169 @@ type BuildRequest struct {
 	ManifestRepo string
 	// CL can be either the CL number or commit SHA of your target CL
 	// ex. 3741 or If9f774179322c413fa0fd5ebb3dd615c5b22cd6c
+	// It can also be a bug prefixed with "bug:", ex. bug:123456 or
+	// bug:b/123456, to search for the earliest submitted CL referencing the
+	// bug. If the CLs referencing the bug were submitted to different
+	// branches, an error wrapping utils.ErrMultipleCLsForBug is returned.
 	CL string
+	// Revision optionally selects the patchset of the CL to search for, as
+	// either a patchset number or a revision SHA.
+	// ex. 2 or 5d6a8e4b0c6c1e1e2e9e0a5f8e4b3c2d1e0f9a8b
+	// If empty, the current revision of the CL is used.
+	Revision string
+	// VerifySignature requests the signed push status of the CL revision,
+	// reported in BuildResponse.Verified. It requires an additional Gerrit
+	// request per CL, so it should only be set if the status is displayed.
+	VerifySignature bool
+	// RepoPrefix is the prefix that the CL's repository is mirrored under in
+	// the manifest files. It is typically set when querying a fallback Gerrit
+	// instance whose repositories are mirrored into the COS manifest.
+	// ex. "mirrors/cros/" maps "chromiumos/platform2" to
+	// "mirrors/cros/chromiumos/platform2"
+	// If empty, the repository is matched by name without a prefix.
+	// RepoPrefix is used for repositories that do not match any rule in
+	// RepoPrefixRules.
+	RepoPrefix string
+	// RepoPrefixRules maps CL repositories to the prefix they are mirrored
+	// under, for fallback instances that mirror repositories under different
+	// prefixes. The first matching rule is used.
+	RepoPrefixRules []RepoPrefixRule
+	// Cache is an optional cache of manifest commits and tags. Requests that
+	// share a Cache only retrieve manifest commits and tags once.
+	// If nil, nothing is cached.
+	Cache *Cache
+	// InitialSearchDays is the number of days after the CL's submission
+	// searched for its first build before expanding the search range.
+	// If zero, 5 days are searched.
+	InitialSearchDays int
+	// MaxSearchDays is the maximum number of days searched for the CL's
+	// first build. If the CL is not found within that range, the search
+	// fails instead of expanding further.
+	// If zero, the search range expands until no builds are left.
+	MaxSearchDays int
+}
+
+// initialSearchDays returns the number of days initially searched for the
+// first build of a CL.
+func (r *BuildRequest) initialSearchDays() int {
+	if r.InitialSearchDays > 0 {
+		return r.InitialSearchDays
+	}
+	return defaultSearchRange
+}
+
+// RepoPrefixRule maps repositories matching a pattern to the prefix they are
+// mirrored under in the manifest files.
+type RepoPrefixRule struct {
+	// Pattern is matched against the repository name of the CL,
+	// ex. "^chromiumos/"
+	Pattern *regexp.Regexp
+	// Prefix is the mirror prefix, ex. "mirrors/cros/"
+	Prefix string
+}
+
+// ParseRepoPrefixRules parses a comma separated list of pattern=prefix pairs
+// into a list of RepoPrefixRules, preserving their order.
+// ex. "^chromiumos/=mirrors/cros/,^aosp/=mirrors/aosp/"
+func ParseRepoPrefixRules(spec string) ([]RepoPrefixRule, error) {
+	var rules []RepoPrefixRule
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid repo prefix rule %q, expected pattern=prefix", pair)
+		}
+		pattern, err := regexp.Compile(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in repo prefix rule %q: %v", pair, err)
+		}
+		rules = append(rules, RepoPrefixRule{Pattern: pattern, Prefix: parts[1]})
+	}
+	return rules, nil
+}
+
+// repoPrefix returns the prefix that a repository is mirrored under.
+func (r *BuildRequest) repoPrefix(project string) string {
+	for _, rule := range r.RepoPrefixRules {
+		if rule.Pattern.MatchString(project) {
+			return rule.Prefix
+		}
+	}
+	return r.RepoPrefix
+}
+
+// Cache stores manifest commits and manifest repository tags so that they can
+// be reused across FindBuild requests. Failed retrievals are not cached.
+// A Cache is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// NewCache creates an empty Cache.
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]*cacheEntry)}
+}
+
+// get returns the value stored for key, calling fetch to retrieve it if it is
+// not cached. Concurrent calls with the same key only call fetch once.
+func (c *Cache) get(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return fetch()
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+	entry.once.Do(func() {
+		entry.value, entry.err = fetch()
+	})
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	return entry.value, entry.err
+}
+
+// manifestCommits retrieves all commits on a release branch of the manifest
+// repository.
+func (c *Cache) manifestCommits(client gitilesProto.GitilesClient, gitilesHost, manifestRepo, release string) ([]*git.Commit, error) {
+	key := strings.Join([]string{"commits", gitilesHost, manifestRepo, release}, "|")
+	commits, err := c.get(key, func() (interface{}, error) {
+		commits, _, err := utils.Commits(context.Background(), client, manifestRepo, "refs/heads/"+release, "", -1)
+		return commits, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return commits.([]*git.Commit), nil
+}
+
+// tags retrieves all tags belonging to the manifest repository.
+func (c *Cache) tags(client GerritClient, instanceURL, manifestRepo string) (map[string]string, error) {
+	key := strings.Join([]string{"tags", instanceURL, manifestRepo}, "|")
+	tags, err := c.get(key, func() (interface{}, error) {
+		return repoTags(client, manifestRepo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags.(map[string]string), nil
 }
 
 // iterCache contains information to perform an iteration of the
@@ -139,6 +331,21 @@ type iterCache struct {
 type BuildResponse struct {
 	BuildNum string
 	CLNum    string
+	// Repo is the Gerrit project of the CL.
+	Repo string
+	// Branch is the branch the CL was submitted to, and Release is the
+	// release branch of the manifest snapshots searched for the CL.
+	Branch    string
+	Release   string
+	Submitted time.Time
+	// Owner is the name of the CL owner, or their email address if the owner
+	// has no name.
+	Owner string
+	// Verified reports whether the CL revision was uploaded with a signed
+	// push whose signature and key are trusted by Gerrit. It is nil if the
+	// signature status is not available, ex. the Gerrit instance does not
+	// accept signed pushes, or if BuildRequest.VerifySignature is not set.
+	Verified *bool `json:",omitempty"`
 }
 
 type clData struct {
@@ -148,15 +355,22 @@ type clData struct {
 	Release          string
 	Branch           string
 	Revision         string
+	GerritProject    string
+	Owner            string
+	Verified         *bool
+	Submitted        time.Time
 	SearchStartRange time.Time
 	SearchEndRange   time.Time
 }
 
 type repoData struct {
 	Candidates map[string]string
-	SourceSHA  string
-	TargetSHA  string
-	RemoteURL  string
+	// Builds maps each candidate build number to the SHA of the CL's
+	// repository used in the build.
+	Builds    map[string]string
+	SourceSHA string
+	TargetSHA string
+	RemoteURL string
 }
 
 type manifestResponse struct {
@@ -168,57 +382,210 @@ type manifestResponse struct {
 }
 
 func queryString(clID string) string {
+	if bug, ok := bugID(clID); ok {
+		return fmt.Sprintf("bug:%s status:merged", bug)
+	}
 	if len(clID) == fullSHALength {
 		return fmt.Sprintf("commit:%s", clID)
 	}
 	return fmt.Sprintf("change:%s", clID)
 }
 
-// queryCL retrieves the list of CLs matching a query from Gerrit
-func queryCL(client *gerrit.Client, clID, instanceURL string) (gerrit.ChangeInfo, utils.ChangelogError) {
+// queryLimit returns the maximum number of changes retrieved for a CL
+// identifier. CL numbers and commit SHAs identify a single change, while
+// queries such as bug queries may match several changes.
+func queryLimit(clID string) int {
+	if _, ok := bugID(clID); ok {
+		return maxBugCLs
+	}
+	return 1
+}
+
+// queryChanges retrieves up to limit changes matching opt from Gerrit. The
+// changes are requested in pages of at most queryPageSize changes until
+// limit changes are retrieved or Gerrit reports no more matching changes.
+func queryChanges(client GerritClient, opt *gerrit.QueryChangeOptions, limit int) ([]gerrit.ChangeInfo, error) {
+	var changes []gerrit.ChangeInfo
+	for len(changes) < limit {
+		opt.Start = len(changes)
+		opt.Limit = limit - len(changes)
+		if opt.Limit > queryPageSize {
+			opt.Limit = queryPageSize
+		}
+		page, err := client.QueryChanges(opt)
+		if err != nil {
+			return nil, err
+		}
+		if page == nil || len(*page) == 0 {
+			break
+		}
+		changes = append(changes, *page...)
+		// Gerrit sets _more_changes on the last change of a page if more
+		// changes match the query.
+		last := &changes[len(changes)-1]
+		if !last.MoreChanges {
+			break
+		}
+		last.MoreChanges = false
+	}
+	return changes, nil
+}
+
+// bugID returns the bug searched for by a CL identifier prefixed with "bug:",
+// without the optional "b/" prefix. The bool is false if clID is not a bug
+// query or if the bug ID is not numeric, so that the CL identifier cannot
+// inject other search operators into the Gerrit query.
+func bugID(clID string) (string, bool) {
+	if !strings.HasPrefix(clID, bugQueryPrefix) {
+		return "", false
+	}
+	bug := strings.TrimPrefix(strings.TrimPrefix(clID, bugQueryPrefix), "b/")
+	return bug, bugIDRe.MatchString(bug)
+}
+
+// bugChange selects the change to search for among the changes referencing a
+// bug. It returns the earliest submitted change if all of them were submitted
+// to the same branch, and an error listing them otherwise.
+func bugChange(bug string, changes []gerrit.ChangeInfo, instanceURL string) (gerrit.ChangeInfo, utils.ChangelogError) {
+	var earliest *gerrit.ChangeInfo
+	clIDs := make([]string, len(changes))
+	branches := make(map[string]bool)
+	for i := range changes {
+		change := &changes[i]
+		clIDs[i] = strconv.Itoa(change.Number)
+		branches[change.Project+"/"+change.Branch] = true
+		if change.Submitted == nil {
+			continue
+		}
+		if earliest == nil || change.Submitted.Time.Before(earliest.Submitted.Time) {
+			earliest = change
+		}
+	}
+	if len(branches) > 1 {
+		sort.Strings(clIDs)
+		log.Debugf("Bug %s is referenced by CLs %v on %d branches", bug, clIDs, len(branches))
+		return gerrit.ChangeInfo{}, utils.MultipleCLsForBug(bug, clIDs, instanceURL)
+	}
+	if earliest == nil {
+		return changes[0], nil
+	}
+	log.Debugf("Selected CL %d, the earliest submitted of CLs %v referencing bug %s", earliest.Number, clIDs, bug)
+	return *earliest, nil
+}
+
+// queryCL retrieves the list of CLs matching a query from Gerrit. It returns
+// the matching change and the SHA of the requested revision, or the current
+// revision if no revision is requested.
+//
+// If clID is a bug prefixed with "bug:", the earliest submitted CL referencing
+// the bug is returned.
+func queryCL(client GerritClient, clID, revision, instanceURL string) (gerrit.ChangeInfo, string, utils.ChangelogError) {
 	log.Debugf("Retrieving CL List from Gerrit for clID: %q", clID)
+	bug, isBug := bugID(clID)
+	if !isBug && strings.HasPrefix(clID, bugQueryPrefix) {
+		log.Errorf("queryCL: Invalid bug ID in CL identifier %q", clID)
+		return gerrit.ChangeInfo{}, "", utils.CLNotFound(clID)
+	}
 	query := queryString(clID)
 	queryOptions := &gerrit.QueryChangeOptions{}
 	queryOptions.Query = []string{query}
-	queryOptions.AdditionalFields = []string{"CURRENT_REVISION"}
-	queryOptions.Limit = 1
+	queryOptions.AdditionalFields = []string{"CURRENT_REVISION", "DETAILED_ACCOUNTS"}
+	if revision != "" {
+		queryOptions.AdditionalFields = []string{"ALL_REVISIONS", "DETAILED_ACCOUNTS"}
+	}
 
-	clList, _, err := client.Changes.QueryChanges(queryOptions)
+	clList, err := queryChanges(client, queryOptions, queryLimit(clID))
 	if err != nil {
 		log.Errorf("queryCL: Error retrieving change for input %s:\n%v", clID, err)
+		if rateLimitErr := gerritRateLimitError(err); rateLimitErr != nil {
+			return gerrit.ChangeInfo{}, "", rateLimitErr
+		}
 		httpCode := utils.GerritErrCode(err)
 		if httpCode == "403" {
-			return gerrit.ChangeInfo{}, utils.ForbiddenError
+			return gerrit.ChangeInfo{}, "", utils.ForbiddenError
 		} else if httpCode == "400" || httpCode == "404" {
-			return gerrit.ChangeInfo{}, utils.CLNotFound(clID)
+			return gerrit.ChangeInfo{}, "", utils.CLNotFound(clID)
 		}
-		return gerrit.ChangeInfo{}, utils.InternalServerError
+		return gerrit.ChangeInfo{}, "", utils.InternalServerError
 	}
-	if len(*clList) == 0 {
+	if len(clList) == 0 {
 		log.Errorf("queryCL: CL with identifier %s not found", clID)
-		return gerrit.ChangeInfo{}, utils.CLNotFound(clID)
+		return gerrit.ChangeInfo{}, "", utils.CLNotFound(clID)
+	}
+	change := clList[0]
+	if isBug {
+		var utilErr utils.ChangelogError
+		if change, utilErr = bugChange(bug, clList, instanceURL); utilErr != nil {
+			return gerrit.ChangeInfo{}, "", utilErr
+		}
 	}
-	change := (*clList)[0]
 	log.Debugf("Found CL: %+v", change)
 	if change.Submitted == nil {
 		log.Debugf("Provided CL identifier %s maps to an unsubmitted CL", clID)
-		return gerrit.ChangeInfo{}, utils.CLNotSubmitted(strconv.Itoa(change.Number), instanceURL)
+		return gerrit.ChangeInfo{}, "", utils.CLNotSubmitted(strconv.Itoa(change.Number), instanceURL)
 	}
-	return change, nil
+	if revision == "" {
+		return change, change.CurrentRevision, nil
+	}
+	sha, ok := changeRevision(change, revision)
+	if !ok {
+		log.Debugf("Revision %s not found on CL %d", revision, change.Number)
+		return gerrit.ChangeInfo{}, "", utils.RevisionNotFound(strconv.Itoa(change.Number), revision, instanceURL)
+	}
+	return change, sha, nil
 }
 
-func getCLData(clID, instanceURL string, httpClient *http.Client) (*clData, utils.ChangelogError) {
-	log.Debugf("Retrieving CL data from Gerrit for changeID: %s", clID)
-	gerritClient, clientErr := gerrit.NewClient(instanceURL, httpClient)
-	if clientErr != nil {
-		log.Errorf("failed to establish Gerrit client for host %s:\n%v", instanceURL, clientErr)
-		return nil, utils.InternalServerError
+// changeRevision returns the SHA of the revision of a change matching either
+// a patchset number or a revision SHA.
+func changeRevision(change gerrit.ChangeInfo, revision string) (string, bool) {
+	for sha, info := range change.Revisions {
+		if sha == revision || strconv.Itoa(info.Number) == revision {
+			return sha, true
+		}
+	}
+	return "", false
+}
+
+// manifestProject returns the name used to locate a CL's repository in
+// manifest files. If repoPrefix is set, the repository is expected to be
+// mirrored under that prefix. Otherwise chromium prefixes are stripped so the
+// repository matches any manifest project with the same name.
+func manifestProject(project, repoPrefix string) string {
+	if repoPrefix != "" {
+		return strings.TrimSuffix(repoPrefix, "/") + "/" + project
+	}
+	if matches := crosRepoRe.FindStringSubmatch(project); matches != nil {
+		return matches[1]
 	}
-	change, err := queryCL(gerritClient, clID, instanceURL)
+	return project
+}
+
+// signatureVerified reports whether a revision of a change was uploaded with
+// a signed push certificate that Gerrit fully trusts. It returns nil if the
+// signature status is unknown: the revision has no push certificate or the
+// certificates could not be retrieved.
+func signatureVerified(client GerritClient, change gerrit.ChangeInfo, revision string) *bool {
+	certs, err := client.PushCertificates(strconv.Itoa(change.Number))
+	if err != nil {
+		log.Warnf("failed to retrieve push certificates of CL %d: %v", change.Number, err)
+		return nil
+	}
+	cert, ok := certs[revision]
+	if !ok {
+		return nil
+	}
+	verified := cert.Key.Status == "TRUSTED" && len(cert.Key.Problems) == 0
+	return &verified
+}
+
+func getCLData(gerritClient GerritClient, request *BuildRequest) (*clData, utils.ChangelogError) {
+	clID, instanceURL := request.CL, request.GerritHost
+	log.Debugf("Retrieving CL data from Gerrit for changeID: %s", clID)
+	change, revision, err := queryCL(gerritClient, clID, request.Revision, instanceURL)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("Target CL found with SHA %s on repo %s, branch %s", change.CurrentRevision, change.Project, change.Branch)
+	log.Debugf("Target CL found with SHA %s on repo %s, branch %s", revision, change.Project, change.Branch)
 	// If a repository has non-conventional branch names, need to convert the
 	// repository branch name to a release branch name
 	release := change.Branch
@@ -234,21 +601,29 @@ func getCLData(clID, instanceURL string, httpClient *http.Client) (*clData, util
 	if change.Branch == "main" {
 		release = "master"
 	}
-	// Strip chromium prefixes
-	project := change.Project
-	if matches := crosRepoRe.FindStringSubmatch(project); matches != nil {
-		project = matches[1]
-	}
+	project := manifestProject(change.Project, request.repoPrefix(change.Project))
 	submittedTime := *change.Submitted
+	owner := change.Owner.Name
+	if owner == "" {
+		owner = change.Owner.Email
+	}
+	var verified *bool
+	if request.VerifySignature {
+		verified = signatureVerified(gerritClient, change, revision)
+	}
 	return &clData{
 		CLNum:            strconv.Itoa(change.Number),
 		InstanceURL:      instanceURL,
 		Project:          project,
 		Release:          release,
 		Branch:           change.Branch,
-		Revision:         change.CurrentRevision,
+		Revision:         revision,
+		GerritProject:    change.Project,
+		Owner:            owner,
+		Verified:         verified,
+		Submitted:        submittedTime.Time,
 		SearchStartRange: submittedTime.Time,
-		SearchEndRange:   submittedTime.Time.AddDate(0, 0, defaultSearchRange),
+		SearchEndRange:   submittedTime.Time.AddDate(0, 0, request.initialSearchDays()),
 	}, nil
 }
 
@@ -261,7 +636,7 @@ func getCLData(clID, instanceURL string, httpClient *http.Client) (*clData, util
 func candidateManifestCommits(manifestCommits []*git.Commit, clData *clData) ([]*git.Commit, bool, utils.ChangelogError) {
 	log.Debugf("Retrieving all manifest snapshots committed within %v to %v", clData.SearchStartRange, clData.SearchEndRange)
 	if manifestCommits[0].Committer.Time.AsTime().Before(clData.SearchStartRange) {
-		return nil, false, utils.CLTooRecent(clData.CLNum, clData.InstanceURL)
+		return nil, false, tooRecentError(manifestCommits, clData, time.Now())
 	}
 	// Find latest commit that occurs before the target commit time.
 	// allManifests is in reverse chronological order.
@@ -300,10 +675,43 @@ func candidateManifestCommits(manifestCommits []*git.Commit, clData *clData) ([]
 	return manifestCommits[latestIdx : earliestIdx+1], latestIdx != 0, nil
 }
 
+// buildCadence estimates the typical time between builds on a release branch
+// using the median time between its most recent manifest commits.
+// manifestCommits must be in reverse chronological order.
+// Returns 0 if the cadence cannot be estimated.
+func buildCadence(manifestCommits []*git.Commit) time.Duration {
+	var intervals []time.Duration
+	for i := 0; i+1 < len(manifestCommits) && len(intervals) < cadenceSampleSize; i++ {
+		newer, older := manifestCommits[i].Committer, manifestCommits[i+1].Committer
+		if newer == nil || older == nil {
+			continue
+		}
+		intervals = append(intervals, newer.Time.AsTime().Sub(older.Time.AsTime()))
+	}
+	if len(intervals) == 0 {
+		return 0
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	return intervals[len(intervals)/2]
+}
+
+// tooRecentError returns an error for a CL that was submitted after the
+// latest build on its release branch, including an estimate of when the next
+// build will be created if one can be made.
+func tooRecentError(manifestCommits []*git.Commit, clData *clData, now time.Time) utils.ChangelogError {
+	cadence := buildCadence(manifestCommits)
+	if cadence <= 0 {
+		return utils.CLTooRecent(clData.CLNum, clData.InstanceURL)
+	}
+	nextBuild := manifestCommits[0].Committer.Time.AsTime().Add(cadence)
+	log.Debugf("CL %s submitted after latest build, next build expected at %v", clData.CLNum, nextBuild)
+	return utils.CLTooRecentWithETA(clData.CLNum, clData.InstanceURL, clData.Submitted, cadence, nextBuild.Sub(now))
+}
+
 // repoTags retrieves all tags belonging to a repository
-func repoTags(client *gerrit.Client, repo string) (map[string]string, error) {
+func repoTags(client GerritClient, repo string) (map[string]string, error) {
 	log.Debugf("Retrieving tags for repository %s", repo)
-	tagInfos, _, err := client.Projects.ListTags(repo, &gerrit.ProjectBaseOptions{})
+	tagInfos, err := client.ListTags(repo, &gerrit.ProjectBaseOptions{})
 	if err != nil {
 		log.Errorf("error retrieving tags:\n%v", err)
 		return nil, err
@@ -418,7 +826,7 @@ func getRepoData(client gitilesProto.GitilesClient, manifestRepo string, clData
 		buildOrder[buildNum] = i * -1
 	}
 
-	output := repoData{Candidates: map[string]string{}}
+	output := repoData{Candidates: map[string]string{}, Builds: map[string]string{}}
 	shaChan := make(chan manifestResponse, len(buildNums))
 	var wg sync.WaitGroup
 	wg.Add(len(buildNums))
@@ -451,6 +859,7 @@ func getRepoData(client gitilesProto.GitilesClient, manifestRepo string, clData
 		if storedBuild, ok := output.Candidates[curr.SHA]; !ok || buildOrder[curr.BuildNum] < buildOrder[storedBuild] {
 			output.Candidates[curr.SHA] = curr.BuildNum
 		}
+		output.Builds[curr.BuildNum] = curr.SHA
 	}
 	if len(output.Candidates) == 0 {
 		log.Debugf("getRepoData: No builds found for CL %s", clData.CLNum)
@@ -463,6 +872,22 @@ func getRepoData(client gitilesProto.GitilesClient, manifestRepo string, clData
 // of candidate builds.
 func firstBuild(changelog []*git.Commit, clData *clData, candidates map[string]string) (string, utils.ChangelogError) {
 	log.Debug("Scanning changelog for first build")
+	targetIdx, utilErr := landingIndex(changelog, clData)
+	if utilErr != nil {
+		return "", utilErr
+	}
+	for i := targetIdx; i >= 0; i-- {
+		currSHA := changelog[i].Id
+		if buildNum, ok := candidates[currSHA]; ok {
+			return buildNum, nil
+		}
+	}
+	return "", utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
+}
+
+// landingIndex returns the index of the target CL in a changelog in reverse
+// chronological order.
+func landingIndex(changelog []*git.Commit, clData *clData) (int, utils.ChangelogError) {
 	targetIdx := -1
 	for i, commit := range changelog {
 		if commit.Id == clData.Revision {
@@ -470,39 +895,71 @@ func firstBuild(changelog []*git.Commit, clData *clData, candidates map[string]s
 		}
 	}
 	if targetIdx == -1 {
-		return "", utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
+		return -1, utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
 	}
-	for i := targetIdx; i >= 0; i-- {
-		currSHA := changelog[i].Id
-		if buildNum, ok := candidates[currSHA]; ok {
-			return buildNum, nil
+	return targetIdx, nil
+}
+
+// buildsContaining retrieves all builds containing the target CL, in
+// chronological order. buildNums lists the candidate builds in reverse
+// chronological order, and builds maps each of them to the SHA of the CL's
+// repository used in the build.
+func buildsContaining(changelog []*git.Commit, clData *clData, buildNums []string, builds map[string]string) ([]string, utils.ChangelogError) {
+	log.Debug("Scanning changelog for all builds")
+	targetIdx, utilErr := landingIndex(changelog, clData)
+	if utilErr != nil {
+		return nil, utilErr
+	}
+	// Commits newer than the CL in the changelog, including the CL itself.
+	descendants := make(map[string]bool, targetIdx+1)
+	for _, commit := range changelog[:targetIdx+1] {
+		descendants[commit.Id] = true
+	}
+	var output []string
+	for i := len(buildNums) - 1; i >= 0; i-- {
+		if sha, ok := builds[buildNums[i]]; ok && descendants[sha] {
+			output = append(output, buildNums[i])
 		}
 	}
-	return "", utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
+	if len(output) == 0 {
+		return nil, utils.CLLandingNotFound(clData.CLNum, clData.InstanceURL)
+	}
+	return output, nil
 }
 
-// findBuildInRange searches for the first build containing a given CL in
-// Git on Borg within the specified start and end time range.
+// rangeSearch holds the data retrieved when searching a time range for the
+// builds containing a CL.
+type rangeSearch struct {
+	// BuildNums lists the builds within the time range, in reverse
+	// chronological order.
+	BuildNums []string
+	RepoData  *repoData
+	// Changelog lists the commits of the CL's repository between the
+	// earliest and latest build in the time range.
+	Changelog []*git.Commit
+}
+
+// searchRange retrieves the builds within the specified start and end time
+// range, and the changelog of the CL's repository between them.
 //
-// Returns the build number if found, a bool indicating if the search range
-// can be further expanded, and an error.
-func findBuildInRange(request *BuildRequest, cache *iterCache, clData *clData) (string, bool, utils.ChangelogError) {
-	log.Debugf("Searching for first build containing CL from time %v to time %v", clData.SearchStartRange, clData.SearchEndRange)
+// Returns the retrieved data, a bool indicating if the search range can be
+// further expanded, and an error.
+func searchRange(request *BuildRequest, cache *iterCache, clData *clData) (*rangeSearch, bool, utils.ChangelogError) {
 	var err error
 	manifestCommits, canExpand, utilErr := candidateManifestCommits(cache.ManifestCommits, clData)
 	if utilErr != nil {
-		return "", canExpand, utilErr
+		return nil, canExpand, utilErr
 	}
 	buildNums, utilErr := candidateBuildNums(manifestCommits, cache.Tags)
-	if err != nil {
-		return "", canExpand, utilErr
+	if utilErr != nil {
+		return nil, canExpand, utilErr
 	}
 	repoData, utilErr := getRepoData(cache.GitilesClient, request.ManifestRepo, clData, buildNums)
 	if utilErr != nil {
-		return "", canExpand, utilErr
+		return nil, canExpand, utilErr
 	}
 	if repoData.TargetSHA == "" {
-		return "", canExpand, utils.CLLandingNotFound(clData.CLNum, request.GerritHost)
+		return nil, canExpand, utils.CLLandingNotFound(clData.CLNum, request.GerritHost)
 	}
 	changelogClient := cache.GitilesClient
 	if repoData.RemoteURL != request.GitilesHost {
@@ -510,113 +967,510 @@ func findBuildInRange(request *BuildRequest, cache *iterCache, clData *clData) (
 		changelogClient, err = gitilesApi.NewRESTClient(request.HTTPClient, repoData.RemoteURL, true)
 		if err != nil {
 			log.Errorf("failed to establish Gitiles client for remote URL %s", repoData.RemoteURL)
-			return "", false, utils.InternalServerError
+			return nil, false, utils.InternalServerError
 		}
 	}
 	querySize := -1
 	if repoData.SourceSHA == "" {
 		querySize = noSourceChangelogSize
 	}
-	changelog, _, err := utils.Commits(changelogClient, clData.Project, repoData.TargetSHA, repoData.SourceSHA, querySize)
+	changelog, _, err := utils.Commits(context.Background(), changelogClient, clData.Project, repoData.TargetSHA, repoData.SourceSHA, querySize)
 	if err != nil {
 		log.Errorf("failed to retrieve changelog: %v", err)
 		if utils.GitilesErrCode(err) == "404" {
-			return "", canExpand, utils.CLNotUsed(clData.CLNum, clData.Project, clData.Release, clData.InstanceURL)
+			return nil, canExpand, utils.CLNotUsed(clData.CLNum, clData.Project, clData.Release, clData.InstanceURL)
 		}
-		return "", canExpand, utils.InternalServerError
+		return nil, canExpand, utils.InternalServerError
 	}
-	buildNum, utilErr := firstBuild(changelog, clData, repoData.Candidates)
+	return &rangeSearch{BuildNums: buildNums, RepoData: repoData, Changelog: changelog}, canExpand, nil
+}
+
+// findBuildInRange searches for the first build containing a given CL in
+// Git on Borg within the specified start and end time range.
+//
+// Returns the build number if found, a bool indicating if the search range
+// can be further expanded, and an error.
+func findBuildInRange(request *BuildRequest, cache *iterCache, clData *clData) (string, bool, utils.ChangelogError) {
+	log.Debugf("Searching for first build containing CL from time %v to time %v", clData.SearchStartRange, clData.SearchEndRange)
+	search, canExpand, utilErr := searchRange(request, cache, clData)
+	if utilErr != nil {
+		return "", canExpand, utilErr
+	}
+	buildNum, utilErr := firstBuild(search.Changelog, clData, search.RepoData.Candidates)
 	if utilErr != nil {
 		return "", canExpand, utilErr
 	}
 	return buildNum, canExpand, nil
 }
 
-// findBuildExponential searches for the first build containing a CL in an
-// exponentially increasing time range.
-func findBuildExponential(gitilesClient gitiles.GitilesClient, request *BuildRequest, clData *clData) (string, utils.ChangelogError) {
-	log.Debug("Searching for first build in exponentially increasing time range")
-	timeRange := defaultSearchRange
-
-	// Manifest commits and tags only need to be retrieved once and can be
-	// reused for each iteration.
-	manifestCommits, _, err := utils.Commits(gitilesClient, request.ManifestRepo, "refs/heads/"+clData.Release, "", -1)
-	if err != nil {
-		log.Errorf("error retrieving manifest commits within CL submission range: %v", err)
-		httpCode := utils.GitilesErrCode(err)
-		if httpCode == "404" {
-			return "", utils.CLInvalidRelease(clData.CLNum, clData.Release, clData.InstanceURL)
-		}
-		return "", utils.InternalServerError
+// findBuildRangeInRange searches for all builds containing a given CL within
+// the specified start and end time range, like findBuildInRange.
+func findBuildRangeInRange(request *BuildRequest, cache *iterCache, clData *clData) ([]string, bool, utils.ChangelogError) {
+	log.Debugf("Searching for all builds containing CL from time %v to time %v", clData.SearchStartRange, clData.SearchEndRange)
+	search, canExpand, utilErr := searchRange(request, cache, clData)
+	if utilErr != nil {
+		return nil, canExpand, utilErr
 	}
-	if manifestCommits[len(manifestCommits)-1].Committer.Time.AsTime().After(clData.SearchEndRange) {
-		clData.SearchStartRange = manifestCommits[len(manifestCommits)-1].Committer.Time.AsTime().Add(-time.Second)
-		clData.SearchEndRange = clData.SearchStartRange.AddDate(0, 0, defaultSearchRange)
-		log.Debugf("CL submitted earlier than first build, set search range to starting time from %v to %v", clData.SearchStartRange, clData.SearchEndRange)
+	buildNums, utilErr := buildsContaining(search.Changelog, clData, search.BuildNums, search.RepoData.Builds)
+	if utilErr != nil {
+		return nil, canExpand, utilErr
 	}
-	// Creating a Gerrit client based on manifest-snapshot repository.
-	// The client will be used for finding information associated with
-	// an annotated git tag.
+	return buildNums, canExpand, nil
+}
+
+// manifestGerritClient creates a Gerrit client for the instance hosting the
+// manifest repository. The client is used for finding information associated
+// with an annotated git tag.
+func manifestGerritClient(request *BuildRequest) (GerritClient, string, utils.ChangelogError) {
 	instanceURL, err := utils.CreateGerritURL(request.GitilesHost)
 	if err != nil {
 		log.Errorf("failed to create Gerrit URL from Gitiles Host %q: %v", request.GitilesHost, err)
-		return "", utils.InternalServerError
+		return nil, "", utils.InternalServerError
 	}
-	gerritClient, err := gerrit.NewClient(instanceURL, request.HTTPClient)
+	gerritClient, err := NewGerritClient(instanceURL, request.HTTPClient)
 	if err != nil {
 		log.Errorf("failed to establish Gerrit client for host %s:\n%v", instanceURL, err)
-		return "", utils.InternalServerError
+		return nil, "", utils.InternalServerError
+	}
+	return gerritClient, instanceURL, nil
+}
+
+// findBuildExponential searches for the first build containing a CL in an
+// exponentially increasing time range.
+func findBuildExponential(gitilesClient gitiles.GitilesClient, request *BuildRequest, clData *clData) (string, utils.ChangelogError) {
+	cache, err := newIterCache(gitilesClient, request, clData.Release)
+	if err != nil {
+		return "", iterCacheError(err, clData)
+	}
+	return findBuildWithCache(request, cache, clData)
+}
+
+// newIterCache retrieves the manifest commits of a release branch and the
+// manifest tags. They only need to be retrieved once and can be reused for
+// each iteration of the search for any CL on the release branch.
+func newIterCache(gitilesClient gitiles.GitilesClient, request *BuildRequest, release string) (*iterCache, error) {
+	manifestCommits, err := request.Cache.manifestCommits(gitilesClient, request.GitilesHost, request.ManifestRepo, release)
+	if err != nil {
+		log.Errorf("error retrieving manifest commits within CL submission range: %v", err)
+		if utils.GitilesErrCode(err) == "404" {
+			return nil, fmt.Errorf("%w: %v", errInvalidRelease, err)
+		}
+		return nil, err
+	}
+	gerritClient, instanceURL, utilErr := manifestGerritClient(request)
+	if utilErr != nil {
+		return nil, utilErr
 	}
-	tagResp, err := repoTags(gerritClient, request.ManifestRepo)
+	tagResp, err := request.Cache.tags(gerritClient, instanceURL, request.ManifestRepo)
 	if err != nil {
 		log.Errorf("failed to retrieve tags for project %s:\n%v", request.ManifestRepo, err)
-		return "", utils.InternalServerError
+		return nil, err
 	}
-	cache := &iterCache{
+	return &iterCache{
 		GitilesClient:   gitilesClient,
 		Tags:            tagResp,
 		ManifestCommits: manifestCommits,
+	}, nil
+}
+
+// iterCacheError converts an error returned by newIterCache to the error
+// reported for a CL.
+func iterCacheError(err error, clData *clData) utils.ChangelogError {
+	var utilErr utils.ChangelogError
+	switch {
+	case errors.Is(err, errInvalidRelease):
+		return utils.CLInvalidRelease(clData.CLNum, clData.Release, clData.InstanceURL)
+	case errors.As(err, &utilErr):
+		return utilErr
+	default:
+		return utils.InternalServerError
 	}
+}
 
-	res, canExpand, utilErr := findBuildInRange(request, cache, clData)
+// findBuildWithCache searches for the first build containing a CL in an
+// exponentially increasing time range, using the manifest commits and tags of
+// the CL's release branch in cache.
+func findBuildWithCache(request *BuildRequest, cache *iterCache, clData *clData) (string, utils.ChangelogError) {
+	log.Debug("Searching for first build in exponentially increasing time range")
+	var res string
+	utilErr := searchExponential(request, cache, clData, func() (bool, utils.ChangelogError) {
+		var canExpand bool
+		var utilErr utils.ChangelogError
+		res, canExpand, utilErr = findBuildInRange(request, cache, clData)
+		return canExpand, utilErr
+	})
+	return res, utilErr
+}
+
+// searchExponential calls search with an exponentially increasing time range
+// set in clData, until it succeeds, fails with an error that isn't retryable,
+// or the time range can't be expanded. search returns whether the time range
+// can be expanded.
+//
+// The time range starts with request.InitialSearchDays, and is not expanded
+// past request.MaxSearchDays.
+func searchExponential(request *BuildRequest, cache *iterCache, clData *clData, search func() (bool, utils.ChangelogError)) utils.ChangelogError {
+	initialRange := request.initialSearchDays()
+	timeRange := initialRange
+	manifestCommits := cache.ManifestCommits
+	if manifestCommits[len(manifestCommits)-1].Committer.Time.AsTime().After(clData.SearchEndRange) {
+		clData.SearchStartRange = manifestCommits[len(manifestCommits)-1].Committer.Time.AsTime().Add(-time.Second)
+		clData.SearchEndRange = clData.SearchStartRange.AddDate(0, 0, initialRange)
+		log.Debugf("CL submitted earlier than first build, set search range to starting time from %v to %v", clData.SearchStartRange, clData.SearchEndRange)
+	}
+	var searchLimit time.Time
+	if request.MaxSearchDays > 0 {
+		searchLimit = clData.SearchStartRange.AddDate(0, 0, request.MaxSearchDays)
+		if clData.SearchEndRange.After(searchLimit) {
+			clData.SearchEndRange = searchLimit
+		}
+	}
+
+	canExpand, utilErr := search()
 	for utilErr != nil && utilErr.Retryable() && canExpand {
+		if !searchLimit.IsZero() && !clData.SearchEndRange.Before(searchLimit) {
+			log.Debugf("Could not locate CL within %d days, not expanding search range", request.MaxSearchDays)
+			return utils.CLSearchRangeExceeded(clData.CLNum, clData.InstanceURL, request.MaxSearchDays)
+		}
 		timeRange *= searchRangeMultiplier
-		clData.SearchStartRange = clData.SearchEndRange.AddDate(0, 0, -defaultSearchRange)
+		clData.SearchStartRange = clData.SearchEndRange.AddDate(0, 0, -initialRange)
 		clData.SearchEndRange = clData.SearchEndRange.AddDate(0, 0, timeRange)
+		if !searchLimit.IsZero() && clData.SearchEndRange.After(searchLimit) {
+			clData.SearchEndRange = searchLimit
+		}
 		log.Debugf("Could not locate CL in current time range, retrying with range %v to %v", clData.SearchStartRange, clData.SearchEndRange)
-		res, canExpand, utilErr = findBuildInRange(request, cache, clData)
+		canExpand, utilErr = search()
 	}
-	return res, utilErr
+	return utilErr
 }
 
 // FindBuild locates the first build that a CL was introduced to.
 func FindBuild(request *BuildRequest) (*BuildResponse, utils.ChangelogError) {
+	if request == nil {
+		log.Error("expected non-nil request")
+		return nil, utils.InternalServerError
+	}
 	log.Debugf("Fetching first build for CL: %s", request.CL)
 	start := time.Now()
+	gitilesClient, clData, clErr := requestCLData(request)
+	if clErr != nil {
+		return nil, clErr
+	}
+	buildNum, clErr := findBuildExponential(gitilesClient, request, clData)
+	if clErr != nil {
+		return nil, clErr
+	}
+	log.Debugf("Retrieved first build for CL: %s in %s\n", request.CL, time.Since(start))
+	return buildResponse(buildNum, clData), nil
+}
+
+// FindBuildRange locates all builds containing a CL, from the first build
+// that the CL was introduced to until the end of the time range in which that
+// build was found. Builds are returned in chronological order.
+func FindBuildRange(request *BuildRequest) ([]BuildResponse, utils.ChangelogError) {
 	if request == nil {
 		log.Error("expected non-nil request")
 		return nil, utils.InternalServerError
 	}
-	gitilesClient, err := gitilesApi.NewRESTClient(request.HTTPClient, request.GitilesHost, true)
+	log.Debugf("Fetching all builds for CL: %s", request.CL)
+	start := time.Now()
+	gitilesClient, clData, clErr := requestCLData(request)
+	if clErr != nil {
+		return nil, clErr
+	}
+	cache, err := newIterCache(gitilesClient, request, clData.Release)
 	if err != nil {
-		log.Errorf("failed to establish Gitiles client for host %s:\n%v", request.GitilesHost, err)
-		return nil, utils.InternalServerError
+		return nil, iterCacheError(err, clData)
 	}
-	clData, clErr := getCLData(request.CL, request.GerritHost, request.HTTPClient)
+	var buildNums []string
+	clErr = searchExponential(request, cache, clData, func() (bool, utils.ChangelogError) {
+		var canExpand bool
+		var clErr utils.ChangelogError
+		buildNums, canExpand, clErr = findBuildRangeInRange(request, cache, clData)
+		return canExpand, clErr
+	})
 	if clErr != nil {
 		return nil, clErr
 	}
-	buildNum, clErr := findBuildExponential(gitilesClient, request, clData)
+	log.Debugf("Retrieved %d builds for CL: %s in %s\n", len(buildNums), request.CL, time.Since(start))
+	output := make([]BuildResponse, len(buildNums))
+	for i, buildNum := range buildNums {
+		output[i] = *buildResponse(buildNum, clData)
+	}
+	return output, nil
+}
+
+// requestCLData creates a Gitiles client for the manifest repository of a
+// request and retrieves the data of the requested CL.
+func requestCLData(request *BuildRequest) (gitilesProto.GitilesClient, *clData, utils.ChangelogError) {
+	gitilesClient, err := gitilesApi.NewRESTClient(request.HTTPClient, request.GitilesHost, true)
+	if err != nil {
+		log.Errorf("failed to establish Gitiles client for host %s:\n%v", request.GitilesHost, err)
+		return nil, nil, utils.InternalServerError
+	}
+	gerritClient, err := NewGerritClient(request.GerritHost, request.HTTPClient)
+	if err != nil {
+		log.Errorf("failed to establish Gerrit client for host %s:\n%v", request.GerritHost, err)
+		return nil, nil, utils.InternalServerError
+	}
+	clData, clErr := getCLData(gerritClient, request)
 	if clErr != nil {
-		return nil, clErr
+		return nil, nil, clErr
 	}
-	log.Debugf("Retrieved first build for CL: %s in %s\n", request.CL, time.Since(start))
+	return gitilesClient, clData, nil
+}
+
+func buildResponse(buildNum string, clData *clData) *BuildResponse {
 	return &BuildResponse{
-		BuildNum: buildNum,
-		CLNum:    clData.CLNum,
+		BuildNum:  buildNum,
+		CLNum:     clData.CLNum,
+		Repo:      clData.GerritProject,
+		Branch:    clData.Branch,
+		Release:   clData.Release,
+		Submitted: clData.Submitted,
+		Owner:     clData.Owner,
+		Verified:  clData.Verified,
+	}
+}
+
+// FindBuilds locates the first build that each of several CLs was introduced
+// to, like FindBuild with request.CL set to each CL. request.CL and
+// request.Revision are ignored, and the current revision of each CL is
+// searched for.
+//
+// CLs are grouped by release branch, and the manifest commits and tags of
+// each release branch are only retrieved once. The CLs of a release branch
+// are searched for concurrently.
+//
+// The first output maps each CL that was found to its first build, and the
+// second output maps each CL that could not be found to the error.
+func FindBuilds(request *BuildRequest, cls []string) (map[string]*BuildResponse, map[string]utils.ChangelogError) {
+	builds := make(map[string]*BuildResponse)
+	errs := make(map[string]utils.ChangelogError)
+	failAll := func(err utils.ChangelogError) (map[string]*BuildResponse, map[string]utils.ChangelogError) {
+		for _, cl := range cls {
+			errs[cl] = err
+		}
+		return builds, errs
+	}
+	if request == nil {
+		log.Error("expected non-nil request")
+		return failAll(utils.InternalServerError)
+	}
+	log.Debugf("Fetching first builds for %d CLs", len(cls))
+	start := time.Now()
+	gitilesClient, err := gitilesApi.NewRESTClient(request.HTTPClient, request.GitilesHost, true)
+	if err != nil {
+		log.Errorf("failed to establish Gitiles client for host %s:\n%v", request.GitilesHost, err)
+		return failAll(utils.InternalServerError)
+	}
+	gerritClient, err := NewGerritClient(request.GerritHost, request.HTTPClient)
+	if err != nil {
+		log.Errorf("failed to establish Gerrit client for host %s:\n%v", request.GerritHost, err)
+		return failAll(utils.InternalServerError)
+	}
+
+	var mu sync.Mutex
+	clDatas := make(map[string]*clData)
+	forEachCL(uniqueCLs(cls), func(cl string) {
+		clRequest := *request
+		clRequest.CL, clRequest.Revision = cl, ""
+		clData, clErr := getCLData(gerritClient, &clRequest)
+		mu.Lock()
+		defer mu.Unlock()
+		if clErr != nil {
+			errs[cl] = clErr
+		} else {
+			clDatas[cl] = clData
+		}
+	})
+	buildNums, clErrs := resolveCLs(clDatas,
+		func(release string) (*iterCache, error) {
+			return newIterCache(gitilesClient, request, release)
+		},
+		func(cache *iterCache, clData *clData) (string, utils.ChangelogError) {
+			return findBuildWithCache(request, cache, clData)
+		})
+	for cl, clErr := range clErrs {
+		errs[cl] = clErr
+	}
+	for cl, buildNum := range buildNums {
+		builds[cl] = buildResponse(buildNum, clDatas[cl])
+	}
+	log.Debugf("Retrieved first builds for %d CLs in %s\n", len(cls), time.Since(start))
+	return builds, errs
+}
+
+// resolveCLs groups CLs by release branch and searches for the first build
+// of each CL. newCache is called once per release branch, and resolve is
+// called for each CL with the cache of its release branch.
+func resolveCLs(clDatas map[string]*clData, newCache func(release string) (*iterCache, error), resolve func(cache *iterCache, clData *clData) (string, utils.ChangelogError)) (map[string]string, map[string]utils.ChangelogError) {
+	byRelease := make(map[string][]string)
+	for cl, clData := range clDatas {
+		byRelease[clData.Release] = append(byRelease[clData.Release], cl)
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	buildNums := make(map[string]string)
+	errs := make(map[string]utils.ChangelogError)
+	for release, releaseCLs := range byRelease {
+		wg.Add(1)
+		go func(release string, releaseCLs []string) {
+			defer wg.Done()
+			cache, err := newCache(release)
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, cl := range releaseCLs {
+					errs[cl] = iterCacheError(err, clDatas[cl])
+				}
+				return
+			}
+			forEachCL(releaseCLs, func(cl string) {
+				buildNum, clErr := resolve(cache, clDatas[cl])
+				mu.Lock()
+				defer mu.Unlock()
+				if clErr != nil {
+					errs[cl] = clErr
+				} else {
+					buildNums[cl] = buildNum
+				}
+			})
+		}(release, releaseCLs)
+	}
+	wg.Wait()
+	return buildNums, errs
+}
+
+// forEachCL calls fn for each CL, with up to findBuildsJobs calls running
+// concurrently.
+func forEachCL(cls []string, fn func(cl string)) {
+	sem := make(chan struct{}, findBuildsJobs)
+	var wg sync.WaitGroup
+	for _, cl := range cls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(cl string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(cl)
+		}(cl)
+	}
+	wg.Wait()
+}
+
+// uniqueCLs returns cls without duplicates, preserving their order.
+func uniqueCLs(cls []string) []string {
+	seen := make(map[string]bool)
+	var output []string
+	for _, cl := range cls {
+		if !seen[cl] {
+			seen[cl] = true
+			output = append(output, cl)
+		}
+	}
+	return output
+}
+
+// BuildMetadata is the output struct for the BuildInfo function
+type BuildMetadata struct {
+	BuildNum string
+	// ManifestSHA is the commit SHA of the build's manifest snapshot in the
+	// manifest repository.
+	ManifestSHA string
+	// CommitTime is the time the manifest snapshot was committed.
+	CommitTime time.Time
+	// Release is the release branch the build was created from,
+	// ex. "release-R89"
+	Release string
+}
+
+// buildInfo retrieves metadata for a build from the tags and manifest
+// snapshots of the manifest repository.
+func buildInfo(gitilesClient gitilesProto.GitilesClient, gerritClient GerritClient, instanceURL string, request *BuildRequest, buildNum string) (*BuildMetadata, utils.ChangelogError) {
+	tags, err := request.Cache.tags(gerritClient, instanceURL, request.ManifestRepo)
+	if err != nil {
+		log.Errorf("failed to retrieve tags for project %s:\n%v", request.ManifestRepo, err)
+		if rateLimitErr := gerritRateLimitError(err); rateLimitErr != nil {
+			return nil, rateLimitErr
+		}
+		if utils.GerritErrCode(err) == "403" {
+			return nil, utils.ForbiddenError
+		}
+		return nil, utils.InternalServerError
+	}
+	manifestSHA, ok := tags["refs/tags/"+buildNum]
+	if !ok {
+		log.Debugf("no tag found for build %s in project %s", buildNum, request.ManifestRepo)
+		return nil, utils.BuildNotFound(buildNum)
+	}
+	commits, _, err := utils.Commits(context.Background(), gitilesClient, request.ManifestRepo, manifestSHA, "", 1)
+	if err != nil || len(commits) == 0 {
+		log.Errorf("failed to retrieve manifest commit %s for build %s:\n%v", manifestSHA, buildNum, err)
+		return nil, utils.InternalServerError
+	}
+	if commits[0].Committer == nil {
+		log.Errorf("manifest %s has no committer", manifestSHA)
+		return nil, utils.InternalServerError
+	}
+	release, utilErr := manifestRelease(gitilesClient, request.ManifestRepo, buildNum)
+	if utilErr != nil {
+		return nil, utilErr
+	}
+	return &BuildMetadata{
+		BuildNum:    buildNum,
+		ManifestSHA: manifestSHA,
+		CommitTime:  commits[0].Committer.Time.AsTime(),
+		Release:     release,
 	}, nil
 }
 
+// manifestRelease returns the release branch of a build, as specified by the
+// default revision of its manifest snapshot.
+func manifestRelease(client gitilesProto.GitilesClient, manifestRepo, buildNum string) (string, utils.ChangelogError) {
+	response, err := utils.DownloadManifest(client, manifestRepo, buildNum)
+	if err != nil {
+		log.Errorf("failed to download manifest for build %s:\n%v", buildNum, err)
+		if utils.GitilesErrCode(err) == "404" {
+			return "", utils.BuildNotFound(buildNum)
+		}
+		return "", utils.InternalServerError
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(response.Contents); err != nil {
+		log.Errorf("failed to parse manifest for build %s:\n%v", buildNum, err)
+		return "", utils.InternalServerError
+	}
+	root := doc.SelectElement("manifest")
+	if root == nil || root.SelectElement("default") == nil {
+		log.Errorf("manifest for build %s has no default element", buildNum)
+		return "", utils.InternalServerError
+	}
+	revision := root.SelectElement("default").SelectAttrValue("revision", "")
+	return strings.TrimPrefix(revision, "refs/heads/"), nil
+}
+
+// BuildInfo retrieves the manifest snapshot commit, commit time, and release
+// branch of a build. It is the reverse of FindBuild, and only uses the
+// GitilesHost, ManifestRepo, HTTPClient and Cache fields of the request.
+func BuildInfo(request *BuildRequest, buildNum string) (*BuildMetadata, utils.ChangelogError) {
+	log.Debugf("Fetching build info for build: %s", buildNum)
+	if request == nil {
+		log.Error("expected non-nil request")
+		return nil, utils.InternalServerError
+	}
+	gitilesClient, err := gitilesApi.NewRESTClient(request.HTTPClient, request.GitilesHost, true)
+	if err != nil {
+		log.Errorf("failed to establish Gitiles client for host %s:\n%v", request.GitilesHost, err)
+		return nil, utils.InternalServerError
+	}
+	gerritClient, instanceURL, utilErr := manifestGerritClient(request)
+	if utilErr != nil {
+		return nil, utilErr
+	}
+	return buildInfo(gitilesClient, gerritClient, instanceURL, request, buildNum)
+}
+
 type secretBundle struct {
 	name  string
 	value *string
@@ -16,13 +16,26 @@ package findbuild
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+	"go.chromium.org/luci/common/proto/git"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
@@ -32,6 +45,8 @@ const (
 	externalManifestRepo      string = "cos/manifest-snapshots"
 )
 
+var record = flag.Bool("record", false, "record live Gerrit and Gitiles traffic into the replay fixtures in testdata/replay")
+
 func getHTTPClient() (*http.Client, error) {
 	creds, err := google.FindDefaultCredentials(context.Background(), "https://www.googleapis.com/auth/gerritcodereview")
 	if err != nil || len(creds.JSON) == 0 {
@@ -40,6 +55,20 @@ func getHTTPClient() (*http.Client, error) {
 	return oauth2.NewClient(oauth2.NoContext, creds.TokenSource), nil
 }
 
+// replayClient returns an http.Client that replays the traffic recorded in
+// testdata/replay/<name>.json. If the -record flag is set, the fixture is
+// regenerated from live services using application default credentials.
+func replayClient(t *testing.T, name string) *http.Client {
+	var base *http.Client
+	if *record {
+		var err error
+		if base, err = getHTTPClient(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fakes.NewReplayClient(t, filepath.Join("testdata", "replay", name+".json"), *record, base)
+}
+
 func TestFindCL(t *testing.T) {
 	tests := map[string]struct {
 		Change             string
@@ -178,7 +207,7 @@ func TestFindCL(t *testing.T) {
 		},
 	}
 
-	httpClient, _ := getHTTPClient()
+	httpClient := replayClient(t, "findcl")
 	for name, test := range tests {
 		req := &BuildRequest{
 			HTTPClient:   httpClient,
@@ -209,8 +238,869 @@ func TestFindCL(t *testing.T) {
 		case test.ExpectedError != "" && err != nil && test.ExpectedError != err.HTTPCode():
 			t.Fatalf("test \"%s\" failed:\nexpected error code %s, got error code %s", name, test.ExpectedError, err.HTTPCode())
 		case test.ExpectedError == "" && res.BuildNum != test.OutputBuildNum:
-			t.Fatalf("test \"%s\" failed:\nexpected output %s, got %s", name, test.OutputBuildNum, res)
+			t.Fatalf("test \"%s\" failed:\nexpected output %s, got %s", name, test.OutputBuildNum, res.BuildNum)
+		}
+		// Avoid Gerrit rate limits while recording.
+		if *record {
+			time.Sleep(time.Second * 5)
+		}
+	}
+}
+
+func TestCacheGet(t *testing.T) {
+	var fetches int32
+	fetch := func() (interface{}, error) {
+		atomic.AddInt32(&fetches, 1)
+		return "value", nil
+	}
+
+	// Concurrent requests for the same key should only fetch once
+	cache := NewCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := cache.get("key", fetch)
+			if err != nil || val.(string) != "value" {
+				t.Errorf("expected value \"value\" with no error, got %v, %v", val, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches != 1 {
+		t.Errorf("expected 1 fetch, got %d", fetches)
+	}
+
+	// Different keys are fetched separately
+	if _, err := cache.get("other", fetch); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if fetches != 2 {
+		t.Errorf("expected 2 fetches, got %d", fetches)
+	}
+
+	// Errors are not cached
+	failures := 0
+	failingFetch := func() (interface{}, error) {
+		failures++
+		return nil, errors.New("fetch failed")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.get("failing", failingFetch); err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	}
+	if failures != 2 {
+		t.Errorf("expected failed fetch to be retried, got %d fetches", failures)
+	}
+
+	// A nil cache always fetches
+	var nilCache *Cache
+	fetches = 0
+	for i := 0; i < 2; i++ {
+		if _, err := nilCache.get("key", fetch); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("expected 2 fetches with nil cache, got %d", fetches)
+	}
+}
+
+func TestManifestProject(t *testing.T) {
+	tests := map[string]struct {
+		Project    string
+		RepoPrefix string
+		Expected   string
+	}{
+		"no prefix":                {"cos/overlays/board-overlays", "", "cos/overlays/board-overlays"},
+		"chromium prefix stripped": {"chromiumos/platform2", "", "platform2"},
+		"unmatched project":        {"platform2", "", "platform2"},
+		"repo prefix":              {"chromiumos/platform2", "mirrors/cros/", "mirrors/cros/chromiumos/platform2"},
+		"repo prefix no slash":     {"chromiumos/platform2", "mirrors/cros", "mirrors/cros/chromiumos/platform2"},
+	}
+	for name, test := range tests {
+		if got := manifestProject(test.Project, test.RepoPrefix); got != test.Expected {
+			t.Errorf("test %q failed: expected %q, got %q", name, test.Expected, got)
 		}
-		time.Sleep(time.Second * 5)
+	}
+}
+
+func TestManifestDataRepoPrefix(t *testing.T) {
+	manifest := `<?xml version="1.0" encoding="UTF-8"?>
+<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/master"/>
+  <project name="chromiumos/platform2" path="src/platform2" revision="upstream-sha" upstream="refs/heads/main"/>
+  <project name="mirrors/cros/chromiumos/platform2" path="src/mirror/platform2" revision="mirror-sha" upstream="refs/heads/main"/>
+</manifest>`
+	client := &fakeGitilesClient{manifests: map[string]string{"15000.0.0": manifest}}
+	tests := map[string]struct {
+		RepoPrefix   string
+		ExpectedRepo string
+		ExpectedSHA  string
+	}{
+		"no prefix": {
+			RepoPrefix:   "",
+			ExpectedRepo: "mirrors/cros/chromiumos/platform2",
+			ExpectedSHA:  "mirror-sha",
+		},
+		"mirror prefix": {
+			RepoPrefix:   "mirrors/cros/",
+			ExpectedRepo: "mirrors/cros/chromiumos/platform2",
+			ExpectedSHA:  "mirror-sha",
+		},
+		"unmatched prefix": {
+			RepoPrefix: "mirrors/other/",
+		},
+	}
+	for name, test := range tests {
+		data := &clData{
+			Project: manifestProject("chromiumos/platform2", test.RepoPrefix),
+			Branch:  "main",
+		}
+		out := make(chan manifestResponse, 1)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		manifestData(client, externalManifestRepo, "15000.0.0", data, out, &wg)
+		res := <-out
+		if res.Err != nil {
+			t.Errorf("test %q failed: expected no error, got %v", name, res.Err)
+		} else if res.Repo != test.ExpectedRepo {
+			t.Errorf("test %q failed: expected repo %q, got %q", name, test.ExpectedRepo, res.Repo)
+		} else if res.SHA != test.ExpectedSHA {
+			t.Errorf("test %q failed: expected SHA %q, got %q", name, test.ExpectedSHA, res.SHA)
+		}
+	}
+}
+
+func TestParseRepoPrefixRules(t *testing.T) {
+	tests := map[string]struct {
+		Spec          string
+		ExpectedRules [][2]string
+		ShouldErr     bool
+	}{
+		"empty":          {Spec: "", ExpectedRules: nil},
+		"single rule":    {Spec: "^chromiumos/=mirrors/cros/", ExpectedRules: [][2]string{{"^chromiumos/", "mirrors/cros/"}}},
+		"multiple rules": {Spec: "^chromiumos/=mirrors/cros/, ^aosp/=mirrors/aosp/", ExpectedRules: [][2]string{{"^chromiumos/", "mirrors/cros/"}, {"^aosp/", "mirrors/aosp/"}}},
+		"empty prefix":   {Spec: "^cos/=", ExpectedRules: [][2]string{{"^cos/", ""}}},
+		"missing prefix": {Spec: "^chromiumos/", ShouldErr: true},
+		"empty pattern":  {Spec: "=mirrors/cros/", ShouldErr: true},
+		"invalid regexp": {Spec: "(=mirrors/cros/", ShouldErr: true},
+	}
+	for name, test := range tests {
+		rules, err := ParseRepoPrefixRules(test.Spec)
+		if test.ShouldErr {
+			if err == nil {
+				t.Errorf("test %q failed: expected error, got nil", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: expected no error, got %v", name, err)
+			continue
+		}
+		if len(rules) != len(test.ExpectedRules) {
+			t.Errorf("test %q failed: expected %d rules, got %d", name, len(test.ExpectedRules), len(rules))
+			continue
+		}
+		for i, rule := range rules {
+			if rule.Pattern.String() != test.ExpectedRules[i][0] || rule.Prefix != test.ExpectedRules[i][1] {
+				t.Errorf("test %q failed: expected rule %v, got {%s %s}", name, test.ExpectedRules[i], rule.Pattern, rule.Prefix)
+			}
+		}
+	}
+}
+
+func TestRepoPrefix(t *testing.T) {
+	rules, err := ParseRepoPrefixRules("^chromiumos/third_party/=mirrors/cros-third-party/,^chromiumos/=mirrors/cros/,^aosp/=mirrors/aosp/")
+	if err != nil {
+		t.Fatalf("failed to parse rules: %v", err)
+	}
+	req := &BuildRequest{RepoPrefix: "mirrors/default/", RepoPrefixRules: rules}
+	tests := map[string]struct {
+		Project  string
+		Expected string
+	}{
+		"first matching rule is used": {"chromiumos/third_party/kernel", "mirrors/cros-third-party/"},
+		"second rule":                 {"chromiumos/platform2", "mirrors/cros/"},
+		"third rule":                  {"aosp/platform/external/libchrome", "mirrors/aosp/"},
+		"default prefix":              {"chromium/tools/depot_tools", "mirrors/default/"},
+	}
+	for name, test := range tests {
+		if got := req.repoPrefix(test.Project); got != test.Expected {
+			t.Errorf("test %q failed: expected prefix %q, got %q", name, test.Expected, got)
+		}
+	}
+
+	// Without rules or a default prefix, no prefix is used
+	if got := (&BuildRequest{}).repoPrefix("chromiumos/platform2"); got != "" {
+		t.Errorf("expected empty prefix, got %q", got)
+	}
+}
+
+// manifestCommitsAt creates manifest commits with the given commit times,
+// which should be in reverse chronological order.
+func manifestCommitsAt(times ...time.Time) []*git.Commit {
+	commits := make([]*git.Commit, len(times))
+	for i, commitTime := range times {
+		commits[i] = &git.Commit{
+			Id:        fmt.Sprintf("manifest-%d", i),
+			Committer: &git.Commit_User{Time: timestamppb.New(commitTime)},
+		}
+	}
+	return commits
+}
+
+func TestBuildCadence(t *testing.T) {
+	base := time.Date(2021, time.March, 4, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		Times    []time.Time
+		Expected time.Duration
+	}{
+		"no commits":     {Times: nil, Expected: 0},
+		"single commit":  {Times: []time.Time{base}, Expected: 0},
+		"regular builds": {Times: []time.Time{base, base.Add(-6 * time.Hour), base.Add(-12 * time.Hour), base.Add(-18 * time.Hour)}, Expected: 6 * time.Hour},
+		"outlier gap ignored": {
+			Times:    []time.Time{base, base.Add(-4 * time.Hour), base.Add(-8 * time.Hour), base.Add(-80 * time.Hour), base.Add(-84 * time.Hour)},
+			Expected: 4 * time.Hour,
+		},
+	}
+	for name, test := range tests {
+		if got := buildCadence(manifestCommitsAt(test.Times...)); got != test.Expected {
+			t.Errorf("test %q failed: expected cadence %v, got %v", name, test.Expected, got)
+		}
+	}
+
+	// Only the most recent commits are sampled
+	var times []time.Time
+	for i := 0; i <= cadenceSampleSize; i++ {
+		times = append(times, base.Add(time.Duration(-i)*time.Hour))
+	}
+	for i := 1; i <= cadenceSampleSize; i++ {
+		times = append(times, base.Add(-time.Duration(cadenceSampleSize)*time.Hour-time.Duration(i)*24*time.Hour))
+	}
+	if got := buildCadence(manifestCommitsAt(times...)); got != time.Hour {
+		t.Errorf("expected cadence of most recent commits to be 1h, got %v", got)
+	}
+}
+
+func TestTooRecentError(t *testing.T) {
+	latest := time.Date(2021, time.March, 4, 12, 0, 0, 0, time.UTC)
+	commits := manifestCommitsAt(latest, latest.Add(-6*time.Hour), latest.Add(-12*time.Hour))
+	data := &clData{CLNum: "1540", InstanceURL: externalGerritURL, Submitted: latest.Add(time.Hour)}
+
+	err := tooRecentError(commits, data, latest.Add(2*time.Hour))
+	expected := "CL 1540 was submitted at Mar 4, 2021 13:00 UTC, which is too recent to be included in any builds. " +
+		"Builds on this branch are typically created every 6 hours. Please try again in about 4 hours."
+	if err.HTTPCode() != "406" {
+		t.Errorf("expected error code 406, got %s", err.HTTPCode())
+	} else if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+
+	// Fall back to the generic error if the cadence is unknown
+	err = tooRecentError(commits[:1], data, latest.Add(2*time.Hour))
+	if err.Error() != utils.CLTooRecent("1540", externalGerritURL).Error() {
+		t.Errorf("expected generic too recent error, got %q", err.Error())
+	}
+}
+
+func TestBuildsContaining(t *testing.T) {
+	// Repository changelog in reverse chronological order
+	changelog := []*git.Commit{{Id: "sha-4"}, {Id: "sha-3"}, {Id: "sha-2"}, {Id: "sha-1"}}
+	// Candidate builds in reverse chronological order
+	buildNums := []string{"15000.0.0", "14999.0.0", "14998.0.0", "14997.0.0", "14996.0.0"}
+	builds := map[string]string{
+		"15000.0.0": "sha-4",
+		"14999.0.0": "sha-3",
+		"14998.0.0": "sha-3",
+		"14997.0.0": "sha-2",
+		"14996.0.0": "sha-1",
+	}
+	tests := map[string]struct {
+		Revision string
+		Builds   map[string]string
+		Expected []string
+		Err      bool
+	}{
+		"oldest commit":        {Revision: "sha-1", Builds: builds, Expected: []string{"14996.0.0", "14997.0.0", "14998.0.0", "14999.0.0", "15000.0.0"}},
+		"builds with same SHA": {Revision: "sha-3", Builds: builds, Expected: []string{"14998.0.0", "14999.0.0", "15000.0.0"}},
+		"newest commit":        {Revision: "sha-4", Builds: builds, Expected: []string{"15000.0.0"}},
+		"missing manifest": {
+			Revision: "sha-2",
+			Builds:   map[string]string{"15000.0.0": "sha-4", "14997.0.0": "sha-2"},
+			Expected: []string{"14997.0.0", "15000.0.0"},
+		},
+		"CL not in changelog": {Revision: "sha-5", Builds: builds, Err: true},
+		"CL not in builds": {
+			Revision: "sha-4",
+			Builds:   map[string]string{"14996.0.0": "sha-1"},
+			Err:      true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := buildsContaining(changelog, &clData{CLNum: "1540", Revision: test.Revision}, buildNums, test.Builds)
+			if test.Err {
+				if err == nil {
+					t.Fatalf("expected error, got builds %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.Expected) {
+				t.Errorf("expected builds %v, got %v", test.Expected, got)
+			}
+		})
+	}
+}
+
+func TestSearchExponential(t *testing.T) {
+	submitted := time.Date(2021, time.March, 4, 0, 0, 0, 0, time.UTC)
+	cache := &iterCache{ManifestCommits: manifestCommitsAt(submitted.AddDate(0, 0, 200), submitted.AddDate(0, 0, -1))}
+	day := func(d int) time.Time { return submitted.AddDate(0, 0, d) }
+	type searchRange struct{ Start, End time.Time }
+	notFound := utils.CLLandingNotFound("1540", externalGerritURL)
+	tests := map[string]struct {
+		Request  *BuildRequest
+		FoundAt  int
+		Expected []searchRange
+		Err      string
+	}{
+		"default range": {
+			Request:  &BuildRequest{},
+			FoundAt:  2,
+			Expected: []searchRange{{day(0), day(5)}, {day(0), day(30)}, {day(25), day(155)}},
+		},
+		"initial range": {
+			Request:  &BuildRequest{InitialSearchDays: 10},
+			FoundAt:  1,
+			Expected: []searchRange{{day(0), day(10)}, {day(0), day(60)}},
+		},
+		"max range": {
+			Request:  &BuildRequest{MaxSearchDays: 40},
+			FoundAt:  -1,
+			Expected: []searchRange{{day(0), day(5)}, {day(0), day(30)}, {day(25), day(40)}},
+			Err:      utils.CLSearchRangeExceeded("1540", externalGerritURL, 40).Error(),
+		},
+		"max range smaller than initial range": {
+			Request:  &BuildRequest{InitialSearchDays: 10, MaxSearchDays: 3},
+			FoundAt:  -1,
+			Expected: []searchRange{{day(0), day(3)}},
+			Err:      utils.CLSearchRangeExceeded("1540", externalGerritURL, 3).Error(),
+		},
+		"found within max range": {
+			Request:  &BuildRequest{MaxSearchDays: 40},
+			FoundAt:  1,
+			Expected: []searchRange{{day(0), day(5)}, {day(0), day(30)}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := &clData{CLNum: "1540", InstanceURL: externalGerritURL, SearchStartRange: submitted, SearchEndRange: day(test.Request.initialSearchDays())}
+			var got []searchRange
+			err := searchExponential(test.Request, cache, data, func() (bool, utils.ChangelogError) {
+				got = append(got, searchRange{data.SearchStartRange, data.SearchEndRange})
+				if len(got)-1 == test.FoundAt {
+					return true, nil
+				}
+				return true, notFound
+			})
+			if !reflect.DeepEqual(got, test.Expected) {
+				t.Errorf("expected search ranges %v, got %v", test.Expected, got)
+			}
+			switch {
+			case test.Err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.Err != "" && (err == nil || err.Error() != test.Err):
+				t.Errorf("expected error %q, got %v", test.Err, err)
+			}
+		})
+	}
+}
+
+func TestResolveCLs(t *testing.T) {
+	clDatas := map[string]*clData{
+		"1": {CLNum: "1", Release: "release-R93", InstanceURL: externalGerritURL},
+		"2": {CLNum: "2", Release: "release-R93", InstanceURL: externalGerritURL},
+		"3": {CLNum: "3", Release: "release-R97", InstanceURL: externalGerritURL},
+		"4": {CLNum: "4", Release: "release-R97", InstanceURL: externalGerritURL},
+		"5": {CLNum: "5", Release: "release-R1", InstanceURL: externalGerritURL},
+		"6": {CLNum: "6", Release: "release-R2", InstanceURL: externalGerritURL},
+	}
+	var mu sync.Mutex
+	fetched := make(map[string]int)
+	newCache := func(release string) (*iterCache, error) {
+		mu.Lock()
+		fetched[release]++
+		mu.Unlock()
+		switch release {
+		case "release-R1":
+			return nil, fmt.Errorf("%w: not found", errInvalidRelease)
+		case "release-R2":
+			return nil, errors.New("internal error")
+		}
+		return &iterCache{Tags: map[string]string{"release": release}}, nil
+	}
+	resolve := func(cache *iterCache, clData *clData) (string, utils.ChangelogError) {
+		if cache.Tags["release"] != clData.Release {
+			t.Errorf("CL %s on %s resolved with the cache of %s", clData.CLNum, clData.Release, cache.Tags["release"])
+		}
+		if clData.CLNum == "4" {
+			return "", utils.CLNotUsed("4", "cos/overlays", clData.Release, clData.InstanceURL)
+		}
+		return "build-" + clData.CLNum, nil
+	}
+
+	buildNums, errs := resolveCLs(clDatas, newCache, resolve)
+	expectedBuilds := map[string]string{"1": "build-1", "2": "build-2", "3": "build-3"}
+	if !reflect.DeepEqual(buildNums, expectedBuilds) {
+		t.Errorf("expected builds %v, got %v", expectedBuilds, buildNums)
+	}
+	expectedErrs := map[string]string{
+		"4": utils.CLNotUsed("4", "cos/overlays", "release-R97", externalGerritURL).HTTPCode(),
+		"5": utils.CLInvalidRelease("5", "release-R1", externalGerritURL).HTTPCode(),
+		"6": utils.InternalServerError.HTTPCode(),
+	}
+	errCodes := make(map[string]string)
+	for cl, err := range errs {
+		errCodes[cl] = err.HTTPCode()
+	}
+	if !reflect.DeepEqual(errCodes, expectedErrs) {
+		t.Errorf("expected error codes %v, got %v", expectedErrs, errCodes)
+	}
+	expectedFetched := map[string]int{"release-R93": 1, "release-R97": 1, "release-R1": 1, "release-R2": 1}
+	if !reflect.DeepEqual(fetched, expectedFetched) {
+		t.Errorf("expected manifest data to be retrieved once per release branch %v, got %v", expectedFetched, fetched)
+	}
+	if got, expected := uniqueCLs([]string{"1", "2", "1", "3", "2"}), []string{"1", "2", "3"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("uniqueCLs: expected %v, got %v", expected, got)
+	}
+}
+
+func TestQueryCL(t *testing.T) {
+	tests := map[string]struct {
+		CL            string
+		Revision      string
+		ErrCode       int
+		ExpectedSHA   string
+		ExpectedError string
+	}{
+		"submitted change":       {CL: "3781", ExpectedSHA: "0123456789abcdef0123456789abcdef01234567"},
+		"submitted commit SHA":   {CL: "89abcdef0123456789abcdef0123456789abcdef", ExpectedSHA: "89abcdef0123456789abcdef0123456789abcdef"},
+		"unsubmitted change":     {CL: "3783", ExpectedError: "406"},
+		"change does not exist":  {CL: "9999", ExpectedError: "404"},
+		"forbidden":              {CL: "3781", ErrCode: 403, ExpectedError: "403"},
+		"not found":              {CL: "3781", ErrCode: 404, ExpectedError: "404"},
+		"bad request":            {CL: "3781", ErrCode: 400, ExpectedError: "404"},
+		"internal gerrit error":  {CL: "3781", ErrCode: 503, ExpectedError: "500"},
+		"current revision":       {CL: "3784", ExpectedSHA: "cccccccccccccccccccccccccccccccccccccccc"},
+		"patchset number":        {CL: "3784", Revision: "2", ExpectedSHA: "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},
+		"revision SHA":           {CL: "3784", Revision: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", ExpectedSHA: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+		"patchset not found":     {CL: "3784", Revision: "4", ExpectedError: "404"},
+		"revision SHA not found": {CL: "3784", Revision: "dddddddddddddddddddddddddddddddddddddddd", ExpectedError: "404"},
+	}
+	for name, test := range tests {
+		client := newFakeGerritClient(t, externalManifestRepo)
+		client.errCode = test.ErrCode
+		_, sha, err := queryCL(client, test.CL, test.Revision, externalGerritURL)
+		if test.ExpectedError != "" {
+			if err == nil {
+				t.Errorf("test %q failed: expected error code %s, got nil", name, test.ExpectedError)
+			} else if err.HTTPCode() != test.ExpectedError {
+				t.Errorf("test %q failed: expected error code %s, got %s", name, test.ExpectedError, err.HTTPCode())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error %v", name, err)
+		} else if sha != test.ExpectedSHA {
+			t.Errorf("test %q failed: expected revision %s, got %s", name, test.ExpectedSHA, sha)
+		}
+	}
+}
+
+func TestQueryCLBug(t *testing.T) {
+	client := newFakeGerritClient(t, externalManifestRepo)
+	change := func(clID string) gerrit.ChangeInfo {
+		return client.changes[queryString(clID)][0]
+	}
+	client.changes[queryString("bug:100")] = []gerrit.ChangeInfo{change("3784"), change("3781")}
+	client.changes[queryString("bug:200")] = []gerrit.ChangeInfo{change("3782"), change("3781")}
+	client.changes[queryString("bug:300")] = []gerrit.ChangeInfo{change("3784")}
+	// Matches the query that would be sent if bug IDs were not validated.
+	client.changes["bug:100 OR owner:someone status:merged"] = []gerrit.ChangeInfo{change("3784")}
+	tests := map[string]struct {
+		CL            string
+		ExpectedCL    int
+		ExpectedError string
+	}{
+		"earliest submitted CL": {CL: "bug:100", ExpectedCL: 3781},
+		"bug with b/ prefix":    {CL: "bug:b/100", ExpectedCL: 3781},
+		"single CL":             {CL: "bug:300", ExpectedCL: 3784},
+		"different branches":    {CL: "bug:200", ExpectedError: "406"},
+		"no CLs":                {CL: "bug:400", ExpectedError: "404"},
+		"empty bug":             {CL: "bug:", ExpectedError: "404"},
+		"non-numeric bug":       {CL: "bug:abc", ExpectedError: "404"},
+		"injected query":        {CL: "bug:100 OR owner:someone", ExpectedError: "404"},
+	}
+	for name, test := range tests {
+		got, _, err := queryCL(client, test.CL, "", externalGerritURL)
+		if test.ExpectedError != "" {
+			if err == nil {
+				t.Errorf("test %q failed: expected error code %s, got CL %d", name, test.ExpectedError, got.Number)
+			} else if err.HTTPCode() != test.ExpectedError {
+				t.Errorf("test %q failed: expected error code %s, got %s", name, test.ExpectedError, err.HTTPCode())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error %v", name, err)
+		} else if got.Number != test.ExpectedCL {
+			t.Errorf("test %q failed: expected CL %d, got %d", name, test.ExpectedCL, got.Number)
+		}
+	}
+
+	_, _, err := queryCL(client, "bug:200", "", externalGerritURL)
+	if !errors.Is(err, utils.ErrMultipleCLsForBug) {
+		t.Errorf("expected error wrapping ErrMultipleCLsForBug, got %v", err)
+	} else if expected := "Bug 200 is referenced by CLs submitted to different branches: CL 3781, CL 3782. Please search for one of the CLs instead."; err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestQueryChangesPagination(t *testing.T) {
+	client := newFakeGerritClient(t, externalManifestRepo)
+	var changes []gerrit.ChangeInfo
+	for i := 0; i < 2*queryPageSize+3; i++ {
+		changes = append(changes, gerrit.ChangeInfo{Number: 1000 + i})
+	}
+	client.changes["paged"] = changes
+	tests := map[string]struct {
+		Limit           int
+		ExpectedChanges int
+		ExpectedQueries int
+	}{
+		"single change":        {Limit: 1, ExpectedChanges: 1, ExpectedQueries: 1},
+		"one page":             {Limit: queryPageSize, ExpectedChanges: queryPageSize, ExpectedQueries: 1},
+		"partial page":         {Limit: queryPageSize + 2, ExpectedChanges: queryPageSize + 2, ExpectedQueries: 2},
+		"all pages":            {Limit: maxBugCLs, ExpectedChanges: len(changes), ExpectedQueries: 3},
+		"limit at last change": {Limit: len(changes), ExpectedChanges: len(changes), ExpectedQueries: 3},
+	}
+	for name, test := range tests {
+		client.queries = 0
+		opt := &gerrit.QueryChangeOptions{}
+		opt.Query = []string{"paged"}
+		got, err := queryChanges(client, opt, test.Limit)
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error %v", name, err)
+			continue
+		}
+		if len(got) != test.ExpectedChanges {
+			t.Errorf("test %q failed: expected %d changes, got %d", name, test.ExpectedChanges, len(got))
+		}
+		if client.queries != test.ExpectedQueries {
+			t.Errorf("test %q failed: expected %d queries, got %d", name, test.ExpectedQueries, client.queries)
+		}
+		for i, change := range got {
+			if change.Number != 1000+i {
+				t.Errorf("test %q failed: expected change %d at index %d, got %d", name, 1000+i, i, change.Number)
+				break
+			}
+			if change.MoreChanges {
+				t.Errorf("test %q failed: change %d has _more_changes set", name, change.Number)
+			}
+		}
+	}
+}
+
+func TestQueryCLBugPagination(t *testing.T) {
+	client := newFakeGerritClient(t, externalManifestRepo)
+	// The earliest submitted CL referencing the bug is on the last page.
+	var changes []gerrit.ChangeInfo
+	for i := 0; i < queryPageSize; i++ {
+		changes = append(changes, client.changes[queryString("3784")][0])
+	}
+	changes = append(changes, client.changes[queryString("3781")][0])
+	client.changes[queryString("bug:500")] = changes
+	got, _, err := queryCL(client, "bug:500", "", externalGerritURL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got.Number != 3781 {
+		t.Errorf("expected CL 3781, got %d", got.Number)
+	}
+	if client.queries != 2 {
+		t.Errorf("expected 2 queries, got %d", client.queries)
+	}
+}
+
+func TestQueryCLRateLimited(t *testing.T) {
+	client := newFakeGerritClient(t, externalManifestRepo)
+	client.errCode = http.StatusTooManyRequests
+	_, _, err := queryCL(client, "3781", "", externalGerritURL)
+	if err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	if err.HTTPCode() != "429" {
+		t.Errorf("expected error code 429, got %s", err.HTTPCode())
+	}
+	if !errors.Is(err, utils.ErrRateLimited) {
+		t.Errorf("expected error wrapping ErrRateLimited, got %v", err)
+	}
+	if err.Retryable() {
+		t.Errorf("expected rate limit error not to expand the search range")
+	}
+}
+
+func TestGerritRetryAfter(t *testing.T) {
+	tests := map[string]struct {
+		Status             int
+		RetryAfter         string
+		ExpectedCode       string
+		ExpectedRetryAfter time.Duration
+	}{
+		"rate limited":             {http.StatusTooManyRequests, "120", "429", 2 * time.Minute},
+		"rate limited no delay":    {http.StatusTooManyRequests, "", "429", 0},
+		"forbidden":                {http.StatusForbidden, "", "403", 0},
+		"unavailable with a delay": {http.StatusServiceUnavailable, "30", "500", 0},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.RetryAfter != "" {
+					w.Header().Set("Retry-After", test.RetryAfter)
+				}
+				w.WriteHeader(test.Status)
+			}))
+			defer server.Close()
+			client, err := NewGerritClient(server.URL, server.Client())
+			if err != nil {
+				t.Fatalf("failed to create Gerrit client: %v", err)
+			}
+			_, _, utilErr := queryCL(client, "3781", "", server.URL)
+			if utilErr == nil {
+				t.Fatalf("expected error, got none")
+			}
+			if utilErr.HTTPCode() != test.ExpectedCode {
+				t.Errorf("expected error code %s, got %s", test.ExpectedCode, utilErr.HTTPCode())
+			}
+			var retryAfter time.Duration
+			if temporary, ok := utilErr.(interface{ RetryAfter() time.Duration }); ok {
+				retryAfter = temporary.RetryAfter()
+			}
+			if retryAfter != test.ExpectedRetryAfter {
+				t.Errorf("expected retry after %v, got %v", test.ExpectedRetryAfter, retryAfter)
+			}
+		})
+	}
+}
+
+func TestGetCLData(t *testing.T) {
+	tests := map[string]struct {
+		CL               string
+		Revision         string
+		ExpectedProject  string
+		ExpectedRelease  string
+		ExpectedRevision string
+		ExpectedOwner    string
+	}{
+		"master branch":         {"3781", "", "cos/overlays", "master", "0123456789abcdef0123456789abcdef01234567", "Owner 3781"},
+		"kernel release branch": {"3782", "", "third_party/kernel", "release-R89-13729.B", "89abcdef0123456789abcdef0123456789abcdef", "Owner 3782"},
+		"earlier patchset":      {"3784", "1", "cos/overlays", "master", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "owner-3784@example.com"},
+	}
+	for name, test := range tests {
+		client := newFakeGerritClient(t, externalManifestRepo)
+		request := &BuildRequest{CL: test.CL, Revision: test.Revision, GerritHost: externalGerritURL}
+		data, err := getCLData(client, request)
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error %v", name, err)
+			continue
+		}
+		if data.Project != test.ExpectedProject {
+			t.Errorf("test %q failed: expected project %s, got %s", name, test.ExpectedProject, data.Project)
+		}
+		if data.Release != test.ExpectedRelease {
+			t.Errorf("test %q failed: expected release %s, got %s", name, test.ExpectedRelease, data.Release)
+		}
+		if data.Revision != test.ExpectedRevision {
+			t.Errorf("test %q failed: expected revision %s, got %s", name, test.ExpectedRevision, data.Revision)
+		}
+		if data.CLNum != test.CL {
+			t.Errorf("test %q failed: expected CL number %s, got %s", name, test.CL, data.CLNum)
+		}
+		if data.Owner != test.ExpectedOwner {
+			t.Errorf("test %q failed: expected owner %s, got %s", name, test.ExpectedOwner, data.Owner)
+		}
+	}
+}
+
+func TestSignatureVerified(t *testing.T) {
+	verified, unverified := true, false
+	tests := map[string]struct {
+		CL       string
+		Revision string
+		ErrCode  int
+		Expected *bool
+	}{
+		"trusted key":              {"3781", "", 0, &verified},
+		"bad signature":            {"3782", "", 0, &unverified},
+		"earlier patchset trusted": {"3784", "1", 0, &verified},
+		"untrusted key":            {"3784", "", 0, &unverified},
+		"unsigned patchset":        {"3784", "2", 0, nil},
+		"gerrit error":             {"3781", "", http.StatusInternalServerError, nil},
+	}
+	for name, test := range tests {
+		client := newFakeGerritClient(t, externalManifestRepo)
+		request := &BuildRequest{CL: test.CL, Revision: test.Revision, GerritHost: externalGerritURL, VerifySignature: true}
+		data, err := getCLData(client, request)
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error %v", name, err)
+			continue
+		}
+		var got *bool
+		if test.ErrCode == 0 {
+			got = data.Verified
+		} else {
+			// Only fail the push certificates request, so that the rest of
+			// the CL data can still be retrieved.
+			change, _, _ := queryCL(client, test.CL, test.Revision, externalGerritURL)
+			client.errCode = test.ErrCode
+			got = signatureVerified(client, change, data.Revision)
+		}
+		if (got == nil) != (test.Expected == nil) || (got != nil && *got != *test.Expected) {
+			t.Errorf("test %q failed: expected verified %s, got %s", name, formatVerified(test.Expected), formatVerified(got))
+		}
+		if res := buildResponse("15000.0.0", data); res.Verified != data.Verified {
+			t.Errorf("test %q failed: expected build response to keep the verified status of the CL", name)
+		}
+	}
+}
+
+func TestSignatureNotRequested(t *testing.T) {
+	client := newFakeGerritClient(t, externalManifestRepo)
+	data, err := getCLData(client, &BuildRequest{CL: "3781", GerritHost: externalGerritURL})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if data.Verified != nil {
+		t.Errorf("expected unset verified status, got %t", *data.Verified)
+	}
+	if client.certRequests != 0 {
+		t.Errorf("expected no push certificate requests, got %d", client.certRequests)
+	}
+}
+
+func formatVerified(verified *bool) string {
+	if verified == nil {
+		return "unset"
+	}
+	return strconv.FormatBool(*verified)
+}
+
+func TestRepoTags(t *testing.T) {
+	client := newFakeGerritClient(t, externalManifestRepo)
+	tags, err := repoTags(client, externalManifestRepo)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := map[string]string{
+		"refs/tags/15000.0.0": "1111111111111111111111111111111111111111",
+		"refs/tags/15001.0.0": "3333333333333333333333333333333333333333",
+	}
+	if len(tags) != len(expected) {
+		t.Errorf("expected %d tags, got %v", len(expected), tags)
+	}
+	for ref, sha := range expected {
+		if tags[ref] != sha {
+			t.Errorf("expected tag %s to point to %s, got %s", ref, sha, tags[ref])
+		}
+	}
+
+	if _, err := repoTags(client, "cos/unknown"); err == nil {
+		t.Errorf("expected error for unknown repository, got nil")
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	commitTime := time.Date(2021, time.March, 4, 12, 0, 0, 0, time.UTC)
+	manifest := `<?xml version="1.0" encoding="UTF-8"?>
+<manifest>
+  <remote fetch="https://cos.googlesource.com" name="cos"/>
+  <default remote="cos" revision="refs/heads/release-R89"/>
+</manifest>`
+	gitilesClient := &fakeGitilesClient{
+		manifests: map[string]string{"15001.0.0": manifest},
+		commits: map[string]*git.Commit{
+			"3333333333333333333333333333333333333333": {
+				Id:        "3333333333333333333333333333333333333333",
+				Committer: &git.Commit_User{Time: timestamppb.New(commitTime)},
+			},
+		},
+	}
+	request := &BuildRequest{GitilesHost: externalGitilesURL, ManifestRepo: externalManifestRepo}
+	tests := map[string]struct {
+		BuildNum      string
+		ErrCode       int
+		Expected      *BuildMetadata
+		ExpectedError string
+	}{
+		"annotated tag": {
+			BuildNum: "15001.0.0",
+			Expected: &BuildMetadata{
+				BuildNum:    "15001.0.0",
+				ManifestSHA: "3333333333333333333333333333333333333333",
+				CommitTime:  commitTime,
+				Release:     "release-R89",
+			},
+		},
+		"unknown build":           {BuildNum: "99999.0.0", ExpectedError: "404"},
+		"missing manifest commit": {BuildNum: "15000.0.0", ExpectedError: "500"},
+		"forbidden":               {BuildNum: "15001.0.0", ErrCode: 403, ExpectedError: "403"},
+		"internal gerrit error":   {BuildNum: "15001.0.0", ErrCode: 503, ExpectedError: "500"},
+	}
+	for name, test := range tests {
+		gerritClient := newFakeGerritClient(t, externalManifestRepo)
+		gerritClient.errCode = test.ErrCode
+		res, err := buildInfo(gitilesClient, gerritClient, externalGerritURL, request, test.BuildNum)
+		if test.ExpectedError != "" {
+			if err == nil {
+				t.Errorf("test %q failed: expected error code %s, got nil", name, test.ExpectedError)
+			} else if err.HTTPCode() != test.ExpectedError {
+				t.Errorf("test %q failed: expected error code %s, got %s", name, test.ExpectedError, err.HTTPCode())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error %v", name, err)
+		} else if *res != *test.Expected {
+			t.Errorf("test %q failed: expected %+v, got %+v", name, test.Expected, res)
+		}
+	}
+}
+
+func TestGetCLDataReplay(t *testing.T) {
+	gerritClient, err := NewGerritClient(externalGerritURL, replayClient(t, "getcldata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, utilErr := getCLData(gerritClient, &BuildRequest{CL: "3781", GerritHost: externalGerritURL})
+	if utilErr != nil {
+		t.Fatalf("unexpected error %v", utilErr)
+	}
+	if data.CLNum != "3781" || data.Revision == "" || data.Release == "" || data.Owner == "" {
+		t.Errorf("expected CL 3781 with a revision, release and owner, got %+v", data)
+	}
+	if data.Verified != nil {
+		t.Errorf("expected unset verified status for a CL without a push certificate, got %t", *data.Verified)
+	}
+
+	_, utilErr = getCLData(gerritClient, &BuildRequest{CL: "99999999", GerritHost: externalGerritURL})
+	if utilErr == nil || utilErr.HTTPCode() != "404" {
+		t.Errorf("expected error code 404 for unknown CL, got %v", utilErr)
 	}
 }
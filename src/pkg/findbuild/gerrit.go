@@ -0,0 +1,149 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package findbuild
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	gerrit "github.com/andygrunwald/go-gerrit"
+)
+
+// GerritClient is the subset of the Gerrit REST API used by findbuild.
+// It allows the Gerrit instance to be replaced by a fake in tests.
+type GerritClient interface {
+	// QueryChanges lists changes visible to the caller that match opt.
+	QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, error)
+	// ListTags lists the tags of a project.
+	ListTags(project string, opt *gerrit.ProjectBaseOptions) (*[]gerrit.TagInfo, error)
+	// PushCertificates returns the push certificates of the revisions of a
+	// change, keyed by revision SHA. Revisions that were not pushed with a
+	// signed push are omitted.
+	PushCertificates(changeID string) (map[string]PushCertificateInfo, error)
+}
+
+// PushCertificateInfo contains the signed push certificate a revision was
+// uploaded with. It mirrors the Gerrit PushCertificateInfo entity, which
+// go-gerrit does not support.
+type PushCertificateInfo struct {
+	Certificate string             `json:"certificate"`
+	Key         PushCertificateKey `json:"key"`
+}
+
+// PushCertificateKey is the GPG key that signed a push certificate, and the
+// result of Gerrit's checks of the key and signature.
+type PushCertificateKey struct {
+	ID          string `json:"id,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Status is "BAD", "OK" or "TRUSTED". Only a TRUSTED key is fully
+	// trusted by Gerrit.
+	Status   string   `json:"status,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// restGerritClient implements GerritClient using the Gerrit REST API.
+type restGerritClient struct {
+	client *gerrit.Client
+}
+
+// NewGerritClient creates a GerritClient for a Gerrit instance.
+// ex. "https://cos-review.googlesource.com"
+func NewGerritClient(instanceURL string, httpClient *http.Client) (GerritClient, error) {
+	client, err := gerrit.NewClient(instanceURL, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &restGerritClient{client: client}, nil
+}
+
+func (c *restGerritClient) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, error) {
+	changes, resp, err := c.client.Changes.QueryChanges(opt)
+	return changes, withRetryAfter(resp, err)
+}
+
+func (c *restGerritClient) ListTags(project string, opt *gerrit.ProjectBaseOptions) (*[]gerrit.TagInfo, error) {
+	tags, resp, err := c.client.Projects.ListTags(project, opt)
+	return tags, withRetryAfter(resp, err)
+}
+
+func (c *restGerritClient) PushCertificates(changeID string) (map[string]PushCertificateInfo, error) {
+	req, err := c.client.NewRequest("GET", fmt.Sprintf("changes/%s/?o=ALL_REVISIONS&o=PUSH_CERTIFICATES", url.PathEscape(changeID)), nil)
+	if err != nil {
+		return nil, err
+	}
+	var change struct {
+		Revisions map[string]struct {
+			PushCertificate *PushCertificateInfo `json:"push_certificate"`
+		} `json:"revisions"`
+	}
+	resp, err := c.client.Do(req, &change)
+	if err != nil {
+		return nil, withRetryAfter(resp, err)
+	}
+	certs := make(map[string]PushCertificateInfo)
+	for sha, revision := range change.Revisions {
+		if revision.PushCertificate != nil {
+			certs[sha] = *revision.PushCertificate
+		}
+	}
+	return certs, nil
+}
+
+// retryAfterError annotates an error returned by Gerrit with the delay
+// requested by the Retry-After header of the response.
+type retryAfterError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// withRetryAfter annotates a Gerrit error with the Retry-After header of the
+// response, if it has one.
+func withRetryAfter(resp *gerrit.Response, err error) error {
+	if err == nil || resp == nil || resp.Response == nil {
+		return err
+	}
+	retryAfter := utils.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if retryAfter <= 0 {
+		return err
+	}
+	return &retryAfterError{err: err, retryAfter: retryAfter}
+}
+
+// gerritRateLimitError returns the ChangelogError for a Gerrit request that
+// was rejected because of a rate limit or an exceeded quota, including the
+// Retry-After delay if Gerrit provided one. It returns nil if err was not
+// caused by a rate limit.
+func gerritRateLimitError(err error) utils.ChangelogError {
+	if !utils.GerritRateLimited(err) {
+		return nil
+	}
+	var retryErr *retryAfterError
+	if errors.As(err, &retryErr) {
+		return utils.RateLimited("Gerrit", retryErr.retryAfter)
+	}
+	return utils.RateLimited("Gerrit", 0)
+}
@@ -19,6 +19,8 @@ type GPUPrecompilationConfig struct {
 	Milestone     string                 `json:"milestone"`
 	Version       string                 `json:"version"`
 	VersionType   string                 `json:"version_type"`
+	// ConfigDir is the GCS directory the config was read from, if any.
+	ConfigDir string `json:"-"`
 }
 
 // stubbing out current time as a function - allows current time to be injected into functions across gpuconfig package and testing
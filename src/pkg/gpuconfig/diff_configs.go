@@ -0,0 +1,76 @@
+package gpuconfig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// unsetField is the value reported for proto fields that are not set.
+const unsetField = "<unset>"
+
+// FieldDiff describes a field whose value differs between two configs.
+type FieldDiff struct {
+	Field string
+	A     string
+	B     string
+}
+
+// ConfigDiff is a field-level diff between two GPU precompilation configs.
+type ConfigDiff struct {
+	Fields []FieldDiff
+}
+
+// Empty reports whether the two compared configs are identical.
+func (d *ConfigDiff) Empty() bool {
+	return len(d.Fields) == 0
+}
+
+// String returns the diff with one differing field per line.
+func (d *ConfigDiff) String() string {
+	var sb strings.Builder
+	for _, field := range d.Fields {
+		fmt.Fprintf(&sb, "%s: %q -> %q\n", field.Field, field.A, field.B)
+	}
+	return sb.String()
+}
+
+// Compares two GPU precompilation configs field by field, for debugging why
+// two builds produced different artifacts. Metadata fields are compared
+// first, followed by the fields of the build request proto. Fields are named
+// as they appear in the metadata and config.textproto files.
+func DiffConfigs(a, b GPUPrecompilationConfig) (*ConfigDiff, error) {
+	if a.ProtoConfig == nil || b.ProtoConfig == nil {
+		return nil, errors.New("cannot diff configs without a build request proto")
+	}
+	diff := &ConfigDiff{}
+	for _, field := range []FieldDiff{
+		{"driver_version", a.DriverVersion, b.DriverVersion},
+		{"milestone", a.Milestone, b.Milestone},
+		{"version", a.Version, b.Version},
+		{"version_type", a.VersionType, b.VersionType},
+	} {
+		if field.A != field.B {
+			diff.Fields = append(diff.Fields, field)
+		}
+	}
+	protoA, protoB := a.ProtoConfig.ProtoReflect(), b.ProtoConfig.ProtoReflect()
+	fields := protoA.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		valueA, valueB := protoFieldString(protoA, fd), protoFieldString(protoB, fd)
+		if valueA != valueB {
+			diff.Fields = append(diff.Fields, FieldDiff{string(fd.Name()), valueA, valueB})
+		}
+	}
+	return diff, nil
+}
+
+func protoFieldString(m protoreflect.Message, fd protoreflect.FieldDescriptor) string {
+	if !m.Has(fd) {
+		return unsetField
+	}
+	return fmt.Sprint(m.Get(fd).Interface())
+}
@@ -0,0 +1,80 @@
+package gpuconfig
+
+import (
+	"testing"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/gpuconfig/pb"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	// modified returns a copy of testConfig changed by f.
+	modified := func(f func(*GPUPrecompilationConfig)) GPUPrecompilationConfig {
+		config := testConfig
+		config.ProtoConfig = proto.Clone(testConfig.ProtoConfig).(*pb.COSGPUBuildRequest)
+		f(&config)
+		return config
+	}
+	for _, tc := range []struct {
+		name     string
+		b        GPUPrecompilationConfig
+		expected []FieldDiff
+	}{
+		{
+			"identical",
+			modified(func(*GPUPrecompilationConfig) {}),
+			nil,
+		},
+		{
+			"driver version",
+			modified(func(c *GPUPrecompilationConfig) {
+				c.DriverVersion = "470.82.01"
+				c.ProtoConfig.NvidiaRunfileAddress = stringPtr("https://us.download.nvidia.com/tesla/470.82.01/NVIDIA-Linux-x86_64-470.82.01.run")
+			}),
+			[]FieldDiff{
+				{"driver_version", "510.47.03", "470.82.01"},
+				{"nvidia_runfile_address", "https://us.download.nvidia.com/tesla/510.47.03/NVIDIA-Linux-x86_64-510.47.03.run", "https://us.download.nvidia.com/tesla/470.82.01/NVIDIA-Linux-x86_64-470.82.01.run"},
+			},
+		},
+		{
+			"kernel version and metadata",
+			modified(func(c *GPUPrecompilationConfig) {
+				c.Milestone = "101"
+				c.Version = "5.15.55-34.m101"
+				c.VersionType = "Image"
+				c.ProtoConfig.DriverOutputGcsDir = stringPtr("gs://nvidia-drivers-us-public/nvidia-cos-project/5.15.55-34.m101/")
+			}),
+			[]FieldDiff{
+				{"milestone", "97", "101"},
+				{"version", "5.10.133-43.r97", "5.15.55-34.m101"},
+				{"version_type", "Kernel", "Image"},
+				{"driver_output_gcs_dir", "gs://nvidia-drivers-us-public/nvidia-cos-project/5.10.133-43.r97/", "gs://nvidia-drivers-us-public/nvidia-cos-project/5.15.55-34.m101/"},
+			},
+		},
+		{
+			"unset field",
+			modified(func(c *GPUPrecompilationConfig) {
+				c.ProtoConfig.ToolchainEnvGcs = nil
+			}),
+			[]FieldDiff{
+				{"toolchain_env_gcs", "gs://cos-kernel-artifacts/builds/5.10.133-43.r97/toolchain_env.x86_64", unsetField},
+			},
+		},
+	} {
+		got, err := DiffConfigs(testConfig, tc.b)
+		if err != nil {
+			t.Fatalf("%s: DiffConfigs() failed: %s", tc.name, err)
+		}
+		if diff := cmp.Diff(tc.expected, got.Fields); diff != "" {
+			t.Errorf("%s: DiffConfigs() returned unexpected difference (-want +got):\n%s", tc.name, diff)
+		}
+		if got.Empty() != (len(tc.expected) == 0) {
+			t.Errorf("%s: Empty() = %t, want %t", tc.name, got.Empty(), len(tc.expected) == 0)
+		}
+	}
+
+	if _, err := DiffConfigs(testConfig, GPUPrecompilationConfig{}); err == nil {
+		t.Errorf("DiffConfigs() with a nil proto config succeeded, want error")
+	}
+}
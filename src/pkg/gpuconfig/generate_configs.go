@@ -2,6 +2,7 @@ package gpuconfig
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"strings"
@@ -18,6 +19,13 @@ const (
 	toolchainEnvPathTemplate         string = "%s/%s/toolchain_env.x86_64"
 	driverOutputGcsDirTemplate       string = "gs://nvidia-drivers-us-public/nvidia-cos-project/%s/"
 	nvidiaRunfileAddressTemplate     string = "https://us.download.nvidia.com/tesla/%[1]s/NVIDIA-Linux-x86_64-%[1]s.run"
+
+	imageGCSBucket                    string = "cos-tools"
+	imageKernelSrcTarballPathTemplate string = "gs://cos-tools/%s/kernel-src.tar.gz"
+	imageKernelHeadersPathTemplate    string = "gs://cos-tools/%s/kernel-headers.tgz"
+	imageToolchainTarballPathTemplate string = "%s/toolchain.tar.xz"
+	imageToolchainURLPathTemplate     string = "%s/toolchain_url"
+	imageToolchainEnvPathTemplate     string = "gs://cos-tools/%s/toolchain_env"
 )
 
 func kernelVersionToMilestone(kernelVersion string) string {
@@ -41,11 +49,69 @@ func GenerateKernelCIConfigs(ctx context.Context, client *storage.Client, kernel
 			return nil, err
 		}
 		milestone := kernelVersionToMilestone(kernelVersion)
-		configs = append(configs, GPUPrecompilationConfig{config, driverVersion, milestone, kernelVersion, "Kernel"})
+		configs = append(configs, GPUPrecompilationConfig{ProtoConfig: config, DriverVersion: driverVersion, Milestone: milestone, Version: kernelVersion, VersionType: "Kernel"})
+	}
+	return configs, nil
+}
+
+// Generates GPU precompilation build configs(and metadata) for a given COS
+// image build number and driver versions. These are the configs processed by
+// the driver builder in image mode.
+func GenerateImageCIConfigs(ctx context.Context, client *storage.Client, buildNumber, milestone string, driverVersions []string) ([]GPUPrecompilationConfig, error) {
+	configs := []GPUPrecompilationConfig{}
+	for _, driverVersion := range driverVersions {
+		config, err := constructImageCIConfig(ctx, client, buildNumber, driverVersion)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, GPUPrecompilationConfig{ProtoConfig: config, DriverVersion: driverVersion, Milestone: milestone, Version: buildNumber, VersionType: "Image"})
 	}
 	return configs, nil
 }
 
+func constructImageCIConfig(ctx context.Context, client *storage.Client, buildNumber, driverVersion string) (*pb.COSGPUBuildRequest, error) {
+	config := pb.COSGPUBuildRequest{
+		KernelSrcTarballGcs:     stringPtr(fmt.Sprintf(imageKernelSrcTarballPathTemplate, buildNumber)),
+		KernelHeadersTarballGcs: stringPtr(fmt.Sprintf(imageKernelHeadersPathTemplate, buildNumber)),
+		NvidiaRunfileAddress:    stringPtr(fmt.Sprintf(nvidiaRunfileAddressTemplate, driverVersion)),
+		ToolchainEnvGcs:         stringPtr(fmt.Sprintf(imageToolchainEnvPathTemplate, buildNumber)),
+		DriverOutputGcsDir:      stringPtr(fmt.Sprintf(driverOutputGcsDirTemplate, buildNumber)),
+	}
+
+	toolchainTarballPath, err := fetchImageToolchainTarballPath(ctx, client, buildNumber)
+	if err != nil {
+		return nil, err
+	}
+	config.ToolchainTarballGcs = &toolchainTarballPath
+
+	return &config, nil
+}
+
+// fetchImageToolchainTarballPath returns the toolchain tarball of a COS image
+// build. Builds without a toolchain tarball in cos-tools point to the
+// toolchain tarball in a toolchain_url file instead.
+func fetchImageToolchainTarballPath(ctx context.Context, client *storage.Client, buildNumber string) (string, error) {
+	bkt := client.Bucket(imageGCSBucket)
+	toolchainTarballPath := fmt.Sprintf(imageToolchainTarballPathTemplate, buildNumber)
+	_, err := bkt.Object(toolchainTarballPath).Attrs(ctx)
+	if err == nil {
+		return fmt.Sprintf("gs://%s/%s", imageGCSBucket, toolchainTarballPath), nil
+	}
+	if !errors.Is(err, storage.ErrObjectNotExist) {
+		return "", fmt.Errorf("Could not check for the toolchain tarball: %w", err)
+	}
+	reader, err := bkt.Object(fmt.Sprintf(imageToolchainURLPathTemplate, buildNumber)).NewReader(ctx)
+	if err != nil {
+		return "", fmt.Errorf("Could not fetch the toolchain tarball path: %w", err)
+	}
+	defer reader.Close()
+	toolchainURL, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("Could not read file contents of toolchain tarball path: %w", err)
+	}
+	return strings.TrimSpace(string(toolchainURL)), nil
+}
+
 func constructKernelCIConfig(ctx context.Context, client *storage.Client, kernelVersion, driverVersion string) (*pb.COSGPUBuildRequest, error) {
 	config := pb.COSGPUBuildRequest{
 		KernelSrcTarballGcs:     stringPtr(fmt.Sprintf(kernelSrcTarballPathTemplate, kernelGCSPrefix, kernelVersion)),
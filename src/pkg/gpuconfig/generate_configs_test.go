@@ -58,6 +58,54 @@ func TestGenerateKernelCIConfigs(t *testing.T) {
 	}
 }
 
+func TestGenerateImageCIConfigs(t *testing.T) {
+	gcs := fakes.GCSForTest(t)
+	defer gcs.Close()
+	gcs.Objects = map[string][]byte{
+		"/cos-tools/16108.403.42/toolchain.tar.xz": []byte("toolchain"),
+		"/cos-tools/15000.0.0/toolchain_url":       []byte(toolchainTarballPath + "\n"),
+	}
+	client := gcs.Client
+	for _, tc := range []struct {
+		buildNumber       string
+		milestone         string
+		driverVersions    []string
+		expectedToolchain string
+	}{
+		{"16108.403.42", "97", []string{"470.82.01", "510.47.03"}, "gs://cos-tools/16108.403.42/toolchain.tar.xz"},
+		{"15000.0.0", "93", []string{"470.82.01"}, toolchainTarballPath},
+	} {
+		var expected []GPUPrecompilationConfig
+		for _, driverVersion := range tc.driverVersions {
+			expected = append(expected, GPUPrecompilationConfig{
+				ProtoConfig: &pb.COSGPUBuildRequest{
+					KernelSrcTarballGcs:     stringPtr("gs://cos-tools/" + tc.buildNumber + "/kernel-src.tar.gz"),
+					KernelHeadersTarballGcs: stringPtr("gs://cos-tools/" + tc.buildNumber + "/kernel-headers.tgz"),
+					NvidiaRunfileAddress:    stringPtr("https://us.download.nvidia.com/tesla/" + driverVersion + "/NVIDIA-Linux-x86_64-" + driverVersion + ".run"),
+					ToolchainTarballGcs:     stringPtr(tc.expectedToolchain),
+					ToolchainEnvGcs:         stringPtr("gs://cos-tools/" + tc.buildNumber + "/toolchain_env"),
+					DriverOutputGcsDir:      stringPtr("gs://nvidia-drivers-us-public/nvidia-cos-project/" + tc.buildNumber + "/"),
+				},
+				DriverVersion: driverVersion,
+				Milestone:     tc.milestone,
+				Version:       tc.buildNumber,
+				VersionType:   "Image",
+			})
+		}
+		got, err := GenerateImageCIConfigs(context.Background(), client, tc.buildNumber, tc.milestone, tc.driverVersions)
+		if err != nil {
+			t.Fatalf("GenerateImageCIConfigs(%s) failed: %s", tc.buildNumber, err)
+		}
+		if diff := cmp.Diff(got, expected, protocmp.Transform()); diff != "" {
+			t.Errorf("GenerateImageCIConfigs(%s) returned unexpected difference (-want +got):\n%s", tc.buildNumber, diff)
+		}
+	}
+
+	if _, err := GenerateImageCIConfigs(context.Background(), client, "1.0.0", "1", []string{"470.82.01"}); err == nil {
+		t.Errorf("GenerateImageCIConfigs() for a build without toolchain artifacts succeeded, want error")
+	}
+}
+
 func TestKernelVersionToMilestone(t *testing.T) {
 	for _, tc := range []struct {
 		kernelVersion     string
@@ -14,9 +14,13 @@ import (
 	"google.golang.org/api/iterator"
 )
 
-func listConfigDirs(ctx context.Context, client *storage.Client, bucketName string, start string) ([]string, error) {
+// configDirTimeFormat is the layout of the timestamp that prefixes config dir names.
+const configDirTimeFormat = "2006-01-02T15:04:05"
+
+func listConfigDirs(ctx context.Context, client *storage.Client, bucketName string, start string, end string) ([]string, error) {
 	query := &storage.Query{
 		StartOffset: start, // Only list objects lexicographically >=
+		EndOffset:   end,   // Only list objects lexicographically <, if set
 		Delimiter:   "/",   // Only list dirs
 	}
 	query.SetAttrSelection([]string{"Prefix"})
@@ -62,14 +66,28 @@ func ReadConfig(ctx context.Context, client *storage.Client, dirName string) (GP
 	if err := proto.UnmarshalText(textproto, config.ProtoConfig); err != nil {
 		return config, err
 	}
+	config.ConfigDir = dirName
 
 	return config, nil
 }
 
 // Reads all config dirs published within <lookBackDays> of current date into a list of GPUPrecompilationConfig struct
 func ReadConfigs(ctx context.Context, client *storage.Client, bucketName string, lookBackDays int, versionType string) ([]GPUPrecompilationConfig, error) {
-	startDay := strings.TrimSuffix(timeNow().AddDate(0, 0, -lookBackDays).Format(time.RFC3339), "Z")
-	dirNames, err := listConfigDirs(ctx, client, bucketName, startDay)
+	return ReadConfigsInRange(ctx, client, bucketName, timeNow().AddDate(0, 0, -lookBackDays), time.Time{}, versionType)
+}
+
+// Reads all config dirs published in the window [since, until) into a list of GPUPrecompilationConfig struct.
+// A zero until leaves the window open-ended.
+func ReadConfigsInRange(ctx context.Context, client *storage.Client, bucketName string, since, until time.Time, versionType string) ([]GPUPrecompilationConfig, error) {
+	if !until.IsZero() && !since.Before(until) {
+		return nil, fmt.Errorf("invalid time range: since %s is not before until %s", since.Format(time.RFC3339), until.Format(time.RFC3339))
+	}
+	start := since.UTC().Format(configDirTimeFormat)
+	var end string
+	if !until.IsZero() {
+		end = until.UTC().Format(configDirTimeFormat)
+	}
+	dirNames, err := listConfigDirs(ctx, client, bucketName, start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -8,6 +8,7 @@ import (
 
 	"cos.googlesource.com/cos/tools.git/src/pkg/fakes"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"google.golang.org/protobuf/testing/protocmp"
 )
 
@@ -20,6 +21,7 @@ func TestReadConfig(t *testing.T) {
 		"/cos-gpu-configs-test/2022-10-07T01:36:07-4ed7213e/metadata":         testMetadataContents,
 	}
 	want := testConfig
+	want.ConfigDir = "gs://cos-gpu-configs-test/2022-10-07T01:36:07-4ed7213e/"
 	got, err := ReadConfig(ctx, gcs.Client, "gs://cos-gpu-configs-test/2022-10-07T01:36:07-4ed7213e/")
 	if err != nil {
 		log.Fatalf("ReadConfig() failed:%v\n", err)
@@ -51,7 +53,95 @@ func TestReadConfigs(t *testing.T) {
 		log.Fatalf("ReadConfigs() failed:%v\n", err)
 	}
 
-	if diff := cmp.Diff(got, []GPUPrecompilationConfig{testConfig}, protocmp.Transform()); diff != "" {
+	want := testConfig
+	want.ConfigDir = "gs://cos-gpu-configs-test/2022-10-07T01:29:43-e9b4b850/"
+	if diff := cmp.Diff(got, []GPUPrecompilationConfig{want}, protocmp.Transform()); diff != "" {
 		t.Errorf("ReadConfigs() returned unexpected difference (-want, got):\n%s", diff)
 	}
 }
+
+func TestReadConfigsInRange(t *testing.T) {
+	ctx := context.Background()
+	gcs := fakes.GCSForTest(t)
+	defer gcs.Close()
+	gcs.Objects = map[string][]byte{
+		"/cos-gpu-configs-test/2022-10-05T05:51:44-0bf111fe/config.textproto": testConfigFileContents,
+		"/cos-gpu-configs-test/2022-10-05T05:51:44-0bf111fe/metadata":         testMetadataContents,
+		"/cos-gpu-configs-test/2022-10-06T13:46:00-269200f5/config.textproto": testConfigFileContents,
+		"/cos-gpu-configs-test/2022-10-06T13:46:00-269200f5/metadata":         testMetadataContents,
+		"/cos-gpu-configs-test/2022-10-07T01:29:43-e9b4b850/config.textproto": testConfigFileContents,
+		"/cos-gpu-configs-test/2022-10-07T01:29:43-e9b4b850/metadata":         testMetadataContents,
+	}
+
+	for _, tc := range []struct {
+		name     string
+		since    time.Time
+		until    time.Time
+		wantDirs []string
+		wantErr  bool
+	}{
+		{
+			name:  "ClosedWindow",
+			since: time.Date(2022, time.October, 5, 12, 0, 0, 0, time.UTC),
+			until: time.Date(2022, time.October, 7, 0, 0, 0, 0, time.UTC),
+			wantDirs: []string{
+				"gs://cos-gpu-configs-test/2022-10-06T13:46:00-269200f5/",
+			},
+		},
+		{
+			name:  "SinceIsInclusive",
+			since: time.Date(2022, time.October, 6, 13, 46, 0, 0, time.UTC),
+			until: time.Date(2022, time.October, 7, 1, 29, 43, 0, time.UTC),
+			wantDirs: []string{
+				"gs://cos-gpu-configs-test/2022-10-06T13:46:00-269200f5/",
+			},
+		},
+		{
+			name:  "OpenEnded",
+			since: time.Date(2022, time.October, 6, 0, 0, 0, 0, time.UTC),
+			wantDirs: []string{
+				"gs://cos-gpu-configs-test/2022-10-06T13:46:00-269200f5/",
+				"gs://cos-gpu-configs-test/2022-10-07T01:29:43-e9b4b850/",
+			},
+		},
+		{
+			name:  "NonUTCBounds",
+			since: time.Date(2022, time.October, 4, 22, 0, 0, 0, time.FixedZone("UTC-7", -7*60*60)),
+			until: time.Date(2022, time.October, 5, 23, 0, 0, 0, time.FixedZone("UTC-7", -7*60*60)),
+			wantDirs: []string{
+				"gs://cos-gpu-configs-test/2022-10-05T05:51:44-0bf111fe/",
+			},
+		},
+		{
+			name:  "EmptyWindow",
+			since: time.Date(2022, time.October, 8, 0, 0, 0, 0, time.UTC),
+			until: time.Date(2022, time.October, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "SinceAfterUntil",
+			since:   time.Date(2022, time.October, 7, 0, 0, 0, 0, time.UTC),
+			until:   time.Date(2022, time.October, 5, 0, 0, 0, 0, time.UTC),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ReadConfigsInRange(ctx, gcs.Client, "cos-gpu-configs-test", tc.since, tc.until, "both")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ReadConfigsInRange() succeeded; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadConfigsInRange() failed: %v", err)
+			}
+			var gotDirs []string
+			for _, config := range got {
+				gotDirs = append(gotDirs, config.ConfigDir)
+			}
+			if diff := cmp.Diff(tc.wantDirs, gotDirs, cmpopts.EquateEmpty(), cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("ReadConfigsInRange() returned unexpected config dirs (-want, got):\n%s", diff)
+			}
+		})
+	}
+}
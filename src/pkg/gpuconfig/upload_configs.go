@@ -20,16 +20,30 @@ func destDir(gcsBucket string) string {
 	return fmt.Sprintf("gs://%s/%s", gcsBucket, timestamp+"-"+uid)
 }
 
-func UploadConfigs(ctx context.Context, client *storage.Client, configs []GPUPrecompilationConfig, gcsBucket string) error {
+// Uploads each config(and its metadata) to a new directory in gcsBucket.
+// With dryRun set, the contents and GCS paths of the objects are logged
+// instead of being uploaded.
+func UploadConfigs(ctx context.Context, client *storage.Client, configs []GPUPrecompilationConfig, gcsBucket string, dryRun bool) error {
 	for _, config := range configs {
-		log.Printf("uploading gpu precompilation config for: %s, driver version %s\n", config.Version, config.DriverVersion)
-		destDir := destDir(gcsBucket)
-		if err := gcs.UploadGCSObjectString(ctx, client, proto.MarshalTextString(config.ProtoConfig), fmt.Sprintf("%s/%s", destDir, "config.textproto")); err != nil {
-			return err
+		if dryRun {
+			log.Printf("dry run: skipping upload of gpu precompilation config for: %s, driver version %s\n", config.Version, config.DriverVersion)
+		} else {
+			log.Printf("uploading gpu precompilation config for: %s, driver version %s\n", config.Version, config.DriverVersion)
 		}
+		destDir := destDir(gcsBucket)
 		metadata, _ := json.MarshalIndent(config, "", "    ")
-		if err := gcs.UploadGCSObjectString(ctx, client, string(metadata), fmt.Sprintf("%s/%s", destDir, "metadata")); err != nil {
-			return err
+		for _, object := range []struct{ name, contents string }{
+			{"config.textproto", proto.MarshalTextString(config.ProtoConfig)},
+			{"metadata", string(metadata)},
+		} {
+			objectPath := fmt.Sprintf("%s/%s", destDir, object.name)
+			if dryRun {
+				log.Printf("dry run: would upload %s with contents:\n%s\n", objectPath, object.contents)
+				continue
+			}
+			if err := gcs.UploadGCSObjectString(ctx, client, object.contents, objectPath); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
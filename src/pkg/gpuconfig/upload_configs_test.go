@@ -1,8 +1,10 @@
 package gpuconfig
 
 import (
+	"bytes"
 	"context"
 	"log"
+	"os"
 	"strings"
 	"testing"
 
@@ -34,7 +36,7 @@ func TestUploadConfig(t *testing.T) {
 	ctx := context.Background()
 	gcs := fakes.GCSForTest(t)
 	defer gcs.Close()
-	err := UploadConfigs(ctx, gcs.Client, []GPUPrecompilationConfig{testConfig}, "cos-gpu-configs-test")
+	err := UploadConfigs(ctx, gcs.Client, []GPUPrecompilationConfig{testConfig}, "cos-gpu-configs-test", false)
 	if err != nil {
 		log.Fatalf("UploadConfig() failed:%v\n", err)
 	}
@@ -54,3 +56,28 @@ func TestUploadConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestUploadConfigDryRun(t *testing.T) {
+	ctx := context.Background()
+	gcs := fakes.GCSForTest(t)
+	defer gcs.Close()
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	if err := UploadConfigs(ctx, gcs.Client, []GPUPrecompilationConfig{testConfig}, "cos-gpu-configs-test", true); err != nil {
+		t.Fatalf("UploadConfigs() failed: %v", err)
+	}
+
+	if len(gcs.Objects) != 0 {
+		t.Errorf("UploadConfigs() in dry run uploaded objects %v; want none", gcs.Objects)
+	}
+	for _, want := range []string{
+		"gs://cos-gpu-configs-test/",
+		"/config.textproto with contents:\n" + string(testConfigFileContents),
+		"/metadata with contents:\n" + string(testMetadataContents),
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("UploadConfigs() in dry run logged:\n%s\nwant it to contain:\n%s", logs.String(), want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package gpuconfig
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Kernel versions built by kernel CI on the main branch(.mNN) or a
+	// release branch(.rNN), example: 5.10.105-23.m97
+	kernelVersionRe = regexp.MustCompile(`^\d+\.\d+\.\d+-\d+\.[mr]\d+$`)
+	// NVIDIA driver versions, example: 470.82.01
+	driverVersionRe = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+)
+
+// Validates that a kernel version has the X.Y.Z-NN.mNN format used by kernel
+// CI builds, so that configs are not generated for versions the driver
+// builder rejects.
+func ValidateKernelVersion(kernelVersion string) error {
+	if !kernelVersionRe.MatchString(kernelVersion) {
+		return fmt.Errorf("invalid kernel version %q, expected a version like 5.10.105-23.m97", kernelVersion)
+	}
+	return nil
+}
+
+// Validates that each of a comma separated list of driver versions has the
+// NNN.NNN.NN format of NVIDIA driver versions.
+func ValidateDriverVersions(driverVersions string) error {
+	var invalid []string
+	for _, driverVersion := range strings.Split(driverVersions, ",") {
+		if !driverVersionRe.MatchString(driverVersion) {
+			invalid = append(invalid, fmt.Sprintf("%q", driverVersion))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid driver versions %s, expected versions like 470.82.01", strings.Join(invalid, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package gpuconfig
+
+import "testing"
+
+func TestValidateKernelVersion(t *testing.T) {
+	for _, tc := range []struct {
+		kernelVersion string
+		valid         bool
+	}{
+		{"5.10.105-23.m97", true},
+		{"5.10.107-10.r97", true},
+		{"5.15.55-34.m101", true},
+		{"5.10.100-14", false},
+		{"5.10.105-23.97", false},
+		{"5.10-23.m97", false},
+		{"5.10.105-23.m97 ", false},
+		{"", false},
+	} {
+		if err := ValidateKernelVersion(tc.kernelVersion); (err == nil) != tc.valid {
+			t.Errorf("ValidateKernelVersion(%q) = %v, want valid: %t", tc.kernelVersion, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateDriverVersions(t *testing.T) {
+	for _, tc := range []struct {
+		driverVersions string
+		valid          bool
+	}{
+		{"450.119.04", true},
+		{"450.119.04,470.150.03", true},
+		{"470.82", false},
+		{"450.119.04,", false},
+		{"450.119.04, 470.150.03", false},
+		{"R470", false},
+		{"", false},
+	} {
+		if err := ValidateDriverVersions(tc.driverVersions); (err == nil) != tc.valid {
+			t.Errorf("ValidateDriverVersions(%q) = %v, want valid: %t", tc.driverVersions, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpclient creates the HTTP clients used by the changelog and
+// findbuild tools to query Gerrit and Gitiles.
+//
+// The clients time out requests, so that a slow Gerrit or Gitiles instance
+// cannot hang a command indefinitely, and retry requests that failed
+// because of a transient error.
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
+	"go.chromium.org/luci/common/api/gerrit"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// GerritScope is the OAuth scope needed to query Gerrit and Gitiles.
+	GerritScope = gerrit.OAuthScope
+	// DefaultTimeout is the default time limit of a request.
+	DefaultTimeout = 2 * time.Minute
+	// DefaultRetries is the default number of times a failed request is
+	// retried.
+	DefaultRetries = 2
+	// DefaultRetryDelay is the default delay before the first retry of a
+	// request. The delay doubles after each retry.
+	DefaultRetryDelay = time.Second
+	// maxRetryAfter is the longest Retry-After delay that is waited for.
+	// Requests asking for a longer delay are not retried.
+	maxRetryAfter = 30 * time.Second
+)
+
+// findDefaultCredentials is stubbed in tests.
+var findDefaultCredentials = google.FindDefaultCredentials
+
+// Options configures the clients created by this package. The zero value
+// uses the defaults.
+type Options struct {
+	// Timeout limits the time of each request, including reading the
+	// response body. If it is 0, DefaultTimeout is used. If it is negative,
+	// requests do not time out.
+	Timeout time.Duration
+	// Scopes are the OAuth scopes requested for application default
+	// credentials. If it is empty, GerritScope is used.
+	Scopes []string
+	// Retries is the number of times a request that failed because of a
+	// transient error is retried. If it is 0, DefaultRetries is used. If it
+	// is negative, requests are not retried.
+	Retries int
+	// RetryDelay is the delay before the first retry of a request, which
+	// doubles after each retry. A Retry-After header in the response takes
+	// precedence. If it is 0, DefaultRetryDelay is used.
+	RetryDelay time.Duration
+	// Base is the transport that sends the requests. If it is nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+func (o *Options) timeout() time.Duration {
+	switch {
+	case o == nil || o.Timeout == 0:
+		return DefaultTimeout
+	case o.Timeout < 0:
+		return 0
+	}
+	return o.Timeout
+}
+
+func (o *Options) scopes() []string {
+	if o == nil || len(o.Scopes) == 0 {
+		return []string{GerritScope}
+	}
+	return o.Scopes
+}
+
+func (o *Options) retries() int {
+	switch {
+	case o == nil || o.Retries == 0:
+		return DefaultRetries
+	case o.Retries < 0:
+		return 0
+	}
+	return o.Retries
+}
+
+func (o *Options) retryDelay() time.Duration {
+	if o == nil || o.RetryDelay <= 0 {
+		return DefaultRetryDelay
+	}
+	return o.RetryDelay
+}
+
+func (o *Options) base() http.RoundTripper {
+	if o == nil || o.Base == nil {
+		return http.DefaultTransport
+	}
+	return o.Base
+}
+
+// New creates an HTTP client that sends requests through opts.Base without
+// adding credentials.
+func New(opts *Options) *http.Client {
+	return &http.Client{
+		Transport: newRetryTransport(opts.base(), opts),
+		Timeout:   opts.timeout(),
+	}
+}
+
+// NewWithTokenSource creates an HTTP client that authorizes requests with
+// the tokens of ts.
+func NewWithTokenSource(ts oauth2.TokenSource, opts *Options) *http.Client {
+	return &http.Client{
+		Transport: &oauth2.Transport{
+			Source: ts,
+			Base:   newRetryTransport(opts.base(), opts),
+		},
+		Timeout: opts.timeout(),
+	}
+}
+
+// NewDefault creates an HTTP client that authorizes requests with the
+// application default credentials, requesting opts.Scopes.
+func NewDefault(ctx context.Context, opts *Options) (*http.Client, error) {
+	creds, err := findDefaultCredentials(ctx, opts.scopes()...)
+	if err != nil {
+		return nil, fmt.Errorf("no application default credentials found - run `gcloud auth application-default login` and try again: %v", err)
+	}
+	return NewWithTokenSource(creds.TokenSource, opts), nil
+}
+
+// retryTransport retries requests that failed because of a transient error:
+// a network error, or a 429, 500, 502, 503 or 504 response. Only GET and
+// HEAD requests without a body are retried, since other requests may not be
+// safe to send twice.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	delay   time.Duration
+	// sleep waits for d or until ctx is done, and is stubbed in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryTransport(base http.RoundTripper, opts *Options) *retryTransport {
+	return &retryTransport{
+		base:    base,
+		retries: opts.retries(),
+		delay:   opts.retryDelay(),
+		sleep:   sleep,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// transientStatus reports whether a response status code indicates an error
+// that may not happen again.
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retryable := (req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		(req.Body == nil || req.Body == http.NoBody)
+	delay := t.delay
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if !retryable || attempt >= t.retries || req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil && !transientStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		wait := delay
+		if err == nil {
+			if retryAfter := utils.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); retryAfter > 0 {
+				if retryAfter > maxRetryAfter {
+					return resp, nil
+				}
+				wait = retryAfter
+			}
+			log.Debugf("retrying %s %s in %v after status %s", req.Method, req.URL.Redacted(), wait, resp.Status)
+			resp.Body.Close()
+		} else {
+			log.Debugf("retrying %s %s in %v after error: %v", req.Method, req.URL.Redacted(), wait, err)
+		}
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		delay *= 2
+	}
+}
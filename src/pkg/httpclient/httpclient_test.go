@@ -0,0 +1,285 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := map[string]struct {
+		Opts     *Options
+		Expected time.Duration
+	}{
+		"nil options": {nil, DefaultTimeout},
+		"default":     {&Options{}, DefaultTimeout},
+		"custom":      {&Options{Timeout: 10 * time.Second}, 10 * time.Second},
+		"disabled":    {&Options{Timeout: -1}, 0},
+	}
+	for name, test := range tests {
+		if got := New(test.Opts).Timeout; got != test.Expected {
+			t.Errorf("test %q failed: expected timeout %v, got %v", name, test.Expected, got)
+		}
+		if got := NewWithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{}), test.Opts).Timeout; got != test.Expected {
+			t.Errorf("test %q failed: expected token client timeout %v, got %v", name, test.Expected, got)
+		}
+	}
+}
+
+func TestSlowServerTimesOut(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := New(&Options{Timeout: 50 * time.Millisecond, Retries: -1})
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected timeout error, got status %s", resp.Status)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected request to time out quickly, took %v", elapsed)
+	}
+}
+
+func TestNewDefaultScopes(t *testing.T) {
+	defer func(orig func(context.Context, ...string) (*google.Credentials, error)) {
+		findDefaultCredentials = orig
+	}(findDefaultCredentials)
+
+	tests := map[string]struct {
+		Opts     *Options
+		Expected []string
+	}{
+		"nil options":   {nil, []string{GerritScope}},
+		"default scope": {&Options{}, []string{GerritScope}},
+		"custom scopes": {
+			&Options{Scopes: []string{GerritScope, "https://www.googleapis.com/auth/devstorage.read_only"}},
+			[]string{GerritScope, "https://www.googleapis.com/auth/devstorage.read_only"},
+		},
+	}
+	for name, test := range tests {
+		var scopes []string
+		findDefaultCredentials = func(ctx context.Context, s ...string) (*google.Credentials, error) {
+			scopes = s
+			return &google.Credentials{TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})}, nil
+		}
+		client, err := NewDefault(context.Background(), test.Opts)
+		if err != nil {
+			t.Errorf("test %q failed: unexpected error: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(scopes, test.Expected) {
+			t.Errorf("test %q failed: expected scopes %v, got %v", name, test.Expected, scopes)
+		}
+		if client.Timeout != test.Opts.timeout() {
+			t.Errorf("test %q failed: expected timeout %v, got %v", name, test.Opts.timeout(), client.Timeout)
+		}
+	}
+
+	findDefaultCredentials = func(ctx context.Context, s ...string) (*google.Credentials, error) {
+		return nil, errors.New("no credentials")
+	}
+	if _, err := NewDefault(context.Background(), nil); err == nil {
+		t.Errorf("expected error without default credentials, got nil")
+	}
+}
+
+func TestNewWithTokenSource(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+	client := NewWithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}), nil)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if expected := "Bearer token"; header != expected {
+		t.Errorf("expected Authorization header %q, got %q", expected, header)
+	}
+}
+
+// fakeTransport returns the responses of statuses in order, or errors for
+// negative statuses.
+type fakeTransport struct {
+	statuses   []int
+	retryAfter string
+	requests   int
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := f.statuses[f.requests]
+	f.requests++
+	if status < 0 {
+		return nil, errors.New("connection reset")
+	}
+	header := http.Header{}
+	if f.retryAfter != "" {
+		header.Set("Retry-After", f.retryAfter)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     header,
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+func TestRetries(t *testing.T) {
+	tests := map[string]struct {
+		Method         string
+		Body           string
+		Statuses       []int
+		RetryAfter     string
+		Retries        int
+		ExpectedStatus int
+		ExpectedErr    bool
+		ExpectedSleeps []time.Duration
+	}{
+		"success": {
+			Method:         http.MethodGet,
+			Statuses:       []int{200},
+			ExpectedStatus: 200,
+		},
+		"transient errors": {
+			Method:         http.MethodGet,
+			Statuses:       []int{503, -1, 200},
+			ExpectedStatus: 200,
+			ExpectedSleeps: []time.Duration{time.Second, 2 * time.Second},
+		},
+		"retries exhausted": {
+			Method:         http.MethodGet,
+			Statuses:       []int{502, 502, 502},
+			ExpectedStatus: 502,
+			ExpectedSleeps: []time.Duration{time.Second, 2 * time.Second},
+		},
+		"error after retries": {
+			Method:         http.MethodGet,
+			Statuses:       []int{-1, -1, -1},
+			ExpectedErr:    true,
+			ExpectedSleeps: []time.Duration{time.Second, 2 * time.Second},
+		},
+		"client error": {
+			Method:         http.MethodGet,
+			Statuses:       []int{404},
+			ExpectedStatus: 404,
+		},
+		"rate limited with Retry-After": {
+			Method:         http.MethodGet,
+			Statuses:       []int{429, 200},
+			RetryAfter:     "5",
+			ExpectedStatus: 200,
+			ExpectedSleeps: []time.Duration{5 * time.Second},
+		},
+		"Retry-After too long": {
+			Method:         http.MethodGet,
+			Statuses:       []int{429},
+			RetryAfter:     "3600",
+			ExpectedStatus: 429,
+		},
+		"POST not retried": {
+			Method:         http.MethodPost,
+			Body:           "data",
+			Statuses:       []int{503},
+			ExpectedStatus: 503,
+		},
+		"retries disabled": {
+			Method:         http.MethodGet,
+			Statuses:       []int{503},
+			Retries:        -1,
+			ExpectedStatus: 503,
+		},
+		"more retries": {
+			Method:         http.MethodGet,
+			Statuses:       []int{500, 500, 500, 200},
+			Retries:        3,
+			ExpectedStatus: 200,
+			ExpectedSleeps: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeTransport{statuses: test.Statuses, retryAfter: test.RetryAfter}
+			transport := newRetryTransport(fake, &Options{Retries: test.Retries})
+			var sleeps []time.Duration
+			transport.sleep = func(ctx context.Context, d time.Duration) error {
+				sleeps = append(sleeps, d)
+				return nil
+			}
+			req, err := http.NewRequest(test.Method, "https://cos-review.googlesource.com/changes/", strings.NewReader(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.Body == "" {
+				req.Body = nil
+			}
+			resp, err := transport.RoundTrip(req)
+			if test.ExpectedErr {
+				if err == nil {
+					t.Errorf("expected error, got status %d", resp.StatusCode)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if resp.StatusCode != test.ExpectedStatus {
+				t.Errorf("expected status %d, got %d", test.ExpectedStatus, resp.StatusCode)
+			}
+			if fake.requests != len(test.Statuses) {
+				t.Errorf("expected %d requests, got %d", len(test.Statuses), fake.requests)
+			}
+			if !reflect.DeepEqual(sleeps, test.ExpectedSleeps) {
+				t.Errorf("expected sleeps %v, got %v", test.ExpectedSleeps, sleeps)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	fake := &fakeTransport{statuses: []int{503, 200}}
+	transport := newRetryTransport(fake, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	transport.sleep = sleep
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://cos-review.googlesource.com/changes/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := transport.RoundTrip(req); err == nil && resp.StatusCode == 200 {
+		t.Errorf("expected canceled request not to be retried")
+	}
+	if fake.requests != 1 {
+		t.Errorf("expected 1 request, got %d", fake.requests)
+	}
+}
@@ -3,6 +3,8 @@ package modules
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"cos.googlesource.com/cos/tools.git/src/pkg/utils"
 )
@@ -15,11 +17,11 @@ func NewModuleParameters() ModuleParameters {
 
 func (i *ModuleParameters) Set(value string) error {
 	module, keyValue, found := utils.Cut(value, ".")
-	if !found {
+	if !found || len(module) == 0 || strings.ContainsAny(module, " \t") {
 		return fmt.Errorf("modules: cannot parse module parameter %s, must be of form module.key=value", value)
 	}
 	moduleParamKey, moduleParamVal, found := utils.Cut(keyValue, "=")
-	if !found || len(moduleParamKey) == 0 || len(moduleParamVal) == 0 {
+	if !found || len(moduleParamKey) == 0 || len(moduleParamVal) == 0 || strings.ContainsAny(moduleParamKey, " \t") {
 		return fmt.Errorf("modules: cannot parse module parameter %s, must be of form module.key=value", value)
 	}
 	(*i)[module] = append((*i)[module], keyValue)
@@ -29,3 +31,22 @@ func (i *ModuleParameters) Set(value string) error {
 func (i *ModuleParameters) String() string {
 	return ""
 }
+
+// SetFromFile reads module parameters from a file with one module.key=value
+// parameter per line. Empty lines and lines starting with '#' are ignored.
+func (i *ModuleParameters) SetFromFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("modules: failed to read module parameters file %s: %v", path, err)
+	}
+	for n, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := i.Set(line); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n+1, err)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,12 @@
 package modules
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestSetModuleParameters(t *testing.T) {
@@ -16,6 +21,9 @@ func TestSetModuleParameters(t *testing.T) {
 		{"param incorrect module", "nvidia,NVreg_EnableGpuFirmware=0", "", "", true},
 		{"param incorrect key", "nvidia.NVreg_EnableGpuFirmware", "", "", true},
 		{"param incorrect value", "nvidia.NVreg_EnableGpuFirmware=", "", "", true},
+		{"param empty module", ".NVreg_EnableGpuFirmware=0", "", "", true},
+		{"param space in key", "nvidia.NVreg_Enable GpuFirmware=0", "", "", true},
+		{"param space in value", "nvidia.NVreg_RegistryDwords=a=1; b=2", "nvidia", "NVreg_RegistryDwords=a=1; b=2", false},
 	} {
 		t.Run(tc.testName, func(t *testing.T) {
 			m := NewModuleParameters()
@@ -34,3 +42,52 @@ func TestSetModuleParameters(t *testing.T) {
 		})
 	}
 }
+
+func TestSetModuleParametersFromFile(t *testing.T) {
+	for _, tc := range []struct {
+		testName     string
+		content      string
+		expectParams ModuleParameters
+		expectError  bool
+	}{
+		{
+			"params",
+			"# GPU firmware\nnvidia.NVreg_EnableGpuFirmware=0\n\n  nvidia_uvm.uvm_debug_prints=1  \nnvidia.NVreg_OpenRmEnableUnsupportedGpus=1\n",
+			ModuleParameters{
+				"nvidia":     {"NVreg_EnableGpuFirmware=0", "NVreg_OpenRmEnableUnsupportedGpus=1"},
+				"nvidia_uvm": {"uvm_debug_prints=1"},
+			},
+			false,
+		},
+		{"empty", "# nothing to set\n", ModuleParameters{}, false},
+		{"invalid line", "nvidia.NVreg_EnableGpuFirmware=0\nnvidia_uvm\n", nil, true},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			testDir, err := ioutil.TempDir("", "testing")
+			if err != nil {
+				t.Fatalf("Failed to create tempdir: %v", err)
+			}
+			defer os.RemoveAll(testDir)
+			path := filepath.Join(testDir, "module-params")
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("Failed to write module parameters file: %v", err)
+			}
+			m := NewModuleParameters()
+			err = m.SetFromFile(path)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tc.expectError {
+				return
+			}
+			if diff := cmp.Diff(tc.expectParams, m); diff != "" {
+				t.Errorf("Unexpected parameters (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	m := NewModuleParameters()
+	if err := m.SetFromFile("/does/not/exist"); err == nil {
+		t.Errorf("SetFromFile() succeeded for a missing file")
+	}
+}
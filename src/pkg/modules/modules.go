@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	log "github.com/golang/glog"
@@ -28,6 +29,10 @@ const (
 
 var (
 	execCommand = exec.Command
+
+	// ErrModuleInUse indicates that a kernel module could not be unloaded
+	// because it is in use.
+	ErrModuleInUse = errors.New("module is in use")
 )
 
 // LoadModule loads a given kernel module to kernel.
@@ -45,6 +50,28 @@ func LoadModule(moduleName, modulePath string, moduleParams ModuleParameters) er
 	return nil
 }
 
+// UnloadModule unloads a given kernel module from kernel. It does nothing if
+// the module is not loaded, and fails with ErrModuleInUse if the module is
+// used by other modules or by processes.
+func UnloadModule(moduleName string) error {
+	useCount, loaded, err := moduleUseCount(moduleName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unload module %s", moduleName)
+	}
+	if !loaded {
+		return nil
+	}
+	if useCount > 0 {
+		return errors.Wrapf(ErrModuleInUse, "failed to unload module %s (used by %d)", moduleName, useCount)
+	}
+	cmd := execCommand("rmmod", moduleName)
+	log.Infof("unloading module: %v", cmd)
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "failed to run command `rmmod %s`", moduleName)
+	}
+	return nil
+}
+
 // UpdateHostLdCache updates the ld cache on host.
 func UpdateHostLdCache(hostRootDir, moduleLibDir string) error {
 	log.Info("Updating host's ld cache")
@@ -66,6 +93,38 @@ func UpdateHostLdCache(hostRootDir, moduleLibDir string) error {
 	return nil
 }
 
+// RemoveHostLdCacheEntry reverts UpdateHostLdCache by removing moduleLibDir
+// from the ld cache on host. It does nothing if moduleLibDir is not in the
+// host's ld.so.conf.
+func RemoveHostLdCacheEntry(hostRootDir, moduleLibDir string) error {
+	log.Info("Removing GPU driver libraries from host's ld cache")
+	ldPath := filepath.Join(hostRootDir, "/etc/ld.so.conf")
+	content, err := ioutil.ReadFile(ldPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", ldPath)
+	}
+	var kept []string
+	lines := strings.SplitAfter(string(content), "\n")
+	for _, line := range lines {
+		if strings.TrimSpace(line) != moduleLibDir {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == len(lines) {
+		log.Infof("%s is not in %s, not updating host's ld cache", moduleLibDir, ldPath)
+		return nil
+	}
+	if err := ioutil.WriteFile(ldPath, []byte(strings.Join(kept, "")), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", ldPath)
+	}
+
+	if err := execCommand("ldconfig", "-r", hostRootDir).Run(); err != nil {
+		return errors.Wrapf(err, "failed to run `ldconfig -r %s`", hostRootDir)
+	}
+
+	return nil
+}
+
 // LoadPublicKey loads the given public key to system keyring.
 func LoadPublicKey(keyName, keyPath, keyring string) error {
 	log.Infof("Loading %s to keyring %s", keyName, keyring)
@@ -151,18 +210,33 @@ func AppendSignature(outfilePath, modulefilePath, sigfilePath string) error {
 }
 
 func isModuleLoaded(moduleName string) (bool, error) {
+	_, loaded, err := moduleUseCount(moduleName)
+	return loaded, err
+}
+
+// moduleUseCount returns the number of users of a kernel module, as listed by
+// lsmod, and whether the module is loaded.
+func moduleUseCount(moduleName string) (int, bool, error) {
 	out, err := execCommand("lsmod").Output()
 	if err != nil {
-		return false, errors.Wrap(err, "failed to run command `lsmod`")
+		return 0, false, errors.Wrap(err, "failed to run command `lsmod`")
 	}
 
 	for _, line := range strings.Split(string(out), "\n") {
 		fields := strings.Fields(line)
-		if len(fields) > 0 && fields[0] == moduleName {
-			return true, nil
+		if len(fields) == 0 || fields[0] != moduleName {
+			continue
+		}
+		if len(fields) < 3 {
+			return 0, true, nil
+		}
+		useCount, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, true, errors.Wrapf(err, "failed to parse use count of module %s from `lsmod`", moduleName)
 		}
+		return useCount, true, nil
 	}
-	return false, nil
+	return 0, false, nil
 }
 
 func loadModule(modulePath string, moduleParams []string) error {
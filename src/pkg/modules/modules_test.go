@@ -1,10 +1,12 @@
 package modules
 
 import (
+	stderrors "errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"testing"
 
@@ -130,3 +132,83 @@ func TestAppendSignature(t *testing.T) {
 			expectedBytes, signedModuleBytes, diff)
 	}
 }
+
+func TestUnloadModule(t *testing.T) {
+	execCommand = fakeExecCommand
+	defer func() {
+		execCommand = exec.Command
+		mockCmdExitStatus = 0
+	}()
+
+	lsmod := "Module\tSize\tUsed by\nnvidia_uvm\t1474560\t0\nnvidia\t56737792\t1 nvidia_uvm\n"
+	for _, tc := range []struct {
+		testName    string
+		moduleName  string
+		expectErr   bool
+		expectInUse bool
+	}{
+		{"TestModuleUnused", "nvidia_uvm", false, false},
+		{"TestModuleInUse", "nvidia", true, true},
+		{"TestModuleNotLoaded", "nvidia_drm", false, false},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			mockCmdStdout = lsmod
+			err := UnloadModule(tc.moduleName)
+			if gotErr := err != nil; gotErr != tc.expectErr {
+				t.Errorf("Unexpected error, want error: %v, got: %v", tc.expectErr, err)
+			}
+			if gotInUse := stderrors.Is(err, ErrModuleInUse); gotInUse != tc.expectInUse {
+				t.Errorf("Unexpected error, want ErrModuleInUse: %v, got: %v", tc.expectInUse, err)
+			}
+		})
+	}
+}
+
+func TestRemoveHostLdCacheEntry(t *testing.T) {
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	for _, tc := range []struct {
+		testName       string
+		ldConf         string
+		expectedLdConf string
+	}{
+		{"TestEntryRemoved",
+			"include /etc/ld.so.conf.d/*.conf\n/var/lib/nvidia/lib64\n/usr/local/lib\n",
+			"include /etc/ld.so.conf.d/*.conf\n/usr/local/lib\n",
+		},
+		{"TestRepeatedEntriesRemoved",
+			"/var/lib/nvidia/lib64\ninclude /etc/ld.so.conf.d/*.conf\n/var/lib/nvidia/lib64\n",
+			"include /etc/ld.so.conf.d/*.conf\n",
+		},
+		{"TestEntryMissing",
+			"include /etc/ld.so.conf.d/*.conf\n/var/lib/nvidia/lib64/old\n",
+			"include /etc/ld.so.conf.d/*.conf\n/var/lib/nvidia/lib64/old\n",
+		},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			hostRootDir, err := ioutil.TempDir("", "testing")
+			if err != nil {
+				t.Fatalf("Failed to create tempdir: %v", err)
+			}
+			defer os.RemoveAll(hostRootDir)
+			ldPath := filepath.Join(hostRootDir, "etc", "ld.so.conf")
+			if err := os.MkdirAll(filepath.Dir(ldPath), 0755); err != nil {
+				t.Fatalf("Failed to create dir: %v", err)
+			}
+			if err := ioutil.WriteFile(ldPath, []byte(tc.ldConf), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", ldPath, err)
+			}
+			if err := RemoveHostLdCacheEntry(hostRootDir, "/var/lib/nvidia/lib64"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got, err := ioutil.ReadFile(ldPath)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", ldPath, err)
+			}
+			if diff := cmp.Diff(tc.expectedLdConf, string(got)); diff != "" {
+				t.Errorf("Unexpected ld.so.conf (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -17,6 +17,14 @@ import (
 
 const defaultCommandTimeout = 300 * time.Second
 
+// Log sinks supported by LoggerOpts.LogSink.
+const (
+	// CloudLogSink writes logs to Google Cloud Logging backend.
+	CloudLogSink = "cloud"
+	// FileLogSink writes logs to a local file, see FileStructuredLogger.
+	FileLogSink = "file"
+)
+
 // componentInfo contains Name and Metrics fields similar to Name and Metrics
 // fields that each profiler component has. componentInfo helps to export
 // component fields to log them to Google Cloud Logging backend.
@@ -46,16 +54,32 @@ type ShellCmdOpts struct {
 type LoggerOpts struct {
 	// Specifies the project ID to write logs to.
 	ProjID string `json:"ProjID"`
+	// Specifies where logs are written: CloudLogSink (the default if unset)
+	// or FileLogSink.
+	LogSink string `json:"LogSink"`
 	// Specifies the commands to run mapped with their options.
 	ShCmds []ShellCmdOpts `json:"ShCmds"`
 	// Specifies the number of times to run the profiler.
 	ProfilerCount int `json: "ProfilerCount"`
 	// Specifies the interval the profiler will run.
 	ProfilerInterval time.Duration `json: "ProfilerInterval"`
+	// Specifies the values above which the components are considered
+	// saturated. Unset thresholds use their default value.
+	Thresholds profiler.Thresholds `json:"Thresholds"`
 	// Components on which to run profiler. It may contain CPU(s), Memory, etc.
 	Components []profiler.Component
 	// ProfilerCmds field specifies additional options needed to run the profiler
 	ProfilerCmds []profiler.Command
+	// Specifies whether the severity of USE report log entries depends on
+	// the USE metrics: logging.Error if a component has errors,
+	// logging.Warning if a component is saturated and logging.Debug
+	// otherwise. If false, USE reports are always logged at logging.Debug.
+	SeverityFromMetrics bool `json:"SeverityFromMetrics"`
+	// OnUSEReport, if set, is called with every USE report generated by
+	// LogProfilerReport, including the reports of a failed collection,
+	// before it is logged. It lets callers write the same reports to
+	// another sink without running the profiler again.
+	OnUSEReport func(useReport *profiler.USEReport) error `json:"-"`
 }
 
 // TextLogger defines the method required to log a text string to Google Cloud
@@ -82,9 +106,16 @@ type StructuredLogger interface {
 // configuration. Similarly, to run the profiler, the user must specify a
 // ProfilerCount configuration if a ProfilerInterval configuration was specified.
 func (l *LoggerOpts) Validate() error {
-	// A valid project ID must be provided.
-	if l.ProjID == "" {
-		return fmt.Errorf("invalid Logger options: cannot run profiler tool if the Cloud Logging Project ID is not set")
+	switch l.LogSink {
+	case "", CloudLogSink:
+		// A valid project ID must be provided.
+		if l.ProjID == "" {
+			return fmt.Errorf("invalid Logger options: cannot run profiler tool if the Cloud Logging Project ID is not set")
+		}
+	case FileLogSink:
+		// Logs are written to a local file, so no project is needed.
+	default:
+		return fmt.Errorf("invalid Logger options: unknown log sink %q, must be %q or %q", l.LogSink, CloudLogSink, FileLogSink)
 	}
 	// To run the profiler, the profilerCount configuration has to be set if
 	// profilerInterval configuration is set.
@@ -188,10 +219,33 @@ func logShellCommand(g StructuredLogger, cmdTimeOut time.Duration, cmd string, a
 	return nil
 }
 
+// useReportSeverity returns the severity of a USEReport log entry based on
+// its USE metrics: logging.Error if any component has errors,
+// logging.Warning if any component is saturated and logging.Debug otherwise.
+func useReportSeverity(useReport *profiler.USEReport) logging.Severity {
+	severity := logging.Debug
+	for _, c := range useReport.Components {
+		metrics := c.USEMetrics()
+		if metrics == nil {
+			continue
+		}
+		if metrics.Errors > 0 {
+			return logging.Error
+		}
+		if metrics.Saturation {
+			severity = logging.Warning
+		}
+	}
+	return severity
+}
+
 // logUSEReport writes a USEReport to a logging backend by calling the `Log`
 // method defined by the StructuredLogger interface. To log a JSON Payload to
 // Google Cloud Logging backend, pass in an instance of type *logging.Logger.
-func logUSEReport(g StructuredLogger, useReport *profiler.USEReport) error {
+// If severityFromMetrics is true, the severity of the log entry is based on
+// the USE metrics of the report, see useReportSeverity. Otherwise, it is
+// logging.Debug.
+func logUSEReport(g StructuredLogger, useReport *profiler.USEReport, severityFromMetrics bool) error {
 	if useReport == nil {
 		return fmt.Errorf("cannot log an empty USEReport")
 	}
@@ -210,12 +264,16 @@ func logUSEReport(g StructuredLogger, useReport *profiler.USEReport) error {
 		},
 		Severity: logging.Debug,
 	}
+	if severityFromMetrics {
+		entry.Severity = useReportSeverity(useReport)
+	}
 	g.Log(entry)
 	return nil
 }
 
 // LogProfilerReport logs the output of logShellCommand and logUSEReport
-// functions to a logger of type *logging.Logger. It takes as input the logger
+// functions to a logger of type *logging.Logger, or *FileStructuredLogger to
+// write them to a local file. It takes as input the logger
 // itself, and a pointer to a LoggerOpts struct that contains the shell command
 // to execute, the number of time to execute that shell command, the interval
 // between shell command executions, the time limit for shell command execution,
@@ -231,8 +289,6 @@ func LogProfilerReport(g StructuredLogger, opts *LoggerOpts) error {
 		return err
 	}
 	log.Info("Done validating logger options.")
-	// Ensure logging entries are written to the cloud logging backend.
-	defer g.Flush()
 	for _, shCmd := range opts.ShCmds {
 		// Only log shell command if the user specified a command.
 		if len(shCmd.Command) == 0 {
@@ -263,11 +319,16 @@ func LogProfilerReport(g StructuredLogger, opts *LoggerOpts) error {
 	// set the counter to a different number.
 	for i := 0; i < opts.ProfilerCount; i++ {
 		useReport, err := profiler.GenerateUSEReport(opts.Components, opts.ProfilerCmds)
+		if opts.OnUSEReport != nil {
+			if err := opts.OnUSEReport(&useReport); err != nil {
+				errArr = append(errArr, err)
+			}
+		}
 		if err != nil {
 			errArr = append(errArr, fmt.Errorf("cannot run profiler.GenerateUSEReport(%v) = %v", opts.Components, err))
 			continue
 		}
-		if err := logUSEReport(g, &useReport); err != nil {
+		if err := logUSEReport(g, &useReport, opts.SeverityFromMetrics); err != nil {
 			errArr = append(errArr, err)
 			continue
 		}
@@ -275,5 +336,9 @@ func LogProfilerReport(g StructuredLogger, opts *LoggerOpts) error {
 		time.Sleep(opts.ProfilerInterval)
 	}
 	log.Info("Done running profiler.")
+	// Ensure logging entries are written to the logging backend.
+	if err := g.Flush(); err != nil {
+		errArr = append(errArr, fmt.Errorf("failed to flush log entries: %v", err))
+	}
 	return checkLogError(emptyCmd, errArr)
 }
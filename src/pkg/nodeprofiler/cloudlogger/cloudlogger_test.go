@@ -439,6 +439,64 @@ func TestTableLogProfilerReport(t *testing.T) {
 			wantOutput: nil,
 			wantErr:    true,
 		},
+		{
+			name: "file log sink without project ID.",
+			input: &LoggerOpts{
+				LogSink:          FileLogSink,
+				ProfilerCount:    1,
+				ProfilerInterval: 0 * time.Second,
+				Components:       components,
+				ProfilerCmds:     cmds,
+			},
+			wantOutput: []logging.Entry{
+				{
+					Payload: struct {
+						Components []componentInfo
+						Analysis   string
+					}{
+						Components: cInfos,
+						Analysis:   useReport.Analysis,
+					},
+					Severity: logging.Debug,
+				}},
+			wantErr: false,
+		},
+		{
+			name: "severity from metrics of saturated components.",
+			input: &LoggerOpts{
+				ProjID:              "cos-interns-playground",
+				ProfilerCount:       1,
+				ProfilerInterval:    0 * time.Second,
+				Components:          components,
+				ProfilerCmds:        cmds,
+				SeverityFromMetrics: true,
+			},
+			wantOutput: []logging.Entry{
+				{
+					Payload: struct {
+						Components []componentInfo
+						Analysis   string
+					}{
+						Components: cInfos,
+						Analysis:   useReport.Analysis,
+					},
+					Severity: logging.Warning,
+				}},
+			wantErr: false,
+		},
+		{
+			name: "invalid logger options: unknown log sink.",
+			input: &LoggerOpts{
+				ProjID:           "cos-interns-playground",
+				LogSink:          "stdout",
+				ProfilerCount:    1,
+				ProfilerInterval: 0 * time.Second,
+				Components:       components,
+				ProfilerCmds:     cmds,
+			},
+			wantOutput: nil,
+			wantErr:    true,
+		},
 	}
 	for _, test := range tests {
 		var f *fakeStructuredLogger = &fakeStructuredLogger{}
@@ -453,3 +511,72 @@ func TestTableLogProfilerReport(t *testing.T) {
 		}
 	}
 }
+
+// Every USE report logged is also passed to OnUSEReport, so the profiler
+// runs once for all sinks.
+func TestLogProfilerReportOnUSEReport(t *testing.T) {
+	components, cmds := generateFakeProfilerOpts()
+	var reports []*profiler.USEReport
+	opts := &LoggerOpts{
+		LogSink:       FileLogSink,
+		ProfilerCount: 2,
+		Components:    components,
+		ProfilerCmds:  cmds,
+		OnUSEReport: func(useReport *profiler.USEReport) error {
+			reports = append(reports, useReport)
+			return nil
+		},
+	}
+	f := &fakeStructuredLogger{}
+	if err := LogProfilerReport(f, opts); err != nil {
+		t.Fatalf("LogProfilerReport(%v, %+v) = %v, want nil", f, opts, err)
+	}
+	if len(reports) != opts.ProfilerCount || len(f.logged) != opts.ProfilerCount {
+		t.Errorf("LogProfilerReport(%v, %+v) passed %d reports to OnUSEReport and logged %d, want %d each", f, opts, len(reports), len(f.logged), opts.ProfilerCount)
+	}
+}
+
+// The severity of a USE report is Error if any component has errors,
+// Warning if any component is saturated and Debug otherwise.
+func TestUSEReportSeverity(t *testing.T) {
+	var tests = []struct {
+		name    string
+		metrics []profiler.USEMetrics
+		want    logging.Severity
+	}{
+		{
+			name:    "healthy components",
+			metrics: []profiler.USEMetrics{{Utilization: 7}, {Utilization: 50}},
+			want:    logging.Debug,
+		},
+		{
+			name:    "saturated component",
+			metrics: []profiler.USEMetrics{{Utilization: 7}, {Utilization: 99, Saturation: true}},
+			want:    logging.Warning,
+		},
+		{
+			name:    "component with errors",
+			metrics: []profiler.USEMetrics{{Errors: 2}, {Utilization: 99}},
+			want:    logging.Error,
+		},
+		{
+			name:    "errors take precedence over saturation",
+			metrics: []profiler.USEMetrics{{Saturation: true}, {Errors: 1}},
+			want:    logging.Error,
+		},
+		{
+			name: "no components",
+			want: logging.Debug,
+		},
+	}
+	for _, test := range tests {
+		var components []profiler.Component
+		for i := range test.metrics {
+			components = append(components, &fakeCPU{CPUName: "fakeCPU", Metrics: &test.metrics[i]})
+		}
+		got := useReportSeverity(&profiler.USEReport{Components: components})
+		if got != test.want {
+			t.Errorf("%s: useReportSeverity() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
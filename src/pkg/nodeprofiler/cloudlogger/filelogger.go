@@ -0,0 +1,91 @@
+package cloudlogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// fileEntry is the JSON encoding of a log entry written by
+// FileStructuredLogger.
+type fileEntry struct {
+	Timestamp time.Time
+	Severity  string
+	Payload   interface{}
+}
+
+// FileStructuredLogger is a StructuredLogger that writes log entries to a
+// local file instead of Google Cloud Logging backend, so that the profiler
+// can be run and validated without a GCP project. Like *logging.Logger, Log
+// only buffers entries and Flush writes the buffered entries. Each entry is
+// appended to the file as a single line of JSON.
+type FileStructuredLogger struct {
+	path   string
+	mu     sync.Mutex
+	buffer []logging.Entry
+}
+
+// NewFileStructuredLogger returns a FileStructuredLogger that appends log
+// entries to the file at path, creating it if needed.
+func NewFileStructuredLogger(path string) *FileStructuredLogger {
+	return &FileStructuredLogger{path: path}
+}
+
+// Log buffers a log entry until the next call to Flush. As with
+// *logging.Logger, the timestamp of the entry is set to the current time if
+// it is not set.
+func (f *FileStructuredLogger) Log(entry logging.Entry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buffer = append(f.buffer, entry)
+}
+
+// Flush appends the buffered log entries to the file and blocks until they
+// are written. Entries that cannot be encoded to JSON are dropped and
+// reported in the returned error. If the file cannot be written, the
+// entries stay buffered so that a later call to Flush can write them.
+func (f *FileStructuredLogger) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.buffer) == 0 {
+		return nil
+	}
+	var data bytes.Buffer
+	var encodeErr error
+	for _, entry := range f.buffer {
+		line, err := json.Marshal(fileEntry{
+			Timestamp: entry.Timestamp,
+			Severity:  entry.Severity.String(),
+			Payload:   entry.Payload,
+		})
+		if err != nil {
+			if encodeErr == nil {
+				encodeErr = fmt.Errorf("failed to encode log entry: %v", err)
+			}
+			continue
+		}
+		data.Write(line)
+		data.WriteByte('\n')
+	}
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %v", f.path, err)
+	}
+	if _, err := file.Write(data.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write log entries to %s: %v", f.path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write log entries to %s: %v", f.path, err)
+	}
+	f.buffer = nil
+	return encodeErr
+}
@@ -0,0 +1,104 @@
+package cloudlogger
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/google/go-cmp/cmp"
+)
+
+// Log entries are only written to the file when flushed, and each flush
+// appends the buffered entries as JSON lines.
+func TestFileStructuredLogger(t *testing.T) {
+	timestamp := time.Date(2021, time.July, 21, 9, 59, 30, 0, time.UTC)
+	var tests = []struct {
+		name       string
+		entries    [][]logging.Entry
+		wantOutput string
+		wantErr    bool
+	}{
+		{
+			name: "single flush",
+			entries: [][]logging.Entry{
+				{
+					{Timestamp: timestamp, Payload: struct{ CommandName string }{"uptime"}, Severity: logging.Debug},
+					{Timestamp: timestamp, Payload: "hello", Severity: logging.Info},
+				},
+			},
+			wantOutput: `{"Timestamp":"2021-07-21T09:59:30Z","Severity":"Debug","Payload":{"CommandName":"uptime"}}
+{"Timestamp":"2021-07-21T09:59:30Z","Severity":"Info","Payload":"hello"}
+`,
+		},
+		{
+			name: "multiple flushes append entries",
+			entries: [][]logging.Entry{
+				{{Timestamp: timestamp, Payload: "first", Severity: logging.Debug}},
+				{},
+				{{Timestamp: timestamp, Payload: "second", Severity: logging.Debug}},
+			},
+			wantOutput: `{"Timestamp":"2021-07-21T09:59:30Z","Severity":"Debug","Payload":"first"}
+{"Timestamp":"2021-07-21T09:59:30Z","Severity":"Debug","Payload":"second"}
+`,
+		},
+		{
+			name: "entry that cannot be encoded is dropped",
+			entries: [][]logging.Entry{
+				{
+					{Timestamp: timestamp, Payload: math.NaN(), Severity: logging.Debug},
+					{Timestamp: timestamp, Payload: "valid", Severity: logging.Debug},
+				},
+			},
+			wantOutput: `{"Timestamp":"2021-07-21T09:59:30Z","Severity":"Debug","Payload":"valid"}
+`,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "profiler.log")
+		f := NewFileStructuredLogger(path)
+		var gotErr bool
+		for _, entries := range test.entries {
+			for _, entry := range entries {
+				f.Log(entry)
+			}
+			if err := f.Flush(); err != nil {
+				gotErr = true
+			}
+		}
+		if gotErr != test.wantErr {
+			t.Errorf("%s: FileStructuredLogger.Flush() gotErr %t, wantErr %t", test.name, gotErr, test.wantErr)
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: failed to read log file %v: %v", test.name, path, err)
+		}
+		if diff := cmp.Diff(string(got), test.wantOutput); diff != "" {
+			t.Errorf("%s: got mismatch between got and want (-got, +want): \n diff %s", test.name, diff)
+		}
+	}
+}
+
+// Log entries are buffered until Flush is called, and entries without a
+// timestamp get the current time.
+func TestFileStructuredLoggerBuffersUntilFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiler.log")
+	f := NewFileStructuredLogger(path)
+	before := time.Now()
+	f.Log(logging.Entry{Payload: "buffered", Severity: logging.Debug})
+	if _, err := ioutil.ReadFile(path); err == nil {
+		t.Errorf("log file %v was written before Flush", path)
+	}
+	if len(f.buffer) != 1 || f.buffer[0].Timestamp.Before(before) {
+		t.Errorf("FileStructuredLogger.Log did not buffer the entry with the current time, buffer: %v", f.buffer)
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatalf("FileStructuredLogger.Flush() = %v", err)
+	}
+	if len(f.buffer) != 0 {
+		t.Errorf("FileStructuredLogger.Flush() did not empty the buffer: %v", f.buffer)
+	}
+}
@@ -2,9 +2,14 @@ package profiler
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"cos.googlesource.com/cos/tools.git/src/pkg/nodeprofiler/utils"
 
@@ -277,3 +282,350 @@ func (fs *df) Run() (map[string][]string, error) {
 	output, err := utils.ParseColumns(lines, allTitles, fs.titles...)
 	return output, err
 }
+
+// loadAvg represents a reader of the system load averages in /proc/loadavg.
+type loadAvg struct {
+	name string
+	// path is the path of the load averages file.
+	path string
+}
+
+// NewLoadAvg function helps to initialize a loadAvg structure.
+func NewLoadAvg(name string) *loadAvg {
+	return &loadAvg{
+		name: name,
+		path: "/proc/loadavg",
+	}
+}
+
+// Name returns the name for the loadAvg command.
+func (l *loadAvg) Name() string {
+	return l.name
+}
+
+// Run reads /proc/loadavg and returns a map of the titles "1min", "5min"
+// and "15min" to the load average over each period. The file holds a
+// single line, ex.
+//
+// "0.20 0.18 0.12 1/80 11206"
+//
+// where the first 3 fields are the load averages.
+func (l *loadAvg) Run() (map[string][]string, error) {
+	out, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %v", l.path, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("failed to parse %s, got %q", l.path, string(out))
+	}
+	output := make(map[string][]string)
+	for i, title := range []string{"1min", "5min", "15min"} {
+		output[title] = []string{fields[i]}
+	}
+	return output, nil
+}
+
+// fileNr represents a reader of the file handle usage of the system in
+// /proc/sys/fs/file-nr.
+type fileNr struct {
+	name string
+	// path is the path of the file handle usage file.
+	path string
+}
+
+// NewFileNr function helps to initialize a fileNr structure.
+func NewFileNr(name string) *fileNr {
+	return &fileNr{
+		name: name,
+		path: "/proc/sys/fs/file-nr",
+	}
+}
+
+// Name returns the name for the fileNr command.
+func (f *fileNr) Name() string {
+	return f.name
+}
+
+// Run reads /proc/sys/fs/file-nr and returns a map of the titles
+// "allocated", "unused" and "max" to the number of allocated file handles,
+// the number of allocated but unused file handles and the maximum number of
+// file handles. The file holds a single line, ex.
+//
+// "1024 0 9223372036854775807"
+func (f *fileNr) Run() (map[string][]string, error) {
+	out, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %v", f.path, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("failed to parse %s, got %q", f.path, string(out))
+	}
+	output := make(map[string][]string)
+	for i, title := range []string{"allocated", "unused", "max"} {
+		output[title] = []string{fields[i]}
+	}
+	return output, nil
+}
+
+// psiResources are the resources whose pressure stall information is
+// reported under /proc/pressure.
+var psiResources = []string{"cpu", "memory", "io"}
+
+// psi represents a reader of the pressure stall information (PSI) in
+// /proc/pressure.
+type psi struct {
+	name string
+	// path is the directory holding the pressure file of each resource.
+	path string
+}
+
+// NewPSI function helps to initialize a psi structure.
+func NewPSI(name string) *psi {
+	return &psi{
+		name: name,
+		path: "/proc/pressure",
+	}
+}
+
+// Name returns the name for the psi command.
+func (p *psi) Name() string {
+	return p.name
+}
+
+// Run reads the pressure files of the CPU, memory and I/O resources and
+// returns a map of "<resource>:<line>_<field>" to the value of the field,
+// ex. "memory:some_avg10". Each file holds a "some" line, and possibly a
+// "full" line, ex.
+//
+// "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+//
+// where avg10, avg60 and avg300 are the percentages of time in the last 10,
+// 60 and 300 seconds during which some (or all) tasks were stalled on the
+// resource. Kernels without PSI support have no pressure files, so missing
+// files are not an error: their titles are left out of the output.
+func (p *psi) Run() (map[string][]string, error) {
+	output := make(map[string][]string)
+	for _, resource := range psiResources {
+		path := filepath.Join(p.path, resource)
+		out, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			log.Debugf("no pressure stall information for %q: %v", resource, err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %v", path, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("failed to parse %s, got %q", path, line)
+			}
+			for _, field := range fields[1:] {
+				kv := strings.SplitN(field, "=", 2)
+				if len(kv) != 2 {
+					return nil, fmt.Errorf("failed to parse field %q of %s", field, path)
+				}
+				title := resource + ":" + fields[0] + "_" + kv[0]
+				output[title] = append(output[title], kv[1])
+			}
+		}
+	}
+	return output, nil
+}
+
+// dmesgTimestampRe matches the timestamp of a kernel ring buffer line, in
+// seconds since boot, ex. "[ 1024.412381] mce: ...".
+var dmesgTimestampRe = regexp.MustCompile(`^\[\s*(\d+\.\d+)\]`)
+
+// dmesg represents the command 'dmesg', which prints the kernel ring buffer.
+type dmesg struct {
+	name  string
+	flags string
+
+	mu sync.Mutex
+	// last is the timestamp of the last message returned by Run, and
+	// seen is false until Run has returned messages.
+	last float64
+	seen bool
+}
+
+// NewDMesg function helps to initialize a dmesg structure.
+func NewDMesg(name string, flags string) *dmesg {
+	return &dmesg{
+		name:  name,
+		flags: flags,
+	}
+}
+
+// Name returns the name for the 'dmesg' command.
+func (d *dmesg) Name() string {
+	return d.name
+}
+
+// Run executes the 'dmesg' command and returns a map of the title
+// "messages" to the non-empty lines of the kernel ring buffer.
+//
+// Only the lines logged after the last line returned by the previous run
+// are returned, so that the errors found in the messages are counted over
+// the interval between two collections instead of since boot. Lines
+// without a timestamp belong to the preceding timestamped line.
+func (d *dmesg) Run() (map[string][]string, error) {
+	args := strings.Fields(d.flags)
+	out, err := utils.RunCommand(d.Name(), args...)
+	if err != nil {
+		str := d.Name() + " " + strings.Join(args, " ")
+		return nil, fmt.Errorf("failed to run the command %q: %v",
+			str, err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	messages := []string{}
+	// Lines before the first timestamp are only new on the first run.
+	isNew := !d.seen
+	last := d.last
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if match := dmesgTimestampRe.FindStringSubmatch(line); match != nil {
+			timestamp, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse timestamp of dmesg line %q: %v", line, err)
+			}
+			isNew = !d.seen || timestamp > d.last
+			if isNew && timestamp > last {
+				last = timestamp
+			}
+		}
+		if isNew {
+			messages = append(messages, line)
+		}
+	}
+	if len(messages) > 0 {
+		d.last, d.seen = last, true
+	}
+	return map[string][]string{"messages": messages}, nil
+}
+
+// netDevColumns are the titles given to the columns of /proc/net/dev. The
+// receive and transmit columns are prefixed with "rx_" and "tx_".
+var netDevColumns = []string{
+	"rx_bytes", "rx_packets", "rx_errs", "rx_drop", "rx_fifo", "rx_frame", "rx_compressed", "rx_multicast",
+	"tx_bytes", "tx_packets", "tx_errs", "tx_drop", "tx_fifo", "tx_colls", "tx_carrier", "tx_compressed",
+}
+
+// netDev represents a reader of the network interface statistics in
+// /proc/net/dev.
+type netDev struct {
+	name string
+	// delay specifies times between updates in seconds.
+	delay int
+	// count specifies number of updates.
+	count int
+	// titles specifies the titles to get values for.
+	titles []string
+	// procPath is the path of the interface statistics file.
+	procPath string
+	// sysPath is the directory holding the link speed of each interface.
+	sysPath string
+}
+
+// NewNetDev function helps to initialize a netDev structure.
+func NewNetDev(name string, delay int, count int, titles []string) *netDev {
+	return &netDev{
+		name:     name,
+		delay:    delay,
+		count:    count,
+		titles:   titles,
+		procPath: "/proc/net/dev",
+		sysPath:  "/sys/class/net",
+	}
+}
+
+// Name returns the name for the netDev command.
+func (n *netDev) Name() string {
+	return n.name
+}
+
+func (n *netDev) setDefaults() {
+	if n.delay == 0 {
+		n.delay = 1
+	}
+	if n.count == 0 {
+		n.count = 5
+	}
+}
+
+// Run reads /proc/net/dev count times, delay seconds apart, and returns a map
+// of "<interface>:<title>" to the value of the title in each update, ex.
+// "eth0:rx_bytes". The "time" title holds the time of each update in seconds
+// since the epoch, and "<interface>:speed" holds the link speed of each
+// interface in Mb/s, if it is known.
+func (n *netDev) Run() (map[string][]string, error) {
+	// if delay and count not set
+	n.setDefaults()
+	if n.count < 0 {
+		return nil, fmt.Errorf("invalid count %d", n.count)
+	}
+	wantTitles := n.titles
+	if len(wantTitles) == 0 {
+		wantTitles = netDevColumns
+	}
+	// map each column title to its index eg "rx_bytes" : 0
+	columns := make(map[string]int)
+	for index, title := range netDevColumns {
+		columns[title] = index
+	}
+	output := make(map[string][]string)
+	ifaces := make(map[string]bool)
+	for i := 0; i < n.count; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(n.delay) * time.Second)
+		}
+		out, err := ioutil.ReadFile(n.procPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %v", n.procPath, err)
+		}
+		sampled := float64(time.Now().UnixNano()) / float64(time.Second)
+		output["time"] = append(output["time"], strconv.FormatFloat(sampled, 'f', 3, 64))
+		lines := strings.Split(strings.Trim(string(out), "\n"), "\n")
+		if len(lines) < 2 {
+			return nil, fmt.Errorf("failed to collect output of %s, rows: %v", n.procPath, lines)
+		}
+		// ignore the first 2 lines in /proc/net/dev, which are column titles
+		// that can't be split into columns by whitespaces.
+		// "Inter-|   Receive                            |  Transmit"
+		// " face |bytes    packets errs drop fifo ...   |bytes    packets ..."
+		// "  eth0: 1405702    2186    0    0    0 ..."
+		parsed, err := utils.ParseRows(lines[2:], ":")
+		if err != nil {
+			return nil, err
+		}
+		for iface, tokens := range parsed {
+			if len(tokens) != len(netDevColumns) {
+				return nil, fmt.Errorf("interface %q has %d columns, expected %d", iface, len(tokens), len(netDevColumns))
+			}
+			ifaces[iface] = true
+			for _, title := range wantTitles {
+				index, ok := columns[title]
+				if !ok {
+					return nil, fmt.Errorf("unknown column title %q", title)
+				}
+				key := iface + ":" + title
+				output[key] = append(output[key], tokens[index])
+			}
+		}
+	}
+	for iface := range ifaces {
+		// virtual interfaces, ex. lo, have no link speed.
+		speed, err := ioutil.ReadFile(filepath.Join(n.sysPath, iface, "speed"))
+		if err != nil {
+			log.Debugf("no link speed for interface %q: %v", iface, err)
+			continue
+		}
+		output[iface+":speed"] = []string{strings.TrimSpace(string(speed))}
+	}
+	return output, nil
+}
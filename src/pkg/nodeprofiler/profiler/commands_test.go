@@ -1,6 +1,9 @@
 package profiler
 
 import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -122,6 +125,124 @@ func TestRun(t *testing.T) {
 				"st": {"0", "0", "0", "0"},
 			},
 		},
+		{
+			name: "loadavg",
+			fakeCmd: &loadAvg{
+				name: "loadavg",
+				path: "testdata/loadavg.txt",
+			},
+			want: map[string][]string{
+				"1min":  {"0.20"},
+				"5min":  {"0.18"},
+				"15min": {"0.12"},
+			},
+		},
+		{
+			name: "missing loadavg file",
+			fakeCmd: &loadAvg{
+				name: "loadavg",
+				path: "testdata/missing.txt",
+			},
+			wantErr: true,
+		},
+		{
+			name: "file-nr",
+			fakeCmd: &fileNr{
+				name: "filenr",
+				path: "testdata/file-nr.txt",
+			},
+			want: map[string][]string{
+				"allocated": {"1024"},
+				"unused":    {"0"},
+				"max":       {"9223372036854775807"},
+			},
+		},
+		{
+			name: "malformed file-nr file",
+			fakeCmd: &fileNr{
+				name: "filenr",
+				path: "testdata/loadavg.txt",
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing file-nr file",
+			fakeCmd: &fileNr{
+				name: "filenr",
+				path: "testdata/missing.txt",
+			},
+			wantErr: true,
+		},
+		{
+			name: "psi",
+			fakeCmd: &psi{
+				name: "psi",
+				path: "testdata/pressure",
+			},
+			want: map[string][]string{
+				"cpu:some_avg10":     {"12.50"},
+				"cpu:some_avg60":     {"4.20"},
+				"cpu:some_avg300":    {"1.05"},
+				"cpu:some_total":     {"8231472"},
+				"cpu:full_avg10":     {"0.00"},
+				"cpu:full_avg60":     {"0.00"},
+				"cpu:full_avg300":    {"0.00"},
+				"cpu:full_total":     {"0"},
+				"memory:some_avg10":  {"0.40"},
+				"memory:some_avg60":  {"0.10"},
+				"memory:some_avg300": {"0.02"},
+				"memory:some_total":  {"105219"},
+				"memory:full_avg10":  {"0.20"},
+				"memory:full_avg60":  {"0.05"},
+				"memory:full_avg300": {"0.01"},
+				"memory:full_total":  {"52114"},
+				"io:some_avg10":      {"31.07"},
+				"io:some_avg60":      {"22.64"},
+				"io:some_avg300":     {"8.91"},
+				"io:some_total":      {"96457310"},
+				"io:full_avg10":      {"25.12"},
+				"io:full_avg60":      {"18.30"},
+				"io:full_avg300":     {"7.02"},
+				"io:full_total":      {"80231985"},
+			},
+		},
+		{
+			name: "psi missing resources",
+			fakeCmd: &psi{
+				name: "psi",
+				path: "testdata/pressure_partial",
+			},
+			want: map[string][]string{
+				"cpu:some_avg10":  {"0.00"},
+				"cpu:some_avg60":  {"0.00"},
+				"cpu:some_avg300": {"0.00"},
+				"cpu:some_total":  {"1520"},
+			},
+		},
+		{
+			name: "psi not supported",
+			fakeCmd: &psi{
+				name: "psi",
+				path: "testdata/missing",
+			},
+			want: map[string][]string{},
+		},
+		{
+			name: "dmesg",
+			fakeCmd: &dmesg{
+				name: "testdata/dmesg_clean.sh",
+			},
+			want: map[string][]string{
+				"messages": {
+					"[    0.000000] Linux version 5.10.68+ (builder@localhost) (Chromium OS 12.0_pre416183_p20210305-r3 clang version 12.0.0) #1 SMP Tue Oct 5 08:39:02 UTC 2021",
+					"[    0.001734] Command line: BOOT_IMAGE=/syslinux/vmlinuz.A init=/usr/lib/systemd/systemd",
+					"[    0.512351] mce: CPU0: Thermal monitoring enabled (TM1)",
+					"[    0.512360] mce: CPU supports 10 MCE banks",
+					"[    2.301244] EXT4-fs (sda1): mounted filesystem with ordered data mode. Opts: (null)",
+					"[   12.550001] IPv6: ADDRCONF(NETDEV_CHANGE): eth0: link becomes ready",
+				},
+			},
+		},
 		{
 			name: "illegal argument",
 			fakeCmd: &vmstat{
@@ -143,3 +264,116 @@ func TestRun(t *testing.T) {
 		}
 	}
 }
+
+func TestDMesgRunSincePrevious(t *testing.T) {
+	buffer := filepath.Join(t.TempDir(), "dmesg.txt")
+	// the fake dmesg prints the contents of buffer.
+	cmd := NewDMesg("cat", buffer)
+	lines := []string{
+		"[    0.000000] Linux version 5.10.68+",
+		"[ 1024.412381] mce: [Hardware Error]: Machine check events logged",
+	}
+	newLines := []string{
+		"[ 2048.118273] CPU3: Core temperature above threshold, cpu clock throttled (total events = 1)",
+		"continuation of the previous line",
+		"[ 3072.664109] watchdog: BUG: soft lockup - CPU#1 stuck for 22s! [kworker/1:2:1234]",
+	}
+	runs := []struct {
+		name   string
+		buffer []string
+		want   []string
+	}{
+		{name: "first run", buffer: lines, want: lines},
+		{name: "no new lines", buffer: lines, want: []string{}},
+		{name: "new lines", buffer: append(append([]string{}, lines...), newLines...), want: newLines},
+	}
+	for _, run := range runs {
+		var contents string
+		for _, line := range run.buffer {
+			contents += line + "\n"
+		}
+		if err := ioutil.WriteFile(buffer, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := cmd.Run()
+		if err != nil {
+			t.Fatalf("%s: Run() err %v", run.name, err)
+		}
+		if diff := cmp.Diff(map[string][]string{"messages": run.want}, got); diff != "" {
+			t.Errorf("%s: Run() returned unexpected messages (-want +got):\n%s", run.name, diff)
+		}
+	}
+}
+
+func TestNetDevRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		fakeCmd *netDev
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "netdev",
+			fakeCmd: &netDev{
+				name:     "netdev",
+				count:    1,
+				titles:   []string{"rx_bytes", "tx_drop"},
+				procPath: "testdata/net_dev.txt",
+				sysPath:  "testdata/sys_class_net",
+			},
+			want: map[string][]string{
+				"lo:rx_bytes":   {"27212"},
+				"lo:tx_drop":    {"0"},
+				"eth0:rx_bytes": {"1405702"},
+				"eth0:tx_drop":  {"1"},
+				"eth0:speed":    {"1000"},
+			},
+		},
+		{
+			name: "unknown title",
+			fakeCmd: &netDev{
+				name:     "netdev",
+				count:    1,
+				titles:   []string{"rx_unknown"},
+				procPath: "testdata/net_dev.txt",
+				sysPath:  "testdata/sys_class_net",
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing file",
+			fakeCmd: &netDev{
+				name:     "netdev",
+				count:    1,
+				procPath: "testdata/missing.txt",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := test.fakeCmd.Run()
+
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Fatalf("%s: Run() err %v, wantErr %t", test.name, err, test.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		// the sampling time differs between runs, so it is only checked
+		// for being a valid timestamp.
+		times := got["time"]
+		if len(times) != test.fakeCmd.count {
+			t.Errorf("%s: Run() got %d samples of time, want %d", test.name, len(times), test.fakeCmd.count)
+		}
+		for _, sampled := range times {
+			if _, err := strconv.ParseFloat(sampled, 64); err != nil {
+				t.Errorf("%s: Run() got invalid time %q: %v", test.name, sampled, err)
+			}
+		}
+		delete(got, "time")
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Ran Run(), but got mismatch between got and want (-got, +want): \n diff %s", diff)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package profiler
 import (
 	"fmt"
 	"math"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -35,27 +37,122 @@ type Component interface {
 	AdditionalInformation() string
 }
 
+// Default saturation thresholds of the components. See Thresholds.
+const (
+	defaultMemSwapPercent        = 10
+	defaultMemUtilizationPercent = 95
+	defaultStorageQueueLength    = 1
+	defaultPSIPercent            = 10
+	defaultFDPercent             = 90
+)
+
+// Thresholds holds the values above which the components are considered
+// saturated. A zero value means the default threshold is used, so the zero
+// Thresholds uses the default value of every threshold.
+type Thresholds struct {
+	// MemSwapPercent is the amount of memory swapped in and out of the
+	// disks, as a percentage of the total swap memory, above which MemCap
+	// is saturated. Defaults to 10.
+	MemSwapPercent float64 `json:"MemSwapPercent"`
+	// MemUtilizationPercent is the memory utilization above which MemCap
+	// is saturated on systems without swap memory. Defaults to 95.
+	MemUtilizationPercent float64 `json:"MemUtilizationPercent"`
+	// StorageQueueLength is the average queue length of the requests
+	// issued to the storage devices above which StorageDevIO is saturated.
+	// Defaults to 1.
+	StorageQueueLength float64 `json:"StorageQueueLength"`
+	// StorageCapPercent is the disk usage percentage above which a file
+	// system of a device saturates StorageCap. Defaults to 90.
+	StorageCapPercent float64 `json:"StorageCapPercent"`
+	// PSIPercent is the percentage of time, over the last 10 seconds,
+	// during which some tasks were stalled on a resource above which CPU,
+	// MemCap and StorageDevIO are saturated. Defaults to 10.
+	PSIPercent float64 `json:"PSIPercent"`
+	// FDPercent is the file handle utilization above which
+	// FileDescriptors is saturated. Defaults to 90.
+	FDPercent float64 `json:"FDPercent"`
+}
+
+// withDefaults returns a copy of t where the unset thresholds are set to
+// their default value.
+func (t Thresholds) withDefaults() Thresholds {
+	if t.MemSwapPercent <= 0 {
+		t.MemSwapPercent = defaultMemSwapPercent
+	}
+	if t.MemUtilizationPercent <= 0 {
+		t.MemUtilizationPercent = defaultMemUtilizationPercent
+	}
+	if t.StorageQueueLength <= 0 {
+		t.StorageQueueLength = defaultStorageQueueLength
+	}
+	if t.StorageCapPercent <= 0 {
+		t.StorageCapPercent = defaultStorageSaturationThreshold
+	}
+	if t.PSIPercent <= 0 {
+		t.PSIPercent = defaultPSIPercent
+	}
+	if t.FDPercent <= 0 {
+		t.FDPercent = defaultFDPercent
+	}
+	return t
+}
+
+// psiSaturated reports whether a resource is saturated according to its
+// pressure stall information, found on the psi command's
+// "<resource>:some_avg10" title. The second result is false if the pressure
+// stall information of the resource is not available, ex. on kernels without
+// PSI support, in which case saturation is only based on other metrics.
+// threshold is the stall percentage above which the resource is saturated.
+func psiSaturated(outputs map[string]utils.ParsedOutput, resource string, threshold float64) (bool, bool) {
+	title := resource + ":some_avg10"
+	val, ok := outputs["psi"][title]
+	if !ok || len(val) == 0 {
+		return false, false
+	}
+	avg10, err := strconv.ParseFloat(val[0], 64)
+	if err != nil {
+		log.Warningf("failed to convert psi title %q value %q to float: %v", title, val[0], err)
+		return false, false
+	}
+	return avg10 > threshold, true
+}
+
 // CPU holds information about the CPU component:
-// name and USE Metrics collected.
+// name, USE Metrics collected and the load averages normalized by CPU count.
 type CPU struct {
 	name    string
 	metrics *USEMetrics
+	// loadAverages holds the 1, 5 and 15-minute load averages divided by
+	// the number of CPUs, or nil if they were not collected.
+	loadAverages []float64
+	thresholds   Thresholds
 }
 
 // NewCPU holds information about the CPU component:
 // this can be used to initialize CPU outside of the
-// profiler package.
-func NewCPU(name string) *CPU {
+// profiler package. Only the PSIPercent threshold applies to CPU.
+func NewCPU(name string, thresholds Thresholds) *CPU {
 	return &CPU{
-		name:    name,
-		metrics: &USEMetrics{},
+		name:       name,
+		metrics:    &USEMetrics{},
+		thresholds: thresholds,
 	}
 }
 
 // AdditionalInformation returns additional information unique to the
-// the CPU component.
+// the CPU component: the load averages normalized by CPU count, if they
+// were collected.
 func (c *CPU) AdditionalInformation() string {
-	return ""
+	if len(c.loadAverages) != 3 {
+		return ""
+	}
+	info := fmt.Sprintf("The 1, 5 and 15-minute load averages normalized by CPU count "+
+		"were %.2f, %.2f and %.2f.", c.loadAverages[0], c.loadAverages[1], c.loadAverages[2])
+	if c.loadAverages[0] > 1 {
+		info += " The 1-minute load average exceeds the CPU count, which indicates " +
+			"that the CPU is saturated."
+	}
+	return info
 }
 
 // Name returns the name of the CPU component.
@@ -138,12 +235,15 @@ func (c *CPU) calculateCPUCount(outputs map[string]utils.ParsedOutput) (int, err
 	return count, nil
 }
 
-// CollectSaturation calculates the saturation value for the CPU component.
-// It does this by comparing the number of runnable processes with the number
-// of CPUs in the system. If the number of processes (running or waiting) is
+// CollectSaturation calculates the saturation value for the CPU component. It
+// does this by comparing the number of runnable processes with the number of
+// CPUs in the system. If the number of processes (running or waiting) is
 // greater than the CPU count, the CPU component is saturated. The value of
-// runnable processes is found on vmstat's 'r' column and CPU count from
-// lscpu's "CPU(s)" row.
+// runnable processes is found on vmstat's 'r' column and CPU count from lscpu's
+// "CPU(s)" row. The CPU component is also saturated if tasks were stalled
+// waiting for a CPU more than 10% of the time (the PSIPercent threshold),
+// according to the pressure stall information in /proc/pressure/cpu, if it is
+// available.
 func (c *CPU) CollectSaturation(outputs map[string]utils.ParsedOutput) error {
 	cmd := "vmstat"
 	parsedOutput, ok := outputs[cmd]
@@ -176,29 +276,112 @@ func (c *CPU) CollectSaturation(outputs map[string]utils.ParsedOutput) error {
 		return err
 	}
 	c.metrics.Saturation = runningProcs > count
+	if saturated, ok := psiSaturated(outputs, "cpu", c.thresholds.withDefaults().PSIPercent); ok {
+		c.metrics.Saturation = c.metrics.Saturation || saturated
+	}
+	// load averages only add context to the saturation value, so failing
+	// to collect them is not an error.
+	if err := c.collectLoadAverages(outputs); err != nil {
+		log.Warningf("failed to collect load averages for %q: %v", c.Name(), err)
+	}
 	return nil
 }
 
-// CollectErrors collects errors for the CPU component.
+// collectLoadAverages collects the 1, 5 and 15-minute load averages of the
+// CPU component, normalized by CPU count. The load averages are found on
+// the "1min", "5min" and "15min" titles of the loadavg command's output, and
+// CPU count from lscpu's "CPU(s)" row. A normalized 1-minute load average
+// greater than 1 corroborates the run queue based saturation value.
+func (c *CPU) collectLoadAverages(outputs map[string]utils.ParsedOutput) error {
+	cmd := "loadavg"
+	parsedOutput, ok := outputs[cmd]
+	if !ok {
+		return fmt.Errorf("missing output for %q", cmd)
+	}
+	count, err := c.calculateCPUCount(outputs)
+	if err != nil {
+		return err
+	}
+	if count <= 0 {
+		return fmt.Errorf("invalid CPU count %d", count)
+	}
+	var loadAverages []float64
+	for _, title := range []string{"1min", "5min", "15min"} {
+		val, ok := parsedOutput[title]
+		if !ok || len(val) == 0 {
+			return fmt.Errorf("missing loadavg title %q", title)
+		}
+		load, err := strconv.ParseFloat(val[0], 64)
+		if err != nil {
+			return fmt.Errorf("could not convert %s to a float: %v", val[0], err)
+		}
+		loadAverages = append(loadAverages, math.Round(load/float64(count)*100)/100)
+	}
+	c.loadAverages = loadAverages
+	return nil
+}
+
+// cpuErrorPatterns match the kernel log messages reporting CPU errors:
+// machine check exceptions, thermal throttling and soft lockups. The
+// informational messages logged by mce at boot, ex. "mce: CPU0: Thermal
+// monitoring enabled", are not errors.
+var cpuErrorPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)machine check|\[hardware error\]`),
+	regexp.MustCompile(`(?i)temperature above threshold|clock throttled`),
+	regexp.MustCompile(`(?i)soft lockup`),
+}
+
+// CollectErrors collects errors for the CPU component. It does this by
+// counting the lines of the kernel ring buffer, found on dmesg's output,
+// that report a CPU error: machine check exceptions (mce), thermal
+// throttling events and soft lockups. Each line is counted once, even if
+// it matches several of these signatures. dmesg only outputs the lines
+// logged since the previous collection, so errors are not counted twice.
+//
+// dmesg may be missing or not allowed on the node, so without its output
+// the errors are unknown and left at 0 instead of failing the collection
+// of the other metrics.
 func (c *CPU) CollectErrors(outputs map[string]utils.ParsedOutput) error {
-	// Not yet implemented.
+	cmd := "dmesg"
+	parsedOutput, ok := outputs[cmd]
+	if !ok {
+		log.Warningf("missing output for %q, errors of %q are unknown", cmd, c.Name())
+		c.metrics.Errors = 0
+		return nil
+	}
+	messages, ok := parsedOutput["messages"]
+	if !ok {
+		return fmt.Errorf("missing dmesg title 'messages'")
+	}
+	var errs int64
+	for _, message := range messages {
+		for _, pattern := range cpuErrorPatterns {
+			if pattern.MatchString(message) {
+				errs++
+				break
+			}
+		}
+	}
+	c.metrics.Errors = errs
 	return nil
 }
 
 // MemCap holds information about the Memory capacity component:
 // name and USE Metrics collected.
 type MemCap struct {
-	name    string
-	metrics *USEMetrics
+	name       string
+	metrics    *USEMetrics
+	thresholds Thresholds
 }
 
 // NewMemCap holds information about the Memory capacity component:
 // this can be used to initialize MemCap outside of the
 // profiler package.
-func NewMemCap(name string) *MemCap {
+func NewMemCap(name string, thresholds Thresholds) *MemCap {
 	return &MemCap{
-		name:    name,
-		metrics: &USEMetrics{},
+		name:       name,
+		metrics:    &USEMetrics{},
+		thresholds: thresholds,
 	}
 }
 
@@ -282,14 +465,19 @@ func (m *MemCap) calculateTotalMemory(mem string, outputs map[string]utils.Parse
 	return total, nil
 }
 
-// CollectSaturation calculates the saturation value for Memory Capacity.
-// It does this by checking whether the amount of memory being swapped in
-// and out of the disks is significant. This indicates that the system is
-// low on memory and the kernel is relying heavily on pages from the swap
-// space on the disk. Here we define "significant" as the amount of swapped
-// memory amounting to roughly 10% of the total memory." The values for
-// memory swapped in and out of disks can be found on vmstat's 'si'
-// (swapped in) and 'so' (swapped to) columns.
+// CollectSaturation calculates the saturation value for Memory Capacity. It
+// does this by checking whether the amount of memory being swapped in and out
+// of the disks is significant. This indicates that the system is low on memory
+// and the kernel is relying heavily on pages from the swap space on the disk.
+// Here we define "significant" as the amount of swapped memory amounting to
+// roughly 10% of the total memory (the MemSwapPercent threshold). On systems
+// without swap memory, Memory Capacity is instead saturated above 95%
+// utilization (the MemUtilizationPercent threshold). The values for memory
+// swapped in and out of disks can be found on vmstat's 'si' (swapped in) and
+// 'so' (swapped to) columns. Memory Capacity is also saturated if tasks were
+// stalled waiting for memory more than 10% of the time (the PSIPercent
+// threshold), according to the pressure stall information in
+// /proc/pressure/memory, if it is available.
 func (m *MemCap) CollectSaturation(outputs map[string]utils.ParsedOutput) error {
 	vmstatCmd := "vmstat"
 	parsedOutput, ok := outputs[vmstatCmd]
@@ -322,20 +510,23 @@ func (m *MemCap) CollectSaturation(outputs map[string]utils.ParsedOutput) error
 	// in kilobytes
 	totalBytes := total * 1024
 
-	// ten percent of total swap memory
 	log.Infof("swaps is %d and total swap memory is %d", swaps, totalBytes)
 
+	thresholds := m.thresholds.withDefaults()
 	var threshold float64
 	// accounts for cases where swap memory is 0
 	if totalBytes == 0 {
-		// threshold set as 95 percent utilization
-		threshold = 95
+		// threshold set as a percentage of utilization, 95 by default
+		threshold = thresholds.MemUtilizationPercent
 		m.metrics.Saturation = m.metrics.Utilization > threshold
 	} else {
-		// threshold set as 10 percent of total swap memory
-		threshold = 0.1 * float64(totalBytes)
+		// threshold set as a percentage of total swap memory, 10 by default
+		threshold = thresholds.MemSwapPercent / 100 * float64(totalBytes)
 		m.metrics.Saturation = float64(swaps) > threshold
 	}
+	if saturated, ok := psiSaturated(outputs, "memory", thresholds.PSIPercent); ok {
+		m.metrics.Saturation = m.metrics.Saturation || saturated
+	}
 	return nil
 }
 
@@ -346,26 +537,48 @@ func (m *MemCap) CollectErrors(outputs map[string]utils.ParsedOutput) error {
 }
 
 // StorageDevIO holds information about the Storage device I/O component:
-// name and USE Metrics collected.
+// name, USE Metrics collected and the devices measured.
 type StorageDevIO struct {
 	name    string
 	metrics *USEMetrics
+	devices []string
+	// maxDevice is the device with the highest average utilization, and
+	// maxUtilization is its average utilization.
+	maxDevice      string
+	maxUtilization float64
+	thresholds     Thresholds
 }
 
 // NewStorageDevIO holds information about the Storage device I/O component:
 // this can be used to initialize Storage device I/O outside of the
-// profiler package.
-func NewStorageDevIO(name string) *StorageDevIO {
+// profiler package. devices are the names of the devices to measure, ex.
+// "sda" or "/dev/sda". If no devices are specified, all the devices reported
+// by iostat are measured.
+func NewStorageDevIO(name string, devices []string, thresholds Thresholds) *StorageDevIO {
 	return &StorageDevIO{
-		name:    name,
-		metrics: &USEMetrics{},
+		name:       name,
+		metrics:    &USEMetrics{},
+		devices:    devices,
+		thresholds: thresholds,
 	}
 }
 
 // AdditionalInformation returns additional information unique to the
 // the StorageDevIO component.
 func (d *StorageDevIO) AdditionalInformation() string {
-	return ""
+	var info string
+	if len(d.devices) > 0 {
+		info = "The utilization value for this component was measured using the " +
+			"following devices: " + strings.Join(d.devices, ",") + "."
+	}
+	if d.maxDevice != "" {
+		if info != "" {
+			info += " "
+		}
+		info += fmt.Sprintf("The busiest device was %s, with an average "+
+			"utilization of %.2f%%.", d.maxDevice, d.maxUtilization)
+	}
+	return info
 }
 
 // Name returns the name of the Storage device I/O component.
@@ -378,49 +591,122 @@ func (d *StorageDevIO) USEMetrics() *USEMetrics {
 	return d.metrics
 }
 
+// deviceValues returns the values of an iostat column grouped by device name.
+// Only the rows of StorageDevIO's devices are returned, or all rows if no
+// devices are set. If iostat's output has no 'Device' column and no devices
+// are set, all values are grouped under an empty device name.
+func (d *StorageDevIO) deviceValues(parsedOutput utils.ParsedOutput, column string) (map[string][]float64, error) {
+	values, ok := parsedOutput[column]
+	if !ok {
+		return nil, fmt.Errorf("missing iostat column %q", column)
+	}
+	// older versions of iostat name the column "Device:"
+	names, hasNames := parsedOutput["Device"]
+	if !hasNames {
+		names, hasNames = parsedOutput["Device:"]
+	}
+	if len(d.devices) > 0 && !hasNames {
+		return nil, fmt.Errorf("missing iostat column 'Device'")
+	}
+	if hasNames && len(names) != len(values) {
+		return nil, fmt.Errorf("iostat columns 'Device' and %q have different lengths", column)
+	}
+	wanted := make(map[string]bool)
+	for _, device := range d.devices {
+		wanted[strings.TrimPrefix(device, "/dev/")] = true
+	}
+	grouped := make(map[string][]float64)
+	for i, value := range values {
+		var name string
+		if hasNames {
+			name = names[i]
+		}
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %q to float: %v", value, err)
+		}
+		grouped[name] = append(grouped[name], val)
+	}
+	return grouped, nil
+}
+
 // CollectUtilization collects the utilization score for the StorageDevIO component.
 // It does this by getting the percentage of elapsed time during which I/O requests
 // were issued to the devices. This value can be found on iostat's '%util' column.
+// The utilization is averaged over the rows of the measured devices, and the
+// device with the highest average utilization is reported separately, since
+// a single busy device can be hidden by the average. Devices missing from
+// iostat's output, ex. idle devices omitted because of the '-z' flag, are
+// not counted.
 func (d *StorageDevIO) CollectUtilization(outputs map[string]utils.ParsedOutput) error {
 	cmd := "iostat"
 	parsedOutput, ok := outputs[cmd]
 	if !ok {
 		return fmt.Errorf("missing output for %q", cmd)
 	}
-	util, ok := parsedOutput["%util"]
-	if !ok {
-		return fmt.Errorf("mising iostat column util")
-	}
-	total, err := utils.SumParseFloat(util)
+	grouped, err := d.deviceValues(parsedOutput, "%util")
 	if err != nil {
 		return err
 	}
-	average := total / float64(len(util))
-	d.metrics.Utilization = average
+	var total float64
+	var count int
+	d.maxDevice, d.maxUtilization = "", 0
+	for name, util := range grouped {
+		var deviceTotal float64
+		for _, val := range util {
+			deviceTotal += val
+		}
+		total += deviceTotal
+		count += len(util)
+		average := deviceTotal / float64(len(util))
+		if name != "" && (d.maxDevice == "" || average > d.maxUtilization ||
+			(average == d.maxUtilization && name < d.maxDevice)) {
+			d.maxDevice, d.maxUtilization = name, average
+		}
+	}
+	d.metrics.Utilization = 0
+	if count > 0 {
+		d.metrics.Utilization = total / float64(count)
+	}
 	return nil
 }
 
-// CollectSaturation collects the saturation value for the StorageDevIO component.
-// It does this by comparing the average queue length of requests that were issued
-// to the device with 1. If the queue length is greater than 1, then the Storage Device
-// component is saturated. The value for the average queue length can be found on
-// iostat's 'aqu-sz' column.
+// CollectSaturation collects the saturation value for the StorageDevIO
+// component. It does this by comparing the average queue length of requests
+// that were issued to the device with 1 (the StorageQueueLength threshold). If
+// the queue length is greater than the threshold, then the Storage Device
+// component is saturated. The value for the average queue length can be found
+// on iostat's 'aqu-sz' column. Only the rows of the measured devices are
+// averaged. The component is also saturated if tasks were stalled waiting for
+// I/O more than 10% of the time (the PSIPercent threshold), according to the
+// pressure stall information in /proc/pressure/io, if it is available. The
+// pressure stall information is not broken down by device.
 func (d *StorageDevIO) CollectSaturation(outputs map[string]utils.ParsedOutput) error {
 	cmd := "iostat"
 	parsedOutput, ok := outputs[cmd]
 	if !ok {
 		return fmt.Errorf("missig output for %q", cmd)
 	}
-	queue, ok := parsedOutput["aqu-sz"]
-	if !ok {
-		return fmt.Errorf("missing iostat column 'aqu-sz'")
-	}
-	total, err := utils.SumParseFloat(queue)
+	grouped, err := d.deviceValues(parsedOutput, "aqu-sz")
 	if err != nil {
 		return err
 	}
-	average := total / float64(len(queue))
-	d.metrics.Saturation = average > 1
+	var total float64
+	var count int
+	for _, queue := range grouped {
+		for _, val := range queue {
+			total += val
+		}
+		count += len(queue)
+	}
+	thresholds := d.thresholds.withDefaults()
+	d.metrics.Saturation = count > 0 && total/float64(count) > thresholds.StorageQueueLength
+	if saturated, ok := psiSaturated(outputs, "io", thresholds.PSIPercent); ok {
+		d.metrics.Saturation = d.metrics.Saturation || saturated
+	}
 	return nil
 }
 
@@ -430,22 +716,31 @@ func (d *StorageDevIO) CollectErrors(outputs map[string]utils.ParsedOutput) erro
 	return nil
 }
 
+// defaultStorageSaturationThreshold is the disk usage percentage above which
+// a device is considered full.
+const defaultStorageSaturationThreshold = 90
+
 // StorageCap holds information about the Storage Capacity component:
-// name and USE Metrics collected.
+// name, USE Metrics collected and the devices measured.
 type StorageCap struct {
 	name    string
 	metrics *USEMetrics
 	devices []string
+	// SaturationThreshold is the disk usage percentage above which a
+	// device saturates the component.
+	SaturationThreshold float64
 }
 
 // NewStorageCap holds information about the StorageCap component:
 // this can be used to initialize StorageCap outside of the
-// profiler package.
-func NewStorageCap(name string) *StorageCap {
+// profiler package. Only the StorageCapPercent threshold applies to
+// StorageCap.
+func NewStorageCap(name string, thresholds Thresholds) *StorageCap {
 	return &StorageCap{
-		name:    name,
-		metrics: &USEMetrics{},
-		devices: []string{},
+		name:                name,
+		metrics:             &USEMetrics{},
+		devices:             []string{},
+		SaturationThreshold: thresholds.withDefaults().StorageCapPercent,
 	}
 }
 
@@ -462,69 +757,93 @@ func (s *StorageCap) setDefaults() {
 	if len(s.devices) == 0 {
 		s.devices = []string{"/dev/sda"}
 	}
+	if s.SaturationThreshold <= 0 {
+		s.SaturationThreshold = defaultStorageSaturationThreshold
+	}
 }
 
-// CollectUtilization calculates the utilization value for Storage Capacity.
-// It does this by getting disk usage of particular devices on the file system.
-// Disk usage on a particular device can be found using the 'df' command by
-// getting the 'Used' value of that device divided by its total size, found
-// on the column specifying metrics of block size. In this case, this column is
-// "1K-blocks", since "-k" was passed as a flag to 'df'. The devices to collect
-// disk usage for are found on StorageCap's devices field. If this field is not
-// set, "/dev/sda", i.e. the boot disk, is used as default.
-func (s *StorageCap) CollectUtilization(outputs map[string]utils.ParsedOutput) error {
-	// if devices are not set
-	s.setDefaults()
+// filesystemUsage returns the used and total blocks of each file system of
+// StorageCap's devices, i.e. of each df row whose file system name starts
+// with one of the devices, so a device includes all of its partitions. Each
+// row is returned once, even if it matches several devices.
+func (s *StorageCap) filesystemUsage(outputs map[string]utils.ParsedOutput) ([]int, []int, error) {
 	dfCmd := "df"
 	parsedOutput, ok := outputs[dfCmd]
 	if !ok {
-		return fmt.Errorf("missing output for %q", dfCmd)
+		return nil, nil, fmt.Errorf("missing output for %q", dfCmd)
 	}
 	usedBlocks, uPresent := parsedOutput["Used"]
 	if !uPresent {
-		return fmt.Errorf("missing df column 'Used'")
+		return nil, nil, fmt.Errorf("missing df column 'Used'")
 	}
 	// total column is represented by the column displaying metrics of block size,
 	// in this case "1K-blocks"
 	totalBlocks, tPresent := parsedOutput["1K-blocks"]
 	if !tPresent {
-		return fmt.Errorf("missing df column '1K-blocks'")
+		return nil, nil, fmt.Errorf("missing df column '1K-blocks'")
 	}
 	fsystems, fsPresent := parsedOutput["Filesystem"]
 	if !fsPresent {
-		return fmt.Errorf("missing column 'Filesystem'")
+		return nil, nil, fmt.Errorf("missing column 'Filesystem'")
 	}
-	// loop over all devices, if a device was specified by the struct,
-	// get its index and use that to find its "Used" and "total" values
-	var fUsed int
-	var fSize int
+	// loop over all file systems, if a file system belongs to a device
+	// specified by the struct, use its index to find its "Used" and
+	// "total" values
+	var used, size []int
 	hasDevice := make([]bool, len(s.devices))
 	for index, fsystem := range fsystems {
+		matched := false
 		for i, device := range s.devices {
 			if strings.HasPrefix(fsystem, device) {
 				// keep track of valid devices to collect statitics from
 				hasDevice[i] = true
-				s := usedBlocks[index]
-				val, err := strconv.Atoi(s)
-				if err != nil {
-					return fmt.Errorf("failed to convert %q to int: %v", val, err)
-				}
-				fUsed += val
-				s = totalBlocks[index]
-				val, err = strconv.Atoi(s)
-				if err != nil {
-					return fmt.Errorf("failed to convert %q to int: %v", val, err)
-				}
-				fSize += val
+				matched = true
 			}
 		}
+		if !matched {
+			continue
+		}
+		fsUsed, err := strconv.Atoi(usedBlocks[index])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert %q to int: %v", usedBlocks[index], err)
+		}
+		fsSize, err := strconv.Atoi(totalBlocks[index])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert %q to int: %v", totalBlocks[index], err)
+		}
+		used = append(used, fsUsed)
+		size = append(size, fsSize)
 	}
 	// check if there are missing devices
 	for i, ok := range hasDevice {
 		if !ok {
-			return fmt.Errorf("failed to find the device %q", s.devices[i])
+			return nil, nil, fmt.Errorf("failed to find the device %q", s.devices[i])
 		}
 	}
+	return used, size, nil
+}
+
+// CollectUtilization calculates the utilization value for Storage Capacity.
+// It does this by getting disk usage of particular devices on the file system.
+// Disk usage on a particular device can be found using the 'df' command by
+// getting the 'Used' value of that device divided by its total size, found
+// on the column specifying metrics of block size. In this case, this column is
+// "1K-blocks", since "-k" was passed as a flag to 'df'. The devices to collect
+// disk usage for are found on StorageCap's devices field. If this field is not
+// set, "/dev/sda", i.e. the boot disk, is used as default.
+func (s *StorageCap) CollectUtilization(outputs map[string]utils.ParsedOutput) error {
+	// if devices are not set
+	s.setDefaults()
+	used, size, err := s.filesystemUsage(outputs)
+	if err != nil {
+		return err
+	}
+	var fUsed int
+	var fSize int
+	for i := range used {
+		fUsed += used[i]
+		fSize += size[i]
+	}
 	util := (float64(fUsed) / float64(fSize)) * 100
 	fsUtilization := math.Round((util)*100) / 100
 	s.metrics.Utilization = fsUtilization
@@ -532,8 +851,27 @@ func (s *StorageCap) CollectUtilization(outputs map[string]utils.ParsedOutput) e
 }
 
 // CollectSaturation collects the saturation value for Storage Capacity.
+// The disk usage of each file system of the devices is computed separately,
+// so that a single full file system, ex. a full partition, saturates the
+// component even if the other file systems have free space. A file system
+// is full when its disk usage is above SaturationThreshold percent.
 func (s *StorageCap) CollectSaturation(outputs map[string]utils.ParsedOutput) error {
-	// Not yet implemented
+	s.setDefaults()
+	used, size, err := s.filesystemUsage(outputs)
+	if err != nil {
+		return err
+	}
+	s.metrics.Saturation = false
+	for i := range used {
+		if size[i] == 0 {
+			continue
+		}
+		util := (float64(used[i]) / float64(size[i])) * 100
+		if util > s.SaturationThreshold {
+			s.metrics.Saturation = true
+			return nil
+		}
+	}
 	return nil
 }
 
@@ -549,6 +887,298 @@ func (s *StorageCap) Name() string {
 	return s.name
 }
 
+// NetworkIO holds information about the Network I/O component:
+// name, USE Metrics collected and the network interfaces measured.
+type NetworkIO struct {
+	name       string
+	metrics    *USEMetrics
+	interfaces []string
+}
+
+// NewNetworkIO holds information about the Network I/O component:
+// this can be used to initialize NetworkIO outside of the
+// profiler package.
+func NewNetworkIO(name string) *NetworkIO {
+	return &NetworkIO{
+		name:       name,
+		metrics:    &USEMetrics{},
+		interfaces: []string{},
+	}
+}
+
+// AdditionalInformation returns additional information unique to the
+// the NetworkIO component.
+func (n *NetworkIO) AdditionalInformation() string {
+	info := "The utilization value for this component was measured using the " +
+		"following interfaces: " + strings.Join(n.interfaces, ",")
+	return info
+}
+
+// Name returns the name of the Network I/O component.
+func (n *NetworkIO) Name() string {
+	return n.name
+}
+
+// USEMetrics returns USEMetrics for the Network I/O component.
+func (n *NetworkIO) USEMetrics() *USEMetrics {
+	return n.metrics
+}
+
+// counterDelta returns the increase of a counter between its first and last
+// samples in the netdev output.
+func counterDelta(parsedOutput utils.ParsedOutput, key string) (int64, error) {
+	samples, ok := parsedOutput[key]
+	if !ok || len(samples) == 0 {
+		return 0, fmt.Errorf("missing netdev column %q", key)
+	}
+	first, err := strconv.ParseInt(samples[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %q to int: %v", samples[0], err)
+	}
+	last, err := strconv.ParseInt(samples[len(samples)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %q to int: %v", samples[len(samples)-1], err)
+	}
+	return last - first, nil
+}
+
+// netDevInterfaces returns the sorted names of the interfaces in the netdev
+// output that have the given column.
+func netDevInterfaces(parsedOutput utils.ParsedOutput, title string) []string {
+	var ifaces []string
+	for key := range parsedOutput {
+		if strings.HasSuffix(key, ":"+title) {
+			ifaces = append(ifaces, strings.TrimSuffix(key, ":"+title))
+		}
+	}
+	sort.Strings(ifaces)
+	return ifaces
+}
+
+// CollectUtilization calculates the utilization value for Network I/O.
+// It does this by getting the throughput of each interface, i.e. the larger
+// of the bytes received and transmitted per second, as a percentage of the
+// interface's link speed in Mb/s, read from /sys/class/net/<iface>/speed.
+// The counters are sampled by the 'netdev' command and the interval between
+// samples is found on its "time" column. Interfaces without a link speed,
+// ex. the loopback interface, are skipped. The highest utilization of all
+// interfaces is used as the utilization of the component.
+func (n *NetworkIO) CollectUtilization(outputs map[string]utils.ParsedOutput) error {
+	cmd := "netdev"
+	parsedOutput, ok := outputs[cmd]
+	if !ok {
+		return fmt.Errorf("missing output for %q", cmd)
+	}
+	times, ok := parsedOutput["time"]
+	if !ok || len(times) < 2 {
+		return fmt.Errorf("need at least 2 samples of %q to calculate throughput", cmd)
+	}
+	start, err := strconv.ParseFloat(times[0], 64)
+	if err != nil {
+		return fmt.Errorf("failed to convert %q to float: %v", times[0], err)
+	}
+	end, err := strconv.ParseFloat(times[len(times)-1], 64)
+	if err != nil {
+		return fmt.Errorf("failed to convert %q to float: %v", times[len(times)-1], err)
+	}
+	elapsed := end - start
+	if elapsed <= 0 {
+		return fmt.Errorf("invalid sampling interval of %v seconds", elapsed)
+	}
+	n.interfaces = []string{}
+	var maxUtil float64
+	for _, iface := range netDevInterfaces(parsedOutput, "speed") {
+		speed, err := strconv.ParseFloat(parsedOutput[iface+":speed"][0], 64)
+		// link speed is -1 if the interface is down.
+		if err != nil || speed <= 0 {
+			log.Debugf("skipping interface %q with link speed %v", iface, parsedOutput[iface+":speed"])
+			continue
+		}
+		rx, err := counterDelta(parsedOutput, iface+":rx_bytes")
+		if err != nil {
+			return err
+		}
+		tx, err := counterDelta(parsedOutput, iface+":tx_bytes")
+		if err != nil {
+			return err
+		}
+		bytes := math.Max(float64(rx), float64(tx))
+		// speed is in megabits per second.
+		util := bytes * 8 / elapsed / (speed * 1e6) * 100
+		maxUtil = math.Max(maxUtil, util)
+		n.interfaces = append(n.interfaces, iface)
+	}
+	if len(n.interfaces) == 0 {
+		return fmt.Errorf("failed to find an interface with a link speed")
+	}
+	n.metrics.Utilization = math.Round(maxUtil*100) / 100
+	return nil
+}
+
+// CollectSaturation collects the saturation value for Network I/O.
+// The Network I/O component is saturated if the number of packets dropped
+// or the number of FIFO buffer errors increased on any interface while
+// the 'netdev' command was sampling.
+func (n *NetworkIO) CollectSaturation(outputs map[string]utils.ParsedOutput) error {
+	cmd := "netdev"
+	parsedOutput, ok := outputs[cmd]
+	if !ok {
+		return fmt.Errorf("missing output for %q", cmd)
+	}
+	saturated := false
+	for _, title := range []string{"rx_drop", "tx_drop", "rx_fifo", "tx_fifo"} {
+		for _, iface := range netDevInterfaces(parsedOutput, title) {
+			delta, err := counterDelta(parsedOutput, iface+":"+title)
+			if err != nil {
+				return err
+			}
+			if delta > 0 {
+				saturated = true
+			}
+		}
+	}
+	n.metrics.Saturation = saturated
+	return nil
+}
+
+// CollectErrors collects errors for the Network I/O component. It does this
+// by summing the increase of the receive and transmit error counters of all
+// interfaces while the 'netdev' command was sampling.
+func (n *NetworkIO) CollectErrors(outputs map[string]utils.ParsedOutput) error {
+	cmd := "netdev"
+	parsedOutput, ok := outputs[cmd]
+	if !ok {
+		return fmt.Errorf("missing output for %q", cmd)
+	}
+	var errs int64
+	for _, title := range []string{"rx_errs", "tx_errs"} {
+		for _, iface := range netDevInterfaces(parsedOutput, title) {
+			delta, err := counterDelta(parsedOutput, iface+":"+title)
+			if err != nil {
+				return err
+			}
+			errs += delta
+		}
+	}
+	n.metrics.Errors = errs
+	return nil
+}
+
+// FileDescriptors holds information about the file descriptors component:
+// name and USE Metrics collected.
+type FileDescriptors struct {
+	name       string
+	metrics    *USEMetrics
+	thresholds Thresholds
+}
+
+// NewFileDescriptors holds information about the file descriptors
+// component: this can be used to initialize FileDescriptors outside of the
+// profiler package. Only the FDPercent threshold applies to
+// FileDescriptors.
+func NewFileDescriptors(name string, thresholds Thresholds) *FileDescriptors {
+	return &FileDescriptors{
+		name:       name,
+		metrics:    &USEMetrics{},
+		thresholds: thresholds,
+	}
+}
+
+// AdditionalInformation returns additional information unique to the
+// the FileDescriptors component.
+func (f *FileDescriptors) AdditionalInformation() string {
+	return "The utilization value for this component is the percentage of the " +
+		"system-wide maximum number of file handles (fs.file-max) in use."
+}
+
+// Name returns the name of the file descriptors component.
+func (f *FileDescriptors) Name() string {
+	return f.name
+}
+
+// USEMetrics returns the USEMetrics of the file descriptors component.
+func (f *FileDescriptors) USEMetrics() *USEMetrics {
+	return f.metrics
+}
+
+// CollectUtilization calculates the utilization value for the file
+// descriptors component. It does this by dividing the number of file handles
+// in use, i.e. allocated but not unused, by the maximum number of file
+// handles. These values are found on the filenr command's "allocated",
+// "unused" and "max" titles, read from /proc/sys/fs/file-nr.
+func (f *FileDescriptors) CollectUtilization(outputs map[string]utils.ParsedOutput) error {
+	cmd := "filenr"
+	parsedOutput, ok := outputs[cmd]
+	if !ok {
+		return fmt.Errorf("missing output for %q", cmd)
+	}
+	values := make(map[string]float64)
+	for _, title := range []string{"allocated", "unused", "max"} {
+		val, ok := parsedOutput[title]
+		if !ok || len(val) == 0 {
+			return fmt.Errorf("missing filenr title %q", title)
+		}
+		num, err := strconv.ParseFloat(val[0], 64)
+		if err != nil {
+			return fmt.Errorf("failed to convert filenr title %q value %q to float: %v", title, val[0], err)
+		}
+		values[title] = num
+	}
+	if values["max"] <= 0 {
+		return fmt.Errorf("invalid maximum number of file handles %v", values["max"])
+	}
+	util := (values["allocated"] - values["unused"]) / values["max"] * 100
+	f.metrics.Utilization = math.Round(util*100) / 100
+	return nil
+}
+
+// CollectSaturation collects the saturation value for the file descriptors
+// component. The component is saturated when its utilization is above 90%
+// (the FDPercent threshold), since opening files fails once the maximum
+// number of file handles is reached. It relies on the utilization collected
+// by CollectUtilization.
+func (f *FileDescriptors) CollectSaturation(outputs map[string]utils.ParsedOutput) error {
+	f.metrics.Saturation = f.metrics.Utilization > f.thresholds.withDefaults().FDPercent
+	return nil
+}
+
+// fdErrorPatterns match the kernel log messages reporting that the
+// maximum number of file handles was reached, or that a process got
+// EMFILE ("Too many open files") errors.
+var fdErrorPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)file-max limit \d+ reached`),
+	regexp.MustCompile(`(?i)too many open files|\bEMFILE\b`),
+}
+
+// CollectErrors collects errors for the file descriptors component. It does
+// this by counting the lines of the kernel ring buffer, found on dmesg's
+// output, reporting that the system ran out of file handles, ex. "VFS:
+// file-max limit 8192 reached", or that a process had too many open files.
+// As for CPU, only the lines logged since the previous collection are
+// counted.
+func (f *FileDescriptors) CollectErrors(outputs map[string]utils.ParsedOutput) error {
+	cmd := "dmesg"
+	parsedOutput, ok := outputs[cmd]
+	if !ok {
+		return fmt.Errorf("missing output for %q", cmd)
+	}
+	messages, ok := parsedOutput["messages"]
+	if !ok {
+		return fmt.Errorf("missing dmesg title 'messages'")
+	}
+	var errs int64
+	for _, message := range messages {
+		for _, pattern := range fdErrorPatterns {
+			if pattern.MatchString(message) {
+				errs++
+				break
+			}
+		}
+	}
+	f.metrics.Errors = errs
+	return nil
+}
+
 // CollectUSEMetrics collects USE Metrics for the component specified. It does this by calling
 // the necessary methods to collect utilization, saturation and errors.
 func CollectUSEMetrics(component Component, outputs map[string]utils.ParsedOutput) error {
@@ -564,6 +1194,10 @@ func CollectUSEMetrics(component Component, outputs map[string]utils.ParsedOutpu
 		gotErr = true
 		log.Errorf("failed to collect saturation for %q: %v", component.Name(), err)
 	}
+	if err := component.CollectErrors(outputs); err != nil {
+		gotErr = true
+		log.Errorf("failed to collect errors for %q: %v", component.Name(), err)
+	}
 	end := time.Now()
 	metrics.Interval = end.Sub(start)
 	if gotErr {
@@ -595,6 +1229,7 @@ func GenerateUSEReport(components []Component, cmds []Command) (USEReport, error
 			failed = append(failed, s.Name())
 		}
 	}
+	useReport.Failed = failed
 	if len(failed) != 0 {
 		err := "failed to generate USE report for %s components" +
 			"Please check the logs for more information"
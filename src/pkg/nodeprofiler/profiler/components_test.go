@@ -1,9 +1,12 @@
 package profiler
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"cos.googlesource.com/cos/tools.git/src/pkg/nodeprofiler/utils"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestCollectUtilization(t *testing.T) {
@@ -16,7 +19,7 @@ func TestCollectUtilization(t *testing.T) {
 	}{
 		{
 			name:      "cpu",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"us": {"1", "2", "7"},
@@ -28,7 +31,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "vmstat slices of length 1",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"us": {"1"},
@@ -40,7 +43,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "empty vmstat slices",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"us": {},
@@ -52,7 +55,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "missing titles",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"us": {"1", "2", "7"},
@@ -63,13 +66,13 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "missing commands output",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs:   map[string]utils.ParsedOutput{},
 			wantErr:   true,
 		},
 		{
 			name:      "memory capacity",
-			component: &MemCap{"fake", &USEMetrics{}},
+			component: &MemCap{"fake", &USEMetrics{}, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"free": {
 					"Mem:used":   {"13"},
@@ -82,7 +85,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "missing titles",
-			component: &MemCap{"fake", &USEMetrics{}},
+			component: &MemCap{"fake", &USEMetrics{}, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"free": {},
 			},
@@ -90,7 +93,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "missing commands output",
-			component: &MemCap{"fake", &USEMetrics{}},
+			component: &MemCap{"fake", &USEMetrics{}, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"si": {"0", "0", "3"},
@@ -101,7 +104,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "storage device I/O",
-			component: &StorageDevIO{"fake", &USEMetrics{}},
+			component: NewStorageDevIO("fake", nil, Thresholds{}),
 			outputs: map[string]utils.ParsedOutput{
 				"iostat": {
 					"%util": {"4.76", "0.09"},
@@ -109,9 +112,41 @@ func TestCollectUtilization(t *testing.T) {
 			},
 			want: 2.425,
 		},
+		{
+			name:      "storage device I/O with devices",
+			component: NewStorageDevIO("fake", []string{"sda", "/dev/sdb"}, Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"Device": {"sda", "sdb", "sdc", "sda", "sdb", "sdc"},
+					"%util":  {"90.00", "10.00", "50.00", "70.00", "30.00", "50.00"},
+				},
+			},
+			want: 50,
+		},
+		{
+			name:      "storage device I/O idle devices",
+			component: NewStorageDevIO("fake", []string{"sdd"}, Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"Device": {"sda"},
+					"%util":  {"90.00"},
+				},
+			},
+			want: 0,
+		},
+		{
+			name:      "storage device I/O missing device column",
+			component: NewStorageDevIO("fake", []string{"sda"}, Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"%util": {"4.76", "0.09"},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name:      "missing titles",
-			component: &StorageDevIO{"fake", &USEMetrics{}},
+			component: NewStorageDevIO("fake", nil, Thresholds{}),
 			outputs: map[string]utils.ParsedOutput{
 				"iostat": {
 					"%idle": {"94.27"},
@@ -121,13 +156,13 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "missing commands output",
-			component: &StorageDevIO{"fake", &USEMetrics{}},
+			component: NewStorageDevIO("fake", nil, Thresholds{}),
 			outputs:   map[string]utils.ParsedOutput{},
 			wantErr:   true,
 		},
 		{
 			name:      "storage capacity",
-			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}},
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}, 90},
 			outputs: map[string]utils.ParsedOutput{
 				"df": {
 					"Filesystem": {"/dev/vdb", "/dev/vda"},
@@ -139,7 +174,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "devices not set",
-			component: &StorageCap{"fake", &USEMetrics{}, []string{}},
+			component: &StorageCap{"fake", &USEMetrics{}, []string{}, 90},
 			outputs: map[string]utils.ParsedOutput{
 				"df": {
 					"Filesystem": {"/dev/sda", "/dev/vda"},
@@ -151,7 +186,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "device (sda) with different partitions",
-			component: &StorageCap{"fake", &USEMetrics{}, []string{}},
+			component: &StorageCap{"fake", &USEMetrics{}, []string{}, 90},
 			outputs: map[string]utils.ParsedOutput{
 				"df": {
 					"Filesystem": {"/dev/sda1", "/dev/sda8"},
@@ -163,7 +198,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "several occurrences of same device",
-			component: &StorageCap{"fake", &USEMetrics{}, []string{"tmpfs"}},
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"tmpfs"}, 90},
 			outputs: map[string]utils.ParsedOutput{
 				"df": {
 					"Filesystem": {"tmpfs", "tmpfs", "/dev/root", "tmpfs"},
@@ -175,7 +210,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "missing [default] device",
-			component: &StorageCap{"fake", &USEMetrics{}, []string{}},
+			component: &StorageCap{"fake", &USEMetrics{}, []string{}, 90},
 			outputs: map[string]utils.ParsedOutput{
 				"df": {
 					"Filesystem": {"/dev/vdb", "/dev/vda"},
@@ -187,7 +222,7 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "missing titles",
-			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}},
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}, 90},
 			outputs: map[string]utils.ParsedOutput{
 				"df": {
 					"Use%": {"67%", "0%", "100%", "2%"},
@@ -197,10 +232,97 @@ func TestCollectUtilization(t *testing.T) {
 		},
 		{
 			name:      "missing commands output",
-			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}},
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}, 90},
 			outputs:   map[string]utils.ParsedOutput{},
 			wantErr:   true,
 		},
+		{
+			name:      "network io",
+			component: NewNetworkIO("fake"),
+			outputs: map[string]utils.ParsedOutput{
+				"netdev": {
+					"time":          {"100.000", "102.000"},
+					"lo:rx_bytes":   {"0", "900000000"},
+					"lo:tx_bytes":   {"0", "900000000"},
+					"eth0:rx_bytes": {"0", "25000000"},
+					"eth0:tx_bytes": {"0", "5000000"},
+					"eth0:speed":    {"1000"},
+				},
+			},
+			want: 10,
+		},
+		{
+			name:      "network io single sample",
+			component: NewNetworkIO("fake"),
+			outputs: map[string]utils.ParsedOutput{
+				"netdev": {
+					"time":          {"100.000"},
+					"eth0:rx_bytes": {"0"},
+					"eth0:tx_bytes": {"0"},
+					"eth0:speed":    {"1000"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "network io without link speed",
+			component: NewNetworkIO("fake"),
+			outputs: map[string]utils.ParsedOutput{
+				"netdev": {
+					"time":        {"100.000", "102.000"},
+					"lo:rx_bytes": {"0", "900000000"},
+					"lo:tx_bytes": {"0", "900000000"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "file descriptors",
+			component: NewFileDescriptors("fake", Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"filenr": {
+					"allocated": {"1024"},
+					"unused":    {"0"},
+					"max":       {"9223372036854775807"},
+				},
+			},
+			want: 0,
+		},
+		{
+			name:      "file descriptors near limit",
+			component: NewFileDescriptors("fake", Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"filenr": {
+					"allocated": {"7680"},
+					"unused":    {"0"},
+					"max":       {"8192"},
+				},
+			},
+			want: 93.75,
+		},
+		{
+			name:      "missing filenr title",
+			component: NewFileDescriptors("fake", Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"filenr": {
+					"allocated": {"1024"},
+					"unused":    {"0"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "zero file-max",
+			component: NewFileDescriptors("fake", Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"filenr": {
+					"allocated": {"1024"},
+					"unused":    {"0"},
+					"max":       {"0"},
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, test := range tests {
 		err := test.component.CollectUtilization(test.outputs)
@@ -223,7 +345,7 @@ func TestCollectSaturation(t *testing.T) {
 	}{
 		{
 			name:      "CPU",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"r": {"15", "12", "12"},
@@ -236,7 +358,7 @@ func TestCollectSaturation(t *testing.T) {
 		},
 		{
 			name:      "vmstat slices of length 1",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"r": {"15"},
@@ -249,7 +371,7 @@ func TestCollectSaturation(t *testing.T) {
 		},
 		{
 			name:      "empty vmstat slices",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"r": {},
@@ -262,7 +384,7 @@ func TestCollectSaturation(t *testing.T) {
 		},
 		{
 			name:      "missing titles",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {},
 				"lscpu": {
@@ -273,7 +395,7 @@ func TestCollectSaturation(t *testing.T) {
 		},
 		{
 			name:      "missing commands output",
-			component: &CPU{"fake", &USEMetrics{}},
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"r": {"10", "10", "10"},
@@ -283,7 +405,7 @@ func TestCollectSaturation(t *testing.T) {
 		},
 		{
 			name:      "Memory capacity",
-			component: &MemCap{"fake", &USEMetrics{}},
+			component: &MemCap{"fake", &USEMetrics{}, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"si": {"0", "7", "3"},
@@ -296,7 +418,7 @@ func TestCollectSaturation(t *testing.T) {
 		},
 		{
 			name:      "missing commands output",
-			component: &MemCap{"fake", &USEMetrics{}},
+			component: &MemCap{"fake", &USEMetrics{}, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"si": {"0", "0", "3"},
@@ -307,7 +429,7 @@ func TestCollectSaturation(t *testing.T) {
 		},
 		{
 			name:      "missing titles",
-			component: &MemCap{"fake", &USEMetrics{}},
+			component: &MemCap{"fake", &USEMetrics{}, Thresholds{}},
 			outputs: map[string]utils.ParsedOutput{
 				"vmstat": {
 					"si": {"0", "0", "3"},
@@ -319,27 +441,380 @@ func TestCollectSaturation(t *testing.T) {
 		},
 		{
 			name:      "Storage Device",
-			component: &StorageDevIO{"fake", &USEMetrics{}},
+			component: NewStorageDevIO("fake", nil, Thresholds{}),
 			outputs: map[string]utils.ParsedOutput{
 				"iostat": {
 					"aqu-sz": {"0.04", "0.00"},
 				},
 			},
 		},
+		{
+			name:      "Storage Device busy device",
+			component: NewStorageDevIO("fake", []string{"sdb"}, Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"Device": {"sda", "sdb", "sda", "sdb"},
+					"aqu-sz": {"0.00", "2.50", "0.00", "1.50"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Storage Device busy device filtered out",
+			component: NewStorageDevIO("fake", []string{"sda"}, Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"Device": {"sda", "sdb", "sda", "sdb"},
+					"aqu-sz": {"0.00", "2.50", "0.00", "1.50"},
+				},
+			},
+		},
 		{
 			name:      "missing commands output",
-			component: &StorageDevIO{"fake", &USEMetrics{}},
+			component: NewStorageDevIO("fake", nil, Thresholds{}),
 			outputs:   map[string]utils.ParsedOutput{},
 			wantErr:   true,
 		},
 		{
 			name:      "missing titles",
-			component: &StorageDevIO{"fake", &USEMetrics{}},
+			component: NewStorageDevIO("fake", nil, Thresholds{}),
 			outputs: map[string]utils.ParsedOutput{
 				"iostat": {},
 			},
 			wantErr: true,
 		},
+		{
+			name:      "CPU stalled tasks",
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"r": {"1", "1", "1"},
+				},
+				"lscpu": {
+					"CPU(s)": {"8"},
+				},
+				"psi": {
+					"cpu:some_avg10": {"12.50"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "CPU PSI under threshold",
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"r": {"1", "1", "1"},
+				},
+				"lscpu": {
+					"CPU(s)": {"8"},
+				},
+				"psi": {
+					"cpu:some_avg10": {"10.00"},
+				},
+			},
+		},
+		{
+			name:      "CPU PSI under threshold with busy run queue",
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"r": {"15", "12", "12"},
+				},
+				"lscpu": {
+					"CPU(s)": {"8"},
+				},
+				"psi": {
+					"cpu:some_avg10": {"0.00"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "CPU invalid PSI",
+			component: &CPU{"fake", &USEMetrics{}, nil, Thresholds{}},
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"r": {"1", "1", "1"},
+				},
+				"lscpu": {
+					"CPU(s)": {"8"},
+				},
+				"psi": {
+					"cpu:some_avg10": {"high"},
+				},
+			},
+		},
+		{
+			name:      "Memory capacity stalled tasks",
+			component: &MemCap{"fake", &USEMetrics{}, Thresholds{}},
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"si": {"0", "7", "3"},
+					"so": {"0", "8", "5"},
+				},
+				"free": {
+					"Swap:total": {"701"},
+				},
+				"psi": {
+					"memory:some_avg10": {"25.00"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Memory capacity without PSI",
+			component: &MemCap{"fake", &USEMetrics{}, Thresholds{}},
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"si": {"0", "7", "3"},
+					"so": {"0", "8", "5"},
+				},
+				"free": {
+					"Swap:total": {"701"},
+				},
+				"psi": {},
+			},
+		},
+		{
+			name:      "Storage Device stalled tasks",
+			component: NewStorageDevIO("fake", nil, Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"aqu-sz": {"0.04", "0.00"},
+				},
+				"psi": {
+					"io:some_avg10": {"31.07"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Memory capacity custom swap threshold",
+			component: NewMemCap("fake", Thresholds{MemSwapPercent: 0.001}),
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"si": {"0", "7", "3"},
+					"so": {"0", "8", "5"},
+				},
+				"free": {
+					"Swap:total": {"701"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Memory capacity without swap custom utilization threshold",
+			component: &MemCap{"fake", &USEMetrics{Utilization: 90}, Thresholds{MemUtilizationPercent: 80}},
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"si": {"0", "0", "0"},
+					"so": {"0", "0", "0"},
+				},
+				"free": {
+					"Swap:total": {"0"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Memory capacity without swap default utilization threshold",
+			component: &MemCap{"fake", &USEMetrics{Utilization: 90}, Thresholds{}},
+			outputs: map[string]utils.ParsedOutput{
+				"vmstat": {
+					"si": {"0", "0", "0"},
+					"so": {"0", "0", "0"},
+				},
+				"free": {
+					"Swap:total": {"0"},
+				},
+			},
+		},
+		{
+			name:      "Storage Device custom queue length threshold",
+			component: NewStorageDevIO("fake", []string{"sdb"}, Thresholds{StorageQueueLength: 2.5}),
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"Device": {"sda", "sdb", "sda", "sdb"},
+					"aqu-sz": {"0.00", "2.50", "0.00", "1.50"},
+				},
+			},
+		},
+		{
+			name:      "Storage Device custom PSI threshold",
+			component: NewStorageDevIO("fake", nil, Thresholds{PSIPercent: 40}),
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"aqu-sz": {"0.04", "0.00"},
+				},
+				"psi": {
+					"io:some_avg10": {"31.07"},
+				},
+			},
+		},
+		{
+			name:      "Storage Capacity custom threshold",
+			component: NewStorageCap("fake", Thresholds{StorageCapPercent: 50}),
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/sda"},
+					"Used":       {"600"},
+					"1K-blocks":  {"1000"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Storage Capacity under threshold",
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}, 90},
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/vdb", "/dev/vda"},
+					"Used":       {"990", "899"},
+					"1K-blocks":  {"1000", "1000"},
+				},
+			},
+		},
+		{
+			name:      "Storage Capacity at threshold",
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}, 90},
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/vda"},
+					"Used":       {"900"},
+					"1K-blocks":  {"1000"},
+				},
+			},
+		},
+		{
+			name:      "Storage Capacity over threshold",
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}, 90},
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/vda"},
+					"Used":       {"901"},
+					"1K-blocks":  {"1000"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Storage Capacity single full device",
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/sda", "/dev/sdb"}, 90},
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/sda1", "/dev/sda8", "/dev/sdb"},
+					"Used":       {"100", "0", "950"},
+					"1K-blocks":  {"10000", "1000", "1000"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Storage Capacity single full partition",
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/sda"}, 90},
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/sda1", "/dev/sda8"},
+					"Used":       {"1000", "0"},
+					"1K-blocks":  {"1000", "1000"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Storage Capacity partitions under threshold",
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/sda"}, 90},
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/sda1", "/dev/sda8"},
+					"Used":       {"900", "100"},
+					"1K-blocks":  {"1000", "1000"},
+				},
+			},
+		},
+		{
+			name:      "Storage Capacity custom threshold",
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vda"}, 50},
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/vda"},
+					"Used":       {"600"},
+					"1K-blocks":  {"1000"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Storage Capacity default threshold",
+			component: NewStorageCap("fake", Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/sda"},
+					"Used":       {"950"},
+					"1K-blocks":  {"1000"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Storage Capacity missing device",
+			component: &StorageCap{"fake", &USEMetrics{}, []string{"/dev/vdc"}, 90},
+			outputs: map[string]utils.ParsedOutput{
+				"df": {
+					"Filesystem": {"/dev/vda"},
+					"Used":       {"950"},
+					"1K-blocks":  {"1000"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "Network I/O",
+			component: NewNetworkIO("fake"),
+			outputs: map[string]utils.ParsedOutput{
+				"netdev": {
+					"eth0:rx_drop": {"0", "0", "0"},
+					"eth0:tx_drop": {"0", "0", "0"},
+					"eth0:rx_fifo": {"0", "0", "0"},
+					"eth0:tx_fifo": {"3", "3", "4"},
+				},
+			},
+			want: true,
+		},
+		{
+			name:      "Network I/O constant counters",
+			component: NewNetworkIO("fake"),
+			outputs: map[string]utils.ParsedOutput{
+				"netdev": {
+					"eth0:rx_drop": {"7", "7", "7"},
+					"eth0:tx_drop": {"0", "0", "0"},
+				},
+			},
+		},
+		{
+			name:      "missing commands output",
+			component: NewNetworkIO("fake"),
+			outputs:   map[string]utils.ParsedOutput{},
+			wantErr:   true,
+		},
+		{
+			name:      "file descriptors under threshold",
+			component: &FileDescriptors{"fake", &USEMetrics{Utilization: 85}, Thresholds{}},
+			outputs:   map[string]utils.ParsedOutput{},
+		},
+		{
+			name:      "file descriptors above threshold",
+			component: &FileDescriptors{"fake", &USEMetrics{Utilization: 93.75}, Thresholds{}},
+			outputs:   map[string]utils.ParsedOutput{},
+			want:      true,
+		},
+		{
+			name:      "file descriptors custom threshold",
+			component: &FileDescriptors{"fake", &USEMetrics{Utilization: 85}, Thresholds{FDPercent: 80}},
+			outputs:   map[string]utils.ParsedOutput{},
+			want:      true,
+		},
 	}
 	for _, test := range tests {
 		err := test.component.CollectSaturation(test.outputs)
@@ -352,3 +827,358 @@ func TestCollectSaturation(t *testing.T) {
 		}
 	}
 }
+
+func TestStorageDevIOMaxUtilization(t *testing.T) {
+	tests := []struct {
+		name       string
+		devices    []string
+		outputs    map[string]utils.ParsedOutput
+		wantAvg    float64
+		wantDevice string
+		wantMax    float64
+		wantInfo   string
+	}{
+		{
+			name: "all devices",
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"Device": {"sda", "sdb", "sdc", "sda", "sdb", "sdc"},
+					"%util":  {"10.00", "100.00", "4.00", "20.00", "80.00", "2.00"},
+				},
+			},
+			wantAvg:    36,
+			wantDevice: "sdb",
+			wantMax:    90,
+			wantInfo:   "The busiest device was sdb, with an average utilization of 90.00%.",
+		},
+		{
+			name:    "selected devices",
+			devices: []string{"/dev/sda", "sdc"},
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"Device": {"sda", "sdb", "sdc", "sda", "sdb", "sdc"},
+					"%util":  {"10.00", "100.00", "4.00", "20.00", "80.00", "2.00"},
+				},
+			},
+			wantAvg:    9,
+			wantDevice: "sda",
+			wantMax:    15,
+			wantInfo: "The utilization value for this component was measured using the " +
+				"following devices: /dev/sda,sdc. The busiest device was sda, with an " +
+				"average utilization of 15.00%.",
+		},
+		{
+			name: "older iostat device column",
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"Device:": {"vda", "vdb"},
+					"%util":   {"5.00", "7.00"},
+				},
+			},
+			wantAvg:    6,
+			wantDevice: "vdb",
+			wantMax:    7,
+			wantInfo:   "The busiest device was vdb, with an average utilization of 7.00%.",
+		},
+		{
+			name: "no device column",
+			outputs: map[string]utils.ParsedOutput{
+				"iostat": {
+					"%util": {"5.00", "7.00"},
+				},
+			},
+			wantAvg: 6,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewStorageDevIO("fake", test.devices, Thresholds{})
+			if err := d.CollectUtilization(test.outputs); err != nil {
+				t.Fatalf("CollectUtilization(%v) unexpected error: %v", test.outputs, err)
+			}
+			if got := d.USEMetrics().Utilization; got != test.wantAvg {
+				t.Errorf("CollectUtilization(%v) utilization = %v, want: %v", test.outputs, got, test.wantAvg)
+			}
+			if d.maxDevice != test.wantDevice || d.maxUtilization != test.wantMax {
+				t.Errorf("CollectUtilization(%v) busiest device = %s (%v), want: %s (%v)",
+					test.outputs, d.maxDevice, d.maxUtilization, test.wantDevice, test.wantMax)
+			}
+			if diff := cmp.Diff(test.wantInfo, d.AdditionalInformation()); diff != "" {
+				t.Errorf("AdditionalInformation() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		component Component
+		outputs   map[string]utils.ParsedOutput
+		want      int64
+		wantErr   bool
+	}{
+		{
+			name:      "Network I/O",
+			component: NewNetworkIO("fake"),
+			outputs: map[string]utils.ParsedOutput{
+				"netdev": {
+					"lo:rx_errs":   {"0", "0", "0"},
+					"lo:tx_errs":   {"0", "0", "0"},
+					"eth0:rx_errs": {"2", "3", "5"},
+					"eth0:tx_errs": {"1", "1", "2"},
+				},
+			},
+			want: 4,
+		},
+		{
+			name:      "CPU",
+			component: NewCPU("fake", Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"dmesg": {
+					"messages": {
+						"[ 3072.664109] watchdog: BUG: soft lockup - CPU#1 stuck for 22s! [kworker/1:2:1234]",
+						"[ 4096.000001] EXT4-fs (sda1): mounted filesystem with ordered data mode. Opts: (null)",
+					},
+				},
+			},
+			want: 1,
+		},
+		{
+			name:      "missing dmesg title",
+			component: NewCPU("fake", Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"dmesg": {},
+			},
+			wantErr: true,
+		},
+		{
+			// errors are unknown when dmesg is unavailable.
+			name:      "missing dmesg output",
+			component: NewCPU("fake", Thresholds{}),
+			outputs:   map[string]utils.ParsedOutput{},
+			want:      0,
+		},
+		{
+			name:      "invalid counter",
+			component: NewNetworkIO("fake"),
+			outputs: map[string]utils.ParsedOutput{
+				"netdev": {
+					"eth0:rx_errs": {"2", "three"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "missing commands output",
+			component: NewNetworkIO("fake"),
+			outputs:   map[string]utils.ParsedOutput{},
+			wantErr:   true,
+		},
+		{
+			name:      "file descriptors",
+			component: NewFileDescriptors("fake", Thresholds{}),
+			outputs: map[string]utils.ParsedOutput{
+				"dmesg": {
+					"messages": {
+						"[ 1024.000001] VFS: file-max limit 8192 reached",
+						"[ 1025.000002] nginx[4321]: accept4() failed (24: Too many open files)",
+						"[ 3072.664109] watchdog: BUG: soft lockup - CPU#1 stuck for 22s! [kworker/1:2:1234]",
+					},
+				},
+			},
+			want: 2,
+		},
+	}
+	for _, test := range tests {
+		err := test.component.CollectErrors(test.outputs)
+		got := test.component.USEMetrics().Errors
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Fatalf("CollectErrors(%v) err %v, wantErr %t", test.outputs, err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("CollectErrors(%v) = %v, want: %v", test.outputs, got, test.want)
+		}
+	}
+}
+
+func TestCPUCollectErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		fakeCmd Command
+		want    int64
+	}{
+		{
+			// the buffer reports 2 machine check exception lines, 2 thermal
+			// throttling lines and 1 soft lockup line.
+			name:    "errors",
+			fakeCmd: NewDMesg("testdata/dmesg.sh", ""),
+			want:    5,
+		},
+		{
+			name:    "clean",
+			fakeCmd: NewDMesg("testdata/dmesg_clean.sh", ""),
+			want:    0,
+		},
+	}
+	for _, test := range tests {
+		output, err := test.fakeCmd.Run()
+		if err != nil {
+			t.Fatalf("%s: Run() err %v", test.name, err)
+		}
+		cpu := NewCPU("fake", Thresholds{})
+		if err := cpu.CollectErrors(map[string]utils.ParsedOutput{"dmesg": output}); err != nil {
+			t.Fatalf("%s: CollectErrors() err %v", test.name, err)
+		}
+		if got := cpu.USEMetrics().Errors; got != test.want {
+			t.Errorf("%s: CollectErrors() = %v, want: %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCPUCollectUSEMetricsWithoutDMesg(t *testing.T) {
+	cpu := NewCPU("fake", Thresholds{})
+	outputs := map[string]utils.ParsedOutput{
+		"vmstat": {
+			"r":  {"15", "12", "12"},
+			"us": {"1", "2", "7"},
+			"sy": {"0", "1", "3"},
+			"st": {"0", "0", "0"},
+		},
+		"lscpu": {
+			"CPU(s)": {"8"},
+		},
+	}
+	if err := CollectUSEMetrics(cpu, outputs); err != nil {
+		t.Fatalf("CollectUSEMetrics() err %v, want nil", err)
+	}
+	metrics := cpu.USEMetrics()
+	if metrics.Utilization != 6.5 || !metrics.Saturation || metrics.Errors != 0 {
+		t.Errorf("CollectUSEMetrics() = %+v, want utilization 6.5, saturation true and 0 errors", *metrics)
+	}
+}
+
+func TestFileDescriptorsErrorsSincePrevious(t *testing.T) {
+	buffer := filepath.Join(t.TempDir(), "dmesg.txt")
+	// the fake dmesg prints the contents of buffer.
+	cmd := NewDMesg("cat", buffer)
+	collections := []struct {
+		name   string
+		buffer string
+		want   int64
+	}{
+		{
+			name:   "first collection",
+			buffer: "[ 1024.000001] VFS: file-max limit 8192 reached\n",
+			want:   1,
+		},
+		{
+			name:   "no new errors",
+			buffer: "[ 1024.000001] VFS: file-max limit 8192 reached\n",
+			want:   0,
+		},
+		{
+			name: "new errors",
+			buffer: "[ 1024.000001] VFS: file-max limit 8192 reached\n" +
+				"[ 2048.000001] VFS: file-max limit 8192 reached\n" +
+				"[ 2049.000001] app[123]: Too many open files\n",
+			want: 2,
+		},
+	}
+	fds := NewFileDescriptors("fake", Thresholds{})
+	for _, collection := range collections {
+		if err := ioutil.WriteFile(buffer, []byte(collection.buffer), 0644); err != nil {
+			t.Fatal(err)
+		}
+		output, err := cmd.Run()
+		if err != nil {
+			t.Fatalf("%s: Run() err %v", collection.name, err)
+		}
+		if err := fds.CollectErrors(map[string]utils.ParsedOutput{"dmesg": output}); err != nil {
+			t.Fatalf("%s: CollectErrors() err %v", collection.name, err)
+		}
+		if got := fds.USEMetrics().Errors; got != collection.want {
+			t.Errorf("%s: CollectErrors() = %v, want: %v", collection.name, got, collection.want)
+		}
+	}
+}
+
+func TestCollectLoadAverages(t *testing.T) {
+	tests := []struct {
+		name     string
+		outputs  map[string]utils.ParsedOutput
+		want     []float64
+		wantInfo string
+		wantErr  bool
+	}{
+		{
+			name: "load averages",
+			outputs: map[string]utils.ParsedOutput{
+				"loadavg": {
+					"1min":  {"0.20"},
+					"5min":  {"0.18"},
+					"15min": {"0.12"},
+				},
+				"lscpu": {
+					"CPU(s)": {"4"},
+				},
+			},
+			want: []float64{0.05, 0.05, 0.03},
+			wantInfo: "The 1, 5 and 15-minute load averages normalized by CPU count " +
+				"were 0.05, 0.05 and 0.03.",
+		},
+		{
+			name: "overloaded",
+			outputs: map[string]utils.ParsedOutput{
+				"loadavg": {
+					"1min":  {"10.00"},
+					"5min":  {"6.00"},
+					"15min": {"2.00"},
+				},
+				"lscpu": {
+					"CPU(s)": {"8"},
+				},
+			},
+			want: []float64{1.25, 0.75, 0.25},
+			wantInfo: "The 1, 5 and 15-minute load averages normalized by CPU count " +
+				"were 1.25, 0.75 and 0.25. The 1-minute load average exceeds the CPU " +
+				"count, which indicates that the CPU is saturated.",
+		},
+		{
+			name: "missing titles",
+			outputs: map[string]utils.ParsedOutput{
+				"loadavg": {
+					"1min": {"0.20"},
+				},
+				"lscpu": {
+					"CPU(s)": {"4"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing commands output",
+			outputs: map[string]utils.ParsedOutput{
+				"loadavg": {
+					"1min":  {"0.20"},
+					"5min":  {"0.18"},
+					"15min": {"0.12"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		cpu := NewCPU("fake", Thresholds{})
+		err := cpu.collectLoadAverages(test.outputs)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Fatalf("%s: collectLoadAverages(%v) err %v, wantErr %t", test.name, test.outputs, err, test.wantErr)
+		}
+		if diff := cmp.Diff(test.want, cpu.loadAverages); diff != "" {
+			t.Errorf("%s: collectLoadAverages(%v) mismatch (-want, +got): \n diff %s", test.name, test.outputs, diff)
+		}
+		if got := cpu.AdditionalInformation(); got != test.wantInfo {
+			t.Errorf("%s: AdditionalInformation() = %q, want: %q", test.name, got, test.wantInfo)
+		}
+	}
+}
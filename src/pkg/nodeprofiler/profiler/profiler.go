@@ -5,6 +5,7 @@
 package profiler
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -41,6 +42,43 @@ type USEReport struct {
 	// Analysis provides insights into the USE metrics collected, including
 	// a guess as to which component may be causing performance issues.
 	Analysis string
+	// Failed contains the names of the components whose USE metrics could
+	// not be collected. Their metrics are not up to date.
+	Failed []string
+}
+
+// ComponentReport contains the name, USE metrics and additional information
+// of a component. Unlike Component, which is an interface, it can be
+// serialized, ex. to JSON.
+type ComponentReport struct {
+	Name       string
+	Metrics    *USEMetrics
+	Additional string
+}
+
+// ComponentReports returns the reports of the components of a USE report.
+func (r USEReport) ComponentReports() []ComponentReport {
+	reports := make([]ComponentReport, 0, len(r.Components))
+	for _, c := range r.Components {
+		reports = append(reports, ComponentReport{
+			Name:       c.Name(),
+			Metrics:    c.USEMetrics(),
+			Additional: c.AdditionalInformation(),
+		})
+	}
+	return reports
+}
+
+// MarshalJSON encodes a USE report as the reports of its components and its
+// analysis.
+func (r USEReport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Components []ComponentReport
+		Analysis   string
+	}{
+		Components: r.ComponentReports(),
+		Analysis:   r.Analysis,
+	})
 }
 
 // ProfilerReport contains debugging information provided by the profiler
@@ -0,0 +1,51 @@
+package profiler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUSEReportMarshalJSON(t *testing.T) {
+	timestamp := time.Date(2021, 8, 11, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		report USEReport
+		want   string
+	}{
+		{
+			name: "components",
+			report: USEReport{
+				Components: []Component{
+					&CPU{"CPU", &USEMetrics{Timestamp: timestamp, Interval: time.Second, Utilization: 6.5, Saturation: true, Errors: 2}, []float64{0.5, 0.25, 0.125}, Thresholds{}},
+					&StorageCap{"StorageCap", &USEMetrics{Timestamp: timestamp, Utilization: 60.59}, []string{"/dev/sda"}, 90},
+				},
+				Analysis: "CPU is saturated",
+			},
+			want: `{"Components":[` +
+				`{"Name":"CPU","Metrics":{"Timestamp":"2021-08-11T10:30:00Z","Interval":1000000000,"Utilization":6.5,"Saturation":true,"Errors":2},` +
+				`"Additional":"The 1, 5 and 15-minute load averages normalized by CPU count were 0.50, 0.25 and 0.12."},` +
+				`{"Name":"StorageCap","Metrics":{"Timestamp":"2021-08-11T10:30:00Z","Interval":0,"Utilization":60.59,"Saturation":false,"Errors":0},` +
+				`"Additional":"The utilization value for this component was measured using the following devices: /dev/sda"}],` +
+				`"Analysis":"CPU is saturated"}`,
+		},
+		{
+			name:   "no components",
+			report: USEReport{},
+			want:   `{"Components":[],"Analysis":""}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := json.Marshal(test.report)
+			if err != nil {
+				t.Fatalf("json.Marshal(%v) unexpected error: %v", test.report, err)
+			}
+			if diff := cmp.Diff(test.want, string(got)); diff != "" {
+				t.Errorf("json.Marshal(%v) mismatch (-want +got):\n%s", test.report, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// prometheusGauge describes a gauge exported by WritePrometheus.
+type prometheusGauge struct {
+	name  string
+	help  string
+	value func(metrics *USEMetrics) float64
+}
+
+var prometheusGauges = []prometheusGauge{
+	{
+		name:  "cos_profiler_utilization",
+		help:  "Percent of time the component was busy servicing work.",
+		value: func(metrics *USEMetrics) float64 { return metrics.Utilization },
+	},
+	{
+		name: "cos_profiler_saturation",
+		help: "Whether the component is saturated (1) or not (0).",
+		value: func(metrics *USEMetrics) float64 {
+			if metrics.Saturation {
+				return 1
+			}
+			return 0
+		},
+	},
+	{
+		name:  "cos_profiler_errors",
+		help:  "Number of errors seen in the component.",
+		value: func(metrics *USEMetrics) float64 { return float64(metrics.Errors) },
+	},
+}
+
+// collectionErrorsGauge is exported by WritePrometheus for every component,
+// whether its USE metrics could be collected or not.
+var collectionErrorsGauge = prometheusGauge{
+	name: "cos_profiler_collection_errors",
+	help: "Whether the USE metrics of the component could not be collected (1) or could (0).",
+}
+
+// labelEscaper escapes label values as required by the Prometheus text
+// exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatPrometheusValue formats a sample value in the Prometheus text
+// exposition format.
+func formatPrometheusValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WritePrometheus writes the USE metrics of a USE report to w in the
+// Prometheus text exposition format, so that they can be scraped by
+// Prometheus. Utilization, saturation and errors are exported as the
+// cos_profiler_utilization, cos_profiler_saturation and cos_profiler_errors
+// gauges, labeled by component name. Saturation is exported as 1 if the
+// component is saturated and 0 otherwise. The USE metrics of the components
+// in report.Failed are not exported, so that stale values are not scraped;
+// instead, the cos_profiler_collection_errors gauge is 1 for them and 0 for
+// the other components.
+func WritePrometheus(w io.Writer, report USEReport) error {
+	failed := make(map[string]bool)
+	for _, name := range report.Failed {
+		failed[name] = true
+	}
+	bw := bufio.NewWriter(w)
+	for _, gauge := range prometheusGauges {
+		writePrometheusHeader(bw, gauge)
+		for _, component := range report.Components {
+			metrics := component.USEMetrics()
+			if metrics == nil || failed[component.Name()] {
+				continue
+			}
+			writePrometheusSample(bw, gauge, component, gauge.value(metrics))
+		}
+	}
+	writePrometheusHeader(bw, collectionErrorsGauge)
+	for _, component := range report.Components {
+		var value float64
+		if failed[component.Name()] {
+			value = 1
+		}
+		writePrometheusSample(bw, collectionErrorsGauge, component, value)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write Prometheus metrics: %v", err)
+	}
+	return nil
+}
+
+// writePrometheusHeader writes the HELP and TYPE lines of gauge to w.
+func writePrometheusHeader(w io.Writer, gauge prometheusGauge) {
+	fmt.Fprintf(w, "# HELP %s %s\n", gauge.name, gauge.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", gauge.name)
+}
+
+// writePrometheusSample writes the value of gauge for component to w.
+func writePrometheusSample(w io.Writer, gauge prometheusGauge, component Component, value float64) {
+	fmt.Fprintf(w, "%s{component=\"%s\"} %s\n", gauge.name,
+		labelEscaper.Replace(component.Name()), formatPrometheusValue(value))
+}
@@ -0,0 +1,128 @@
+package profiler
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// failingWriter is an io.Writer that always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWritePrometheus(t *testing.T) {
+	tests := []struct {
+		name   string
+		report USEReport
+		want   string
+	}{
+		{
+			name: "components",
+			report: USEReport{
+				Components: []Component{
+					&CPU{"CPU", &USEMetrics{Utilization: 6.5, Saturation: true, Errors: 2}, nil, Thresholds{}},
+					&MemCap{"MemCap", &USEMetrics{Utilization: 52.25}, Thresholds{}},
+				},
+			},
+			want: `# HELP cos_profiler_utilization Percent of time the component was busy servicing work.
+# TYPE cos_profiler_utilization gauge
+cos_profiler_utilization{component="CPU"} 6.5
+cos_profiler_utilization{component="MemCap"} 52.25
+# HELP cos_profiler_saturation Whether the component is saturated (1) or not (0).
+# TYPE cos_profiler_saturation gauge
+cos_profiler_saturation{component="CPU"} 1
+cos_profiler_saturation{component="MemCap"} 0
+# HELP cos_profiler_errors Number of errors seen in the component.
+# TYPE cos_profiler_errors gauge
+cos_profiler_errors{component="CPU"} 2
+cos_profiler_errors{component="MemCap"} 0
+# HELP cos_profiler_collection_errors Whether the USE metrics of the component could not be collected (1) or could (0).
+# TYPE cos_profiler_collection_errors gauge
+cos_profiler_collection_errors{component="CPU"} 0
+cos_profiler_collection_errors{component="MemCap"} 0
+`,
+		},
+		{
+			name: "escaped component name and NaN",
+			report: USEReport{
+				Components: []Component{
+					&MemCap{"Mem \"Cap\"\\", &USEMetrics{Utilization: math.NaN()}, Thresholds{}},
+				},
+			},
+			want: `# HELP cos_profiler_utilization Percent of time the component was busy servicing work.
+# TYPE cos_profiler_utilization gauge
+cos_profiler_utilization{component="Mem \"Cap\"\\"} NaN
+# HELP cos_profiler_saturation Whether the component is saturated (1) or not (0).
+# TYPE cos_profiler_saturation gauge
+cos_profiler_saturation{component="Mem \"Cap\"\\"} 0
+# HELP cos_profiler_errors Number of errors seen in the component.
+# TYPE cos_profiler_errors gauge
+cos_profiler_errors{component="Mem \"Cap\"\\"} 0
+# HELP cos_profiler_collection_errors Whether the USE metrics of the component could not be collected (1) or could (0).
+# TYPE cos_profiler_collection_errors gauge
+cos_profiler_collection_errors{component="Mem \"Cap\"\\"} 0
+`,
+		},
+		{
+			name:   "no components",
+			report: USEReport{},
+			want: `# HELP cos_profiler_utilization Percent of time the component was busy servicing work.
+# TYPE cos_profiler_utilization gauge
+# HELP cos_profiler_saturation Whether the component is saturated (1) or not (0).
+# TYPE cos_profiler_saturation gauge
+# HELP cos_profiler_errors Number of errors seen in the component.
+# TYPE cos_profiler_errors gauge
+# HELP cos_profiler_collection_errors Whether the USE metrics of the component could not be collected (1) or could (0).
+# TYPE cos_profiler_collection_errors gauge
+`,
+		},
+		{
+			name: "failed component",
+			report: USEReport{
+				Components: []Component{
+					&CPU{"CPU", &USEMetrics{Utilization: 6.5, Saturation: true, Errors: 2}, nil, Thresholds{}},
+					&MemCap{"MemCap", &USEMetrics{Utilization: 52.25}, Thresholds{}},
+				},
+				Failed: []string{"CPU"},
+			},
+			want: `# HELP cos_profiler_utilization Percent of time the component was busy servicing work.
+# TYPE cos_profiler_utilization gauge
+cos_profiler_utilization{component="MemCap"} 52.25
+# HELP cos_profiler_saturation Whether the component is saturated (1) or not (0).
+# TYPE cos_profiler_saturation gauge
+cos_profiler_saturation{component="MemCap"} 0
+# HELP cos_profiler_errors Number of errors seen in the component.
+# TYPE cos_profiler_errors gauge
+cos_profiler_errors{component="MemCap"} 0
+# HELP cos_profiler_collection_errors Whether the USE metrics of the component could not be collected (1) or could (0).
+# TYPE cos_profiler_collection_errors gauge
+cos_profiler_collection_errors{component="CPU"} 1
+cos_profiler_collection_errors{component="MemCap"} 0
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WritePrometheus(&buf, test.report); err != nil {
+				t.Fatalf("WritePrometheus() unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(test.want, buf.String()); diff != "" {
+				t.Errorf("WritePrometheus() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWritePrometheusError(t *testing.T) {
+	report := USEReport{Components: []Component{NewCPU("CPU", Thresholds{})}}
+	if err := WritePrometheus(failingWriter{}, report); err == nil {
+		t.Errorf("WritePrometheus() expected error, got nil")
+	}
+}
@@ -0,0 +1,17 @@
+#!/bin/bash
+
+main() {
+    cat <<EOF
+[    0.000000] Linux version 5.10.68+ (builder@localhost) (Chromium OS 12.0_pre416183_p20210305-r3 clang version 12.0.0) #1 SMP Tue Oct 5 08:39:02 UTC 2021
+[    0.001734] Command line: BOOT_IMAGE=/syslinux/vmlinuz.A init=/usr/lib/systemd/systemd
+
+[ 1024.412381] mce: [Hardware Error]: Machine check events logged
+[ 1024.412390] [Hardware Error]: CPU 2: Machine Check Exception: 5 Bank 0: b200000000070005
+[ 2048.118273] CPU3: Core temperature above threshold, cpu clock throttled (total events = 1)
+[ 2048.118274] CPU3: Package temperature above threshold, cpu clock throttled (total events = 1)
+[ 3072.664109] watchdog: BUG: soft lockup - CPU#1 stuck for 22s! [kworker/1:2:1234]
+[ 4096.000001] EXT4-fs (sda1): mounted filesystem with ordered data mode. Opts: (null)
+EOF
+}
+
+main "$#"
@@ -0,0 +1,14 @@
+#!/bin/bash
+
+main() {
+    cat <<EOF
+[    0.000000] Linux version 5.10.68+ (builder@localhost) (Chromium OS 12.0_pre416183_p20210305-r3 clang version 12.0.0) #1 SMP Tue Oct 5 08:39:02 UTC 2021
+[    0.001734] Command line: BOOT_IMAGE=/syslinux/vmlinuz.A init=/usr/lib/systemd/systemd
+[    0.512351] mce: CPU0: Thermal monitoring enabled (TM1)
+[    0.512360] mce: CPU supports 10 MCE banks
+[    2.301244] EXT4-fs (sda1): mounted filesystem with ordered data mode. Opts: (null)
+[   12.550001] IPv6: ADDRCONF(NETDEV_CHANGE): eth0: link becomes ready
+EOF
+}
+
+main "$#"
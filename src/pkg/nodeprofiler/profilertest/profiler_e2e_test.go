@@ -35,7 +35,7 @@ func TestCPUOverload(t *testing.T) {
 
 	commands := []profiler.Command{vmstat, lscpu}
 
-	cpu := profiler.NewCPU("CPU")
+	cpu := profiler.NewCPU("CPU", profiler.Thresholds{})
 	components := []profiler.Component{cpu}
 	// get number of cores in CPU
 	n := runtime.NumCPU()
@@ -105,12 +105,12 @@ func TestCPUOverload(t *testing.T) {
 func TestStorageDevOverload(t *testing.T) {
 
 	// initialize all commands needed and the mem cap component
-	titles := []string{"%util", "aqu-sz"}
+	titles := []string{"Device", "%util", "aqu-sz"}
 	iostat := profiler.NewIOStat("iostat", "-dxyz", 1, 10, titles)
 
 	commands := []profiler.Command{iostat}
 
-	dev := profiler.NewStorageDevIO("StorageDevIO")
+	dev := profiler.NewStorageDevIO("StorageDevIO", []string{}, profiler.Thresholds{})
 	components := []profiler.Component{dev}
 
 	// stress test will run for 1 minute perfoming a number of I/O operations
@@ -184,7 +184,7 @@ func TestMemOverload(t *testing.T) {
 
 	commands := []profiler.Command{vmstat, free}
 
-	mem := profiler.NewMemCap("MemCap")
+	mem := profiler.NewMemCap("MemCap", profiler.Thresholds{})
 	components := []profiler.Component{mem}
 
 	// stress test will run for 2 minutes writing to the allocated memory (90%)
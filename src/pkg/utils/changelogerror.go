@@ -20,10 +20,15 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"html"
+	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -59,8 +64,22 @@ var (
 		err:      "An unexpected error occurred while retrieving the requested information.",
 	}
 
+	// ErrMultipleCLsForBug is wrapped by the ChangelogError returned by
+	// MultipleCLsForBug.
+	ErrMultipleCLsForBug = errors.New("multiple CLs found for bug")
+
+	// ErrRateLimited is wrapped by the ChangelogError returned by
+	// RateLimited.
+	ErrRateLimited = errors.New("request rate limited")
+
 	gitiles403ErrMsg = "unexpected HTTP 403 from Gitiles"
-	gerritErrCodeRe  = regexp.MustCompile("status code\\s*(\\d+)")
+	// gerritErrCodeRe matches the status code in the errors of both the
+	// LUCI Gerrit client, ex. "..., status code 403", and go-gerrit,
+	// ex. "API call to ... failed: 403 Forbidden".
+	gerritErrCodeRe = regexp.MustCompile("(?:status code\\s*|failed: )(\\d+)")
+	// quotaErrRe matches the messages of requests rejected because a quota
+	// was exceeded, which are not always returned with status code 429.
+	quotaErrRe = regexp.MustCompile("(?i)quota exceeded|exceeded .*quota|rate limit|too many requests")
 )
 
 // ChangelogError is the error type used by the changelog and findbuild package
@@ -79,6 +98,11 @@ type UtilChangelogError struct {
 	err       string
 	htmlErr   string
 	retryable bool
+	// retryAfter is the delay after which the request may be retried, if
+	// the error is temporary and the delay is known.
+	retryAfter time.Duration
+	// cause is the underlying error, if any, returned by Unwrap.
+	cause error
 }
 
 // HTTPCode retrieves the HTTP error code associated with the error
@@ -112,6 +136,18 @@ func (e *UtilChangelogError) Retryable() bool {
 	return e.retryable
 }
 
+// RetryAfter returns the delay after which the request may be retried, or 0
+// if it is unknown. Unlike Retryable, it indicates that the same request may
+// succeed later, ex. once a rate limit is lifted.
+func (e *UtilChangelogError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *UtilChangelogError) Unwrap() error {
+	return e.cause
+}
+
 func unwrapError(err error) error {
 	innerErr := err
 	for errors.Unwrap(innerErr) != nil {
@@ -141,19 +177,42 @@ func BothBuildsNotFound(croslandURL, source, target, sourceBuildNum, targetBuild
 		}, " "),
 		htmlErr: fmt.Sprintf("%s %s and %s %s<br><br>%s %s <a href=%s target=\"_blank\">%s</a>. %s %s",
 			"The builds associated with input",
-			source,
-			target,
+			html.EscapeString(source),
+			html.EscapeString(target),
 			"could not be found.",
 			"It may be possible that the inputs are either invalid or both belong to pre-Cusky builds.",
 			"If both of the inputs belong to pre-Cusky builds, please check",
-			croslandLink(croslandURL, sourceBuildNum, targetBuildNum),
-			croslandLink(croslandURL, sourceBuildNum, targetBuildNum),
+			html.EscapeString(croslandLink(croslandURL, sourceBuildNum, targetBuildNum)),
+			html.EscapeString(croslandLink(croslandURL, sourceBuildNum, targetBuildNum)),
 			"Otherwise, please input valid build numbers",
 			"(example: 13310.1035.0) or valid image names (example: cos-rc-85-13310-1034-0).",
 		),
 	}
 }
 
+// BothBuildsNotFoundWithSuggestions indicates that neither build was found,
+// and suggests existing builds with build numbers similar to each input.
+// If there are no suggestions for either build, it is equivalent to
+// BothBuildsNotFound.
+func BothBuildsNotFoundWithSuggestions(croslandURL, source, target, sourceBuildNum, targetBuildNum string, sourceSuggestions, targetSuggestions []string) *UtilChangelogError {
+	err := BothBuildsNotFound(croslandURL, source, target, sourceBuildNum, targetBuildNum)
+	var hints, htmlHints []string
+	if len(sourceSuggestions) > 0 {
+		hint := fmt.Sprintf("Builds similar to %s: %s.", source, strings.Join(sourceSuggestions, ", "))
+		hints, htmlHints = append(hints, hint), append(htmlHints, html.EscapeString(hint))
+	}
+	if len(targetSuggestions) > 0 {
+		hint := fmt.Sprintf("Builds similar to %s: %s.", target, strings.Join(targetSuggestions, ", "))
+		hints, htmlHints = append(hints, hint), append(htmlHints, html.EscapeString(hint))
+	}
+	if len(hints) == 0 {
+		return err
+	}
+	err.err = fmt.Sprintf("%s %s", err.err, strings.Join(hints, " "))
+	err.htmlErr = fmt.Sprintf("%s<br><br>%s", err.htmlErr, strings.Join(htmlHints, "<br>"))
+	return err
+}
+
 // BuildNotFound returns a ChangelogError object for changelog indicating
 // the desired build could not be found
 func BuildNotFound(buildNumber string) *UtilChangelogError {
@@ -170,7 +229,7 @@ func BuildNotFound(buildNumber string) *UtilChangelogError {
 		}, " "),
 		htmlErr: fmt.Sprintf("%s %s %s<br><br>%s %s %s %s",
 			"The build associated with input",
-			buildNumber,
+			html.EscapeString(buildNumber),
 			"cannot be found.",
 			"It may be possible that either the input is either invalid or belongs to a",
 			"pre-Cusky build. If you entered a pre-Cusky build number or image name, note that changelog between",
@@ -180,6 +239,36 @@ func BuildNotFound(buildNumber string) *UtilChangelogError {
 	}
 }
 
+// InvalidManifest returns a ChangelogError object for changelog indicating
+// that a provided manifest file could not be parsed
+func InvalidManifest(manifestName string) *UtilChangelogError {
+	return &UtilChangelogError{
+		httpCode: "400",
+		header:   "Invalid Manifest",
+		err:      fmt.Sprintf("The %s manifest file could not be parsed. Please provide a valid manifest XML file.", manifestName),
+	}
+}
+
+// RequestCanceled returns a ChangelogError object indicating that the request
+// was cancelled or timed out before it completed. It wraps ctxErr, the error
+// of the cancelled context.
+func RequestCanceled(ctxErr error) *UtilChangelogError {
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		return &UtilChangelogError{
+			httpCode: "504",
+			header:   "Request Timeout",
+			err:      "The request took too long to complete. Please retry, or request a smaller changelog.",
+			cause:    ctxErr,
+		}
+	}
+	return &UtilChangelogError{
+		httpCode: "500",
+		header:   "Request Cancelled",
+		err:      "The request was cancelled before it completed.",
+		cause:    ctxErr,
+	}
+}
+
 func clLink(clID, instanceURL string) string {
 	return fmt.Sprintf("<a href=\"%s/c/%s\" target=\"_blank\">CL %s</a>", instanceURL, clID, clID)
 }
@@ -222,6 +311,37 @@ func CLNotUsed(clID, repo, branch, instanceURL string) *UtilChangelogError {
 	}
 }
 
+// RevisionNotFound returns a ChangelogError object for findbuild indicating
+// that the requested revision does not exist on the provided CL
+func RevisionNotFound(clID, revision, instanceURL string) *UtilChangelogError {
+	errStrFmt := "%s does not have a patchset or revision matching %s. Please verify the patchset number or revision SHA and try again."
+	link := clLink(clID, instanceURL)
+	return &UtilChangelogError{
+		httpCode: "404",
+		header:   "Revision Not Found",
+		err:      fmt.Sprintf(errStrFmt, "CL "+clID, revision),
+		htmlErr:  fmt.Sprintf(errStrFmt, link, html.EscapeString(revision)),
+	}
+}
+
+// MultipleCLsForBug returns a ChangelogError object for findbuild indicating
+// that the CLs referencing a bug were submitted to different branches, so no
+// single CL can be searched for. It wraps ErrMultipleCLsForBug.
+func MultipleCLsForBug(bug string, clIDs []string, instanceURL string) *UtilChangelogError {
+	errStrFmt := "Bug %s is referenced by CLs submitted to different branches: %s. Please search for one of the CLs instead."
+	links := make([]string, len(clIDs))
+	for i, clID := range clIDs {
+		links[i] = clLink(clID, instanceURL)
+	}
+	return &UtilChangelogError{
+		httpCode: "406",
+		header:   "Multiple CLs Found",
+		err:      fmt.Sprintf(errStrFmt, bug, "CL "+strings.Join(clIDs, ", CL ")),
+		htmlErr:  fmt.Sprintf(errStrFmt, html.EscapeString(bug), strings.Join(links, ", ")),
+		cause:    ErrMultipleCLsForBug,
+	}
+}
+
 // CLTooRecent returns a ChangelogError object for findbuild indicating the provided
 // CL could not be found
 func CLTooRecent(clID, instanceURL string) *UtilChangelogError {
@@ -235,6 +355,58 @@ func CLTooRecent(clID, instanceURL string) *UtilChangelogError {
 	}
 }
 
+// CLSearchRangeExceeded returns a ChangelogError object for findbuild
+// indicating that no build containing the provided CL was found within the
+// maximum number of days searched
+func CLSearchRangeExceeded(clID, instanceURL string, days int) *UtilChangelogError {
+	errStrFmt := "No build containing %s was found within %d days of its submission. Please try again with a larger search range."
+	link := clLink(clID, instanceURL)
+	return &UtilChangelogError{
+		httpCode: "406",
+		header:   "No Build Found",
+		err:      fmt.Sprintf(errStrFmt, "CL "+clID, days),
+		htmlErr:  fmt.Sprintf(errStrFmt, link, days),
+	}
+}
+
+// approxDuration formats a duration as an approximate number of hours, or
+// minutes if it is less than an hour.
+func approxDuration(d time.Duration) string {
+	if d < time.Hour {
+		minutes := int(d.Round(time.Minute).Minutes())
+		if minutes <= 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	hours := int(d.Round(time.Hour).Hours())
+	if hours == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", hours)
+}
+
+// CLTooRecentWithETA returns a ChangelogError object for findbuild indicating
+// the provided CL was submitted too recently to be included in any builds.
+// cadence is the typical time between builds on the CL's release branch, and
+// eta is the estimated time until the next build is created.
+func CLTooRecentWithETA(clID, instanceURL string, submitted time.Time, cadence, eta time.Duration) *UtilChangelogError {
+	errStrFmt := "%s was submitted at %s, which is too recent to be included in any builds. " +
+		"Builds on this branch are typically created every %s. %s"
+	retry := fmt.Sprintf("Please try again in about %s.", approxDuration(eta))
+	if eta <= 0 {
+		retry = "A new build is expected shortly. Please try again later."
+	}
+	submittedStr := submitted.UTC().Format("Jan 2, 2006 15:04 MST")
+	link := clLink(clID, instanceURL)
+	return &UtilChangelogError{
+		httpCode: "406",
+		header:   "CL Too Recent",
+		err:      fmt.Sprintf(errStrFmt, "CL "+clID, submittedStr, approxDuration(cadence), retry),
+		htmlErr:  fmt.Sprintf(errStrFmt, link, submittedStr, approxDuration(cadence), retry),
+	}
+}
+
 // CLNotSubmitted returns a ChangelogError object for findbuild indicating
 // that the provided CL has not been submitted
 func CLNotSubmitted(clID, instanceURL string) *UtilChangelogError {
@@ -261,6 +433,24 @@ func CLInvalidRelease(clID, release, instanceURL string) *UtilChangelogError {
 	}
 }
 
+// RateLimited returns a ChangelogError object indicating that a request to
+// service was rejected because of a rate limit or an exceeded quota.
+// retryAfter is the delay after which the request may be retried, or 0 if it
+// is unknown. The returned error wraps ErrRateLimited.
+func RateLimited(service string, retryAfter time.Duration) *UtilChangelogError {
+	retry := "Please try again in a few minutes."
+	if retryAfter > 0 {
+		retry = fmt.Sprintf("Please try again in about %s.", approxDuration(retryAfter))
+	}
+	return &UtilChangelogError{
+		httpCode:   "429",
+		header:     "Too Many Requests",
+		err:        fmt.Sprintf("%s is temporarily limiting requests from this application. %s", service, retry),
+		retryAfter: retryAfter,
+		cause:      ErrRateLimited,
+	}
+}
+
 // GitilesErrCode parses a Gitiles error message and returns an HTTP error code
 // associated with the error. Returns 500 if no error code is found.
 func GitilesErrCode(err error) string {
@@ -290,3 +480,33 @@ func GerritErrCode(err error) string {
 	}
 	return matches[1]
 }
+
+// GerritRateLimited reports whether a Gerrit error was caused by a rate limit
+// or an exceeded quota, either by its status code 429 or by its message.
+func GerritRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+	return GerritErrCode(err) == "429" || quotaErrRe.MatchString(err.Error())
+}
+
+// ParseRetryAfter parses the value of a Retry-After HTTP header, which is
+// either a number of seconds or an HTTP date, into a delay relative to now.
+// It returns 0 if the value is empty, invalid or in the past.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+	return date.Sub(now)
+}
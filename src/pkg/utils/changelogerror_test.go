@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -49,6 +50,14 @@ func TestGerritErrCode(t *testing.T) {
 			inputErr:     errors.New("failed to fetch \"https://cos-internal-review.googlesource.com/a/changes/?n=1&o=CURRENT_REVISION&q=1\", status code 689"),
 			expectedCode: "689",
 		},
+		"go-gerrit Error": {
+			inputErr:     errors.New("API call to https://cos-review.googlesource.com/changes/?n=1&q=change:1 failed: 429 Too Many Requests"),
+			expectedCode: "429",
+		},
+		"Wrapped Error": {
+			inputErr:     fmt.Errorf("query failed: %w", errors.New("API call to https://cos-review.googlesource.com/changes/ failed: 403 Forbidden")),
+			expectedCode: "403",
+		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -60,6 +69,75 @@ func TestGerritErrCode(t *testing.T) {
 	}
 }
 
+func TestGerritRateLimited(t *testing.T) {
+	tests := map[string]struct {
+		inputErr error
+		expected bool
+	}{
+		"nil":            {nil, false},
+		"429":            {errors.New("API call to https://cos-review.googlesource.com/changes/ failed: 429 Too Many Requests"), true},
+		"LUCI 429":       {errors.New("failed to fetch \"https://cos-review.googlesource.com/a/changes/\", status code 429"), true},
+		"quota exceeded": {errors.New("API call to https://cos-review.googlesource.com/changes/ failed: 403 Quota exceeded for user"), true},
+		"rate limit":     {errors.New("API call to https://cos-review.googlesource.com/changes/ failed: 503 rate limit reached"), true},
+		"403":            {errors.New("API call to https://cos-review.googlesource.com/changes/ failed: 403 Forbidden"), false},
+		"500":            {errors.New("API call to https://cos-review.googlesource.com/changes/ failed: 500 Internal Server Error"), false},
+	}
+	for name, test := range tests {
+		if got := GerritRateLimited(test.inputErr); got != test.expected {
+			t.Errorf("test %q: expected rate limited = %t, got %t", name, test.expected, got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		value    string
+		expected time.Duration
+	}{
+		"empty":       {"", 0},
+		"seconds":     {"120", 2 * time.Minute},
+		"padded":      {" 30 ", 30 * time.Second},
+		"zero":        {"0", 0},
+		"negative":    {"-5", 0},
+		"HTTP date":   {"Mon, 01 Mar 2021 12:05:00 GMT", 5 * time.Minute},
+		"past date":   {"Mon, 01 Mar 2021 11:55:00 GMT", 0},
+		"invalid":     {"soon", 0},
+		"not integer": {"1.5", 0},
+	}
+	for name, test := range tests {
+		if got := ParseRetryAfter(test.value, now); got != test.expected {
+			t.Errorf("test %q: expected %v, got %v", name, test.expected, got)
+		}
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	tests := map[string]struct {
+		retryAfter     time.Duration
+		expectedErrStr string
+	}{
+		"known delay":   {5 * time.Minute, "Gerrit is temporarily limiting requests from this application. Please try again in about 5 minutes."},
+		"unknown delay": {0, "Gerrit is temporarily limiting requests from this application. Please try again in a few minutes."},
+	}
+	for name, test := range tests {
+		err := RateLimited("Gerrit", test.retryAfter)
+		if err.HTTPCode() != "429" {
+			t.Errorf("test %q: expected HTTP code 429, got %s", name, err.HTTPCode())
+		} else if err.Header() != "Too Many Requests" {
+			t.Errorf("test %q: expected error header \"Too Many Requests\", got %s", name, err.Header())
+		} else if err.Error() != test.expectedErrStr {
+			t.Errorf("test %q: expected error string %q, got %q", name, test.expectedErrStr, err.Error())
+		} else if err.RetryAfter() != test.retryAfter {
+			t.Errorf("test %q: expected retry after %v, got %v", name, test.retryAfter, err.RetryAfter())
+		} else if err.Retryable() {
+			t.Errorf("test %q: expected retryable = false, got true", name)
+		} else if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("test %q: expected error wrapping ErrRateLimited", name)
+		}
+	}
+}
+
 func TestGitilesErrCode(t *testing.T) {
 	tests := map[string]struct {
 		inputErr     error
@@ -130,6 +208,44 @@ func TestBothBuildsNotFound(t *testing.T) {
 	}
 }
 
+func TestBothBuildsNotFoundWithSuggestions(t *testing.T) {
+	source := "13310.1053.0"
+	target := "13310.1064.0"
+	croslandURL := "https://www.google.com"
+	base := BothBuildsNotFound(croslandURL, source, target, source, target)
+	tests := map[string]struct {
+		SourceSuggestions []string
+		TargetSuggestions []string
+		ExpectedHint      string
+		ExpectedHTMLHint  string
+	}{
+		"no suggestions": {},
+		"source suggestions": {
+			SourceSuggestions: []string{"13310.1035.0", "13310.1050.0"},
+			ExpectedHint:      " Builds similar to 13310.1053.0: 13310.1035.0, 13310.1050.0.",
+			ExpectedHTMLHint:  "<br><br>Builds similar to 13310.1053.0: 13310.1035.0, 13310.1050.0.",
+		},
+		"both suggestions": {
+			SourceSuggestions: []string{"13310.1035.0"},
+			TargetSuggestions: []string{"13310.1046.0"},
+			ExpectedHint:      " Builds similar to 13310.1053.0: 13310.1035.0. Builds similar to 13310.1064.0: 13310.1046.0.",
+			ExpectedHTMLHint:  "<br><br>Builds similar to 13310.1053.0: 13310.1035.0.<br>Builds similar to 13310.1064.0: 13310.1046.0.",
+		},
+	}
+	for name, test := range tests {
+		err := BothBuildsNotFoundWithSuggestions(croslandURL, source, target, source, target, test.SourceSuggestions, test.TargetSuggestions)
+		if err.HTTPCode() != "404" {
+			t.Errorf("test %q: expected HTTP code 404, got %s", name, err.HTTPCode())
+		} else if err.Header() != base.Header() {
+			t.Errorf("test %q: expected error header %q, got %q", name, base.Header(), err.Header())
+		} else if err.Error() != base.Error()+test.ExpectedHint {
+			t.Errorf("test %q: expected error string %q, got %q", name, base.Error()+test.ExpectedHint, err.Error())
+		} else if err.HTMLError() != base.HTMLError()+test.ExpectedHTMLHint {
+			t.Errorf("test %q: expected html error string %q, got %q", name, base.HTMLError()+test.ExpectedHTMLHint, err.HTMLError())
+		}
+	}
+}
+
 func TestBuildNotFound(t *testing.T) {
 	buildNumber := "cos-stable-81-12871-117-0"
 	expectedCode := "404"
@@ -165,6 +281,42 @@ func TestBuildNotFound(t *testing.T) {
 	}
 }
 
+func TestBuildNotFoundEscapesHTML(t *testing.T) {
+	input := "<script>alert(1)</script>"
+	errs := map[string]ChangelogError{
+		"build not found":       BuildNotFound(input),
+		"both builds not found": BothBuildsNotFound("https://www.google.com", input, input, input, input),
+		"suggestions":           BothBuildsNotFoundWithSuggestions("https://www.google.com", input, input, input, input, []string{"13310.1035.0"}, []string{"13310.1046.0"}),
+	}
+	for name, err := range errs {
+		if strings.Contains(err.HTMLError(), input) {
+			t.Errorf("test %q: expected input to be escaped in html error, got %s", name, err.HTMLError())
+		}
+		if !strings.Contains(err.Error(), input) {
+			t.Errorf("test %q: expected input in error, got %s", name, err.Error())
+		}
+	}
+}
+
+func TestInvalidManifest(t *testing.T) {
+	manifestName := "source"
+	expectedCode := "400"
+	expectedErrHeader := "Invalid Manifest"
+	expectedErrStr := "The source manifest file could not be parsed. Please provide a valid manifest XML file."
+	err := InvalidManifest(manifestName)
+	if err.HTTPCode() != expectedCode {
+		t.Errorf("expected HTTP code %s, got %s", expectedCode, err.HTTPCode())
+	} else if err.Header() != expectedErrHeader {
+		t.Errorf("expected error header \"%s\", got %s", expectedErrHeader, err.Header())
+	} else if err.Error() != expectedErrStr {
+		t.Errorf("expected error string %s, got %s", expectedErrStr, err.Error())
+	} else if err.HTMLError() != expectedErrStr {
+		t.Errorf("expected html error string %s, got %s", expectedErrStr, err.HTMLError())
+	} else if err.Retryable() {
+		t.Errorf("expected retryable = false, got true")
+	}
+}
+
 func TestCLNotFound(t *testing.T) {
 	clID := "1540"
 	expectedCode := "404"
@@ -249,6 +401,69 @@ func TestCLTooRecent(t *testing.T) {
 	}
 }
 
+func TestRevisionNotFound(t *testing.T) {
+	clID := "1540"
+	revision := "3"
+	expectedCode := "404"
+	expectedErrHeader := "Revision Not Found"
+	expectedErrStr := fmt.Sprintf("CL %s does not have a patchset or revision matching %s. Please verify the patchset number or revision SHA and try again.", clID, revision)
+	link := testCLLink(clID, testInstanceURL)
+	expectedHTMLErrStr := fmt.Sprintf("%s does not have a patchset or revision matching %s. Please verify the patchset number or revision SHA and try again.", link, revision)
+	err := RevisionNotFound(clID, revision, testInstanceURL)
+	if err.HTTPCode() != expectedCode {
+		t.Errorf("expected HTTP code %s, got %s", expectedCode, err.HTTPCode())
+	} else if err.Header() != expectedErrHeader {
+		t.Errorf("expected error header \"%s\", got %s", expectedErrHeader, err.Header())
+	} else if err.Error() != expectedErrStr {
+		t.Errorf("expected error string %s, got %s", expectedErrStr, err.Error())
+	} else if err.HTMLError() != expectedHTMLErrStr {
+		t.Errorf("expected html error string %s, got %s", expectedHTMLErrStr, err.HTMLError())
+	}
+}
+
+func TestCLTooRecentWithETA(t *testing.T) {
+	clID := "1540"
+	submitted := time.Date(2021, time.March, 4, 10, 30, 0, 0, time.UTC)
+	link := testCLLink(clID, testInstanceURL)
+	tests := map[string]struct {
+		Cadence     time.Duration
+		ETA         time.Duration
+		ExpectedMsg string
+	}{
+		"hours": {
+			Cadence:     6 * time.Hour,
+			ETA:         4*time.Hour + 10*time.Minute,
+			ExpectedMsg: "%s was submitted at Mar 4, 2021 10:30 UTC, which is too recent to be included in any builds. Builds on this branch are typically created every 6 hours. Please try again in about 4 hours.",
+		},
+		"minutes": {
+			Cadence:     time.Hour,
+			ETA:         25 * time.Minute,
+			ExpectedMsg: "%s was submitted at Mar 4, 2021 10:30 UTC, which is too recent to be included in any builds. Builds on this branch are typically created every 1 hour. Please try again in about 25 minutes.",
+		},
+		"overdue": {
+			Cadence:     24 * time.Hour,
+			ETA:         -time.Hour,
+			ExpectedMsg: "%s was submitted at Mar 4, 2021 10:30 UTC, which is too recent to be included in any builds. Builds on this branch are typically created every 24 hours. A new build is expected shortly. Please try again later.",
+		},
+	}
+	for name, test := range tests {
+		err := CLTooRecentWithETA(clID, testInstanceURL, submitted, test.Cadence, test.ETA)
+		expectedErrStr := fmt.Sprintf(test.ExpectedMsg, "CL "+clID)
+		expectedHTMLErrStr := fmt.Sprintf(test.ExpectedMsg, link)
+		if err.HTTPCode() != "406" {
+			t.Errorf("test %q: expected HTTP code 406, got %s", name, err.HTTPCode())
+		} else if err.Header() != "CL Too Recent" {
+			t.Errorf("test %q: expected error header \"CL Too Recent\", got %s", name, err.Header())
+		} else if err.Error() != expectedErrStr {
+			t.Errorf("test %q: expected error string %s, got %s", name, expectedErrStr, err.Error())
+		} else if err.HTMLError() != expectedHTMLErrStr {
+			t.Errorf("test %q: expected html error string %s, got %s", name, expectedHTMLErrStr, err.HTMLError())
+		} else if err.Retryable() {
+			t.Errorf("test %q: expected retryable = false, got true", name)
+		}
+	}
+}
+
 func TestCLNotSubmitted(t *testing.T) {
 	clID := "1540"
 	expectedCode := "406"
@@ -23,6 +23,8 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"go.chromium.org/luci/common/proto/git"
@@ -58,11 +60,18 @@ func limitPageSize(pageSize, querySize int, noLimit bool) int {
 // DownloadManifest retrieves a manifest file from Git on Borg for a specific
 // build number
 func DownloadManifest(client gitilesProto.GitilesClient, manifestRepo, buildNum string) (*gitilesProto.DownloadFileResponse, error) {
-	log.Debugf("Downloading manifest file for build %s", buildNum)
+	return DownloadManifestFile(client, manifestRepo, buildNum, manifestFileName)
+}
+
+// DownloadManifestFile retrieves a file at path in the manifest repository
+// from Git on Borg for a specific build number, such as a manifest file
+// included by the build's manifest.
+func DownloadManifestFile(client gitilesProto.GitilesClient, manifestRepo, buildNum, path string) (*gitilesProto.DownloadFileResponse, error) {
+	log.Debugf("Downloading manifest file %s for build %s", path, buildNum)
 	request := gitilesProto.DownloadFileRequest{
 		Project:    manifestRepo,
 		Committish: "refs/tags/" + buildNum,
-		Path:       manifestFileName,
+		Path:       path,
 		Format:     1,
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), requestMaxAge)
@@ -71,7 +80,29 @@ func DownloadManifest(client gitilesProto.GitilesClient, manifestRepo, buildNum
 	return response, err
 }
 
-func nextCommits(client gitilesProto.GitilesClient, repo string, committish string, ancestor string, nextToken string, pageSize int) (*gitilesProto.LogResponse, error) {
+// ListBuilds retrieves the build numbers of all builds with a manifest file
+// in manifestRepo, based on the repository's tags.
+func ListBuilds(client gitilesProto.GitilesClient, manifestRepo string) ([]string, error) {
+	log.Debugf("Listing builds in manifest repository %s", manifestRepo)
+	request := gitilesProto.RefsRequest{
+		Project:  manifestRepo,
+		RefsPath: "refs/tags",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestMaxAge)
+	defer cancel()
+	response, err := client.Refs(ctx, &request)
+	if err != nil {
+		return nil, err
+	}
+	builds := make([]string, 0, len(response.Revisions))
+	for ref := range response.Revisions {
+		builds = append(builds, strings.TrimPrefix(ref, "refs/tags/"))
+	}
+	sort.Strings(builds)
+	return builds, nil
+}
+
+func nextCommits(ctx context.Context, client gitilesProto.GitilesClient, repo string, committish string, ancestor string, nextToken string, pageSize int) (*gitilesProto.LogResponse, error) {
 	request := gitilesProto.LogRequest{
 		Project:            repo,
 		Committish:         committish,
@@ -79,7 +110,7 @@ func nextCommits(client gitilesProto.GitilesClient, repo string, committish stri
 		PageToken:          nextToken,
 		PageSize:           int32(pageSize),
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), requestMaxAge)
+	ctx, cancel := context.WithTimeout(ctx, requestMaxAge)
 	defer cancel()
 	return client.Log(ctx, &request)
 }
@@ -87,26 +118,71 @@ func nextCommits(client gitilesProto.GitilesClient, repo string, committish stri
 // Commits retrieves querySize commits that occur between a committish and an ancestor
 // for a given repository. Returns a list of commits and a bool that is set to true
 // if there are more than querySize commits between the two provided committishs.
-func Commits(client gitilesProto.GitilesClient, repo string, committish string, ancestor string, querySize int) ([]*git.Commit, bool, error) {
+//
+// Requests are cancelled when ctx is done.
+func Commits(ctx context.Context, client gitilesProto.GitilesClient, repo string, committish string, ancestor string, querySize int) ([]*git.Commit, bool, error) {
+	commits, nextToken, err := queryCommits(ctx, client, repo, committish, ancestor, querySize)
+	if err != nil {
+		return nil, false, err
+	}
+	return commits, nextToken != "", nil
+}
+
+// CommitsWithCount retrieves querySize commits that occur between a committish
+// and an ancestor for a given repository, like Commits. Returns a list of
+// commits and the total number of commits between the two provided
+// committishs, which is larger than the number of commits returned if there
+// are more than querySize commits.
+//
+// Gitiles has no way to count the commits of a range without listing them,
+// so the remaining commits are listed page by page but not returned. This
+// requires a request per maxPageSize commits. The pages are aligned to
+// multiples of maxPageSize commits, and the last output maps the 0-based
+// position of the first commit of each page to the page token used to
+// request it, which is the SHA of that commit.
+func CommitsWithCount(ctx context.Context, client gitilesProto.GitilesClient, repo string, committish string, ancestor string, querySize int) ([]*git.Commit, int, map[int]string, error) {
+	commits, nextToken, err := queryCommits(ctx, client, repo, committish, ancestor, querySize)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	count := len(commits)
+	pageTokens := make(map[int]string)
+	for nextToken != "" {
+		pageTokens[count] = nextToken
+		pageSize := maxPageSize - count%maxPageSize
+		response, err := nextCommits(ctx, client, repo, committish, ancestor, nextToken, pageSize)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("commits: Error counting commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
+		}
+		count += len(response.Log)
+		nextToken = response.NextPageToken
+	}
+	return commits, count, pageTokens, nil
+}
+
+// queryCommits retrieves querySize commits that occur between a committish and
+// an ancestor for a given repository. Returns a list of commits and the page
+// token of the remaining commits, which is empty if there are none.
+func queryCommits(ctx context.Context, client gitilesProto.GitilesClient, repo string, committish string, ancestor string, querySize int) ([]*git.Commit, string, error) {
 	log.Debugf("Fetching changelog for repo: %s from: %s to: %s\n", repo, ancestor, committish)
 	if querySize < -1 {
-		return nil, false, fmt.Errorf("commits: %d is not a valid querySize. Please specify a positive querySize, or -1 for all commits", querySize)
+		return nil, "", fmt.Errorf("commits: %d is not a valid querySize. Please specify a positive querySize, or -1 for all commits", querySize)
 	}
 	start := time.Now()
 
 	noLimit := querySize == -1
 	pageSize := limitPageSize(defaultPageSize, querySize, noLimit)
 	querySize -= pageSize
-	response, err := nextCommits(client, repo, committish, ancestor, "", pageSize)
+	response, err := nextCommits(ctx, client, repo, committish, ancestor, "", pageSize)
 	if err != nil {
-		return nil, false, fmt.Errorf("commits: Error retrieving commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
+		return nil, "", fmt.Errorf("commits: Error retrieving commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
 	}
 
 	// No nextPageToken means there were less than <defaultPageSize> commits total.
 	// We can immediately return.
 	if response.NextPageToken == "" {
 		log.Debugf("Retrieved %d commits from %s in %s\n", len(response.Log), repo, time.Since(start))
-		return response.Log, false, nil
+		return response.Log, "", nil
 	}
 	// Retrieve remaining commits using exponential increase in pageSize.
 	allCommits := response.Log
@@ -117,14 +193,14 @@ func Commits(client gitilesProto.GitilesClient, repo string, committish string,
 		pageSize = limitPageSize(pageSize, querySize, noLimit)
 		log.Debugf("More commits remaining, expanding page size to %d commits", pageSize)
 		querySize -= pageSize
-		response, err = nextCommits(client, repo, committish, ancestor, response.NextPageToken, pageSize)
+		response, err = nextCommits(ctx, client, repo, committish, ancestor, response.NextPageToken, pageSize)
 		if err != nil {
-			return nil, false, fmt.Errorf("commits: Error retrieving next page commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
+			return nil, "", fmt.Errorf("commits: Error retrieving next page commits for repo %s with committish %s and ancestor %s:\n%w", repo, committish, ancestor, err)
 		}
 		allCommits = append(allCommits, response.Log...)
 	}
 	log.Debugf("Retrieved %d commits from %s in %s\n", len(allCommits), repo, time.Since(start))
-	return allCommits, response.NextPageToken != "", nil
+	return allCommits, response.NextPageToken, nil
 }
 
 // CreateGerritURL creates a Gerrit URL from a given
@@ -188,7 +188,7 @@ func TestCommits(t *testing.T) {
 	gobClient, _ := gitiles.NewRESTClient(httpClient, cosGoBURL, false)
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			commits, moreCommits, err := Commits(gobClient, test.Repo, test.SHA, test.AncestorSHA, test.QuerySize)
+			commits, moreCommits, err := Commits(context.Background(), gobClient, test.Repo, test.SHA, test.AncestorSHA, test.QuerySize)
 			if (err != nil) != test.ShouldError {
 				ShouldError := "no error"
 				if test.ShouldError {
@@ -0,0 +1,187 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/term"
+)
+
+const (
+	progressBarWidth = 30
+	// progressLogInterval is how often a download in progress is logged
+	// when the output is not a terminal.
+	progressLogInterval = 10 * time.Second
+)
+
+// ProgressFunc is called as a download makes progress. written is the number
+// of bytes downloaded so far and total is the size of the download, or -1 if
+// the size is unknown. It is called once more with done set when the
+// download finishes.
+type ProgressFunc func(infoStr string, written, total int64, done bool)
+
+var (
+	progressMu       sync.Mutex
+	downloadProgress ProgressFunc
+)
+
+// SetDownloadProgress sets the function that is notified of the progress of
+// the downloads made by this package. A nil fn disables progress reporting.
+func SetDownloadProgress(fn ProgressFunc) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	downloadProgress = fn
+}
+
+func getDownloadProgress() ProgressFunc {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	return downloadProgress
+}
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// NewProgressReporter returns a ProgressFunc that draws a progress bar on f if
+// it is a terminal, and otherwise periodically logs the progress.
+func NewProgressReporter(f *os.File) ProgressFunc {
+	if IsTerminal(f) {
+		return (&progressBar{out: f}).update
+	}
+	return NewProgressLogger()
+}
+
+// NewProgressLogger returns a ProgressFunc that periodically logs the progress
+// instead of drawing a progress bar.
+func NewProgressLogger() ProgressFunc {
+	return (&progressLogger{logf: glog.Infof, now: time.Now, interval: progressLogInterval}).update
+}
+
+// progressBar renders download progress as a single terminal line that is
+// redrawn in place.
+type progressBar struct {
+	out  io.Writer
+	last string
+}
+
+func (p *progressBar) update(infoStr string, written, total int64, done bool) {
+	line := formatProgressBar(infoStr, written, total)
+	if line == p.last && !done {
+		return
+	}
+	p.last = line
+	if done {
+		p.last = ""
+		line += "\n"
+	}
+	fmt.Fprint(p.out, "\r"+line)
+}
+
+// progressLogger logs download progress at most once per interval, for
+// output that is not a terminal.
+type progressLogger struct {
+	logf     func(format string, args ...interface{})
+	now      func() time.Time
+	interval time.Duration
+	last     time.Time
+}
+
+func (p *progressLogger) update(infoStr string, written, total int64, done bool) {
+	now := p.now()
+	if written == 0 && !done {
+		// Start of a new download.
+		p.last = now
+		return
+	}
+	if !done && now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	p.logf("%s", formatProgressLog(infoStr, written, total, done))
+}
+
+// formatProgressBar formats a progress bar line, ex.
+// "driver.run [=======>       ]  50% 12.0 MiB/24.0 MiB".
+func formatProgressBar(infoStr string, written, total int64) string {
+	if total <= 0 {
+		return fmt.Sprintf("%s %s", infoStr, formatBytes(written))
+	}
+	percent := progressPercent(written, total)
+	filled := progressBarWidth * percent / 100
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %3d%% %s/%s", infoStr, bar, percent, formatBytes(written), formatBytes(total))
+}
+
+// formatProgressLog formats a log line for a download in progress, ex.
+// "Downloading driver.run: 12.0 MiB of 24.0 MiB (50%)".
+func formatProgressLog(infoStr string, written, total int64, done bool) string {
+	verb := "Downloading"
+	if done {
+		verb = "Downloaded"
+	}
+	if total <= 0 {
+		return fmt.Sprintf("%s %s: %s", verb, infoStr, formatBytes(written))
+	}
+	return fmt.Sprintf("%s %s: %s of %s (%d%%)", verb, infoStr, formatBytes(written), formatBytes(total), progressPercent(written, total))
+}
+
+func progressPercent(written, total int64) int {
+	if written >= total {
+		return 100
+	}
+	return int(written * 100 / total)
+}
+
+// formatBytes formats a byte count with a binary unit, ex. "1.5 KiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// progressWriter reports the bytes written through it to a ProgressFunc.
+type progressWriter struct {
+	w        io.Writer
+	infoStr  string
+	written  int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.progress(p.infoStr, p.written, p.total, false)
+	return n, err
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormatProgressLog(t *testing.T) {
+	for _, tc := range []struct {
+		testName string
+		written  int64
+		total    int64
+		done     bool
+		want     string
+	}{
+		{
+			testName: "InProgress",
+			written:  12 << 20,
+			total:    24 << 20,
+			want:     "Downloading driver.run: 12.0 MiB of 24.0 MiB (50%)",
+		},
+		{
+			testName: "Done",
+			written:  24 << 20,
+			total:    24 << 20,
+			done:     true,
+			want:     "Downloaded driver.run: 24.0 MiB of 24.0 MiB (100%)",
+		},
+		{
+			testName: "UnknownTotal",
+			written:  1536,
+			total:    -1,
+			want:     "Downloading driver.run: 1.5 KiB",
+		},
+		{
+			testName: "Bytes",
+			written:  512,
+			total:    3 << 30,
+			want:     "Downloading driver.run: 512 B of 3.0 GiB (0%)",
+		},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			if got := formatProgressLog("driver.run", tc.written, tc.total, tc.done); got != tc.want {
+				t.Errorf("formatProgressLog(%d, %d, %v) = %q, want %q", tc.written, tc.total, tc.done, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProgressLogger(t *testing.T) {
+	var now time.Time
+	var lines []string
+	logger := &progressLogger{
+		logf:     func(format string, args ...interface{}) { lines = append(lines, fmt.Sprintf(format, args...)) },
+		now:      func() time.Time { return now },
+		interval: 10 * time.Second,
+	}
+	for _, step := range []struct {
+		elapsed time.Duration
+		written int64
+		done    bool
+	}{
+		{0, 0, false},
+		{time.Second, 1024, false},
+		{10 * time.Second, 2048, false},
+		{15 * time.Second, 3072, false},
+		{21 * time.Second, 4096, false},
+		{22 * time.Second, 4096, true},
+	} {
+		now = time.Unix(0, 0).Add(step.elapsed)
+		logger.update("toolchain.tar.xz", step.written, 4096, step.done)
+	}
+	want := []string{
+		"Downloading toolchain.tar.xz: 2.0 KiB of 4.0 KiB (50%)",
+		"Downloading toolchain.tar.xz: 4.0 KiB of 4.0 KiB (100%)",
+		"Downloaded toolchain.tar.xz: 4.0 KiB of 4.0 KiB (100%)",
+	}
+	if diff := cmp.Diff(want, lines); diff != "" {
+		t.Errorf("progressLogger logged unexpected lines (-want +got):\n%s", diff)
+	}
+}
+
+func TestFormatProgressBar(t *testing.T) {
+	want := "driver.run [===============>              ]  50% 12.0 MiB/24.0 MiB"
+	if got := formatProgressBar("driver.run", 12<<20, 24<<20); got != want {
+		t.Errorf("formatProgressBar() = %q, want %q", got, want)
+	}
+}
+
+func TestDownloadContentFromURLToProgress(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 4096)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		w.Write(content)
+	}))
+	defer ts.Close()
+
+	var totals []int64
+	var written int64
+	var done bool
+	SetDownloadProgress(func(infoStr string, n, total int64, d bool) {
+		totals = append(totals, total)
+		written, done = n, d
+	})
+	defer SetDownloadProgress(nil)
+
+	var buf bytes.Buffer
+	if err := DownloadContentFromURLTo(&buf, ts.URL, "test file"); err != nil {
+		t.Fatalf("DownloadContentFromURLTo() failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("DownloadContentFromURLTo() wrote %d bytes, want %d", buf.Len(), len(content))
+	}
+	if written != int64(len(content)) || !done {
+		t.Errorf("last progress update = (%d, done=%v), want (%d, done=true)", written, done, len(content))
+	}
+	for _, total := range totals {
+		if total != int64(len(content)) {
+			t.Errorf("progress reported total %d, want %d", total, len(content))
+		}
+	}
+}
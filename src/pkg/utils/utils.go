@@ -84,6 +84,16 @@ func Flock() *os.File {
 
 // DownloadContentFromURL downloads file from a given URL.
 func DownloadContentFromURL(url, outputPath, infoStr string) error {
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %s", outputPath)
+	}
+	defer outputFile.Close()
+	return DownloadContentFromURLTo(outputFile, url, infoStr)
+}
+
+// DownloadContentFromURLTo downloads content from a given URL and writes it to w.
+func DownloadContentFromURLTo(w io.Writer, url, infoStr string) error {
 	url = strings.TrimSpace(url)
 	glog.Infof("Downloading %s from %s", infoStr, url)
 
@@ -101,12 +111,6 @@ func DownloadContentFromURL(url, outputPath, infoStr string) error {
 		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
-		return errors.Wrapf(err, "failed to create file %s", outputPath)
-	}
-	defer outputFile.Close()
-
 	client := &http.Client{}
 
 	var response *http.Response
@@ -129,9 +133,18 @@ func DownloadContentFromURL(url, outputPath, infoStr string) error {
 	if response.StatusCode != 200 {
 		return errors.Errorf("failed to download %s, status: %s", infoStr, response.Status)
 	}
-	if _, err := io.Copy(outputFile, response.Body); err != nil {
+	progress := getDownloadProgress()
+	if progress != nil {
+		progress(infoStr, 0, response.ContentLength, false)
+		w = &progressWriter{w: w, infoStr: infoStr, total: response.ContentLength, progress: progress}
+	}
+	written, err := io.Copy(w, response.Body)
+	if err != nil {
 		return errors.Wrapf(err, "failed to download %s", infoStr)
 	}
+	if progress != nil {
+		progress(infoStr, written, response.ContentLength, true)
+	}
 
 	glog.V(2).Infof("Successfully downloaded %s from %s", infoStr, url)
 	return nil
@@ -145,6 +158,12 @@ func DownloadFromGCS(destDir, gcsBucket, gcsPath string) error {
 	return DownloadContentFromURL(downloadURL, outputPath, filename)
 }
 
+// DownloadFromGCSTo downloads an object from the given GCS path and writes it to w.
+func DownloadFromGCSTo(w io.Writer, gcsBucket, gcsPath string) error {
+	downloadURL := fmt.Sprintf("https://storage.googleapis.com/%s/%s", gcsBucket, gcsPath)
+	return DownloadContentFromURLTo(w, downloadURL, filepath.Base(gcsPath))
+}
+
 // ListGCSBucket lists the objects whose names begin with the given prefix in the given GCS bucket.
 func ListGCSBucket(bucket, prefix string) ([]string, error) {
 	glog.V(2).Infof("Listing objects from GCS bucket %s with prefix %s", bucket, prefix)